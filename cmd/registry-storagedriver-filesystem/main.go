@@ -21,7 +21,12 @@ func main() {
 		panic(err)
 	}
 
-	if err := ipc.StorageDriverServer(filesystem.FromParameters(parameters)); err != nil {
+	driver, err := filesystem.FromParameters(parameters)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ipc.StorageDriverServer(driver); err != nil {
 		logrus.Fatalln(err)
 	}
 }
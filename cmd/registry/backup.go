@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/distribution/registry/storage"
+)
+
+// runBackup implements the backup command, which writes the metadata of the
+// storage backend configured in the given configuration to an archive:
+//
+//	registry backup <config> <archive>
+//
+// The archive holds the tags, manifests and layer links of every repository,
+// the repository index and the robot accounts, but not the layer blobs. It is
+// written to the standard output if it is "-". Prefer enabling read-only mode
+// while backing up a running registry, so that the archive is consistent.
+func runBackup(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "backup <config> <archive>")
+		os.Exit(1)
+	}
+
+	ctx, driver := repoDriver(args[0])
+
+	w, report := io.Writer(os.Stdout), os.Stderr
+	if args[1] != "-" {
+		f, err := os.Create(args[1])
+		if err != nil {
+			repoFatalf("error creating archive: %v", err)
+		}
+		defer f.Close()
+		w, report = f, os.Stdout
+	}
+
+	written, err := storage.BackupMetadata(ctx, driver, w)
+	if err != nil {
+		repoFatalf("error backing up metadata: %v", err)
+	}
+
+	fmt.Fprintf(report, "backed up %d files\n", written)
+}
+
+// runRestore implements the restore command, which stores the metadata of an
+// archive written by the backup command in the storage backend configured in
+// the given configuration:
+//
+//	registry restore <config> <archive>
+//
+// The backend must still hold the layer blobs. Existing metadata with the
+// same paths is replaced, and other metadata is left in place. The archive is
+// read from the standard input if it is "-".
+func runRestore(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "restore <config> <archive>")
+		os.Exit(1)
+	}
+
+	ctx, driver := repoDriver(args[0])
+
+	r := io.Reader(os.Stdin)
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			repoFatalf("error opening archive: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	restored, err := storage.RestoreMetadata(ctx, driver, r)
+	if err != nil {
+		repoFatalf("error restoring metadata: %v", err)
+	}
+
+	fmt.Printf("restored %d files\n", restored)
+}
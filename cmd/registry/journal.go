@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	"github.com/docker/distribution/registry/storage/driver/middleware/journal"
+)
+
+// runJournal implements the journal command, which replays the metadata
+// mutations recorded by the journal storage middleware to the storage backend
+// configured in the given configuration:
+//
+//	registry journal replay [-until <time>] <config> <journal>
+//
+// Replaying a journal over a copy of the blob store reconstructs the tags,
+// manifest revisions and layer links of the registry as they were at the
+// given time. The storage middleware of the configuration is not applied, so
+// that the replay is not journaled again.
+func runJournal(args []string) {
+	if len(args) == 0 || args[0] != "replay" {
+		journalUsage()
+		os.Exit(1)
+	}
+
+	var untilStr string
+
+	flags := flag.NewFlagSet("journal replay", flag.ExitOnError)
+	flags.StringVar(&untilStr, "until", "", "only replay the mutations recorded up to this RFC3339 time")
+	flags.Usage = func() {
+		journalUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	var until time.Time
+	if untilStr != "" {
+		var err error
+		until, err = time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			repoFatalf("invalid time %q: %v", untilStr, err)
+		}
+	}
+
+	config, err := parseConfiguration(flags.Arg(0))
+	if err != nil {
+		repoFatalf("configuration error: %v", err)
+	}
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		repoFatalf("error creating storage driver: %v", err)
+	}
+
+	f, err := os.Open(flags.Arg(1))
+	if err != nil {
+		repoFatalf("error opening journal: %v", err)
+	}
+	defer f.Close()
+
+	applied, err := journal.Replay(context.Background(), driver, f, until)
+	if err != nil {
+		repoFatalf("%v after replaying %d mutations", err, applied)
+	}
+
+	fmt.Printf("replayed %d mutations\n", applied)
+}
+
+func journalUsage() {
+	fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "journal replay [-until <time>] <config> <journal>")
+}
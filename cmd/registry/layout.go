@@ -0,0 +1,514 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+)
+
+// The export and import commands transfer repositories as tar archives of
+// OCI image layouts. The index of a layout lists the exported manifests,
+// which are kept in their signed form, and the blobs directory holds the
+// manifests and their layers, addressed by digest.
+const (
+	// layoutVersion is the version of the image layouts written and read.
+	layoutVersion = "1.0.0"
+
+	// refNameAnnotation is the annotation of the index naming the tag of
+	// a manifest.
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// imageLayout is the content of the oci-layout file of an image layout.
+type imageLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+// layoutDescriptor describes a blob of an image layout.
+type layoutDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      digest.Digest     `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// layoutIndex is the content of the index.json file of an image layout.
+type layoutIndex struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	Manifests     []layoutDescriptor `json:"manifests"`
+}
+
+// layoutReference is a repository given on the command line, with either a
+// tag or a digest to select a single manifest.
+type layoutReference struct {
+	name string
+	tag  string
+	dgst digest.Digest
+}
+
+// runExport implements the export command, which writes repositories of the
+// storage backend configured in the given configuration to an archive:
+//
+//	registry export <config> <archive> <name>[:<tag>|@<digest>]...
+//
+// All tags of a repository are exported unless a tag or digest is given. The
+// archive is written to the standard output if it is "-".
+func runExport(args []string) {
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "export <config> <archive> <name>[:<tag>|@<digest>]...")
+		os.Exit(1)
+	}
+
+	ctx, registry := repoRegistry(args[0])
+
+	var references []layoutReference
+	for _, arg := range args[2:] {
+		name, tag, dgst := parseRepoReference(arg)
+		references = append(references, layoutReference{name: name, tag: tag, dgst: dgst})
+	}
+
+	w, report := io.Writer(os.Stdout), os.Stderr
+	if args[1] != "-" {
+		f, err := os.Create(args[1])
+		if err != nil {
+			repoFatalf("error creating archive: %v", err)
+		}
+		defer f.Close()
+		w, report = f, os.Stdout
+	}
+
+	exported, err := exportLayout(ctx, registry, w, references)
+	if err != nil {
+		repoFatalf("error exporting repositories: %v", err)
+	}
+
+	for _, reference := range exported {
+		fmt.Fprintf(report, "exported %s\n", reference)
+	}
+}
+
+// runImport implements the import command, which stores the repositories of
+// an archive written by the export command in the storage backend
+// configured in the given configuration:
+//
+//	registry import <config> <archive>
+//
+// Manifests are stored in the repositories they are signed for, and replace
+// the existing manifests with the same tags.
+func runImport(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "import <config> <archive>")
+		os.Exit(1)
+	}
+
+	ctx, registry := repoRegistry(args[0])
+
+	// The archive is read several times, so it cannot be a stream.
+	f, err := os.Open(args[1])
+	if err != nil {
+		repoFatalf("error opening archive: %v", err)
+	}
+	defer f.Close()
+
+	imported, err := importLayout(ctx, registry, f)
+	if err != nil {
+		repoFatalf("error importing repositories: %v", err)
+	}
+
+	for _, reference := range imported {
+		fmt.Printf("imported %s\n", reference)
+	}
+}
+
+// exportLayout writes the manifests selected by references and their layers
+// to w as an image layout archive, returning the exported tags.
+func exportLayout(ctx context.Context, registry distribution.Namespace, w io.Writer, references []layoutReference) ([]string, error) {
+	type layerRef struct {
+		name string
+		dgst digest.Digest
+	}
+
+	var (
+		index     = layoutIndex{SchemaVersion: 2}
+		manifests = map[digest.Digest][]byte{}
+		layers    []layerRef
+		seen      = map[string]bool{}
+		exported  []string
+	)
+
+	for _, reference := range references {
+		repo, err := registry.Repository(ctx, reference.name)
+		if err != nil {
+			return nil, err
+		}
+
+		var sms []*manifest.SignedManifest
+		switch {
+		case reference.tag != "":
+			sm, err := repo.Manifests().GetByTag(reference.tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%s: %v", reference.name, reference.tag, err)
+			}
+			sms = append(sms, sm)
+		case reference.dgst != "":
+			sm, err := repo.Manifests().Get(reference.dgst)
+			if err != nil {
+				return nil, fmt.Errorf("%s@%s: %v", reference.name, reference.dgst, err)
+			}
+			sms = append(sms, sm)
+		default:
+			tags, err := repo.Manifests().Tags()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", reference.name, err)
+			}
+
+			for _, tag := range tags {
+				sm, err := repo.Manifests().GetByTag(tag)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%s: %v", reference.name, tag, err)
+				}
+				sms = append(sms, sm)
+			}
+		}
+
+		for _, sm := range sms {
+			dgst, err := digest.FromBytes(sm.Raw)
+			if err != nil {
+				return nil, err
+			}
+
+			tagged := reference.name + ":" + sm.Tag
+			if seen[tagged+"@"+dgst.String()] {
+				continue
+			}
+			seen[tagged+"@"+dgst.String()] = true
+
+			index.Manifests = append(index.Manifests, layoutDescriptor{
+				MediaType:   manifest.ManifestMediaType,
+				Digest:      dgst,
+				Size:        int64(len(sm.Raw)),
+				Annotations: map[string]string{refNameAnnotation: sm.Tag},
+			})
+			manifests[dgst] = sm.Raw
+			exported = append(exported, tagged)
+
+			for _, fsLayer := range sm.FSLayers {
+				if !seen[fsLayer.BlobSum.String()] {
+					seen[fsLayer.BlobSum.String()] = true
+					layers = append(layers, layerRef{name: reference.name, dgst: fsLayer.BlobSum})
+				}
+			}
+		}
+	}
+
+	tw := tar.NewWriter(w)
+
+	layout, err := json.Marshal(imageLayout{ImageLayoutVersion: layoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLayoutFile(tw, "oci-layout", int64(len(layout)), bytes.NewReader(layout)); err != nil {
+		return nil, err
+	}
+
+	// The index is written first, so that readers streaming the archive can
+	// tell the manifests from the layers.
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLayoutFile(tw, "index.json", int64(len(indexJSON)), bytes.NewReader(indexJSON)); err != nil {
+		return nil, err
+	}
+
+	for _, descriptor := range index.Manifests {
+		raw, ok := manifests[descriptor.Digest]
+		if !ok {
+			// Already written for another tag.
+			continue
+		}
+		delete(manifests, descriptor.Digest)
+
+		if err := writeLayoutFile(tw, layoutBlobPath(descriptor.Digest), int64(len(raw)), bytes.NewReader(raw)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ref := range layers {
+		repo, err := registry.Repository(ctx, ref.name)
+		if err != nil {
+			return nil, err
+		}
+
+		layer, err := repo.Layers().Fetch(ref.dgst)
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: %v", ref.name, ref.dgst, err)
+		}
+
+		err = writeLayoutFile(tw, layoutBlobPath(ref.dgst), layer.Length(), layer)
+		layer.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return exported, nil
+}
+
+// importLayout stores the manifests of the image layout archive read from r
+// and their layers, returning the imported tags.
+func importLayout(ctx context.Context, registry distribution.Namespace, r io.ReadSeeker) ([]string, error) {
+	var (
+		layout *imageLayout
+		index  *layoutIndex
+	)
+
+	err := walkLayout(r, func(name string, content io.Reader) error {
+		switch name {
+		case "oci-layout":
+			layout = &imageLayout{}
+			return json.NewDecoder(content).Decode(layout)
+		case "index.json":
+			index = &layoutIndex{}
+			return json.NewDecoder(content).Decode(index)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case layout == nil || index == nil:
+		return nil, fmt.Errorf("not an image layout archive")
+	case layout.ImageLayoutVersion != layoutVersion:
+		return nil, fmt.Errorf("unsupported image layout version %q", layout.ImageLayoutVersion)
+	}
+
+	manifests := map[digest.Digest]*manifest.SignedManifest{}
+	for _, descriptor := range index.Manifests {
+		if descriptor.MediaType != manifest.ManifestMediaType {
+			return nil, fmt.Errorf("unsupported media type %q of manifest %s", descriptor.MediaType, descriptor.Digest)
+		}
+		manifests[descriptor.Digest] = nil
+	}
+
+	err = walkLayout(r, func(name string, content io.Reader) error {
+		dgst, ok := layoutBlobDigest(name)
+		if !ok {
+			return nil
+		}
+
+		if sm, ok := manifests[dgst]; !ok || sm != nil {
+			return nil
+		}
+
+		raw, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		if computed, err := digest.FromBytes(raw); err != nil || computed != dgst {
+			return fmt.Errorf("manifest %s does not match its digest", dgst)
+		}
+
+		var sm manifest.SignedManifest
+		if err := json.Unmarshal(raw, &sm); err != nil {
+			return fmt.Errorf("invalid manifest %s: %v", dgst, err)
+		}
+		manifests[dgst] = &sm
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Layers are stored in every repository referencing them.
+	layers := map[digest.Digest][]string{}
+	for dgst, sm := range manifests {
+		if sm == nil {
+			return nil, fmt.Errorf("manifest %s missing from archive", dgst)
+		}
+
+	fsLayers:
+		for _, fsLayer := range sm.FSLayers {
+			for _, name := range layers[fsLayer.BlobSum] {
+				if name == sm.Name {
+					continue fsLayers
+				}
+			}
+			layers[fsLayer.BlobSum] = append(layers[fsLayer.BlobSum], sm.Name)
+		}
+	}
+
+	err = walkLayout(r, func(name string, content io.Reader) error {
+		dgst, ok := layoutBlobDigest(name)
+		if !ok {
+			return nil
+		}
+
+		names, ok := layers[dgst]
+		if !ok {
+			return nil
+		}
+		delete(layers, dgst)
+
+		// The content of the archive can only be read once, so the layer is
+		// copied from the first repository to the others.
+		var stored distribution.Repository
+		for _, name := range names {
+			repo, err := registry.Repository(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			exists, err := repo.Layers().Exists(dgst)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case exists:
+			case stored == nil:
+				err = importLayer(repo, dgst, content)
+			default:
+				var layer distribution.Layer
+				layer, err = stored.Layers().Fetch(dgst)
+				if err == nil {
+					err = importLayer(repo, dgst, layer)
+					layer.Close()
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("%s@%s: %v", name, dgst, err)
+			}
+
+			stored = repo
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []string
+	for _, descriptor := range index.Manifests {
+		sm := manifests[descriptor.Digest]
+
+		repo, err := registry.Repository(ctx, sm.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			return nil, fmt.Errorf("%s:%s: %v", sm.Name, sm.Tag, err)
+		}
+
+		imported = append(imported, sm.Name+":"+sm.Tag)
+	}
+
+	return imported, nil
+}
+
+// importLayer uploads the layer read from r to repo.
+func importLayer(repo distribution.Repository, dgst digest.Digest, r io.Reader) error {
+	upload, err := repo.Layers().Upload()
+	if err != nil {
+		return err
+	}
+
+	if _, err := upload.ReadFrom(r); err != nil {
+		upload.Cancel()
+		return err
+	}
+
+	if _, err := upload.Finish(dgst); err != nil {
+		upload.Cancel()
+		return err
+	}
+
+	return nil
+}
+
+// walkLayout calls fn with the name and content of each regular file of the
+// image layout archive read from r, from its start.
+func walkLayout(r io.ReadSeeker, fn func(name string, content io.Reader) error) error {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := fn(strings.TrimPrefix(path.Clean(hdr.Name), "./"), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeLayoutFile writes a regular file to the image layout archive.
+func writeLayoutFile(tw *tar.Writer, name string, size int64, content io.Reader) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, content)
+	return err
+}
+
+// layoutBlobPath returns the path of the blob with the given digest in an
+// image layout.
+func layoutBlobPath(dgst digest.Digest) string {
+	return path.Join("blobs", dgst.Algorithm(), dgst.Hex())
+}
+
+// layoutBlobDigest returns the digest of the blob at the given path of an
+// image layout, if it is one.
+func layoutBlobDigest(name string) (digest.Digest, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] != "blobs" {
+		return "", false
+	}
+
+	dgst, err := digest.ParseDigest(parts[1] + ":" + parts[2])
+	if err != nil {
+		return "", false
+	}
+
+	return dgst, true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/libtrust"
+)
+
+// TestExportImportLayout checks that repositories exported to an image
+// layout archive are imported with the same manifests and layers.
+func TestExportImportLayout(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewRegistryWithDriver(ctx, inmemory.New(), nil)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	// Both repositories share a layer.
+	shared := []byte("shared layer")
+	putTestManifest(t, ctx, source, pk, "foo/bar", "latest", shared, []byte("bar layer"))
+	putTestManifest(t, ctx, source, pk, "foo/bar", "stable", shared)
+	putTestManifest(t, ctx, source, pk, "foo/baz", "latest", shared)
+
+	archive, err := ioutil.TempFile("", "layout")
+	if err != nil {
+		t.Fatalf("unexpected error creating archive: %v", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	exported, err := exportLayout(ctx, source, archive, []layoutReference{
+		{name: "foo/bar"},
+		{name: "foo/baz", tag: "latest"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error exporting repositories: %v", err)
+	}
+
+	expected := []string{"foo/bar:latest", "foo/bar:stable", "foo/baz:latest"}
+	if !reflect.DeepEqual(exported, expected) {
+		t.Fatalf("unexpected exported tags: %v != %v", exported, expected)
+	}
+
+	destination := storage.NewRegistryWithDriver(ctx, inmemory.New(), nil)
+	imported, err := importLayout(ctx, destination, archive)
+	if err != nil {
+		t.Fatalf("unexpected error importing repositories: %v", err)
+	}
+
+	if !reflect.DeepEqual(imported, expected) {
+		t.Fatalf("unexpected imported tags: %v != %v", imported, expected)
+	}
+
+	for _, name := range []string{"foo/bar", "foo/baz"} {
+		src, _ := source.Repository(ctx, name)
+		dst, err := destination.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository: %v", err)
+		}
+
+		tags, err := src.Manifests().Tags()
+		if err != nil {
+			t.Fatalf("unexpected error listing tags: %v", err)
+		}
+
+		for _, tag := range tags {
+			expectedManifest, _ := src.Manifests().GetByTag(tag)
+			sm, err := dst.Manifests().GetByTag(tag)
+			if err != nil {
+				t.Fatalf("unexpected error getting imported manifest %s:%s: %v", name, tag, err)
+			}
+
+			if !bytes.Equal(sm.Raw, expectedManifest.Raw) {
+				t.Fatalf("unexpected imported manifest %s:%s", name, tag)
+			}
+
+			for _, fsLayer := range sm.FSLayers {
+				if exists, err := dst.Layers().Exists(fsLayer.BlobSum); err != nil || !exists {
+					t.Fatalf("layer %s of %s:%s not imported: %v", fsLayer.BlobSum, name, tag, err)
+				}
+			}
+		}
+	}
+}
+
+// putTestManifest uploads the layers and puts a manifest referencing them
+// with the given tag.
+func putTestManifest(t *testing.T, ctx context.Context, registry distribution.Namespace, pk libtrust.PrivateKey, name, tag string, layers ...[]byte) {
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: name,
+		Tag:  tag,
+	}
+
+	for _, content := range layers {
+		dgst, err := digest.FromBytes(content)
+		if err != nil {
+			t.Fatalf("unexpected error digesting layer: %v", err)
+		}
+
+		if err := importLayer(repo, dgst, bytes.NewReader(content)); err != nil {
+			t.Fatalf("unexpected error uploading layer: %v", err)
+		}
+
+		m.FSLayers = append(m.FSLayers, manifest.FSLayer{BlobSum: dgst})
+		m.History = append(m.History, manifest.History{V1Compatibility: "{}"})
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+}
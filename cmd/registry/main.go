@@ -2,13 +2,10 @@ package main
 
 import (
 	"crypto/tls"
-	"crypto/x509"
-	_ "expvar"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"time"
 
@@ -17,11 +14,13 @@ import (
 	"github.com/bugsnag/bugsnag-go"
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/context"
-	_ "github.com/docker/distribution/health"
+	_ "github.com/docker/distribution/registry/auth/anonymous"
+	_ "github.com/docker/distribution/registry/auth/chain"
 	_ "github.com/docker/distribution/registry/auth/silly"
 	_ "github.com/docker/distribution/registry/auth/token"
 	"github.com/docker/distribution/registry/handlers"
 	"github.com/docker/distribution/registry/listener"
+	_ "github.com/docker/distribution/registry/middleware/http/headers"
 	_ "github.com/docker/distribution/registry/storage/driver/azure"
 	_ "github.com/docker/distribution/registry/storage/driver/filesystem"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
@@ -48,6 +47,36 @@ func main() {
 		return
 	}
 
+	switch flag.Arg(0) {
+	case "sync":
+		runSync(flag.Args()[1:])
+		return
+	case "reindex":
+		runReindex(flag.Args()[1:])
+		return
+	case "repo":
+		runRepo(flag.Args()[1:])
+		return
+	case "verify":
+		runVerify(flag.Args()[1:])
+		return
+	case "export":
+		runExport(flag.Args()[1:])
+		return
+	case "import":
+		runImport(flag.Args()[1:])
+		return
+	case "backup":
+		runBackup(flag.Args()[1:])
+		return
+	case "restore":
+		runRestore(flag.Args()[1:])
+		return
+	case "journal":
+		runJournal(flag.Args()[1:])
+		return
+	}
+
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, "version", version.Version)
 
@@ -66,66 +95,111 @@ func main() {
 	handler = gorhandlers.CombinedLoggingHandler(os.Stdout, handler)
 
 	if config.HTTP.Debug.Addr != "" {
-		go debugServer(config.HTTP.Debug.Addr)
+		debugHandler, err := handlers.NewDebugHandler(config.HTTP.Debug)
+		if err != nil {
+			context.GetLogger(app).Fatalln(err)
+		}
+
+		go debugServer(config.HTTP.Debug.Addr, debugHandler)
+	}
+
+	if config.HTTP.Debug.Admin && config.Admin.Addr == "" {
+		context.GetLogger(app).Warnf("debug endpoints not served on the admin interface, which has no address configured")
+	}
+
+	if config.Admin.Addr != "" {
+		adminApp, err := handlers.NewAdminApp(app, resolveConfiguration)
+		if err != nil {
+			context.GetLogger(app).Fatalln(err)
+		}
+
+		go adminServer(config.Admin.Net, config.Admin.Addr, socketOptions(config.Admin.Socket), adminApp)
 	}
 
 	server := &http.Server{
 		Handler: handler,
 	}
 
-	ln, err := listener.NewListener(config.HTTP.Net, config.HTTP.Addr)
-	if err != nil {
+	// The main listener is served along with the additional ones.
+	listeners := append([]configuration.Listener{{
+		Net:    config.HTTP.Net,
+		Addr:   config.HTTP.Addr,
+		Socket: config.HTTP.Socket,
+		TLS:    config.HTTP.TLS,
+	}}, config.HTTP.Listeners...)
+
+	errs := make(chan error, len(listeners))
+	for _, listenerConfig := range listeners {
+		ln, err := newServerListener(app, listenerConfig)
+		if err != nil {
+			context.GetLogger(app).Fatalln(err)
+		}
+		defer ln.Close()
+
+		go func(ln net.Listener) {
+			errs <- server.Serve(ln)
+		}(ln)
+	}
+
+	if err := <-errs; err != nil {
 		context.GetLogger(app).Fatalln(err)
 	}
-	defer ln.Close()
+}
 
-	if config.HTTP.TLS.Certificate != "" {
-		tlsConf := &tls.Config{
-			ClientAuth:   tls.NoClientCert,
-			NextProtos:   []string{"http/1.1"},
-			Certificates: make([]tls.Certificate, 1),
-		}
+// newServerListener returns a listener of the registry api, with TLS if a
+// certificate is configured and restricted to the configured networks.
+func newServerListener(app *handlers.App, config configuration.Listener) (net.Listener, error) {
+	ln, err := listener.NewListenerWithOptions(config.Net, config.Addr, socketOptions(config.Socket))
+	if err != nil {
+		return nil, err
+	}
 
-		tlsConf.Certificates[0], err = tls.LoadX509KeyPair(config.HTTP.TLS.Certificate, config.HTTP.TLS.Key)
+	if len(config.Networks) > 0 {
+		ln, err = listener.RestrictNetworks(ln, config.Networks)
 		if err != nil {
-			context.GetLogger(app).Fatalln(err)
+			ln.Close()
+			return nil, fmt.Errorf("invalid networks of listener %s: %v", config.Addr, err)
 		}
+	}
 
-		if len(config.HTTP.TLS.ClientCAs) != 0 {
-			pool := x509.NewCertPool()
-
-			for _, ca := range config.HTTP.TLS.ClientCAs {
-				caPem, err := ioutil.ReadFile(ca)
-				if err != nil {
-					context.GetLogger(app).Fatalln(err)
-				}
+	name := ""
+	if config.Name != "" {
+		name = " (" + config.Name + ")"
+	}
 
-				if ok := pool.AppendCertsFromPEM(caPem); !ok {
-					context.GetLogger(app).Fatalln(fmt.Errorf("Could not add CA to pool"))
-				}
-			}
+	if config.TLS.Certificate != "" {
+		tlsConf, err := newTLSConfig(config.TLS)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
 
-			for _, subj := range pool.Subjects() {
+		if tlsConf.ClientCAs != nil {
+			for _, subj := range tlsConf.ClientCAs.Subjects() {
 				context.GetLogger(app).Debugf("CA Subject: %s", string(subj))
 			}
-
-			tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
-			tlsConf.ClientCAs = pool
 		}
 
 		ln = tls.NewListener(ln, tlsConf)
-		context.GetLogger(app).Infof("listening on %v, tls", ln.Addr())
+		context.GetLogger(app).Infof("listening on %v%s, tls", ln.Addr(), name)
 	} else {
-		context.GetLogger(app).Infof("listening on %v", ln.Addr())
+		context.GetLogger(app).Infof("listening on %v%s", ln.Addr(), name)
 	}
 
-	if err := server.Serve(ln); err != nil {
-		context.GetLogger(app).Fatalln(err)
-	}
+	return ln, nil
 }
 
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "<config>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "sync [options] <source> <destination> <repository>...")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "reindex <config>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "repo ls|inspect|rm [options] <config> ...")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "verify <config> <name>@<digest>...")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "export <config> <archive> <name>[:<tag>|@<digest>]...")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "import <config> <archive>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "backup <config> <archive>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "restore <config> <archive>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "journal replay [-until <time>] <config> <journal>")
 	flag.PrintDefaults()
 }
 
@@ -144,6 +218,11 @@ func resolveConfiguration() (*configuration.Configuration, error) {
 		configurationPath = os.Getenv("REGISTRY_CONFIGURATION_PATH")
 	}
 
+	return parseConfiguration(configurationPath)
+}
+
+// parseConfiguration reads the configuration file at configurationPath.
+func parseConfiguration(configurationPath string) (*configuration.Configuration, error) {
 	if configurationPath == "" {
 		return nil, fmt.Errorf("configuration path unspecified")
 	}
@@ -268,9 +347,34 @@ func logLevel(level configuration.Loglevel) log.Level {
 // debugServer starts the debug server with pprof, expvar among other
 // endpoints. The addr should not be exposed externally. For most of these to
 // work, tls cannot be enabled on the endpoint, so it is generally separate.
-func debugServer(addr string) {
+func debugServer(addr string, handler http.Handler) {
 	log.Infof("debug server listening %v", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(addr, handler); err != nil {
 		log.Fatalf("error listening on debug interface: %v", err)
 	}
 }
+
+// adminServer starts the admin server, which hosts operational endpoints
+// such as read-only mode, cache flushes and configuration reloads. Like the
+// debug server, it should not be exposed externally.
+func adminServer(network, addr string, options listener.SocketOptions, handler http.Handler) {
+	ln, err := listener.NewListenerWithOptions(network, addr, options)
+	if err != nil {
+		log.Fatalf("error listening on admin interface: %v", err)
+	}
+	defer ln.Close()
+
+	log.Infof("admin server listening %v", ln.Addr())
+	if err := http.Serve(ln, gorhandlers.CombinedLoggingHandler(os.Stdout, handler)); err != nil {
+		log.Fatalf("error serving admin interface: %v", err)
+	}
+}
+
+// socketOptions returns the listener options of the socket configuration.
+func socketOptions(socket configuration.Socket) listener.SocketOptions {
+	return listener.SocketOptions{
+		Mode:  socket.Mode,
+		Owner: socket.Owner,
+		Group: socket.Group,
+	}
+}
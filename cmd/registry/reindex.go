@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+)
+
+// runReindex implements the reindex command, which rebuilds the repository
+// index of the storage backend configured in the given configuration:
+//
+//	registry reindex <config>
+//
+// It can be run against a live registry. Repositories pushed while it runs
+// are indexed by the registry itself, if the index is enabled.
+func runReindex(args []string) {
+	configurationPath := os.Getenv("REGISTRY_CONFIGURATION_PATH")
+	if len(args) > 0 {
+		configurationPath = args[0]
+	}
+
+	config, err := parseConfiguration(configurationPath)
+	if err != nil {
+		fatalf("configuration error: %v", err)
+	}
+
+	ctx := context.Background()
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating storage driver: %v\n", err)
+		os.Exit(1)
+	}
+
+	repositories, err := storage.RebuildRepositoryIndex(ctx, driver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error rebuilding repository index: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("indexed %d repositories\n", len(repositories))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// runRepo implements the repo command, which operates on the repositories of
+// the storage backend configured in the given configuration directly, without
+// going through the registry API:
+//
+//	registry repo ls [options] <config>
+//	registry repo inspect <config> <name>[:<tag>|@<digest>]
+//	registry repo rm [options] <config> <name>[:<tag>]
+//
+// It is meant for maintenance while the API is unavailable. Removals are not
+// coordinated with running registries, and the blobs of removed tags and
+// repositories are left in place.
+func runRepo(args []string) {
+	if len(args) == 0 {
+		repoUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "ls":
+		runRepoList(args[1:])
+	case "inspect":
+		runRepoInspect(args[1:])
+	case "rm":
+		runRepoRemove(args[1:])
+	default:
+		repoUsage()
+		os.Exit(1)
+	}
+}
+
+func repoUsage() {
+	fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "repo ls [options] <config>")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "repo inspect <config> <name>[:<tag>|@<digest>]")
+	fmt.Fprintln(os.Stderr, "       ", os.Args[0], "repo rm [options] <config> <name>[:<tag>]")
+}
+
+// runRepoList lists the repositories, walking the repositories tree unless
+// the repository index is used.
+func runRepoList(args []string) {
+	var index bool
+
+	flags := flag.NewFlagSet("repo ls", flag.ExitOnError)
+	flags.BoolVar(&index, "index", false, "list the repository index instead of walking the repositories")
+	flags.Usage = func() {
+		repoUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ctx, driver := repoDriver(flags.Arg(0))
+
+	list := storage.FindRepositories
+	if index {
+		list = storage.ListRepositories
+	}
+
+	repositories, err := list(ctx, driver)
+	if err != nil {
+		repoFatalf("error listing repositories: %v", err)
+	}
+
+	for _, name := range repositories {
+		fmt.Println(name)
+	}
+}
+
+// runRepoInspect prints the tags of a repository, or the manifest with the
+// given tag or digest.
+func runRepoInspect(args []string) {
+	if len(args) != 2 {
+		repoUsage()
+		os.Exit(1)
+	}
+
+	ctx, registry := repoRegistry(args[0])
+	name, tag, dgst := parseRepoReference(args[1])
+
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		repoFatalf("invalid repository %s: %v", name, err)
+	}
+	manifests := repo.Manifests()
+
+	var sm *manifest.SignedManifest
+	switch {
+	case tag != "":
+		sm, err = manifests.GetByTag(tag)
+	case dgst != "":
+		sm, err = manifests.Get(dgst)
+	default:
+		tags, err := manifests.Tags()
+		if err != nil {
+			repoFatalf("error listing tags of %s: %v", name, err)
+		}
+
+		for _, tag := range tags {
+			fmt.Println(tag)
+		}
+		return
+	}
+
+	if err != nil {
+		repoFatalf("error getting manifest %s: %v", args[1], err)
+	}
+
+	os.Stdout.Write(sm.Raw)
+	fmt.Println()
+}
+
+// runRepoRemove removes a tag, or a whole repository if forced. Tags are
+// moved to the trash instead when it is enabled.
+func runRepoRemove(args []string) {
+	var force bool
+
+	flags := flag.NewFlagSet("repo rm", flag.ExitOnError)
+	flags.BoolVar(&force, "f", false, "remove a whole repository when no tag is given")
+	flags.Usage = func() {
+		repoUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ctx, config, driver := repoConfigDriver(flags.Arg(0))
+	name, tag, dgst := parseRepoReference(flags.Arg(1))
+
+	rules := repoNameRules(config)
+
+	// Deleted tags are kept in the trash, if it is enabled, until the
+	// registry purges it.
+	deleteTag := storage.DeleteTag
+	if trashConfig, ok := config.Storage["maintenance"]["trash"].(map[interface{}]interface{}); ok && trashConfig["enabled"] == true {
+		deleteTag = storage.TrashTag
+	}
+
+	switch {
+	case dgst != "":
+		repoFatalf("manifests cannot be removed by digest, remove their tags instead")
+	case tag != "":
+		if err := deleteTag(ctx, driver, rules, name, tag); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				repoFatalf("unknown tag %s:%s", name, tag)
+			}
+			repoFatalf("error removing tag %s:%s: %v", name, tag, err)
+		}
+		fmt.Printf("removed tag %s:%s\n", name, tag)
+	case !force:
+		repoFatalf("refusing to remove repository %s without -f", name)
+	default:
+		if err := storage.DeleteRepository(ctx, driver, rules, name); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				repoFatalf("unknown repository %s", name)
+			}
+			repoFatalf("error removing repository %s: %v", name, err)
+		}
+		fmt.Printf("removed repository %s\n", name)
+	}
+}
+
+// repoDriver returns the storage driver configured in the configuration at
+// configurationPath.
+func repoDriver(configurationPath string) (context.Context, storagedriver.StorageDriver) {
+	ctx, _, driver := repoConfigDriver(configurationPath)
+	return ctx, driver
+}
+
+// repoConfigDriver returns the configuration at configurationPath and the
+// storage driver it configures.
+func repoConfigDriver(configurationPath string) (context.Context, *configuration.Configuration, storagedriver.StorageDriver) {
+	config, err := parseConfiguration(configurationPath)
+	if err != nil {
+		repoFatalf("configuration error: %v", err)
+	}
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		repoFatalf("error creating storage driver: %v", err)
+	}
+
+	// The storage middleware is applied so that changes are journaled.
+	for _, mw := range config.Middleware["storage"] {
+		driver, err = storagemiddleware.Get(mw.Name, mw.Options, driver)
+		if err != nil {
+			repoFatalf("error configuring storage middleware %s: %v", mw.Name, err)
+		}
+	}
+
+	return context.Background(), config, driver
+}
+
+// repoRegistry returns the registry of the storage driver configured in the
+// configuration at configurationPath, which accepts the repository names
+// allowed by the configured rules.
+func repoRegistry(configurationPath string) (context.Context, distribution.Namespace) {
+	ctx, config, driver := repoConfigDriver(configurationPath)
+
+	var options []storage.RegistryOption
+	if rules := repoNameRules(config); rules != v2.DefaultNameRules {
+		options = append(options, storage.RepositoryNameRules(rules))
+	}
+
+	return ctx, storage.NewRegistryWithDriver(ctx, driver, nil, options...)
+}
+
+// repoNameRules returns the rules of repository names of the configuration.
+func repoNameRules(config *configuration.Configuration) *v2.NameRules {
+	rules, err := handlers.NameRules(config.Validation.Names)
+	if err != nil {
+		repoFatalf("configuration error: %v", err)
+	}
+
+	return rules
+}
+
+// parseRepoReference splits a reference into the repository name and either
+// its tag or its digest, if any.
+func parseRepoReference(reference string) (name, tag string, dgst digest.Digest) {
+	if i := strings.LastIndex(reference, "@"); i >= 0 {
+		dgst, err := digest.ParseDigest(reference[i+1:])
+		if err != nil {
+			repoFatalf("invalid digest in %s: %v", reference, err)
+		}
+		return reference[:i], "", dgst
+	}
+
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		return reference[:i], reference[i+1:], ""
+	}
+
+	return reference, "", ""
+}
+
+func repoFatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/distribution/registry/client"
+)
+
+// runSync implements the sync command, which mirrors repositories from one
+// registry to another:
+//
+//	registry sync [options] <source> <destination> <repository>...
+//
+// The source and destination are base urls of registries. The destination
+// is usually this registry, but either side may be remote.
+func runSync(args []string) {
+	var (
+		options client.SyncOptions
+		tags    string
+	)
+
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	flags.StringVar(&tags, "tags", "", "comma separated tag patterns to sync, all tags if empty")
+	flags.IntVar(&options.Concurrency, "concurrency", 4, "number of tags synced in parallel")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "only report the tags that would be synced")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "sync [options] <source> <destination> <repository>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if tags != "" {
+		options.Tags = strings.Split(tags, ",")
+	}
+
+	src, err := client.New(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid source %q: %v\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+
+	dst, err := client.New(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid destination %q: %v\n", flags.Arg(1), err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, name := range flags.Args()[2:] {
+		results, err := client.Sync(src, dst, name, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+
+		for _, result := range results {
+			switch {
+			case result.Err != nil:
+				fmt.Fprintf(os.Stderr, "%s:%s: %v\n", name, result.Tag, result.Err)
+				failed = true
+			case !result.Synced:
+				fmt.Printf("%s:%s %s up to date\n", name, result.Tag, result.Digest)
+			case options.DryRun:
+				fmt.Printf("%s:%s %s would be synced\n", name, result.Tag, result.Digest)
+			default:
+				fmt.Printf("%s:%s %s synced\n", name, result.Tag, result.Digest)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
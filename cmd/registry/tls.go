@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/docker/distribution/configuration"
+)
+
+// tlsVersions maps the configured names of TLS versions to their values.
+var tlsVersions = map[string]uint16{
+	"tls1.0": tls.VersionTLS10,
+	"tls1.1": tls.VersionTLS11,
+	"tls1.2": tls.VersionTLS12,
+	"tls1.3": tls.VersionTLS13,
+}
+
+// tlsCurves maps the configured names of elliptic curves to their values.
+var tlsCurves = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+// tlsClientAuth maps the configured client certificate policies to their
+// values.
+var tlsClientAuth = map[string]tls.ClientAuthType{
+	"require":         tls.RequireAndVerifyClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+}
+
+// newTLSConfig returns the TLS configuration of the registry server, or an
+// error if the configuration is invalid.
+func newTLSConfig(config configuration.TLS) (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		ClientAuth:   tls.NoClientCert,
+		NextProtos:   []string{"http/1.1"},
+		Certificates: make([]tls.Certificate, 1),
+	}
+
+	var err error
+	tlsConf.Certificates[0], err = tls.LoadX509KeyPair(config.Certificate, config.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MinVersion != "" {
+		version, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown minimum TLS version %q", config.MinVersion)
+		}
+		tlsConf.MinVersion = version
+	}
+
+	if config.MaxVersion != "" {
+		version, ok := tlsVersions[config.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown maximum TLS version %q", config.MaxVersion)
+		}
+		tlsConf.MaxVersion = version
+	}
+
+	if len(config.CipherSuites) != 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+
+		for _, name := range config.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+			}
+			tlsConf.CipherSuites = append(tlsConf.CipherSuites, id)
+		}
+	}
+
+	for _, name := range config.CurvePreferences {
+		curve, ok := tlsCurves[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS curve %q", name)
+		}
+		tlsConf.CurvePreferences = append(tlsConf.CurvePreferences, curve)
+	}
+
+	if len(config.ClientCAs) != 0 {
+		pool := x509.NewCertPool()
+
+		for _, ca := range config.ClientCAs {
+			caPem, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok := pool.AppendCertsFromPEM(caPem); !ok {
+				return nil, fmt.Errorf("Could not add CA to pool")
+			}
+		}
+
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConf.ClientCAs = pool
+	}
+
+	if config.ClientAuth != "" {
+		clientAuth, ok := tlsClientAuth[config.ClientAuth]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS client authentication %q", config.ClientAuth)
+		}
+
+		if tlsConf.ClientCAs == nil {
+			return nil, fmt.Errorf("TLS client authentication requires client CAs")
+		}
+		tlsConf.ClientAuth = clientAuth
+	}
+
+	return tlsConf, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+)
+
+// writeTestCertificate writes a self-signed certificate and its key to dir,
+// returning their paths.
+func writeTestCertificate(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "registry"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling key: %v", err)
+	}
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error writing certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("unexpected error writing key: %v", err)
+	}
+
+	return certPath, keyPath
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCertificate(t, dir)
+
+	tlsConf, err := newTLSConfig(configuration.TLS{
+		Certificate:      certPath,
+		Key:              keyPath,
+		ClientCAs:        []string{certPath},
+		ClientAuth:       "verify-if-given",
+		MinVersion:       "tls1.2",
+		MaxVersion:       "tls1.3",
+		CipherSuites:     []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		CurvePreferences: []string{"X25519", "P256"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating TLS configuration: %v", err)
+	}
+
+	if tlsConf.MinVersion != tls.VersionTLS12 || tlsConf.MaxVersion != tls.VersionTLS13 {
+		t.Fatalf("unexpected TLS versions: %x-%x", tlsConf.MinVersion, tlsConf.MaxVersion)
+	}
+
+	if !reflect.DeepEqual(tlsConf.CipherSuites, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}) {
+		t.Fatalf("unexpected cipher suites: %v", tlsConf.CipherSuites)
+	}
+
+	if !reflect.DeepEqual(tlsConf.CurvePreferences, []tls.CurveID{tls.X25519, tls.CurveP256}) {
+		t.Fatalf("unexpected curve preferences: %v", tlsConf.CurvePreferences)
+	}
+
+	if tlsConf.ClientAuth != tls.VerifyClientCertIfGiven || tlsConf.ClientCAs == nil {
+		t.Fatalf("unexpected client authentication: %v", tlsConf.ClientAuth)
+	}
+
+	for _, invalid := range []configuration.TLS{
+		{Certificate: certPath, Key: keyPath, MinVersion: "ssl3"},
+		{Certificate: certPath, Key: keyPath, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{Certificate: certPath, Key: keyPath, CurvePreferences: []string{"P224"}},
+		{Certificate: certPath, Key: keyPath, ClientAuth: "require"},
+	} {
+		if _, err := newTLSConfig(invalid); err == nil {
+			t.Fatalf("expected an error for %#v", invalid)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+)
+
+// runVerify implements the verify command, which checks that layers are
+// stored in the storage backend configured in the given configuration and
+// that their content matches their digest:
+//
+//	registry verify <config> <name>@<digest>...
+//
+// Each layer is streamed from the storage backend and its digest computed
+// again. The command exits with an error status if any layer is missing or
+// corrupted, so that it can be used for periodic audits.
+func runVerify(args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "verify <config> <name>@<digest>...")
+		os.Exit(1)
+	}
+
+	ctx, registry := repoRegistry(args[0])
+
+	failed := false
+	for _, reference := range args[1:] {
+		name, _, dgst := parseRepoReference(reference)
+		if dgst == "" {
+			fmt.Fprintf(os.Stderr, "%s: digest required\n", reference)
+			failed = true
+			continue
+		}
+
+		size, err := verifyLayer(ctx, registry, name, dgst)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", reference, err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("%s ok, %d bytes\n", reference, size)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// verifyLayer streams the layer with the given digest from the named
+// repository and checks its content against the digest, returning its size.
+func verifyLayer(ctx context.Context, registry distribution.Namespace, name string, dgst digest.Digest) (int64, error) {
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	layer, err := repo.Layers().Fetch(dgst)
+	if err != nil {
+		if _, ok := err.(distribution.ErrUnknownLayer); ok {
+			return 0, fmt.Errorf("layer missing")
+		}
+		return 0, err
+	}
+	defer layer.Close()
+
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return 0, err
+	}
+
+	// The digest of the content can be reported for mismatches, unless it
+	// is a tarsum.
+	writer := io.Writer(verifier)
+	digester, err := digest.NewDigesterForAlgorithm(dgst.Algorithm())
+	digested := err == nil
+	if digested {
+		writer = io.MultiWriter(verifier, &digester)
+	}
+
+	size, err := io.Copy(writer, layer)
+	if err != nil {
+		return size, fmt.Errorf("error reading layer: %v", err)
+	}
+
+	if size != layer.Length() {
+		return size, fmt.Errorf("layer truncated: read %d of %d bytes", size, layer.Length())
+	}
+
+	if !verifier.Verified() {
+		if digested {
+			return size, fmt.Errorf("digest mismatch: content digest is %s", digester.Digest())
+		}
+		return size, fmt.Errorf("digest mismatch")
+	}
+
+	return size, nil
+}
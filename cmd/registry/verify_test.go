@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestVerifyLayer(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := storage.NewRegistryWithDriver(ctx, driver, nil)
+
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	content := []byte("layer content")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	upload, err := repo.Layers().Upload()
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	if _, err := upload.ReadFrom(bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error uploading layer: %v", err)
+	}
+	if _, err := upload.Finish(dgst); err != nil {
+		t.Fatalf("unexpected error finishing upload: %v", err)
+	}
+
+	size, err := verifyLayer(ctx, registry, "foo/bar", dgst)
+	if err != nil {
+		t.Fatalf("unexpected error verifying layer: %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Fatalf("unexpected size of verified layer: %d != %d", size, len(content))
+	}
+
+	missing, _ := digest.FromBytes([]byte("missing"))
+	if _, err := verifyLayer(ctx, registry, "foo/bar", missing); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected missing layer error, got %v", err)
+	}
+
+	// Corrupt the blob data in place, keeping its size.
+	dataPath := "/docker/registry/v2/blobs/sha256/" + dgst.Hex()[:2] + "/" + dgst.Hex() + "/data"
+	if err := driver.PutContent(ctx, dataPath, []byte("LAYER CONTENT")); err != nil {
+		t.Fatalf("unexpected error corrupting layer: %v", err)
+	}
+
+	corrupted, _ := digest.FromBytes([]byte("LAYER CONTENT"))
+	if _, err := verifyLayer(ctx, registry, "foo/bar", dgst); err == nil || !strings.Contains(err.Error(), corrupted.String()) {
+		t.Fatalf("expected digest mismatch reporting %s, got %v", corrupted, err)
+	}
+}
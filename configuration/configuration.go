@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"reflect"
 	"strings"
 	"time"
@@ -57,38 +58,50 @@ type Configuration struct {
 		// Net specifies the net portion of the bind address. A default empty value means tcp.
 		Net string `yaml:"net,omitempty"`
 
+		// Socket sets the permissions of the socket when Net is unix.
+		Socket Socket `yaml:"socket,omitempty"`
+
+		// Prefix is the path under which the routes of the registry are
+		// served, such as /path/, when it does not run at the root path.
+		// Missing leading and trailing slashes are added.
 		Prefix string `yaml:"prefix,omitempty"`
 
 		// Secret specifies the secret key which HMAC tokens are created with.
 		Secret string `yaml:"secret,omitempty"`
 
+		// PreviousSecrets lists secret keys that were used before Secret.
+		// Tokens created with them are still accepted, so that the secret
+		// can be rotated without breaking uploads in progress.
+		PreviousSecrets []string `yaml:"previoussecrets,omitempty"`
+
 		// TLS instructs the http server to listen with a TLS configuration.
 		// This only support simple tls configuration with a cert and key.
 		// Mostly, this is useful for testing situations or simple deployments
 		// that require tls. If more complex configurations are required, use
 		// a proxy or make a proposal to add support here.
-		TLS struct {
-			// Certificate specifies the path to an x509 certificate file to
-			// be used for TLS.
-			Certificate string `yaml:"certificate,omitempty"`
+		TLS TLS `yaml:"tls,omitempty"`
+
+		// Listeners configures additional listeners serving the registry
+		// api besides the one of Addr, each with its own TLS configuration,
+		// such as a plain listener for internal traffic.
+		Listeners []Listener `yaml:"listeners,omitempty"`
+
+		// Timeouts bound the time spent serving requests of each class.
+		Timeouts Timeouts `yaml:"timeouts,omitempty"`
 
-			// Key specifies the path to the x509 key file, which should
-			// contain the private portion for the file specified in
-			// Certificate.
-			Key string `yaml:"key,omitempty"`
+		// TrustedProxies lists the networks, in CIDR notation, or addresses
+		// of the proxies whose forwarding headers are honored when building
+		// the urls returned to clients. By default, the headers are honored
+		// whatever the proxy.
+		TrustedProxies []string `yaml:"trustedproxies,omitempty"`
 
-			// Specifies the CA certs for client authentication
-			// A file may contain multiple CA certificates encoded as PEM
-			ClientCAs []string `yaml:"clientcas,omitempty"`
-		} `yaml:"tls,omitempty"`
+		// Compression configures the compression of JSON responses.
+		Compression Compression `yaml:"compression,omitempty"`
 
 		// Debug configures the http debug interface, if specified. This can
 		// include services such as pprof, expvar and other data that should
 		// not be exposed externally. Left disabled by default.
-		Debug struct {
-			// Addr specifies the bind address for the debug server.
-			Addr string `yaml:"addr,omitempty"`
-		} `yaml:"debug,omitempty"`
+		Debug Debug `yaml:"debug,omitempty"`
 	} `yaml:"http,omitempty"`
 
 	// Notifications specifies configuration about various endpoint to which
@@ -124,6 +137,39 @@ type Configuration struct {
 			IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
 		} `yaml:"pool,omitempty"`
 	} `yaml:"redis,omitempty"`
+
+	// Health configures the health checks registered by the registry.
+	Health Health `yaml:"health,omitempty"`
+
+	// Admin configures the admin interface, which serves operational
+	// endpoints on a listener separate from the registry api.
+	Admin Admin `yaml:"admin,omitempty"`
+
+	// Namespaces lists configuration overrides for the repositories of
+	// particular namespaces. The first namespace matching a repository
+	// applies to it.
+	Namespaces []Namespace `yaml:"namespaces,omitempty"`
+
+	// Replication configures the peer registries to which pushed content is
+	// replicated.
+	Replication Replication `yaml:"replication,omitempty"`
+
+	// Uploads configures how the state of layer uploads is kept.
+	Uploads Uploads `yaml:"uploads,omitempty"`
+
+	// PullStats configures the collection of manifest pull statistics.
+	PullStats PullStats `yaml:"pullstats,omitempty"`
+
+	// Coordination configures how the registry instances sharing the
+	// storage backend elect the one running background jobs.
+	Coordination Coordination `yaml:"coordination,omitempty"`
+
+	// Validation configures how pushed content is validated.
+	Validation Validation `yaml:"validation,omitempty"`
+
+	// Trust advertises the trust service holding the signatures of the
+	// content of this registry.
+	Trust Trust `yaml:"trust,omitempty"`
 }
 
 // v0_1Configuration is a Version 0.1 Configuration struct
@@ -186,16 +232,22 @@ type Parameters map[string]interface{}
 // Storage defines the configuration for registry object storage
 type Storage map[string]Parameters
 
+// storageOptions are the keys of the storage section which configure how the
+// registry uses its storage rather than the storage driver.
+var storageOptions = map[string]bool{
+	"maintenance":     true, // maintenance of uploads, read-only mode and trash
+	"cache":           true, // caching
+	"index":           true, // the repository index
+	"reader":          true, // layer reads
+	"digest":          true, // the canonical digest algorithm
+	"contentencoding": true, // layer content encodings
+}
+
 // Type returns the storage driver type, such as filesystem or s3
 func (storage Storage) Type() string {
 	// Return only key in this map
 	for k := range storage {
-		switch k {
-		case "maintenance":
-			// allow configuration of maintenance
-		case "cache":
-			// allow configuration of caching
-		default:
+		if !storageOptions[k] {
 			return k
 		}
 	}
@@ -221,12 +273,7 @@ func (storage *Storage) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		if len(storageMap) > 1 {
 			types := make([]string, 0, len(storageMap))
 			for k := range storageMap {
-				switch k {
-				case "maintenance":
-					// allow for configuration of maintenance
-				case "cache":
-					// allow configuration of caching
-				default:
+				if !storageOptions[k] {
 					types = append(types, k)
 				}
 			}
@@ -324,6 +371,37 @@ type Notifications struct {
 	// respond to webhook notifications. In the future, we may allow other
 	// kinds of endpoints, such as external queues.
 	Endpoints []Endpoint `yaml:"endpoints,omitempty"`
+
+	// Files is a list of files, or the standard output, to which events
+	// are appended as JSON lines for log pipelines.
+	Files []FileSink `yaml:"files,omitempty"`
+
+	// Labels names the image configuration labels whose values are
+	// included in manifest events.
+	Labels []string `yaml:"labels,omitempty"`
+
+	// DedupWindow, if set, drops events identical to an event sent within
+	// the window, such as repeated pulls of a layer by the same client.
+	DedupWindow time.Duration `yaml:"dedupwindow,omitempty"`
+
+	// Uploads configures the events reporting the progress of large layer
+	// uploads.
+	Uploads UploadEvents `yaml:"uploads,omitempty"`
+}
+
+// UploadEvents configures the events reporting when large layer uploads
+// start, reach milestones and complete.
+type UploadEvents struct {
+	// Enabled enables the upload events.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// MinSize is the size in bytes from which uploads are reported, 100MB
+	// by default.
+	MinSize int64 `yaml:"minsize,omitempty"`
+
+	// Milestones lists the percentages of the expected size of an upload
+	// at which its progress is reported, 25, 50 and 75 by default.
+	Milestones []int `yaml:"milestones,omitempty"`
 }
 
 // Endpoint describes the configuration of an http webhook notification
@@ -336,6 +414,170 @@ type Endpoint struct {
 	Timeout   time.Duration `yaml:"timeout"`   // HTTP timeout
 	Threshold int           `yaml:"threshold"` // circuit breaker threshold before backing off on failure
 	Backoff   time.Duration `yaml:"backoff"`   // backoff duration
+	TLS       EndpointTLS   `yaml:"tls"`       // client tls configuration
+}
+
+// FileSink describes a file to which events are appended as JSON lines.
+type FileSink struct {
+	Name     string `yaml:"name"`     // identifies the file in the registry instance.
+	Disabled bool   `yaml:"disabled"` // disables the file
+
+	// Path is the path of the file, or "-" for the standard output.
+	Path string `yaml:"path"`
+
+	// MaxSize, if positive, is the size in bytes past which the file is
+	// rotated.
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+
+	// MaxBackups is the number of rotated files kept. Without backups, the
+	// file is truncated when rotated.
+	MaxBackups int `yaml:"maxbackups,omitempty"`
+}
+
+// EndpointTLS configures the tls connections to a notification endpoint.
+type EndpointTLS struct {
+	// Certificate specifies the path to an x509 certificate file presented
+	// to the endpoint for client authentication.
+	Certificate string `yaml:"certificate,omitempty"`
+
+	// Key specifies the path to the x509 key file of Certificate.
+	Key string `yaml:"key,omitempty"`
+
+	// CAs specifies the CA certs used to verify the endpoint, instead of
+	// the system roots. A file may contain multiple CA certificates encoded
+	// as PEM.
+	CAs []string `yaml:"cas,omitempty"`
+}
+
+// Replication configures the replication of pushed manifests and their
+// layers to peer registries.
+type Replication struct {
+	// Peers lists the registries to which content is replicated.
+	Peers []ReplicationPeer `yaml:"peers,omitempty"`
+}
+
+// ReplicationPeer describes a registry to which content is replicated.
+type ReplicationPeer struct {
+	Name         string        `yaml:"name"`                   // identifies the peer in the registry instance.
+	Disabled     bool          `yaml:"disabled"`               // disables replication to the peer
+	URL          string        `yaml:"url"`                    // base url of the peer registry
+	Repositories []string      `yaml:"repositories,omitempty"` // patterns selecting the replicated repositories
+	Attempts     int           `yaml:"attempts"`               // attempts before an event is dropped
+	Backoff      time.Duration `yaml:"backoff"`                // backoff duration between attempts
+	Username     string        `yaml:"username,omitempty"`     // basic authentication username on the peer
+	Password     string        `yaml:"password,omitempty"`     // basic authentication password on the peer
+	Token        string        `yaml:"token,omitempty"`        // bearer token authenticating to the peer
+	Timeout      time.Duration `yaml:"timeout,omitempty"`      // timeout of each request to the peer
+}
+
+// Timeouts bound the time until the response to requests of each class
+// starts. If a timeout expires first, the request context is canceled and
+// the response is abandoned. Zero durations disable the timeouts.
+type Timeouts struct {
+	// Manifest bounds manifest requests.
+	Manifest time.Duration `yaml:"manifest,omitempty"`
+
+	// Blob bounds layer fetches, until the content starts being sent or
+	// the client is redirected to the storage backend.
+	Blob time.Duration `yaml:"blob,omitempty"`
+
+	// Upload bounds each request of a layer upload.
+	Upload time.Duration `yaml:"upload,omitempty"`
+}
+
+// Uploads configures how the state of layer uploads is kept.
+type Uploads struct {
+	// Sessions selects a store shared by the registry instances for the
+	// state of uploads in progress, so that any instance can continue an
+	// upload without the state token. It is one of "redis" or "storage".
+	// By default, the state is only kept in the token.
+	Sessions string `yaml:"sessions,omitempty"`
+}
+
+// PullStats configures the collection of manifest pull statistics.
+type PullStats struct {
+	// Store selects where the daily pull counters are kept. It is one of
+	// "redis" or "storage". By default, pulls are not counted.
+	Store string `yaml:"store,omitempty"`
+
+	// Retention is the time for which the counters are kept in redis.
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+// Coordination configures the election of the registry instance running
+// background jobs, such as upload purging.
+type Coordination struct {
+	// Lock selects where the leader lock is kept. It is one of "redis" or
+	// "storage". By default, every instance runs the background jobs.
+	Lock string `yaml:"lock,omitempty"`
+
+	// TTL is the time for which the leader keeps the lock without renewing
+	// it, after which another instance takes over.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+// Validation configures how pushed content is validated.
+type Validation struct {
+	// Manifests configures the validation of pushed manifests.
+	Manifests ManifestValidation `yaml:"manifests,omitempty"`
+
+	// Names configures the rules repository names follow.
+	Names NameValidation `yaml:"names,omitempty"`
+
+	// Layers configures the validation of uploaded layers.
+	Layers LayerValidation `yaml:"layers,omitempty"`
+}
+
+// ManifestValidation configures the validation of pushed manifests.
+type ManifestValidation struct {
+	// Blobs selects the layers referenced by a manifest which must exist in
+	// the repository. It is one of "strict", the default, requiring every
+	// layer, "fast", only requiring the top layer, or "none".
+	Blobs string `yaml:"blobs,omitempty"`
+
+	// MaxSize is the maximum size in bytes of a pushed manifest, 4MiB by
+	// default.
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+
+	// Admission lists the admission hooks to which manifests are posted
+	// before they are accepted, in order.
+	Admission []ValidationHook `yaml:"admission,omitempty"`
+}
+
+// NameValidation configures the rules repository names follow. The rules of
+// the specification apply by default.
+type NameValidation struct {
+	// Component is the regular expression each slash-delimited component
+	// of a name matches. It must not match slashes, and must only use
+	// non-capturing groups.
+	Component string `yaml:"component,omitempty"`
+
+	// ComponentMinLength is the minimum length of a name component.
+	ComponentMinLength int `yaml:"componentminlength,omitempty"`
+
+	// MaxLength is the maximum length of a name.
+	MaxLength int `yaml:"maxlength,omitempty"`
+}
+
+// LayerValidation configures the validation of uploaded layers.
+type LayerValidation struct {
+	// Scanners lists the content scanners to which uploaded layers are
+	// posted before they are committed, in order.
+	Scanners []ValidationHook `yaml:"scanners,omitempty"`
+}
+
+// ValidationHook configures an http endpoint validating pushed content. The
+// endpoint accepts the content with a 2xx status, or rejects it with a 403
+// or 422 status and the reason in the body.
+type ValidationHook struct {
+	Name    string        `yaml:"name"`    // identifies the hook in logs.
+	URL     string        `yaml:"url"`     // post url of the hook.
+	Headers http.Header   `yaml:"headers"` // static headers added to all requests
+	Timeout time.Duration `yaml:"timeout"` // HTTP timeout
+
+	// Enforce is "block", the default, failing pushes rejected by the hook
+	// or which it could not validate, or "log", only logging them.
+	Enforce string `yaml:"enforce,omitempty"`
 }
 
 // Reporting defines error reporting methods.
@@ -367,6 +609,247 @@ type NewRelicReporting struct {
 	Verbose bool `yaml:"verbose,omitempty"`
 }
 
+// Namespace configures overrides for the repositories whose names match
+// Prefix.
+type Namespace struct {
+	// Prefix selects the repositories of the namespace. A prefix ending in
+	// "/*", such as "team-a/*", matches every repository under it. Any other
+	// prefix only matches the repository with that exact name.
+	Prefix string `yaml:"prefix"`
+
+	// Redirect configures whether layer requests are redirected to the
+	// storage backend, when it supports it.
+	Redirect struct {
+		// Disable serves layers from the registry even if the storage
+		// driver can provide urls for them.
+		Disable bool `yaml:"disable,omitempty"`
+	} `yaml:"redirect,omitempty"`
+
+	// Quota limits the content pushed to the repositories of the namespace.
+	Quota Quota `yaml:"quota,omitempty"`
+
+	// Notifications lists endpoints notified of the events of the
+	// namespace, in addition to the globally configured endpoints.
+	Notifications Notifications `yaml:"notifications,omitempty"`
+
+	// Network restricts the client addresses allowed to access the
+	// repositories of the namespace.
+	Network NetworkPolicy `yaml:"network,omitempty"`
+}
+
+// NetworkPolicy restricts access by client address, separately for pulls
+// (GET and HEAD requests) and pushes (any other request).
+type NetworkPolicy struct {
+	Pull NetworkRule `yaml:"pull,omitempty"`
+	Push NetworkRule `yaml:"push,omitempty"`
+}
+
+// NetworkRule lists networks in CIDR notation, such as "10.0.0.0/8", or
+// single addresses. Addresses in a denied network are rejected. If any
+// network is allowed, addresses outside the allowed networks are rejected
+// too.
+type NetworkRule struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// Quota limits the content pushed to a repository. A zero value means no
+// limit.
+type Quota struct {
+	// MaxLayerSize is the maximum size in bytes of a single layer.
+	MaxLayerSize int64 `yaml:"maxlayersize,omitempty"`
+
+	// MaxTags is the maximum number of tags in a repository. Pushes of new
+	// tags beyond this limit are rejected.
+	MaxTags int `yaml:"maxtags,omitempty"`
+}
+
+// Debug configures the debug endpoints: the pprof profiles, the expvar
+// counters and the health status. They are served on their own listener when
+// Addr is set, and on the admin interface when Admin is set.
+type Debug struct {
+	// Addr specifies the bind address for the debug server.
+	Addr string `yaml:"addr,omitempty"`
+
+	// Admin serves the debug endpoints on the admin interface as well,
+	// guarded by its access controller.
+	Admin bool `yaml:"admin,omitempty"`
+
+	// Pprof, Expvar and Health switch the individual endpoints off.
+	Pprof  DebugEndpoint `yaml:"pprof,omitempty"`
+	Expvar DebugEndpoint `yaml:"expvar,omitempty"`
+	Health DebugEndpoint `yaml:"health,omitempty"`
+
+	// Auth requires HTTP basic authentication on the debug server, if a
+	// username is set.
+	Auth DebugAuth `yaml:"auth,omitempty"`
+}
+
+// DebugEndpoint configures one of the debug endpoints, which are enabled
+// unless Disabled is set.
+type DebugEndpoint struct {
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// DebugAuth holds the credentials of the debug server.
+type DebugAuth struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Admin configures the admin interface. It is disabled unless Addr is set.
+type Admin struct {
+	// Addr specifies the bind address for the admin interface.
+	Addr string `yaml:"addr,omitempty"`
+
+	// Net specifies the net portion of the bind address. A default empty
+	// value means tcp.
+	Net string `yaml:"net,omitempty"`
+
+	// Socket sets the permissions of the socket when Net is unix.
+	Socket Socket `yaml:"socket,omitempty"`
+
+	// Auth configures the access controller guarding the admin interface,
+	// independently of the access controller used for the registry api.
+	Auth Auth `yaml:"auth,omitempty"`
+}
+
+// TLS configures the TLS server of the registry.
+type TLS struct {
+	// Certificate specifies the path to an x509 certificate file to
+	// be used for TLS.
+	Certificate string `yaml:"certificate,omitempty"`
+
+	// Key specifies the path to the x509 key file, which should
+	// contain the private portion for the file specified in
+	// Certificate.
+	Key string `yaml:"key,omitempty"`
+
+	// Specifies the CA certs for client authentication
+	// A file may contain multiple CA certificates encoded as PEM
+	ClientCAs []string `yaml:"clientcas,omitempty"`
+
+	// ClientAuth selects how client certificates are verified when
+	// ClientCAs are set: "require", the default, rejects clients without
+	// a valid certificate, and "verify-if-given" only verifies the
+	// certificates that clients present.
+	ClientAuth string `yaml:"clientauth,omitempty"`
+
+	// MinVersion and MaxVersion bound the accepted TLS versions, one of
+	// "tls1.0", "tls1.1", "tls1.2" or "tls1.3".
+	MinVersion string `yaml:"minversion,omitempty"`
+	MaxVersion string `yaml:"maxversion,omitempty"`
+
+	// CipherSuites lists the accepted cipher suites of TLS versions up to
+	// 1.2, by their standard names.
+	CipherSuites []string `yaml:"ciphersuites,omitempty"`
+
+	// CurvePreferences lists the elliptic curves used for key exchange, in
+	// order of preference: "X25519", "P256", "P384" or "P521".
+	CurvePreferences []string `yaml:"curvepreferences,omitempty"`
+}
+
+// Socket sets the permissions of a unix socket the registry listens on.
+// Zero values leave the defaults of the process.
+type Socket struct {
+	// Mode is the file mode of the socket, such as 0660.
+	Mode os.FileMode `yaml:"mode,omitempty"`
+
+	// Owner and Group are the names or numeric IDs of the user and group
+	// owning the socket.
+	Owner string `yaml:"owner,omitempty"`
+	Group string `yaml:"group,omitempty"`
+}
+
+// Listener configures an additional listener serving the registry api.
+type Listener struct {
+	// Name identifies the listener in logs.
+	Name string `yaml:"name,omitempty"`
+
+	// Net and Addr specify the bind address of the listener, as for the
+	// main listener.
+	Net  string `yaml:"net,omitempty"`
+	Addr string `yaml:"addr"`
+
+	// Socket sets the permissions of the socket when Net is unix.
+	Socket Socket `yaml:"socket,omitempty"`
+
+	// TLS enables TLS on the listener when a certificate is set.
+	TLS TLS `yaml:"tls,omitempty"`
+
+	// Networks lists the networks, in CIDR notation, or addresses of the
+	// clients whose tcp connections are accepted. By default, all clients
+	// are accepted.
+	Networks []string `yaml:"networks,omitempty"`
+}
+
+// Compression configures the compression of JSON responses, such as tag
+// lists, negotiated with clients from their Accept-Encoding header. Layers
+// and manifests are never compressed.
+type Compression struct {
+	// Enabled enables the gzip and deflate compression of JSON responses.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// MinSize is the size in bytes from which responses are compressed,
+	// 1024 by default.
+	MinSize int `yaml:"minsize,omitempty"`
+}
+
+// Trust advertises the trust service, such as a Notary server, holding the
+// signatures of the content of this registry, so that clients can locate it
+// from the registry.
+type Trust struct {
+	// Server is the base url of the trust service.
+	Server string `yaml:"server,omitempty"`
+}
+
+// Health configures the health checks registered by the registry. The status
+// of each check is reported on the debug server.
+type Health struct {
+	// FileCheckers lists files whose existence takes the registry out of
+	// rotation.
+	FileCheckers []FileChecker `yaml:"file,omitempty"`
+	// HTTPCheckers lists URIs that must respond with a 200 to a HEAD
+	// request for the registry to be healthy.
+	HTTPCheckers []HTTPChecker `yaml:"http,omitempty"`
+	// StorageDriver configures a check of the registry's storage driver.
+	StorageDriver StorageDriverChecker `yaml:"storagedriver,omitempty"`
+}
+
+// FileChecker is a health check that fails while a file exists.
+type FileChecker struct {
+	// File is the path of the file to check.
+	File string `yaml:"file"`
+	// Interval is the period at which the check runs.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Threshold is the number of consecutive failures before the check is
+	// considered failed.
+	Threshold int `yaml:"threshold,omitempty"`
+}
+
+// HTTPChecker is a health check that fails when a URI cannot be reached.
+type HTTPChecker struct {
+	// URI is the address to send HEAD requests to.
+	URI string `yaml:"uri"`
+	// Interval is the period at which the check runs.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Threshold is the number of consecutive failures before the check is
+	// considered failed.
+	Threshold int `yaml:"threshold,omitempty"`
+}
+
+// StorageDriverChecker is a health check that fails when the storage driver
+// cannot list the root directory.
+type StorageDriverChecker struct {
+	// Enabled turns the check on.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval is the period at which the check runs.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Threshold is the number of consecutive failures before the check is
+	// considered failed.
+	Threshold int `yaml:"threshold,omitempty"`
+}
+
 // Middleware configures named middlewares to be applied at injection points.
 type Middleware struct {
 	// Name the middleware registers itself as
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/yaml.v2"
@@ -62,25 +63,35 @@ var configStruct = Configuration{
 	HTTP: struct {
 		Addr   string `yaml:"addr,omitempty"`
 		Net    string `yaml:"net,omitempty"`
+		Socket Socket `yaml:"socket,omitempty"`
 		Prefix string `yaml:"prefix,omitempty"`
 		Secret string `yaml:"secret,omitempty"`
-		TLS    struct {
-			Certificate string   `yaml:"certificate,omitempty"`
-			Key         string   `yaml:"key,omitempty"`
-			ClientCAs   []string `yaml:"clientcas,omitempty"`
-		} `yaml:"tls,omitempty"`
-		Debug struct {
-			Addr string `yaml:"addr,omitempty"`
-		} `yaml:"debug,omitempty"`
+
+		PreviousSecrets []string    `yaml:"previoussecrets,omitempty"`
+		TLS             TLS         `yaml:"tls,omitempty"`
+		Listeners       []Listener  `yaml:"listeners,omitempty"`
+		Timeouts        Timeouts    `yaml:"timeouts,omitempty"`
+		TrustedProxies  []string    `yaml:"trustedproxies,omitempty"`
+		Compression     Compression `yaml:"compression,omitempty"`
+		Debug           Debug       `yaml:"debug,omitempty"`
 	}{
-		TLS: struct {
-			Certificate string   `yaml:"certificate,omitempty"`
-			Key         string   `yaml:"key,omitempty"`
-			ClientCAs   []string `yaml:"clientcas,omitempty"`
-		}{
+		TLS: TLS{
 			ClientCAs: []string{"/path/to/ca.pem"},
 		},
 	},
+	Health: Health{
+		FileCheckers: []FileChecker{
+			{
+				File:     "/tmp/disable",
+				Interval: 5 * time.Second,
+			},
+		},
+		StorageDriver: StorageDriverChecker{
+			Enabled:   true,
+			Interval:  10 * time.Second,
+			Threshold: 3,
+		},
+	},
 }
 
 // configYamlV0_1 is a Version 0.1 yaml document representing configStruct
@@ -117,6 +128,14 @@ reporting:
 http:
   clientcas:
     - /path/to/ca.pem
+health:
+  file:
+    - file: /tmp/disable
+      interval: 5s
+  storagedriver:
+    enabled: true
+    interval: 10s
+    threshold: 3
 `
 
 // inmemoryConfigYamlV0_1 is a Version 0.1 yaml document specifying an inmemory
@@ -172,6 +191,7 @@ func (suite *ConfigSuite) TestParseInmemory(c *C) {
 	suite.expectedConfig.Storage = Storage{"inmemory": Parameters{}}
 	suite.expectedConfig.Reporting = Reporting{}
 	suite.expectedConfig.Log.Fields = nil
+	suite.expectedConfig.Health = Health{}
 
 	config, err := Parse(bytes.NewReader([]byte(inmemoryConfigYamlV0_1)))
 	c.Assert(err, IsNil)
@@ -191,6 +211,7 @@ func (suite *ConfigSuite) TestParseIncomplete(c *C) {
 	suite.expectedConfig.Auth = Auth{"silly": Parameters{"realm": "silly"}}
 	suite.expectedConfig.Reporting = Reporting{}
 	suite.expectedConfig.Notifications = Notifications{}
+	suite.expectedConfig.Health = Health{}
 
 	os.Setenv("REGISTRY_STORAGE", "filesystem")
 	os.Setenv("REGISTRY_STORAGE_FILESYSTEM_ROOTDIRECTORY", "/tmp/testroot")
@@ -202,6 +223,18 @@ func (suite *ConfigSuite) TestParseIncomplete(c *C) {
 	c.Assert(config, DeepEquals, suite.expectedConfig)
 }
 
+// TestParseStorageOptions validates that the storage options may be given
+// alongside the storage type, but not a second storage type.
+func (suite *ConfigSuite) TestParseStorageOptions(c *C) {
+	optionsConfigYaml := "version: 0.1\nstorage:\n  inmemory: {}\n  index: {}\n  digest: {}\n  contentencoding: {}\n"
+	config, err := Parse(bytes.NewReader([]byte(optionsConfigYaml)))
+	c.Assert(err, IsNil)
+	c.Assert(config.Storage.Type(), Equals, "inmemory")
+
+	_, err = Parse(bytes.NewReader([]byte(optionsConfigYaml + "  filesystem: {}\n")))
+	c.Assert(err, NotNil)
+}
+
 // TestParseWithSameEnvStorage validates that providing environment variables
 // that match the given storage type will only include environment-defined
 // parameters and remove yaml-defined parameters
@@ -365,5 +398,9 @@ func copyConfig(config Configuration) *Configuration {
 		configCopy.Notifications.Endpoints = append(configCopy.Notifications.Endpoints, v)
 	}
 
+	configCopy.Health = config.Health
+	configCopy.Health.FileCheckers = append([]FileChecker(nil), config.Health.FileCheckers...)
+	configCopy.Health.HTTPCheckers = append([]HTTPChecker(nil), config.Health.HTTPCheckers...)
+
 	return configCopy
 }
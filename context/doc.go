@@ -53,6 +53,12 @@
 // added to the request context, is unique to that context and can have
 // request scoped varaibles.
 //
+// Loggers are logrus entries by default. Other logging libraries can be used
+// by implementing FieldLogger, which adds methods to derive loggers with
+// fields, and setting the logger with SetDefaultLogger, or on a context with
+// WithLogger. Since fields are only attached for keys present in the context,
+// a logger pushed on the context with its fields bound is reused as is.
+//
 // HTTP Requests
 //
 // This package also contains several methods for working with http requests.
@@ -2,6 +2,7 @@ package context
 
 import (
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -161,7 +162,7 @@ func GetRequestLogger(ctx Context) Logger {
 // this function on the context will lead to missing or invalid data. Only
 // call this at the end of a request, after the response has been written.
 func GetResponseLogger(ctx Context) Logger {
-	l := getLogrusLogger(ctx,
+	l := getFieldLogger(ctx,
 		"http.response.written",
 		"http.response.status",
 		"http.response.contenttype")
@@ -285,6 +286,30 @@ func (irw *instrumentedResponseWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// ReadFrom copies src to the response with the ReadFrom of the underlying
+// writer, if any, so that files may be sent without copying them through
+// user space.
+func (irw *instrumentedResponseWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	if rf, ok := irw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		// Hide ReadFrom, so that io.Copy writes through the underlying Write.
+		n, err = io.Copy(struct{ io.Writer }{irw.ResponseWriter}, src)
+	}
+
+	irw.mu.Lock()
+	irw.written += n
+
+	// Guess the likely status if not set.
+	if irw.status == 0 {
+		irw.status = http.StatusOK
+	}
+
+	irw.mu.Unlock()
+
+	return
+}
+
 func (irw *instrumentedResponseWriter) WriteHeader(status int) {
 	irw.ResponseWriter.WriteHeader(status)
 
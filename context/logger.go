@@ -39,6 +39,44 @@ type Logger interface {
 	Warnln(args ...interface{})
 }
 
+// FieldLogger is a Logger that can derive loggers with additional fields.
+// Loggers provided to WithLogger or SetDefaultLogger should implement it, so
+// that fields resolved from the context can be attached to their entries. It
+// is the extension point for adapters of other logging libraries.
+type FieldLogger interface {
+	Logger
+
+	// WithField returns a logger with the field added.
+	WithField(key string, value interface{}) FieldLogger
+
+	// WithFields returns a logger with the fields added.
+	WithFields(fields map[string]interface{}) FieldLogger
+}
+
+// logrusLogger adapts a logrus entry to the FieldLogger interface.
+type logrusLogger struct {
+	*logrus.Entry
+}
+
+func (l logrusLogger) WithField(key string, value interface{}) FieldLogger {
+	return logrusLogger{l.Entry.WithField(key, value)}
+}
+
+func (l logrusLogger) WithFields(fields map[string]interface{}) FieldLogger {
+	return logrusLogger{l.Entry.WithFields(logrus.Fields(fields))}
+}
+
+// defaultLogger is used when the context has no logger, set with
+// SetDefaultLogger.
+var defaultLogger FieldLogger = logrusLogger{logrus.NewEntry(logrus.StandardLogger())}
+
+// SetDefaultLogger sets the logger used for contexts without a logger, which
+// is the logrus standard logger by default. It should be called during
+// initialization, before any logging.
+func SetDefaultLogger(logger FieldLogger) {
+	defaultLogger = logger
+}
+
 // WithLogger creates a new context with provided logger.
 func WithLogger(ctx Context, logger Logger) Context {
 	return WithValue(ctx, "logger", logger)
@@ -48,14 +86,14 @@ func WithLogger(ctx Context, logger Logger) Context {
 // and value without affecting the context. Extra specified keys will be
 // resolved from the context.
 func GetLoggerWithField(ctx Context, key, value interface{}, keys ...interface{}) Logger {
-	return getLogrusLogger(ctx, keys...).WithField(fmt.Sprint(key), value)
+	return getFieldLogger(ctx, keys...).WithField(fmt.Sprint(key), value)
 }
 
 // GetLoggerWithFields returns a logger instance with the specified fields
 // without affecting the context. Extra specified keys will be resolved from
 // the context.
 func GetLoggerWithFields(ctx Context, fields map[string]interface{}, keys ...interface{}) Logger {
-	return getLogrusLogger(ctx, keys...).WithFields(logrus.Fields(fields))
+	return getFieldLogger(ctx, keys...).WithFields(fields)
 }
 
 // GetLogger returns the logger from the current context, if present. If one
@@ -65,37 +103,58 @@ func GetLoggerWithFields(ctx Context, fields map[string]interface{}, keys ...int
 // a logging key field. If context keys are integer constants, for example,
 // its recommended that a String method is implemented.
 func GetLogger(ctx Context, keys ...interface{}) Logger {
-	return getLogrusLogger(ctx, keys...)
+	return getFieldLogger(ctx, keys...)
 }
 
-// GetLogrusLogger returns the logrus logger for the context. If one more keys
-// are provided, they will be resolved on the context and included in the
-// logger. Only use this function if specific logrus functionality is
-// required.
-func getLogrusLogger(ctx Context, keys ...interface{}) *logrus.Entry {
-	var logger *logrus.Entry
+// getFieldLogger returns the logger for the context, or the default logger.
+// If one more keys are provided, they will be resolved on the context and
+// included in the logger. Fields are only allocated for keys with values, so
+// loggers pushed on the context with the common fields already bound are
+// returned as is.
+func getFieldLogger(ctx Context, keys ...interface{}) FieldLogger {
+	logger := defaultLogger
 
 	// Get a logger, if it is present.
-	loggerInterface := ctx.Value("logger")
-	if loggerInterface != nil {
-		if lgr, ok := loggerInterface.(*logrus.Entry); ok {
-			logger = lgr
-		}
+	switch lgr := ctx.Value("logger").(type) {
+	case FieldLogger:
+		logger = lgr
+	case *logrus.Entry:
+		logger = logrusLogger{lgr}
+	case Logger:
+		// Fields can not be attached to loggers that do not support them.
+		return fieldlessLogger{lgr}
 	}
 
-	if logger == nil {
-		// If no logger is found, just return the standard logger.
-		logger = logrus.NewEntry(logrus.StandardLogger())
-	}
-
-	fields := logrus.Fields{}
-
+	var fields map[string]interface{}
 	for _, key := range keys {
 		v := ctx.Value(key)
-		if v != nil {
-			fields[fmt.Sprint(key)] = v
+		if v == nil {
+			continue
+		}
+
+		if fields == nil {
+			fields = make(map[string]interface{}, len(keys))
 		}
+		fields[fmt.Sprint(key)] = v
+	}
+
+	if fields == nil {
+		return logger
 	}
 
 	return logger.WithFields(fields)
 }
+
+// fieldlessLogger adapts a Logger that does not implement FieldLogger,
+// dropping any fields.
+type fieldlessLogger struct {
+	Logger
+}
+
+func (l fieldlessLogger) WithField(key string, value interface{}) FieldLogger {
+	return l
+}
+
+func (l fieldlessLogger) WithFields(fields map[string]interface{}) FieldLogger {
+	return l
+}
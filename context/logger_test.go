@@ -0,0 +1,66 @@
+package context
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingLogger is a FieldLogger recording its fields and messages.
+type recordingLogger struct {
+	Logger
+	fields   map[string]interface{}
+	messages *[]string
+}
+
+func (l recordingLogger) WithField(key string, value interface{}) FieldLogger {
+	return l.WithFields(map[string]interface{}{key: value})
+}
+
+func (l recordingLogger) WithFields(fields map[string]interface{}) FieldLogger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return recordingLogger{Logger: l.Logger, fields: merged, messages: l.messages}
+}
+
+func (l recordingLogger) Infof(format string, args ...interface{}) {
+	*l.messages = append(*l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestCustomLogger(t *testing.T) {
+	var messages []string
+	logger := recordingLogger{
+		Logger:   GetLogger(Background()),
+		fields:   map[string]interface{}{"bound": "yes"},
+		messages: &messages,
+	}
+
+	ctx := WithValue(WithLogger(Background(), logger), "request.id", "abc")
+
+	// Without keys resolving to values, the logger is returned as is.
+	if l := GetLogger(ctx, "missing"); !reflect.DeepEqual(l, logger) {
+		t.Fatalf("unexpected logger without fields: %#v != %#v", l, logger)
+	}
+
+	l := GetLoggerWithField(ctx, "key", "value", "request.id")
+	l.Infof("hello %s", "world")
+
+	expected := map[string]interface{}{
+		"bound":      "yes",
+		"key":        "value",
+		"request.id": "abc",
+	}
+	if fields := l.(recordingLogger).fields; !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("unexpected fields: %v != %v", fields, expected)
+	}
+
+	if !reflect.DeepEqual(messages, []string{"hello world"}) {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+}
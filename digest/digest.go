@@ -80,6 +80,21 @@ func FromReader(rd io.Reader) (Digest, error) {
 	return digester.Digest(), nil
 }
 
+// FromReaderWithAlgorithm returns the digest of the underlying content
+// using the named algorithm, one of sha256, sha384 or sha512.
+func FromReaderWithAlgorithm(alg string, rd io.Reader) (Digest, error) {
+	digester, err := NewDigesterForAlgorithm(alg)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(digester, rd); err != nil {
+		return "", err
+	}
+
+	return digester.Digest(), nil
+}
+
 // FromTarArchive produces a tarsum digest from reader rd.
 func FromTarArchive(rd io.Reader) (Digest, error) {
 	ts, err := tarsum.NewTarSum(rd, true, tarsum.Version1)
@@ -104,6 +119,12 @@ func FromBytes(p []byte) (Digest, error) {
 	return FromReader(bytes.NewReader(p))
 }
 
+// FromBytesWithAlgorithm digests the input using the named algorithm and
+// returns a Digest.
+func FromBytesWithAlgorithm(alg string, p []byte) (Digest, error) {
+	return FromReaderWithAlgorithm(alg, bytes.NewReader(p))
+}
+
 // Validate checks that the contents of d is a valid digest, returning an
 // error if not.
 func (d Digest) Validate() error {
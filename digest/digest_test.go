@@ -109,3 +109,33 @@ func checkTarsumDigest(t *testing.T, msg string, rd io.Reader, expected Digest)
 		t.Fatalf("unexpected digest for %s: %q != %q", msg, dgst, expected)
 	}
 }
+
+func TestFromBytesWithAlgorithm(t *testing.T) {
+	for _, testcase := range []struct {
+		algorithm string
+		expected  Digest
+		err       error
+	}{
+		{
+			algorithm: "sha256",
+			expected:  DigestSha256EmptyTar,
+		},
+		{
+			algorithm: "sha512",
+			expected:  "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+		},
+		{
+			algorithm: "md5",
+			err:       ErrDigestUnsupported,
+		},
+	} {
+		dgst, err := FromBytesWithAlgorithm(testcase.algorithm, []byte{})
+		if err != testcase.err {
+			t.Fatalf("error differed from expected digesting with %q: %v != %v", testcase.algorithm, err, testcase.err)
+		}
+
+		if dgst != testcase.expected {
+			t.Fatalf("unexpected digest with %q: %q != %q", testcase.algorithm, dgst, testcase.expected)
+		}
+	}
+}
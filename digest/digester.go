@@ -28,6 +28,18 @@ func NewCanonicalDigester() Digester {
 	return NewDigester("sha256", sha256.New())
 }
 
+// NewDigesterForAlgorithm creates a new Digester for the named algorithm,
+// one of sha256, sha384 or sha512. ErrDigestUnsupported is returned for any
+// other algorithm.
+func NewDigesterForAlgorithm(alg string) (Digester, error) {
+	switch alg {
+	case "sha256", "sha384", "sha512":
+		return NewDigester(alg, newHash(alg)), nil
+	default:
+		return Digester{}, ErrDigestUnsupported
+	}
+}
+
 // Digest returns the current digest for this digester.
 func (d *Digester) Digest() Digest {
 	return NewDigest(d.alg, d.Hash)
@@ -100,6 +100,11 @@ func (err ErrUnknownLayer) Error() string {
 // ErrLayerInvalidDigest returned when tarsum check fails.
 type ErrLayerInvalidDigest struct {
 	Digest digest.Digest
+
+	// Received is the digest of the uploaded content with the algorithm of
+	// Digest, if it was computed.
+	Received digest.Digest
+
 	Reason error
 }
 
@@ -107,3 +112,14 @@ func (err ErrLayerInvalidDigest) Error() string {
 	return fmt.Sprintf("invalid digest for referenced layer: %v, %v",
 		err.Digest, err.Reason)
 }
+
+// ErrLayerRejected is returned when the content of an uploaded layer is
+// rejected by a layer validator, such as a content scanner.
+type ErrLayerRejected struct {
+	Digest digest.Digest
+	Reason string
+}
+
+func (err ErrLayerRejected) Error() string {
+	return fmt.Sprintf("layer %v rejected: %v", err.Digest, err.Reason)
+}
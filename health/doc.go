@@ -32,6 +32,15 @@
 //  # curl localhost:5001/debug/health
 //  {"manual_http_status":"Manual Check"}
 //
+// A more detailed view is available at "/debug/health/detail". It returns a
+// JSON object with an entry for every registered check, reporting its status,
+// last error, last success time, latency, consecutive failures and, for
+// periodic checks, the interval and threshold. Unlike "/debug/health", it does
+// not run synchronous checks but reports the outcome of their last run:
+//
+//  # curl localhost:5001/debug/health/detail
+//  {"manual_http_status":{"status":"ok","lastChecked":"...","lastSuccess":"...","latency":"1.2µs","failures":0}}
+//
 // After importing these packages to your main application, you can start
 // registering checks.
 //
@@ -110,33 +110,204 @@ func NewThresholdStatusUpdater(t int) Updater {
 	return &thresholdUpdater{threshold: t}
 }
 
+// CheckDetail describes the current state of a registered check, as
+// reported by the "/debug/health/detail" endpoint.
+type CheckDetail struct {
+	// Status is "ok" if the check currently passes and "failed" otherwise,
+	// or "unknown" for a synchronous check that was never called.
+	Status string `json:"status"`
+
+	// LastError is the error returned by the most recent run of the check,
+	// if any. A check with a threshold may report an error while its status
+	// is still "ok".
+	LastError string `json:"lastError,omitempty"`
+
+	// LastChecked is the time of the most recent run of the check.
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+
+	// LastSuccess is the time of the most recent successful run of the
+	// check.
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+
+	// Latency is the duration of the most recent run of the check.
+	Latency string `json:"latency"`
+
+	// Failures is the number of consecutive failed runs of the check.
+	Failures int `json:"failures"`
+
+	// Threshold is the number of consecutive failures required before the
+	// check is considered failed.
+	Threshold int `json:"threshold,omitempty"`
+
+	// Interval is the period at which the check runs, for periodic checks.
+	Interval string `json:"interval,omitempty"`
+}
+
+// checkStats records the outcome of the most recent runs of a check.
+type checkStats struct {
+	mu          sync.Mutex
+	lastError   error
+	lastChecked time.Time
+	lastSuccess time.Time
+	latency     time.Duration
+	failures    int
+	threshold   int
+	interval    time.Duration
+}
+
+// record stores the result of a run of the check that started at start.
+func (cs *checkStats) record(start time.Time, err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.lastChecked = start
+	cs.latency = time.Since(start)
+	cs.lastError = err
+	if err == nil {
+		cs.lastSuccess = start
+		cs.failures = 0
+	} else {
+		cs.failures++
+	}
+}
+
+// detail returns the recorded state of the check, with the given current
+// status.
+func (cs *checkStats) detail(status error) CheckDetail {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	detail := CheckDetail{
+		Status:    "ok",
+		Latency:   cs.latency.String(),
+		Failures:  cs.failures,
+		Threshold: cs.threshold,
+	}
+
+	if status != nil {
+		detail.Status = "failed"
+	}
+
+	if cs.lastError != nil {
+		detail.LastError = cs.lastError.Error()
+	} else if status != nil {
+		detail.LastError = status.Error()
+	}
+
+	if !cs.lastChecked.IsZero() {
+		lastChecked := cs.lastChecked
+		detail.LastChecked = &lastChecked
+	}
+
+	if !cs.lastSuccess.IsZero() {
+		lastSuccess := cs.lastSuccess
+		detail.LastSuccess = &lastSuccess
+	}
+
+	if cs.interval != 0 {
+		detail.Interval = cs.interval.String()
+	}
+
+	return detail
+}
+
+// status returns the error recorded by the most recent run of the check.
+func (cs *checkStats) status() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.lastError
+}
+
+// trackedChecker is implemented by checkers that record the outcome of their
+// runs.
+type trackedChecker interface {
+	Checker
+
+	// detail returns the details of the check from the recorded outcome of
+	// its runs, without running it.
+	detail() CheckDetail
+}
+
+// syncChecker records the outcome of every synchronous call to a Checker.
+type syncChecker struct {
+	Checker
+	cs checkStats
+}
+
+// Check implements the Checker interface
+func (sc *syncChecker) Check() error {
+	start := time.Now()
+	err := sc.Checker.Check()
+	sc.cs.record(start, err)
+	return err
+}
+
+// detail reports the status of the most recent synchronous call. The status
+// of a check that was never called is unknown.
+func (sc *syncChecker) detail() CheckDetail {
+	detail := sc.cs.detail(sc.cs.status())
+	if detail.LastChecked == nil {
+		detail.Status = "unknown"
+	}
+	return detail
+}
+
+// periodicChecker wraps an Updater that is updated by running a check
+// periodically, recording the outcome of each run.
+type periodicChecker struct {
+	Updater
+	cs   checkStats
+	stop chan struct{}
+}
+
+// detail reports the status kept by the Updater, which only changes when the
+// check runs.
+func (pc *periodicChecker) detail() CheckDetail {
+	return pc.cs.detail(pc.Updater.Check())
+}
+
+// run updates the status with the result of check every period, until the
+// checker is stopped.
+func (pc *periodicChecker) run(check Checker, period time.Duration) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-pc.stop:
+			return
+		}
+		start := time.Now()
+		err := check.Check()
+		pc.cs.record(start, err)
+		pc.Update(err)
+	}
+}
+
+// close stops running the check.
+func (pc *periodicChecker) close() {
+	close(pc.stop)
+}
+
 // PeriodicChecker wraps an updater to provide a periodic checker
 func PeriodicChecker(check Checker, period time.Duration) Checker {
-	u := NewStatusUpdater()
-	go func() {
-		t := time.NewTicker(period)
-		for {
-			<-t.C
-			u.Update(check.Check())
-		}
-	}()
+	pc := &periodicChecker{Updater: NewStatusUpdater(), stop: make(chan struct{})}
+	pc.cs.interval = period
+	go pc.run(check, period)
 
-	return u
+	return pc
 }
 
 // PeriodicThresholdChecker wraps an updater to provide a periodic checker that
 // uses a threshold before it changes status
 func PeriodicThresholdChecker(check Checker, period time.Duration, threshold int) Checker {
-	tu := NewThresholdStatusUpdater(threshold)
-	go func() {
-		t := time.NewTicker(period)
-		for {
-			<-t.C
-			tu.Update(check.Check())
-		}
-	}()
+	pc := &periodicChecker{Updater: NewThresholdStatusUpdater(threshold), stop: make(chan struct{})}
+	pc.cs.interval = period
+	pc.cs.threshold = threshold
+	go pc.run(check, period)
 
-	return tu
+	return pc
 }
 
 // CheckStatus returns a map with all the current health check errors
@@ -154,6 +325,21 @@ func CheckStatus() map[string]string {
 	return statusKeys
 }
 
+// CheckDetails returns a map with the current details of every registered
+// health check. Unlike CheckStatus, it does not run synchronous checks but
+// reports the outcome of their last call.
+func CheckDetails() map[string]CheckDetail {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	details := make(map[string]CheckDetail)
+	for k, v := range registeredChecks {
+		// Register ensures that every check is tracked.
+		details[k] = v.(trackedChecker).detail()
+	}
+
+	return details
+}
+
 // Register associates the checker with the provided name. We allow
 // overwrites to a specific check status.
 func Register(name string, check Checker) {
@@ -163,7 +349,30 @@ func Register(name string, check Checker) {
 	if ok {
 		panic("Check already exists: " + name)
 	}
-	registeredChecks[name] = check
+	registeredChecks[name] = tracked(check)
+}
+
+// Replace associates the checker with the provided name like Register, but
+// replaces the checker already registered with that name instead of
+// panicking. A replaced periodic checker stops running. This allows an
+// application that is created several times in a process, as in tests, to
+// register its checks each time.
+func Replace(name string, check Checker) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if pc, ok := registeredChecks[name].(*periodicChecker); ok && pc != check {
+		pc.close()
+	}
+	registeredChecks[name] = tracked(check)
+}
+
+// tracked returns check, wrapped to record the outcome of its calls unless it
+// already records the outcome of its runs.
+func tracked(check Checker) Checker {
+	if _, ok := check.(trackedChecker); ok {
+		return check
+	}
+	return &syncChecker{Checker: check}
 }
 
 // RegisterFunc allows the convenience of registering a checker directly
@@ -206,7 +415,33 @@ func StatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Registers global /debug/health api endpoint
+// DetailHandler returns a JSON blob with the details of all the currently
+// registered Health Checks, including their last error, last success time
+// and latency.
+// Returns 503 if any check is failing, 200 otherwise
+func DetailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		details := CheckDetails()
+		for _, detail := range details {
+			if detail.Status == "failed" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				break
+			}
+		}
+		err := json.NewEncoder(w).Encode(details)
+
+		// Parsing of the JSON failed. Returning generic error message
+		if err != nil {
+			w.Write([]byte("{server_error: 'Could not parse error message'}"))
+		}
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Registers global /debug/health and /debug/health/detail api endpoints
 func init() {
 	http.HandleFunc("/debug/health", StatusHandler)
+	http.HandleFunc("/debug/health/detail", DetailHandler)
 }
@@ -1,10 +1,12 @@
 package health
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // TestReturns200IfThereAreNoChecks ensures that the result code of the health
@@ -45,3 +47,104 @@ func TestReturns503IfThereAreErrorChecks(t *testing.T) {
 		t.Errorf("Did not get a 503.")
 	}
 }
+
+// TestDetailHandler ensures that the detail endpoint reports the last error,
+// latency and failure count of registered checks, without running them.
+func TestDetailHandler(t *testing.T) {
+	calls := 0
+	Register("detail_check", CheckFunc(func() error {
+		calls++
+		return errors.New("detail check failed")
+	}))
+	CheckStatus()
+
+	recorder := httptest.NewRecorder()
+
+	req, err := http.NewRequest("GET", "https://fakeurl.com/debug/health/detail", nil)
+	if err != nil {
+		t.Errorf("Failed to create request.")
+	}
+
+	DetailHandler(recorder, req)
+
+	if recorder.Code != 503 {
+		t.Errorf("Did not get a 503.")
+	}
+
+	var details map[string]CheckDetail
+	if err := json.NewDecoder(recorder.Body).Decode(&details); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	detail, ok := details["detail_check"]
+	if !ok {
+		t.Fatalf("detail_check missing from response: %v", details)
+	}
+
+	if detail.Status != "failed" || detail.LastError != "detail check failed" {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+
+	if detail.Failures != 1 || detail.LastChecked == nil || detail.LastSuccess != nil {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+
+	if calls != 1 {
+		t.Errorf("unexpected number of check calls: %d", calls)
+	}
+}
+
+// TestDetailNotCalled ensures that a synchronous check that was never called
+// is reported with an unknown status until its first call.
+func TestDetailNotCalled(t *testing.T) {
+	check := tracked(CheckFunc(func() error { return nil })).(trackedChecker)
+
+	if detail := check.detail(); detail.Status != "unknown" || detail.LastChecked != nil {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+
+	check.Check()
+
+	if detail := check.detail(); detail.Status != "ok" || detail.LastChecked == nil {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+}
+
+// TestReplace ensures that Replace registers a check again under the same
+// name and stops the periodic checker it replaces.
+func TestReplace(t *testing.T) {
+	first := PeriodicChecker(CheckFunc(func() error { return nil }), time.Hour)
+	Replace("replaced_check", first)
+	Replace("replaced_check", CheckFunc(func() error {
+		return errors.New("replacement failed")
+	}))
+
+	select {
+	case <-first.(*periodicChecker).stop:
+	default:
+		t.Errorf("replaced periodic checker not stopped")
+	}
+
+	if status := CheckStatus(); status["replaced_check"] != "replacement failed" {
+		t.Errorf("unexpected status: %v", status)
+	}
+}
+
+// TestCheckStatsThreshold ensures that recorded failures are reported until
+// the next success.
+func TestCheckStatsThreshold(t *testing.T) {
+	cs := &checkStats{threshold: 2}
+	cs.record(time.Now(), errors.New("first"))
+	cs.record(time.Now(), errors.New("second"))
+
+	detail := cs.detail(nil)
+	if detail.Status != "ok" || detail.Failures != 2 || detail.Threshold != 2 || detail.LastError != "second" {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+
+	cs.record(time.Now(), nil)
+	detail = cs.detail(nil)
+	if detail.Failures != 0 || detail.LastError != "" || detail.LastSuccess == nil {
+		t.Errorf("unexpected detail: %#v", detail)
+	}
+}
@@ -1,7 +1,10 @@
 package manifest
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 
 	"github.com/docker/distribution/digest"
 	"github.com/docker/libtrust"
@@ -73,6 +76,41 @@ func (sm *SignedManifest) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ErrManifestTooLarge is returned by DecodeSignedManifest when the manifest
+// exceeds the maximum size.
+type ErrManifestTooLarge struct {
+	MaxSize int64
+}
+
+func (err ErrManifestTooLarge) Error() string {
+	return fmt.Sprintf("manifest exceeds the maximum size of %d bytes", err.MaxSize)
+}
+
+// DecodeSignedManifest reads a signed manifest from r, failing with
+// ErrManifestTooLarge without reading further if it is larger than maxSize
+// bytes. The content is read once and kept as the raw manifest, which the
+// manifest is decoded from without copying it.
+func DecodeSignedManifest(r io.Reader, maxSize int64) (*SignedManifest, error) {
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n > maxSize {
+		return nil, ErrManifestTooLarge{MaxSize: maxSize}
+	}
+
+	// Surrounding whitespace is not part of the manifest, as when it is
+	// decoded from a stream.
+	sm := &SignedManifest{Raw: bytes.TrimSpace(buf.Bytes())}
+	if err := json.Unmarshal(sm.Raw, &sm.Manifest); err != nil {
+		return nil, err
+	}
+
+	return sm, nil
+}
+
 // Payload returns the raw, signed content of the signed manifest. The
 // contents can be used to calculate the content identifier.
 func (sm *SignedManifest) Payload() ([]byte, error) {
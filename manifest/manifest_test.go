@@ -44,6 +44,26 @@ func TestManifestUnmarshaling(t *testing.T) {
 	}
 }
 
+func TestDecodeSignedManifest(t *testing.T) {
+	env := genEnv(t)
+	size := int64(len(env.signed.Raw))
+
+	content := append(append([]byte{}, env.signed.Raw...), '\n')
+	signed, err := DecodeSignedManifest(bytes.NewReader(content), size+1)
+	if err != nil {
+		t.Fatalf("error decoding signed manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(signed, env.signed) {
+		t.Fatalf("manifests are different after decoding: %v != %v", signed, env.signed)
+	}
+
+	_, err = DecodeSignedManifest(bytes.NewReader(env.signed.Raw), size-1)
+	if err != (ErrManifestTooLarge{MaxSize: size - 1}) {
+		t.Fatalf("unexpected error decoding oversized manifest: %v", err)
+	}
+}
+
 func TestManifestVerification(t *testing.T) {
 	env := genEnv(t)
 
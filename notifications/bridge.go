@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -12,14 +13,19 @@ import (
 )
 
 type bridge struct {
-	ub      URLBuilder
-	actor   ActorRecord
-	source  SourceRecord
-	request RequestRecord
-	sink    Sink
-}
-
-var _ Listener = &bridge{}
+	ub              URLBuilder
+	actor           ActorRecord
+	source          SourceRecord
+	request         RequestRecord
+	sink            Sink
+	digestAlgorithm string
+	labels          []string
+}
+
+var (
+	_ Listener       = &bridge{}
+	_ UploadListener = &bridge{}
+)
 
 // URLBuilder defines a subset of url builder to be used by the event listener.
 type URLBuilder interface {
@@ -29,15 +35,20 @@ type URLBuilder interface {
 
 // NewBridge returns a notification listener that writes records to sink,
 // using the actor and source. Any urls populated in the events created by
-// this bridge will be created using the URLBuilder.
+// this bridge will be created using the URLBuilder. Manifests are identified
+// by digests of the named algorithm, which should be the canonical digest
+// algorithm of the registry. Manifest events carry the values of the named
+// labels of the image configuration, when the image has them.
 // TODO(stevvooe): Update this to simply take a context.Context object.
-func NewBridge(ub URLBuilder, source SourceRecord, actor ActorRecord, request RequestRecord, sink Sink) Listener {
+func NewBridge(ub URLBuilder, source SourceRecord, actor ActorRecord, request RequestRecord, sink Sink, digestAlgorithm string, labels []string) Listener {
 	return &bridge{
-		ub:      ub,
-		actor:   actor,
-		source:  source,
-		request: request,
-		sink:    sink,
+		ub:              ub,
+		actor:           actor,
+		source:          source,
+		request:         request,
+		sink:            sink,
+		digestAlgorithm: digestAlgorithm,
+		labels:          labels,
 	}
 }
 
@@ -77,6 +88,24 @@ func (b *bridge) LayerDeleted(repo distribution.Repository, layer distribution.L
 	return b.createLayerEventAndWrite(EventActionDelete, repo, layer)
 }
 
+func (b *bridge) UploadStarted(repo distribution.Repository, upload UploadRecord) error {
+	return b.createUploadEventAndWrite(EventActionUploadStart, repo, upload)
+}
+
+func (b *bridge) UploadProgressed(repo distribution.Repository, upload UploadRecord) error {
+	return b.createUploadEventAndWrite(EventActionUploadProgress, repo, upload)
+}
+
+func (b *bridge) UploadCompleted(repo distribution.Repository, layer distribution.Layer, upload UploadRecord) error {
+	event, err := b.createLayerEvent(EventActionUploadComplete, repo, layer)
+	if err != nil {
+		return err
+	}
+	event.Upload = &upload
+
+	return b.sink.Write(*event)
+}
+
 func (b *bridge) createManifestEventAndWrite(action string, repo distribution.Repository, sm *manifest.SignedManifest) error {
 	manifestEvent, err := b.createManifestEvent(action, repo, sm)
 	if err != nil {
@@ -98,7 +127,7 @@ func (b *bridge) createManifestEvent(action string, repo distribution.Repository
 
 	event.Target.Length = int64(len(p))
 
-	event.Target.Digest, err = digest.FromBytes(p)
+	event.Target.Digest, err = digest.FromBytesWithAlgorithm(b.digestAlgorithm, p)
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +139,57 @@ func (b *bridge) createManifestEvent(action string, repo distribution.Repository
 		return nil, err
 	}
 
+	b.describeImage(event, sm)
+
 	return event, nil
 }
 
+// v1Image holds the fields of the v1 compatibility image configuration that
+// are reported in manifest events.
+type v1Image struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       struct {
+		Labels map[string]string
+	} `json:"config"`
+}
+
+// describeImage sets the platform and the selected labels of the image of sm
+// in the event. The image configuration is taken from the topmost entry of
+// the v1 compatibility history; a manifest without a parsable one is only
+// described by its architecture.
+func (b *bridge) describeImage(event *Event, sm *manifest.SignedManifest) {
+	var image v1Image
+	if len(sm.History) > 0 {
+		if err := json.Unmarshal([]byte(sm.History[0].V1Compatibility), &image); err != nil {
+			image = v1Image{}
+		}
+	}
+
+	if image.Architecture == "" {
+		image.Architecture = sm.Architecture
+	}
+
+	if image.Architecture != "" || image.OS != "" {
+		event.Target.Platforms = []PlatformRecord{{
+			Architecture: image.Architecture,
+			OS:           image.OS,
+		}}
+	}
+
+	for _, label := range b.labels {
+		value, ok := image.Config.Labels[label]
+		if !ok {
+			continue
+		}
+
+		if event.Target.Labels == nil {
+			event.Target.Labels = make(map[string]string)
+		}
+		event.Target.Labels[label] = value
+	}
+}
+
 func (b *bridge) createLayerEventAndWrite(action string, repo distribution.Repository, layer distribution.Layer) error {
 	event, err := b.createLayerEvent(action, repo, layer)
 	if err != nil {
@@ -141,6 +218,18 @@ func (b *bridge) createLayerEvent(action string, repo distribution.Repository, l
 	return event, nil
 }
 
+// createUploadEventAndWrite writes an event for an upload in progress, whose
+// layer is not known yet.
+func (b *bridge) createUploadEventAndWrite(action string, repo distribution.Repository, upload UploadRecord) error {
+	event := b.createEvent(action)
+	event.Target.MediaType = layerMediaType
+	event.Target.Repository = repo.Name()
+	event.Target.Length = upload.Received
+	event.Upload = &upload
+
+	return b.sink.Write(*event)
+}
+
 // createEvent creates an event with actor and source populated.
 func (b *bridge) createEvent(action string) *Event {
 	event := createEvent(action)
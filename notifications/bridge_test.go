@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/libtrust"
+)
+
+type testURLBuilder struct{}
+
+func (testURLBuilder) BuildManifestURL(name, tag string) (string, error) {
+	return "http://example.com/v2/" + name + "/manifests/" + tag, nil
+}
+
+func (testURLBuilder) BuildBlobURL(name string, dgst digest.Digest) (string, error) {
+	return "http://example.com/v2/" + name + "/blobs/" + dgst.String(), nil
+}
+
+type testRepository struct {
+	distribution.Repository
+	name string
+}
+
+func (r testRepository) Name() string {
+	return r.name
+}
+
+func TestBridgeManifestEventImage(t *testing.T) {
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name:         "library/test",
+		Tag:          "latest",
+		Architecture: "amd64",
+		History: []manifest.History{
+			{V1Compatibility: `{"architecture":"arm","os":"linux","config":{"Labels":{"team":"infra","stage":"prod"}}}`},
+		},
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	b := NewBridge(testURLBuilder{}, SourceRecord{}, ActorRecord{}, RequestRecord{}, nil, "sha256", []string{"team", "missing"}).(*bridge)
+	event, err := b.createManifestEvent(EventActionPush, testRepository{name: "library/test"}, sm)
+	if err != nil {
+		t.Fatalf("unexpected error creating event: %v", err)
+	}
+
+	if event.Target.MediaType != manifest.ManifestMediaType {
+		t.Fatalf("unexpected media type: %q", event.Target.MediaType)
+	}
+
+	expectedPlatforms := []PlatformRecord{{Architecture: "arm", OS: "linux"}}
+	if !reflect.DeepEqual(event.Target.Platforms, expectedPlatforms) {
+		t.Fatalf("unexpected platforms: %v != %v", event.Target.Platforms, expectedPlatforms)
+	}
+
+	expectedLabels := map[string]string{"team": "infra"}
+	if !reflect.DeepEqual(event.Target.Labels, expectedLabels) {
+		t.Fatalf("unexpected labels: %v != %v", event.Target.Labels, expectedLabels)
+	}
+
+	// Without a v1 image configuration, the manifest architecture is used.
+	m.History = nil
+	sm, err = manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	event, err = b.createManifestEvent(EventActionPush, testRepository{name: "library/test"}, sm)
+	if err != nil {
+		t.Fatalf("unexpected error creating event: %v", err)
+	}
+
+	expectedPlatforms = []PlatformRecord{{Architecture: "amd64"}}
+	if !reflect.DeepEqual(event.Target.Platforms, expectedPlatforms) {
+		t.Fatalf("unexpected platforms: %v != %v", event.Target.Platforms, expectedPlatforms)
+	}
+
+	if event.Target.Labels != nil {
+		t.Fatalf("unexpected labels: %v", event.Target.Labels)
+	}
+}
+
+func TestBridgeUploadEvent(t *testing.T) {
+	var ts testSink
+	b := NewBridge(testURLBuilder{}, SourceRecord{}, ActorRecord{}, RequestRecord{}, &ts, "sha256", nil).(UploadListener)
+
+	upload := UploadRecord{UUID: "upload", Received: 512, Size: 1024, Percent: 50}
+	if err := b.UploadProgressed(testRepository{name: "library/test"}, upload); err != nil {
+		t.Fatalf("unexpected error writing upload event: %v", err)
+	}
+
+	if len(ts.events) != 1 {
+		t.Fatalf("unexpected events written: %d != 1", len(ts.events))
+	}
+
+	event := ts.events[0]
+	if event.Action != EventActionUploadProgress || event.Target.Repository != "library/test" || event.Target.Length != 512 {
+		t.Fatalf("unexpected upload event: %#v", event)
+	}
+
+	if event.Upload == nil || *event.Upload != upload {
+		t.Fatalf("unexpected upload record: %v != %v", event.Upload, upload)
+	}
+}
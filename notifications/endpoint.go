@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"crypto/tls"
 	"net/http"
 	"time"
 )
@@ -12,6 +13,10 @@ type EndpointConfig struct {
 	Timeout   time.Duration
 	Threshold int
 	Backoff   time.Duration
+
+	// TLSConfig, if set, configures the tls connections to the endpoint,
+	// such as to present a client certificate.
+	TLSConfig *tls.Config
 }
 
 // defaults set any zero-valued fields to a reasonable default.
@@ -53,7 +58,7 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 
 	// Configures the inmemory queue, retry, http pipeline.
 	endpoint.Sink = newHTTPSink(
-		endpoint.url, endpoint.Timeout, endpoint.Headers,
+		endpoint.url, endpoint.Timeout, endpoint.Headers, endpoint.TLSConfig,
 		endpoint.metrics.httpStatusListener())
 	endpoint.Sink = newRetryingSink(endpoint.Sink, endpoint.Threshold, endpoint.Backoff)
 	endpoint.Sink = newEventQueue(endpoint.Sink, endpoint.metrics.eventQueueListener())
@@ -84,3 +89,52 @@ func (e *Endpoint) ReadMetrics(em *EndpointMetrics) {
 		em.Statuses[k] = v
 	}
 }
+
+// PingResult reports the response of an endpoint to a test event.
+type PingResult struct {
+	// Status is the status code of the response, or zero if the endpoint
+	// did not respond.
+	Status int
+
+	// Latency is the duration of the request.
+	Latency time.Duration
+}
+
+// Ping sends event to the endpoint directly, bypassing its queue and retries,
+// and reports the response. The metrics of the endpoint are not affected. An
+// error is returned if the endpoint does not accept the event.
+func (e *Endpoint) Ping(event Event) (PingResult, error) {
+	var listener pingListener
+	sink := newHTTPSink(e.url, e.Timeout, e.Headers, e.TLSConfig, &listener)
+	defer sink.Close()
+
+	err := sink.Write(event)
+	return listener.PingResult, err
+}
+
+// NewPingEvent returns a test event from source, to send to endpoints with
+// Ping.
+func NewPingEvent(source SourceRecord) Event {
+	event := createEvent(EventActionPing)
+	event.Source = source
+	return *event
+}
+
+// pingListener records the response of an endpoint to a ping.
+type pingListener struct {
+	PingResult
+}
+
+func (pl *pingListener) success(status int, events ...Event) {
+	pl.Status = status
+}
+
+func (pl *pingListener) failure(status int, events ...Event) {
+	pl.Status = status
+}
+
+func (pl *pingListener) err(err error, events ...Event) {}
+
+func (pl *pingListener) latency(d time.Duration) {
+	pl.Latency = d
+}
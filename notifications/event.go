@@ -12,6 +12,19 @@ const (
 	EventActionPull   = "pull"
 	EventActionPush   = "push"
 	EventActionDelete = "delete"
+
+	// The upload actions report the progress of large layer uploads.
+	EventActionUploadStart    = "upload.start"
+	EventActionUploadProgress = "upload.progress"
+	EventActionUploadComplete = "upload.complete"
+
+	// EventActionPing is the action of the test events sent to check the
+	// connectivity of an endpoint.
+	EventActionPing = "ping"
+
+	// EventActionRename is the action of the events reporting that a
+	// repository was renamed, with its new name as the target repository.
+	EventActionRename = "rename"
 )
 
 const (
@@ -59,8 +72,21 @@ type Event struct {
 
 		// URL provides a direct link to the content.
 		URL string `json:"url,omitempty"`
+
+		// Platforms lists the platforms the image of a manifest runs on.
+		Platforms []PlatformRecord `json:"platforms,omitempty"`
+
+		// Labels holds the selected labels from the image configuration of a
+		// manifest.
+		Labels map[string]string `json:"labels,omitempty"`
 	} `json:"target,omitempty"`
 
+	// Upload reports the progress of the upload of upload events.
+	Upload *UploadRecord `json:"upload,omitempty"`
+
+	// Rename reports the former name of the repository of rename events.
+	Rename *RenameRecord `json:"rename,omitempty"`
+
 	// Request covers the request that generated the event.
 	Request RequestRecord `json:"request,omitempty"`
 
@@ -74,6 +100,53 @@ type Event struct {
 	Source SourceRecord `json:"source,omitempty"`
 }
 
+// PlatformRecord identifies a platform supported by the image of a
+// manifest.
+type PlatformRecord struct {
+	// Architecture is the cpu architecture of the platform, such as amd64.
+	Architecture string `json:"architecture,omitempty"`
+
+	// OS is the operating system of the platform, such as linux.
+	OS string `json:"os,omitempty"`
+}
+
+// UploadRecord reports the progress of a layer upload.
+type UploadRecord struct {
+	// UUID identifies the upload.
+	UUID string `json:"uuid"`
+
+	// Received is the number of bytes received so far.
+	Received int64 `json:"received"`
+
+	// Size is the expected size of the layer, when the client announced it.
+	Size int64 `json:"size,omitempty"`
+
+	// Percent is the milestone reached by a progress event, in percents of
+	// the expected size. It is zero when the size is unknown.
+	Percent int `json:"percent,omitempty"`
+
+	// Duration is the time elapsed since the start of the upload, in
+	// nanoseconds.
+	Duration time.Duration `json:"duration"`
+}
+
+// RenameRecord reports the former name of a renamed repository.
+type RenameRecord struct {
+	// From is the name of the repository before it was renamed.
+	From string `json:"from"`
+}
+
+// NewRenameEvent returns an event from source reporting that the repository
+// from was renamed to.
+func NewRenameEvent(source SourceRecord, request RequestRecord, from, to string) Event {
+	event := createEvent(EventActionRename)
+	event.Target.Repository = to
+	event.Rename = &RenameRecord{From: from}
+	event.Source = source
+	event.Request = request
+	return *event
+}
+
 // ActorRecord specifies the agent that initiated the event. For most
 // situations, this could be from the authorizaton context of the request.
 // Data in this record can refer to both the initiating client and the
@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// fileSink appends events to a file or to the standard output as JSON lines,
+// one event per line, for consumption by log pipelines. The file is rotated
+// once it grows past its maximum size.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	closed bool
+	w      io.Writer
+	file   *os.File // nil when writing to the standard output
+	size   int64
+}
+
+// NewFileSink returns a sink appending events as JSON lines to the file at
+// path, or to the standard output if path is "-". If maxSize is positive, the
+// file is rotated before it grows larger, keeping maxBackups rotated files
+// named path.1, path.2 and so on, the most recent first.
+func NewFileSink(path string, maxSize int64, maxBackups int) (Sink, error) {
+	fs := &fileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if path == "-" {
+		fs.w = os.Stdout
+		return fs, nil
+	}
+
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Write appends the events to the file, rotating it first if they would
+// grow it past the maximum size.
+func (fs *fileSink) Write(events ...Event) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrSinkClosed
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("%v: error marshaling event: %v", fs, err)
+		}
+	}
+
+	if fs.file != nil && fs.maxSize > 0 && fs.size > 0 && fs.size+int64(buf.Len()) > fs.maxSize {
+		if err := fs.rotate(); err != nil {
+			return fmt.Errorf("%v: error reopening rotated file: %v", fs, err)
+		}
+	}
+
+	n, err := fs.w.Write(buf.Bytes())
+	fs.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("%v: error writing events: %v", fs, err)
+	}
+
+	return nil
+}
+
+// Close closes the file. The standard output is left open.
+func (fs *fileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return fmt.Errorf("filesink: already closed")
+	}
+
+	fs.closed = true
+	if fs.file != nil {
+		return fs.file.Close()
+	}
+	return nil
+}
+
+func (fs *fileSink) String() string {
+	return fmt.Sprintf("fileSink{%s}", fs.path)
+}
+
+// open opens the file for appending.
+func (fs *fileSink) open() error {
+	file, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	fs.file = file
+	fs.w = file
+	fs.size = fi.Size()
+	return nil
+}
+
+// rotate renames the file to path.1, shifting the older backups, and opens a
+// new file. The oldest backup is removed once there are maxBackups. If the
+// file cannot be renamed, it is reopened to keep appending events to it.
+func (fs *fileSink) rotate() error {
+	if err := fs.file.Close(); err != nil {
+		logrus.Errorf("%v: error closing file for rotation: %v", fs, err)
+	}
+
+	if err := fs.shift(); err != nil {
+		logrus.Errorf("%v: error rotating file, appending to it: %v", fs, err)
+	}
+
+	return fs.open()
+}
+
+// shift moves the file and its backups to the next backup.
+func (fs *fileSink) shift() error {
+	if fs.maxBackups <= 0 {
+		return os.Remove(fs.path)
+	}
+
+	for i := fs.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(fs.backup(i), fs.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(fs.path, fs.backup(1))
+}
+
+// backup returns the path of the ith most recent backup.
+func (fs *fileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", fs.path, i)
+}
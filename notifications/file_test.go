@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileSink checks that events are appended as JSON lines, and that the
+// file is rotated once it grows past its maximum size.
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.log")
+	event := createTestEvent("push", "library/test", "blob")
+	p, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling event: %v", err)
+	}
+	lineSize := int64(len(p) + 1)
+
+	// Each file holds two events.
+	sink, err := NewFileSink(path, 2*lineSize, 2)
+	if err != nil {
+		t.Fatalf("unexpected error creating file sink: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(event, event); err != nil {
+			t.Fatalf("unexpected error writing events: %v", err)
+		}
+	}
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+	checkClose(t, sink)
+
+	for _, testcase := range []struct {
+		path   string
+		events int
+	}{
+		{path: path, events: 1},
+		{path: path + ".1", events: 2},
+		{path: path + ".2", events: 2},
+	} {
+		if n := readEventLines(t, testcase.path); n != testcase.events {
+			t.Errorf("unexpected number of events in %s: %d != %d", testcase.path, n, testcase.events)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected the oldest backup to be removed: %v", err)
+	}
+
+	if err := sink.Write(event); err != ErrSinkClosed {
+		t.Fatalf("unexpected error writing to closed sink: %v", err)
+	}
+}
+
+// readEventLines returns the number of events in the file at path, checking
+// that each line is an event.
+func readEventLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("unexpected error decoding line %d of %s: %v", n+1, path, err)
+		}
+		if event.Action != "push" {
+			t.Fatalf("unexpected event in %s: %#v", path, event)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected error reading %s: %v", path, err)
+	}
+
+	return n
+}
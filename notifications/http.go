@@ -2,8 +2,10 @@ package notifications
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -25,14 +27,28 @@ type httpSink struct {
 }
 
 // newHTTPSink returns an unreliable, single-flight http sink. Wrap in other
-// sinks for increased reliability.
-func newHTTPSink(u string, timeout time.Duration, headers http.Header, listeners ...httpStatusListener) *httpSink {
+// sinks for increased reliability. If tlsConfig is nil, the default
+// transport is used.
+func newHTTPSink(u string, timeout time.Duration, headers http.Header, tlsConfig *tls.Config, listeners ...httpStatusListener) *httpSink {
+	transport := http.DefaultTransport.(*http.Transport)
+	if tlsConfig != nil {
+		transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     tlsConfig,
+		}
+	}
+
 	return &httpSink{
 		url:       u,
 		listeners: listeners,
 		client: &http.Client{
 			Transport: &headerRoundTripper{
-				Transport: http.DefaultTransport.(*http.Transport),
+				Transport: transport,
 				headers:   headers,
 			},
 			Timeout: timeout,
@@ -45,6 +61,10 @@ type httpStatusListener interface {
 	success(status int, events ...Event)
 	failure(status int, events ...Event)
 	err(err error, events ...Event)
+
+	// latency is called with the duration of each request answered by the
+	// endpoint.
+	latency(d time.Duration)
 }
 
 // Accept makes an attempt to notify the endpoint, returning an error if it
@@ -76,6 +96,7 @@ func (hs *httpSink) Write(events ...Event) error {
 	}
 
 	body := bytes.NewReader(p)
+	start := time.Now()
 	resp, err := hs.client.Post(hs.url, EventsMediaType, body)
 	if err != nil {
 		for _, listener := range hs.listeners {
@@ -86,6 +107,11 @@ func (hs *httpSink) Write(events ...Event) error {
 	}
 	defer resp.Body.Close()
 
+	latency := time.Since(start)
+	for _, listener := range hs.listeners {
+		listener.latency(latency)
+	}
+
 	// The notifier will treat any 2xx or 3xx response as accepted by the
 	// endpoint.
 	switch {
@@ -1,6 +1,8 @@
 package notifications
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"mime"
@@ -60,7 +62,7 @@ func TestHTTPSink(t *testing.T) {
 	}))
 
 	metrics := newSafeMetrics()
-	sink := newHTTPSink(server.URL, 0, nil,
+	sink := newHTTPSink(server.URL, 0, nil, nil,
 		&endpointMetricsHTTPStatusListener{safeMetrics: metrics})
 
 	var expectedMetrics EndpointMetrics
@@ -107,6 +109,7 @@ func TestHTTPSink(t *testing.T) {
 
 		if tc.statusCode > 0 {
 			expectedMetrics.Statuses[fmt.Sprintf("%d %s", tc.statusCode, http.StatusText(tc.statusCode))] += len(tc.events)
+			expectedMetrics.Latency.Requests++
 		}
 
 		url := tc.url
@@ -131,6 +134,22 @@ func TestHTTPSink(t *testing.T) {
 			}
 		}
 
+		// The timings vary, so they are only checked for consistency.
+		if metrics.Latency.Requests != expectedMetrics.Latency.Requests {
+			t.Fatalf("unexpected number of answered requests: %d != %d", metrics.Latency.Requests, expectedMetrics.Latency.Requests)
+		}
+		if metrics.Latency.Requests > 0 && (metrics.Latency.Last <= 0 || metrics.Latency.Max < metrics.Latency.Last || metrics.Latency.Total < metrics.Latency.Max) {
+			t.Fatalf("inconsistent latency metrics: %#v", metrics.Latency)
+		}
+		if !tc.failure && len(tc.events) > 0 {
+			if metrics.LastSuccess.IsZero() || metrics.Lag <= 0 {
+				t.Fatalf("expected lag and last success to be recorded: %v, %v", metrics.Lag, metrics.LastSuccess)
+			}
+		}
+		expectedMetrics.Latency = metrics.Latency
+		expectedMetrics.Lag = metrics.Lag
+		expectedMetrics.LastSuccess = metrics.LastSuccess
+
 		if !reflect.DeepEqual(metrics.EndpointMetrics, expectedMetrics) {
 			t.Fatalf("metrics not as expected: %#v != %#v", metrics.EndpointMetrics, expectedMetrics)
 		}
@@ -147,6 +166,39 @@ func TestHTTPSink(t *testing.T) {
 
 }
 
+// TestHTTPSinkTLS checks that the sink authenticates a tls endpoint with the
+// configured CAs and sends it the static headers.
+func TestHTTPSinkTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer server.Close()
+
+	headers := http.Header{"Authorization": []string{"Bearer secret"}}
+	event := createTestEvent("push", "library/test", manifest.ManifestMediaType)
+
+	// The test server certificate is not trusted by default.
+	sink := newHTTPSink(server.URL, 0, headers, nil)
+	if err := sink.Write(event); err == nil {
+		t.Fatalf("expected error writing to untrusted endpoint")
+	}
+
+	cert, err := x509.ParseCertificate(server.TLS.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("unexpected error parsing server certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	sink = newHTTPSink(server.URL, 0, headers, &tls.Config{RootCAs: pool})
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("unexpected error writing to trusted endpoint: %v", err)
+	}
+}
+
 func createTestEvent(action, repo, typ string) Event {
 	event := createEvent(action)
 
@@ -29,6 +29,15 @@ type LayerListener interface {
 	LayerDeleted(repo distribution.Repository, layer distribution.Layer) error
 }
 
+// UploadListener describes a listener that can respond to the progress of
+// large layer uploads. It is optional for listeners, and is driven by the
+// upload handlers rather than by the repositories.
+type UploadListener interface {
+	UploadStarted(repo distribution.Repository, upload UploadRecord) error
+	UploadProgressed(repo distribution.Repository, upload UploadRecord) error
+	UploadCompleted(repo distribution.Repository, layer distribution.Layer, upload UploadRecord) error
+}
+
 // Listener combines all repository events into a single interface.
 type Listener interface {
 	ManifestListener
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // EndpointMetrics track various actions taken by the endpoint, typically by
@@ -17,6 +18,27 @@ type EndpointMetrics struct {
 	Failures  int            // total events failed
 	Errors    int            // total events errored
 	Statuses  map[string]int // status code histogram, per call event
+
+	// Latency tracks the duration of the requests answered by the
+	// endpoint.
+	Latency LatencyMetrics
+
+	// Lag is the time from the creation of the oldest event of the last
+	// successful request to its delivery. A growing lag indicates that the
+	// endpoint falls behind.
+	Lag time.Duration
+
+	// LastSuccess is the time of the last successful request.
+	LastSuccess time.Time
+}
+
+// LatencyMetrics tracks the duration of requests. The mean latency over a
+// period is the increase of Total divided by the increase of Requests.
+type LatencyMetrics struct {
+	Requests int           // total requests answered
+	Total    time.Duration // total duration of the requests
+	Max      time.Duration // longest request
+	Last     time.Duration // duration of the last request
 }
 
 // safeMetrics guards the metrics implementation with a lock and provides a
@@ -61,6 +83,15 @@ func (emsl *endpointMetricsHTTPStatusListener) success(status int, events ...Eve
 	defer emsl.safeMetrics.Unlock()
 	emsl.Statuses[fmt.Sprintf("%d %s", status, http.StatusText(status))] += len(events)
 	emsl.Successes += len(events)
+
+	now := time.Now()
+	emsl.LastSuccess = now
+	emsl.Lag = 0
+	for _, event := range events {
+		if lag := now.Sub(event.Timestamp); lag > emsl.Lag {
+			emsl.Lag = lag
+		}
+	}
 }
 
 func (emsl *endpointMetricsHTTPStatusListener) failure(status int, events ...Event) {
@@ -70,6 +101,17 @@ func (emsl *endpointMetricsHTTPStatusListener) failure(status int, events ...Eve
 	emsl.Failures += len(events)
 }
 
+func (emsl *endpointMetricsHTTPStatusListener) latency(d time.Duration) {
+	emsl.safeMetrics.Lock()
+	defer emsl.safeMetrics.Unlock()
+	emsl.Latency.Requests++
+	emsl.Latency.Total += d
+	emsl.Latency.Last = d
+	if d > emsl.Latency.Max {
+		emsl.Latency.Max = d
+	}
+}
+
 func (emsl *endpointMetricsHTTPStatusListener) err(err error, events ...Event) {
 	emsl.safeMetrics.Lock()
 	defer emsl.safeMetrics.Unlock()
@@ -109,6 +151,22 @@ func register(e *Endpoint) {
 	endpoints.registered = append(endpoints.registered, e)
 }
 
+// replicators is global registry of replicators used to report metrics to
+// expvar.
+var replicators struct {
+	registered []*Replicator
+	mu         sync.Mutex
+}
+
+// registerReplicator places the replicator into expvar so that stats are
+// tracked.
+func registerReplicator(r *Replicator) {
+	replicators.mu.Lock()
+	defer replicators.mu.Unlock()
+
+	replicators.registered = append(replicators.registered, r)
+}
+
 func init() {
 	// NOTE(stevvooe): Setup registry metrics structure to report to expvar.
 	// Ideally, we do more metrics through logging but we need some nice
@@ -148,5 +206,31 @@ func init() {
 		return names
 	}))
 
+	notifications.Set("replicators", expvar.Func(func() interface{} {
+		replicators.mu.Lock()
+		defer replicators.mu.Unlock()
+
+		var names []interface{}
+		for _, v := range replicators.registered {
+			var rjson struct {
+				Name string `json:"name"`
+				URL  string `json:"url"`
+				ReplicatorConfig
+
+				Metrics ReplicatorMetrics
+			}
+
+			rjson.Name = v.Name()
+			rjson.URL = v.URL()
+			rjson.ReplicatorConfig = v.ReplicatorConfig
+
+			v.ReadMetrics(&rjson.Metrics)
+
+			names = append(names, rjson)
+		}
+
+		return names
+	}))
+
 	registry.(*expvar.Map).Set("notifications", &notifications)
 }
@@ -0,0 +1,349 @@
+package notifications
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/client"
+	"golang.org/x/net/context"
+)
+
+// ReplicatorConfig covers the optional configuration parameters for a
+// replicator.
+type ReplicatorConfig struct {
+	// Repositories lists patterns, in the syntax of path.Match, selecting
+	// the repositories to replicate. All repositories are replicated if it
+	// is empty.
+	Repositories []string
+
+	// Attempts is the number of times the replication of an event is tried
+	// before it is dropped.
+	Attempts int
+
+	// Backoff is the time to wait between attempts.
+	Backoff time.Duration
+
+	// Username and Password authenticate the requests to the peer with
+	// HTTP basic authentication, if Username is set.
+	Username string
+	Password string
+
+	// Token authenticates the requests to the peer as a bearer token, if
+	// set. It excludes Username.
+	Token string
+
+	// Timeout bounds each request to the peer, including the upload of a
+	// layer, so that a stuck peer fails the attempt.
+	Timeout time.Duration
+}
+
+// defaults set any zero-valued fields to a reasonable default.
+func (rc *ReplicatorConfig) defaults() {
+	if rc.Attempts <= 0 {
+		rc.Attempts = 10
+	}
+
+	if rc.Backoff <= 0 {
+		rc.Backoff = time.Second
+	}
+
+	if rc.Timeout <= 0 {
+		rc.Timeout = 5 * time.Minute
+	}
+}
+
+// ReplicatorMetrics track the replication of events to a peer.
+type ReplicatorMetrics struct {
+	Pending   int // events pending in queue
+	Events    int // total events incoming
+	Skipped   int // total events filtered out or already present on the peer
+	Successes int // total events replicated successfully
+	Failures  int // total events dropped after exhausting all attempts
+	Retries   int // total failed attempts that were retried
+}
+
+// Replicator is a queued, thread-safe sink that replicates pushed manifests
+// and their layers from the local registry to a peer registry. Writes are
+// non-blocking and always succeed for callers.
+type Replicator struct {
+	Sink
+	name string
+	url  string
+
+	ReplicatorConfig
+
+	metrics struct {
+		ReplicatorMetrics
+		sync.Mutex
+	}
+}
+
+// NewReplicator returns a running replicator, reading content from registry
+// and pushing it to the peer registry at url.
+func NewReplicator(name, url string, registry distribution.Namespace, config ReplicatorConfig) (*Replicator, error) {
+	config.defaults()
+
+	options := []client.Option{
+		client.WithTransportConfig(client.TransportConfig{}),
+		client.WithTimeout(config.Timeout),
+	}
+	switch {
+	case config.Username != "" && config.Token != "":
+		return nil, fmt.Errorf("replicator %s: username and token are exclusive", name)
+	case config.Username != "":
+		options = append(options, client.WithBasicAuth(config.Username, config.Password))
+	case config.Token != "":
+		options = append(options, client.WithBearerToken(config.Token))
+	}
+
+	c, err := client.New(url, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicator Replicator
+	replicator.name = name
+	replicator.url = url
+	replicator.ReplicatorConfig = config
+
+	replicator.Sink = newEventQueue(&replicationSink{
+		replicator: &replicator,
+		registry:   registry,
+		client:     c,
+	}, &replicatorEventQueueListener{&replicator})
+
+	registerReplicator(&replicator)
+	return &replicator, nil
+}
+
+// Name returns the name of the replicator, generally used for debugging.
+func (r *Replicator) Name() string {
+	return r.name
+}
+
+// URL returns the url of the peer registry.
+func (r *Replicator) URL() string {
+	return r.url
+}
+
+// ReadMetrics populates rm with metrics from the replicator.
+func (r *Replicator) ReadMetrics(rm *ReplicatorMetrics) {
+	r.metrics.Lock()
+	defer r.metrics.Unlock()
+
+	*rm = r.metrics.ReplicatorMetrics
+}
+
+// update applies fn to the metrics of the replicator under lock.
+func (r *Replicator) update(fn func(rm *ReplicatorMetrics)) {
+	r.metrics.Lock()
+	defer r.metrics.Unlock()
+
+	fn(&r.metrics.ReplicatorMetrics)
+}
+
+// selected returns true if the repository is selected for replication.
+func (r *Replicator) selected(repository string) bool {
+	if len(r.Repositories) == 0 {
+		return true
+	}
+
+	for _, pattern := range r.Repositories {
+		if matched, _ := path.Match(pattern, repository); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replicatorEventQueueListener maintains the queue related counters of a
+// replicator.
+type replicatorEventQueueListener struct {
+	*Replicator
+}
+
+func (rql *replicatorEventQueueListener) ingress(events ...Event) {
+	rql.update(func(rm *ReplicatorMetrics) {
+		rm.Events += len(events)
+		rm.Pending += len(events)
+	})
+}
+
+func (rql *replicatorEventQueueListener) egress(events ...Event) {
+	rql.update(func(rm *ReplicatorMetrics) {
+		rm.Pending -= len(events)
+	})
+}
+
+// replicationSink replicates the manifest push events written to it,
+// retrying each event up to the configured number of attempts.
+type replicationSink struct {
+	replicator *Replicator
+	registry   distribution.Namespace
+	client     client.Client
+
+	// mu serializes the attempts, so that retries do not add to the load of
+	// a failing peer.
+	mu     sync.Mutex
+	closed bool
+}
+
+// Write replicates the events in order. Failed events are attempted again
+// after the backoff in the background, so that they do not hold up the
+// events queued after them, and are logged and dropped after all attempts.
+func (rs *replicationSink) Write(events ...Event) error {
+	for _, event := range events {
+		if event.Action != EventActionPush ||
+			event.Target.MediaType != manifest.ManifestMediaType ||
+			!rs.replicator.selected(event.Target.Repository) {
+			rs.replicator.update(func(rm *ReplicatorMetrics) { rm.Skipped++ })
+			continue
+		}
+
+		rs.attempt(event, 1)
+	}
+
+	return nil
+}
+
+// attempt replicates the event, scheduling the next attempt if it fails.
+func (rs *replicationSink) attempt(event Event, attempt int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.closed {
+		return
+	}
+
+	replicated, err := rs.replicate(event)
+	if err == nil {
+		rs.replicator.update(func(rm *ReplicatorMetrics) {
+			if replicated {
+				rm.Successes++
+			} else {
+				rm.Skipped++
+			}
+		})
+		return
+	}
+
+	if attempt >= rs.replicator.Attempts {
+		logrus.Errorf("replicator %s: dropping event %s for %s@%s after %d attempts: %v",
+			rs.replicator.name, event.ID, event.Target.Repository, event.Target.Digest, attempt, err)
+		rs.replicator.update(func(rm *ReplicatorMetrics) { rm.Failures++ })
+		return
+	}
+
+	logrus.Warnf("replicator %s: error replicating %s@%s, retrying after %v: %v",
+		rs.replicator.name, event.Target.Repository, event.Target.Digest, rs.replicator.Backoff, err)
+	rs.replicator.update(func(rm *ReplicatorMetrics) { rm.Retries++ })
+	time.AfterFunc(rs.replicator.Backoff, func() {
+		rs.attempt(event, attempt+1)
+	})
+}
+
+// Close implements the Sink interface. Pending retries are dropped.
+func (rs *replicationSink) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.closed = true
+	return nil
+}
+
+// replicate pushes the manifest targeted by the event and its missing layers
+// to the peer. It returns false if the peer already had the manifest.
+func (rs *replicationSink) replicate(event Event) (bool, error) {
+	ctx := context.Background()
+	repo, err := rs.registry.Repository(ctx, event.Target.Repository)
+	if err != nil {
+		return false, err
+	}
+
+	sm, err := repo.Manifests().Get(event.Target.Digest)
+	if err != nil {
+		return false, err
+	}
+
+	// A retried event may have been superseded by a later push of the tag,
+	// which must not be overwritten on the peer.
+	if tagged, err := repo.Manifests().GetByTag(sm.Tag); err == nil {
+		if dgst, err := manifestDigest(event.Target.Digest.Algorithm(), tagged); err == nil && dgst != event.Target.Digest {
+			return false, nil
+		}
+	}
+
+	// Checking the peer first avoids replicating the manifest back and forth
+	// between peers that replicate to each other.
+	if remote, err := rs.client.GetImageManifest(sm.Name, sm.Tag); err == nil {
+		if dgst, err := manifestDigest(event.Target.Digest.Algorithm(), remote); err == nil && dgst == event.Target.Digest {
+			return false, nil
+		}
+	}
+
+	pushed := make(map[digest.Digest]struct{})
+	for _, fsLayer := range sm.FSLayers {
+		if _, ok := pushed[fsLayer.BlobSum]; ok {
+			continue
+		}
+
+		if err := rs.replicateLayer(repo, fsLayer.BlobSum); err != nil {
+			return false, err
+		}
+		pushed[fsLayer.BlobSum] = struct{}{}
+	}
+
+	if err := rs.client.PutImageManifest(sm.Name, sm.Tag, sm); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// replicateLayer uploads the layer to the peer, unless it already has it.
+func (rs *replicationSink) replicateLayer(repo distribution.Repository, dgst digest.Digest) error {
+	length, err := rs.client.BlobLength(repo.Name(), dgst)
+	if err != nil {
+		return err
+	}
+
+	if length >= 0 {
+		return nil // the peer already has the layer
+	}
+
+	layer, err := repo.Layers().Fetch(dgst)
+	if err != nil {
+		return err
+	}
+
+	location, err := rs.client.InitiateBlobUpload(repo.Name())
+	if err != nil {
+		layer.Close()
+		return err
+	}
+
+	// UploadBlob closes the layer.
+	return rs.client.UploadBlob(location, layer, int(layer.Length()), dgst)
+}
+
+func (rs *replicationSink) String() string {
+	return fmt.Sprintf("replicator %s (%s)", rs.replicator.name, rs.replicator.url)
+}
+
+// manifestDigest returns the digest of the payload of the manifest, using
+// the named algorithm.
+func manifestDigest(alg string, sm *manifest.SignedManifest) (digest.Digest, error) {
+	p, err := sm.Payload()
+	if err != nil {
+		return "", err
+	}
+
+	return digest.FromBytesWithAlgorithm(alg, p)
+}
@@ -100,6 +100,95 @@ func (b *Broadcaster) run() {
 	}
 }
 
+// DeduplicatingSink drops events identical to an event written within the
+// deduplication window, so that repeated pulls of the same content by the
+// same actor are delivered once. Events are identical if they have the same
+// action, repository, digest and actor. Upload events are never dropped.
+type DeduplicatingSink struct {
+	Sink
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[dedupKey]time.Time
+	lastSweep time.Time
+}
+
+// dedupKey identifies events deduplicated by DeduplicatingSink.
+type dedupKey struct {
+	action     string
+	repository string
+	digest     string
+	actor      string
+}
+
+// NewDeduplicatingSink returns a sink writing events to sink, except for the
+// duplicates of events written within window.
+func NewDeduplicatingSink(sink Sink, window time.Duration) *DeduplicatingSink {
+	return &DeduplicatingSink{
+		Sink:   sink,
+		window: window,
+		seen:   make(map[dedupKey]time.Time),
+	}
+}
+
+// Write writes the events that are not duplicates to the underlying sink.
+func (ds *DeduplicatingSink) Write(events ...Event) error {
+	events = ds.filter(time.Now(), events)
+	if len(events) == 0 {
+		return nil
+	}
+
+	return ds.Sink.Write(events...)
+}
+
+// filter returns the events not seen within the window before now, and
+// records them as seen. The events are left as they are, since the caller
+// may write them to other sinks.
+func (ds *DeduplicatingSink) filter(now time.Time, events []Event) []Event {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	// Forget the events outside of the window once per window, so that the
+	// map does not grow without bound.
+	if now.Sub(ds.lastSweep) > ds.window {
+		for key, t := range ds.seen {
+			if now.Sub(t) > ds.window {
+				delete(ds.seen, key)
+			}
+		}
+		ds.lastSweep = now
+	}
+
+	filtered := make([]Event, 0, len(events))
+	for _, event := range events {
+		// Upload events report progress, so they are never duplicates.
+		if event.Upload != nil {
+			filtered = append(filtered, event)
+			continue
+		}
+
+		key := dedupKey{
+			action:     event.Action,
+			repository: event.Target.Repository,
+			digest:     string(event.Target.Digest),
+			actor:      event.Actor.Name,
+		}
+
+		if t, ok := ds.seen[key]; ok && now.Sub(t) <= ds.window {
+			continue
+		}
+
+		ds.seen[key] = now
+		filtered = append(filtered, event)
+	}
+
+	return filtered
+}
+
+func (ds *DeduplicatingSink) String() string {
+	return fmt.Sprintf("deduplicatingSink{%v}", ds.Sink)
+}
+
 // eventQueue accepts all messages into a queue for asynchronous consumption
 // by a sink. It is unbounded and thread safe but the sink must be reliable or
 // events will be dropped.
@@ -159,6 +159,65 @@ func TestRetryingSink(t *testing.T) {
 	}
 }
 
+func TestDeduplicatingSink(t *testing.T) {
+	var ts testSink
+	ds := NewDeduplicatingSink(&ts, time.Minute)
+
+	pull := createTestEvent("pull", "library/test", "blob")
+	pull.Target.Digest = "sha256:abc"
+	pull.Actor.Name = "alice"
+
+	otherActor := pull
+	otherActor.Actor.Name = "bob"
+
+	push := pull
+	push.Action = "push"
+
+	progress := createTestEvent(EventActionUploadProgress, "library/test", "blob")
+	progress.Upload = &UploadRecord{UUID: "upload", Received: 1024}
+
+	now := time.Now()
+	for _, tc := range []struct {
+		at       time.Time
+		events   []Event
+		expected int
+	}{
+		{at: now, events: []Event{pull, pull}, expected: 1},
+		{at: now.Add(time.Second), events: []Event{pull, otherActor, push}, expected: 2},
+		{at: now.Add(30 * time.Second), events: []Event{pull}, expected: 0},
+		{at: now.Add(2 * time.Minute), events: []Event{pull}, expected: 1},
+		{at: now.Add(2 * time.Minute), events: []Event{progress, progress}, expected: 2},
+	} {
+		if filtered := ds.filter(tc.at, tc.events); len(filtered) != tc.expected {
+			t.Fatalf("unexpected events after deduplication at %v: %d != %d", tc.at.Sub(now), len(filtered), tc.expected)
+		}
+	}
+
+	// The events written are not modified, since they may be written to
+	// other sinks.
+	events := []Event{pull, pull, push}
+	ds.filter(now.Add(time.Hour), events)
+	if events[0].Action != "pull" || events[1].Action != "pull" || events[2].Action != "push" {
+		t.Fatalf("events modified by deduplication: %v", events)
+	}
+
+	if err := ds.Write(otherActor, otherActor); err != nil {
+		t.Fatalf("unexpected error writing events: %v", err)
+	}
+
+	if len(ts.events) != 1 {
+		t.Fatalf("unexpected events written: %d != %d", len(ts.events), 1)
+	}
+
+	if err := ds.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	if !ts.closed {
+		t.Fatalf("sink should have been closed")
+	}
+}
+
 type testSink struct {
 	events []Event
 	mu     sync.Mutex
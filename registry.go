@@ -155,6 +155,11 @@ type LayerUpload interface {
 	// StartedAt returns the time this layer upload was started.
 	StartedAt() time.Time
 
+	// SetContentEncoding records the content encoding of the uploaded layer,
+	// such as gzip for pre-compressed content, to serve the layer with once
+	// the upload is finished.
+	SetContentEncoding(encoding string)
+
 	// Finish marks the upload as completed, returning a valid handle to the
 	// uploaded layer. The digest is validated against the contents of the
 	// uploaded layer.
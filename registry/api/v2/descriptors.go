@@ -65,6 +65,16 @@ var (
 		},
 	}
 
+	trustServerHeader = ParameterDescriptor{
+		Name:        "Docker-Content-Trust-Server",
+		Type:        "url",
+		Description: "The base url of the trust service holding the signatures of the content of the registry. Set on all responses when the registry is configured with a trust service.",
+		Format:      "<url>",
+		Examples: []string{
+			"https://notary.example.com",
+		},
+	}
+
 	contentLengthZeroHeader = ParameterDescriptor{
 		Name:        "Content-Length",
 		Description: "The `Content-Length` header must be zero and the body must be empty.",
@@ -165,6 +175,39 @@ const (
         ...
     ]
 }`
+
+	infoBody = `{
+    "version": "<registry version>",
+    "package": "<import path of the registry>",
+    "storage": {
+        "driver": "<storage driver name>"
+    },
+    "extensions": {
+        "delete": <true|false>,
+        "redirect": <true|false>,
+        "proxy": <true|false>,
+        "redirectDisabled": [
+            "<namespace prefix>",
+            ...
+        ]
+    },
+    "manifestMediaTypes": [
+        "<media type>",
+        ...
+    ],
+    "trustServer": "<url of the trust service, if any>"
+}`
+
+	namespacesBody = `{
+    "parent": "<parent namespace, if any>",
+    "namespaces": [
+        {
+            "name": "<namespace>",
+            "repositories": <number of repositories beneath the namespace>
+        },
+        ...
+    ]
+}`
 )
 
 // APIDescriptor exports descriptions of the layout of the v2 registry API.
@@ -361,6 +404,9 @@ var routeDescriptors = []RouteDescriptor{
 							{
 								Description: "The API implements V2 protocol and is accessible.",
 								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									trustServerHeader,
+								},
 							},
 						},
 						Failures: []ResponseDescriptor{
@@ -369,6 +415,7 @@ var routeDescriptors = []RouteDescriptor{
 								StatusCode:  http.StatusUnauthorized,
 								Headers: []ParameterDescriptor{
 									authChallengeHeader,
+									trustServerHeader,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json; charset=utf-8",
@@ -388,6 +435,134 @@ var routeDescriptors = []RouteDescriptor{
 			},
 		},
 	},
+	{
+		Name:        RouteNameInfo,
+		Path:        "/v2/_info",
+		Entity:      "Info",
+		Description: `Registry information route. Reports the registry version and enabled capabilities, so that clients and operators can discover them programmatically.`,
+		Methods: []MethodDescriptor{
+			{
+				Method:      "GET",
+				Description: "Fetch the version and capabilities of the registry.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The registry version and capabilities. `redirect` is true if the storage backend can serve layers to clients through redirects, except for the repositories of the namespaces listed in `redirectDisabled`.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      infoBody,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Description: "The client is not authorized to access the registry.",
+								StatusCode:  http.StatusUnauthorized,
+								Headers: []ParameterDescriptor{
+									authChallengeHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeUnauthorized,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameNamespaces,
+		Path:        "/v2/_namespaces",
+		Entity:      "Namespaces",
+		Description: `List the namespaces of the registry one level at a time, with the number of repositories beneath each of them, so that clients can build tree views of the repositories. The namespaces are read from the repository index, and are empty when it is not enabled.`,
+		Methods: []MethodDescriptor{
+			{
+				Method:      "GET",
+				Description: "Fetch the namespaces directly beneath the `parent` namespace, or the top-level namespaces. Access to the `registry:catalog` resource is required.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "parent",
+								Type:        "query",
+								Format:      "<namespace>",
+								Regexp:      RepositoryNameRegexp,
+								Description: "Namespace whose direct children are listed. The top-level namespaces are listed when it is missing.",
+							},
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The namespaces directly beneath the parent namespace, sorted by name. A repository named like a namespace is not counted in it.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      namespacesBody,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Description: "The parent namespace is not a valid repository name.",
+								StatusCode:  http.StatusBadRequest,
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameInvalid,
+								},
+							},
+							{
+								Description: "The client is not authorized to list the namespaces.",
+								StatusCode:  http.StatusUnauthorized,
+								Headers: []ParameterDescriptor{
+									authChallengeHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeUnauthorized,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        RouteNameTags,
 		Path:        "/v2/{name:" + RepositoryNameRegexp.String() + "}/tags/list",
@@ -531,6 +706,56 @@ var routeDescriptors = []RouteDescriptor{
 					},
 				},
 			},
+			{
+				Method:      "HEAD",
+				Description: "Resolve the manifest identified by `name` and `reference` to its digest and length without fetching it, such as to check whether a tag has changed.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							tagParameterDescriptor,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The manifest identified by `name` and `reference` exists. The response has the headers of a `GET` request, without the manifest.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the manifest.",
+										Format:      "<length>",
+									},
+									{
+										Name:        "Content-Type",
+										Type:        "string",
+										Description: "Media type of the manifest.",
+										Format:      "application/json; charset=utf-8",
+									},
+									digestHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								StatusCode:  http.StatusUnauthorized,
+								Description: "The client does not have access to the repository.",
+								Headers: []ParameterDescriptor{
+									authChallengeHeader,
+								},
+							},
+							{
+								Description: "The named manifest is not known to the registry. The response has no body.",
+								StatusCode:  http.StatusNotFound,
+							},
+						},
+					},
+				},
+			},
 			{
 				Method:      "PUT",
 				Description: "Put the manifest identified by `name` and `reference` where `reference` can be a tag or digest.",
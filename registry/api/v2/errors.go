@@ -3,6 +3,8 @@ package v2
 import (
 	"fmt"
 	"strings"
+
+	"github.com/docker/distribution/digest"
 )
 
 // ErrorCode represents the error type. The errors are serialized via strings
@@ -132,6 +134,30 @@ func (e Error) Error() string {
 // for use within the application.
 type Errors struct {
 	Errors []Error `json:"errors,omitempty"`
+
+	// RequestID identifies the request which failed, so that the errors
+	// reported to clients can be correlated with the registry logs.
+	RequestID string `json:"requestid,omitempty"`
+}
+
+// DigestMismatchDetail is the detail of errors about content which does not
+// match the digest provided by the client.
+type DigestMismatchDetail struct {
+	// Expected is the digest provided by the client.
+	Expected digest.Digest `json:"expected"`
+
+	// Received is the digest of the received content, if known.
+	Received digest.Digest `json:"received,omitempty"`
+}
+
+// MediaTypeDetail is the detail of errors about content sent with an
+// unsupported media type.
+type MediaTypeDetail struct {
+	// MediaType is the media type of the content.
+	MediaType string `json:"mediatype"`
+
+	// Accepted lists the supported media types.
+	Accepted []string `json:"accepted,omitempty"`
 }
 
 // Push pushes an error on to the error stack, with the optional detail
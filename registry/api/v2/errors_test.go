@@ -163,3 +163,28 @@ func TestMarshalUnmarshal(t *testing.T) {
 		t.Fatalf("errors not equal after round trip: %#v != %#v", unmarshaled, errors)
 	}
 }
+
+// TestErrorsDetails checks that structured details and the request ID are
+// marshaled alongside the errors.
+func TestErrorsDetails(t *testing.T) {
+	errs := Errors{RequestID: "some-request-id"}
+	errs.Push(ErrorCodeDigestInvalid, DigestMismatchDetail{
+		Expected: "sha256:expected",
+		Received: "sha256:received",
+	})
+	errs.Push(ErrorCodeBlobUploadInvalid, MediaTypeDetail{
+		MediaType: "text/plain",
+		Accepted:  []string{"application/octet-stream"},
+	})
+
+	p, err := json.Marshal(errs)
+	if err != nil {
+		t.Fatalf("error marshaling errors: %v", err)
+	}
+
+	expectedJSON := `{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content","detail":{"expected":"sha256:expected","received":"sha256:received"}},{"code":"BLOB_UPLOAD_INVALID","message":"blob upload invalid","detail":{"mediatype":"text/plain","accepted":["application/octet-stream"]}}],"requestid":"some-request-id"}`
+
+	if string(p) != expectedJSON {
+		t.Fatalf("unexpected json: %q != %q", string(p), expectedJSON)
+	}
+}
@@ -0,0 +1,177 @@
+package v2
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedHost returns the scheme and host requested by the client as
+// reported by the proxies forwarding the request, or empty strings if they
+// are not reported.
+//
+// Each proxy appends an element to the forwarding headers, so the element of
+// the proxy closest to the client is the first one. Only the elements of
+// trusted proxies are used: the peer of the request must be trusted, as well
+// as each proxy from which a trusted proxy received the request, according to
+// the addresses it reported. The element of the outermost trusted proxy is
+// used. A nil trusted function trusts every proxy.
+//
+// The Forwarded header (RFC 7239) is used if present; otherwise, the
+// X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host headers are used.
+func forwardedHost(r *http.Request, trusted func(net.IP) bool) (scheme, host string) {
+	if forwarded := r.Header["Forwarded"]; len(forwarded) > 0 {
+		elements := parseForwarded(strings.Join(forwarded, ","))
+
+		clients := make([]string, len(elements))
+		for i, element := range elements {
+			clients[i] = element["for"]
+		}
+
+		first := len(elements) - trustedHops(r, clients, trusted)
+		if first < 0 {
+			first = 0
+		}
+
+		// Proxies may omit parameters, which are then taken from the next
+		// trusted proxy.
+		for _, element := range elements[first:] {
+			if scheme == "" {
+				scheme = element["proto"]
+			}
+			if host == "" {
+				host = element["host"]
+			}
+		}
+
+		return scheme, host
+	}
+
+	hops := trustedHops(r, splitHeader(r.Header.Get("X-Forwarded-For")), trusted)
+
+	// Proxies either append to the scheme and host headers or replace
+	// them, so the value of the outermost trusted proxy is counted from the
+	// end.
+	outermost := func(values []string) string {
+		if len(values) == 0 || hops == 0 {
+			return ""
+		}
+
+		i := len(values) - hops
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+
+	return outermost(splitHeader(r.Header.Get("X-Forwarded-Proto"))),
+		outermost(splitHeader(r.Header.Get("X-Forwarded-Host")))
+}
+
+// ClientIP returns the address of the client of the request: the address
+// reported by the outermost of the consecutive trusted proxies forwarding the
+// request, or the peer address if it is not a trusted proxy. It returns nil if
+// the address is unknown or obfuscated.
+//
+// Unlike for the forwarded scheme and host, a nil trusted function trusts no
+// proxy, since the address may be used for access control.
+func ClientIP(r *http.Request, trusted func(net.IP) bool) net.IP {
+	if trusted == nil {
+		return parseNodeIP(r.RemoteAddr)
+	}
+
+	var clients []string
+	if forwarded := r.Header["Forwarded"]; len(forwarded) > 0 {
+		for _, element := range parseForwarded(strings.Join(forwarded, ",")) {
+			clients = append(clients, element["for"])
+		}
+	} else {
+		clients = splitHeader(r.Header.Get("X-Forwarded-For"))
+	}
+
+	hops := trustedHops(r, clients, trusted)
+	if hops == 0 || len(clients) == 0 {
+		return parseNodeIP(r.RemoteAddr)
+	}
+
+	// The client of the outermost trusted proxy is reported by it, even if
+	// the client is itself trusted.
+	i := len(clients) - hops
+	if i < 0 {
+		i = 0
+	}
+	return parseNodeIP(clients[i])
+}
+
+// trustedHops returns the number of consecutive trusted proxies which
+// forwarded the request, starting from the peer, given the client addresses
+// reported by the proxies in order.
+func trustedHops(r *http.Request, clients []string, trusted func(net.IP) bool) int {
+	if trusted == nil {
+		// Every proxy is trusted, including those which did not report
+		// the address of their client.
+		return math.MaxInt32
+	}
+
+	if ip := parseNodeIP(r.RemoteAddr); ip == nil || !trusted(ip) {
+		return 0
+	}
+
+	hops := 1
+	for i := len(clients) - 1; i >= 0; i-- {
+		ip := parseNodeIP(clients[i])
+		if ip == nil || !trusted(ip) {
+			break
+		}
+		hops++
+	}
+
+	return hops
+}
+
+// parseForwarded parses the elements of a Forwarded header, mapping the
+// lowercased parameter names to their unquoted values.
+func parseForwarded(header string) []map[string]string {
+	var elements []map[string]string
+	for _, element := range splitHeader(header) {
+		params := make(map[string]string)
+		for _, pair := range strings.Split(element, ";") {
+			i := strings.IndexByte(pair, '=')
+			if i < 0 {
+				continue
+			}
+
+			name := strings.ToLower(strings.TrimSpace(pair[:i]))
+			params[name] = strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+		}
+
+		elements = append(elements, params)
+	}
+
+	return elements
+}
+
+// parseNodeIP parses the address of a node, with an optional port and IPv6
+// addresses optionally in brackets. It returns nil for obfuscated or unknown
+// nodes.
+func parseNodeIP(node string) net.IP {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+
+	return net.ParseIP(strings.Trim(node, "[]"))
+}
+
+// splitHeader splits a comma separated header value into its trimmed,
+// non-empty elements.
+func splitHeader(header string) []string {
+	var values []string
+	for _, value := range strings.Split(header, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
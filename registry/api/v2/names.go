@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
 )
 
 // TODO(stevvooe): Move these definitions back to an exported package. While
@@ -59,6 +62,12 @@ var (
 	// ErrRepositoryNameComponentInvalid is returned when a repository name does
 	// not match RepositoryNameComponentRegexp
 	ErrRepositoryNameComponentInvalid = fmt.Errorf("repository name component must match %q", RepositoryNameComponentRegexp.String())
+
+	// ErrRepositoryNameComponentReserved is returned when a repository name
+	// component could be confused with the directories the registry
+	// reserves in repositories, such as _manifests, or with the escaping of
+	// slashes as double underscores in the repository index.
+	ErrRepositoryNameComponentReserved = fmt.Errorf("repository name component must not start or end with an underscore, nor contain consecutive underscores")
 )
 
 // ValidateRespositoryName ensures the repository name is valid for use in the
@@ -76,8 +85,79 @@ var (
 // The result of the production, known as the "namespace", should be limited
 // to 255 characters.
 func ValidateRespositoryName(name string) error {
-	if len(name) > RepositoryNameTotalLengthMax {
-		return ErrRepositoryNameLong
+	return DefaultNameRules.Validate(name)
+}
+
+// NameRules are the rules repository names follow. Registries may relax or
+// restrict the rules of the specification, for instance to allow uppercase
+// or longer names.
+type NameRules struct {
+	componentMinLength int
+	totalLengthMax     int
+
+	// componentRegexp matches a whole name component, and nameRegexp
+	// matches names in routes.
+	componentRegexp *regexp.Regexp
+	nameRegexp      *regexp.Regexp
+
+	errComponentShort   error
+	errLong             error
+	errComponentInvalid error
+
+	routerOnce sync.Once
+	router     *mux.Router
+}
+
+// DefaultNameRules are the rules of the specification, described by
+// ValidateRespositoryName.
+var DefaultNameRules = &NameRules{
+	componentMinLength:  RepositoryNameComponentMinLength,
+	totalLengthMax:      RepositoryNameTotalLengthMax,
+	componentRegexp:     RepositoryNameComponentAnchoredRegexp,
+	nameRegexp:          RepositoryNameRegexp,
+	errComponentShort:   ErrRepositoryNameComponentShort,
+	errLong:             ErrRepositoryNameLong,
+	errComponentInvalid: ErrRepositoryNameComponentInvalid,
+}
+
+// NewNameRules returns rules requiring each slash-delimited component of a
+// name to match componentPattern and to be at least componentMinLength
+// characters long, and names to be at most totalLengthMax characters long.
+// The pattern must not match slashes, and must use non-capturing groups,
+// since it is embedded in the routes of the API. Whatever the pattern,
+// components starting or ending with an underscore, or containing consecutive
+// underscores, are rejected, since they would collide with the storage
+// layout.
+func NewNameRules(componentPattern string, componentMinLength, totalLengthMax int) (*NameRules, error) {
+	componentRegexp, err := regexp.Compile(`^(?:` + componentPattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository name component pattern %q: %v", componentPattern, err)
+	}
+
+	if componentRegexp.NumSubexp() > 0 {
+		return nil, fmt.Errorf("repository name component pattern %q must not have capturing groups", componentPattern)
+	}
+
+	if componentMinLength < 1 || totalLengthMax < componentMinLength {
+		return nil, fmt.Errorf("invalid repository name lengths: component minimum %d, total maximum %d", componentMinLength, totalLengthMax)
+	}
+
+	return &NameRules{
+		componentMinLength:  componentMinLength,
+		totalLengthMax:      totalLengthMax,
+		componentRegexp:     componentRegexp,
+		nameRegexp:          regexp.MustCompile(`(?:(?:` + componentPattern + `)/)*(?:` + componentPattern + `)`),
+		errComponentShort:   fmt.Errorf("respository name component must be %v or more characters", componentMinLength),
+		errLong:             fmt.Errorf("repository name must not be more than %v characters", totalLengthMax),
+		errComponentInvalid: fmt.Errorf("repository name component must match %q", componentPattern),
+	}, nil
+}
+
+// Validate ensures that the repository name follows the rules, returning an
+// error describing the broken rule if it does not.
+func (rules *NameRules) Validate(name string) error {
+	if len(name) > rules.totalLengthMax {
+		return rules.errLong
 	}
 
 	components := strings.Split(name, "/")
@@ -87,14 +167,34 @@ func ValidateRespositoryName(name string) error {
 	}
 
 	for _, component := range components {
-		if len(component) < RepositoryNameComponentMinLength {
-			return ErrRepositoryNameComponentShort
+		if len(component) < rules.componentMinLength {
+			return rules.errComponentShort
 		}
 
-		if !RepositoryNameComponentAnchoredRegexp.MatchString(component) {
-			return ErrRepositoryNameComponentInvalid
+		if !rules.componentRegexp.MatchString(component) {
+			return rules.errComponentInvalid
+		}
+
+		if strings.HasPrefix(component, "_") || strings.HasSuffix(component, "_") || strings.Contains(component, "__") {
+			return ErrRepositoryNameComponentReserved
 		}
 	}
 
 	return nil
 }
+
+// Regexp returns the regular expression matching names in the routes of the
+// API, which may still be too short or long.
+func (rules *NameRules) Regexp() *regexp.Regexp {
+	return rules.nameRegexp
+}
+
+// urlRouter returns the router used to build urls for the names allowed by
+// the rules. It is shared, since building urls does not modify it.
+func (rules *NameRules) urlRouter() *mux.Router {
+	rules.routerOnce.Do(func() {
+		rules.router = RouterWithNameRules("", rules)
+	})
+
+	return rules.router
+}
@@ -1,8 +1,11 @@
 package v2
 
 import (
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/gorilla/mux"
 )
 
 func TestRepositoryNameRegexp(t *testing.T) {
@@ -98,3 +101,88 @@ func TestRepositoryNameRegexp(t *testing.T) {
 		}
 	}
 }
+
+func TestNameRules(t *testing.T) {
+	rules, err := NewNameRules(`[a-zA-Z0-9]+(?:[._-][a-zA-Z0-9]+)*`, 1, 300)
+	if err != nil {
+		t.Fatalf("unexpected error creating name rules: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		input string
+		valid bool
+	}{
+		{input: "library/ubuntu", valid: true},
+		{input: "Internal/Tooling/App", valid: true},
+		{input: "a/b", valid: true},
+		{input: strings.Repeat("a", 300), valid: true},
+		{input: strings.Repeat("a", 301)},
+		{input: "a//b"},
+		{input: "asdf$$^/aa"},
+	} {
+		if err := rules.Validate(testcase.input); (err == nil) != testcase.valid {
+			t.Errorf("%s: unexpected validation result: %v", testcase.input, err)
+		}
+	}
+
+	// The routes and urls of the API must accept the names allowed by the
+	// rules.
+	router := RouterWithNameRules("", rules)
+	r, err := http.NewRequest("GET", "http://localhost/v2/Internal/Tooling/manifests/latest", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.Route.GetName() != RouteNameManifest {
+		t.Fatalf("expected a manifest route match")
+	}
+
+	if match.Vars["name"] != "Internal/Tooling" {
+		t.Fatalf("unexpected name matched: %q", match.Vars["name"])
+	}
+
+	ub, err := NewURLBuilderFromString("http://localhost/")
+	if err != nil {
+		t.Fatalf("unexpected error creating url builder: %v", err)
+	}
+
+	u, err := ub.WithNameRules(rules).BuildManifestURL("Internal/Tooling", "latest")
+	if err != nil {
+		t.Fatalf("unexpected error building manifest url: %v", err)
+	}
+
+	if u != "http://localhost/v2/Internal/Tooling/manifests/latest" {
+		t.Fatalf("unexpected manifest url: %q", u)
+	}
+
+	for _, pattern := range []string{`[a-z`, `([a-z]+)`} {
+		if _, err := NewNameRules(pattern, 2, 255); err == nil {
+			t.Errorf("expected error creating name rules with pattern %q", pattern)
+		}
+	}
+}
+
+// TestNameRulesReservedComponents checks that names colliding with the
+// storage layout are rejected whatever the pattern.
+func TestNameRulesReservedComponents(t *testing.T) {
+	rules, err := NewNameRules(`[a-z0-9_]+`, 1, 255)
+	if err != nil {
+		t.Fatalf("unexpected error creating name rules: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		input string
+		valid bool
+	}{
+		{input: "foo_bar/app", valid: true},
+		{input: "foo/_manifests"},
+		{input: "_trash/app"},
+		{input: "foo__bar/app"},
+		{input: "foo_/bar"},
+	} {
+		if err := rules.Validate(testcase.input); (err == nil) != testcase.valid {
+			t.Errorf("%s: unexpected validation result: %v", testcase.input, err)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package v2
 
-import "github.com/gorilla/mux"
+import (
+	"strings"
+
+	"github.com/gorilla/mux"
+)
 
 // The following are definitions of the name under which all V2 routes are
 // registered. These symbols can be used to look up a route based on the name.
 const (
 	RouteNameBase            = "base"
+	RouteNameInfo            = "info"
+	RouteNameNamespaces      = "namespaces"
 	RouteNameManifest        = "manifest"
 	RouteNameTags            = "tags"
 	RouteNameBlob            = "blob"
@@ -31,6 +37,15 @@ func Router() *mux.Router {
 // RouterWithPrefix builds a gorilla router with a configured prefix
 // on all routes.
 func RouterWithPrefix(prefix string) *mux.Router {
+	return RouterWithNameRules(prefix, DefaultNameRules)
+}
+
+// RouterWithNameRules builds a gorilla router with a configured prefix on all
+// routes, matching the repository names allowed by rules.
+func RouterWithNameRules(prefix string, rules *NameRules) *mux.Router {
+	namePattern := "{name:" + RepositoryNameRegexp.String() + "}"
+	rulesPattern := "{name:" + rules.Regexp().String() + "}"
+
 	rootRouter := mux.NewRouter()
 	router := rootRouter
 	if prefix != "" {
@@ -40,7 +55,8 @@ func RouterWithPrefix(prefix string) *mux.Router {
 	router.StrictSlash(true)
 
 	for _, descriptor := range routeDescriptors {
-		router.Path(descriptor.Path).Name(descriptor.Name)
+		path := strings.Replace(descriptor.Path, namePattern, rulesPattern, 1)
+		router.Path(path).Name(descriptor.Name)
 	}
 
 	return rootRouter
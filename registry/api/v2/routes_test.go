@@ -35,6 +35,16 @@ func TestRouter(t *testing.T) {
 			RequestURI: "/v2/",
 			Vars:       map[string]string{},
 		},
+		{
+			RouteName:  RouteNameInfo,
+			RequestURI: "/v2/_info",
+			Vars:       map[string]string{},
+		},
+		{
+			RouteName:  RouteNameNamespaces,
+			RequestURI: "/v2/_namespaces",
+			Vars:       map[string]string{},
+		},
 		{
 			RouteName:  RouteNameManifest,
 			RequestURI: "/v2/foo/manifests/bar",
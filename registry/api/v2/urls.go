@@ -1,6 +1,7 @@
 package v2
 
 import (
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -42,15 +43,23 @@ func NewURLBuilderFromString(root string) (*URLBuilder, error) {
 }
 
 // NewURLBuilderFromRequest uses information from an *http.Request to
-// construct the root url.
+// construct the root url. The forwarding headers of the request are trusted.
 func NewURLBuilderFromRequest(r *http.Request) *URLBuilder {
+	return NewURLBuilderFromTrustedRequest(r, nil)
+}
+
+// NewURLBuilderFromTrustedRequest uses information from an *http.Request to
+// construct the root url, honoring the scheme and host reported by the
+// forwarding headers of the proxies for which trusted returns true. All
+// proxies are trusted if trusted is nil.
+func NewURLBuilderFromTrustedRequest(r *http.Request, trusted func(net.IP) bool) *URLBuilder {
 	var scheme string
 
-	forwardedProto := r.Header.Get("X-Forwarded-Proto")
+	forwardedScheme, forwardedHost := forwardedHost(r, trusted)
 
 	switch {
-	case len(forwardedProto) > 0:
-		scheme = forwardedProto
+	case len(forwardedScheme) > 0:
+		scheme = forwardedScheme
 	case r.TLS != nil:
 		scheme = "https"
 	case len(r.URL.Scheme) > 0:
@@ -60,14 +69,8 @@ func NewURLBuilderFromRequest(r *http.Request) *URLBuilder {
 	}
 
 	host := r.Host
-	forwardedHost := r.Header.Get("X-Forwarded-Host")
 	if len(forwardedHost) > 0 {
-		// According to the Apache mod_proxy docs, X-Forwarded-Host can be a
-		// comma-separated list of hosts, to which each proxy appends the
-		// requested host. We want to grab the first from this comma-separated
-		// list.
-		hosts := strings.SplitN(forwardedHost, ",", 2)
-		host = strings.TrimSpace(hosts[0])
+		host = forwardedHost
 	}
 
 	basePath := routeDescriptorsMap[RouteNameBase].Path
@@ -88,6 +91,31 @@ func NewURLBuilderFromRequest(r *http.Request) *URLBuilder {
 	return NewURLBuilder(u)
 }
 
+// WithPathPrefix returns a copy of the builder building urls under the path
+// prefix the registry is served at, instead of the root path of the request.
+// The prefix is taken as a directory, whether or not it has a trailing slash.
+func (ub *URLBuilder) WithPathPrefix(prefix string) *URLBuilder {
+	root := *ub.root
+	root.Path = "/" + strings.Trim(prefix, "/") + "/"
+	if root.Path == "//" {
+		root.Path = "/"
+	}
+
+	return &URLBuilder{
+		root:   &root,
+		router: ub.router,
+	}
+}
+
+// WithNameRules returns a copy of the builder building urls for the
+// repository names allowed by rules.
+func (ub *URLBuilder) WithNameRules(rules *NameRules) *URLBuilder {
+	return &URLBuilder{
+		root:   ub.root,
+		router: rules.urlRouter(),
+	}
+}
+
 // BuildBaseURL constructs a base url for the API, typically just "/v2/".
 func (ub *URLBuilder) BuildBaseURL() (string, error) {
 	route := ub.cloneRoute(RouteNameBase)
@@ -100,6 +128,31 @@ func (ub *URLBuilder) BuildBaseURL() (string, error) {
 	return baseURL.String(), nil
 }
 
+// BuildInfoURL constructs a url for the registry information endpoint.
+func (ub *URLBuilder) BuildInfoURL() (string, error) {
+	route := ub.cloneRoute(RouteNameInfo)
+
+	infoURL, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return infoURL.String(), nil
+}
+
+// BuildNamespacesURL constructs a url to list the namespaces, with the
+// optional parent namespace in values.
+func (ub *URLBuilder) BuildNamespacesURL(values ...url.Values) (string, error) {
+	route := ub.cloneRoute(RouteNameNamespaces)
+
+	namespacesURL, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(namespacesURL, values...).String(), nil
+}
+
 // BuildTagsURL constructs a url to list the tags in the named repository.
 func (ub *URLBuilder) BuildTagsURL(name string) (string, error) {
 	route := ub.cloneRoute(RouteNameTags)
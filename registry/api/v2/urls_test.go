@@ -1,6 +1,7 @@
 package v2
 
 import (
+	"net"
 	"net/http"
 	"net/url"
 	"testing"
@@ -19,6 +20,25 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 			expectedPath: "/v2/",
 			build:        urlBuilder.BuildBaseURL,
 		},
+		{
+			description:  "test info url",
+			expectedPath: "/v2/_info",
+			build:        urlBuilder.BuildInfoURL,
+		},
+		{
+			description:  "test namespaces url",
+			expectedPath: "/v2/_namespaces",
+			build: func() (string, error) {
+				return urlBuilder.BuildNamespacesURL()
+			},
+		},
+		{
+			description:  "test namespaces url with parent",
+			expectedPath: "/v2/_namespaces?parent=foo%2Fbar",
+			build: func() (string, error) {
+				return urlBuilder.BuildNamespacesURL(url.Values{"parent": []string{"foo/bar"}})
+			},
+		},
 		{
 			description:  "test tags url",
 			expectedPath: "/v2/foo/bar/tags/list",
@@ -237,3 +257,242 @@ func TestBuilderFromRequestWithPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestBuilderWithPathPrefix(t *testing.T) {
+	for _, testcase := range []struct {
+		request string
+		prefix  string
+		base    string
+	}{
+		{
+			request: "http://example.com/v2/",
+			prefix:  "",
+			base:    "http://example.com/",
+		},
+		{
+			request: "http://example.com/prefix/v2/",
+			prefix:  "/prefix/",
+			base:    "http://example.com/prefix/",
+		},
+		{
+			request: "http://example.com/prefix/v2/",
+			prefix:  "prefix",
+			base:    "http://example.com/prefix/",
+		},
+		{
+			// The prefix may itself contain the api version.
+			request: "http://example.com/registry/v2/v2/foo/bar/tags/list",
+			prefix:  "/registry/v2",
+			base:    "http://example.com/registry/v2/",
+		},
+	} {
+		u, err := url.Parse(testcase.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		builder := NewURLBuilderFromRequest(&http.Request{URL: u, Host: u.Host}).WithPathPrefix(testcase.prefix)
+
+		for _, testCase := range makeURLBuilderTestCases(builder) {
+			url, err := testCase.build()
+			if err != nil {
+				t.Fatalf("%s: error building url: %v", testCase.description, err)
+			}
+
+			expectedURL := testcase.base[0:len(testcase.base)-1] + testCase.expectedPath
+
+			if url != expectedURL {
+				t.Fatalf("%s: %q != %q", testCase.description, url, expectedURL)
+			}
+		}
+	}
+}
+
+func TestBuilderFromTrustedRequest(t *testing.T) {
+	u, err := url.Parse("http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := proxies.Contains
+
+	testRequests := []struct {
+		description string
+		remoteAddr  string
+		headers     map[string]string
+		base        string
+	}{
+		{
+			description: "untrusted peer",
+			remoteAddr:  "192.0.2.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example.com",
+			},
+			base: "http://example.com",
+		},
+		{
+			description: "trusted peer",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "192.0.2.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "registry.example.com",
+			},
+			base: "https://registry.example.com",
+		},
+		{
+			description: "trusted hops",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "192.0.2.1, 10.0.0.2",
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-Host":  "registry.example.com, lb.internal",
+			},
+			base: "https://registry.example.com",
+		},
+		{
+			description: "untrusted hop",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-For":   "10.0.0.3, 192.0.2.1",
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-Host":  "evil.example.com, registry.example.com",
+			},
+			base: "http://registry.example.com",
+		},
+		{
+			description: "forwarded header",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":        `for=192.0.2.1;proto=https;host="registry.example.com", for="[2001:db8::1]:4711";host=lb.internal`,
+				"X-Forwarded-Host": "ignored.example.com",
+			},
+			base: "http://lb.internal",
+		},
+		{
+			description: "forwarded header through trusted hops",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded": `for=192.0.2.1;proto=https;host="registry.example.com", for=10.0.0.2;host=lb.internal`,
+			},
+			base: "https://registry.example.com",
+		},
+	}
+
+	for _, tr := range testRequests {
+		request := &http.Request{URL: u, Host: u.Host, RemoteAddr: tr.remoteAddr, Header: make(http.Header)}
+		for name, value := range tr.headers {
+			request.Header.Set(name, value)
+		}
+
+		builder := NewURLBuilderFromTrustedRequest(request, trusted)
+
+		for _, testCase := range makeURLBuilderTestCases(builder) {
+			url, err := testCase.build()
+			if err != nil {
+				t.Fatalf("%s: %s: error building url: %v", tr.description, testCase.description, err)
+			}
+
+			expectedURL := tr.base + testCase.expectedPath
+
+			if url != expectedURL {
+				t.Fatalf("%s: %s: %q != %q", tr.description, testCase.description, url, expectedURL)
+			}
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := proxies.Contains
+
+	for _, testCase := range []struct {
+		description string
+		remoteAddr  string
+		headers     map[string]string
+		trusted     func(net.IP) bool
+		client      string
+	}{
+		{
+			description: "untrusted peer",
+			remoteAddr:  "192.0.2.1:1234",
+			headers:     map[string]string{"X-Forwarded-For": "10.0.0.5"},
+			trusted:     trusted,
+			client:      "192.0.2.1",
+		},
+		{
+			description: "no trusted proxies",
+			remoteAddr:  "10.0.0.1:1234",
+			headers:     map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			client:      "10.0.0.1",
+		},
+		{
+			description: "trusted peer without headers",
+			remoteAddr:  "10.0.0.1:1234",
+			trusted:     trusted,
+			client:      "10.0.0.1",
+		},
+		{
+			description: "trusted peer",
+			remoteAddr:  "10.0.0.1:1234",
+			headers:     map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			trusted:     trusted,
+			client:      "192.0.2.1",
+		},
+		{
+			description: "trusted hops",
+			remoteAddr:  "10.0.0.1:1234",
+			headers:     map[string]string{"X-Forwarded-For": "192.0.2.1, 10.0.0.2"},
+			trusted:     trusted,
+			client:      "192.0.2.1",
+		},
+		{
+			description: "spoofed address before untrusted hop",
+			remoteAddr:  "10.0.0.1:1234",
+			headers:     map[string]string{"X-Forwarded-For": "10.0.0.3, 192.0.2.1"},
+			trusted:     trusted,
+			client:      "192.0.2.1",
+		},
+		{
+			description: "forwarded header",
+			remoteAddr:  "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711", for=10.0.0.2`,
+				"X-Forwarded-For": "192.0.2.1",
+			},
+			trusted: trusted,
+			client:  "2001:db8::1",
+		},
+		{
+			description: "obfuscated client",
+			remoteAddr:  "10.0.0.1:1234",
+			headers:     map[string]string{"Forwarded": "for=_hidden"},
+			trusted:     trusted,
+		},
+	} {
+		request := &http.Request{RemoteAddr: testCase.remoteAddr, Header: make(http.Header)}
+		for name, value := range testCase.headers {
+			request.Header.Set(name, value)
+		}
+
+		client := ClientIP(request, testCase.trusted)
+		if testCase.client == "" {
+			if client != nil {
+				t.Fatalf("%s: unexpected client %v", testCase.description, client)
+			}
+			continue
+		}
+
+		if !client.Equal(net.ParseIP(testCase.client)) {
+			t.Fatalf("%s: %v != %s", testCase.description, client, testCase.client)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package anonymous provides an access controller granting a restricted set
+// of actions, pulls by default, to any request. It is intended as the last
+// controller of a chain, to let clients pull without credentials while
+// pushes still require the controllers before it.
+//
+// Requests for no particular access, such as those of the base route, are
+// denied, so that clients checking the registry for authentication are
+// challenged by the other controllers of the chain.
+//
+// With the publiconly option, access is only granted on the repositories the
+// registry reports public, through the repository visibility of the request
+// context.
+package anonymous
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+)
+
+// ErrAccessDenied is returned for requests asking for actions not granted
+// anonymously.
+var ErrAccessDenied = errors.New("access denied")
+
+// accessController grants its actions on any resource without
+// authentication.
+type accessController struct {
+	actions map[string]bool
+
+	// publicOnly restricts the access to repositories to the public ones.
+	publicOnly bool
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{actions: map[string]bool{"pull": true}}
+
+	if actions, present := options["actions"]; present {
+		items, ok := actions.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"actions" must be a list for anonymous access controller`)
+		}
+
+		ac.actions = make(map[string]bool, len(items))
+		for _, item := range items {
+			action, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf(`"actions" must be a list of strings for anonymous access controller, got %v`, item)
+			}
+			ac.actions[action] = true
+		}
+	}
+
+	if publicOnly, present := options["publiconly"]; present {
+		if _, ok := publicOnly.(bool); !ok {
+			return nil, fmt.Errorf(`"publiconly" must be a boolean for anonymous access controller`)
+		}
+		ac.publicOnly = publicOnly.(bool)
+	}
+
+	return ac, nil
+}
+
+// Authorized grants the request if every access it asks for is one of the
+// anonymous actions, on a public repository if publiconly is set. The
+// returned context has no user.
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	if len(accessRecords) == 0 {
+		return nil, ErrAccessDenied
+	}
+
+	for _, access := range accessRecords {
+		if !ac.actions[access.Action] {
+			return nil, ErrAccessDenied
+		}
+
+		if ac.publicOnly {
+			if access.Type != "repository" {
+				return nil, ErrAccessDenied
+			}
+
+			public, err := ac.public(ctx, access.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if !public {
+				return nil, ErrAccessDenied
+			}
+		}
+	}
+
+	return ctx, nil
+}
+
+// public returns true if the named repository is public according to the
+// repository visibility of the context. Without one, no repository is.
+func (ac *accessController) public(ctx context.Context, name string) (bool, error) {
+	visibility := auth.GetRepositoryVisibility(ctx)
+	if visibility == nil {
+		return false, nil
+	}
+
+	public, err := visibility.Public(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("unable to check visibility of repository %s: %v", name, err)
+	}
+
+	return public, nil
+}
+
+// init registers the anonymous auth backend.
+func init() {
+	auth.Register("anonymous", auth.InitFunc(newAccessController))
+}
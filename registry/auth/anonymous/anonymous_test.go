@@ -0,0 +1,72 @@
+package anonymous
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+)
+
+// publicRepositories is a repository visibility with a fixed set of public
+// repositories.
+type publicRepositories map[string]bool
+
+func (pr publicRepositories) Public(ctx context.Context, name string) (bool, error) {
+	return pr[name], nil
+}
+
+func access(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func TestAnonymousAccessController(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := ac.Authorized(ctx, access("foo/bar", "pull")); err != nil {
+		t.Fatalf("unexpected error authorizing anonymous pull: %v", err)
+	}
+
+	for _, accessRecords := range [][]auth.Access{
+		{access("foo/bar", "pull"), access("foo/bar", "push")},
+		nil,
+	} {
+		if _, err := ac.Authorized(ctx, accessRecords...); err != ErrAccessDenied {
+			t.Fatalf("expected %v to be denied: %v", accessRecords, err)
+		}
+	}
+
+	if _, err := newAccessController(map[string]interface{}{"actions": "pull"}); err == nil {
+		t.Fatalf("expected error creating access controller with invalid actions")
+	}
+}
+
+func TestAnonymousAccessControllerPublicOnly(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{"publiconly": true})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	// Without repository visibility, no repository is public.
+	if _, err := ac.Authorized(context.Background(), access("foo/public", "pull")); err != ErrAccessDenied {
+		t.Fatalf("expected pull without visibility to be denied: %v", err)
+	}
+
+	ctx := auth.WithRepositoryVisibility(context.Background(), publicRepositories{"foo/public": true})
+	if _, err := ac.Authorized(ctx, access("foo/public", "pull")); err != nil {
+		t.Fatalf("unexpected error authorizing pull of public repository: %v", err)
+	}
+
+	for _, accessRecords := range [][]auth.Access{
+		{access("foo/private", "pull")},
+		{access("foo/public", "pull"), access("foo/private", "pull")},
+		{{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "pull"}},
+	} {
+		if _, err := ac.Authorized(ctx, accessRecords...); err != ErrAccessDenied {
+			t.Fatalf("expected %v to be denied: %v", accessRecords, err)
+		}
+	}
+}
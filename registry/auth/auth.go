@@ -109,6 +109,27 @@ func (uic userInfoContext) Value(key interface{}) interface{} {
 	return uic.Context.Value(key)
 }
 
+// RepositoryVisibility reports the visibility of the repositories of a
+// registry, for access controllers granting anonymous pulls.
+type RepositoryVisibility interface {
+	// Public returns true if the named repository may be pulled without
+	// authentication.
+	Public(ctx context.Context, name string) (bool, error)
+}
+
+// WithRepositoryVisibility returns a context carrying the repository
+// visibility of the registry, which is passed to access controllers.
+func WithRepositoryVisibility(ctx context.Context, visibility RepositoryVisibility) context.Context {
+	return context.WithValue(ctx, "auth.visibility", visibility)
+}
+
+// GetRepositoryVisibility returns the repository visibility of the context,
+// or nil if the registry does not provide one.
+func GetRepositoryVisibility(ctx context.Context) RepositoryVisibility {
+	visibility, _ := ctx.Value("auth.visibility").(RepositoryVisibility)
+	return visibility
+}
+
 // InitFunc is the type of an AccessController factory function and is used
 // to register the constructor for different AccesController backends.
 type InitFunc func(options map[string]interface{}) (AccessController, error)
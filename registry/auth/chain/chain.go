@@ -0,0 +1,246 @@
+// Package chain provides an access controller combining an ordered chain of
+// access controllers, so that a registry may accept, for instance, client
+// certificates, then tokens, then fall back to anonymous pulls.
+//
+// The chain is configured with the controllers to consult, in order, each
+// with the options of its own type, and with a policy for combining their
+// results:
+//
+//	auth:
+//	  chain:
+//	    policy: first
+//	    controllers:
+//	      - token:
+//	          realm: https://auth.example.com/token
+//	          service: registry.example.com
+//	          issuer: auth.example.com
+//	          rootcertbundle: /path/to/bundle.pem
+//	      - anonymous:
+//	          actions: [pull]
+//
+// With the "first" policy, the default, requests are authorized by the first
+// controller granting them. With the "all" policy, requests must be
+// authorized by every controller.
+package chain
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+)
+
+// Policies for combining the results of the controllers of a chain.
+const (
+	// PolicyFirst authorizes requests granted by any controller, using the
+	// context of the first one granting them.
+	PolicyFirst = "first"
+
+	// PolicyAll authorizes requests granted by every controller, each
+	// consulted with the context returned by the previous one.
+	PolicyAll = "all"
+)
+
+// link is a controller of a chain, with the name of its type for errors.
+type link struct {
+	name       string
+	controller auth.AccessController
+}
+
+// accessController consults its controllers in order, combining their
+// results according to its policy.
+type accessController struct {
+	policy      string
+	controllers []link
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{policy: PolicyFirst}
+
+	if policy, present := options["policy"]; present {
+		switch policy {
+		case PolicyFirst, PolicyAll:
+			ac.policy = policy.(string)
+		default:
+			return nil, fmt.Errorf(`"policy" must be %q or %q for chain access controller, got %v`, PolicyFirst, PolicyAll, policy)
+		}
+	}
+
+	controllers, ok := options["controllers"].([]interface{})
+	if !ok || len(controllers) == 0 {
+		return nil, fmt.Errorf(`"controllers" must list at least one access controller for chain access controller`)
+	}
+
+	for i, item := range controllers {
+		name, controllerOptions, err := parseController(item)
+		if err != nil {
+			return nil, fmt.Errorf("chain access controller %d: %v", i, err)
+		}
+
+		controller, err := auth.GetAccessController(name, controllerOptions)
+		if err != nil {
+			return nil, fmt.Errorf("chain access controller %d (%s): %v", i, name, err)
+		}
+
+		ac.controllers = append(ac.controllers, link{name: name, controller: controller})
+	}
+
+	return ac, nil
+}
+
+// parseController parses an item of the controllers option, which is either
+// the name of an access controller type without options, or a map of its
+// name to its options.
+func parseController(item interface{}) (string, map[string]interface{}, error) {
+	var name interface{}
+	var options interface{}
+
+	switch item := item.(type) {
+	case string:
+		return item, map[string]interface{}{}, nil
+	case map[interface{}]interface{}:
+		if len(item) != 1 {
+			return "", nil, fmt.Errorf("must specify exactly one access controller type, got %d", len(item))
+		}
+		for key, value := range item {
+			name, options = key, value
+		}
+	case map[string]interface{}:
+		if len(item) != 1 {
+			return "", nil, fmt.Errorf("must specify exactly one access controller type, got %d", len(item))
+		}
+		for key, value := range item {
+			name, options = key, value
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid access controller: %v", item)
+	}
+
+	if _, ok := name.(string); !ok {
+		return "", nil, fmt.Errorf("invalid access controller type: %v", name)
+	}
+
+	switch options := options.(type) {
+	case nil:
+		return name.(string), map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return name.(string), options, nil
+	case map[interface{}]interface{}:
+		stringOptions := make(map[string]interface{}, len(options))
+		for key, value := range options {
+			if _, ok := key.(string); !ok {
+				return "", nil, fmt.Errorf("invalid option for %s access controller: %v", name, key)
+			}
+			stringOptions[key.(string)] = value
+		}
+		return name.(string), stringOptions, nil
+	default:
+		return "", nil, fmt.Errorf("invalid options for %s access controller: %v", name, options)
+	}
+}
+
+// Authorized consults the controllers of the chain according to its policy.
+// When a request is denied, the challenges of the controllers which denied
+// it are combined, so that clients may answer any of them. If none of them
+// issued a challenge, the error of the last one is returned.
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	var challenges challenges
+	var lastErr error
+
+	for _, link := range ac.controllers {
+		authCtx, err := link.controller.Authorized(ctx, accessRecords...)
+		if err == nil {
+			if ac.policy == PolicyFirst {
+				return authCtx, nil
+			}
+
+			ctx = authCtx
+			continue
+		}
+
+		if challenge, ok := err.(auth.Challenge); ok {
+			challenges = append(challenges, challenge)
+		} else {
+			lastErr = fmt.Errorf("%s: %v", link.name, err)
+		}
+
+		if ac.policy == PolicyAll {
+			break
+		}
+	}
+
+	if ac.policy == PolicyAll && challenges == nil && lastErr == nil {
+		return ctx, nil
+	}
+
+	if len(challenges) > 0 {
+		return nil, challenges
+	}
+
+	return nil, lastErr
+}
+
+// challenges combines the challenges of the controllers of a chain.
+type challenges []auth.Challenge
+
+// ServeHTTP writes the WWW-Authenticate headers of every challenge, with the
+// status of the first one.
+func (chs challenges) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := 0
+	for _, ch := range chs {
+		recorder := &headerRecorder{header: http.Header{}}
+		ch.ServeHTTP(recorder, r)
+
+		for _, value := range recorder.header["Www-Authenticate"] {
+			w.Header().Add("WWW-Authenticate", value)
+		}
+
+		if status == 0 {
+			status = recorder.status
+		}
+	}
+
+	if status == 0 {
+		status = http.StatusUnauthorized
+	}
+	w.WriteHeader(status)
+}
+
+func (chs challenges) Error() string {
+	if len(chs) == 1 {
+		return chs[0].Error()
+	}
+
+	return fmt.Sprintf("chain authentication challenges: %v", []auth.Challenge(chs))
+}
+
+// headerRecorder records the headers and status a challenge writes.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (hr *headerRecorder) Header() http.Header {
+	return hr.header
+}
+
+func (hr *headerRecorder) Write(p []byte) (int, error) {
+	if hr.status == 0 {
+		hr.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+func (hr *headerRecorder) WriteHeader(status int) {
+	if hr.status == 0 {
+		hr.status = status
+	}
+}
+
+// init registers the chain auth backend.
+func init() {
+	auth.Register("chain", auth.InitFunc(newAccessController))
+}
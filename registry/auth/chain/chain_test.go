@@ -0,0 +1,155 @@
+package chain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/registry/auth"
+	_ "github.com/docker/distribution/registry/auth/anonymous"
+	_ "github.com/docker/distribution/registry/auth/silly"
+	"golang.org/x/net/context"
+)
+
+// sillyOptions returns the options of a silly controller as parsed from
+// yaml.
+func sillyOptions(realm string) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		"silly": map[interface{}]interface{}{
+			"realm":   realm,
+			"service": "test-service",
+		},
+	}
+}
+
+func pull(name string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "pull"}
+}
+
+func push(name string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "push"}
+}
+
+// authorize authorizes a request for access, with the Authorization header if
+// set, and returns the authorized context or the response to the challenge.
+func authorize(t *testing.T, ac auth.AccessController, authorization string, access ...auth.Access) (context.Context, *httptest.ResponseRecorder) {
+	req, err := http.NewRequest("GET", "http://example.com/v2/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	ctx, err := ac.Authorized(context.WithValue(context.Background(), "http.request", req), access...)
+	if err == nil {
+		return ctx, nil
+	}
+
+	challenge, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("unexpected error authorizing request: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	challenge.ServeHTTP(recorder, req)
+	return nil, recorder
+}
+
+func TestChainFirstPolicy(t *testing.T) {
+	ac, err := auth.GetAccessController("chain", map[string]interface{}{
+		"controllers": []interface{}{
+			sillyOptions("first-realm"),
+			sillyOptions("second-realm"),
+			"anonymous",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating chain: %v", err)
+	}
+
+	ctx, _ := authorize(t, ac, "Bearer anything", push("foo/bar"))
+	if ctx == nil {
+		t.Fatalf("expected request with credentials to be authorized")
+	}
+	if userInfo, ok := ctx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "silly" {
+		t.Fatalf("unexpected user of authorized request: %v", ctx.Value("auth.user"))
+	}
+
+	// Pulls fall back to the anonymous controller.
+	ctx, _ = authorize(t, ac, "", pull("foo/bar"))
+	if ctx == nil {
+		t.Fatalf("expected anonymous pull to be authorized")
+	}
+	if ctx.Value("auth.user") != nil {
+		t.Fatalf("unexpected user of anonymous request: %v", ctx.Value("auth.user"))
+	}
+
+	// Pushes and base route requests are challenged by every controller
+	// which issued a challenge.
+	for _, access := range [][]auth.Access{{push("foo/bar")}, nil} {
+		ctx, recorder := authorize(t, ac, "", access...)
+		if ctx != nil {
+			t.Fatalf("expected request for %v to be denied", access)
+		}
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status code: %d != %d", recorder.Code, http.StatusUnauthorized)
+		}
+
+		challenges := recorder.HeaderMap["Www-Authenticate"]
+		if len(challenges) != 2 {
+			t.Fatalf("expected a challenge of each silly controller: %v", challenges)
+		}
+	}
+}
+
+func TestChainAllPolicy(t *testing.T) {
+	ac, err := auth.GetAccessController("chain", map[string]interface{}{
+		"policy": "all",
+		"controllers": []interface{}{
+			sillyOptions("silly-realm"),
+			map[interface{}]interface{}{
+				"anonymous": map[interface{}]interface{}{
+					"actions": []interface{}{"pull"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating chain: %v", err)
+	}
+
+	ctx, _ := authorize(t, ac, "Bearer anything", pull("foo/bar"))
+	if ctx == nil {
+		t.Fatalf("expected request granted by every controller to be authorized")
+	}
+	if userInfo, ok := ctx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "silly" {
+		t.Fatalf("expected the user of the first controller to be kept: %v", ctx.Value("auth.user"))
+	}
+
+	ctx, recorder := authorize(t, ac, "", pull("foo/bar"))
+	if ctx != nil || recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected request without credentials to be challenged")
+	}
+
+	if _, err := ac.Authorized(context.WithValue(context.Background(), "http.request", &http.Request{Header: http.Header{"Authorization": {"Bearer anything"}}}), push("foo/bar")); err == nil {
+		t.Fatalf("expected push denied by the anonymous controller to be denied")
+	}
+}
+
+func TestChainOptions(t *testing.T) {
+	for _, options := range []map[string]interface{}{
+		{},
+		{"controllers": []interface{}{}},
+		{"controllers": []interface{}{"anonymous"}, "policy": "any"},
+		{"controllers": []interface{}{"unknown"}},
+		{"controllers": []interface{}{map[interface{}]interface{}{"silly": nil}}},
+		{"controllers": []interface{}{map[interface{}]interface{}{"anonymous": nil, "silly": nil}}},
+		{"controllers": []interface{}{42}},
+	} {
+		if _, err := auth.GetAccessController("chain", options); err == nil {
+			t.Fatalf("expected error creating chain with options %v", options)
+		}
+	}
+}
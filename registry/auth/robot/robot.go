@@ -0,0 +1,208 @@
+// Package robot provides an access controller for robot accounts: automated
+// clients, such as CI jobs, authenticating with HTTP basic authentication as
+// the name of their account and its static token, for the access granted to
+// the account only. This removes the need for a token server when only such
+// clients require credentials.
+//
+// The accounts are looked up through the Accounts of the request context,
+// which the registry provides from its storage backend. Only hashes of the
+// tokens are stored.
+package robot
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+)
+
+// defaultRealm is the realm of the basic authentication challenges, unless
+// another one is configured.
+const defaultRealm = "registry"
+
+// Account is a robot account as verified by the access controller.
+type Account struct {
+	Name string
+
+	// TokenHash is the hash of the token of the account, as returned by
+	// HashToken.
+	TokenHash string
+
+	Access []Grant
+}
+
+// Grant grants actions on the repositories whose names match the repository
+// pattern, in the syntax of path.Match. The "*" action grants all actions.
+type Grant struct {
+	Repository string
+	Actions    []string
+}
+
+// Accounts looks up robot accounts.
+type Accounts interface {
+	// Account returns the named account, or nil if it does not exist.
+	Account(ctx context.Context, name string) (*Account, error)
+}
+
+// WithAccounts returns a context carrying the robot accounts of the registry,
+// which is passed to access controllers.
+func WithAccounts(ctx context.Context, accounts Accounts) context.Context {
+	return context.WithValue(ctx, "auth.robots", accounts)
+}
+
+// GetAccounts returns the robot accounts of the context, or nil if the
+// registry does not provide them.
+func GetAccounts(ctx context.Context) Accounts {
+	accounts, _ := ctx.Value("auth.robots").(Accounts)
+	return accounts
+}
+
+// GenerateToken returns a new random token for a robot account.
+func GenerateToken() (string, error) {
+	p := make([]byte, 32)
+	if _, err := rand.Read(p); err != nil {
+		return "", fmt.Errorf("unable to read random bytes for robot token: %v", err)
+	}
+
+	return base64.URLEncoding.EncodeToString(p), nil
+}
+
+// HashToken returns the hash of a token, as stored with its account. Tokens
+// are random, so they are hashed without salt.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// accessController authorizes requests authenticated as robot accounts.
+type accessController struct {
+	realm string
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{realm: defaultRealm}
+
+	if realm, present := options["realm"]; present {
+		if _, ok := realm.(string); !ok {
+			return nil, fmt.Errorf(`"realm" must be a string for robot access controller`)
+		}
+		ac.realm = realm.(string)
+	}
+
+	return ac, nil
+}
+
+// Authorized checks the basic authentication credentials of the request
+// against the robot accounts, and that the account is granted the access.
+// Requests for no particular access only require valid credentials, so that
+// clients may log in. The user of the returned context is named after the
+// account, with a "robot:" prefix.
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	req, err := ctxu.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name, token, ok := parseBasicAuth(req.Header.Get("Authorization"))
+	if !ok {
+		return nil, ac.challenge()
+	}
+
+	accounts := GetAccounts(ctx)
+	if accounts == nil {
+		return nil, fmt.Errorf("robot accounts unavailable")
+	}
+
+	account, err := accounts.Account(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get robot account %s: %v", name, err)
+	}
+
+	if account == nil || subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(account.TokenHash)) != 1 {
+		return nil, ac.challenge()
+	}
+
+	for _, access := range accessRecords {
+		if !allows(account, access) {
+			return nil, ac.challenge()
+		}
+	}
+
+	return auth.WithUser(ctx, auth.UserInfo{Name: "robot:" + account.Name}), nil
+}
+
+// allows returns true if the account is granted the access.
+func allows(account *Account, access auth.Access) bool {
+	if access.Type != "repository" {
+		return false
+	}
+
+	for _, grant := range account.Access {
+		if matched, _ := path.Match(grant.Repository, access.Name); !matched {
+			continue
+		}
+
+		for _, action := range grant.Actions {
+			if action == "*" || action == access.Action {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseBasicAuth parses the value of an Authorization header for HTTP basic
+// authentication.
+func parseBasicAuth(header string) (username, password string, ok bool) {
+	if !strings.HasPrefix(header, "Basic ") {
+		return
+	}
+
+	c, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return
+	}
+
+	cs := string(c)
+	s := strings.IndexByte(cs, ':')
+	if s < 0 {
+		return
+	}
+
+	return cs[:s], cs[s+1:], true
+}
+
+func (ac *accessController) challenge() *challenge {
+	return &challenge{realm: ac.realm}
+}
+
+// challenge is a basic authentication challenge.
+type challenge struct {
+	realm string
+}
+
+func (ch *challenge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", ch.realm))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func (ch *challenge) Error() string {
+	return fmt.Sprintf("robot authentication challenge: %#v", ch)
+}
+
+// init registers the robot auth backend.
+func init() {
+	auth.Register("robot", auth.InitFunc(newAccessController))
+}
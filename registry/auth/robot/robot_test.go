@@ -0,0 +1,101 @@
+package robot
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+)
+
+// testAccounts is a fixed set of robot accounts.
+type testAccounts map[string]*Account
+
+func (ta testAccounts) Account(ctx context.Context, name string) (*Account, error) {
+	return ta[name], nil
+}
+
+func access(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func TestRobotAccessController(t *testing.T) {
+	token, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("unexpected error generating token: %v", err)
+	}
+
+	accounts := testAccounts{
+		"ci": {
+			Name:      "ci",
+			TokenHash: HashToken(token),
+			Access: []Grant{
+				{Repository: "team/*", Actions: []string{"pull", "push"}},
+				{Repository: "base", Actions: []string{"pull"}},
+			},
+		},
+	}
+
+	ac, err := newAccessController(map[string]interface{}{"realm": "test-realm"})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	authorize := func(username, password string, accessRecords ...auth.Access) (context.Context, error) {
+		req, err := http.NewRequest("GET", "http://example.com/v2/", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+
+		ctx := WithAccounts(context.WithValue(context.Background(), "http.request", req), accounts)
+		return ac.Authorized(ctx, accessRecords...)
+	}
+
+	for _, accessRecords := range [][]auth.Access{
+		nil,
+		{access("team/app", "pull"), access("team/app", "push")},
+		{access("base", "pull")},
+	} {
+		ctx, err := authorize("ci", token, accessRecords...)
+		if err != nil {
+			t.Fatalf("unexpected error authorizing %v: %v", accessRecords, err)
+		}
+
+		if userInfo, ok := ctx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "robot:ci" {
+			t.Fatalf("unexpected user of authorized request: %v", ctx.Value("auth.user"))
+		}
+	}
+
+	for _, testcase := range []struct {
+		username, password string
+		access             []auth.Access
+	}{
+		{"", "", []auth.Access{access("team/app", "pull")}},
+		{"ci", "wrong", []auth.Access{access("team/app", "pull")}},
+		{"other", token, []auth.Access{access("team/app", "pull")}},
+		{"ci", token, []auth.Access{access("base", "push")}},
+		{"ci", token, []auth.Access{access("other/app", "pull")}},
+		{"ci", token, []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "admin"}, Action: "admin"}}},
+	} {
+		_, err := authorize(testcase.username, testcase.password, testcase.access...)
+		if _, ok := err.(auth.Challenge); !ok {
+			t.Fatalf("expected challenge for %q authorizing %v: %v", testcase.username, testcase.access, err)
+		}
+	}
+
+	// Without robot accounts in the context, requests fail without a
+	// challenge.
+	req, err := http.NewRequest("GET", "http://example.com/v2/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+	req.SetBasicAuth("ci", token)
+	if _, err := ac.Authorized(context.WithValue(context.Background(), "http.request", req)); err == nil {
+		t.Fatalf("expected error without robot accounts")
+	} else if _, ok := err.(auth.Challenge); ok {
+		t.Fatalf("unexpected challenge without robot accounts: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 // Package silly provides a simple authentication scheme that checks for the
 // existence of an Authorization header and issues access if is present and
-// non-empty.
+// non-empty. For integration tests, it can instead authorize canned
+// identities read from a fixture, each with its own access.
 //
 // This package is present as an example implementation of a minimal
 // auth.AccessController and for testing. This is not suitable for any kind of
@@ -23,6 +24,10 @@ import (
 type accessController struct {
 	realm   string
 	service string
+
+	// identities holds the canned identities by credential. If set, only
+	// their credentials are accepted, for the access granted to them.
+	identities map[string]identity
 }
 
 var _ auth.AccessController = &accessController{}
@@ -38,35 +43,72 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		return nil, fmt.Errorf(`"service" must be set for silly access controller`)
 	}
 
-	return &accessController{realm: realm.(string), service: service.(string)}, nil
+	ac := &accessController{realm: realm.(string), service: service.(string)}
+
+	if fixturePath, present := options["identities"]; present {
+		if _, ok := fixturePath.(string); !ok {
+			return nil, fmt.Errorf(`"identities" must be a path for silly access controller`)
+		}
+
+		identities, err := readIdentities(fixturePath.(string))
+		if err != nil {
+			return nil, err
+		}
+		ac.identities = identities
+	}
+
+	return ac, nil
 }
 
 // Authorized simply checks for the existence of the authorization header,
-// responding with a bearer challenge if it doesn't exist.
+// responding with a bearer challenge if it doesn't exist. If identities are
+// configured, the header must carry the credential of an identity granted
+// the access.
 func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
 	req, err := ctxu.GetRequest(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if req.Header.Get("Authorization") == "" {
-		challenge := challenge{
-			realm:   ac.realm,
-			service: ac.service,
-		}
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return nil, ac.challenge(accessRecords)
+	}
 
-		if len(accessRecords) > 0 {
-			var scopes []string
-			for _, access := range accessRecords {
-				scopes = append(scopes, fmt.Sprintf("%s:%s:%s", access.Type, access.Resource.Name, access.Action))
-			}
-			challenge.scope = strings.Join(scopes, " ")
+	if ac.identities == nil {
+		return auth.WithUser(ctx, auth.UserInfo{Name: "silly"}), nil
+	}
+
+	id, ok := ac.identities[credential(header)]
+	if !ok {
+		return nil, ac.challenge(accessRecords)
+	}
+
+	for _, access := range accessRecords {
+		if !id.allows(access) {
+			return nil, ac.challenge(accessRecords)
 		}
+	}
 
-		return nil, &challenge
+	return auth.WithUser(ctx, auth.UserInfo{Name: id.Name}), nil
+}
+
+// challenge returns a challenge for the access records.
+func (ac *accessController) challenge(accessRecords []auth.Access) *challenge {
+	challenge := challenge{
+		realm:   ac.realm,
+		service: ac.service,
+	}
+
+	if len(accessRecords) > 0 {
+		var scopes []string
+		for _, access := range accessRecords {
+			scopes = append(scopes, fmt.Sprintf("%s:%s:%s", access.Type, access.Resource.Name, access.Action))
+		}
+		challenge.scope = strings.Join(scopes, " ")
 	}
 
-	return auth.WithUser(ctx, auth.UserInfo{Name: "silly"}), nil
+	return &challenge
 }
 
 type challenge struct {
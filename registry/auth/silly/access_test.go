@@ -1,8 +1,10 @@
 package silly
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/docker/distribution/registry/auth"
@@ -68,3 +70,90 @@ func TestSillyAccessController(t *testing.T) {
 		t.Fatalf("unexpected response status: %v != %v", resp.StatusCode, http.StatusNoContent)
 	}
 }
+
+func TestSillyIdentities(t *testing.T) {
+	fixture, err := ioutil.TempFile("", "silly-identities")
+	if err != nil {
+		t.Fatalf("unexpected error creating fixture: %v", err)
+	}
+	defer os.Remove(fixture.Name())
+
+	_, err = fixture.WriteString(`
+identities:
+  - credential: alice-secret
+    name: alice
+    access:
+      - type: repository
+        name: alice/*
+        actions: [pull, push]
+      - type: repository
+        name: library/*
+        actions: [pull]
+  - credential: root-secret
+    name: root
+    access:
+      - type: repository
+        name: "*/*"
+        actions: ["*"]
+`)
+	fixture.Close()
+	if err != nil {
+		t.Fatalf("unexpected error writing fixture: %v", err)
+	}
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":      "test-realm",
+		"service":    "test-service",
+		"identities": fixture.Name(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: name},
+			Action:   action,
+		}
+	}
+
+	for _, testcase := range []struct {
+		authorization string
+		access        auth.Access
+		user          string // empty if unauthorized
+	}{
+		{"", access("alice/app", "pull"), ""},
+		{"Bearer unknown", access("alice/app", "pull"), ""},
+		{"Bearer alice-secret", access("alice/app", "push"), "alice"},
+		{"Bearer alice-secret", access("alice/app", "delete"), ""},
+		{"Bearer alice-secret", access("library/ubuntu", "pull"), "alice"},
+		{"Bearer alice-secret", access("library/ubuntu", "push"), ""},
+		{"Basic root-secret", access("library/ubuntu", "delete"), "root"},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		if testcase.authorization != "" {
+			req.Header.Set("Authorization", testcase.authorization)
+		}
+
+		authCtx, err := ac.Authorized(context.WithValue(nil, "http.request", req), testcase.access)
+		if testcase.user == "" {
+			if _, ok := err.(auth.Challenge); !ok {
+				t.Errorf("expected challenge for %q with %v, got %v", testcase.authorization, testcase.access, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error authorizing %q with %v: %v", testcase.authorization, testcase.access, err)
+			continue
+		}
+
+		userInfo, _ := authCtx.Value("auth.user").(auth.UserInfo)
+		if userInfo.Name != testcase.user {
+			t.Errorf("unexpected user for %q: %q != %q", testcase.authorization, userInfo.Name, testcase.user)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package silly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/registry/auth"
+	"gopkg.in/yaml.v2"
+)
+
+// identityFixture lists the canned identities of the silly access
+// controller, read from the YAML file given by the "identities" option:
+//
+//	identities:
+//	  - credential: alice-secret
+//	    name: alice
+//	    access:
+//	      - type: repository
+//	        name: alice/*
+//	        actions: [pull, push]
+//
+// A request presenting the credential in its Authorization header, after the
+// scheme, is authorized as the named user for the listed access only.
+type identityFixture struct {
+	Identities []identity `yaml:"identities"`
+}
+
+// identity is a canned identity and the access granted to it.
+type identity struct {
+	Credential string           `yaml:"credential"`
+	Name       string           `yaml:"name"`
+	Access     []identityAccess `yaml:"access"`
+}
+
+// identityAccess grants actions on the resources of the given type whose
+// names match the name pattern, in the syntax of path.Match. The "*" action
+// grants all actions.
+type identityAccess struct {
+	Type    string   `yaml:"type"`
+	Name    string   `yaml:"name"`
+	Actions []string `yaml:"actions"`
+}
+
+// readIdentities reads the identity fixture at path, keyed by credential.
+func readIdentities(fixturePath string) (map[string]identity, error) {
+	p, err := ioutil.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read silly identities %q: %v", fixturePath, err)
+	}
+
+	var fixture identityFixture
+	if err := yaml.Unmarshal(p, &fixture); err != nil {
+		return nil, fmt.Errorf("unable to parse silly identities %q: %v", fixturePath, err)
+	}
+
+	identities := make(map[string]identity, len(fixture.Identities))
+	for _, id := range fixture.Identities {
+		if id.Credential == "" || id.Name == "" {
+			return nil, fmt.Errorf("silly identities %q: each identity requires a credential and a name", fixturePath)
+		}
+
+		for _, access := range id.Access {
+			if _, err := path.Match(access.Name, ""); err != nil {
+				return nil, fmt.Errorf("silly identities %q: invalid name pattern %q for %s", fixturePath, access.Name, id.Name)
+			}
+		}
+
+		identities[id.Credential] = id
+	}
+
+	return identities, nil
+}
+
+// credential returns the credential of an Authorization header value, which
+// follows the scheme if there is one.
+func credential(header string) string {
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		return strings.TrimSpace(header[i+1:])
+	}
+
+	return header
+}
+
+// allows returns true if the identity is granted the access.
+func (id identity) allows(access auth.Access) bool {
+	for _, granted := range id.Access {
+		if granted.Type != access.Type {
+			continue
+		}
+
+		if matched, _ := path.Match(granted.Name, access.Name); !matched {
+			continue
+		}
+
+		for _, action := range granted.Actions {
+			if action == "*" || action == access.Action {
+				return true
+			}
+		}
+	}
+
+	return false
+}
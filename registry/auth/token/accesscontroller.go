@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/auth"
@@ -130,6 +131,8 @@ type accessController struct {
 	service     string
 	rootCerts   *x509.CertPool
 	trustedKeys map[string]libtrust.PublicKey
+	leeway      time.Duration
+	maxAge      time.Duration
 }
 
 // tokenAccessOptions is a convenience type for handling
@@ -139,6 +142,8 @@ type tokenAccessOptions struct {
 	issuer         string
 	service        string
 	rootCertBundle string
+	leeway         time.Duration
+	maxAge         time.Duration
 }
 
 // checkOptions gathers the necessary options
@@ -158,9 +163,38 @@ func checkOptions(options map[string]interface{}) (tokenAccessOptions, error) {
 
 	opts.realm, opts.issuer, opts.service, opts.rootCertBundle = vals[0], vals[1], vals[2], vals[3]
 
+	var err error
+	if opts.leeway, err = durationOption(options, "leeway"); err != nil {
+		return opts, err
+	}
+	if opts.maxAge, err = durationOption(options, "maxage"); err != nil {
+		return opts, err
+	}
+
 	return opts, nil
 }
 
+// durationOption parses the optional duration option named key, such as
+// "30s". It returns zero if the option is not set.
+func durationOption(options map[string]interface{}, key string) (time.Duration, error) {
+	val, ok := options[key]
+	if !ok {
+		return 0, nil
+	}
+
+	str, ok := val.(string)
+	if !ok {
+		return 0, fmt.Errorf("token auth requires a valid duration string for option %q", key)
+	}
+
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("token auth requires a valid duration string for option %q: %q", key, str)
+	}
+
+	return d, nil
+}
+
 // newAccessController creates an accessController using the given options.
 func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
 	config, err := checkOptions(options)
@@ -200,7 +234,7 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 	trustedKeys := make(map[string]libtrust.PublicKey, len(rootCerts))
 	for _, rootCert := range rootCerts {
 		rootPool.AddCert(rootCert)
-		pubKey, err := libtrust.FromCryptoPublicKey(crypto.PublicKey(rootCert.PublicKey))
+		pubKey, err := publicKeyFromCrypto(crypto.PublicKey(rootCert.PublicKey))
 		if err != nil {
 			return nil, fmt.Errorf("unable to get public key from token auth root certificate: %s", err)
 		}
@@ -213,6 +247,8 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		service:     config.service,
 		rootCerts:   rootPool,
 		trustedKeys: trustedKeys,
+		leeway:      config.leeway,
+		maxAge:      config.maxAge,
 	}, nil
 }
 
@@ -250,6 +286,8 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		AcceptedAudiences: []string{ac.service},
 		Roots:             ac.rootCerts,
 		TrustedKeys:       ac.trustedKeys,
+		Leeway:            ac.leeway,
+		MaxAge:            ac.maxAge,
 	}
 
 	if err = token.Verify(verifyOpts); err != nil {
@@ -0,0 +1,172 @@
+package token
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/libtrust"
+)
+
+// ed25519SigningAlg is the JWS algorithm of Ed25519 signatures.
+const ed25519SigningAlg = "EdDSA"
+
+// ed25519PublicKey is a libtrust.PublicKey verifying Ed25519 signatures,
+// which libtrust does not support. Its key ID is the libtrust fingerprint of
+// the key, so that it can be referenced by the "kid" token header.
+type ed25519PublicKey struct {
+	ed25519.PublicKey
+	extended map[string]interface{}
+}
+
+var _ libtrust.PublicKey = &ed25519PublicKey{}
+
+func (k *ed25519PublicKey) KeyType() string {
+	return "OKP"
+}
+
+func (k *ed25519PublicKey) KeyID() string {
+	derBytes, err := x509.MarshalPKIXPublicKey(k.PublicKey)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(derBytes)
+	s := strings.TrimRight(base32.StdEncoding.EncodeToString(hash[:30]), "=")
+
+	var groups []string
+	for len(s) > 4 {
+		groups = append(groups, s[:4])
+		s = s[4:]
+	}
+	return strings.Join(append(groups, s), ":")
+}
+
+func (k *ed25519PublicKey) Verify(data io.Reader, alg string, signature []byte) error {
+	if alg != ed25519SigningAlg {
+		return fmt.Errorf("unable to verify signature: Ed25519 public key does not support signature algorithm %q", alg)
+	}
+
+	message, err := ioutil.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("error reading data to verify: %s", err)
+	}
+
+	if !ed25519.Verify(k.PublicKey, message, signature) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+func (k *ed25519PublicKey) CryptoPublicKey() crypto.PublicKey {
+	return k.PublicKey
+}
+
+func (k *ed25519PublicKey) MarshalJSON() ([]byte, error) {
+	jwk := make(map[string]interface{}, len(k.extended)+4)
+	for field, value := range k.extended {
+		jwk[field] = value
+	}
+
+	jwk["kty"] = k.KeyType()
+	jwk["kid"] = k.KeyID()
+	jwk["crv"] = "Ed25519"
+	jwk["x"] = base64.RawURLEncoding.EncodeToString(k.PublicKey)
+
+	return json.Marshal(jwk)
+}
+
+func (k *ed25519PublicKey) PEMBlock() (*pem.Block, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(k.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize Ed25519 PublicKey to DER-encoded PKIX format: %s", err)
+	}
+
+	return &pem.Block{Type: "PUBLIC KEY", Bytes: derBytes}, nil
+}
+
+func (k *ed25519PublicKey) String() string {
+	return fmt.Sprintf("Ed25519 Public Key <%s>", k.KeyID())
+}
+
+func (k *ed25519PublicKey) AddExtendedField(field string, value interface{}) {
+	if k.extended == nil {
+		k.extended = make(map[string]interface{})
+	}
+	k.extended[field] = value
+}
+
+func (k *ed25519PublicKey) GetExtendedField(field string) interface{} {
+	return k.extended[field]
+}
+
+// publicKeyFromCrypto returns a libtrust public key for a public key parsed
+// by crypto/x509, including Ed25519 keys.
+func publicKeyFromCrypto(key crypto.PublicKey) (libtrust.PublicKey, error) {
+	if key, ok := key.(ed25519.PublicKey); ok {
+		return &ed25519PublicKey{PublicKey: key}, nil
+	}
+
+	return libtrust.FromCryptoPublicKey(key)
+}
+
+// unmarshalPublicKeyJWK parses a JSON Web Key, including Ed25519 keys of
+// the "OKP" key type.
+func unmarshalPublicKeyJWK(data []byte) (libtrust.PublicKey, error) {
+	jwk := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&jwk); err != nil {
+		return nil, fmt.Errorf("decoding JWK Public Key JSON data: %s", err)
+	}
+
+	if jwk["kty"] != "OKP" {
+		return libtrust.UnmarshalPublicKeyJWK(data)
+	}
+
+	if jwk["crv"] != "Ed25519" {
+		return nil, fmt.Errorf("JWK OKP Public Key curve %v is not supported", jwk["crv"])
+	}
+
+	x, ok := jwk["x"].(string)
+	if !ok {
+		return nil, errors.New("JWK OKP Public Key has no \"x\" value")
+	}
+
+	keyBytes, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWK OKP Public Key \"x\" value: %s", err)
+	}
+
+	if len(keyBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("JWK Ed25519 Public Key is %d octets long, should be %d", len(keyBytes), ed25519.PublicKeySize)
+	}
+
+	key := &ed25519PublicKey{PublicKey: ed25519.PublicKey(keyBytes)}
+
+	if kid, ok := jwk["kid"]; ok && kid != key.KeyID() {
+		return nil, fmt.Errorf("JWK key ID specified %v does not match the key", kid)
+	}
+
+	for field, value := range jwk {
+		switch field {
+		case "kty", "kid", "crv", "x":
+		default:
+			key.AddExtendedField(field, value)
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,351 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+// Grant types of the OAuth2 requests of token services.
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+)
+
+const (
+	// defaultTokenExpiration is the lifetime of access tokens, unless the
+	// issuer specifies otherwise.
+	defaultTokenExpiration = 5 * time.Minute
+
+	// defaultRefreshTokenExpiration is the lifetime of refresh tokens,
+	// unless the issuer specifies otherwise.
+	defaultRefreshTokenExpiration = 30 * 24 * time.Hour
+)
+
+// Errors returned by token services built with this package.
+var (
+	ErrInvalidTokenRequest  = errors.New("invalid token request")
+	ErrUnsupportedGrantType = errors.New("unsupported grant type")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+)
+
+// Issuer issues the tokens of a token service, for deployments building
+// their own token service with this package. Access tokens are intended for
+// the registry named by the service of the request, and are accepted by
+// registries trusting the signing key of the issuer. Refresh tokens are
+// intended for the issuer itself, so registries reject them, and let clients
+// get new access tokens without their credentials.
+type Issuer struct {
+	// Name is the issuer claim of the tokens, which registries must trust.
+	Name string
+
+	// SigningKey signs the tokens. If it has an x5c extended field, its
+	// certificate chain is included in the tokens.
+	SigningKey libtrust.PrivateKey
+
+	// Expiration is the lifetime of access tokens, five minutes by default.
+	Expiration time.Duration
+
+	// RefreshExpiration is the lifetime of refresh tokens, thirty days by
+	// default.
+	RefreshExpiration time.Duration
+}
+
+// CreateToken returns an access token granting the subject the access to
+// the resources of the registry of the audience.
+func (iss *Issuer) CreateToken(subject, audience string, access []*ResourceActions) (string, error) {
+	expiration := iss.Expiration
+	if expiration <= 0 {
+		expiration = defaultTokenExpiration
+	}
+
+	if access == nil {
+		access = []*ResourceActions{}
+	}
+
+	return iss.sign(subject, audience, expiration, access)
+}
+
+// CreateRefreshToken returns a refresh token identifying the subject to the
+// issuer.
+func (iss *Issuer) CreateRefreshToken(subject string) (string, error) {
+	expiration := iss.RefreshExpiration
+	if expiration <= 0 {
+		expiration = defaultRefreshTokenExpiration
+	}
+
+	return iss.sign(subject, iss.Name, expiration, nil)
+}
+
+// VerifyRefreshToken verifies a refresh token created by the issuer,
+// returning the subject it identifies.
+func (iss *Issuer) VerifyRefreshToken(rawToken string) (string, error) {
+	token, err := NewToken(rawToken)
+	if err != nil {
+		return "", ErrInvalidRefreshToken
+	}
+
+	// Refresh tokens are identified by key ID, so that they are verified
+	// with the signing key of the issuer rather than a certificate chain.
+	publicKey := iss.SigningKey.PublicKey()
+	if token.Header.KeyID != publicKey.KeyID() || len(token.Header.X5c) > 0 || len(token.Header.RawJWK) > 0 {
+		return "", ErrInvalidRefreshToken
+	}
+
+	if err := token.Verify(VerifyOptions{
+		TrustedIssuers:    []string{iss.Name},
+		AcceptedAudiences: []string{iss.Name},
+		TrustedKeys:       map[string]libtrust.PublicKey{publicKey.KeyID(): publicKey},
+	}); err != nil {
+		return "", ErrInvalidRefreshToken
+	}
+
+	return token.Claims.Subject, nil
+}
+
+// Issue returns the response to the token request authenticated as subject,
+// with an access token granting access. A refresh token is included if the
+// request asks for an offline token: the refresh token of the request for
+// refresh token grants, or a new one otherwise.
+func (iss *Issuer) Issue(req *TokenRequest, subject string, access []*ResourceActions) (*TokenResponse, error) {
+	token, err := iss.CreateToken(subject, req.Service, access)
+	if err != nil {
+		return nil, err
+	}
+
+	expiration := iss.Expiration
+	if expiration <= 0 {
+		expiration = defaultTokenExpiration
+	}
+
+	response := &TokenResponse{
+		Token:       token,
+		AccessToken: token,
+		ExpiresIn:   int(expiration / time.Second),
+		IssuedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if req.OfflineToken {
+		if req.GrantType == GrantTypeRefreshToken {
+			response.RefreshToken = req.RefreshToken
+		} else if response.RefreshToken, err = iss.CreateRefreshToken(subject); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// sign returns a token with the claims, signed by the key of the issuer.
+// Access tokens carry the public key, and its certificate chain if any, for
+// registries to verify, while refresh tokens only carry its ID.
+func (iss *Issuer) sign(subject, audience string, expiration time.Duration, access []*ResourceActions) (string, error) {
+	publicKey := iss.SigningKey.PublicKey()
+	header := Header{Type: "JWT"}
+	if access != nil {
+		rawJWK, err := publicKey.MarshalJSON()
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal signing key: %s", err)
+		}
+		header.RawJWK = json.RawMessage(rawJWK)
+	} else {
+		header.KeyID = publicKey.KeyID()
+	}
+
+	jti := make([]byte, 15)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("unable to read random bytes for jwt id: %s", err)
+	}
+
+	now := time.Now()
+	claimSet := ClaimSet{
+		Issuer:     iss.Name,
+		Subject:    subject,
+		Audience:   audience,
+		Expiration: now.Add(expiration).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      base64.URLEncoding.EncodeToString(jti),
+		Access:     access,
+	}
+
+	claimSetJSON, err := json.Marshal(claimSet)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal claim set: %s", err)
+	}
+
+	if header.SigningAlg, err = signingAlgorithm(publicKey); err != nil {
+		return "", err
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal jose header: %s", err)
+	}
+
+	payload := joseBase64UrlEncode(headerJSON) + TokenSeparator + joseBase64UrlEncode(claimSetJSON)
+	signature, _, err := iss.SigningKey.Sign(strings.NewReader(payload), crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign token: %s", err)
+	}
+
+	return payload + TokenSeparator + joseBase64UrlEncode(signature), nil
+}
+
+// signingAlgorithm returns the algorithm libtrust signs with using the key,
+// with SHA-256 for RSA keys and the hash matching the curve for EC keys.
+func signingAlgorithm(key libtrust.PublicKey) (string, error) {
+	switch cryptoKey := key.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch cryptoKey.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported signing key type: %s", key.KeyType())
+}
+
+// TokenRequest is a request for a token, either a GET request of the token
+// authentication specification, or a POST request of its OAuth2 variant.
+type TokenRequest struct {
+	// Service names the registry the token is intended for.
+	Service string
+
+	// ClientID identifies the client making the request.
+	ClientID string
+
+	// Account is the account the client authenticates as, if specified.
+	Account string
+
+	// Scopes lists the access requested to resources.
+	Scopes []*ResourceActions
+
+	// OfflineToken requests a refresh token along with the access token,
+	// with the offline_token parameter of GET requests or the offline
+	// access type of POST requests.
+	OfflineToken bool
+
+	// GrantType is the grant of POST requests, either GrantTypePassword,
+	// with Username and Password, or GrantTypeRefreshToken, with
+	// RefreshToken. It is empty for GET requests, whose credentials are in
+	// their Authorization header.
+	GrantType    string
+	Username     string
+	Password     string
+	RefreshToken string
+}
+
+// TokenResponse is the response of a token service. Token and AccessToken
+// are the same, for clients of either flavor of the specification.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	IssuedAt     string `json:"issued_at,omitempty"`
+}
+
+// ParseTokenRequest parses a request for a token. GET requests take the
+// service, account, client_id, offline_token and repeated scope query
+// parameters. POST requests take a form with the grant_type, service,
+// client_id, access_type and space separated scope parameters, along with
+// username and password or refresh_token, depending on the grant type.
+// Offline tokens require a client_id.
+func ParseTokenRequest(r *http.Request) (*TokenRequest, error) {
+	var req TokenRequest
+	var scopes []string
+
+	switch r.Method {
+	case "GET":
+		query := r.URL.Query()
+		req.Service = query.Get("service")
+		req.ClientID = query.Get("client_id")
+		req.Account = query.Get("account")
+		req.OfflineToken = query.Get("offline_token") == "true"
+		for _, scope := range query["scope"] {
+			scopes = append(scopes, strings.Fields(scope)...)
+		}
+	case "POST":
+		if err := r.ParseForm(); err != nil {
+			return nil, ErrInvalidTokenRequest
+		}
+
+		req.Service = r.PostForm.Get("service")
+		req.ClientID = r.PostForm.Get("client_id")
+		req.OfflineToken = r.PostForm.Get("access_type") == "offline"
+		req.GrantType = r.PostForm.Get("grant_type")
+		scopes = strings.Fields(r.PostForm.Get("scope"))
+
+		switch req.GrantType {
+		case GrantTypePassword:
+			req.Username = r.PostForm.Get("username")
+			req.Password = r.PostForm.Get("password")
+			req.Account = req.Username
+			if req.Username == "" {
+				return nil, ErrInvalidTokenRequest
+			}
+		case GrantTypeRefreshToken:
+			req.RefreshToken = r.PostForm.Get("refresh_token")
+			if req.RefreshToken == "" {
+				return nil, ErrInvalidTokenRequest
+			}
+		default:
+			return nil, ErrUnsupportedGrantType
+		}
+
+		// Clients of the OAuth2 variant must identify themselves.
+		if req.ClientID == "" {
+			return nil, ErrInvalidTokenRequest
+		}
+	default:
+		return nil, ErrInvalidTokenRequest
+	}
+
+	if req.Service == "" || (req.OfflineToken && req.ClientID == "") {
+		return nil, ErrInvalidTokenRequest
+	}
+
+	for _, scope := range scopes {
+		resourceActions, err := ParseScope(scope)
+		if err != nil {
+			return nil, err
+		}
+		req.Scopes = append(req.Scopes, resourceActions)
+	}
+
+	return &req, nil
+}
+
+// ParseScope parses a scope of the form type:name:actions, such as
+// "repository:foo/bar:pull,push". The name may contain colons, as a
+// registry host with a port.
+func ParseScope(scope string) (*ResourceActions, error) {
+	first := strings.Index(scope, ":")
+	last := strings.LastIndex(scope, ":")
+	if first <= 0 || last == first || last == len(scope)-1 {
+		return nil, ErrInvalidTokenRequest
+	}
+
+	return &ResourceActions{
+		Type:    scope[:first],
+		Name:    scope[first+1 : last],
+		Actions: strings.Split(scope[last+1:], ","),
+	}, nil
+}
@@ -0,0 +1,248 @@
+package token
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestIssuer checks that access tokens are accepted by registries trusting
+// the issuer, and that refresh tokens are only accepted by the issuer.
+func TestIssuer(t *testing.T) {
+	rootKeys, err := makeRootKeys(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &Issuer{
+		Name:       "test-issuer",
+		SigningKey: rootKeys[0],
+		Expiration: time.Minute,
+	}
+	access := []*ResourceActions{
+		{
+			Type:    "repository",
+			Name:    "foo/bar",
+			Actions: []string{"pull"},
+		},
+	}
+
+	req := &TokenRequest{
+		Service:      "test-service",
+		ClientID:     "test-client",
+		Scopes:       access,
+		OfflineToken: true,
+	}
+	response, err := issuer.Issue(req, "alice", access)
+	if err != nil {
+		t.Fatalf("unexpected error issuing token: %v", err)
+	}
+
+	if response.Token == "" || response.AccessToken != response.Token || response.RefreshToken == "" {
+		t.Fatalf("unexpected token response: %+v", response)
+	}
+
+	if response.ExpiresIn != 60 {
+		t.Fatalf("unexpected token expiration: %d", response.ExpiresIn)
+	}
+
+	registryOptions := VerifyOptions{
+		TrustedIssuers:    []string{"test-issuer"},
+		AcceptedAudiences: []string{"test-service"},
+		TrustedKeys:       makeTrustedKeyMap(rootKeys[:1]),
+	}
+
+	token, err := NewToken(response.Token)
+	if err != nil {
+		t.Fatalf("unexpected error parsing access token: %v", err)
+	}
+
+	if err := token.Verify(registryOptions); err != nil {
+		t.Fatalf("unexpected error verifying access token: %v", err)
+	}
+
+	if token.Claims.Subject != "alice" || !reflect.DeepEqual(token.Claims.Access, access) {
+		t.Fatalf("unexpected access token claims: %+v", token.Claims)
+	}
+
+	refreshToken, err := NewToken(response.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected error parsing refresh token: %v", err)
+	}
+
+	if err := refreshToken.Verify(registryOptions); err == nil {
+		t.Fatalf("expected registries to reject refresh tokens")
+	}
+
+	subject, err := issuer.VerifyRefreshToken(response.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected error verifying refresh token: %v", err)
+	}
+
+	if subject != "alice" {
+		t.Fatalf("unexpected refresh token subject: %q", subject)
+	}
+
+	if _, err := issuer.VerifyRefreshToken(response.Token); err != ErrInvalidRefreshToken {
+		t.Fatalf("expected access tokens to be rejected as refresh tokens: %v", err)
+	}
+
+	otherIssuer := &Issuer{Name: "test-issuer", SigningKey: rootKeys[1]}
+	if _, err := otherIssuer.VerifyRefreshToken(response.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Fatalf("expected refresh tokens of another key to be rejected: %v", err)
+	}
+
+	// Refresh token grants return the refresh token they were made with.
+	req.GrantType = GrantTypeRefreshToken
+	req.RefreshToken = response.RefreshToken
+	refreshed, err := issuer.Issue(req, subject, access)
+	if err != nil {
+		t.Fatalf("unexpected error issuing refreshed token: %v", err)
+	}
+
+	if refreshed.RefreshToken != response.RefreshToken {
+		t.Fatalf("expected the refresh token to be returned as is")
+	}
+
+	req.OfflineToken = false
+	online, err := issuer.Issue(req, subject, access)
+	if err != nil {
+		t.Fatalf("unexpected error issuing online token: %v", err)
+	}
+
+	if online.RefreshToken != "" {
+		t.Fatalf("unexpected refresh token without offline access")
+	}
+}
+
+// TestParseTokenRequest parses GET and OAuth2 POST token requests.
+func TestParseTokenRequest(t *testing.T) {
+	pull := &ResourceActions{Type: "repository", Name: "foo/bar", Actions: []string{"pull"}}
+	push := &ResourceActions{Type: "repository", Name: "localhost:5000/foo", Actions: []string{"pull", "push"}}
+
+	for _, testcase := range []struct {
+		method   string
+		query    string
+		form     url.Values
+		expected *TokenRequest
+		err      error
+	}{
+		{
+			method: "GET",
+			query:  "service=registry&account=alice&scope=repository:foo/bar:pull&scope=repository:localhost:5000/foo:pull,push",
+			expected: &TokenRequest{
+				Service: "registry",
+				Account: "alice",
+				Scopes:  []*ResourceActions{pull, push},
+			},
+		},
+		{
+			method: "GET",
+			query:  "service=registry&client_id=cli&offline_token=true",
+			expected: &TokenRequest{
+				Service:      "registry",
+				ClientID:     "cli",
+				OfflineToken: true,
+			},
+		},
+		{
+			method: "GET",
+			query:  "service=registry&offline_token=true",
+			err:    ErrInvalidTokenRequest,
+		},
+		{
+			method: "GET",
+			query:  "scope=repository:foo/bar:pull",
+			err:    ErrInvalidTokenRequest,
+		},
+		{
+			method: "GET",
+			query:  "service=registry&scope=repository:foo/bar",
+			err:    ErrInvalidTokenRequest,
+		},
+		{
+			method: "POST",
+			form: url.Values{
+				"grant_type":  {"password"},
+				"service":     {"registry"},
+				"client_id":   {"cli"},
+				"access_type": {"offline"},
+				"username":    {"alice"},
+				"password":    {"secret"},
+				"scope":       {"repository:foo/bar:pull repository:localhost:5000/foo:pull,push"},
+			},
+			expected: &TokenRequest{
+				Service:      "registry",
+				ClientID:     "cli",
+				Account:      "alice",
+				Scopes:       []*ResourceActions{pull, push},
+				OfflineToken: true,
+				GrantType:    GrantTypePassword,
+				Username:     "alice",
+				Password:     "secret",
+			},
+		},
+		{
+			method: "POST",
+			form: url.Values{
+				"grant_type":    {"refresh_token"},
+				"service":       {"registry"},
+				"client_id":     {"cli"},
+				"refresh_token": {"token"},
+			},
+			expected: &TokenRequest{
+				Service:      "registry",
+				ClientID:     "cli",
+				GrantType:    GrantTypeRefreshToken,
+				RefreshToken: "token",
+			},
+		},
+		{
+			method: "POST",
+			form: url.Values{
+				"grant_type": {"refresh_token"},
+				"service":    {"registry"},
+				"client_id":  {"cli"},
+			},
+			err: ErrInvalidTokenRequest,
+		},
+		{
+			method: "POST",
+			form: url.Values{
+				"grant_type": {"password"},
+				"service":    {"registry"},
+				"username":   {"alice"},
+			},
+			err: ErrInvalidTokenRequest,
+		},
+		{
+			method: "POST",
+			form: url.Values{
+				"grant_type": {"client_credentials"},
+				"service":    {"registry"},
+				"client_id":  {"cli"},
+			},
+			err: ErrUnsupportedGrantType,
+		},
+	} {
+		r, err := http.NewRequest(testcase.method, "http://auth.example.com/token?"+testcase.query, strings.NewReader(testcase.form.Encode()))
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		if testcase.method == "POST" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		req, err := ParseTokenRequest(r)
+		if err != testcase.err {
+			t.Fatalf("unexpected error parsing %s %s%v: %v != %v", testcase.method, testcase.query, testcase.form, err, testcase.err)
+		}
+
+		if !reflect.DeepEqual(req, testcase.expected) {
+			t.Fatalf("unexpected token request for %s %s%v: %+v != %+v", testcase.method, testcase.query, testcase.form, req, testcase.expected)
+		}
+	}
+}
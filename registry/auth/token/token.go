@@ -74,6 +74,14 @@ type VerifyOptions struct {
 	AcceptedAudiences []string
 	Roots             *x509.CertPool
 	TrustedKeys       map[string]libtrust.PublicKey
+
+	// Leeway is the clock skew tolerated when checking the validity period
+	// of the token.
+	Leeway time.Duration
+
+	// MaxAge, if set, rejects tokens issued longer ago, regardless of their
+	// expiration. Tokens must then have an issued at claim.
+	MaxAge time.Duration
 }
 
 // NewToken parses the given raw token string
@@ -142,13 +150,24 @@ func (t *Token) Verify(verifyOpts VerifyOptions) error {
 		return ErrInvalidToken
 	}
 
-	// Verify that the token is currently usable and not expired.
+	// Verify that the token is currently usable and not expired, allowing
+	// for clock skew.
 	currentUnixTime := time.Now().Unix()
-	if !(t.Claims.NotBefore <= currentUnixTime && currentUnixTime <= t.Claims.Expiration) {
+	leeway := int64(verifyOpts.Leeway / time.Second)
+	if !(t.Claims.NotBefore-leeway <= currentUnixTime && currentUnixTime <= t.Claims.Expiration+leeway) {
 		log.Errorf("token not to be used before %d or after %d - currently %d", t.Claims.NotBefore, t.Claims.Expiration, currentUnixTime)
 		return ErrInvalidToken
 	}
 
+	// Verify that the token is not older than allowed.
+	if verifyOpts.MaxAge > 0 {
+		maxAge := int64(verifyOpts.MaxAge / time.Second)
+		if t.Claims.IssuedAt == 0 || currentUnixTime-t.Claims.IssuedAt > maxAge+leeway {
+			log.Errorf("token issued at %d is older than %v - currently %d", t.Claims.IssuedAt, verifyOpts.MaxAge, currentUnixTime)
+			return ErrInvalidToken
+		}
+	}
+
 	// Verify the token signature.
 	if len(t.Signature) == 0 {
 		log.Error("token has no signature")
@@ -258,7 +277,7 @@ func parseAndVerifyCertChain(x5c []string, roots *x509.CertPool) (leafKey libtru
 		return nil, errors.New("unable to get leaf cert public key value")
 	}
 
-	leafKey, err = libtrust.FromCryptoPublicKey(leafCryptoKey)
+	leafKey, err = publicKeyFromCrypto(leafCryptoKey)
 	if err != nil {
 		return nil, fmt.Errorf("unable to make libtrust public key from leaf certificate: %s", err)
 	}
@@ -267,7 +286,7 @@ func parseAndVerifyCertChain(x5c []string, roots *x509.CertPool) (leafKey libtru
 }
 
 func parseAndVerifyRawJWK(rawJWK json.RawMessage, verifyOpts VerifyOptions) (pubKey libtrust.PublicKey, err error) {
-	pubKey, err = libtrust.UnmarshalPublicKeyJWK([]byte(rawJWK))
+	pubKey, err = unmarshalPublicKeyJWK([]byte(rawJWK))
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode raw JWK value: %s", err)
 	}
@@ -2,13 +2,16 @@ package token
 
 import (
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"os"
 	"strings"
@@ -208,6 +211,155 @@ func TestTokenVerify(t *testing.T) {
 	}
 }
 
+// makeEd25519TestToken makes a token signed by an Ed25519 key, identified by
+// the given header fields, by an issuer whose clock is off by skew.
+func makeEd25519TestToken(issuer, audience string, key ed25519.PrivateKey, header Header, skew time.Duration) (*Token, error) {
+	header.Type = "JWT"
+	header.SigningAlg = "EdDSA"
+
+	now := time.Now().Add(skew)
+	claimSet := &ClaimSet{
+		Issuer:     issuer,
+		Subject:    "foo",
+		Audience:   audience,
+		Expiration: now.Add(5 * time.Minute).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal jose header: %s", err)
+	}
+	claimSetBytes, err := json.Marshal(claimSet)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal claim set: %s", err)
+	}
+
+	encodingToSign := fmt.Sprintf("%s.%s", joseBase64UrlEncode(headerBytes), joseBase64UrlEncode(claimSetBytes))
+	signature := ed25519.Sign(key, []byte(encodingToSign))
+
+	return NewToken(fmt.Sprintf("%s.%s", encodingToSign, joseBase64UrlEncode(signature)))
+}
+
+// TestTokenVerifyEd25519 verifies Ed25519 signed tokens identifying their key
+// by certificate chain, JWK and key ID.
+func TestTokenVerifyEd25519(t *testing.T) {
+	issuer, audience := "test-issuer", "test-audience"
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(cert)
+
+	pubKey, err := publicKeyFromCrypto(cert.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawJWK, err := pubKey.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyOps := VerifyOptions{
+		TrustedIssuers:    []string{issuer},
+		AcceptedAudiences: []string{audience},
+		Roots:             rootPool,
+		TrustedKeys:       map[string]libtrust.PublicKey{pubKey.KeyID(): pubKey},
+	}
+
+	for _, header := range []Header{
+		{X5c: []string{base64.StdEncoding.EncodeToString(certDER)}},
+		{RawJWK: json.RawMessage(rawJWK)},
+		{KeyID: pubKey.KeyID()},
+	} {
+		token, err := makeEd25519TestToken(issuer, audience, priv, header, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := token.Verify(verifyOps); err != nil {
+			t.Fatalf("unexpected error verifying token with header %+v: %v", header, err)
+		}
+
+		// The signature is only valid for EdDSA.
+		token.Header.SigningAlg = "ES256"
+		if err := token.Verify(verifyOps); err == nil {
+			t.Fatalf("expected error verifying token with mismatched algorithm")
+		}
+	}
+}
+
+// TestTokenVerifyLeeway checks the validity period of tokens from issuers
+// with skewed clocks against the leeway and maximum age options.
+func TestTokenVerifyLeeway(t *testing.T) {
+	issuer, audience := "test-issuer", "test-audience"
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, err := publicKeyFromCrypto(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		skew   time.Duration
+		leeway time.Duration
+		maxAge time.Duration
+		valid  bool
+	}{
+		{skew: 0, valid: true},
+		{skew: 30 * time.Second, valid: false},
+		{skew: 30 * time.Second, leeway: time.Minute, valid: true},
+		{skew: -10 * time.Minute, valid: false},
+		{skew: -10 * time.Minute, leeway: 6 * time.Minute, valid: true},
+		{skew: -2 * time.Minute, valid: true},
+		{skew: -2 * time.Minute, maxAge: time.Minute, valid: false},
+		{skew: -2 * time.Minute, maxAge: time.Minute, leeway: 2 * time.Minute, valid: true},
+	} {
+		token, err := makeEd25519TestToken(issuer, audience, priv, Header{KeyID: pubKey.KeyID()}, testcase.skew)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = token.Verify(VerifyOptions{
+			TrustedIssuers:    []string{issuer},
+			AcceptedAudiences: []string{audience},
+			TrustedKeys:       map[string]libtrust.PublicKey{pubKey.KeyID(): pubKey},
+			Leeway:            testcase.leeway,
+			MaxAge:            testcase.maxAge,
+		})
+		if (err == nil) != testcase.valid {
+			t.Errorf("unexpected verification result for skew %v, leeway %v, max age %v: %v", testcase.skew, testcase.leeway, testcase.maxAge, err)
+		}
+	}
+}
+
 func writeTempRootCerts(rootKeys []libtrust.PrivateKey) (filename string, err error) {
 	rootCerts, err := makeRootCerts(rootKeys)
 	if err != nil {
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
@@ -20,6 +22,10 @@ type Client interface {
 	// name, tag pair.
 	GetImageManifest(name, tag string) (*manifest.SignedManifest, error)
 
+	// ResolveTag returns the descriptor of the image manifest at the given
+	// name, tag pair, without fetching the manifest.
+	ResolveTag(name, tag string) (ManifestDescriptor, error)
+
 	// PutImageManifest uploads an image manifest for the image at the given
 	// name, tag pair.
 	PutImageManifest(name, tag string, imageManifest *manifest.SignedManifest) error
@@ -67,6 +73,18 @@ type Client interface {
 	CancelBlobUpload(location string) error
 }
 
+// ManifestDescriptor describes an image manifest stored in the registry.
+type ManifestDescriptor struct {
+	// Digest identifies the manifest content.
+	Digest digest.Digest
+
+	// Size is the length of the manifest in bytes.
+	Size int64
+
+	// MediaType is the media type the manifest is served with.
+	MediaType string
+}
+
 var (
 	patternRangeHeader = regexp.MustCompile("bytes=0-(\\d+)/(\\d+)")
 )
@@ -74,22 +92,35 @@ var (
 // New returns a new Client which operates against a registry with the
 // given base endpoint
 // This endpoint should not include /v2/ or any part of the url after this.
-func New(endpoint string) (Client, error) {
+// Requests are sent with http.DefaultClient unless options configure another
+// transport.
+func New(endpoint string, options ...Option) (Client, error) {
 	ub, err := v2.NewURLBuilderFromString(endpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	return &clientImpl{
+	c := &clientImpl{
 		endpoint: endpoint,
 		ub:       ub,
-	}, nil
+		client:   http.DefaultClient,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
 }
 
 // clientImpl is the default implementation of the Client interface
 type clientImpl struct {
 	endpoint string
 	ub       *v2.URLBuilder
+	client   *http.Client
+
+	// authorization is the Authorization header sent with every request,
+	// if any.
+	authorization string
 }
 
 // TODO(bbland): use consistent route generation between server and client
@@ -100,7 +131,7 @@ func (r *clientImpl) GetImageManifest(name, tag string) (*manifest.SignedManifes
 		return nil, err
 	}
 
-	response, err := http.Get(manifestURL)
+	response, err := r.get(manifestURL)
 	if err != nil {
 		return nil, err
 	}
@@ -135,6 +166,53 @@ func (r *clientImpl) GetImageManifest(name, tag string) (*manifest.SignedManifes
 	return manifest, nil
 }
 
+// ResolveTag resolves the tag with a HEAD request, which the registry
+// answers with the digest of the manifest and no body, so that watching a tag
+// does not download the manifest each time.
+func (r *clientImpl) ResolveTag(name, tag string) (ManifestDescriptor, error) {
+	manifestURL, err := r.ub.BuildManifestURL(name, tag)
+	if err != nil {
+		return ManifestDescriptor{}, err
+	}
+
+	response, err := r.head(manifestURL)
+	if err != nil {
+		return ManifestDescriptor{}, err
+	}
+	defer response.Body.Close()
+
+	// The response to a HEAD request has no error details.
+	switch {
+	case response.StatusCode == http.StatusOK:
+		break
+	case response.StatusCode == http.StatusNotFound:
+		return ManifestDescriptor{}, &ImageManifestNotFoundError{Name: name, Tag: tag}
+	default:
+		return ManifestDescriptor{}, &UnexpectedHTTPStatusError{Status: response.Status}
+	}
+
+	dgst, err := digest.ParseDigest(response.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return ManifestDescriptor{}, fmt.Errorf("invalid Docker-Content-Digest header for %s:%s: %v", name, tag, err)
+	}
+
+	size, err := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return ManifestDescriptor{}, err
+	}
+
+	mediaType := response.Header.Get("Content-Type")
+	if parsed, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = parsed
+	}
+
+	return ManifestDescriptor{
+		Digest:    dgst,
+		Size:      size,
+		MediaType: mediaType,
+	}, nil
+}
+
 func (r *clientImpl) PutImageManifest(name, tag string, manifest *manifest.SignedManifest) error {
 	manifestURL, err := r.ub.BuildManifestURL(name, tag)
 	if err != nil {
@@ -146,7 +224,7 @@ func (r *clientImpl) PutImageManifest(name, tag string, manifest *manifest.Signe
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -181,7 +259,7 @@ func (r *clientImpl) DeleteImage(name, tag string) error {
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(deleteRequest)
+	response, err := r.do(deleteRequest)
 	if err != nil {
 		return err
 	}
@@ -214,7 +292,7 @@ func (r *clientImpl) ListImageTags(name string) ([]string, error) {
 		return nil, err
 	}
 
-	response, err := http.Get(tagsURL)
+	response, err := r.get(tagsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -257,7 +335,7 @@ func (r *clientImpl) BlobLength(name string, dgst digest.Digest) (int, error) {
 		return -1, err
 	}
 
-	response, err := http.Head(blobURL)
+	response, err := r.head(blobURL)
 	if err != nil {
 		return -1, err
 	}
@@ -299,7 +377,7 @@ func (r *clientImpl) GetBlob(name string, dgst digest.Digest, byteOffset int) (i
 	}
 
 	getRequest.Header.Add("Range", fmt.Sprintf("%d-", byteOffset))
-	response, err := http.DefaultClient.Do(getRequest)
+	response, err := r.do(getRequest)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -341,7 +419,7 @@ func (r *clientImpl) InitiateBlobUpload(name string) (string, error) {
 		return "", err
 	}
 
-	response, err := http.DefaultClient.Do(postRequest)
+	response, err := r.do(postRequest)
 	if err != nil {
 		return "", err
 	}
@@ -367,7 +445,7 @@ func (r *clientImpl) InitiateBlobUpload(name string) (string, error) {
 }
 
 func (r *clientImpl) GetBlobUploadStatus(location string) (int, int, error) {
-	response, err := http.Get(location)
+	response, err := r.get(location)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -406,8 +484,9 @@ func (r *clientImpl) UploadBlob(location string, blob io.ReadCloser, length int,
 
 	putRequest.Header.Set("Content-Type", "application/octet-stream")
 	putRequest.Header.Set("Content-Length", fmt.Sprint(length))
+	putRequest.ContentLength = int64(length)
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -444,10 +523,11 @@ func (r *clientImpl) UploadBlobChunk(location string, blobChunk io.ReadCloser, l
 
 	putRequest.Header.Set("Content-Type", "application/octet-stream")
 	putRequest.Header.Set("Content-Length", fmt.Sprint(length))
+	putRequest.ContentLength = int64(length)
 	putRequest.Header.Set("Content-Range",
 		fmt.Sprintf("%d-%d/%d", startByte, endByte, endByte))
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -497,7 +577,7 @@ func (r *clientImpl) FinishChunkedBlobUpload(location string, length int, dgst d
 	putRequest.Header.Set("Content-Range",
 		fmt.Sprintf("%d-%d/%d", length, length, length))
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -528,7 +608,7 @@ func (r *clientImpl) CancelBlobUpload(location string) error {
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(deleteRequest)
+	response, err := r.do(deleteRequest)
 	if err != nil {
 		return err
 	}
@@ -553,6 +633,47 @@ func (r *clientImpl) CancelBlobUpload(location string) error {
 	}
 }
 
+// do sends the request, returning a TooManyRequestsError or a
+// RequestTooLargeError instead of the response if the registry rate limits
+// the client or rejects the size of the request.
+func (r *clientImpl) do(req *http.Request) (*http.Response, error) {
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+
+	response, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limitError(response, time.Now()); err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// get sends a GET request to the url, see do.
+func (r *clientImpl) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.do(req)
+}
+
+// head sends a HEAD request to the url, see do.
+func (r *clientImpl) head(url string) (*http.Response, error) {
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.do(req)
+}
+
 // parseRangeHeader parses out the offset and length from a returned Range
 // header
 func parseRangeHeader(byteRangeHeader string) (int, int, error) {
@@ -1,13 +1,18 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
@@ -438,3 +443,526 @@ func (hirw *headerInterceptingResponseWriter) WriteHeader(status int) {
 
 	hirw.ResponseWriter.WriteHeader(status)
 }
+
+func TestSync(t *testing.T) {
+	name := "hello/world"
+	existingBlob := testBlob{
+		digest:   "tarsum.v2+sha256:12345",
+		contents: []byte("some contents"),
+	}
+	missingBlob := testBlob{
+		digest:   "tarsum.v2+sha256:98765",
+		contents: []byte("some other contents"),
+	}
+
+	newManifest := func(tag string, blobs ...testBlob) []byte {
+		m := &manifest.SignedManifest{
+			Manifest: manifest.Manifest{
+				Name:         name,
+				Tag:          tag,
+				Architecture: "x86",
+				Versioned: manifest.Versioned{
+					SchemaVersion: 1,
+				},
+			},
+		}
+		for _, blob := range blobs {
+			m.FSLayers = append(m.FSLayers, manifest.FSLayer{BlobSum: blob.digest})
+			m.History = append(m.History, manifest.History{V1Compatibility: blob.digest.String()})
+		}
+
+		p, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// "v1" is already up to date on the destination, "v2" needs to be
+	// synced and "dev" is not selected.
+	v1 := newManifest("v1", existingBlob)
+	v2 := newManifest("v2", existingBlob, missingBlob)
+
+	srcHandler := testutil.NewHandler(testutil.RequestResponseMap{
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/tags/list",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       []byte(`{"name": "hello/world", "tags": ["v1", "v2", "dev"]}`),
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/tags/list",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       []byte(`{"name": "hello/world", "tags": ["v1", "v2", "dev"]}`),
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v1",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v1,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v1",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v1,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v2",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v2,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v2",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v2,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/blobs/" + missingBlob.digest.String(),
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       missingBlob.contents,
+			},
+		},
+	})
+	src := httptest.NewServer(srcHandler)
+	defer src.Close()
+
+	uploadLocation := fmt.Sprintf("/v2/%s/blobs/test-uuid", name)
+	dstHandler := testutil.NewHandler(testutil.RequestResponseMap{
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v1",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v1,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "GET",
+				Route:  "/v2/" + name + "/manifests/v1",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       v1,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "HEAD",
+				Route:  "/v2/" + name + "/blobs/" + existingBlob.digest.String(),
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Headers: http.Header(map[string][]string{
+					"Content-Length": {fmt.Sprint(len(existingBlob.contents))},
+				}),
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "POST",
+				Route:  "/v2/" + name + "/blobs/uploads/",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusAccepted,
+				Headers: http.Header(map[string][]string{
+					"Location": {uploadLocation},
+				}),
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "PUT",
+				Route:  uploadLocation,
+				QueryParams: map[string][]string{
+					"digest": {missingBlob.digest.String()},
+				},
+				Body: missingBlob.contents,
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusCreated,
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "PUT",
+				Route:  "/v2/" + name + "/manifests/v2",
+				Body:   v2,
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+			},
+		},
+	})
+
+	var dst *httptest.Server
+	dst = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w = &headerInterceptingResponseWriter{ResponseWriter: w, serverURL: dst.URL}
+		dstHandler.ServeHTTP(w, r)
+	}))
+	defer dst.Close()
+
+	srcClient, err := New(src.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	dstClient, err := New(dst.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	checkResults := func(results []SyncResult) {
+		if len(results) != 2 {
+			t.Fatalf("unexpected number of results: %#v", results)
+		}
+
+		for _, result := range results {
+			if result.Err != nil {
+				t.Fatalf("unexpected error syncing %s: %v", result.Tag, result.Err)
+			}
+
+			if result.Synced != (result.Tag == "v2") {
+				t.Fatalf("unexpected sync result: %#v", result)
+			}
+		}
+	}
+
+	// The handlers are not safe for concurrent use, so tags are synced one
+	// at a time.
+	options := SyncOptions{
+		Tags:        []string{"v*"},
+		Concurrency: 1,
+		DryRun:      true,
+	}
+
+	results, err := Sync(srcClient, dstClient, name, options)
+	if err != nil {
+		t.Fatalf("unexpected error syncing: %v", err)
+	}
+	checkResults(results)
+
+	options.DryRun = false
+	results, err = Sync(srcClient, dstClient, name, options)
+	if err != nil {
+		t.Fatalf("unexpected error syncing: %v", err)
+	}
+	checkResults(results)
+}
+
+// countingTransport counts the requests it forwards.
+type countingTransport struct {
+	http.RoundTripper
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func TestClientTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name": "foo/bar",
+			"tags": []string{"latest"},
+		})
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{RoundTripper: NewTransport(TransportConfig{
+		MaxIdleConnsPerHost: 4,
+	})}
+
+	client, err := New(server.URL, WithTransport(transport))
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	tags, err := client.ListImageTags("foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error listing tags: %v", err)
+	}
+
+	if len(tags) != 1 || tags[0] != "latest" || transport.requests != 1 {
+		t.Fatalf("unexpected tags or requests: %v, %d", tags, transport.requests)
+	}
+
+	proxyURL, err := url.Parse("http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatalf("unexpected error parsing proxy url: %v", err)
+	}
+
+	configured := NewTransport(TransportConfig{
+		Proxy:               proxyURL,
+		MaxIdleConnsPerHost: 4,
+		TLSHandshakeTimeout: time.Second,
+	})
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	if proxy, err := configured.Proxy(req); err != nil || proxy.String() != proxyURL.String() {
+		t.Fatalf("unexpected proxy: %v, %v", proxy, err)
+	}
+
+	if configured.MaxIdleConnsPerHost != 4 || configured.TLSHandshakeTimeout != time.Second || configured.MaxIdleConns == 0 {
+		t.Fatalf("unexpected transport settings: %#v", configured)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// The timeout is kept whatever the order of the options.
+	client, err := New(server.URL, WithTimeout(10*time.Millisecond), WithTransportConfig(TransportConfig{}))
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	if _, err := client.ListImageTags("foo/bar"); err == nil {
+		t.Fatalf("expected listing tags of a stuck registry to time out")
+	}
+
+	if http.DefaultClient.Timeout != 0 {
+		t.Fatalf("default client modified: %v", http.DefaultClient.Timeout)
+	}
+}
+
+func TestClientAuthorization(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name": "foo/bar",
+			"tags": []string{"latest"},
+		})
+	}))
+	defer server.Close()
+
+	for _, testcase := range []struct {
+		option   Option
+		expected string
+	}{
+		{WithBasicAuth("robot", "secret"), "Basic cm9ib3Q6c2VjcmV0"},
+		{WithBearerToken("token"), "Bearer token"},
+	} {
+		client, err := New(server.URL, testcase.option)
+		if err != nil {
+			t.Fatalf("unexpected error creating client: %v", err)
+		}
+
+		if _, err := client.ListImageTags("foo/bar"); err != nil {
+			t.Fatalf("unexpected error listing tags: %v", err)
+		}
+
+		if authorization != testcase.expected {
+			t.Fatalf("unexpected authorization: %q != %q", authorization, testcase.expected)
+		}
+	}
+}
+
+func TestLimitErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Header().Set("Retry-After", "120")
+			w.Header().Set("RateLimit-Limit", "100;w=21600")
+			w.Header().Set("RateLimit-Remaining", "0;w=21600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "PUT":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+
+	_, err = client.ListImageTags("foo/bar")
+	tooMany, ok := err.(*TooManyRequestsError)
+	if !ok {
+		t.Fatalf("unexpected error listing tags: %v", err)
+	}
+
+	if tooMany.RetryAfter != 2*time.Minute || tooMany.Limit != 100 || tooMany.Remaining != 0 || tooMany.Window != 6*time.Hour {
+		t.Fatalf("unexpected rate limit error: %#v", tooMany)
+	}
+
+	err = client.PutImageManifest("foo/bar", "latest", &manifest.SignedManifest{Raw: []byte("{}")})
+	tooLarge, ok := err.(*RequestTooLargeError)
+	if !ok {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if len(tooLarge.Errors.Errors) != 1 || tooLarge.RetryAfter != 0 {
+		t.Fatalf("unexpected request too large error: %#v", tooLarge)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, testcase := range []struct {
+		header   string
+		expected time.Duration
+	}{
+		{header: "", expected: 0},
+		{header: "30", expected: 30 * time.Second},
+		{header: "-1", expected: 0},
+		{header: "Mon, 01 Jun 2015 12:01:30 GMT", expected: 90 * time.Second},
+		{header: "Mon, 01 Jun 2015 11:00:00 GMT", expected: 0},
+		{header: "soon", expected: 0},
+	} {
+		if d := parseRetryAfter(testcase.header, now); d != testcase.expected {
+			t.Fatalf("unexpected delay for Retry-After %q: %s != %s", testcase.header, d, testcase.expected)
+		}
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := []byte("some layer content")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	rc, err := NewVerifyingReader(ioutil.NopCloser(bytes.NewReader(content)), dgst)
+	if err != nil {
+		t.Fatalf("unexpected error creating verifying reader: %v", err)
+	}
+
+	p, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("unexpected error reading verified content: %v", err)
+	}
+	if !bytes.Equal(p, content) {
+		t.Fatalf("unexpected content read: %q != %q", p, content)
+	}
+
+	corrupt := append([]byte{}, content...)
+	corrupt[0] = 'S'
+	rc, err = NewVerifyingReader(ioutil.NopCloser(bytes.NewReader(corrupt)), dgst)
+	if err != nil {
+		t.Fatalf("unexpected error creating verifying reader: %v", err)
+	}
+
+	_, err = ioutil.ReadAll(rc)
+	if mismatch, ok := err.(*BlobDigestMismatchError); !ok || mismatch.Digest != dgst {
+		t.Fatalf("expected a digest mismatch error reading corrupt content, got %v", err)
+	}
+
+	// Reading past the end keeps failing.
+	if _, err := rc.Read(make([]byte, 1)); err == io.EOF {
+		t.Fatalf("expected the digest mismatch error after the end, got %v", err)
+	}
+
+	if _, err := NewVerifyingReader(ioutil.NopCloser(bytes.NewReader(content)), "sha256:invalid"); err == nil {
+		t.Fatalf("expected an error verifying an invalid digest")
+	}
+}
+
+func TestResolveTag(t *testing.T) {
+	name := "hello/world"
+	dgst := digest.Digest("sha256:" + strings.Repeat("a", 64))
+
+	handler := testutil.NewHandler([]testutil.RequestResponseMapping{
+		{
+			Request: testutil.Request{
+				Method: "HEAD",
+				Route:  "/v2/" + name + "/manifests/latest",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Headers: http.Header{
+					"Content-Length":        []string{"1234"},
+					"Content-Type":          []string{"application/json; charset=utf-8"},
+					"Docker-Content-Digest": []string{dgst.String()},
+				},
+			},
+		},
+		{
+			Request: testutil.Request{
+				Method: "HEAD",
+				Route:  "/v2/" + name + "/manifests/missing",
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusNotFound,
+			},
+		},
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := New(server.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	desc, err := client.ResolveTag(name, "latest")
+	if err != nil {
+		t.Fatalf("unexpected error resolving tag: %v", err)
+	}
+
+	expected := ManifestDescriptor{
+		Digest:    dgst,
+		Size:      1234,
+		MediaType: "application/json",
+	}
+	if desc != expected {
+		t.Fatalf("unexpected manifest descriptor: %#v != %#v", desc, expected)
+	}
+
+	if _, err := client.ResolveTag(name, "missing"); err == nil {
+		t.Fatalf("expected an error resolving a missing tag")
+	} else if _, ok := err.(*ImageManifestNotFoundError); !ok {
+		t.Fatalf("unexpected error resolving a missing tag: %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
 )
 
 // RepositoryNotFoundError is returned when making an operation against a
@@ -40,6 +46,17 @@ func (e *BlobNotFoundError) Error() string {
 		e.Name, e.Digest)
 }
 
+// BlobDigestMismatchError is returned when reading a blob whose content does
+// not match its digest, such as a blob corrupted in the registry or in
+// transit.
+type BlobDigestMismatchError struct {
+	Digest digest.Digest
+}
+
+func (e *BlobDigestMismatchError) Error() string {
+	return fmt.Sprintf("Blob content does not match Digest: %s", e.Digest)
+}
+
 // BlobUploadNotFoundError is returned when making a blob upload operation against an
 // invalid blob upload location url.
 // This may be the result of using a cancelled, completed, or stale upload
@@ -77,3 +94,134 @@ type UnexpectedHTTPStatusError struct {
 func (e *UnexpectedHTTPStatusError) Error() string {
 	return fmt.Sprintf("Received unexpected HTTP status: %s", e.Status)
 }
+
+// TooManyRequestsError is returned when the registry rate limits the client
+// with 429 Too Many Requests. Clients should wait for RetryAfter, if set,
+// before retrying.
+type TooManyRequestsError struct {
+	// RetryAfter is the delay requested by the Retry-After header, or zero
+	// if the registry requested none.
+	RetryAfter time.Duration
+
+	// Limit is the number of requests allowed in each Window, and Remaining
+	// the number left in the current one, from the RateLimit-Limit and
+	// RateLimit-Remaining headers sent by Docker Hub. They are -1 if the
+	// registry did not send them.
+	Limit     int
+	Remaining int
+	Window    time.Duration
+
+	// Errors are the errors of the response body, if any.
+	Errors v2.Errors
+}
+
+func (e *TooManyRequestsError) Error() string {
+	msg := "Too many requests"
+	if e.Limit >= 0 {
+		msg += fmt.Sprintf(", limit of %d requests", e.Limit)
+		if e.Window > 0 {
+			msg += fmt.Sprintf(" per %s", e.Window)
+		}
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	return msg
+}
+
+// RequestTooLargeError is returned when the registry rejects a request with
+// 413 Request Entity Too Large, such as a manifest over the size limit or a
+// push over a storage quota.
+type RequestTooLargeError struct {
+	// RetryAfter is the delay requested by the Retry-After header, when the
+	// condition is temporary, or zero.
+	RetryAfter time.Duration
+
+	// Errors are the errors of the response body, if any.
+	Errors v2.Errors
+}
+
+func (e *RequestTooLargeError) Error() string {
+	if len(e.Errors.Errors) > 0 {
+		return fmt.Sprintf("Request too large: %v", e.Errors.Error())
+	}
+	return "Request too large"
+}
+
+// limitError returns the TooManyRequestsError or RequestTooLargeError of the
+// response, or nil if it has another status.
+func limitError(response *http.Response, now time.Time) error {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		err := &TooManyRequestsError{
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), now),
+			Limit:      -1,
+			Remaining:  -1,
+		}
+		if limit, window, ok := parseRateLimit(response.Header.Get("RateLimit-Limit")); ok {
+			err.Limit, err.Window = limit, window
+		}
+		if remaining, _, ok := parseRateLimit(response.Header.Get("RateLimit-Remaining")); ok {
+			err.Remaining = remaining
+		}
+		json.NewDecoder(response.Body).Decode(&err.Errors)
+		return err
+	case http.StatusRequestEntityTooLarge:
+		err := &RequestTooLargeError{
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), now),
+		}
+		json.NewDecoder(response.Body).Decode(&err.Errors)
+		return err
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date, into the delay from now. It returns zero if the
+// header is missing, malformed or in the past.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
+
+// parseRateLimit parses a rate limit header of Docker Hub, such as
+// "100;w=21600", into the number of requests and the window, in seconds, if
+// it is given.
+func parseRateLimit(header string) (int, time.Duration, bool) {
+	if header == "" {
+		return 0, 0, false
+	}
+
+	parts := strings.Split(header, ";")
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	var window time.Duration
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "w=") {
+			if seconds, err := strconv.Atoi(part[len("w="):]); err == nil {
+				window = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return n, window, true
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"path"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+)
+
+// defaultSyncConcurrency is the number of tags synced in parallel, unless
+// SyncOptions specifies otherwise.
+const defaultSyncConcurrency = 4
+
+// SyncOptions configures a Sync.
+type SyncOptions struct {
+	// Tags lists patterns, in the syntax of path.Match, selecting the tags
+	// to sync. All tags are synced if it is empty.
+	Tags []string
+
+	// Concurrency is the number of tags synced in parallel.
+	Concurrency int
+
+	// DryRun reports the tags that would be synced without copying them.
+	DryRun bool
+}
+
+// SyncResult describes the outcome of syncing a single tag.
+type SyncResult struct {
+	Tag    string
+	Digest digest.Digest
+
+	// Synced is true if the tag was, or in a dry run would be, copied.
+	// It is false if the destination already had the same manifest.
+	Synced bool
+
+	Err error
+}
+
+// Sync mirrors the tags of the named repository matching the options from
+// the source registry to the destination registry. Tags whose manifest digest
+// is the same on both sides are skipped, as are layers the destination
+// already has. A result is returned for every selected tag; failing tags do
+// not stop the others.
+func Sync(src, dst Client, name string, options SyncOptions) ([]SyncResult, error) {
+	tags, err := src.ListImageTags(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []string
+	for _, tag := range tags {
+		if tagSelected(tag, options.Tags) {
+			selected = append(selected, tag)
+		}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultSyncConcurrency
+	}
+
+	results := make([]SyncResult, len(selected))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = syncTag(src, dst, name, selected[i], options.DryRun)
+			}
+		}()
+	}
+
+	for i := range selected {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, nil
+}
+
+// tagSelected returns true if tag matches any of the patterns, or if there
+// are none.
+func tagSelected(tag string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, tag); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func syncTag(src, dst Client, name, tag string, dryRun bool) SyncResult {
+	result := SyncResult{Tag: tag}
+
+	m, err := src.GetImageManifest(name, tag)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Digest, err = manifestDigest(m)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Any error fetching the destination manifest, such as the tag not
+	// existing there, means it has to be synced.
+	if existing, err := dst.GetImageManifest(name, tag); err == nil {
+		if dgst, err := manifestDigest(existing); err == nil && dgst == result.Digest {
+			log.WithFields(log.Fields{
+				"name":   name,
+				"tag":    tag,
+				"digest": result.Digest,
+			}).Info("Tag up to date")
+			return result
+		}
+	}
+
+	result.Synced = true
+	if dryRun {
+		return result
+	}
+
+	synced := make(map[digest.Digest]struct{})
+	for _, fsLayer := range m.FSLayers {
+		if _, ok := synced[fsLayer.BlobSum]; ok {
+			continue
+		}
+
+		if err := syncLayer(src, dst, name, fsLayer); err != nil {
+			result.Err = err
+			return result
+		}
+		synced[fsLayer.BlobSum] = struct{}{}
+	}
+
+	if err := dst.PutImageManifest(name, tag, m); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"name":  name,
+			"tag":   tag,
+		}).Warn("Unable to upload manifest")
+		result.Err = err
+		return result
+	}
+
+	log.WithFields(log.Fields{
+		"name":   name,
+		"tag":    tag,
+		"digest": result.Digest,
+	}).Info("Synced tag")
+	return result
+}
+
+func syncLayer(src, dst Client, name string, fsLayer manifest.FSLayer) error {
+	length, err := dst.BlobLength(name, fsLayer.BlobSum)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"layer": fsLayer,
+		}).Warn("Unable to check existence of remote layer")
+		return err
+	}
+	if length >= 0 {
+		log.WithField("layer", fsLayer).Info("Layer already exists")
+		return nil
+	}
+
+	blob, length, err := src.GetBlob(name, fsLayer.BlobSum, 0)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"layer": fsLayer,
+		}).Warn("Unable to fetch layer")
+		return err
+	}
+
+	// Verify the layer as it is copied, so that a layer corrupted in the
+	// source registry fails the upload rather than being synced.
+	if verified, err := NewVerifyingReader(blob, fsLayer.BlobSum); err == nil {
+		blob = verified
+	}
+
+	location, err := dst.InitiateBlobUpload(name)
+	if err != nil {
+		blob.Close()
+		log.WithFields(log.Fields{
+			"error": err,
+			"layer": fsLayer,
+		}).Warn("Unable to upload layer")
+		return err
+	}
+
+	if err := dst.UploadBlob(location, blob, length, fsLayer.BlobSum); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"layer": fsLayer,
+		}).Warn("Unable to upload layer")
+		return err
+	}
+
+	return nil
+}
+
+// manifestDigest returns the digest identifying the content of the manifest:
+// the digest of its payload if it is signed, of its raw bytes otherwise.
+func manifestDigest(m *manifest.SignedManifest) (digest.Digest, error) {
+	p, err := m.Payload()
+	if err != nil {
+		p, err = m.MarshalJSON()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return digest.FromBytes(p)
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportConfig configures the HTTP transport of a client. Fields left
+// zero keep the defaults of http.DefaultTransport.
+type TransportConfig struct {
+	// Proxy is the URL of the HTTP or HTTPS proxy requests are sent
+	// through. If nil, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY environment variables.
+	Proxy *url.URL
+
+	// DialContext dials the connections to the registry, or to the proxy.
+	// If nil, connections are dialed with a 30 second timeout and keep-alive
+	// period.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// MaxIdleConns and MaxIdleConnsPerHost bound the idle connections kept
+	// open in the pool, across all hosts and for each host.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long idle connections are kept open.
+	IdleConnTimeout time.Duration
+
+	// TLSClientConfig configures the TLS connections, for instance to trust
+	// the certificate authority of a private registry or to present a
+	// client certificate.
+	TLSClientConfig *tls.Config
+
+	// TLSHandshakeTimeout bounds the duration of TLS handshakes.
+	TLSHandshakeTimeout time.Duration
+}
+
+// NewTransport returns an http.Transport configured by config.
+func NewTransport(config TransportConfig) *http.Transport {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       config.TLSClientConfig,
+	}
+
+	if config.Proxy != nil {
+		transport.Proxy = http.ProxyURL(config.Proxy)
+	}
+	if config.DialContext != nil {
+		transport.DialContext = config.DialContext
+	}
+	if config.MaxIdleConns != 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+	if config.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+
+	return transport
+}
+
+// Option configures a client created by New.
+type Option func(*clientImpl)
+
+// WithTransport makes the client send its requests with transport, which may
+// wrap an http.Transport to add authentication or instrumentation.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *clientImpl) {
+		c.client = &http.Client{Transport: transport, Timeout: c.client.Timeout}
+	}
+}
+
+// WithTimeout bounds the duration of each request of the client, including
+// the transfer of its body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *clientImpl) {
+		client := *c.client
+		client.Timeout = timeout
+		c.client = &client
+	}
+}
+
+// WithBasicAuth makes the client authenticate its requests with HTTP basic
+// authentication, as registries using robot accounts or htpasswd expect.
+func WithBasicAuth(username, password string) Option {
+	return func(c *clientImpl) {
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		c.authorization = "Basic " + credentials
+	}
+}
+
+// WithBearerToken makes the client authenticate its requests with token, a
+// long-lived token issued by the token server of the registry. The token is
+// sent as is, so it must grant all the access the client needs.
+func WithBearerToken(token string) Option {
+	return func(c *clientImpl) {
+		c.authorization = "Bearer " + token
+	}
+}
+
+// WithTransportConfig makes the client send its requests with a transport
+// configured by config, see NewTransport.
+func WithTransportConfig(config TransportConfig) Option {
+	return WithTransport(NewTransport(config))
+}
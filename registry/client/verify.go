@@ -0,0 +1,60 @@
+package client
+
+import (
+	"io"
+
+	"github.com/docker/distribution/digest"
+)
+
+// verifyingReader verifies the content of a blob against its digest as it is
+// read, and fails the read reaching its end if they do not match.
+type verifyingReader struct {
+	io.ReadCloser
+	digest   digest.Digest
+	verifier digest.Verifier
+
+	// err is the result of the verification, once the end is reached.
+	err error
+}
+
+// NewVerifyingReader returns a reader reading the blob content from rc and
+// verifying it against dgst as it is consumed, so that a blob can be
+// verified without buffering it. The final read returns a
+// BlobDigestMismatchError instead of io.EOF if the content does not match the
+// digest. The content must be read from its start, so readers of resumed
+// downloads cannot be verified. Closing the returned reader closes rc.
+func NewVerifyingReader(rc io.ReadCloser, dgst digest.Digest) (io.ReadCloser, error) {
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifyingReader{
+		ReadCloser: rc,
+		digest:     dgst,
+		verifier:   verifier,
+	}, nil
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	if vr.err != nil {
+		return 0, vr.err
+	}
+
+	n, err := vr.ReadCloser.Read(p)
+	if n > 0 {
+		if _, err := vr.verifier.Write(p[:n]); err != nil {
+			return n, err
+		}
+	}
+
+	if err == io.EOF {
+		vr.err = io.EOF
+		if !vr.verifier.Verified() {
+			vr.err = &BlobDigestMismatchError{Digest: vr.digest}
+		}
+		return n, vr.err
+	}
+
+	return n, err
+}
@@ -0,0 +1,869 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"runtime"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/robot"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/cache"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// defaultAdminPurgeAge is the minimum age of the uploads removed by the admin
+// gc endpoint, unless another age is requested.
+const defaultAdminPurgeAge = 168 * time.Hour
+
+// defaultAdminPullStatsWindow is the period covered by the admin pull
+// statistics, unless another window is requested.
+const defaultAdminPullStatsWindow = 30 * 24 * time.Hour
+
+// adminAccess is the access required to use the admin interface. Grants of
+// "*" on the resource also cover it.
+var adminAccess = auth.Access{
+	Resource: auth.Resource{
+		Type: "registry",
+		Name: "admin",
+	},
+	Action: "admin",
+}
+
+// ReloadFunc returns a freshly loaded registry configuration.
+type ReloadFunc func() (*configuration.Configuration, error)
+
+// AdminApp serves the admin interface of an App. It is meant to be served on
+// its own listener, keeping operational controls off the registry api.
+type AdminApp struct {
+	app              *App
+	router           *mux.Router
+	accessController auth.AccessController
+	reload           ReloadFunc
+	started          time.Time
+}
+
+// NewAdminApp returns the admin interface for app, protected by the access
+// controller configured in the admin section of the configuration. If reload
+// is nil, configuration reloads are not supported.
+func NewAdminApp(app *App, reload ReloadFunc) (*AdminApp, error) {
+	aa := &AdminApp{
+		app:     app,
+		router:  mux.NewRouter(),
+		reload:  reload,
+		started: time.Now(),
+	}
+
+	authType := app.Config.Admin.Auth.Type()
+	if authType != "" {
+		accessController, err := auth.GetAccessController(authType, app.Config.Admin.Auth.Parameters())
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure admin authorization (%s): %v", authType, err)
+		}
+		aa.accessController = accessController
+	} else {
+		if !localAdminAddr(app.Config.Admin.Net, app.Config.Admin.Addr) {
+			return nil, fmt.Errorf("admin interface on %s requires auth, unless it listens on a unix socket or a loopback address", app.Config.Admin.Addr)
+		}
+		ctxu.GetLogger(app).Warnf("admin interface has no auth configured, only serving local requests")
+	}
+
+	aa.router.Path("/admin/v1/stats").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getStats),
+	})
+	aa.router.Path("/admin/v1/readonly").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getReadOnly),
+		"PUT": http.HandlerFunc(aa.putReadOnly),
+	})
+	aa.router.Path("/admin/v1/cache/flush").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.flushCache),
+	})
+	aa.router.Path("/admin/v1/gc").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.collectGarbage),
+	})
+	aa.router.Path("/admin/v1/config/reload").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.reloadConfig),
+	})
+	aa.router.Path("/admin/v1/pullstats").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getPullStats),
+	})
+	aa.router.Path("/admin/v1/blobs").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getBlob),
+	})
+	aa.router.Path("/admin/v1/tags/history").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getTagHistory),
+	})
+	aa.router.Path("/admin/v1/trash").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getTrash),
+	})
+	aa.router.Path("/admin/v1/trash/restore").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.restoreTag),
+	})
+	aa.router.Path("/admin/v1/repositories/rename").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.renameRepository),
+	})
+	aa.router.Path("/admin/v1/repositories/metadata").Handler(handlers.MethodHandler{
+		"GET": http.HandlerFunc(aa.getRepositoryMetadata),
+		"PUT": http.HandlerFunc(aa.putRepositoryMetadata),
+	})
+	aa.router.Path("/admin/v1/robots").Handler(handlers.MethodHandler{
+		"GET":  http.HandlerFunc(aa.getRobotAccounts),
+		"POST": http.HandlerFunc(aa.createRobotAccount),
+	})
+	aa.router.Path("/admin/v1/robots/{name}").Handler(handlers.MethodHandler{
+		"DELETE": http.HandlerFunc(aa.deleteRobotAccount),
+	})
+	aa.router.Path("/admin/v1/notifications/endpoints/{endpoint}/ping").Handler(handlers.MethodHandler{
+		"POST": http.HandlerFunc(aa.pingEndpoint),
+	})
+
+	if app.Config.HTTP.Debug.Admin {
+		aa.router.PathPrefix("/debug/").Handler(newDebugMux(app.Config.HTTP.Debug))
+	}
+
+	return aa, nil
+}
+
+// localAdminAddr returns true if the admin interface listening on addr only
+// accepts connections from the local host. An empty address is served by the
+// caller, and is checked by request instead.
+func localAdminAddr(network, addr string) bool {
+	switch network {
+	case "unix":
+		return true
+	case "", "tcp":
+	default:
+		return false
+	}
+
+	if addr == "" {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// localRequest returns true if the request was received from the local host.
+// The peer address is used, since forwarding headers can be set by any
+// client.
+func (aa *AdminApp) localRequest(r *http.Request) bool {
+	if aa.app.Config.Admin.Net == "unix" {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServeHTTP authorizes the request against the admin access controller
+// before dispatching it to the admin endpoints. Without an access controller,
+// only local requests are served.
+func (aa *AdminApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := ctxu.WithRequest(aa.app, r)
+	ctx = ctxu.WithLogger(ctx, ctxu.GetRequestLogger(ctx))
+
+	if aa.accessController == nil && !aa.localRequest(r) {
+		ctxu.GetLogger(ctx).Errorf("admin request from %s denied without auth configured", r.RemoteAddr)
+		serveAdminError(w, http.StatusForbidden, v2.ErrorCodeUnauthorized, "admin interface only serves local requests without auth configured")
+		return
+	}
+
+	if aa.accessController != nil {
+		if _, err := aa.accessController.Authorized(ctx, adminAccess); err != nil {
+			switch err := err.(type) {
+			case auth.Challenge:
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				err.ServeHTTP(w, r)
+
+				var errs v2.Errors
+				errs.Push(v2.ErrorCodeUnauthorized, []auth.Access{adminAccess})
+				serveJSON(w, errs)
+			default:
+				ctxu.GetLogger(ctx).Errorf("error checking admin authorization: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
+	}
+
+	ctxu.GetLogger(ctx).Infof("admin request")
+	aa.router.ServeHTTP(w, r)
+}
+
+type adminStatsResponse struct {
+	Uptime     string          `json:"uptime"`
+	ReadOnly   bool            `json:"readOnly"`
+	Goroutines int             `json:"goroutines"`
+	HeapAlloc  uint64          `json:"heapAlloc"`
+	Registry   json.RawMessage `json:"registry,omitempty"`
+}
+
+// getStats reports runtime statistics and the registry expvar counters.
+func (aa *AdminApp) getStats(w http.ResponseWriter, r *http.Request) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	stats := adminStatsResponse{
+		Uptime:     time.Since(aa.started).String(),
+		ReadOnly:   aa.app.ReadOnly(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  memStats.HeapAlloc,
+	}
+
+	if registry := expvar.Get("registry"); registry != nil {
+		stats.Registry = json.RawMessage(registry.String())
+	}
+
+	serveJSON(w, stats)
+}
+
+type adminReadOnlyRequest struct {
+	ReadOnly bool `json:"readOnly"`
+}
+
+// getReadOnly reports whether the registry is in read-only mode.
+func (aa *AdminApp) getReadOnly(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, adminReadOnlyRequest{ReadOnly: aa.app.ReadOnly()})
+}
+
+// putReadOnly enables or disables read-only mode.
+func (aa *AdminApp) putReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req adminReadOnlyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	aa.app.SetReadOnly(req.ReadOnly)
+	ctxu.GetLogger(aa.app).Infof("read-only mode set to %t", req.ReadOnly)
+	serveJSON(w, req)
+}
+
+// flushCache discards the contents of the layer info cache.
+func (aa *AdminApp) flushCache(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := aa.app.layerInfoCache.(cache.Flusher)
+	if !ok {
+		serveAdminError(w, http.StatusNotFound, v2.ErrorCodeUnsupported, "no flushable cache configured")
+		return
+	}
+
+	if err := flusher.Flush(aa.app); err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type adminGCResponse struct {
+	DryRun  bool     `json:"dryRun"`
+	Deleted []string `json:"deleted"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// collectGarbage removes stale uploads from the storage backend, and the
+// tags deleted before the trash retention from the trash, if it is enabled.
+// The same collection can be scheduled in the configuration. The "age" query
+// parameter sets the minimum age of removed uploads and
+// "dryrun=true" only reports what would be removed.
+func (aa *AdminApp) collectGarbage(w http.ResponseWriter, r *http.Request) {
+	age := defaultAdminPurgeAge
+	if ageStr := r.FormValue("age"); ageStr != "" {
+		var err error
+		age, err = time.ParseDuration(ageStr)
+		if err != nil {
+			serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, fmt.Sprintf("invalid age: %v", err))
+			return
+		}
+	}
+
+	resp := adminGCResponse{
+		DryRun: r.FormValue("dryrun") == "true",
+	}
+
+	deleted, errs := aa.app.collectGarbage(aa.app, time.Now().Add(-age), resp.DryRun)
+	resp.Deleted = deleted
+	if resp.Deleted == nil {
+		resp.Deleted = []string{}
+	}
+	for _, err := range errs {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+
+	serveJSON(w, resp)
+}
+
+type adminReloadResponse struct {
+	LogLevel string `json:"logLevel"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+// reloadConfig reloads the configuration and applies the settings that can
+// change at runtime: the log level and read-only mode.
+func (aa *AdminApp) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if aa.reload == nil {
+		serveAdminError(w, http.StatusNotFound, v2.ErrorCodeUnsupported, "configuration reload not supported")
+		return
+	}
+
+	config, err := aa.reload()
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	level := config.Log.Level
+	if level == "" {
+		level = config.Loglevel
+	}
+
+	if level != "" {
+		l, err := log.ParseLevel(string(level))
+		if err != nil {
+			serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err)
+			return
+		}
+		log.SetLevel(l)
+	}
+
+	aa.app.SetReadOnly(readOnlyEnabled(config.Storage))
+	ctxu.GetLogger(aa.app).Infof("configuration reloaded")
+
+	serveJSON(w, adminReloadResponse{
+		LogLevel: log.GetLevel().String(),
+		ReadOnly: aa.app.ReadOnly(),
+	})
+}
+
+type adminPullStatsResponse struct {
+	Repository string           `json:"repository"`
+	Since      time.Time        `json:"since"`
+	Pulls      map[string]int64 `json:"pulls"`
+}
+
+// getPullStats reports the manifest pulls of each tag of the repository given
+// by the "repository" query parameter. The "window" query parameter sets the
+// period covered, 30 days by default. Tags that were not pulled are reported
+// with no pulls, so that unused images stand out.
+func (aa *AdminApp) getPullStats(w http.ResponseWriter, r *http.Request) {
+	if aa.app.pullStats == nil {
+		serveAdminError(w, http.StatusNotFound, v2.ErrorCodeUnsupported, "pull statistics not enabled")
+		return
+	}
+
+	name := r.FormValue("repository")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+
+	window := defaultAdminPullStatsWindow
+	if windowStr := r.FormValue("window"); windowStr != "" {
+		var err error
+		window, err = time.ParseDuration(windowStr)
+		if err != nil {
+			serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, fmt.Sprintf("invalid window: %v", err))
+			return
+		}
+	}
+
+	resp := adminPullStatsResponse{
+		Repository: name,
+		Since:      time.Now().Add(-window).UTC(),
+	}
+
+	var err error
+	resp.Pulls, err = aa.app.pullStats.counts(aa.app, name, resp.Since)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	repository, err := aa.app.registry.Repository(aa.app, name)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	tags, err := repository.Manifests().Tags()
+	if err != nil {
+		switch err := err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			serveAdminError(w, http.StatusNotFound, v2.ErrorCodeNameUnknown, err)
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	for _, tag := range tags {
+		if _, ok := resp.Pulls[tag]; !ok {
+			resp.Pulls[tag] = 0
+		}
+	}
+
+	serveJSON(w, resp)
+}
+
+type adminBlobResponse struct {
+	Digest       digest.Digest `json:"digest"`
+	Stored       bool          `json:"stored"`
+	Repositories []string      `json:"repositories"`
+}
+
+// getBlob reports whether the blob with the digest given by the "digest"
+// query parameter is in the blob store, and which indexed repositories link
+// it, to find the images containing a layer without walking the storage
+// backend.
+func (aa *AdminApp) getBlob(w http.ResponseWriter, r *http.Request) {
+	dgst, err := digest.ParseDigest(r.FormValue("digest"))
+	if err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeDigestInvalid, err)
+		return
+	}
+
+	resp := adminBlobResponse{
+		Digest: dgst,
+	}
+
+	resp.Stored, resp.Repositories, err = storage.BlobRepositories(aa.app, aa.app.driver, dgst)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+	if resp.Repositories == nil {
+		resp.Repositories = []string{}
+	}
+
+	serveJSON(w, resp)
+}
+
+// adminTagRegexp matches a whole tag name.
+var adminTagRegexp = regexp.MustCompile(`^` + v2.TagNameRegexp.String() + `$`)
+
+type adminTagHistoryResponse struct {
+	Repository string                `json:"repository"`
+	Tag        string                `json:"tag"`
+	Revisions  []storage.TagRevision `json:"revisions"`
+}
+
+// getTagHistory reports the manifest digests the tag given by the "tag"
+// query parameter has referenced in the repository given by "repository",
+// with the last time it was set to each of them, for incident forensics.
+func (aa *AdminApp) getTagHistory(w http.ResponseWriter, r *http.Request) {
+	name, tag := r.FormValue("repository"), r.FormValue("tag")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+	if !adminTagRegexp.MatchString(tag) {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeTagInvalid, tag)
+		return
+	}
+
+	revisions, err := storage.TagHistory(aa.app, aa.app.driver, aa.app.nameRules, name, tag)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			serveAdminError(w, http.StatusNotFound, v2.ErrorCodeManifestUnknown, map[string]string{"name": name, "tag": tag})
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	serveJSON(w, adminTagHistoryResponse{
+		Repository: name,
+		Tag:        tag,
+		Revisions:  revisions,
+	})
+}
+
+type adminTrashResponse struct {
+	Repository string               `json:"repository"`
+	Tags       []storage.TrashedTag `json:"tags"`
+}
+
+// getTrash lists the deleted tags in the trash of the repository given by the
+// "repository" query parameter, with the time they were deleted.
+func (aa *AdminApp) getTrash(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("repository")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+
+	tags, err := storage.TrashedTags(aa.app, aa.app.driver, aa.app.nameRules, name)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+	if tags == nil {
+		tags = []storage.TrashedTag{}
+	}
+
+	serveJSON(w, adminTrashResponse{
+		Repository: name,
+		Tags:       tags,
+	})
+}
+
+type adminRestoreResponse struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+// restoreTag restores the deleted tag given by the "tag" query parameter
+// from the trash of the repository given by "repository", unless the tag has
+// been pushed again since.
+func (aa *AdminApp) restoreTag(w http.ResponseWriter, r *http.Request) {
+	if aa.app.ReadOnly() {
+		serveAdminError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnsupported, "registry is in read-only mode")
+		return
+	}
+
+	name, tag := r.FormValue("repository"), r.FormValue("tag")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+	if !adminTagRegexp.MatchString(tag) {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeTagInvalid, tag)
+		return
+	}
+
+	if err := storage.RestoreTag(aa.app, aa.app.driver, aa.app.nameRules, name, tag); err != nil {
+		switch err := err.(type) {
+		case storage.ErrTagExists:
+			serveAdminError(w, http.StatusConflict, v2.ErrorCodeTagInvalid, err.Error())
+		case storagedriver.PathNotFoundError:
+			serveAdminError(w, http.StatusNotFound, v2.ErrorCodeManifestUnknown, map[string]string{"name": name, "tag": tag})
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	ctxu.GetLogger(aa.app).Infof("restored tag %s:%s from the trash", name, tag)
+	serveJSON(w, adminRestoreResponse{
+		Repository: name,
+		Tag:        tag,
+	})
+}
+
+type adminRenameResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// renameRepository renames the repository given by the "from" query
+// parameter to the name given by "to", so that teams can reorganize their
+// repositories without pulling and pushing their images again. The layer
+// info cache is flushed, if possible, so that the former name no longer
+// serves layers, and a rename event is sent to the notification endpoints.
+func (aa *AdminApp) renameRepository(w http.ResponseWriter, r *http.Request) {
+	if aa.app.ReadOnly() {
+		serveAdminError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnsupported, "registry is in read-only mode")
+		return
+	}
+
+	from, to := r.FormValue("from"), r.FormValue("to")
+	for _, name := range []string{from, to} {
+		if err := aa.app.nameRules.Validate(name); err != nil {
+			serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+			return
+		}
+	}
+
+	if err := storage.RenameRepository(aa.app, aa.app.driver, aa.app.nameRules, from, to); err != nil {
+		switch err := err.(type) {
+		case storage.ErrRepositoryExists:
+			serveAdminError(w, http.StatusConflict, v2.ErrorCodeNameInvalid, err.Error())
+		case storagedriver.PathNotFoundError:
+			serveAdminError(w, http.StatusNotFound, v2.ErrorCodeNameUnknown, from)
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	// The layers of the repository are no longer members of its old name,
+	// nor missing from its new one.
+	if invalidator, ok := aa.app.layerInfoCache.(cache.Invalidator); ok {
+		for _, name := range []string{from, to} {
+			if err := invalidator.InvalidateRepository(aa.app, name); err != nil {
+				ctxu.GetLogger(aa.app).Errorf("error invalidating layer info cache of %s after renaming %s: %v", name, from, err)
+			}
+		}
+	} else if flusher, ok := aa.app.layerInfoCache.(cache.Flusher); ok {
+		if err := flusher.Flush(aa.app); err != nil {
+			ctxu.GetLogger(aa.app).Errorf("error flushing layer info cache after renaming %s: %v", from, err)
+		}
+	}
+
+	// The metadata of the repository moved along with it.
+	aa.app.visibility.invalidate(from, to)
+
+	request := notifications.NewRequestRecord(ctxu.GetRequestID(ctxu.WithRequest(aa.app, r)), r)
+	event := notifications.NewRenameEvent(aa.app.events.source, request, from, to)
+	if err := aa.app.events.sink.Write(event); err != nil {
+		ctxu.GetLogger(aa.app).Errorf("error writing rename event: %v", err)
+	}
+
+	ctxu.GetLogger(aa.app).Infof("renamed repository %s to %s", from, to)
+	serveJSON(w, adminRenameResponse{From: from, To: to})
+}
+
+type adminMetadataResponse struct {
+	Repository string `json:"repository"`
+	storage.RepositoryMetadata
+}
+
+// getRepositoryMetadata reports the attributes of the repository given by
+// the "repository" query parameter, such as its visibility.
+func (aa *AdminApp) getRepositoryMetadata(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("repository")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+
+	metadata, err := storage.GetRepositoryMetadata(aa.app, aa.app.driver, aa.app.nameRules, name)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	serveJSON(w, adminMetadataResponse{
+		Repository:         name,
+		RepositoryMetadata: metadata,
+	})
+}
+
+// putRepositoryMetadata replaces the attributes of the repository given by
+// the "repository" query parameter with those of the request body. The
+// attributes may be set before the repository is first pushed, so that it
+// is never public by accident.
+func (aa *AdminApp) putRepositoryMetadata(w http.ResponseWriter, r *http.Request) {
+	if aa.app.ReadOnly() {
+		serveAdminError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnsupported, "registry is in read-only mode")
+		return
+	}
+
+	name := r.FormValue("repository")
+	if err := aa.app.nameRules.Validate(name); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeNameInvalid, err)
+		return
+	}
+
+	var metadata storage.RepositoryMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	if err := storage.PutRepositoryMetadata(aa.app, aa.app.driver, aa.app.nameRules, name, metadata); err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+	aa.app.visibility.invalidate(name)
+
+	ctxu.GetLogger(aa.app).Infof("set metadata of repository %s, public: %t", name, metadata.Public)
+	serveJSON(w, adminMetadataResponse{
+		Repository:         name,
+		RepositoryMetadata: metadata,
+	})
+}
+
+type adminRobotAccountsResponse struct {
+	Robots []storage.RobotAccount `json:"robots"`
+}
+
+// getRobotAccounts lists the robot accounts, without their token hashes.
+func (aa *AdminApp) getRobotAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := storage.RobotAccounts(aa.app, aa.app.driver)
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	for i := range accounts {
+		accounts[i].TokenHash = ""
+	}
+	if accounts == nil {
+		accounts = []storage.RobotAccount{}
+	}
+
+	serveJSON(w, adminRobotAccountsResponse{Robots: accounts})
+}
+
+type adminCreateRobotRequest struct {
+	Name   string                `json:"name"`
+	Access []storage.RobotAccess `json:"access"`
+}
+
+type adminCreateRobotResponse struct {
+	storage.RobotAccount
+	Token string `json:"token"`
+}
+
+// createRobotAccount creates the robot account described by the request
+// body, with a new token granting the listed access. The token is only
+// returned in the response, as its hash only is stored.
+func (aa *AdminApp) createRobotAccount(w http.ResponseWriter, r *http.Request) {
+	if aa.app.ReadOnly() {
+		serveAdminError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnsupported, "registry is in read-only mode")
+		return
+	}
+
+	var req adminCreateRobotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	token, err := robot.GenerateToken()
+	if err != nil {
+		serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		return
+	}
+
+	account := storage.RobotAccount{
+		Name:      req.Name,
+		TokenHash: robot.HashToken(token),
+		Access:    req.Access,
+		Created:   time.Now().UTC(),
+	}
+	if err := storage.ValidateRobotAccount(account); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+
+	if err := storage.CreateRobotAccount(aa.app, aa.app.driver, account); err != nil {
+		switch err := err.(type) {
+		case storage.ErrRobotAccountExists:
+			serveAdminError(w, http.StatusConflict, v2.ErrorCodeUnknown, err.Error())
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	aa.app.robotAccounts.invalidate(account.Name)
+	ctxu.GetLogger(aa.app).Infof("created robot account %s", account.Name)
+	account.TokenHash = ""
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	serveJSON(w, adminCreateRobotResponse{
+		RobotAccount: account,
+		Token:        token,
+	})
+}
+
+// deleteRobotAccount removes the named robot account, revoking its token.
+func (aa *AdminApp) deleteRobotAccount(w http.ResponseWriter, r *http.Request) {
+	if aa.app.ReadOnly() {
+		serveAdminError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnsupported, "registry is in read-only mode")
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := storage.ValidateRobotAccount(storage.RobotAccount{Name: name}); err != nil {
+		serveAdminError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+
+	if err := storage.DeleteRobotAccount(aa.app, aa.app.driver, name); err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			serveAdminError(w, http.StatusNotFound, v2.ErrorCodeUnknown, fmt.Sprintf("unknown robot account: %s", name))
+		default:
+			serveAdminError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err)
+		}
+		return
+	}
+
+	aa.app.robotAccounts.invalidate(name)
+	ctxu.GetLogger(aa.app).Infof("deleted robot account %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type adminPingResponse struct {
+	Endpoint string `json:"endpoint"`
+	URL      string `json:"url"`
+	EventID  string `json:"eventId"`
+	Accepted bool   `json:"accepted"`
+	Status   int    `json:"status,omitempty"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+// pingEndpoint sends a test event to the named notification endpoint and
+// reports its response, so that operators can check the connectivity and
+// authorization of an endpoint without pushing an image. The response of the
+// endpoint is reported even if it rejects the event.
+func (aa *AdminApp) pingEndpoint(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["endpoint"]
+	endpoint, ok := aa.app.events.endpoints[name]
+	if !ok {
+		serveAdminError(w, http.StatusNotFound, v2.ErrorCodeUnknown, fmt.Sprintf("unknown endpoint: %s", name))
+		return
+	}
+
+	event := notifications.NewPingEvent(aa.app.events.source)
+	result, err := endpoint.Ping(event)
+
+	resp := adminPingResponse{
+		Endpoint: name,
+		URL:      endpoint.URL(),
+		EventID:  event.ID,
+		Accepted: err == nil,
+		Status:   result.Status,
+		Latency:  result.Latency.String(),
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	ctxu.GetLogger(aa.app).Infof("pinged endpoint %s: accepted=%t, status=%d", name, resp.Accepted, resp.Status)
+	serveJSON(w, resp)
+}
+
+// serveAdminError writes an error response with the given status.
+func serveAdminError(w http.ResponseWriter, status int, code v2.ErrorCode, detail interface{}) {
+	var errs v2.Errors
+	errs.Push(code, detail)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	serveJSON(w, errs)
+}
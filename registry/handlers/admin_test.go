@@ -0,0 +1,870 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/notifications"
+	_ "github.com/docker/distribution/registry/auth/anonymous"
+	_ "github.com/docker/distribution/registry/auth/chain"
+	_ "github.com/docker/distribution/registry/auth/silly"
+	"github.com/docker/distribution/registry/storage"
+	"golang.org/x/net/context"
+)
+
+// TestAdminReadOnly toggles read-only mode through the admin interface and
+// checks that uploads are rejected while it is enabled.
+func TestAdminReadOnly(t *testing.T) {
+	env := newTestEnv(t)
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	uploadURL, err := env.builder.BuildBlobUploadURL("foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error building upload url: %v", err)
+	}
+
+	setReadOnly := func(readOnly bool) {
+		body := `{"readOnly": false}`
+		if readOnly {
+			body = `{"readOnly": true}`
+		}
+
+		req, err := http.NewRequest("PUT", adminServer.URL+"/admin/v1/readonly", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error setting read-only mode: %v", err)
+		}
+		defer resp.Body.Close()
+
+		checkResponse(t, "setting read-only mode", resp, http.StatusOK)
+		if env.app.ReadOnly() != readOnly {
+			t.Fatalf("read-only mode not set to %t", readOnly)
+		}
+	}
+
+	setReadOnly(true)
+
+	resp, err := http.Post(uploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "starting upload in read-only mode", resp, http.StatusMethodNotAllowed)
+
+	setReadOnly(false)
+
+	resp, err = http.Post(uploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "starting upload", resp, http.StatusAccepted)
+}
+
+// TestAdminEndpoints exercises the stats, cache flush, gc and reload
+// endpoints of the admin interface.
+func TestAdminEndpoints(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"cache": configuration.Parameters{
+				"layerinfo": "inmemory",
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	reloaded := config
+	reloaded.Storage = configuration.Storage{
+		"inmemory": configuration.Parameters{},
+		"maintenance": configuration.Parameters{
+			"readonly": map[interface{}]interface{}{
+				"enabled": true,
+			},
+		},
+	}
+	reloaded.Loglevel = "info"
+
+	adminApp, err := NewAdminApp(env.app, func() (*configuration.Configuration, error) {
+		return &reloaded, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	resp, err := http.Get(adminServer.URL + "/admin/v1/stats")
+	if err != nil {
+		t.Fatalf("unexpected error getting stats: %v", err)
+	}
+	checkResponse(t, "getting stats", resp, http.StatusOK)
+
+	var stats adminStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("unexpected error decoding stats: %v", err)
+	}
+	resp.Body.Close()
+
+	if stats.Uptime == "" || stats.Goroutines == 0 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+
+	resp, err = http.Post(adminServer.URL+"/admin/v1/cache/flush", "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error flushing cache: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "flushing cache", resp, http.StatusNoContent)
+
+	resp, err = http.Post(adminServer.URL+"/admin/v1/gc?dryrun=true&age=1h", "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error running gc: %v", err)
+	}
+	checkResponse(t, "running gc", resp, http.StatusOK)
+
+	var gc adminGCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gc); err != nil {
+		t.Fatalf("unexpected error decoding gc response: %v", err)
+	}
+	resp.Body.Close()
+
+	if !gc.DryRun {
+		t.Fatalf("gc did not run as a dry run: %#v", gc)
+	}
+
+	resp, err = http.Get(adminServer.URL + "/admin/v1/blobs?digest=invalid")
+	if err != nil {
+		t.Fatalf("unexpected error getting blob: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "getting blob with invalid digest", resp, http.StatusBadRequest)
+
+	unknownDigest := "sha256:" + strings.Repeat("0", 64)
+	resp, err = http.Get(adminServer.URL + "/admin/v1/blobs?digest=" + unknownDigest)
+	if err != nil {
+		t.Fatalf("unexpected error getting blob: %v", err)
+	}
+	checkResponse(t, "getting unknown blob", resp, http.StatusOK)
+
+	var blob adminBlobResponse
+	if err := json.NewDecoder(resp.Body).Decode(&blob); err != nil {
+		t.Fatalf("unexpected error decoding blob response: %v", err)
+	}
+	resp.Body.Close()
+
+	if blob.Digest.String() != unknownDigest || blob.Stored || len(blob.Repositories) != 0 {
+		t.Fatalf("unexpected unknown blob response: %#v", blob)
+	}
+
+	resp, err = http.Get(adminServer.URL + "/admin/v1/tags/history?repository=foo/bar&tag=latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting tag history: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "getting history of unknown tag", resp, http.StatusNotFound)
+
+	resp, err = http.Get(adminServer.URL + "/admin/v1/tags/history?repository=foo/bar&tag=../latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting tag history: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "getting history of invalid tag", resp, http.StatusBadRequest)
+
+	resp, err = http.Post(adminServer.URL+"/admin/v1/config/reload", "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error reloading configuration: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "reloading configuration", resp, http.StatusOK)
+
+	if !env.app.ReadOnly() {
+		t.Fatalf("read-only mode not enabled by reloaded configuration")
+	}
+}
+
+// TestAdminAuth checks that the admin interface challenges unauthenticated
+// requests when an access controller is configured.
+func TestAdminAuth(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.Admin.Auth = configuration.Auth{
+		"silly": configuration.Parameters{
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+	app := NewApp(context.Background(), config)
+
+	adminApp, err := NewAdminApp(app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://example.com/admin/v1/stats", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	adminApp.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code: %d != %d", recorder.Code, http.StatusUnauthorized)
+	}
+
+	recorder = httptest.NewRecorder()
+	req.Header.Set("Authorization", "Bearer admin")
+	adminApp.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d != %d", recorder.Code, http.StatusOK)
+	}
+}
+
+// TestAdminWithoutAuth checks that the admin interface without an access
+// controller only listens and serves requests locally.
+func TestAdminWithoutAuth(t *testing.T) {
+	for _, testcase := range []struct {
+		net, addr string
+		local     bool
+	}{
+		{"", "localhost:5002", true},
+		{"tcp", "127.0.0.1:5002", true},
+		{"tcp", "[::1]:5002", true},
+		{"unix", "/run/registry/admin.sock", true},
+		{"", ":5002", false},
+		{"tcp", "0.0.0.0:5002", false},
+		{"tcp", "10.0.0.1:5002", false},
+		{"systemd", "", false},
+	} {
+		config := configuration.Configuration{
+			Storage: configuration.Storage{
+				"inmemory": configuration.Parameters{},
+			},
+		}
+		config.Admin.Net = testcase.net
+		config.Admin.Addr = testcase.addr
+
+		_, err := NewAdminApp(NewApp(context.Background(), config), nil)
+		if testcase.local && err != nil {
+			t.Fatalf("unexpected error creating admin app on %s %s: %v", testcase.net, testcase.addr, err)
+		} else if !testcase.local && err == nil {
+			t.Fatalf("expected error creating admin app without auth on %s %s", testcase.net, testcase.addr)
+		}
+	}
+
+	env := newTestEnv(t)
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"127.0.0.1:1234", http.StatusOK},
+		{"[::1]:1234", http.StatusOK},
+		{"192.0.2.1:1234", http.StatusForbidden},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/admin/v1/stats", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		req.RemoteAddr = testcase.remoteAddr
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+
+		recorder := httptest.NewRecorder()
+		adminApp.ServeHTTP(recorder, req)
+		if recorder.Code != testcase.status {
+			t.Fatalf("unexpected status code from %s: %d != %d", testcase.remoteAddr, recorder.Code, testcase.status)
+		}
+	}
+}
+
+// TestAdminPingEndpoint pings notification endpoints through the admin
+// interface and checks that their responses are reported.
+func TestAdminPingEndpoint(t *testing.T) {
+	var received []notifications.Event
+	listener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var envelope notifications.Envelope
+		if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+			t.Errorf("unexpected error decoding envelope: %v", err)
+		}
+		received = append(received, envelope.Events...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer listener.Close()
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Notifications: configuration.Notifications{
+			Endpoints: []configuration.Endpoint{
+				{
+					Name:    "authorized",
+					URL:     listener.URL,
+					Headers: http.Header{"Authorization": []string{"Bearer secret"}},
+				},
+				{
+					Name: "unauthorized",
+					URL:  listener.URL,
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	ping := func(endpoint string, expectedStatus int) adminPingResponse {
+		resp, err := http.Post(adminServer.URL+"/admin/v1/notifications/endpoints/"+endpoint+"/ping", "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error pinging %s: %v", endpoint, err)
+		}
+		defer resp.Body.Close()
+
+		checkResponse(t, "pinging "+endpoint, resp, expectedStatus)
+
+		var pingResp adminPingResponse
+		if expectedStatus == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&pingResp); err != nil {
+				t.Fatalf("unexpected error decoding ping response: %v", err)
+			}
+		}
+		return pingResp
+	}
+
+	resp := ping("authorized", http.StatusOK)
+	if !resp.Accepted || resp.Status != http.StatusAccepted || resp.Error != "" {
+		t.Fatalf("unexpected response to accepted ping: %#v", resp)
+	}
+	if len(received) != 1 || received[0].Action != notifications.EventActionPing || received[0].ID != resp.EventID {
+		t.Fatalf("unexpected events received by endpoint: %#v", received)
+	}
+
+	resp = ping("unauthorized", http.StatusOK)
+	if resp.Accepted || resp.Status != http.StatusUnauthorized || resp.Error == "" {
+		t.Fatalf("unexpected response to rejected ping: %#v", resp)
+	}
+
+	ping("unknown", http.StatusNotFound)
+}
+
+// TestAdminRenameRepository renames a repository through the admin
+// interface and checks that its layers are served under the new name.
+func TestAdminRenameRepository(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"cache": configuration.Parameters{
+				"layerinfo": "inmemory",
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	content := []byte("renamed layer")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting layer: %v", err)
+	}
+
+	for _, name := range []string{"foo/bar", "baz"} {
+		uploadURLBase, _ := startPushLayer(t, env.builder, name)
+		pushLayer(t, env.builder, name, dgst, uploadURLBase, bytes.NewReader(content))
+	}
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	rename := func(from, to string, expectedStatus int) {
+		resp, err := http.Post(adminServer.URL+"/admin/v1/repositories/rename?from="+from+"&to="+to, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error renaming %s: %v", from, err)
+		}
+		defer resp.Body.Close()
+
+		checkResponse(t, "renaming "+from+" to "+to, resp, expectedStatus)
+	}
+
+	head := func(name string, expectedStatus int) {
+		layerURL, err := env.builder.BuildBlobURL(name, dgst)
+		if err != nil {
+			t.Fatalf("unexpected error building layer url: %v", err)
+		}
+
+		resp, err := http.Head(layerURL)
+		if err != nil {
+			t.Fatalf("unexpected error checking layer of %s: %v", name, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "checking layer of "+name, resp, expectedStatus)
+	}
+
+	head("foo/bar", http.StatusOK)
+	rename("foo/bar", "team/bar", http.StatusOK)
+	head("team/bar", http.StatusOK)
+	head("foo/bar", http.StatusNotFound)
+
+	rename("team/bar", "baz", http.StatusConflict)
+	rename("foo/bar", "qux", http.StatusNotFound)
+	rename("team/bar", "-invalid", http.StatusBadRequest)
+}
+
+// TestAdminTrash restores a deleted tag from the trash through the admin
+// interface.
+func TestAdminTrash(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{
+				"uploadpurging": map[interface{}]interface{}{
+					"enabled": false,
+				},
+				"trash": map[interface{}]interface{}{
+					"enabled":   true,
+					"retention": "1h",
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	if env.app.trashRetention != time.Hour {
+		t.Fatalf("unexpected trash retention: %s", env.app.trashRetention)
+	}
+
+	repo, err := env.app.registry.Repository(env.ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	sm, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: "foo/bar",
+		Tag:  "latest",
+	}, env.pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if err := storage.TrashTag(env.ctx, env.app.driver, env.app.nameRules, "foo/bar", "latest"); err != nil {
+		t.Fatalf("unexpected error trashing tag: %v", err)
+	}
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	resp, err := http.Get(adminServer.URL + "/admin/v1/trash?repository=foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting trash: %v", err)
+	}
+	checkResponse(t, "getting trash", resp, http.StatusOK)
+
+	var trash adminTrashResponse
+	if err := json.NewDecoder(resp.Body).Decode(&trash); err != nil {
+		t.Fatalf("unexpected error decoding trash response: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(trash.Tags) != 1 || trash.Tags[0].Tag != "latest" {
+		t.Fatalf("unexpected trash response: %#v", trash)
+	}
+
+	restore := func(tag string, expectedStatus int) {
+		resp, err := http.Post(adminServer.URL+"/admin/v1/trash/restore?repository=foo/bar&tag="+tag, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error restoring %s: %v", tag, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "restoring "+tag, resp, expectedStatus)
+	}
+
+	restore("latest", http.StatusOK)
+	restore("latest", http.StatusNotFound)
+	restore("../latest", http.StatusBadRequest)
+
+	if exists, err := repo.Manifests().ExistsByTag("latest"); err != nil || !exists {
+		t.Fatalf("expected restored tag to exist: %v, %v", exists, err)
+	}
+}
+
+// TestAdminRepositoryMetadata sets the visibility of a repository through the
+// admin interface and checks that anonymous pulls are only granted on public
+// repositories.
+func TestAdminRepositoryMetadata(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Auth: configuration.Auth{
+			"chain": configuration.Parameters{
+				"controllers": []interface{}{
+					map[interface{}]interface{}{
+						"silly": map[interface{}]interface{}{
+							"realm":   "realm-test",
+							"service": "service-test",
+						},
+					},
+					map[interface{}]interface{}{
+						"anonymous": map[interface{}]interface{}{
+							"publiconly": true,
+						},
+					},
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	pull := func(name string, expectedStatus int) {
+		tagsURL, err := env.builder.BuildTagsURL(name)
+		if err != nil {
+			t.Fatalf("unexpected error building tags url: %v", err)
+		}
+
+		resp, err := http.Get(tagsURL)
+		if err != nil {
+			t.Fatalf("unexpected error getting tags of %s: %v", name, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "anonymously getting tags of "+name, resp, expectedStatus)
+	}
+
+	putMetadata := func(name, body string, expectedStatus int) {
+		req, err := http.NewRequest("PUT", adminServer.URL+"/admin/v1/repositories/metadata?repository="+name, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error setting metadata of %s: %v", name, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "setting metadata of "+name, resp, expectedStatus)
+	}
+
+	pull("foo/bar", http.StatusUnauthorized)
+
+	putMetadata("foo/bar", `{"public": true, "description": "public images"}`, http.StatusOK)
+	putMetadata("foo/bar", `not json`, http.StatusBadRequest)
+	putMetadata("-invalid", `{"public": true}`, http.StatusBadRequest)
+
+	resp, err := http.Get(adminServer.URL + "/admin/v1/repositories/metadata?repository=foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting metadata: %v", err)
+	}
+	checkResponse(t, "getting metadata", resp, http.StatusOK)
+
+	var metadata adminMetadataResponse
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		t.Fatalf("unexpected error decoding metadata response: %v", err)
+	}
+	resp.Body.Close()
+
+	if metadata.Repository != "foo/bar" || !metadata.Public || metadata.Description != "public images" {
+		t.Fatalf("unexpected metadata response: %#v", metadata)
+	}
+
+	// The repository has no tags, but anonymous clients may now see that.
+	pull("foo/bar", http.StatusNotFound)
+	pull("foo/private", http.StatusUnauthorized)
+
+	putMetadata("foo/bar", `{"public": false}`, http.StatusOK)
+	pull("foo/bar", http.StatusUnauthorized)
+
+	// Without admin auth, remote clients cannot make repositories public.
+	req, err := http.NewRequest("PUT", "http://example.com/admin/v1/repositories/metadata?repository=foo/bar", strings.NewReader(`{"public": true}`))
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	recorder := httptest.NewRecorder()
+	adminApp.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status code setting metadata remotely: %d != %d", recorder.Code, http.StatusForbidden)
+	}
+	pull("foo/bar", http.StatusUnauthorized)
+}
+
+// TestAdminRobotAccounts creates a robot account through the admin interface
+// and checks that its token is only accepted for the access it is granted,
+// until the account is deleted.
+func TestAdminRobotAccounts(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Auth: configuration.Auth{
+			"robot": configuration.Parameters{},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	create := func(body string, expectedStatus int) *http.Response {
+		resp, err := http.Post(adminServer.URL+"/admin/v1/robots", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error creating robot account: %v", err)
+		}
+
+		checkResponse(t, "creating robot account", resp, expectedStatus)
+		return resp
+	}
+
+	resp := create(`{"name": "ci", "access": [{"repository": "team/*", "actions": ["pull"]}]}`, http.StatusCreated)
+	var created adminCreateRobotResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("unexpected error decoding robot account response: %v", err)
+	}
+	resp.Body.Close()
+
+	if created.Name != "ci" || created.Token == "" || created.TokenHash != "" {
+		t.Fatalf("unexpected robot account response: %#v", created)
+	}
+
+	create(`{"name": "ci", "access": []}`, http.StatusConflict).Body.Close()
+	create(`{"name": "Bad Name"}`, http.StatusBadRequest).Body.Close()
+
+	resp, err = http.Get(adminServer.URL + "/admin/v1/robots")
+	if err != nil {
+		t.Fatalf("unexpected error listing robot accounts: %v", err)
+	}
+	checkResponse(t, "listing robot accounts", resp, http.StatusOK)
+
+	var listed adminRobotAccountsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("unexpected error decoding robot accounts response: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(listed.Robots) != 1 || listed.Robots[0].Name != "ci" || listed.Robots[0].TokenHash != "" {
+		t.Fatalf("unexpected robot accounts response: %#v", listed)
+	}
+
+	getTags := func(name, user, token string, expectedStatus int) {
+		tagsURL, err := env.builder.BuildTagsURL(name)
+		if err != nil {
+			t.Fatalf("unexpected error building tags url: %v", err)
+		}
+
+		req, err := http.NewRequest("GET", tagsURL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		req.SetBasicAuth(user, token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error getting tags of %s: %v", name, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "getting tags of "+name+" as robot", resp, expectedStatus)
+	}
+
+	// The repository has no tags, but the robot may now see that.
+	getTags("team/app", "ci", created.Token, http.StatusNotFound)
+	getTags("team/app", "ci", "wrong", http.StatusUnauthorized)
+	getTags("other/app", "ci", created.Token, http.StatusUnauthorized)
+
+	// Names which cannot be robot accounts are challenged like unknown ones.
+	getTags("team/app", "Alice", created.Token, http.StatusUnauthorized)
+
+	uploadURL, err := env.builder.BuildBlobUploadURL("team/app")
+	if err != nil {
+		t.Fatalf("unexpected error building upload url: %v", err)
+	}
+	req, err := http.NewRequest("POST", uploadURL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+	req.SetBasicAuth("ci", created.Token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "pushing as pull-only robot", resp, http.StatusUnauthorized)
+
+	deleteRobot := func(name string, expectedStatus int) {
+		req, err := http.NewRequest("DELETE", adminServer.URL+"/admin/v1/robots/"+name, nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error deleting robot account: %v", err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, "deleting robot account "+name, resp, expectedStatus)
+	}
+
+	deleteRobot("ci", http.StatusNoContent)
+	deleteRobot("ci", http.StatusNotFound)
+	getTags("team/app", "ci", created.Token, http.StatusUnauthorized)
+}
+
+// TestAdminRobotAccountsUnauthenticated checks that robot accounts cannot be
+// created without admin credentials, nor remotely without admin auth.
+func TestAdminRobotAccountsUnauthenticated(t *testing.T) {
+	create := func(adminApp *AdminApp, remoteAddr string, expectedStatus int) {
+		body := `{"name": "ci", "access": [{"repository": "*", "actions": ["*"]}]}`
+		req, err := http.NewRequest("POST", "http://example.com/admin/v1/robots", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		req.RemoteAddr = remoteAddr
+
+		recorder := httptest.NewRecorder()
+		adminApp.ServeHTTP(recorder, req)
+		if recorder.Code != expectedStatus {
+			t.Fatalf("unexpected status code creating robot account from %s: %d != %d", remoteAddr, recorder.Code, expectedStatus)
+		}
+	}
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.Admin.Auth = configuration.Auth{
+		"silly": configuration.Parameters{
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+	app := NewApp(context.Background(), config)
+
+	adminApp, err := NewAdminApp(app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	create(adminApp, "127.0.0.1:1234", http.StatusUnauthorized)
+
+	config.Admin.Auth = nil
+	app = NewApp(context.Background(), config)
+
+	adminApp, err = NewAdminApp(app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	create(adminApp, "192.0.2.1:1234", http.StatusForbidden)
+
+	accounts, err := storage.RobotAccounts(app, app.driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing robot accounts: %v", err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("unexpected robot accounts created without auth: %#v", accounts)
+	}
+}
+
+// TestAdminRelaxedNames checks that the admin endpoints accept the names
+// allowed by relaxed name rules, which the default rules reject.
+func TestAdminRelaxedNames(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.Validation.Names.Component = `[a-zA-Z0-9]+(?:[._-][a-zA-Z0-9]+)*`
+	env := newTestEnvWithConfig(t, &config)
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating admin app: %v", err)
+	}
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	for _, testcase := range []struct {
+		method, path string
+		status       int
+	}{
+		{"PUT", "/admin/v1/repositories/metadata?repository=Team/App", http.StatusOK},
+		{"GET", "/admin/v1/repositories/metadata?repository=Team/App", http.StatusOK},
+		{"GET", "/admin/v1/trash?repository=Team/App", http.StatusOK},
+		{"PUT", "/admin/v1/repositories/metadata?repository=Team/_manifests", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(testcase.method, adminServer.URL+testcase.path, strings.NewReader(`{"public": true}`))
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error requesting %s %s: %v", testcase.method, testcase.path, err)
+		}
+		resp.Body.Close()
+
+		checkResponse(t, testcase.method+" "+testcase.path, resp, testcase.status)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+)
+
+// manifestAdmissionRequest is the body posted to manifest admission hooks.
+type manifestAdmissionRequest struct {
+	Repository string          `json:"repository"`
+	Tag        string          `json:"tag,omitempty"`
+	Digest     digest.Digest   `json:"digest"`
+	Manifest   json.RawMessage `json:"manifest"`
+}
+
+// configureAdmission sets up the admission hooks of pushed manifests.
+func (app *App) configureAdmission(config configuration.ManifestValidation) {
+	for _, hookConfig := range config.Admission {
+		app.admissionHooks = append(app.admissionHooks, newValidationHook(hookConfig))
+	}
+
+	if len(app.admissionHooks) > 0 {
+		ctxu.GetLogger(app).Infof("admitting manifests with %d hooks", len(app.admissionHooks))
+	}
+}
+
+// admitManifest posts the pushed manifest to the admission hooks, in order,
+// so that they enforce the policies of the registry, such as required labels
+// or allowed base images. If a hook rejects the manifest, the error is
+// reported in the response and false is returned.
+func (imh *imageManifestHandler) admitManifest(w http.ResponseWriter, sm *manifest.SignedManifest) bool {
+	if len(imh.App.admissionHooks) == 0 {
+		return true
+	}
+
+	p, err := json.Marshal(manifestAdmissionRequest{
+		Repository: imh.Repository.Name(),
+		Tag:        sm.Tag,
+		Digest:     imh.Digest,
+		Manifest:   json.RawMessage(sm.Raw),
+	})
+	if err != nil {
+		imh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	for _, hook := range imh.App.admissionHooks {
+		err := hook.post(bytes.NewReader(p), http.Header{
+			"Content-Type": []string{"application/json"},
+		})
+		if err == nil {
+			continue
+		}
+
+		err = hook.enforce(imh, err, map[string]interface{}{"digest": imh.Digest})
+		if err == nil {
+			continue
+		}
+
+		if _, ok := err.(hookRejection); ok {
+			imh.Errors.Push(v2.ErrorCodeManifestInvalid, err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			imh.Errors.Push(v2.ErrorCodeUnknown, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	return true
+}
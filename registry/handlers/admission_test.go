@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+)
+
+// TestManifestAdmission pushes manifests to a registry with an admission
+// hook denying one of the tags.
+func TestManifestAdmission(t *testing.T) {
+	var admitted []manifestAdmissionRequest
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req manifestAdmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("unexpected error decoding admission request: %v", err)
+		}
+		admitted = append(admitted, req)
+
+		if req.Tag == "denied" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("missing maintainer label"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Validation: configuration.Validation{
+			Manifests: configuration.ManifestValidation{
+				Admission: []configuration.ValidationHook{
+					{Name: "policy", URL: hook.URL},
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	for _, tag := range []string{"allowed", "denied"} {
+		sm, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: "foo/bar",
+			Tag:  tag,
+		}, env.pk)
+		checkErr(t, err, "signing manifest")
+
+		manifestURL, err := env.builder.BuildManifestURL("foo/bar", tag)
+		checkErr(t, err, "building manifest url")
+
+		resp := putManifest(t, "putting manifest", manifestURL, sm)
+		defer resp.Body.Close()
+
+		if tag == "denied" {
+			checkResponse(t, "putting denied manifest", resp, http.StatusBadRequest)
+			checkBodyHasErrorCodes(t, "putting denied manifest", resp, v2.ErrorCodeManifestInvalid)
+		} else {
+			checkResponse(t, "putting allowed manifest", resp, http.StatusAccepted)
+		}
+
+		req := admitted[len(admitted)-1]
+		if req.Repository != "foo/bar" || req.Tag != tag || req.Digest == "" {
+			t.Fatalf("unexpected admission request: %#v", req)
+		}
+
+		var admittedManifest manifest.SignedManifest
+		if err := json.Unmarshal(req.Manifest, &admittedManifest); err != nil {
+			t.Fatalf("unexpected error decoding admitted manifest: %v", err)
+		}
+		if admittedManifest.Tag != tag {
+			t.Fatalf("unexpected admitted manifest: %#v", admittedManifest)
+		}
+	}
+
+	resp, err := http.Get(env.server.URL + "/v2/foo/bar/manifests/denied")
+	checkErr(t, err, "fetching denied manifest")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching denied manifest", resp, http.StatusNotFound)
+}
@@ -15,13 +15,17 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/cache"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
+	"github.com/docker/distribution/version"
 	"github.com/docker/libtrust"
 	"github.com/gorilla/handlers"
 	"golang.org/x/net/context"
@@ -59,6 +63,213 @@ func TestCheckAPI(t *testing.T) {
 	}
 }
 
+// TestInfoAPI hits the info endpoint (/v2/_info) and ensures that it reports
+// the registry version and capabilities.
+func TestInfoAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	infoURL, err := env.builder.BuildInfoURL()
+	if err != nil {
+		t.Fatalf("unexpected error building info url: %v", err)
+	}
+
+	resp, err := http.Get(infoURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing info request", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Type": []string{"application/json; charset=utf-8"},
+	})
+
+	var info infoAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("unexpected error decoding info response: %v", err)
+	}
+
+	if info.Version != version.Version {
+		t.Fatalf("unexpected version: %q != %q", info.Version, version.Version)
+	}
+
+	if info.Storage.Driver != "inmemory" {
+		t.Fatalf("unexpected storage driver: %q", info.Storage.Driver)
+	}
+
+	if info.Extensions.Delete || info.Extensions.Redirect || info.Extensions.Proxy {
+		t.Fatalf("unexpected extensions: %#v", info.Extensions)
+	}
+
+	if len(info.ManifestMediaTypes) == 0 || info.ManifestMediaTypes[0] != manifest.ManifestMediaType {
+		t.Fatalf("unexpected manifest media types: %v", info.ManifestMediaTypes)
+	}
+}
+
+// TestNamespacesAPI lists the namespaces of the repository index and checks
+// that listing them requires access to the catalog.
+func TestNamespacesAPI(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"index":    configuration.Parameters{"enabled": true},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	for _, name := range []string{"foo/bar", "foo/baz/qux", "other"} {
+		if err := env.app.driver.PutContent(env.ctx, "/docker/registry/v2/repositories/"+name+"/_manifests/tags/latest/current/link", []byte("sha256:"+strings.Repeat("0", 64))); err != nil {
+			t.Fatalf("unexpected error creating repository %s: %v", name, err)
+		}
+	}
+	if _, err := storage.RebuildRepositoryIndex(env.ctx, env.app.driver); err != nil {
+		t.Fatalf("unexpected error indexing repositories: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		parent     string
+		namespaces []storage.NamespaceCount
+	}{
+		{namespaces: []storage.NamespaceCount{{Name: "foo", Repositories: 2}}},
+		{parent: "foo", namespaces: []storage.NamespaceCount{{Name: "foo/baz", Repositories: 1}}},
+		{parent: "other", namespaces: []storage.NamespaceCount{}},
+	} {
+		namespacesURL, err := env.builder.BuildNamespacesURL(url.Values{"parent": []string{testcase.parent}})
+		if err != nil {
+			t.Fatalf("unexpected error building namespaces url: %v", err)
+		}
+
+		resp, err := http.Get(namespacesURL)
+		if err != nil {
+			t.Fatalf("unexpected error issuing request: %v", err)
+		}
+
+		checkResponse(t, "listing namespaces", resp, http.StatusOK)
+
+		var namespaces namespacesAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&namespaces); err != nil {
+			t.Fatalf("unexpected error decoding namespaces response: %v", err)
+		}
+		resp.Body.Close()
+
+		if namespaces.Parent != testcase.parent || !reflect.DeepEqual(namespaces.Namespaces, testcase.namespaces) {
+			t.Fatalf("unexpected namespaces of %q: %#v", testcase.parent, namespaces)
+		}
+	}
+
+	namespacesURL, err := env.builder.BuildNamespacesURL(url.Values{"parent": []string{"-invalid"}})
+	if err != nil {
+		t.Fatalf("unexpected error building namespaces url: %v", err)
+	}
+
+	resp, err := http.Get(namespacesURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	checkResponse(t, "listing namespaces of invalid parent", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "listing namespaces of invalid parent", resp, v2.ErrorCodeNameInvalid)
+	resp.Body.Close()
+
+	config.Auth = configuration.Auth{
+		"silly": configuration.Parameters{
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+	env = newTestEnvWithConfig(t, &config)
+
+	namespacesURL, err = env.builder.BuildNamespacesURL()
+	if err != nil {
+		t.Fatalf("unexpected error building namespaces url: %v", err)
+	}
+
+	resp, err = http.Get(namespacesURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	resp.Body.Close()
+
+	checkResponse(t, "listing namespaces without authorization", resp, http.StatusUnauthorized)
+	if challenge := resp.Header.Get("WWW-Authenticate"); !strings.Contains(challenge, `scope="registry:catalog:*"`) {
+		t.Fatalf("unexpected challenge: %q", challenge)
+	}
+}
+
+// TestTrustServer checks that the configured trust server is advertised on
+// the base route, its authentication challenges and the info route.
+func TestTrustServer(t *testing.T) {
+	const trustServer = "https://notary.example.com"
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Trust: configuration.Trust{Server: trustServer + "/"},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	baseURL, err := env.builder.BuildBaseURL()
+	if err != nil {
+		t.Fatalf("unexpected error building base url: %v", err)
+	}
+
+	resp, err := http.Get(baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing api base check", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Trust-Server": []string{trustServer},
+	})
+
+	infoURL, err := env.builder.BuildInfoURL()
+	if err != nil {
+		t.Fatalf("unexpected error building info url: %v", err)
+	}
+
+	resp, err = http.Get(infoURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info infoAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("unexpected error decoding info response: %v", err)
+	}
+
+	if info.TrustServer != trustServer {
+		t.Fatalf("unexpected trust server: %q != %q", info.TrustServer, trustServer)
+	}
+
+	// Clients not logged in yet find the trust server in the challenge.
+	config.Auth = configuration.Auth{
+		"silly": configuration.Parameters{
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+	env = newTestEnvWithConfig(t, &config)
+
+	baseURL, err = env.builder.BuildBaseURL()
+	if err != nil {
+		t.Fatalf("unexpected error building base url: %v", err)
+	}
+
+	resp, err = http.Get(baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing unauthorized api base check", resp, http.StatusUnauthorized)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Trust-Server": []string{trustServer},
+	})
+}
+
 func TestURLPrefix(t *testing.T) {
 	config := configuration.Configuration{
 		Storage: configuration.Storage{
@@ -93,6 +304,54 @@ func TestURLPrefix(t *testing.T) {
 
 }
 
+// TestURLPrefixLocation checks that the urls returned to clients include the
+// path prefix, even when it contains the api version and lacks a trailing
+// slash.
+func TestURLPrefixLocation(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.HTTP.Prefix = "/registry/v2"
+
+	env := newTestEnvWithConfig(t, &config)
+
+	builder, err := v2.NewURLBuilderFromString(env.server.URL + "/registry/v2/")
+	if err != nil {
+		t.Fatalf("error creating url builder: %v", err)
+	}
+
+	uploadURLBase, _ := startPushLayer(t, builder, "foo/bar")
+	if !strings.HasPrefix(uploadURLBase, env.server.URL+"/registry/v2/v2/foo/bar/blobs/uploads/") {
+		t.Fatalf("unexpected upload location: %s", uploadURLBase)
+	}
+
+	content := []byte("prefixed layer")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting layer: %v", err)
+	}
+
+	layerURL := pushLayer(t, builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+	expectedLayerURL, err := builder.BuildBlobURL("foo/bar", dgst)
+	if err != nil {
+		t.Fatalf("error building expected layer url: %v", err)
+	}
+
+	if layerURL != expectedLayerURL {
+		t.Fatalf("unexpected layer location: %s != %s", layerURL, expectedLayerURL)
+	}
+
+	resp, err := http.Get(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching prefixed layer", resp, http.StatusOK)
+}
+
 // TestLayerAPI conducts a full test of the of the layer api.
 func TestLayerAPI(t *testing.T) {
 	// TODO(stevvooe): This test code is complete junk but it should cover the
@@ -437,6 +696,18 @@ func TestManifestAPI(t *testing.T) {
 		t.Fatalf("manifests do not match")
 	}
 
+	// ----------------------------
+	// Resolve tag with HEAD request
+	resp, err = http.Head(manifestURL)
+	checkErr(t, err, "resolving manifest tag")
+	defer resp.Body.Close()
+
+	checkResponse(t, "resolving manifest tag", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length":        []string{fmt.Sprint(len(signedManifest.Raw))},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
 	// Ensure that the tag is listed.
 	resp, err = http.Get(tagsURL)
 	if err != nil {
@@ -467,6 +738,281 @@ func TestManifestAPI(t *testing.T) {
 	}
 }
 
+// TestLayerAPINameRules pushes a layer to a repository whose name is only
+// valid under the configured name rules.
+func TestLayerAPINameRules(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Validation: configuration.Validation{
+			Names: configuration.NameValidation{
+				Component: "[a-zA-Z0-9]+(?:[._-][a-zA-Z0-9]+)*",
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+	builder := env.builder.WithNameRules(env.app.nameRules)
+	imageName := "Internal/Tooling"
+
+	rs, dgstStr, err := testutil.CreateRandomTarFile()
+	checkErr(t, err, "creating random layer")
+	layerDigest := digest.Digest(dgstStr)
+
+	uploadURLBase, _ := startPushLayer(t, builder, imageName)
+	layerURL := pushLayer(t, builder, imageName, layerDigest, uploadURLBase, rs)
+
+	resp, err := http.Head(layerURL)
+	checkErr(t, err, "checking head on pushed layer")
+	defer resp.Body.Close()
+	checkResponse(t, "checking head on pushed layer", resp, http.StatusOK)
+
+	// Names must still follow the other rules.
+	layerUploadURL, err := builder.BuildBlobUploadURL("Internal/T")
+	checkErr(t, err, "building layer upload url")
+
+	resp, err = http.Post(layerUploadURL, "", nil)
+	checkErr(t, err, "starting layer push")
+	defer resp.Body.Close()
+	checkResponse(t, "starting layer push with short name", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "starting layer push with short name", resp, v2.ErrorCodeNameInvalid)
+}
+
+// TestLayerAPIMonolithicUpload pushes layers in a single POST request.
+func TestLayerAPIMonolithicUpload(t *testing.T) {
+	env := newTestEnv(t)
+
+	content := []byte("monolithic layer content")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting layer: %v", err)
+	}
+
+	post := func(digestParam string, body []byte) *http.Response {
+		uploadURL, err := env.builder.BuildBlobUploadURL("foo/bar", url.Values{
+			"digest": []string{digestParam},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error building upload url: %v", err)
+		}
+
+		resp, err := http.Post(uploadURL, "application/octet-stream", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("unexpected error posting layer: %v", err)
+		}
+		return resp
+	}
+
+	// A mismatched digest is rejected.
+	resp := post("sha256:"+strings.Repeat("0", 64), content)
+	defer resp.Body.Close()
+	checkResponse(t, "posting layer with mismatched digest", resp, http.StatusBadRequest)
+	errs, _, _ := checkBodyHasErrorCodes(t, "posting layer with mismatched digest", resp, v2.ErrorCodeDigestInvalid)
+
+	// The detail reports the digest of the content.
+	if detail, ok := errs.Errors[0].Detail.(map[string]interface{}); !ok || detail["received"] != dgst.String() {
+		t.Fatalf("unexpected detail of mismatched digest: %#v", errs.Errors[0].Detail)
+	}
+
+	if errs.RequestID == "" {
+		t.Fatalf("expected the request id in the error response")
+	}
+
+	// An invalid digest is rejected before the upload starts.
+	resp = post("invalid", content)
+	defer resp.Body.Close()
+	checkResponse(t, "posting layer with invalid digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "posting layer with invalid digest", resp, v2.ErrorCodeDigestInvalid)
+
+	resp = post(dgst.String(), content)
+	defer resp.Body.Close()
+	checkResponse(t, "posting layer", resp, http.StatusCreated)
+
+	layerURL, err := env.builder.BuildBlobURL("foo/bar", dgst)
+	if err != nil {
+		t.Fatalf("unexpected error building layer url: %v", err)
+	}
+
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{layerURL},
+		"Content-Length":        []string{"0"},
+		"Docker-Content-Digest": []string{dgst.String()},
+		"Docker-Upload-UUID":    []string{"*"},
+	})
+
+	resp, err = http.Get(layerURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching posted layer", resp, http.StatusOK)
+	fetched, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error reading layer: %v", err)
+	}
+
+	if !bytes.Equal(fetched, content) {
+		t.Fatalf("fetched layer does not match posted content: %q != %q", fetched, content)
+	}
+}
+
+// TestManifestAPIDigestAlgorithm pushes an image to a registry with sha512
+// canonical digests and checks that the manifest is identified by its sha512
+// digest.
+func TestManifestAPIDigestAlgorithm(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"digest": configuration.Parameters{
+				"algorithm": "sha512",
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/bar"
+
+	rs, dgstStr, err := testutil.CreateRandomTarFile()
+	checkErr(t, err, "creating random layer")
+	layerDigest := digest.Digest(dgstStr)
+
+	layerContent, err := ioutil.ReadAll(rs)
+	checkErr(t, err, "reading random layer")
+
+	canonicalLayerDigest, err := digest.FromBytesWithAlgorithm("sha512", layerContent)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+	resp, err := doPushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, bytes.NewReader(layerContent))
+	checkErr(t, err, "pushing layer")
+	defer resp.Body.Close()
+	checkResponse(t, "pushing layer", resp, http.StatusCreated)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{canonicalLayerDigest.String()},
+	})
+
+	signedManifest, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: imageName,
+		Tag:  "latest",
+		FSLayers: []manifest.FSLayer{
+			{BlobSum: layerDigest},
+		},
+	}, env.pk)
+	checkErr(t, err, "signing manifest")
+
+	payload, err := signedManifest.Payload()
+	checkErr(t, err, "getting manifest payload")
+
+	sha256Digest, err := digest.FromBytes(payload)
+	checkErr(t, err, "digesting manifest")
+
+	sha512Digest, err := digest.FromBytesWithAlgorithm("sha512", payload)
+	checkErr(t, err, "digesting manifest")
+
+	// Manifests can not be pushed by a digest that is not canonical.
+	manifestDigestURL, err := env.builder.BuildManifestURL(imageName, sha256Digest.String())
+	checkErr(t, err, "building manifest url")
+
+	resp = putManifest(t, "putting manifest by sha256 digest", manifestDigestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest by sha256 digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "putting manifest by sha256 digest", resp, v2.ErrorCodeDigestInvalid)
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp = putManifest(t, "putting signed manifest", manifestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting signed manifest", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{sha512Digest.String()},
+	})
+
+	manifestDigestURL, err = env.builder.BuildManifestURL(imageName, sha512Digest.String())
+	checkErr(t, err, "building manifest url")
+
+	resp, err = http.Get(manifestDigestURL)
+	checkErr(t, err, "fetching manifest by digest")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest by digest", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{sha512Digest.String()},
+	})
+}
+
+// TestManifestAPIWarmLayerInfoCache checks that pulling a manifest records
+// its layers in the layer info cache when cache warming is enabled.
+func TestManifestAPIWarmLayerInfoCache(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"cache": configuration.Parameters{
+				"layerinfo": "inmemory",
+				"warm":      true,
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/warm"
+
+	rs, dgstStr, err := testutil.CreateRandomTarFile()
+	checkErr(t, err, "creating random layer")
+	layerDigest := digest.Digest(dgstStr)
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, rs)
+
+	signedManifest, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: imageName,
+		Tag:  "latest",
+		FSLayers: []manifest.FSLayer{
+			{BlobSum: layerDigest},
+		},
+	}, env.pk)
+	checkErr(t, err, "signing manifest")
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp := putManifest(t, "putting signed manifest", manifestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting signed manifest", resp, http.StatusAccepted)
+
+	// Pushing only checks that the layers exist, which does not resolve
+	// their location.
+	if _, err := env.app.layerInfoCache.Meta(env.ctx, layerDigest); err != cache.ErrNotFound {
+		t.Fatalf("expected layer meta to be missing before the pull: %v", err)
+	}
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest", resp, http.StatusOK)
+
+	// The cache is warmed in the background.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		meta, err := env.app.layerInfoCache.Meta(env.ctx, layerDigest)
+		if err == nil {
+			if meta.Path == "" || meta.Length == 0 {
+				t.Fatalf("unexpected layer meta: %#v", meta)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("layer meta was not cached after the pull: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 type testEnv struct {
 	pk      libtrust.PrivateKey
 	ctx     context.Context
@@ -476,6 +1022,42 @@ type testEnv struct {
 	builder *v2.URLBuilder
 }
 
+// TestManifestAPIMaxSize checks that manifests larger than the configured
+// maximum size are rejected.
+func TestManifestAPIMaxSize(t *testing.T) {
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	checkErr(t, err, "generating private key")
+
+	sm, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: "foo/bar",
+		Tag:  "large",
+	}, pk)
+	checkErr(t, err, "signing manifest")
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Validation: configuration.Validation{
+			Manifests: configuration.ManifestValidation{
+				MaxSize: int64(len(sm.Raw) - 1),
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	manifestURL, err := env.builder.BuildManifestURL("foo/bar", "large")
+	checkErr(t, err, "building manifest url")
+
+	resp := putManifest(t, "putting oversized manifest", manifestURL, sm)
+	defer resp.Body.Close()
+	checkResponse(t, "putting oversized manifest", resp, http.StatusRequestEntityTooLarge)
+	checkBodyHasErrorCodes(t, "putting oversized manifest", resp, v2.ErrorCodeManifestInvalid)
+}
+
 func newTestEnv(t *testing.T) *testEnv {
 	config := configuration.Configuration{
 		Storage: configuration.Storage{
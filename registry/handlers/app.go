@@ -1,22 +1,36 @@
 package handlers
 
 import (
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"expvar"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/configuration"
 	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/health"
+	"github.com/docker/distribution/health/checks"
 	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/robot"
+	httpmiddleware "github.com/docker/distribution/registry/middleware/http"
 	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
 	repositorymiddleware "github.com/docker/distribution/registry/middleware/repository"
+	"github.com/docker/distribution/registry/scheduler"
 	"github.com/docker/distribution/registry/storage"
 	"github.com/docker/distribution/registry/storage/cache"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
@@ -36,6 +50,7 @@ type App struct {
 	Config configuration.Configuration
 
 	router           *mux.Router                 // main application router, configured with dispatchers
+	handler          http.Handler                // router wrapped by the configured http middleware, if any
 	driver           storagedriver.StorageDriver // driver maintains the app global storage driver instance.
 	registry         distribution.Namespace      // registry is the primary registry backend for the app instance.
 	accessController auth.AccessController       // main access controller for application
@@ -44,27 +59,120 @@ type App struct {
 	events struct {
 		sink   notifications.Sink
 		source notifications.SourceRecord
+
+		// endpoints are the configured endpoints by name, including the
+		// endpoints of namespaces, to ping them from the admin interface.
+		endpoints map[string]*notifications.Endpoint
 	}
 
 	redis *redis.Pool
+
+	// layerInfoCache is the cache used by the registry, if one is configured.
+	layerInfoCache cache.LayerInfoCache
+
+	// cacheWarmer fetches the layers of pulled manifests into the layer
+	// info cache, if enabled.
+	cacheWarmer *cacheWarmer
+
+	// readOnly is non-zero while the registry rejects write requests.
+	readOnly int32
+
+	// namespaces lists the configuration overrides of namespaces.
+	namespaces []*namespace
+
+	// uploadStateKeys protect the upload state tokens handed to clients.
+	uploadStateKeys hmacKeys
+
+	// uploadSessions shares the state of uploads between instances, if
+	// configured.
+	uploadSessions uploadSessionStore
+
+	// pullStats counts manifest pulls, if configured.
+	pullStats pullStatsStore
+
+	// leader is non-zero while this instance runs the background jobs.
+	leader int32
+
+	// trustedProxies lists the networks of the proxies whose forwarding
+	// headers are honored, or is nil if all proxies are trusted.
+	trustedProxies []*net.IPNet
+
+	// pathPrefix is the path prefix of the routes, with leading and
+	// trailing slashes, or empty if they are not prefixed.
+	pathPrefix string
+
+	// nameRules are the rules repository names follow.
+	nameRules *v2.NameRules
+
+	// admissionHooks validate pushed manifests before they are accepted.
+	admissionHooks []*validationHook
+
+	// maxManifestSize is the maximum size in bytes of pushed manifests.
+	maxManifestSize int64
+
+	// scheduler runs the scheduled maintenance tasks.
+	scheduler *scheduler.Scheduler
+
+	// trashRetention is how long deleted tags are kept in the trash, or zero
+	// if the trash is not enabled.
+	trashRetention time.Duration
+
+	// compressionMinSize is the size from which JSON responses are
+	// compressed, or zero if compression is disabled.
+	compressionMinSize int
+
+	// digestAlgorithm is the algorithm of the canonical digests of pushed
+	// content.
+	digestAlgorithm string
+
+	// uploadEvents configures the events reporting the progress of large
+	// uploads, or is nil if they are disabled.
+	uploadEvents *uploadEvents
+
+	// trustServer is the url of the trust service advertised to clients, if
+	// any.
+	trustServer string
+
+	// visibility caches the visibility of repositories consulted by the
+	// access controllers.
+	visibility *repositoryVisibility
+
+	// robotAccounts caches the robot accounts consulted by the access
+	// controllers.
+	robotAccounts *robotAccounts
+
+	// redirect is true if the storage driver can redirect clients to the
+	// content of layers.
+	redirect bool
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
 // requests. The app only implements ServeHTTP and can be wrapped in other
 // handlers accordingly.
 func NewApp(ctx context.Context, configuration configuration.Configuration) *App {
+	rules := nameRules(configuration.Validation.Names)
+	prefix := pathPrefix(configuration.HTTP.Prefix)
 	app := &App{
-		Config:  configuration,
-		Context: ctx,
-		router:  v2.RouterWithPrefix(configuration.HTTP.Prefix),
+		Config:     configuration,
+		Context:    ctx,
+		router:     v2.RouterWithNameRules(prefix, rules),
+		pathPrefix: prefix,
+		nameRules:  rules,
 	}
 
 	app.Context = ctxu.WithLogger(app.Context, ctxu.GetLogger(app, "instance.id"))
 
+	app.configureUploadStateKeys(&configuration)
+	app.configureTrustedProxies(configuration.HTTP.TrustedProxies)
+	app.configureCompression(configuration.HTTP.Compression)
+	app.configureTrust(configuration.Trust)
+
 	// Register the handler dispatchers.
 	app.register(v2.RouteNameBase, func(ctx *Context, r *http.Request) http.Handler {
 		return http.HandlerFunc(apiBase)
 	})
+	app.register(v2.RouteNameInfo, infoDispatcher)
+	app.register(v2.RouteNameNamespaces, namespacesDispatcher)
 	app.register(v2.RouteNameManifest, imageManifestDispatcher)
 	app.register(v2.RouteNameTags, tagsDispatcher)
 	app.register(v2.RouteNameBlob, layerDispatcher)
@@ -72,6 +180,11 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 	app.register(v2.RouteNameBlobUploadChunk, layerUploadDispatcher)
 
 	var err error
+	app.handler, err = applyHTTPMiddleware(app.router, configuration.Middleware["http"])
+	if err != nil {
+		panic(err)
+	}
+
 	app.driver, err = factory.Create(configuration.Storage.Type(), configuration.Storage.Parameters())
 	if err != nil {
 		// TODO(stevvooe): Move the creation of a service into a protected
@@ -91,38 +204,95 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 
 	}
 
-	startUploadPurger(app, app.driver, ctxu.GetLogger(app), purgeConfig)
+	app.SetReadOnly(readOnlyEnabled(configuration.Storage))
 
+	purgeDriver := app.driver
 	app.driver, err = applyStorageMiddleware(app.driver, configuration.Middleware["storage"])
 	if err != nil {
 		panic(err)
 	}
+	app.driver = &backpressureDriver{StorageDriver: app.driver}
+	app.visibility = newRepositoryVisibility(app.driver, app.nameRules)
+	app.robotAccounts = newRobotAccounts(app.driver)
+	app.redirect = redirects(app, app.driver)
 
-	app.configureEvents(&configuration)
 	app.configureRedis(&configuration)
+	app.configureCoordination(configuration.Coordination)
+	app.trashRetention = trashRetention(configuration.Storage)
+	if app.trashRetention > 0 {
+		ctxu.GetLogger(app).Infof("keeping deleted tags in the trash for %s", app.trashRetention)
+	}
+	app.configureUploadSessions(configuration.Uploads.Sessions)
+	app.configurePullStats(configuration.PullStats)
+	app.configureAdmission(configuration.Validation.Manifests)
+	app.maxManifestSize = maxManifestSize(configuration.Validation.Manifests)
+	app.registerHealthChecks(&configuration)
+
+	var registryOptions []storage.RegistryOption
+	if repositoryIndexEnabled(configuration.Storage) {
+		ctxu.GetLogger(app).Infof("maintaining repository index")
+		registryOptions = append(registryOptions, storage.EnableRepositoryIndex())
+	}
+	app.digestAlgorithm = digestAlgorithm(configuration.Storage)
+	if app.digestAlgorithm != "sha256" {
+		ctxu.GetLogger(app).Infof("using %s canonical digests", app.digestAlgorithm)
+		registryOptions = append(registryOptions, storage.CanonicalDigestAlgorithm(app.digestAlgorithm))
+	}
+	if size := readBufferSize(configuration.Storage); size > 0 {
+		ctxu.GetLogger(app).Infof("using %d byte layer read buffers", size)
+		registryOptions = append(registryOptions, storage.ReadBufferSize(size))
+	}
+	if verifyLayerReads(configuration.Storage) {
+		ctxu.GetLogger(app).Infof("verifying layer digests as they are served")
+		ctxu.GetLogger(app).Warnf("layers fetched by tarsum, range requests and redirects to the storage backend are served unverified")
+		registryOptions = append(registryOptions, storage.VerifyLayerReads())
+	}
+	if contentEncodingsEnabled(configuration.Storage) {
+		ctxu.GetLogger(app).Infof("serving layers with their content encodings")
+		registryOptions = append(registryOptions, storage.ContentEncodings())
+	}
+	if app.nameRules != v2.DefaultNameRules {
+		registryOptions = append(registryOptions, storage.RepositoryNameRules(app.nameRules))
+	}
+	if scanners := layerScanners(configuration.Validation.Layers); len(scanners) > 0 {
+		ctxu.GetLogger(app).Infof("scanning layers with %d scanners", len(scanners))
+		registryOptions = append(registryOptions, storage.LayerValidators(scanners...))
+	}
+	if verification := manifestBlobVerification(configuration.Validation.Manifests); verification != storage.VerifyAllBlobs {
+		ctxu.GetLogger(app).Infof("using %s verification of manifest layers", configuration.Validation.Manifests.Blobs)
+		registryOptions = append(registryOptions, storage.ManifestBlobVerification(verification))
+	}
 
 	// configure storage caches
 	if cc, ok := configuration.Storage["cache"]; ok {
+		if ttl := negativeCacheTTL(cc); ttl > 0 {
+			ctxu.GetLogger(app).Infof("caching missing layers for %s", ttl)
+			registryOptions = append(registryOptions, storage.NegativeLayerCacheTTL(ttl))
+		}
+
 		switch cc["layerinfo"] {
 		case "redis":
 			if app.redis == nil {
 				panic("redis configuration required to use for layerinfo cache")
 			}
-			app.registry = storage.NewRegistryWithDriver(app, app.driver, cache.NewRedisLayerInfoCache(app.redis))
+			app.layerInfoCache = cache.NewRedisLayerInfoCache(app.redis)
+			app.registry = storage.NewRegistryWithDriver(app, app.driver, app.layerInfoCache, registryOptions...)
 			ctxu.GetLogger(app).Infof("using redis layerinfo cache")
 		case "inmemory":
-			app.registry = storage.NewRegistryWithDriver(app, app.driver, cache.NewInMemoryLayerInfoCache())
+			app.layerInfoCache = app.broadcastInvalidations(cc, cache.NewInMemoryLayerInfoCache())
+			app.registry = storage.NewRegistryWithDriver(app, app.driver, app.layerInfoCache, registryOptions...)
 			ctxu.GetLogger(app).Infof("using inmemory layerinfo cache")
 		default:
 			if cc["layerinfo"] != "" {
 				ctxu.GetLogger(app).Warnf("unkown cache type %q, caching disabled", configuration.Storage["cache"])
 			}
 		}
+
 	}
 
 	if app.registry == nil {
 		// configure the registry if no cache section is available.
-		app.registry = storage.NewRegistryWithDriver(app.Context, app.driver, nil)
+		app.registry = storage.NewRegistryWithDriver(app.Context, app.driver, nil, registryOptions...)
 	}
 
 	app.registry, err = applyRegistryMiddleware(app.registry, configuration.Middleware["registry"])
@@ -130,6 +300,19 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 		panic(err)
 	}
 
+	if warm, _ := configuration.Storage["cache"]["warm"].(bool); warm && app.layerInfoCache != nil {
+		ctxu.GetLogger(app).Infof("warming layerinfo cache on manifest pulls")
+		app.cacheWarmer = newCacheWarmer(app, app.registry, app.layerInfoCache)
+	}
+
+	// Maintenance tasks use the caches, so they are scheduled after them.
+	app.configureScheduler(configuration.Storage, purgeDriver, purgeConfig)
+
+	// Replicators read from the registry, so events are configured after it.
+	app.configureEvents(&configuration)
+	app.configureUploadEvents(configuration.Notifications.Uploads)
+	app.configureNamespaces(&configuration)
+
 	authType := configuration.Auth.Type()
 
 	if authType != "" {
@@ -143,6 +326,197 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 	return app
 }
 
+// ReadOnly returns true if the registry currently rejects write requests.
+func (app *App) ReadOnly() bool {
+	return atomic.LoadInt32(&app.readOnly) != 0
+}
+
+// SetReadOnly enables or disables read-only mode. In read-only mode, only GET
+// and HEAD requests are served.
+func (app *App) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	atomic.StoreInt32(&app.readOnly, v)
+}
+
+// readOnlyEnabled returns true if the storage maintenance section enables
+// read-only mode.
+func readOnlyEnabled(storageConfig configuration.Storage) bool {
+	readOnlyConfig, ok := storageConfig["maintenance"]["readonly"].(map[interface{}]interface{})
+	return ok && readOnlyConfig["enabled"] == true
+}
+
+// defaultTrashRetention is how long deleted tags are kept in the trash, unless
+// another retention is configured.
+const defaultTrashRetention = 168 * time.Hour
+
+// trashRetention returns how long deleted tags are kept in the trash, or zero
+// if the storage maintenance section does not enable the trash.
+func trashRetention(storageConfig configuration.Storage) time.Duration {
+	trashConfig, ok := storageConfig["maintenance"]["trash"].(map[interface{}]interface{})
+	if !ok || trashConfig["enabled"] != true {
+		return 0
+	}
+
+	retention, ok := trashConfig["retention"].(string)
+	if !ok {
+		return defaultTrashRetention
+	}
+
+	d, err := time.ParseDuration(retention)
+	if err != nil || d <= 0 {
+		panic(fmt.Sprintf("invalid trash retention %q", retention))
+	}
+
+	return d
+}
+
+// configureUploadStateKeys sets up the keys protecting upload state tokens
+// from the configured secrets. If no secret is configured, a random one is
+// generated, which only works for a single registry instance and does not
+// survive restarts.
+func (app *App) configureUploadStateKeys(configuration *configuration.Configuration) {
+	secret := configuration.HTTP.Secret
+	if secret == "" {
+		var secretBytes [randomSecretSize]byte
+		if _, err := cryptorand.Read(secretBytes[:]); err != nil {
+			panic(fmt.Sprintf("could not generate random bytes for HTTP secret: %v", err))
+		}
+		secret = string(secretBytes[:])
+		ctxu.GetLogger(app).Warn("No HTTP secret provided - generated random secret. This may cause problems with uploads if multiple registries are behind a load-balancer or if the registry restarts during an upload. To provide a shared secret, fill in http.secret in the configuration file or set the REGISTRY_HTTP_SECRET environment variable.")
+	}
+
+	app.uploadStateKeys = hmacKeys{hmacKey(secret)}
+	for _, previous := range configuration.HTTP.PreviousSecrets {
+		app.uploadStateKeys = append(app.uploadStateKeys, hmacKey(previous))
+	}
+}
+
+// repositoryIndexEnabled returns true if the storage configuration enables
+// the maintenance of the repository index.
+func repositoryIndexEnabled(storageConfig configuration.Storage) bool {
+	return storageConfig["index"]["enabled"] == true
+}
+
+// readBufferSize returns the configured size of the buffers used to read
+// layers, or zero for the default.
+func readBufferSize(storageConfig configuration.Storage) int {
+	switch size := storageConfig["reader"]["buffersize"].(type) {
+	case int:
+		return size
+	case string:
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			panic(fmt.Sprintf("invalid layer read buffer size %q: %v", size, err))
+		}
+		return n
+	}
+
+	return 0
+}
+
+// verifyLayerReads returns true if the storage configuration enables the
+// verification of layer digests as they are served.
+func verifyLayerReads(storageConfig configuration.Storage) bool {
+	return storageConfig["reader"]["verify"] == true
+}
+
+// contentEncodingsEnabled returns true if the storage configuration enables
+// the content encodings of layers.
+func contentEncodingsEnabled(storageConfig configuration.Storage) bool {
+	return storageConfig["contentencoding"]["enabled"] == true
+}
+
+// nameRules returns the configured rules of repository names, panicking on
+// an invalid configuration like the other app configuration steps.
+func nameRules(config configuration.NameValidation) *v2.NameRules {
+	rules, err := NameRules(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return rules
+}
+
+// NameRules returns the configured rules of repository names, which are
+// those of the specification unless overridden. Omitted settings keep their
+// default.
+func NameRules(config configuration.NameValidation) (*v2.NameRules, error) {
+	if config == (configuration.NameValidation{}) {
+		return v2.DefaultNameRules, nil
+	}
+
+	component := config.Component
+	if component == "" {
+		component = v2.RepositoryNameComponentRegexp.String()
+	}
+
+	componentMinLength := config.ComponentMinLength
+	if componentMinLength == 0 {
+		componentMinLength = v2.RepositoryNameComponentMinLength
+	}
+
+	maxLength := config.MaxLength
+	if maxLength == 0 {
+		maxLength = v2.RepositoryNameTotalLengthMax
+	}
+
+	return v2.NewNameRules(component, componentMinLength, maxLength)
+}
+
+// manifestBlobVerification returns the configured verification of the layers
+// of pushed manifests.
+func manifestBlobVerification(config configuration.ManifestValidation) storage.BlobVerification {
+	switch config.Blobs {
+	case "", "strict":
+		return storage.VerifyAllBlobs
+	case "fast":
+		return storage.VerifyTopBlob
+	case "none":
+		return storage.VerifyNoBlobs
+	default:
+		panic(fmt.Sprintf("unsupported manifest blob verification: %q", config.Blobs))
+	}
+}
+
+// defaultMaxManifestSize is the maximum size of pushed manifests unless
+// configured otherwise.
+const defaultMaxManifestSize = 4 << 20
+
+// maxManifestSize returns the configured maximum size of pushed manifests.
+func maxManifestSize(config configuration.ManifestValidation) int64 {
+	if config.MaxSize > 0 {
+		return config.MaxSize
+	}
+	return defaultMaxManifestSize
+}
+
+// redirects returns true if driver, including its middleware, can provide
+// urls to redirect clients to. Drivers unable to provide urls fail URLFor
+// with ErrUnsupportedMethod whatever the path, so a single call tells.
+func redirects(ctx ctxu.Context, driver storagedriver.StorageDriver) bool {
+	_, err := driver.URLFor(ctx, "/docker/registry/v2", nil)
+	return err != storagedriver.ErrUnsupportedMethod
+}
+
+// digestAlgorithm returns the configured canonical digest algorithm, sha256
+// by default.
+func digestAlgorithm(storageConfig configuration.Storage) string {
+	alg, ok := storageConfig["digest"]["algorithm"].(string)
+	if !ok || alg == "" {
+		return "sha256"
+	}
+
+	if _, err := digest.NewDigesterForAlgorithm(alg); err != nil {
+		panic(fmt.Sprintf("invalid canonical digest algorithm %q: %v", alg, err))
+	}
+
+	return alg
+}
+
 // register a handler with the application, by route name. The handler will be
 // passed through the application filters and context will be constructed at
 // request time.
@@ -157,32 +531,100 @@ func (app *App) register(routeName string, dispatch dispatchFunc) {
 	app.router.GetRoute(routeName).Handler(app.dispatcher(dispatch))
 }
 
-// configureEvents prepares the event sink for action.
-func (app *App) configureEvents(configuration *configuration.Configuration) {
-	// Configure all of the endpoint sinks.
-	var sinks []notifications.Sink
-	for _, endpoint := range configuration.Notifications.Endpoints {
-		if endpoint.Disabled {
-			ctxu.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
-			continue
+// pathPrefix returns the configured path prefix of the routes with leading
+// and trailing slashes, or an empty string if the routes are not prefixed.
+func pathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return "/" + prefix + "/"
+}
+
+// urlBuilder returns the builder of the urls returned in response to the
+// request, which honors the forwarding headers of trusted proxies and the
+// path prefix of the routes.
+func (app *App) urlBuilder(r *http.Request) *v2.URLBuilder {
+	var ub *v2.URLBuilder
+	if app.trustedProxies == nil {
+		ub = v2.NewURLBuilderFromRequest(r)
+	} else {
+		ub = v2.NewURLBuilderFromTrustedRequest(r, app.trustsProxy)
+	}
+
+	if app.pathPrefix != "" {
+		// The root of the request cannot be told from its path if the
+		// prefix contains the api version.
+		ub = ub.WithPathPrefix(app.pathPrefix)
+	}
+
+	if app.nameRules != nil {
+		ub = ub.WithNameRules(app.nameRules)
+	}
+
+	return ub
+}
+
+// randomSecretSize is the number of random bytes to generate if no secret
+// was specified.
+const randomSecretSize = 32
+
+// defaultHealthCheckInterval is the period of configured health checks that
+// do not specify an interval.
+const defaultHealthCheckInterval = 10 * time.Second
+
+// registerHealthChecks registers the health checks listed in the health
+// section of the configuration.
+func (app *App) registerHealthChecks(configuration *configuration.Configuration) {
+	registerPeriodic := func(name string, check health.Checker, interval time.Duration, threshold int) {
+		if interval == 0 {
+			interval = defaultHealthCheckInterval
 		}
 
-		ctxu.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
-		endpoint := notifications.NewEndpoint(endpoint.Name, endpoint.URL, notifications.EndpointConfig{
-			Timeout:   endpoint.Timeout,
-			Threshold: endpoint.Threshold,
-			Backoff:   endpoint.Backoff,
-			Headers:   endpoint.Headers,
+		if threshold > 0 {
+			health.Replace(name, health.PeriodicThresholdChecker(check, interval, threshold))
+		} else {
+			health.Replace(name, health.PeriodicChecker(check, interval))
+		}
+	}
+
+	if configuration.Health.StorageDriver.Enabled {
+		storageDriverCheck := health.CheckFunc(func() error {
+			_, err := app.driver.List(app, "/")
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				err = nil // pass this through, backend is responding, but this path doesn't exist.
+			}
+			return err
 		})
 
-		sinks = append(sinks, endpoint)
+		registerPeriodic("storagedriver_"+configuration.Storage.Type(), storageDriverCheck,
+			configuration.Health.StorageDriver.Interval, configuration.Health.StorageDriver.Threshold)
 	}
 
+	for _, fileChecker := range configuration.Health.FileCheckers {
+		ctxu.GetLogger(app).Infof("configuring file health check path=%s, interval=%v", fileChecker.File, fileChecker.Interval)
+		registerPeriodic(fileChecker.File, checks.FileChecker(fileChecker.File), fileChecker.Interval, fileChecker.Threshold)
+	}
+
+	for _, httpChecker := range configuration.Health.HTTPCheckers {
+		ctxu.GetLogger(app).Infof("configuring HTTP health check uri=%s, interval=%v", httpChecker.URI, httpChecker.Interval)
+		registerPeriodic(httpChecker.URI, checks.HTTPChecker(httpChecker.URI), httpChecker.Interval, httpChecker.Threshold)
+	}
+}
+
+// configureEvents prepares the event sink for action.
+func (app *App) configureEvents(configuration *configuration.Configuration) {
+	// Configure all of the endpoint sinks.
+	sinks := app.endpointSinks(configuration.Notifications.Endpoints)
+	sinks = append(sinks, app.fileSinks(configuration.Notifications.Files)...)
+	sinks = append(sinks, app.replicatorSinks(configuration.Replication.Peers)...)
+
 	// NOTE(stevvooe): Moving to a new queueing implementation is as easy as
 	// replacing broadcaster with a rabbitmq implementation. It's recommended
 	// that the registry instances also act as the workers to keep deployment
 	// simple.
-	app.events.sink = notifications.NewBroadcaster(sinks...)
+	app.events.sink = app.deduplicateEvents(notifications.NewBroadcaster(sinks...))
 
 	// Populate registry event source
 	hostname, err := os.Hostname()
@@ -202,6 +644,148 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	}
 }
 
+// deduplicateEvents wraps sink to drop duplicate events, if a deduplication
+// window is configured.
+func (app *App) deduplicateEvents(sink notifications.Sink) notifications.Sink {
+	window := app.Config.Notifications.DedupWindow
+	if window <= 0 {
+		return sink
+	}
+
+	return notifications.NewDeduplicatingSink(sink, window)
+}
+
+// endpointSinks returns a sink for each enabled endpoint.
+func (app *App) endpointSinks(endpoints []configuration.Endpoint) []notifications.Sink {
+	var sinks []notifications.Sink
+	for _, endpoint := range endpoints {
+		if endpoint.Disabled {
+			ctxu.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
+			continue
+		}
+
+		// Only the names of the headers are logged, since their values may
+		// hold credentials for the endpoint.
+		var headers []string
+		for name := range endpoint.Headers {
+			headers = append(headers, name)
+		}
+		sort.Strings(headers)
+
+		ctxu.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, headers)
+
+		tlsConfig, err := endpointTLSConfig(endpoint.TLS)
+		if err != nil {
+			panic(fmt.Sprintf("unable to configure tls for endpoint %s: %v", endpoint.Name, err))
+		}
+
+		endpoint := notifications.NewEndpoint(endpoint.Name, endpoint.URL, notifications.EndpointConfig{
+			Timeout:   endpoint.Timeout,
+			Threshold: endpoint.Threshold,
+			Backoff:   endpoint.Backoff,
+			Headers:   endpoint.Headers,
+			TLSConfig: tlsConfig,
+		})
+
+		if app.events.endpoints == nil {
+			app.events.endpoints = make(map[string]*notifications.Endpoint)
+		}
+		if _, ok := app.events.endpoints[endpoint.Name()]; !ok {
+			app.events.endpoints[endpoint.Name()] = endpoint
+		}
+
+		sinks = append(sinks, endpoint)
+	}
+
+	return sinks
+}
+
+// fileSinks returns a sink for each enabled file.
+func (app *App) fileSinks(files []configuration.FileSink) []notifications.Sink {
+	var sinks []notifications.Sink
+	for _, file := range files {
+		if file.Disabled {
+			ctxu.GetLogger(app).Infof("file %s disabled, skipping", file.Name)
+			continue
+		}
+
+		ctxu.GetLogger(app).Infof("configuring file %v (%v), maxsize=%d, maxbackups=%d", file.Name, file.Path, file.MaxSize, file.MaxBackups)
+
+		sink, err := notifications.NewFileSink(file.Path, file.MaxSize, file.MaxBackups)
+		if err != nil {
+			panic(fmt.Sprintf("unable to open file %s for events: %v", file.Name, err))
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	return sinks
+}
+
+// endpointTLSConfig loads the client tls configuration of an endpoint. It
+// returns nil if the endpoint uses the defaults.
+func endpointTLSConfig(config configuration.EndpointTLS) (*tls.Config, error) {
+	if config.Certificate == "" && config.Key == "" && len(config.CAs) == 0 {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if config.Certificate != "" || config.Key != "" {
+		cert, err := tls.LoadX509KeyPair(config.Certificate, config.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(config.CAs) != 0 {
+		pool := x509.NewCertPool()
+		for _, ca := range config.CAs {
+			caPem, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok := pool.AppendCertsFromPEM(caPem); !ok {
+				return nil, fmt.Errorf("could not add CA %s to pool", ca)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// replicatorSinks returns a replicating sink for each enabled peer.
+func (app *App) replicatorSinks(peers []configuration.ReplicationPeer) []notifications.Sink {
+	var sinks []notifications.Sink
+	for _, peer := range peers {
+		if peer.Disabled {
+			ctxu.GetLogger(app).Infof("replication peer %s disabled, skipping", peer.Name)
+			continue
+		}
+
+		ctxu.GetLogger(app).Infof("configuring replication peer %v (%v), repositories=%v", peer.Name, peer.URL, peer.Repositories)
+		replicator, err := notifications.NewReplicator(peer.Name, peer.URL, app.registry, notifications.ReplicatorConfig{
+			Repositories: peer.Repositories,
+			Attempts:     peer.Attempts,
+			Backoff:      peer.Backoff,
+			Username:     peer.Username,
+			Password:     peer.Password,
+			Token:        peer.Token,
+			Timeout:      peer.Timeout,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("unable to configure replication peer %s: %v", peer.Name, err))
+		}
+
+		sinks = append(sinks, replicator)
+	}
+
+	return sinks
+}
+
 func (app *App) configureRedis(configuration *configuration.Configuration) {
 	if configuration.Redis.Addr == "" {
 		ctxu.GetLogger(app).Infof("redis not configured")
@@ -287,6 +871,11 @@ func (app *App) configureRedis(configuration *configuration.Configuration) {
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() // ensure that request body is always closed.
 
+	if cw := app.compressResponse(w, r); cw != nil {
+		defer cw.Close()
+		w = cw
+	}
+
 	// Instantiate an http context here so we can track the error codes
 	// returned by the request router.
 	ctx := defaultContextManager.context(app, w, r)
@@ -304,6 +893,15 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Set a header with the Docker Distribution API Version for all responses.
 	w.Header().Add("Docker-Distribution-API-Version", "registry/2.0")
+	if app.trustServer != "" {
+		// Advertise the trust service on all responses, including the
+		// authentication challenges of clients not logged in yet.
+		w.Header().Set("Docker-Content-Trust-Server", app.trustServer)
+	}
+	if app.handler != nil {
+		app.handler.ServeHTTP(w, r)
+		return
+	}
 	app.router.ServeHTTP(w, r)
 }
 
@@ -330,6 +928,13 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 		// Add username to request logging
 		context.Context = ctxu.WithLogger(context.Context, ctxu.GetLogger(context.Context, "auth.user.name"))
 
+		if app.ReadOnly() && r.Method != "GET" && r.Method != "HEAD" {
+			context.Errors.Push(v2.ErrorCodeUnsupported, "registry is in read-only mode")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			serveJSON(w, context.Errors)
+			return
+		}
+
 		if app.nameRequired(r) {
 			repository, err := app.registry.Repository(context, getName(context))
 
@@ -348,6 +953,16 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 				return
 			}
 
+			context.namespace = app.namespace(repository.Name())
+
+			if !context.networkAllowed(r) {
+				ctxu.GetLogger(context).Errorf("access from %s (peer %s) denied by network policy", v2.ClientIP(r, app.trustsProxy), r.RemoteAddr)
+				context.Errors.Push(v2.ErrorCodeUnauthorized, "access denied by network policy")
+				w.WriteHeader(http.StatusForbidden)
+				serveJSON(w, context.Errors)
+				return
+			}
+
 			// assign and decorate the authorized repository with an event bridge.
 			context.Repository = notifications.Listen(
 				repository,
@@ -363,19 +978,32 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 			}
 		}
 
-		dispatch(context, r).ServeHTTP(w, r)
-		// Automated error response handling here. Handlers may return their
-		// own errors if they need different behavior (such as range errors
-		// for layer upload).
-		if context.Errors.Len() > 0 {
-			if context.Value("http.response.status") == 0 {
-				// TODO(stevvooe): Getting this value from the context is a
-				// bit of a hack. We can further address with some of our
-				// future refactoring.
-				w.WriteHeader(http.StatusBadRequest)
+		serve := func(w http.ResponseWriter) {
+			w = &backpressureWriter{
+				ResponseWriter: w,
+				backpressure:   getBackpressure(context),
 			}
-			app.logError(context, context.Errors)
-			serveJSON(w, context.Errors)
+
+			dispatch(context, r).ServeHTTP(w, r)
+			// Automated error response handling here. Handlers may return their
+			// own errors if they need different behavior (such as range errors
+			// for layer upload).
+			if context.Errors.Len() > 0 {
+				if context.Value("http.response.status") == 0 {
+					// TODO(stevvooe): Getting this value from the context is a
+					// bit of a hack. We can further address with some of our
+					// future refactoring.
+					w.WriteHeader(http.StatusBadRequest)
+				}
+				app.logError(context, context.Errors)
+				serveJSON(w, context.Errors)
+			}
+		}
+
+		if timeout := app.routeTimeout(r); timeout > 0 {
+			serveWithTimeout(context, w, r, timeout, serve)
+		} else {
+			serve(w)
 		}
 	})
 }
@@ -398,6 +1026,7 @@ func (app *App) logError(context context.Context, errors v2.Errors) {
 func (app *App) context(w http.ResponseWriter, r *http.Request) *Context {
 	ctx := defaultContextManager.context(app, w, r)
 	ctx = ctxu.WithVars(ctx, r)
+	ctx = withBackpressure(ctx)
 	ctx = ctxu.WithLogger(ctx, ctxu.GetLogger(ctx,
 		"vars.name",
 		"vars.reference",
@@ -407,8 +1036,9 @@ func (app *App) context(w http.ResponseWriter, r *http.Request) *Context {
 	context := &Context{
 		App:        app,
 		Context:    ctx,
-		urlBuilder: v2.NewURLBuilderFromRequest(r),
+		urlBuilder: app.urlBuilder(r),
 	}
+	context.Errors.RequestID = ctxu.GetStringValue(ctx, "http.request.id")
 
 	return context
 }
@@ -428,13 +1058,23 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 
 	if repo != "" {
 		accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
+	} else if app.catalogRoute(r) {
+		// Listing the namespaces reveals the repositories of the registry,
+		// so it requires access to the catalog.
+		accessRecords = append(accessRecords, auth.Access{
+			Resource: auth.Resource{
+				Type: "registry",
+				Name: "catalog",
+			},
+			Action: "*",
+		})
 	} else {
-		// Only allow the name not to be set on the base route.
+		// Only allow the name not to be set on the base and info routes.
 		if app.nameRequired(r) {
 			// For this to be properly secured, repo must always be set for a
 			// resource that may make a modification. The only condition under
 			// which name is not set and we still allow access is when the
-			// base route is accessed. This section prevents us from making
+			// base or info route is accessed. This section prevents us from making
 			// that mistake elsewhere in the code, allowing any operation to
 			// proceed.
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -447,7 +1087,12 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 		}
 	}
 
-	ctx, err := app.accessController.Authorized(context.Context, accessRecords...)
+	// Access controllers may consult the visibility of the repository, to
+	// grant anonymous pulls of public repositories, and the robot accounts.
+	authCtx := auth.WithRepositoryVisibility(context.Context, app.visibility)
+	authCtx = robot.WithAccounts(authCtx, app.robotAccounts)
+
+	ctx, err := app.accessController.Authorized(authCtx, accessRecords...)
 	if err != nil {
 		switch err := err.(type) {
 		case auth.Challenge:
@@ -484,13 +1129,34 @@ func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listene
 	}
 	request := notifications.NewRequestRecord(ctxu.GetRequestID(ctx), r)
 
-	return notifications.NewBridge(ctx.urlBuilder, app.events.source, actor, request, app.events.sink)
+	sink := app.events.sink
+	if ctx.namespace != nil {
+		sink = ctx.namespace.sink
+	}
+
+	return notifications.NewBridge(ctx.urlBuilder, app.events.source, actor, request, sink, app.digestAlgorithm, app.Config.Notifications.Labels)
 }
 
 // nameRequired returns true if the route requires a name.
 func (app *App) nameRequired(r *http.Request) bool {
 	route := mux.CurrentRoute(r)
-	return route == nil || route.GetName() != v2.RouteNameBase
+	if route == nil {
+		return true
+	}
+
+	switch route.GetName() {
+	case v2.RouteNameBase, v2.RouteNameInfo, v2.RouteNameNamespaces:
+		return false
+	}
+
+	return true
+}
+
+// catalogRoute returns true if the route lists the repositories of the
+// registry rather than accessing a single one.
+func (app *App) catalogRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && route.GetName() == v2.RouteNameNamespaces
 }
 
 // apiBase implements a simple yes-man for doing overall checks against the
@@ -529,17 +1195,34 @@ func appendAccessRecords(records []auth.Access, method string, repo string) []au
 				Action:   "push",
 			})
 	case "DELETE":
-		// DELETE access requires full admin rights, which is represented
-		// as "*". This may not be ideal.
+		// Deletes have their own action, so that pushes can be allowed
+		// without allowing deletes. Grants of "*" still cover it.
 		records = append(records,
 			auth.Access{
 				Resource: resource,
-				Action:   "*",
+				Action:   "delete",
 			})
 	}
 	return records
 }
 
+// applyHTTPMiddleware wraps the handler of the application with the
+// configured middlewares, skipping the disabled ones.
+func applyHTTPMiddleware(handler http.Handler, middlewares []configuration.Middleware) (http.Handler, error) {
+	for _, mw := range middlewares {
+		if mw.Disabled {
+			continue
+		}
+
+		hmw, err := httpmiddleware.Get(mw.Name, mw.Options, handler)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure http middleware (%s): %v", mw.Name, err)
+		}
+		handler = hmw
+	}
+	return handler, nil
+}
+
 // applyRegistryMiddleware wraps a registry instance with the configured middlewares
 func applyRegistryMiddleware(registry distribution.Namespace, middlewares []configuration.Middleware) (distribution.Namespace, error) {
 	for _, mw := range middlewares {
@@ -577,6 +1260,52 @@ func applyStorageMiddleware(driver storagedriver.StorageDriver, middlewares []co
 	return driver, nil
 }
 
+// defaultInvalidationChannel is the redis channel on which cache
+// invalidations are broadcast, unless another is configured.
+const defaultInvalidationChannel = "registry::cache::invalidations"
+
+// broadcastInvalidations returns lic, a cache local to the instance, set up
+// to broadcast its invalidations to the other instances if the cache
+// configuration enables it.
+func (app *App) broadcastInvalidations(cacheConfig configuration.Parameters, lic cache.LayerInfoCache) cache.LayerInfoCache {
+	switch cacheConfig["invalidation"] {
+	case nil, "":
+		return lic
+	case "redis":
+	default:
+		panic(fmt.Sprintf("unsupported cache invalidation: %v", cacheConfig["invalidation"]))
+	}
+
+	if app.redis == nil {
+		panic("redis configuration required to broadcast cache invalidations")
+	}
+
+	channel, _ := cacheConfig["invalidationchannel"].(string)
+	if channel == "" {
+		channel = defaultInvalidationChannel
+	}
+
+	ctxu.GetLogger(app).Infof("broadcasting layerinfo cache invalidations on redis channel %s", channel)
+	return cache.NewBroadcastingLayerInfoCache(app, lic, cache.NewRedisInvalidationChannel(app.redis, channel), ctxu.GetStringValue(app, "instance.id"))
+}
+
+// negativeCacheTTL returns the time layers found missing are recorded in the
+// layer info cache, or zero if missing layers are not cached.
+func negativeCacheTTL(cacheConfig configuration.Parameters) time.Duration {
+	switch ttl := cacheConfig["negativettl"].(type) {
+	case nil:
+		return 0
+	case string:
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			panic(fmt.Sprintf("invalid negative cache ttl %q", ttl))
+		}
+		return d
+	default:
+		panic(fmt.Sprintf("invalid negative cache ttl %v", ttl))
+	}
+}
+
 // uploadPurgeDefaultConfig provides a default configuration for upload
 // purging to be used in the absence of configuration in the
 // confifuration file
@@ -593,13 +1322,10 @@ func badPurgeUploadConfig(reason string) {
 	panic(fmt.Sprintf("Unable to parse upload purge configuration: %s", reason))
 }
 
-// startUploadPurger schedules a goroutine which will periodically
-// check upload directories for old files and delete them
-func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log ctxu.Logger, config map[interface{}]interface{}) {
-	if config["enabled"] == false {
-		return
-	}
-
+// uploadPurgeSettings parses the upload purging configuration into the
+// minimum age of purged uploads, the interval between purges and whether
+// purges only report what they would remove.
+func uploadPurgeSettings(config map[interface{}]interface{}) (time.Duration, time.Duration, bool) {
 	var purgeAgeDuration time.Duration
 	var err error
 	purgeAge, ok := config["age"]
@@ -643,6 +1369,13 @@ func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageD
 		badPurgeUploadConfig("dryrun missing")
 	}
 
+	return purgeAgeDuration, intervalDuration, dryRunBool
+}
+
+// startUploadPurger schedules a goroutine which will periodically
+// check upload directories for old files and delete them, as long as
+// isLeader returns true.
+func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log ctxu.Logger, purgeAgeDuration, intervalDuration time.Duration, dryRunBool bool, isLeader func() bool) {
 	go func() {
 		rand.Seed(time.Now().Unix())
 		jitter := time.Duration(rand.Int()%60) * time.Minute
@@ -650,9 +1383,146 @@ func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageD
 		time.Sleep(jitter)
 
 		for {
-			storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			if isLeader() {
+				storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			} else {
+				log.Infof("Skipping upload purge on an instance that is not the leader")
+			}
 			log.Infof("Starting upload purge in %s", intervalDuration)
 			time.Sleep(intervalDuration)
 		}
 	}()
 }
+
+// trashPurgeInterval is the interval between purges of the trash, unless
+// another schedule is configured.
+const trashPurgeInterval = time.Hour
+
+// maintenanceTasks are the tasks which can be scheduled in the schedule
+// subsection of the storage maintenance section.
+var maintenanceTasks = map[string]struct{}{
+	"gc":            {},
+	"uploadpurging": {},
+	"trash":         {},
+	"cache":         {},
+}
+
+// maintenanceSchedules returns the schedules configured for the maintenance
+// tasks, by task name.
+func maintenanceSchedules(storageConfig configuration.Storage) map[string]scheduler.Schedule {
+	schedules := make(map[string]scheduler.Schedule)
+	scheduleConfig, ok := storageConfig["maintenance"]["schedule"].(map[interface{}]interface{})
+	if !ok {
+		return schedules
+	}
+
+	for k, v := range scheduleConfig {
+		name := fmt.Sprint(k)
+		if _, ok := maintenanceTasks[name]; !ok {
+			panic(fmt.Sprintf("unknown scheduled maintenance task %q", name))
+		}
+
+		spec, ok := v.(string)
+		if !ok {
+			panic(fmt.Sprintf("schedule of maintenance task %q is not a string", name))
+		}
+
+		schedule, err := scheduler.Parse(spec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to schedule maintenance task %q: %v", name, err))
+		}
+		schedules[name] = schedule
+	}
+
+	return schedules
+}
+
+// configureScheduler schedules the maintenance tasks and starts them. Upload
+// purging runs at its configured interval unless it is scheduled, and the
+// trash is purged every hour unless it is scheduled. Garbage collection and
+// cache flushes only run if they are scheduled.
+func (app *App) configureScheduler(storageConfig configuration.Storage, purgeDriver storagedriver.StorageDriver, purgeConfig map[interface{}]interface{}) {
+	app.scheduler = scheduler.New(app)
+	schedules := maintenanceSchedules(storageConfig)
+
+	if purgeConfig["enabled"] != false {
+		age, interval, dryRun := uploadPurgeSettings(purgeConfig)
+		if schedule, ok := schedules["uploadpurging"]; ok {
+			app.scheduler.Add("uploadpurging", schedule, app.leaderTask("upload purge", func(ctx context.Context) error {
+				_, errs := storage.PurgeUploads(ctx, purgeDriver, time.Now().Add(-age), !dryRun)
+				return maintenanceError(errs)
+			}))
+		} else {
+			startUploadPurger(app, purgeDriver, ctxu.GetLogger(app), age, interval, dryRun, app.IsLeader)
+		}
+	}
+
+	if app.trashRetention > 0 {
+		schedule, ok := schedules["trash"]
+		if !ok {
+			schedule = scheduler.Every(trashPurgeInterval)
+		}
+		app.scheduler.Add("trash", schedule, app.leaderTask("trash purge", func(ctx context.Context) error {
+			_, errs := storage.PurgeTrash(ctx, app.driver, time.Now().Add(-app.trashRetention), true)
+			return maintenanceError(errs)
+		}))
+	}
+
+	if schedule, ok := schedules["gc"]; ok {
+		app.scheduler.Add("gc", schedule, app.leaderTask("garbage collection", func(ctx context.Context) error {
+			_, errs := app.collectGarbage(ctx, time.Now().Add(-defaultAdminPurgeAge), false)
+			return maintenanceError(errs)
+		}))
+	}
+
+	if schedule, ok := schedules["cache"]; ok {
+		flusher, ok := app.layerInfoCache.(cache.Flusher)
+		if !ok {
+			panic("scheduled cache flushes require a layerinfo cache")
+		}
+
+		// Caches may be local to each instance, so every instance flushes
+		// its own.
+		app.scheduler.Add("cache", schedule, flusher.Flush)
+	}
+
+	app.scheduler.Start()
+}
+
+// leaderTask returns a task running task on the leader only.
+func (app *App) leaderTask(description string, task scheduler.Task) scheduler.Task {
+	return func(ctx context.Context) error {
+		if !app.IsLeader() {
+			ctxu.GetLogger(ctx).Infof("Skipping %s on an instance that is not the leader", description)
+			return nil
+		}
+		return task(ctx)
+	}
+}
+
+// collectGarbage removes the uploads started before olderThan, and the tags
+// deleted before the trash retention from the trash, if it is enabled. The
+// paths removed, or that would be removed if dryRun is set, are returned
+// with the errors encountered.
+func (app *App) collectGarbage(ctx context.Context, olderThan time.Time, dryRun bool) ([]string, []error) {
+	deleted, errs := storage.PurgeUploads(ctx, app.driver, olderThan, !dryRun)
+	if app.trashRetention > 0 {
+		trashed, trashErrs := storage.PurgeTrash(ctx, app.driver, time.Now().Add(-app.trashRetention), !dryRun)
+		deleted = append(deleted, trashed...)
+		errs = append(errs, trashErrs...)
+	}
+	return deleted, errs
+}
+
+// maintenanceError summarizes the errors of a maintenance task, which
+// carries on past them.
+func maintenanceError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%v, and %d more errors", errs[0], len(errs)-1)
+	}
+}
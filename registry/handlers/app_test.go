@@ -7,11 +7,14 @@ import (
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
 	_ "github.com/docker/distribution/registry/auth/silly"
+	_ "github.com/docker/distribution/registry/middleware/http/headers"
 	"github.com/docker/distribution/registry/storage"
 	"github.com/docker/distribution/registry/storage/cache"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
@@ -205,6 +208,138 @@ func TestNewApp(t *testing.T) {
 	}
 }
 
+// TestNewAppHealthChecks ensures that an application with health checks can
+// be created twice in a process, the checks of the latest one replacing the
+// previous ones.
+func TestNewAppHealthChecks(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+		},
+	}
+	config.Health.StorageDriver.Enabled = true
+	config.Health.StorageDriver.Interval = time.Hour
+
+	NewApp(context.Background(), config)
+	NewApp(context.Background(), config)
+
+	if _, ok := health.CheckDetails()["storagedriver_inmemory"]; !ok {
+		t.Fatalf("storage driver health check not registered: %v", health.CheckDetails())
+	}
+}
+
+// TestScheduledMaintenance ensures that the maintenance tasks scheduled in
+// the configuration run, and that invalid schedules are rejected.
+func TestScheduledMaintenance(t *testing.T) {
+	ctx := context.Background()
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+			"cache": configuration.Parameters{
+				"layerinfo": "inmemory",
+			},
+			"maintenance": configuration.Parameters{
+				"uploadpurging": map[interface{}]interface{}{
+					"enabled": false,
+				},
+				"schedule": map[interface{}]interface{}{
+					"cache": "@every 10ms",
+					"gc":    "@daily",
+				},
+			},
+		},
+	}
+
+	app := NewApp(ctx, config)
+	defer app.scheduler.Stop()
+
+	if err := app.layerInfoCache.Add(ctx, "foo/bar", "sha256:abc"); err != nil {
+		t.Fatalf("unexpected error adding cache entry: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	exists, err := app.layerInfoCache.Contains(ctx, "foo/bar", "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error checking cache entry: %v", err)
+	}
+	if exists {
+		t.Fatalf("expected the scheduled flush to remove the cache entry")
+	}
+
+	for _, schedule := range []map[interface{}]interface{}{
+		{"cache": "0 3 * *"},
+		{"scrub": "@daily"},
+		{"gc": 3},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected schedule %v to be rejected", schedule)
+				}
+			}()
+
+			maintenanceSchedules(configuration.Storage{
+				"maintenance": configuration.Parameters{
+					"schedule": schedule,
+				},
+			})
+		}()
+	}
+}
+
+// TestCacheInvalidationConfig checks that broadcasting cache invalidations
+// requires redis.
+func TestCacheInvalidationConfig(t *testing.T) {
+	app := &App{Context: context.Background()}
+	lic := cache.NewInMemoryLayerInfoCache()
+
+	if app.broadcastInvalidations(configuration.Parameters{"layerinfo": "inmemory"}, lic) != lic {
+		t.Fatalf("expected the cache to be used as is without invalidation")
+	}
+
+	for _, invalidation := range []string{"redis", "gossip"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected %s invalidation without redis to be rejected", invalidation)
+				}
+			}()
+
+			app.broadcastInvalidations(configuration.Parameters{"invalidation": invalidation}, lic)
+		}()
+	}
+}
+
+// TestNegativeCacheTTLConfig checks the parsing of the time missing layers are
+// cached.
+func TestNegativeCacheTTLConfig(t *testing.T) {
+	for _, testcase := range []struct {
+		config   configuration.Parameters
+		expected time.Duration
+	}{
+		{configuration.Parameters{"layerinfo": "inmemory"}, 0},
+		{configuration.Parameters{"negativettl": "30s"}, 30 * time.Second},
+		{configuration.Parameters{"negativettl": "0s"}, 0},
+	} {
+		if ttl := negativeCacheTTL(testcase.config); ttl != testcase.expected {
+			t.Fatalf("unexpected negative cache ttl for %v: %s != %s", testcase.config, ttl, testcase.expected)
+		}
+	}
+
+	for _, ttl := range []interface{}{"soon", "-1m", 30} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected negative cache ttl %v to be rejected", ttl)
+				}
+			}()
+
+			negativeCacheTTL(configuration.Parameters{"negativettl": ttl})
+		}()
+	}
+}
+
 // Test the access record accumulator
 func TestAppendAccessRecords(t *testing.T) {
 	repo := "testRepo"
@@ -222,9 +357,9 @@ func TestAppendAccessRecords(t *testing.T) {
 		Resource: expectedResource,
 		Action:   "push",
 	}
-	expectedAllRecord := auth.Access{
+	expectedDeleteRecord := auth.Access{
 		Resource: expectedResource,
-		Action:   "*",
+		Action:   "delete",
 	}
 
 	records := []auth.Access{}
@@ -264,9 +399,54 @@ func TestAppendAccessRecords(t *testing.T) {
 
 	records = []auth.Access{}
 	result = appendAccessRecords(records, "DELETE", repo)
-	expectedResult = []auth.Access{expectedAllRecord}
+	expectedResult = []auth.Access{expectedDeleteRecord}
 	if ok := reflect.DeepEqual(result, expectedResult); !ok {
 		t.Fatalf("Actual access record differs from expected")
 	}
 
 }
+
+// TestHTTPMiddleware checks that the configured http middleware wraps the
+// application handler, and that disabled middleware is skipped.
+func TestHTTPMiddleware(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Middleware: map[string][]configuration.Middleware{
+			"http": {
+				{
+					Name:    "headers",
+					Options: configuration.Parameters{"X-Frame-Options": "DENY"},
+				},
+				{
+					Name:     "headers",
+					Disabled: true,
+					Options:  configuration.Parameters{"X-Disabled": "true"},
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	baseURL, err := env.builder.BuildBaseURL()
+	if err != nil {
+		t.Fatalf("unexpected error building base url: %v", err)
+	}
+
+	resp, err := http.Get(baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing api base check", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"X-Frame-Options":                 []string{"DENY"},
+		"Docker-Distribution-Api-Version": []string{"registry/2.0"},
+	})
+
+	if value := resp.Header.Get("X-Disabled"); value != "" {
+		t.Fatalf("unexpected header from disabled middleware: %q", value)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// minRetryAfter is the delay clients are asked to wait before retrying a
+// request throttled by the storage backend, unless the backend requests a
+// longer one.
+const minRetryAfter = time.Second
+
+// backpressure records whether the storage backend throttled the calls made
+// for a request. It is kept in the request context, so that the storage
+// driver can report to the handler of the request.
+type backpressure struct {
+	mu         sync.Mutex
+	throttled  bool
+	retryAfter time.Duration
+}
+
+// backpressureKey is the key of the backpressure in the request context.
+type backpressureKey struct{}
+
+// withBackpressure returns a context recording the backpressure of the
+// storage backend.
+func withBackpressure(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backpressureKey{}, &backpressure{})
+}
+
+// getBackpressure returns the backpressure recorded in ctx, or nil if it is
+// not recorded.
+func getBackpressure(ctx context.Context) *backpressure {
+	bp, _ := ctx.Value(backpressureKey{}).(*backpressure)
+	return bp
+}
+
+// record records a throttled call.
+func (bp *backpressure) record(err storagedriver.ThrottledError) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	bp.throttled = true
+	if err.RetryAfter > bp.retryAfter {
+		bp.retryAfter = err.RetryAfter
+	}
+}
+
+// delay returns the delay clients should wait before retrying the request,
+// and whether any call was throttled. The delay is the longest requested by
+// the backend, of at least minRetryAfter, and rounded up to whole seconds
+// for the Retry-After header.
+func (bp *backpressure) delay() (time.Duration, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if !bp.throttled {
+		return 0, false
+	}
+
+	delay := bp.retryAfter
+	if delay < minRetryAfter {
+		delay = minRetryAfter
+	}
+
+	if rem := delay % time.Second; rem != 0 {
+		delay += time.Second - rem
+	}
+
+	return delay, true
+}
+
+// backpressureWriter responds with 503 Service Unavailable and a Retry-After
+// header instead of an error status when the storage backend throttled the
+// request, so that clients back off before retrying. Handlers map storage
+// errors to various statuses, such as 404 Not Found for manifests that could
+// not be read, so any error status is replaced once a call was throttled.
+type backpressureWriter struct {
+	http.ResponseWriter
+	backpressure *backpressure
+}
+
+func (bw *backpressureWriter) WriteHeader(status int) {
+	if status >= http.StatusBadRequest {
+		if delay, ok := bw.backpressure.delay(); ok {
+			bw.Header().Set("Retry-After", strconv.Itoa(int(delay/time.Second)))
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+// ReadFrom copies src to the response with the ReadFrom of the underlying
+// writer, if any, so that layers may be sent without copying them through
+// user space.
+func (bw *backpressureWriter) ReadFrom(src io.Reader) (int64, error) {
+	if rf, ok := bw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+
+	// Hide ReadFrom, so that io.Copy writes through Write.
+	return io.Copy(struct{ io.Writer }{bw}, src)
+}
+
+// backpressureDriver reports the calls throttled by the storage backend to
+// the backpressure of the request context.
+type backpressureDriver struct {
+	storagedriver.StorageDriver
+}
+
+// report records err in the backpressure of ctx if it is a throttling error.
+func (d *backpressureDriver) report(ctx context.Context, err error) {
+	throttled, ok := err.(storagedriver.ThrottledError)
+	if !ok {
+		return
+	}
+
+	if bp := getBackpressure(ctx); bp != nil {
+		bp.record(throttled)
+	}
+}
+
+func (d *backpressureDriver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	content, err := d.StorageDriver.GetContent(ctx, path)
+	d.report(ctx, err)
+	return content, err
+}
+
+func (d *backpressureDriver) PutContent(ctx context.Context, path string, content []byte) error {
+	err := d.StorageDriver.PutContent(ctx, path, content)
+	d.report(ctx, err)
+	return err
+}
+
+func (d *backpressureDriver) ReadStream(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	rc, err := d.StorageDriver.ReadStream(ctx, path, offset)
+	d.report(ctx, err)
+	return rc, err
+}
+
+func (d *backpressureDriver) WriteStream(ctx context.Context, path string, offset int64, reader io.Reader) (int64, error) {
+	nn, err := d.StorageDriver.WriteStream(ctx, path, offset, reader)
+	d.report(ctx, err)
+	return nn, err
+}
+
+func (d *backpressureDriver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	fi, err := d.StorageDriver.Stat(ctx, path)
+	d.report(ctx, err)
+	return fi, err
+}
+
+func (d *backpressureDriver) List(ctx context.Context, path string) ([]string, error) {
+	children, err := d.StorageDriver.List(ctx, path)
+	d.report(ctx, err)
+	return children, err
+}
+
+func (d *backpressureDriver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	err := d.StorageDriver.Move(ctx, sourcePath, destPath)
+	d.report(ctx, err)
+	return err
+}
+
+func (d *backpressureDriver) Delete(ctx context.Context, path string) error {
+	err := d.StorageDriver.Delete(ctx, path)
+	d.report(ctx, err)
+	return err
+}
+
+func (d *backpressureDriver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	url, err := d.StorageDriver.URLFor(ctx, path, options)
+	d.report(ctx, err)
+	return url, err
+}
+
+// OpenFile keeps the optional file access of the wrapped driver available.
+func (d *backpressureDriver) OpenFile(ctx context.Context, path string) (*os.File, error) {
+	opener, ok := d.StorageDriver.(storagedriver.FileOpener)
+	if !ok {
+		return nil, storagedriver.ErrUnsupportedMethod
+	}
+
+	file, err := opener.OpenFile(ctx, path)
+	d.report(ctx, err)
+	return file, err
+}
+
+// StatMany keeps the optional batched stats of the wrapped driver available.
+func (d *backpressureDriver) StatMany(ctx context.Context, paths []string) ([]storagedriver.FileInfo, []error) {
+	fis, errs := storagedriver.StatMany(ctx, d.StorageDriver, paths)
+	for _, err := range errs {
+		d.report(ctx, err)
+	}
+	return fis, errs
+}
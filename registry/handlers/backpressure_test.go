@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// throttledDriver fails stats and writes as a throttling storage backend
+// does.
+type throttledDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *throttledDriver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	return nil, d.throttled()
+}
+
+func (d *throttledDriver) PutContent(ctx context.Context, path string, content []byte) error {
+	return d.throttled()
+}
+
+func (d *throttledDriver) throttled() error {
+	return storagedriver.ThrottledError{
+		DriverName: d.Name(),
+		RetryAfter: 1500 * time.Millisecond,
+		Enclosed:   storagedriver.ErrUnsupportedMethod,
+	}
+}
+
+func init() {
+	storagemiddleware.Register("throttled", func(driver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+		return &throttledDriver{StorageDriver: driver}, nil
+	})
+}
+
+// TestBackpressure checks that requests throttled by the storage backend are
+// answered with 503 Service Unavailable and a Retry-After header.
+func TestBackpressure(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Middleware: map[string][]configuration.Middleware{
+			"storage": {{Name: "throttled"}},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	uploadURL, err := env.builder.BuildBlobUploadURL("foo/bar")
+	checkErr(t, err, "building upload url")
+
+	resp, err := http.Post(uploadURL, "", nil)
+	checkErr(t, err, "starting layer upload")
+	defer resp.Body.Close()
+
+	checkResponse(t, "starting throttled upload", resp, http.StatusServiceUnavailable)
+	checkHeaders(t, resp, http.Header{
+		"Retry-After": []string{"2"},
+	})
+	checkBodyHasErrorCodes(t, "starting throttled upload", resp, v2.ErrorCodeUnknown)
+
+	// Manifests that cannot be read are otherwise reported unknown.
+	manifestURL, err := env.builder.BuildManifestURL("foo/bar", "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching throttled manifest", resp, http.StatusServiceUnavailable)
+	checkHeaders(t, resp, http.Header{
+		"Retry-After": []string{"2"},
+	})
+}
+
+func TestBackpressureDelay(t *testing.T) {
+	for _, testcase := range []struct {
+		retryAfters []time.Duration
+		expected    time.Duration
+	}{
+		{retryAfters: []time.Duration{0}, expected: time.Second},
+		{retryAfters: []time.Duration{0, 2 * time.Second}, expected: 2 * time.Second},
+		{retryAfters: []time.Duration{2500 * time.Millisecond, time.Second}, expected: 3 * time.Second},
+	} {
+		var bp backpressure
+		if _, ok := bp.delay(); ok {
+			t.Fatalf("unexpected delay without throttled calls")
+		}
+
+		for _, retryAfter := range testcase.retryAfters {
+			bp.record(storagedriver.ThrottledError{RetryAfter: retryAfter})
+		}
+
+		if delay, ok := bp.delay(); !ok || delay != testcase.expected {
+			t.Errorf("unexpected delay for %v: %v != %v", testcase.retryAfters, delay, testcase.expected)
+		}
+	}
+}
+
+// TestBackpressureWriterReadFrom checks that copies to the response reach
+// its ReadFrom.
+func TestBackpressureWriterReadFrom(t *testing.T) {
+	w := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	bw := &backpressureWriter{ResponseWriter: w, backpressure: &backpressure{}}
+
+	if _, err := io.Copy(bw, struct{ io.Reader }{strings.NewReader("content")}); err != nil {
+		t.Fatalf("unexpected error copying: %v", err)
+	}
+
+	if w.readFrom != 1 || w.Body.String() != "content" {
+		t.Fatalf("unexpected copy: %d copies with ReadFrom, %q", w.readFrom, w.Body.String())
+	}
+}
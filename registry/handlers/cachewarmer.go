@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"sync"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage/cache"
+	"golang.org/x/net/context"
+)
+
+const (
+	// cacheWarmerWorkers is the number of manifests warmed concurrently.
+	cacheWarmerWorkers = 4
+
+	// cacheWarmerQueueSize is the number of manifests waiting to be warmed,
+	// beyond which pulled manifests are not warmed.
+	cacheWarmerQueueSize = 64
+)
+
+// cacheWarmer warms the layer info cache with the layers of pulled
+// manifests. Manifests are queued and warmed by a fixed number of workers
+// with the context and registry of the app, as the requests which pulled
+// them are usually done by then. A manifest which is already queued is not
+// queued again, and pulled manifests are not warmed while the queue is full.
+type cacheWarmer struct {
+	ctx      context.Context
+	registry distribution.Namespace
+	lic      cache.LayerInfoCache
+	queue    chan warmRequest
+
+	mu      sync.Mutex
+	pending map[warmRequest]struct{}
+}
+
+// warmRequest identifies a pulled manifest to warm.
+type warmRequest struct {
+	repo   string
+	digest digest.Digest
+	sm     *manifest.SignedManifest
+}
+
+// newCacheWarmer starts the workers of a cache warmer, which stop when the
+// context is done.
+func newCacheWarmer(ctx context.Context, registry distribution.Namespace, lic cache.LayerInfoCache) *cacheWarmer {
+	cw := &cacheWarmer{
+		ctx:      ctx,
+		registry: registry,
+		lic:      lic,
+		queue:    make(chan warmRequest, cacheWarmerQueueSize),
+		pending:  make(map[warmRequest]struct{}),
+	}
+
+	for i := 0; i < cacheWarmerWorkers; i++ {
+		go cw.run()
+	}
+
+	return cw
+}
+
+// warm queues the manifest of the repository, identified by its digest, to
+// be warmed. It does not block.
+func (cw *cacheWarmer) warm(repo string, dgst digest.Digest, sm *manifest.SignedManifest) {
+	key := warmRequest{repo: repo, digest: dgst}
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if _, ok := cw.pending[key]; ok {
+		return
+	}
+
+	select {
+	case cw.queue <- warmRequest{repo: repo, digest: dgst, sm: sm}:
+		cw.pending[key] = struct{}{}
+	default:
+		ctxu.GetLogger(cw.ctx).Debugf("layer info cache warming queue is full, not warming %s@%s", repo, dgst)
+	}
+}
+
+func (cw *cacheWarmer) run() {
+	for {
+		select {
+		case req := <-cw.queue:
+			cw.mu.Lock()
+			delete(cw.pending, warmRequest{repo: req.repo, digest: req.digest})
+			cw.mu.Unlock()
+
+			repository, err := cw.registry.Repository(cw.ctx, req.repo)
+			if err != nil {
+				ctxu.GetLogger(cw.ctx).Warnf("error resolving repository %s to warm layer info cache: %v", req.repo, err)
+				continue
+			}
+
+			warmLayerInfoCache(cw.ctx, cw.lic, repository, req.sm)
+		case <-cw.ctx.Done():
+			return
+		}
+	}
+}
+
+// warmLayerInfoCache fetches the layers of the manifest, which records them
+// in the layer info cache, so that the layer requests which usually follow a
+// manifest pull do not have to look them up in the storage backend. Layers
+// already in the cache, which are looked up together, are not fetched again.
+func warmLayerInfoCache(ctx context.Context, lic cache.LayerInfoCache, repository distribution.Repository, sm *manifest.SignedManifest) {
+	seen := make(map[digest.Digest]struct{}, len(sm.FSLayers))
+	var dgsts []digest.Digest
+	for _, fsLayer := range sm.FSLayers {
+		if _, ok := seen[fsLayer.BlobSum]; ok {
+			continue
+		}
+		seen[fsLayer.BlobSum] = struct{}{}
+		dgsts = append(dgsts, fsLayer.BlobSum)
+	}
+
+	cached := make([]bool, len(dgsts))
+	if contained, err := cache.ContainsMany(ctx, lic, repository.Name(), dgsts); err != nil {
+		ctxu.GetLogger(ctx).Warnf("error looking up layers of %s in layer info cache: %v", repository.Name(), err)
+	} else {
+		_, errs := cache.MetaMany(ctx, lic, dgsts)
+		for i := range dgsts {
+			cached[i] = contained[i] && errs[i] == nil
+		}
+	}
+
+	layers := repository.Layers()
+	for i, dgst := range dgsts {
+		if cached[i] {
+			continue
+		}
+
+		layer, err := layers.Fetch(dgst)
+		if err != nil {
+			ctxu.GetLogger(ctx).Warnf("error warming layer info cache for %s@%s: %v", repository.Name(), dgst, err)
+			continue
+		}
+		layer.Close()
+	}
+}
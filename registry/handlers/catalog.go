@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/gorilla/handlers"
+)
+
+// namespacesDispatcher constructs the namespaces api endpoint.
+func namespacesDispatcher(ctx *Context, r *http.Request) http.Handler {
+	namespacesHandler := &namespacesHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(namespacesHandler.GetNamespaces),
+	}
+}
+
+// namespacesHandler lists the namespaces of the registry.
+type namespacesHandler struct {
+	*Context
+}
+
+type namespacesAPIResponse struct {
+	Parent     string                   `json:"parent,omitempty"`
+	Namespaces []storage.NamespaceCount `json:"namespaces"`
+}
+
+// GetNamespaces lists the namespaces directly beneath the namespace given by
+// the "parent" query parameter, or the top-level namespaces, with their
+// repository counts, so that tree views can be built from the repository
+// index one level at a time.
+func (nh *namespacesHandler) GetNamespaces(w http.ResponseWriter, r *http.Request) {
+	parent := r.FormValue("parent")
+	if parent != "" {
+		if err := nh.App.nameRules.Validate(parent); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			nh.Errors.Push(v2.ErrorCodeNameInvalid, err)
+			return
+		}
+	}
+
+	namespaces, err := storage.ListNamespaces(nh, nh.App.driver, parent)
+	if err != nil {
+		nh.Errors.PushErr(err)
+		return
+	}
+	if namespaces == nil {
+		namespaces = []storage.NamespaceCount{}
+	}
+
+	p, err := json.Marshal(namespacesAPIResponse{
+		Parent:     parent,
+		Namespaces: namespaces,
+	})
+	if err != nil {
+		nh.Errors.PushErr(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
+	w.Write(p)
+}
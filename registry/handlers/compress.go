@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+)
+
+// defaultCompressionMinSize is the size from which JSON responses are
+// compressed, unless configured otherwise. Smaller responses do not gain
+// enough to be worth the cost of compressing them.
+const defaultCompressionMinSize = 1024
+
+// compressionEncodings lists the supported content encodings, in order of
+// preference.
+var compressionEncodings = []string{"gzip", "deflate"}
+
+// configureCompression sets up the compression of JSON responses.
+func (app *App) configureCompression(config configuration.Compression) {
+	if !config.Enabled {
+		return
+	}
+
+	app.compressionMinSize = config.MinSize
+	if app.compressionMinSize <= 0 {
+		app.compressionMinSize = defaultCompressionMinSize
+	}
+}
+
+// compressResponse returns a response writer compressing the JSON response
+// to r, or nil if compression is disabled or not accepted by the client. The
+// returned writer must be closed once the response is written.
+func (app *App) compressResponse(w http.ResponseWriter, r *http.Request) *compressResponseWriter {
+	if app.compressionMinSize <= 0 || r.Method == "HEAD" {
+		return nil
+	}
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+
+	return &compressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        app.compressionMinSize,
+	}
+}
+
+// negotiateEncoding returns the supported encoding preferred by an
+// Accept-Encoding header, or an empty string if none is acceptable.
+func negotiateEncoding(header string) string {
+	var (
+		best        string
+		bestQuality float64
+	)
+
+	for _, element := range strings.Split(header, ",") {
+		params := strings.Split(element, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+
+		for _, encoding := range compressionEncodings {
+			if coding != encoding || quality <= 0 {
+				continue
+			}
+
+			// Equal qualities keep the preferred encoding.
+			if quality > bestQuality || quality == bestQuality && preference(encoding) < preference(best) {
+				best, bestQuality = encoding, quality
+			}
+		}
+	}
+
+	return best
+}
+
+// preference returns the rank of a supported encoding in the order of
+// preference.
+func preference(encoding string) int {
+	for i, e := range compressionEncodings {
+		if e == encoding {
+			return i
+		}
+	}
+
+	return len(compressionEncodings)
+}
+
+// compressResponseWriter compresses JSON responses of at least minSize
+// bytes, buffering their beginning until it is reached. Other responses,
+// such as layers, and responses declaring their length pass through.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int    // status written by the handler, if any
+	buf     []byte // beginning of the response, until the decision
+	decided bool   // whether the response is compressed is decided
+	writer  io.WriteCloser
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.buf == nil && !cw.compressible() {
+			cw.passThrough()
+		} else {
+			cw.buf = append(cw.buf, p...)
+			if len(cw.buf) < cw.minSize {
+				return len(p), nil
+			}
+
+			if err := cw.compress(); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// ReadFrom copies src to the response with the ReadFrom of the underlying
+// writer when the response is not compressed, so that layers may be sent
+// without copying them through user space.
+func (cw *compressResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !cw.decided && cw.buf == nil && !cw.compressible() {
+		if err := cw.passThrough(); err != nil {
+			return 0, err
+		}
+	}
+
+	if rf, ok := cw.ResponseWriter.(io.ReaderFrom); ok && cw.decided && cw.writer == nil {
+		return rf.ReadFrom(src)
+	}
+
+	// Hide ReadFrom, so that io.Copy writes through Write.
+	return io.Copy(struct{ io.Writer }{cw}, src)
+}
+
+// Close writes the rest of the response, which is only compressed if it
+// reached the minimum size.
+func (cw *compressResponseWriter) Close() error {
+	if !cw.decided {
+		if err := cw.passThrough(); err != nil {
+			return err
+		}
+	}
+
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+
+	return nil
+}
+
+// Flush sends the response written so far, deciding to leave it uncompressed
+// if the decision is pending.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		if err := cw.passThrough(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := cw.writer.(interface {
+		Flush() error
+	}); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// compressible returns true if the response, about to be written, is a JSON
+// response whose encoding and length are not set by the handler.
+func (cw *compressResponseWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Length") != "" {
+		return false
+	}
+
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// passThrough writes the status and the buffered beginning of the response,
+// leaving it uncompressed.
+func (cw *compressResponseWriter) passThrough() error {
+	cw.decided = true
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// compress writes the status and the buffered beginning of the response,
+// compressing it and the rest of the response.
+func (cw *compressResponseWriter) compress() error {
+	cw.decided = true
+
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Add("Vary", "Accept-Encoding")
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	switch cw.encoding {
+	case "gzip":
+		cw.writer = gzip.NewWriter(cw.ResponseWriter)
+	case "deflate":
+		// The deflate content encoding is the zlib format.
+		cw.writer = zlib.NewWriter(cw.ResponseWriter)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	_, err := cw.writer.Write(buf)
+	return err
+}
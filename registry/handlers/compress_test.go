@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+	_ "github.com/docker/distribution/registry/storage/driver/filesystem"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, testcase := range []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: ""},
+		{header: "identity", expected: ""},
+		{header: "gzip", expected: "gzip"},
+		{header: "deflate", expected: "deflate"},
+		{header: "deflate, gzip", expected: "gzip"},
+		{header: "gzip;q=0.5, deflate", expected: "deflate"},
+		{header: "GZIP; q=1.0", expected: "gzip"},
+		{header: "gzip;q=0", expected: ""},
+		{header: "br, zstd", expected: ""},
+	} {
+		if encoding := negotiateEncoding(testcase.header); encoding != testcase.expected {
+			t.Errorf("unexpected encoding negotiated for %q: %q != %q", testcase.header, encoding, testcase.expected)
+		}
+	}
+}
+
+// TestCompressedResponses checks that JSON responses are compressed from the
+// minimum size when accepted by the client, and that layers are not.
+func TestCompressedResponses(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.HTTP.Compression = configuration.Compression{Enabled: true, MinSize: 32}
+	env := newTestEnvWithConfig(t, &config)
+
+	get := func(u, acceptEncoding string) *http.Response {
+		req, err := http.NewRequest("GET", u, nil)
+		checkErr(t, err, "building request")
+		// Setting the header keeps the client from decompressing responses.
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, "issuing request")
+		return resp
+	}
+
+	// The error listing the unknown repository is large enough.
+	tagsURL, err := env.builder.BuildTagsURL("foo/bar")
+	checkErr(t, err, "building tags url")
+
+	resp := get(tagsURL, "gzip")
+	defer resp.Body.Close()
+	checkResponse(t, "getting unknown tags", resp, http.StatusNotFound)
+	checkHeaders(t, resp, http.Header{
+		"Content-Encoding": []string{"gzip"},
+		"Vary":             []string{"Accept-Encoding"},
+	})
+
+	gz, err := gzip.NewReader(resp.Body)
+	checkErr(t, err, "reading compressed response")
+
+	var errs v2.Errors
+	checkErr(t, json.NewDecoder(gz).Decode(&errs), "decoding compressed response")
+	if errs.Len() != 1 || errs.Errors[0].Code != v2.ErrorCodeNameUnknown {
+		t.Fatalf("unexpected errors in compressed response: %v", errs)
+	}
+
+	resp = get(tagsURL, "identity")
+	defer resp.Body.Close()
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Fatalf("unexpected encoding of response not accepting compression: %q", encoding)
+	}
+
+	// The empty object of the base route is too small.
+	baseURL, err := env.builder.BuildBaseURL()
+	checkErr(t, err, "building base url")
+
+	resp = get(baseURL, "gzip")
+	defer resp.Body.Close()
+	checkResponse(t, "checking api", resp, http.StatusOK)
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Fatalf("unexpected encoding of small response: %q", encoding)
+	}
+
+	// Layers are served as is, even if their content is JSON.
+	content := bytes.Repeat([]byte(`{"key": "value"}`), 8)
+	dgst, err := digest.FromBytes(content)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, "foo/bar")
+	layerURL := pushLayer(t, env.builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+
+	resp = get(layerURL, "gzip")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching layer", resp, http.StatusOK)
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		t.Fatalf("unexpected encoding of layer: %q", encoding)
+	}
+}
+
+// readFromRecorder records the copies made to the response with ReadFrom.
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int
+}
+
+func (rr *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rr.readFrom++
+	return io.Copy(rr.ResponseRecorder, src)
+}
+
+// TestCompressResponseWriterReadFrom checks that copies of responses left
+// uncompressed, such as layers, reach the ReadFrom of the response, and
+// that JSON responses are compressed anyway.
+func TestCompressResponseWriterReadFrom(t *testing.T) {
+	for _, testcase := range []struct {
+		contentType string
+		compressed  bool
+	}{
+		{contentType: "application/octet-stream"},
+		{contentType: "application/json", compressed: true},
+	} {
+		w := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: "gzip", minSize: 1}
+		cw.Header().Set("Content-Type", testcase.contentType)
+
+		content := strings.Repeat("content", 16)
+		if _, err := io.Copy(cw, struct{ io.Reader }{strings.NewReader(content)}); err != nil {
+			t.Fatalf("unexpected error copying %s: %v", testcase.contentType, err)
+		}
+		if err := cw.Close(); err != nil {
+			t.Fatalf("unexpected error closing %s: %v", testcase.contentType, err)
+		}
+
+		if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != testcase.compressed {
+			t.Fatalf("unexpected compression of %s: %t", testcase.contentType, compressed)
+		}
+		if passed := w.readFrom > 0; passed == testcase.compressed {
+			t.Fatalf("unexpected copies of %s with ReadFrom: %d", testcase.contentType, w.readFrom)
+		}
+		if !testcase.compressed && w.Body.String() != content {
+			t.Fatalf("unexpected content of %s: %q", testcase.contentType, w.Body.String())
+		}
+	}
+}
+
+// TestServeLayerReadFrom checks that layers of the filesystem driver served
+// through App.ServeHTTP, with compression and timeouts enabled, reach the
+// ReadFrom of the response, which sends them with sendfile.
+func TestServeLayerReadFrom(t *testing.T) {
+	root, err := ioutil.TempDir("", "readfrom-")
+	checkErr(t, err, "creating root directory")
+	defer os.RemoveAll(root)
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"filesystem": configuration.Parameters{"rootdirectory": root},
+		},
+	}
+	config.HTTP.Compression = configuration.Compression{Enabled: true, MinSize: 32}
+	config.HTTP.Timeouts = configuration.Timeouts{Blob: time.Minute}
+	env := newTestEnvWithConfig(t, &config)
+
+	content := bytes.Repeat([]byte(`{"key": "value"}`), 64)
+	dgst, err := digest.FromBytes(content)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, "foo/bar")
+	layerURL := pushLayer(t, env.builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+
+	// The server closes the body of the requests it serves.
+	req, err := http.NewRequest("GET", layerURL, strings.NewReader(""))
+	checkErr(t, err, "building request")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	w := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	env.app.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status fetching layer: %d", w.Code)
+	}
+	if w.readFrom == 0 {
+		t.Fatalf("layer not copied with ReadFrom")
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("unexpected layer content: %q", w.Body.String())
+	}
+}
@@ -31,6 +31,10 @@ type Context struct {
 
 	urlBuilder *v2.URLBuilder
 
+	// namespace holds the configuration overrides for the repository of
+	// the request. This field may be nil.
+	namespace *namespace
+
 	// TODO(stevvooe): The goal is too completely factor this context and
 	// dispatching out of the web application. Ideally, we should lean on
 	// context.Context for injection of these resources.
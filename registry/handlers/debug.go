@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/health"
+)
+
+// debugRealm is the basic authentication realm of the debug server.
+const debugRealm = "registry debug"
+
+// NewDebugHandler returns the handler of the debug server, serving the debug
+// endpoints enabled in config. If a username is configured, requests must
+// authenticate with its credentials.
+func NewDebugHandler(config configuration.Debug) (http.Handler, error) {
+	handler := http.Handler(newDebugMux(config))
+	if config.Auth.Username == "" {
+		return handler, nil
+	}
+
+	if config.Auth.Password == "" {
+		return nil, fmt.Errorf("debug server password required with username %q", config.Auth.Username)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := basicAuth(r)
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(config.Auth.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(config.Auth.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", debugRealm))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}), nil
+}
+
+// newDebugMux returns a mux serving the pprof profiles under /debug/pprof/,
+// the expvar counters on /debug/vars and the health status on /debug/health
+// and /debug/health/detail, unless they are disabled in config.
+func newDebugMux(config configuration.Debug) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	if !config.Pprof.Disabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	}
+
+	if !config.Expvar.Disabled {
+		mux.HandleFunc("/debug/vars", serveExpvar)
+	}
+
+	if !config.Health.Disabled {
+		mux.HandleFunc("/debug/health", health.StatusHandler)
+		mux.HandleFunc("/debug/health/detail", health.DetailHandler)
+	}
+
+	return mux
+}
+
+// serveExpvar writes the published expvar variables as a JSON object, like
+// the handler the expvar package registers on the default mux.
+func serveExpvar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
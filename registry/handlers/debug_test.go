@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"golang.org/x/net/context"
+)
+
+// TestDebugHandler checks that the debug endpoints can be switched off
+// individually.
+func TestDebugHandler(t *testing.T) {
+	checkStatus := func(handler http.Handler, path string, expected int) {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != expected {
+			t.Fatalf("unexpected status code for %s: %d != %d", path, recorder.Code, expected)
+		}
+
+		if path == "/debug/vars" && expected == http.StatusOK {
+			var vars map[string]interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &vars); err != nil {
+				t.Fatalf("unexpected error decoding expvar response: %v", err)
+			}
+
+			if _, ok := vars["memstats"]; !ok {
+				t.Fatalf("expected memstats in expvar response: %v", vars)
+			}
+		}
+	}
+
+	handler, err := NewDebugHandler(configuration.Debug{})
+	if err != nil {
+		t.Fatalf("unexpected error creating debug handler: %v", err)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars", "/debug/health", "/debug/health/detail"} {
+		checkStatus(handler, path, http.StatusOK)
+	}
+
+	var config configuration.Debug
+	config.Pprof.Disabled = true
+	config.Health.Disabled = true
+	handler, err = NewDebugHandler(config)
+	if err != nil {
+		t.Fatalf("unexpected error creating debug handler: %v", err)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/health", "/debug/health/detail"} {
+		checkStatus(handler, path, http.StatusNotFound)
+	}
+	checkStatus(handler, "/debug/vars", http.StatusOK)
+}
+
+// TestDebugHandlerAuth checks that the debug server requires the configured
+// credentials.
+func TestDebugHandlerAuth(t *testing.T) {
+	var config configuration.Debug
+	config.Auth.Username = "debug"
+	if _, err := NewDebugHandler(config); err == nil {
+		t.Fatalf("expected error creating debug handler without password")
+	}
+
+	config.Auth.Password = "secret"
+	handler, err := NewDebugHandler(config)
+	if err != nil {
+		t.Fatalf("unexpected error creating debug handler: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		username, password string
+		expected           int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"debug", "wrong", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+		{"debug", "secret", http.StatusOK},
+	} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com/debug/vars", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+
+		if testcase.username != "" {
+			req.SetBasicAuth(testcase.username, testcase.password)
+		}
+
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != testcase.expected {
+			t.Fatalf("unexpected status code for %q: %d != %d", testcase.username, recorder.Code, testcase.expected)
+		}
+
+		if recorder.Code == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected basic authentication challenge")
+		}
+	}
+}
+
+// TestAdminDebugEndpoints checks that the debug endpoints are served on the
+// admin interface only when configured.
+func TestAdminDebugEndpoints(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		config := configuration.Configuration{
+			Storage: configuration.Storage{
+				"inmemory": configuration.Parameters{},
+			},
+		}
+		config.HTTP.Debug.Admin = enabled
+		config.HTTP.Debug.Pprof.Disabled = true
+
+		adminApp, err := NewAdminApp(NewApp(context.Background(), config), nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating admin app: %v", err)
+		}
+
+		expected := map[string]int{
+			"/debug/vars":   http.StatusNotFound,
+			"/debug/pprof/": http.StatusNotFound,
+		}
+		if enabled {
+			expected["/debug/vars"] = http.StatusOK
+		}
+
+		for path, status := range expected {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %v", err)
+			}
+			req.RemoteAddr = "127.0.0.1:1234"
+
+			adminApp.ServeHTTP(recorder, req)
+			if recorder.Code != status {
+				t.Fatalf("unexpected status code for %s with debug endpoints enabled=%v: %d != %d", path, enabled, recorder.Code, status)
+			}
+		}
+	}
+}
@@ -70,3 +70,35 @@ func (secret hmacKey) packUploadState(lus layerUploadState) (string, error) {
 
 	return base64.URLEncoding.EncodeToString(append(mac.Sum(nil), p...)), nil
 }
+
+// hmacKeys is a set of keys protecting upload state tokens. Tokens are packed
+// with the first key and unpacked with any of the keys, so that keys can be
+// rotated without invalidating uploads in progress.
+type hmacKeys []hmacKey
+
+// unpackUploadState unpacks and validates the layer upload state from the
+// token, trying each key in turn.
+func (keys hmacKeys) unpackUploadState(token string) (layerUploadState, error) {
+	var (
+		state layerUploadState
+		err   = fmt.Errorf("Invalid token")
+	)
+
+	for _, key := range keys {
+		state, err = key.unpackUploadState(token)
+		if err == nil {
+			return state, nil
+		}
+	}
+
+	return state, err
+}
+
+// packUploadState packs the upload state with the first key of the set.
+func (keys hmacKeys) packUploadState(lus layerUploadState) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no upload state key configured")
+	}
+
+	return keys[0].packUploadState(lus)
+}
@@ -115,3 +115,41 @@ func assertLayerUploadStateEquals(t *testing.T, expected layerUploadState, recei
 		t.Fatalf("Expected Offset=%d, Received Offset=%d", expected.Offset, received.Offset)
 	}
 }
+
+// TestHMACKeysRotation checks that tokens packed with a previous key are
+// still accepted after the key is rotated, and that new tokens are packed
+// with the current key.
+func TestHMACKeysRotation(t *testing.T) {
+	previous := hmacKeys{hmacKey("previous")}
+	rotated := hmacKeys{hmacKey("current"), hmacKey("previous")}
+
+	for _, testcase := range layerUploadStates {
+		token, err := previous.packUploadState(testcase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lus, err := rotated.unpackUploadState(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertLayerUploadStateEquals(t, testcase, lus)
+
+		token, err = rotated.packUploadState(testcase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := previous.unpackUploadState(token); err == nil {
+			t.Fatalf("token packed with the current key accepted by previous key")
+		}
+
+		lus, err = hmacKey("current").unpackUploadState(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertLayerUploadStateEquals(t, testcase, lus)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+)
+
+// maxHookReasonSize bounds the rejection reasons read from validation hooks.
+const maxHookReasonSize = 1024
+
+// validationHook posts pushed content to an http endpoint which accepts or
+// rejects it, such as a content scanner or an admission policy.
+type validationHook struct {
+	name    string
+	url     string
+	headers http.Header
+	client  *http.Client
+
+	// block fails the pushes which are rejected or could not be validated,
+	// instead of only logging them.
+	block bool
+}
+
+// hookRejection is returned by a validation hook rejecting content.
+type hookRejection struct {
+	hook   string
+	reason string
+}
+
+func (r hookRejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.hook, r.reason)
+}
+
+// newValidationHook returns the hook configured by config. It panics on an
+// invalid enforcement, like the other app configuration steps.
+func newValidationHook(config configuration.ValidationHook) *validationHook {
+	hook := &validationHook{
+		name:    config.Name,
+		url:     config.URL,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: config.Timeout},
+	}
+
+	switch config.Enforce {
+	case "", "block":
+		hook.block = true
+	case "log":
+	default:
+		panic(fmt.Sprintf("unsupported enforcement of validation hook %s: %q", config.Name, config.Enforce))
+	}
+
+	return hook
+}
+
+// post posts the content to the hook with the given headers. It returns a
+// hookRejection if the hook rejects the content.
+func (h *validationHook) post(content io.Reader, headers http.Header) error {
+	// The content is owned by the caller and must not be closed by the
+	// transport.
+	req, err := http.NewRequest("POST", h.url, ioutil.NopCloser(content))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range h.headers {
+		req.Header[k] = v
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting to %s: %v", h.name, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == 422:
+		p, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHookReasonSize))
+
+		reason := strings.TrimSpace(string(p))
+		if reason == "" {
+			reason = "content rejected"
+		}
+
+		return hookRejection{hook: h.name, reason: reason}
+	default:
+		return fmt.Errorf("unexpected status from %s: %v", h.name, resp.Status)
+	}
+}
+
+// enforce logs the outcome of a failed validation of the content described
+// by fields, returning the error if the hook blocks pushes and nil otherwise.
+func (h *validationHook) enforce(ctx ctxu.Context, err error, fields map[string]interface{}) error {
+	fields["hook"] = h.name
+	logger := ctxu.GetLoggerWithFields(ctx, fields)
+	if _, ok := err.(hookRejection); ok {
+		logger.Warnf("content rejected: %v", err)
+	} else {
+		logger.Errorf("error validating content: %v", err)
+	}
+
+	if !h.block {
+		return nil
+	}
+
+	return err
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -32,6 +31,7 @@ func imageManifestDispatcher(ctx *Context, r *http.Request) http.Handler {
 
 	return handlers.MethodHandler{
 		"GET":    http.HandlerFunc(imageManifestHandler.GetImageManifest),
+		"HEAD":   http.HandlerFunc(imageManifestHandler.GetImageManifest),
 		"PUT":    http.HandlerFunc(imageManifestHandler.PutImageManifest),
 		"DELETE": http.HandlerFunc(imageManifestHandler.DeleteImageManifest),
 	}
@@ -70,7 +70,7 @@ func (imh *imageManifestHandler) GetImageManifest(w http.ResponseWriter, r *http
 
 	// Get the digest, if we don't already have it.
 	if imh.Digest == "" {
-		dgst, err := digestManifest(imh, sm)
+		dgst, err := digestManifest(imh, imh.digestAlgorithm, sm)
 		if err != nil {
 			imh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
 			w.WriteHeader(http.StatusBadRequest)
@@ -84,22 +84,45 @@ func (imh *imageManifestHandler) GetImageManifest(w http.ResponseWriter, r *http
 	w.Header().Set("Content-Length", fmt.Sprint(len(sm.Raw)))
 	w.Header().Set("Docker-Content-Digest", imh.Digest.String())
 	w.Write(sm.Raw)
+
+	if r.Method == "GET" {
+		reference := imh.Tag
+		if reference == "" {
+			reference = imh.Digest.String()
+		}
+		imh.recordPull(imh, imh.Repository.Name(), reference)
+
+		if imh.cacheWarmer != nil {
+			imh.cacheWarmer.warm(imh.Repository.Name(), imh.Digest, sm)
+		}
+	}
 }
 
 // PutImageManifest validates and stores and image in the registry.
 func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("PutImageManifest")
 	manifests := imh.Repository.Manifests()
-	dec := json.NewDecoder(r.Body)
 
-	var manifest manifest.SignedManifest
-	if err := dec.Decode(&manifest); err != nil {
+	maxSize := imh.App.maxManifestSize
+	if r.ContentLength > maxSize {
+		imh.Errors.Push(v2.ErrorCodeManifestInvalid, manifest.ErrManifestTooLarge{MaxSize: maxSize})
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	signed, err := manifest.DecodeSignedManifest(r.Body, maxSize)
+	if err != nil {
 		imh.Errors.Push(v2.ErrorCodeManifestInvalid, err)
-		w.WriteHeader(http.StatusBadRequest)
+		if _, ok := err.(manifest.ErrManifestTooLarge); ok {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
 		return
 	}
+	manifest := *signed
 
-	dgst, err := digestManifest(imh, &manifest)
+	dgst, err := digestManifest(imh, imh.digestAlgorithm, &manifest)
 	if err != nil {
 		imh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -117,9 +140,21 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 
 		imh.Digest = dgst
 	} else if imh.Digest != "" {
+		if imh.Digest.Algorithm() != dgst.Algorithm() {
+			// Manifests are only stored under their canonical digest, so
+			// they could not be fetched by this one.
+			ctxu.GetLogger(imh).Errorf("payload digest algorithm is not canonical: %q != %q", imh.Digest.Algorithm(), dgst.Algorithm())
+			imh.Errors.Push(v2.ErrorCodeDigestInvalid, fmt.Sprintf("manifests are identified by %s digests", dgst.Algorithm()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
 		if dgst != imh.Digest {
 			ctxu.GetLogger(imh).Errorf("payload digest does match: %q != %q", dgst, imh.Digest)
-			imh.Errors.Push(v2.ErrorCodeDigestInvalid)
+			imh.Errors.Push(v2.ErrorCodeDigestInvalid, v2.DigestMismatchDetail{
+				Expected: imh.Digest,
+				Received: dgst,
+			})
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -129,6 +164,14 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
+	if !imh.checkTagQuota(w, manifests) {
+		return
+	}
+
+	if !imh.admitManifest(w, &manifest) {
+		return
+	}
+
 	if err := manifests.Put(&manifest); err != nil {
 		// TODO(stevvooe): These error handling switches really need to be
 		// handled by an app global mapper.
@@ -137,7 +180,9 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 			for _, verificationError := range err {
 				switch verificationError := verificationError.(type) {
 				case distribution.ErrUnknownLayer:
-					imh.Errors.Push(v2.ErrorCodeBlobUnknown, verificationError.FSLayer)
+					imh.Errors.Push(v2.ErrorCodeBlobUnknown, map[string]digest.Digest{
+						"digest": verificationError.FSLayer.BlobSum,
+					})
 				case distribution.ErrManifestUnverified:
 					imh.Errors.Push(v2.ErrorCodeManifestUnverified)
 				default:
@@ -172,6 +217,41 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// checkTagQuota verifies that pushing the manifest does not create more tags
+// than allowed by the quota of the namespace. If the quota would be exceeded,
+// the error is reported in the response and false is returned.
+func (imh *imageManifestHandler) checkTagQuota(w http.ResponseWriter, manifests distribution.ManifestService) bool {
+	maxTags := imh.quota().MaxTags
+	if maxTags <= 0 || imh.Tag == "" {
+		return true
+	}
+
+	tags, err := manifests.Tags()
+	if err != nil {
+		if _, ok := err.(distribution.ErrRepositoryUnknown); ok {
+			return true
+		}
+
+		imh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	for _, tag := range tags {
+		if tag == imh.Tag {
+			return true // updating an existing tag
+		}
+	}
+
+	if len(tags) >= maxTags {
+		imh.Errors.Push(v2.ErrorCodeTagInvalid, fmt.Sprintf("repository exceeds the quota of %d tags", maxTags))
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
 // DeleteImageManifest removes the image with the given tag from the registry.
 func (imh *imageManifestHandler) DeleteImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("DeleteImageManifest")
@@ -185,9 +265,10 @@ func (imh *imageManifestHandler) DeleteImageManifest(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusBadRequest)
 }
 
-// digestManifest takes a digest of the given manifest. This belongs somewhere
-// better but we'll wait for a refactoring cycle to find that real somewhere.
-func digestManifest(ctx context.Context, sm *manifest.SignedManifest) (digest.Digest, error) {
+// digestManifest takes a digest of the given manifest with the named
+// algorithm. This belongs somewhere better but we'll wait for a refactoring
+// cycle to find that real somewhere.
+func digestManifest(ctx context.Context, alg string, sm *manifest.SignedManifest) (digest.Digest, error) {
 	p, err := sm.Payload()
 	if err != nil {
 		if !strings.Contains(err.Error(), "missing signature key") {
@@ -201,7 +282,7 @@ func digestManifest(ctx context.Context, sm *manifest.SignedManifest) (digest.Di
 		p = sm.Raw
 	}
 
-	dgst, err := digest.FromBytes(p)
+	dgst, err := digest.FromBytesWithAlgorithm(alg, p)
 	if err != nil {
 		ctxu.GetLogger(ctx).Errorf("error digesting manifest: %v", err)
 		return "", err
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/version"
+	"github.com/gorilla/handlers"
+)
+
+// infoDispatcher constructs the registry information api endpoint.
+func infoDispatcher(ctx *Context, r *http.Request) http.Handler {
+	infoHandler := &infoHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(infoHandler.GetInfo),
+	}
+}
+
+// infoHandler reports the version and capabilities of the registry.
+type infoHandler struct {
+	*Context
+}
+
+type infoAPIResponse struct {
+	Version string `json:"version"`
+	Package string `json:"package"`
+
+	Storage struct {
+		Driver string `json:"driver"`
+	} `json:"storage"`
+
+	Extensions struct {
+		Delete   bool `json:"delete"`
+		Redirect bool `json:"redirect"`
+		Proxy    bool `json:"proxy"`
+
+		// RedirectDisabled lists the prefixes of the namespaces whose
+		// layers are served by the registry despite Redirect.
+		RedirectDisabled []string `json:"redirectDisabled,omitempty"`
+	} `json:"extensions"`
+
+	ManifestMediaTypes []string `json:"manifestMediaTypes"`
+
+	TrustServer string `json:"trustServer,omitempty"`
+}
+
+// GetInfo returns a json description of the registry version, its storage
+// driver and the optional features that are enabled.
+func (ih *infoHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
+	var info infoAPIResponse
+	info.Version = version.Version
+	info.Package = version.Package
+	info.Storage.Driver = ih.App.Config.Storage.Type()
+
+	// Manifest deletes are not supported and there is no proxy mode.
+	info.Extensions.Delete = false
+	info.Extensions.Proxy = false
+
+	// Layers are served with a redirect whenever the driver can provide a
+	// url for them, unless redirects are disabled for their namespace.
+	info.Extensions.Redirect = ih.App.redirect
+	if info.Extensions.Redirect {
+		for _, ns := range ih.App.namespaces {
+			if ns.Redirect.Disable {
+				info.Extensions.RedirectDisabled = append(info.Extensions.RedirectDisabled, ns.Prefix)
+			}
+		}
+	}
+
+	info.ManifestMediaTypes = []string{
+		manifest.ManifestMediaType,
+		"application/json",
+	}
+
+	info.TrustServer = ih.App.trustServer
+
+	p, err := json.Marshal(info)
+	if err != nil {
+		ih.Errors.PushErr(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
+	w.Write(p)
+}
@@ -63,6 +63,18 @@ func (lh *layerHandler) GetLayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if lh.redirectDisabled() {
+		// Serve the content directly, without asking the driver for a url.
+		if handler, ok := layer.(http.Handler); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", layer.Digest().String())
+		http.ServeContent(w, r, layer.Digest().String(), layer.CreatedAt(), layer)
+		return
+	}
+
 	handler, err := layer.Handler(r)
 	if err != nil {
 		context.GetLogger(lh).Debugf("unexpected error getting layer HTTP handler: %s", err)
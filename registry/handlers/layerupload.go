@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 
 	"github.com/docker/distribution"
 	ctxu "github.com/docker/distribution/context"
@@ -32,8 +33,13 @@ func layerUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
 	})
 
 	if luh.UUID != "" {
-		state, err := hmacKey(ctx.Config.HTTP.Secret).unpackUploadState(r.FormValue("_state"))
-		if err != nil {
+		state, err := luh.resolveUploadState(r)
+		if err == distribution.ErrLayerUploadUnknown {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				luh.Errors.Push(v2.ErrorCodeBlobUploadUnknown, err)
+			})
+		} else if err != nil {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				ctxu.GetLogger(ctx).Infof("error resolving upload: %v", err)
 				w.WriteHeader(http.StatusBadRequest)
@@ -106,6 +112,19 @@ func layerUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
 	return handler
 }
 
+// resolveUploadState returns the upload state from the _state token of the
+// request. If the token is missing or invalid, the state is resolved from the
+// upload session store instead, when one is configured.
+func (luh *layerUploadHandler) resolveUploadState(r *http.Request) (layerUploadState, error) {
+	state, err := luh.uploadStateKeys.unpackUploadState(r.FormValue("_state"))
+	if err == nil || luh.uploadSessions == nil {
+		return state, err
+	}
+
+	ctxu.GetLogger(luh).Debugf("resolving upload state from session store: %v", err)
+	return luh.uploadSessions.resolve(luh.Context, luh.UUID)
+}
+
 // layerUploadHandler handles the http layer upload process.
 type layerUploadHandler struct {
 	*Context
@@ -116,11 +135,31 @@ type layerUploadHandler struct {
 	Upload distribution.LayerUpload
 
 	State layerUploadState
+
+	// progress reports the progress of the upload, if upload events are
+	// enabled.
+	progress *uploadProgress
 }
 
 // StartLayerUpload begins the layer upload process and allocates a server-
-// side upload session.
+// side upload session. If the digest parameter is present, the upload is
+// completed in the same request with the request body as the layer data.
 func (luh *layerUploadHandler) StartLayerUpload(w http.ResponseWriter, r *http.Request) {
+	// The digest is read from the url only, so that the body is not taken
+	// for a form.
+	dgstStr := r.URL.Query().Get("digest")
+
+	var dgst digest.Digest
+	if dgstStr != "" {
+		var err error
+		dgst, err = digest.ParseDigest(dgstStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			luh.Errors.Push(v2.ErrorCodeDigestInvalid, "digest parsing failed")
+			return
+		}
+	}
+
 	layers := luh.Repository.Layers()
 	upload, err := layers.Upload()
 	if err != nil {
@@ -132,12 +171,29 @@ func (luh *layerUploadHandler) StartLayerUpload(w http.ResponseWriter, r *http.R
 	luh.Upload = upload
 	defer luh.Upload.Close()
 
+	if dgst != "" {
+		// Monolithic upload: the whole layer is in the request body.
+		w.Header().Set("Docker-Upload-UUID", luh.Upload.UUID())
+		if !luh.copyLayerData(w, r, true) {
+			return
+		}
+
+		luh.finishLayerUpload(w, r, dgst)
+		return
+	}
+
 	if err := luh.layerUploadResponse(w, r, true); err != nil {
 		w.WriteHeader(http.StatusInternalServerError) // Error conditions here?
 		luh.Errors.Push(v2.ErrorCodeUnknown, err)
 		return
 	}
 
+	if err := luh.updateUploadSession(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		luh.Errors.Push(v2.ErrorCodeUnknown, err)
+		return
+	}
+
 	w.Header().Set("Docker-Upload-UUID", luh.Upload.UUID())
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -174,17 +230,17 @@ func (luh *layerUploadHandler) PatchLayerData(w http.ResponseWriter, r *http.Req
 	ct := r.Header.Get("Content-Type")
 	if ct != "" && ct != "application/octet-stream" {
 		w.WriteHeader(http.StatusBadRequest)
-		// TODO(dmcgowan): encode error
+		luh.Errors.Push(v2.ErrorCodeBlobUploadInvalid, v2.MediaTypeDetail{
+			MediaType: ct,
+			Accepted:  []string{"application/octet-stream"},
+		})
 		return
 	}
 
 	// TODO(dmcgowan): support Content-Range header to seek and write range
 
 	// Copy the data
-	if _, err := io.Copy(luh.Upload, r.Body); err != nil {
-		ctxu.GetLogger(luh).Errorf("unknown error copying into upload: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		luh.Errors.Push(v2.ErrorCodeUnknown, err)
+	if !luh.copyLayerData(w, r, false) {
 		return
 	}
 
@@ -194,6 +250,12 @@ func (luh *layerUploadHandler) PatchLayerData(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if err := luh.updateUploadSession(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		luh.Errors.Push(v2.ErrorCodeUnknown, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusAccepted)
 }
 
@@ -231,19 +293,45 @@ func (luh *layerUploadHandler) PutLayerUploadComplete(w http.ResponseWriter, r *
 	// may miss a root cause.
 
 	// Read in the data, if any.
-	if _, err := io.Copy(luh.Upload, r.Body); err != nil {
-		ctxu.GetLogger(luh).Errorf("unknown error copying into upload: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		luh.Errors.Push(v2.ErrorCodeUnknown, err)
+	if !luh.copyLayerData(w, r, true) {
 		return
 	}
 
+	luh.finishLayerUpload(w, r, dgst)
+}
+
+// contentEncodingRegexp matches a Content-Encoding header value: a list of
+// comma separated content codings, which are http tokens.
+var contentEncodingRegexp = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+(?: *, *[A-Za-z0-9!#$%&'*+.^_`|~-]+)*$")
+
+// finishLayerUpload verifies the uploaded data against dgst and links the
+// layer into the repository, with the Content-Encoding of the request if any,
+// responding with 201 Created and the canonical url of the layer. If the
+// layer fails verification, the upload is canceled.
+func (luh *layerUploadHandler) finishLayerUpload(w http.ResponseWriter, r *http.Request, dgst digest.Digest) {
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" {
+		if !contentEncodingRegexp.MatchString(encoding) {
+			// Allow retrying with a valid encoding.
+			w.WriteHeader(http.StatusBadRequest)
+			luh.Errors.Push(v2.ErrorCodeBlobUploadInvalid, fmt.Sprintf("invalid content encoding %q", encoding))
+			return
+		}
+
+		luh.Upload.SetContentEncoding(encoding)
+	}
+
 	layer, err := luh.Upload.Finish(dgst)
 	if err != nil {
 		switch err := err.(type) {
 		case distribution.ErrLayerInvalidDigest:
 			w.WriteHeader(http.StatusBadRequest)
-			luh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
+			luh.Errors.Push(v2.ErrorCodeDigestInvalid, v2.DigestMismatchDetail{
+				Expected: err.Digest,
+				Received: err.Received,
+			})
+		case distribution.ErrLayerRejected:
+			w.WriteHeader(http.StatusBadRequest)
+			luh.Errors.Push(v2.ErrorCodeBlobUploadInvalid, err.Error())
 		default:
 			ctxu.GetLogger(luh).Errorf("unknown error completing upload: %#v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -255,9 +343,15 @@ func (luh *layerUploadHandler) PutLayerUploadComplete(w http.ResponseWriter, r *
 			// If the cleanup fails, all we can do is observe and report.
 			ctxu.GetLogger(luh).Errorf("error canceling upload after error: %v", err)
 		}
+		luh.removeUploadSession()
 
 		return
 	}
+	luh.removeUploadSession()
+
+	if luh.progress != nil {
+		luh.progress.complete(layer)
+	}
 
 	// Build our canonical layer url
 	layerURL, err := luh.urlBuilder.BuildBlobURL(luh.Repository.Name(), layer.Digest())
@@ -287,10 +381,99 @@ func (luh *layerUploadHandler) CancelLayerUpload(w http.ResponseWriter, r *http.
 		w.WriteHeader(http.StatusInternalServerError)
 		luh.Errors.PushErr(err)
 	}
+	luh.removeUploadSession()
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// updateUploadSession records the state of the upload after data was
+// written, if upload sessions are configured. The state of status requests
+// is not recorded, since it always has a zero offset.
+func (luh *layerUploadHandler) updateUploadSession() error {
+	if luh.uploadSessions == nil {
+		return nil
+	}
+
+	if err := luh.uploadSessions.update(luh.Context, luh.State); err != nil {
+		ctxu.GetLogger(luh).Errorf("error updating upload session: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// removeUploadSession drops the session of the upload once it is completed
+// or canceled. Sessions left behind expire or are ignored, so errors are only
+// logged.
+func (luh *layerUploadHandler) removeUploadSession() {
+	if luh.uploadSessions == nil {
+		return
+	}
+
+	if err := luh.uploadSessions.remove(luh.Context, luh.Upload.UUID()); err != nil {
+		ctxu.GetLogger(luh).Errorf("error removing upload session: %v", err)
+	}
+}
+
+// copyLayerData copies the body of r into the upload, enforcing the layer
+// size quota of the namespace. If the copy fails or the quota is exceeded,
+// the error is reported in the response and false is returned. The final
+// argument is true when the body completes the layer, so that its expected
+// size is known from the content length for the upload events.
+func (luh *layerUploadHandler) copyLayerData(w http.ResponseWriter, r *http.Request, final bool) bool {
+	maxSize := luh.quota().MaxLayerSize
+	body := io.Reader(r.Body)
+
+	var offset int64
+	if maxSize > 0 || luh.uploadEvents != nil {
+		var err error
+		offset, err = luh.Upload.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			ctxu.GetLogger(luh).Errorf("unable get current offset of layer upload: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			luh.Errors.Push(v2.ErrorCodeUnknown, err)
+			return false
+		}
+	}
+
+	size := int64(-1)
+	if final && r.ContentLength >= 0 {
+		size = offset + r.ContentLength
+	}
+
+	luh.progress = luh.trackUpload(r, size)
+	if luh.progress != nil {
+		body = &progressReader{Reader: body, progress: luh.progress, received: offset}
+	}
+
+	if maxSize > 0 {
+		// Read one byte past the quota to detect oversized layers without
+		// storing their whole contents.
+		body = io.LimitReader(body, maxSize-offset+1)
+	}
+
+	n, err := io.Copy(luh.Upload, body)
+	if err != nil {
+		ctxu.GetLogger(luh).Errorf("unknown error copying into upload: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		luh.Errors.Push(v2.ErrorCodeUnknown, err)
+		return false
+	}
+
+	if maxSize > 0 && offset+n > maxSize {
+		if err := luh.Upload.Cancel(); err != nil {
+			ctxu.GetLogger(luh).Errorf("error canceling upload after exceeding quota: %v", err)
+		}
+		luh.removeUploadSession()
+
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		luh.Errors.Push(v2.ErrorCodeSizeInvalid, fmt.Sprintf("layer exceeds the quota of %d bytes", maxSize))
+		return false
+	}
+
+	return true
+}
+
 // layerUploadResponse provides a standard request for uploading layers and
 // chunk responses. This sets the correct headers but the response status is
 // left to the caller. The fresh argument is used to ensure that new layer
@@ -314,7 +497,7 @@ func (luh *layerUploadHandler) layerUploadResponse(w http.ResponseWriter, r *htt
 	luh.State.Offset = offset
 	luh.State.StartedAt = luh.Upload.StartedAt()
 
-	token, err := hmacKey(luh.Config.HTTP.Secret).packUploadState(luh.State)
+	token, err := luh.uploadStateKeys.packUploadState(luh.State)
 	if err != nil {
 		ctxu.GetLogger(luh).Infof("error building upload state token: %s", err)
 		return err
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultLeaderTTL is the time for which an instance stays the leader
+	// without renewing its lock, unless configured otherwise.
+	defaultLeaderTTL = time.Minute
+
+	// leaderLockPath is the path of the leader lock in the storage backend.
+	leaderLockPath = "/docker/registry/v2/leader"
+
+	// leaderLockKey is the redis key of the leader lock.
+	leaderLockKey = "leader"
+)
+
+// leaderLock elects the registry instance running the background jobs among
+// the instances sharing the storage backend.
+type leaderLock interface {
+	// acquire takes the lock for the instance, or renews it if the instance
+	// holds it already, for ttl. It returns whether the instance holds the
+	// lock.
+	acquire(ctx context.Context, instanceID string, ttl time.Duration) (bool, error)
+}
+
+// acquireRedisLeaderLock sets the lock to the instance if it is free, or
+// extends it if the instance holds it, in a single step.
+var acquireRedisLeaderLock = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// redisLeaderLock keeps the ID of the leader in a redis key which expires
+// unless renewed.
+type redisLeaderLock struct {
+	pool *redis.Pool
+}
+
+func (rll *redisLeaderLock) acquire(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	conn := rll.pool.Get()
+	defer conn.Close()
+
+	held, err := redis.Int(acquireRedisLeaderLock.Do(conn, leaderLockKey, instanceID, int64(ttl/time.Millisecond)))
+	if err != nil {
+		return false, err
+	}
+
+	return held == 1, nil
+}
+
+// storageLeaderLock keeps the ID of the leader and the expiry of its lock in
+// a file of the storage backend. The storage backends do not support
+// conditional writes, so the lock is written and then read back: concurrent
+// instances may both take a free lock, but only the last writer keeps it
+// after the next renewal.
+type storageLeaderLock struct {
+	driver storagedriver.StorageDriver
+}
+
+// storageLeaderLockRecord is the content of the leader lock file.
+type storageLeaderLockRecord struct {
+	InstanceID string    `json:"instanceid"`
+	Expires    time.Time `json:"expires"`
+}
+
+func (sll *storageLeaderLock) acquire(ctx context.Context, instanceID string, ttl time.Duration) (bool, error) {
+	current, err := sll.read(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if current.InstanceID != instanceID && current.Expires.After(now) {
+		return false, nil
+	}
+
+	p, err := json.Marshal(storageLeaderLockRecord{
+		InstanceID: instanceID,
+		Expires:    now.Add(ttl),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := sll.driver.PutContent(ctx, leaderLockPath, p); err != nil {
+		return false, err
+	}
+
+	written, err := sll.read(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return written.InstanceID == instanceID, nil
+}
+
+// read returns the lock record, which is empty if the lock was never taken.
+func (sll *storageLeaderLock) read(ctx context.Context) (storageLeaderLockRecord, error) {
+	var record storageLeaderLockRecord
+
+	p, err := sll.driver.GetContent(ctx, leaderLockPath)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return record, nil
+		}
+		return record, err
+	}
+
+	if err := json.Unmarshal(p, &record); err != nil {
+		return record, fmt.Errorf("invalid leader lock at %s: %v", leaderLockPath, err)
+	}
+
+	return record, nil
+}
+
+// configureCoordination starts the election of the instance running the
+// background jobs, if a leader lock is configured. Otherwise, every instance
+// runs them. It panics on an unknown lock, like the other app configuration
+// steps.
+func (app *App) configureCoordination(config configuration.Coordination) {
+	var lock leaderLock
+	switch config.Lock {
+	case "":
+		atomic.StoreInt32(&app.leader, 1)
+		return
+	case "storage":
+		lock = &storageLeaderLock{driver: app.driver}
+	case "redis":
+		if app.redis == nil {
+			panic("redis configuration required to use for the leader lock")
+		}
+		lock = &redisLeaderLock{pool: app.redis}
+	default:
+		panic(fmt.Sprintf("unsupported leader lock: %q", config.Lock))
+	}
+
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+
+	ctxu.GetLogger(app).Infof("electing the leader instance with the %s lock", config.Lock)
+
+	app.elect(lock, ttl)
+	go func() {
+		// Renew the lock well before it expires, so that a slow renewal
+		// does not hand it over.
+		for range time.Tick(ttl / 3) {
+			app.elect(lock, ttl)
+		}
+	}()
+}
+
+// elect takes or renews the leader lock and records whether this instance is
+// the leader. The instance stops being the leader if the lock cannot be
+// reached, since another instance may take it when it expires.
+func (app *App) elect(lock leaderLock, ttl time.Duration) {
+	instanceID := ctxu.GetStringValue(app, "instance.id")
+
+	held, err := lock.acquire(app, instanceID, ttl)
+	if err != nil {
+		ctxu.GetLogger(app).Errorf("error acquiring the leader lock: %v", err)
+	}
+
+	var leader int32
+	if held {
+		leader = 1
+	}
+
+	if previous := atomic.SwapInt32(&app.leader, leader); previous != leader {
+		if held {
+			ctxu.GetLogger(app).Infof("instance %s is the leader", instanceID)
+		} else {
+			ctxu.GetLogger(app).Infof("instance %s is no longer the leader", instanceID)
+		}
+	}
+}
+
+// IsLeader returns true if this instance runs the background jobs, which
+// only one of the instances sharing the storage backend does when a leader
+// lock is configured.
+func (app *App) IsLeader() bool {
+	return atomic.LoadInt32(&app.leader) != 0
+}
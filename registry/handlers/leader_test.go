@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+// TestStorageLeaderLock checks that only one instance holds the storage
+// leader lock until it expires.
+func TestStorageLeaderLock(t *testing.T) {
+	ctx := context.Background()
+	lock := &storageLeaderLock{driver: inmemory.New()}
+	ttl := 100 * time.Millisecond
+
+	acquire := func(instanceID string, expected bool) {
+		held, err := lock.acquire(ctx, instanceID, ttl)
+		if err != nil {
+			t.Fatalf("unexpected error acquiring lock for %s: %v", instanceID, err)
+		}
+
+		if held != expected {
+			t.Fatalf("unexpected lock state for %s: %v != %v", instanceID, held, expected)
+		}
+	}
+
+	acquire("a", true)
+	acquire("b", false)
+
+	// The holder renews the lock.
+	acquire("a", true)
+	acquire("b", false)
+
+	time.Sleep(2 * ttl)
+
+	// Another instance takes over the expired lock.
+	acquire("b", true)
+	acquire("a", false)
+}
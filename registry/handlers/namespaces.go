@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+)
+
+// namespace holds the configuration overrides applied to the repositories of
+// a namespace.
+type namespace struct {
+	configuration.Namespace
+
+	// sink receives the events of the namespace, including delivery to the
+	// global endpoints.
+	sink notifications.Sink
+
+	// pullNetworks and pushNetworks restrict the client addresses of pulls
+	// and pushes.
+	pullNetworks networkRule
+	pushNetworks networkRule
+}
+
+// matches returns true if the repository name belongs to the namespace.
+func (ns *namespace) matches(name string) bool {
+	if strings.HasSuffix(ns.Prefix, "/*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(ns.Prefix, "*"))
+	}
+
+	return name == ns.Prefix
+}
+
+// configureNamespaces prepares the namespace overrides. It must be called
+// after the events are configured.
+func (app *App) configureNamespaces(configuration *configuration.Configuration) {
+	for _, config := range configuration.Namespaces {
+		ns := &namespace{
+			Namespace: config,
+			sink:      app.events.sink,
+		}
+
+		var err error
+		if ns.pullNetworks, err = newNetworkRule(config.Network.Pull); err != nil {
+			panic(fmt.Sprintf("invalid pull network policy for namespace %q: %v", config.Prefix, err))
+		}
+		if ns.pushNetworks, err = newNetworkRule(config.Network.Push); err != nil {
+			panic(fmt.Sprintf("invalid push network policy for namespace %q: %v", config.Prefix, err))
+		}
+
+		if sinks := app.endpointSinks(config.Notifications.Endpoints); len(sinks) > 0 {
+			ns.sink = app.deduplicateEvents(notifications.NewBroadcaster(append([]notifications.Sink{app.events.sink}, sinks...)...))
+		}
+
+		ctxu.GetLogger(app).Infof("configuring namespace %q", config.Prefix)
+		app.namespaces = append(app.namespaces, ns)
+	}
+}
+
+// namespace returns the namespace of the named repository, or nil if no
+// namespace is configured for it.
+func (app *App) namespace(name string) *namespace {
+	for _, ns := range app.namespaces {
+		if ns.matches(name) {
+			return ns
+		}
+	}
+
+	return nil
+}
+
+// quota returns the quota applicable to the repository of the request.
+func (ctx *Context) quota() configuration.Quota {
+	if ctx.namespace == nil {
+		return configuration.Quota{}
+	}
+
+	return ctx.namespace.Quota
+}
+
+// redirectDisabled returns true if layers of the repository of the request
+// must be served by the registry rather than through a redirect.
+func (ctx *Context) redirectDisabled() bool {
+	return ctx.namespace != nil && ctx.namespace.Redirect.Disable
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// redirectingDriver provides urls as a storage backend able to redirect
+// clients does.
+type redirectingDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *redirectingDriver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	return "https://storage.example.com" + path, nil
+}
+
+func init() {
+	storagemiddleware.Register("redirecting", func(driver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+		return &redirectingDriver{StorageDriver: driver}, nil
+	})
+}
+
+func TestNamespaceMatches(t *testing.T) {
+	for _, testcase := range []struct {
+		prefix  string
+		name    string
+		matches bool
+	}{
+		{"team-a/*", "team-a/app", true},
+		{"team-a/*", "team-a/group/app", true},
+		{"team-a/*", "team-a", false},
+		{"team-a/*", "team-ab/app", false},
+		{"team-a/app", "team-a/app", true},
+		{"team-a/app", "team-a/app2", false},
+	} {
+		ns := &namespace{Namespace: configuration.Namespace{Prefix: testcase.prefix}}
+		if ns.matches(testcase.name) != testcase.matches {
+			t.Errorf("unexpected match of %q against %q: %t", testcase.name, testcase.prefix, !testcase.matches)
+		}
+	}
+}
+
+// TestNamespaceLayerQuota checks that layers larger than the quota of their
+// namespace are rejected, while other namespaces are unaffected.
+func TestNamespaceLayerQuota(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Namespaces: []configuration.Namespace{
+			{
+				Prefix: "limited/*",
+				Quota: configuration.Quota{
+					MaxLayerSize: 8,
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	content := []byte("more than eight bytes")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, "limited/app")
+	resp, err := doPushLayer(t, env.builder, "limited/app", dgst, uploadURLBase, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error pushing layer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "pushing layer over quota", resp, http.StatusRequestEntityTooLarge)
+	checkBodyHasErrorCodes(t, "pushing layer over quota", resp, v2.ErrorCodeSizeInvalid)
+
+	uploadURLBase, _ = startPushLayer(t, env.builder, "unlimited/app")
+	pushLayer(t, env.builder, "unlimited/app", dgst, uploadURLBase, bytes.NewReader(content))
+}
+
+// TestNamespaceNetworkPolicy checks that the network policy of a namespace
+// rejects clients by address, separately for pulls and pushes. The test
+// client connects from the loopback address.
+func TestNamespaceNetworkPolicy(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Namespaces: []configuration.Namespace{
+			{
+				Prefix: "internal/*",
+				Network: configuration.NetworkPolicy{
+					Pull: configuration.NetworkRule{Allow: []string{"10.0.0.0/8"}},
+				},
+			},
+			{
+				Prefix: "mirror/*",
+				Network: configuration.NetworkPolicy{
+					Push: configuration.NetworkRule{Deny: []string{"127.0.0.1", "::1"}},
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	for _, testcase := range []struct {
+		name   string
+		method string
+		status int
+	}{
+		{"internal/app", "GET", http.StatusForbidden},
+		{"internal/app", "POST", http.StatusAccepted},
+		{"mirror/app", "GET", http.StatusNotFound},
+		{"mirror/app", "POST", http.StatusForbidden},
+		{"other/app", "POST", http.StatusAccepted},
+	} {
+		var (
+			u   string
+			err error
+		)
+		if testcase.method == "GET" {
+			u, err = env.builder.BuildTagsURL(testcase.name)
+		} else {
+			u, err = env.builder.BuildBlobUploadURL(testcase.name)
+		}
+		checkErr(t, err, "building url")
+
+		req, err := http.NewRequest(testcase.method, u, nil)
+		checkErr(t, err, "creating request")
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, "sending request")
+		defer resp.Body.Close()
+
+		checkResponse(t, testcase.method+" "+testcase.name, resp, testcase.status)
+		if testcase.status == http.StatusForbidden {
+			checkBodyHasErrorCodes(t, testcase.method+" "+testcase.name, resp, v2.ErrorCodeUnauthorized)
+		}
+	}
+}
+
+// TestNamespaceNetworkPolicyTrustedProxy checks that the network policy of a
+// namespace applies to the client address reported by trusted proxies, and
+// ignores the forwarding headers of other clients. The test client connects
+// from the loopback address, as a trusted proxy would.
+func TestNamespaceNetworkPolicyTrustedProxy(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Namespaces: []configuration.Namespace{
+			{
+				Prefix: "internal/*",
+				Network: configuration.NetworkPolicy{
+					Pull: configuration.NetworkRule{Allow: []string{"10.0.0.0/8"}},
+				},
+			},
+		},
+	}
+	config.HTTP.TrustedProxies = []string{"127.0.0.1", "::1"}
+	env := newTestEnvWithConfig(t, &config)
+
+	u, err := env.builder.BuildTagsURL("internal/app")
+	checkErr(t, err, "building url")
+
+	for _, testcase := range []struct {
+		description string
+		headers     map[string]string
+		status      int
+	}{
+		{"without forwarding headers", nil, http.StatusForbidden},
+		{"internal client", map[string]string{"X-Forwarded-For": "10.1.2.3"}, http.StatusNotFound},
+		{"external client", map[string]string{"X-Forwarded-For": "192.0.2.1"}, http.StatusForbidden},
+		{"spoofed internal client", map[string]string{"X-Forwarded-For": "10.1.2.3, 192.0.2.1"}, http.StatusForbidden},
+		{"internal client in forwarded header", map[string]string{"Forwarded": "for=10.1.2.3"}, http.StatusNotFound},
+	} {
+		req, err := http.NewRequest("GET", u, nil)
+		checkErr(t, err, "creating request")
+		for name, value := range testcase.headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, "sending request")
+		resp.Body.Close()
+
+		checkResponse(t, testcase.description, resp, testcase.status)
+	}
+}
+
+// TestNamespaceRedirectInfo checks that the info endpoint reports redirects
+// when the storage driver provides urls, along with the namespaces disabling
+// them.
+func TestNamespaceRedirectInfo(t *testing.T) {
+	disabled := configuration.Namespace{Prefix: "team-a/*"}
+	disabled.Redirect.Disable = true
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Middleware: map[string][]configuration.Middleware{
+			"storage": {{Name: "redirecting"}},
+		},
+		Namespaces: []configuration.Namespace{
+			disabled,
+			{Prefix: "team-b/*"},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	infoURL, err := env.builder.BuildInfoURL()
+	checkErr(t, err, "building info url")
+
+	resp, err := http.Get(infoURL)
+	checkErr(t, err, "issuing info request")
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing info request", resp, http.StatusOK)
+
+	var info infoAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("unexpected error decoding info response: %v", err)
+	}
+
+	if !info.Extensions.Redirect {
+		t.Fatalf("expected redirects to be reported: %#v", info.Extensions)
+	}
+
+	if !reflect.DeepEqual(info.Extensions.RedirectDisabled, []string{"team-a/*"}) {
+		t.Fatalf("unexpected namespaces disabling redirects: %v", info.Extensions.RedirectDisabled)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/listener"
+)
+
+// networkRule is a parsed configuration.NetworkRule.
+type networkRule struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// newNetworkRule parses the networks of rule.
+func newNetworkRule(rule configuration.NetworkRule) (networkRule, error) {
+	var (
+		nr  networkRule
+		err error
+	)
+
+	if nr.allow, err = listener.ParseNetworks(rule.Allow); err != nil {
+		return networkRule{}, err
+	}
+	if nr.deny, err = listener.ParseNetworks(rule.Deny); err != nil {
+		return networkRule{}, err
+	}
+
+	return nr, nil
+}
+
+// allows returns true if the rule allows access from ip.
+func (nr networkRule) allows(ip net.IP) bool {
+	for _, ipnet := range nr.deny {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(nr.allow) == 0 {
+		return true
+	}
+
+	for _, ipnet := range nr.allow {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkAllowed returns true if the network policy of the namespace of the
+// request allows its client address. The address is the one reported by the
+// trusted proxies forwarding the request, or the one of the peer connection
+// otherwise, since forwarding headers can be set by any client.
+func (ctx *Context) networkAllowed(r *http.Request) bool {
+	if ctx.namespace == nil {
+		return true
+	}
+
+	rule := ctx.namespace.pushNetworks
+	if r.Method == "GET" || r.Method == "HEAD" {
+		rule = ctx.namespace.pullNetworks
+	}
+
+	// Proxies are only trusted if configured, unlike for building urls.
+	ip := v2.ClientIP(r, ctx.App.trustsProxy)
+	if ip == nil {
+		// Unknown addresses are only allowed without restrictions.
+		return len(rule.allow) == 0 && len(rule.deny) == 0
+	}
+
+	return rule.allows(ip)
+}
+
+// configureTrustedProxies parses the networks of the trusted proxies. It
+// panics on an invalid network, like the other app configuration steps.
+func (app *App) configureTrustedProxies(proxies []string) {
+	if len(proxies) == 0 {
+		return
+	}
+
+	nets, err := listener.ParseNetworks(proxies)
+	if err != nil {
+		panic(fmt.Sprintf("invalid trusted proxies: %v", err))
+	}
+	app.trustedProxies = nets
+}
+
+// trustsProxy returns true if ip is the address of a trusted proxy.
+func (app *App) trustsProxy(ip net.IP) bool {
+	for _, ipnet := range app.trustedProxies {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+const (
+	// pullStatsDayFormat formats the day of the daily pull counters.
+	pullStatsDayFormat = "2006-01-02"
+
+	// defaultPullStatsRetention is the time for which redis keeps the daily
+	// pull counters, unless configured otherwise.
+	defaultPullStatsRetention = 90 * 24 * time.Hour
+
+	// pullStatsFlushInterval is the interval at which the pull counters are
+	// written to the storage backend.
+	pullStatsFlushInterval = time.Minute
+
+	// pullStatsRoot is the path of the pull counters in the storage backend.
+	pullStatsRoot = "/docker/registry/v2/pullstats"
+)
+
+// pullStatsStore counts the manifest pulls of each tag of a repository by
+// day.
+type pullStatsStore interface {
+	// record counts a pull of the manifest of the tag of the repository.
+	record(ctx context.Context, repo, tag string, at time.Time) error
+
+	// counts returns the number of pulls of each tag of the repository on
+	// the days from since to now.
+	counts(ctx context.Context, repo string, since time.Time) (map[string]int64, error)
+}
+
+// pullStatsDays returns the days from since to now.
+func pullStatsDays(since, now time.Time) []string {
+	var days []string
+	since = since.UTC().Truncate(24 * time.Hour)
+	for day := since; !day.After(now.UTC()); day = day.Add(24 * time.Hour) {
+		days = append(days, day.Format(pullStatsDayFormat))
+	}
+	return days
+}
+
+// redisPullStats keeps the pull counters of each repository and day in a
+// redis hash keyed by tag, which expires after the retention period.
+type redisPullStats struct {
+	pool      *redis.Pool
+	retention time.Duration
+}
+
+func (rps *redisPullStats) record(ctx context.Context, repo, tag string, at time.Time) error {
+	conn := rps.pool.Get()
+	defer conn.Close()
+
+	key := rps.pullStatsHashKey(repo, at.UTC().Format(pullStatsDayFormat))
+
+	conn.Send("MULTI")
+	conn.Send("HINCRBY", key, tag, 1)
+	conn.Send("EXPIRE", key, int64(rps.retention/time.Second))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (rps *redisPullStats) counts(ctx context.Context, repo string, since time.Time) (map[string]int64, error) {
+	conn := rps.pool.Get()
+	defer conn.Close()
+
+	counts := make(map[string]int64)
+	for _, day := range pullStatsDays(since, time.Now()) {
+		reply, err := redis.Values(conn.Do("HGETALL", rps.pullStatsHashKey(repo, day)))
+		if err != nil {
+			return nil, err
+		}
+
+		for len(reply) > 0 {
+			var tag string
+			var count int64
+			reply, err = redis.Scan(reply, &tag, &count)
+			if err != nil {
+				return nil, err
+			}
+			counts[tag] += count
+		}
+	}
+
+	return counts, nil
+}
+
+func (rps *redisPullStats) pullStatsHashKey(repo, day string) string {
+	return "pullstats::" + repo + "::" + day
+}
+
+// storagePullStats counts pulls in memory and periodically writes the
+// counters of the instance to the storage backend, one file per repository,
+// day and instance, so that instances never write the same file. Pulls
+// counted since the last write are lost if the instance stops.
+type storagePullStats struct {
+	driver     storagedriver.StorageDriver
+	instanceID string
+
+	// flushMu serializes the writes of the counters.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]map[string]map[string]int64 // repo -> day -> tag -> count
+	dirty   map[string]map[string]bool             // repo -> day -> changed
+}
+
+func newStoragePullStats(ctx context.Context, driver storagedriver.StorageDriver) *storagePullStats {
+	sps := &storagePullStats{
+		driver:     driver,
+		instanceID: ctxu.GetStringValue(ctx, "instance.id"),
+		pending:    make(map[string]map[string]map[string]int64),
+		dirty:      make(map[string]map[string]bool),
+	}
+
+	if sps.instanceID == "" {
+		sps.instanceID = uuid.New()
+	}
+
+	go func() {
+		ticker := time.NewTicker(pullStatsFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := sps.flush(ctx); err != nil {
+					ctxu.GetLogger(ctx).Errorf("error writing pull statistics: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return sps
+}
+
+func (sps *storagePullStats) record(ctx context.Context, repo, tag string, at time.Time) error {
+	sps.mu.Lock()
+	defer sps.mu.Unlock()
+
+	day := at.UTC().Format(pullStatsDayFormat)
+
+	days, ok := sps.pending[repo]
+	if !ok {
+		days = make(map[string]map[string]int64)
+		sps.pending[repo] = days
+	}
+
+	if _, ok := sps.dirty[repo]; !ok {
+		sps.dirty[repo] = make(map[string]bool)
+	}
+
+	if _, ok := days[day]; !ok {
+		days[day] = make(map[string]int64)
+	}
+
+	days[day][tag]++
+	sps.dirty[repo][day] = true
+	return nil
+}
+
+// flush writes the changed counters to the storage backend. Counters of
+// past days are dropped from memory once written. The counters are copied
+// under the lock and written outside it, so that pulls are not held up by
+// the backend, and flushes are serialized so that older copies never
+// overwrite newer ones.
+func (sps *storagePullStats) flush(ctx context.Context) error {
+	sps.flushMu.Lock()
+	defer sps.flushMu.Unlock()
+
+	type dayCounts struct {
+		repo, day string
+		counts    map[string]int64
+	}
+
+	sps.mu.Lock()
+	today := time.Now().UTC().Format(pullStatsDayFormat)
+	var changed []dayCounts
+	for repo, days := range sps.dirty {
+		for day := range days {
+			counts := make(map[string]int64, len(sps.pending[repo][day]))
+			for tag, count := range sps.pending[repo][day] {
+				counts[tag] = count
+			}
+			changed = append(changed, dayCounts{repo: repo, day: day, counts: counts})
+		}
+	}
+	sps.dirty = make(map[string]map[string]bool)
+	sps.mu.Unlock()
+
+	for i, dc := range changed {
+		p, err := json.Marshal(dc.counts)
+		if err == nil {
+			err = sps.driver.PutContent(ctx, sps.path(dc.repo, dc.day, sps.instanceID), p)
+		}
+
+		if err != nil {
+			// The counters which were not written are written by the next
+			// flush.
+			sps.mu.Lock()
+			for _, dc := range changed[i:] {
+				if sps.dirty[dc.repo] == nil {
+					sps.dirty[dc.repo] = make(map[string]bool)
+				}
+				sps.dirty[dc.repo][dc.day] = true
+			}
+			sps.mu.Unlock()
+			return err
+		}
+	}
+
+	sps.mu.Lock()
+	for _, dc := range changed {
+		if dc.day < today && !sps.dirty[dc.repo][dc.day] {
+			delete(sps.pending[dc.repo], dc.day)
+		}
+
+		if len(sps.pending[dc.repo]) == 0 {
+			delete(sps.pending, dc.repo)
+			delete(sps.dirty, dc.repo)
+		}
+	}
+	sps.mu.Unlock()
+
+	return nil
+}
+
+func (sps *storagePullStats) counts(ctx context.Context, repo string, since time.Time) (map[string]int64, error) {
+	// Pending counters of this instance are written first, so that they
+	// are included.
+	if err := sps.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	for _, day := range pullStatsDays(since, time.Now()) {
+		instances, err := sps.driver.List(ctx, sps.path(repo, day))
+		if err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, instance := range instances {
+			p, err := sps.driver.GetContent(ctx, instance)
+			if err != nil {
+				return nil, err
+			}
+
+			var dayCounts map[string]int64
+			if err := json.Unmarshal(p, &dayCounts); err != nil {
+				return nil, fmt.Errorf("invalid pull statistics at %s: %v", instance, err)
+			}
+
+			for tag, count := range dayCounts {
+				counts[tag] += count
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+func (sps *storagePullStats) path(elem ...string) string {
+	return path.Join(append([]string{pullStatsRoot}, elem...)...)
+}
+
+// configurePullStats selects the store of the pull statistics from the
+// configuration. It panics on an unknown store, like the other app
+// configuration steps.
+func (app *App) configurePullStats(config configuration.PullStats) {
+	switch config.Store {
+	case "":
+		return
+	case "storage":
+		app.pullStats = newStoragePullStats(app, app.driver)
+	case "redis":
+		if app.redis == nil {
+			panic("redis configuration required to use for pull statistics")
+		}
+
+		retention := config.Retention
+		if retention <= 0 {
+			retention = defaultPullStatsRetention
+		}
+		app.pullStats = &redisPullStats{pool: app.redis, retention: retention}
+	default:
+		panic(fmt.Sprintf("unsupported pull statistics store: %q", config.Store))
+	}
+
+	ctxu.GetLogger(app).Infof("collecting pull statistics in %s", config.Store)
+}
+
+// recordPull counts a pull of the manifest of the tag, if pull statistics are
+// enabled. Failures are only logged, since they must not fail the pull.
+func (app *App) recordPull(ctx context.Context, repo, tag string) {
+	if app.pullStats == nil {
+		return
+	}
+
+	if err := app.pullStats.record(ctx, repo, tag, time.Now()); err != nil {
+		ctxu.GetLogger(ctx).Errorf("error recording pull of %s:%s: %v", repo, tag, err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/testutil"
+)
+
+// TestPullStats pulls one of two tags and checks the pulls reported by the
+// admin interface.
+func TestPullStats(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		PullStats: configuration.PullStats{
+			Store: "storage",
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/stats"
+
+	rs, dgstStr, err := testutil.CreateRandomTarFile()
+	checkErr(t, err, "creating random layer")
+	layerDigest := digest.Digest(dgstStr)
+
+	layerContent, err := ioutil.ReadAll(rs)
+	checkErr(t, err, "reading random layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, bytes.NewReader(layerContent))
+
+	for _, tag := range []string{"latest", "old"} {
+		signedManifest, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: imageName,
+			Tag:  tag,
+			FSLayers: []manifest.FSLayer{
+				{BlobSum: layerDigest},
+			},
+		}, env.pk)
+		checkErr(t, err, "signing manifest")
+
+		manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+		checkErr(t, err, "building manifest url")
+
+		resp := putManifest(t, "putting signed manifest", manifestURL, signedManifest)
+		defer resp.Body.Close()
+		checkResponse(t, "putting signed manifest", resp, http.StatusAccepted)
+	}
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, "latest")
+	checkErr(t, err, "building manifest url")
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(manifestURL)
+		checkErr(t, err, "fetching manifest")
+		defer resp.Body.Close()
+		checkResponse(t, "fetching manifest", resp, http.StatusOK)
+	}
+
+	adminApp, err := NewAdminApp(env.app, nil)
+	checkErr(t, err, "creating admin app")
+	adminServer := httptest.NewServer(adminApp)
+	defer adminServer.Close()
+
+	resp, err := http.Get(adminServer.URL + "/admin/v1/pullstats?repository=" + imageName)
+	checkErr(t, err, "fetching pull statistics")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching pull statistics", resp, http.StatusOK)
+
+	var stats adminPullStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("error decoding pull statistics: %v", err)
+	}
+
+	expected := map[string]int64{"latest": 2, "old": 0}
+	if !reflect.DeepEqual(stats.Pulls, expected) {
+		t.Fatalf("unexpected pulls: %v != %v", stats.Pulls, expected)
+	}
+
+	resp, err = http.Get(adminServer.URL + "/admin/v1/pullstats?repository=foo/unknown")
+	checkErr(t, err, "fetching pull statistics")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching pull statistics of unknown repository", resp, http.StatusNotFound)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/testutil"
+)
+
+// TestReplication pushes an image to a registry replicating to a peer
+// requiring authentication and checks that the manifest and its layers
+// arrive on the peer, while repositories that are not selected are left
+// alone.
+func TestReplication(t *testing.T) {
+	peer := newTestEnvWithConfig(t, &configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Auth: configuration.Auth{
+			"silly": {
+				"realm":   "realm-test",
+				"service": "service-test",
+			},
+		},
+	})
+
+	getPeer := func(url string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer peer-token")
+		return http.DefaultClient.Do(req)
+	}
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Replication: configuration.Replication{
+			Peers: []configuration.ReplicationPeer{
+				{
+					Name:         "peer",
+					URL:          peer.server.URL,
+					Repositories: []string{"replicated/*"},
+					Attempts:     3,
+					Backoff:      10 * time.Millisecond,
+					Token:        "peer-token",
+				},
+			},
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	pushImage := func(imageName string) digest.Digest {
+		unsignedManifest := &manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: imageName,
+			Tag:  "latest",
+			FSLayers: []manifest.FSLayer{
+				{}, {},
+			},
+		}
+
+		for i := range unsignedManifest.FSLayers {
+			rs, dgstStr, err := testutil.CreateRandomTarFile()
+			if err != nil {
+				t.Fatalf("error creating random layer %d: %v", i, err)
+			}
+			dgst := digest.Digest(dgstStr)
+			unsignedManifest.FSLayers[i].BlobSum = dgst
+
+			uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+			pushLayer(t, env.builder, imageName, dgst, uploadURLBase, rs)
+		}
+
+		signedManifest, err := manifest.Sign(unsignedManifest, env.pk)
+		checkErr(t, err, "signing manifest")
+
+		payload, err := signedManifest.Payload()
+		checkErr(t, err, "getting manifest payload")
+
+		dgst, err := digest.FromBytes(payload)
+		checkErr(t, err, "digesting manifest")
+
+		manifestURL, err := env.builder.BuildManifestURL(imageName, "latest")
+		checkErr(t, err, "building manifest url")
+
+		resp := putManifest(t, "putting signed manifest", manifestURL, signedManifest)
+		resp.Body.Close()
+		checkResponse(t, "putting signed manifest", resp, http.StatusAccepted)
+
+		return dgst
+	}
+
+	replicatedDigest := pushImage("replicated/app")
+	pushImage("local/app")
+
+	manifestURL, err := peer.builder.BuildManifestURL("replicated/app", "latest")
+	checkErr(t, err, "building peer manifest url")
+
+	var resp *http.Response
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		resp, err = getPeer(manifestURL)
+		if err != nil {
+			t.Fatalf("unexpected error fetching replicated manifest: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkResponse(t, "fetching replicated manifest", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{replicatedDigest.String()},
+	})
+
+	manifestURL, err = peer.builder.BuildManifestURL("local/app", "latest")
+	checkErr(t, err, "building peer manifest url")
+
+	resp, err = getPeer(manifestURL)
+	if err != nil {
+		t.Fatalf("unexpected error fetching unreplicated manifest: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "fetching unreplicated manifest", resp, http.StatusNotFound)
+}
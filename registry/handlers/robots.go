@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/auth/robot"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"golang.org/x/net/context"
+)
+
+const (
+	// robotAccountCacheTTL is the time for which a robot account, or its
+	// absence, is cached, bounding how long changes made through the admin
+	// interface of another instance take to apply.
+	robotAccountCacheTTL = 10 * time.Second
+
+	// maxRobotAccountCacheEntries bounds the number of cached accounts,
+	// since clients may authenticate with any name.
+	maxRobotAccountCacheEntries = 10000
+)
+
+// robotAccounts looks up the robot accounts stored by driver, caching them
+// for robotAccountCacheTTL so that authenticating requests does not read the
+// account from the backend each time.
+type robotAccounts struct {
+	driver storagedriver.StorageDriver
+
+	mu      sync.Mutex
+	entries map[string]robotAccountEntry
+}
+
+// robotAccountEntry is a cached robot account, nil if it does not exist.
+type robotAccountEntry struct {
+	account *robot.Account
+	expires time.Time
+}
+
+var _ robot.Accounts = &robotAccounts{}
+
+func newRobotAccounts(driver storagedriver.StorageDriver) *robotAccounts {
+	return &robotAccounts{
+		driver:  driver,
+		entries: make(map[string]robotAccountEntry),
+	}
+}
+
+// Account returns the named robot account, or nil if it does not exist.
+// Names which are not valid robot account names, such as those of human
+// users, have no account.
+func (ra *robotAccounts) Account(ctx context.Context, name string) (*robot.Account, error) {
+	if err := storage.ValidateRobotAccount(storage.RobotAccount{Name: name}); err != nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+
+	ra.mu.Lock()
+	entry, ok := ra.entries[name]
+	ra.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.account, nil
+	}
+
+	account, err := ra.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if len(ra.entries) >= maxRobotAccountCacheEntries {
+		for cached, entry := range ra.entries {
+			if !now.Before(entry.expires) {
+				delete(ra.entries, cached)
+			}
+		}
+
+		if len(ra.entries) >= maxRobotAccountCacheEntries {
+			ra.entries = make(map[string]robotAccountEntry)
+		}
+	}
+
+	ra.entries[name] = robotAccountEntry{
+		account: account,
+		expires: now.Add(robotAccountCacheTTL),
+	}
+
+	return account, nil
+}
+
+// get reads the named robot account from the backend.
+func (ra *robotAccounts) get(ctx context.Context, name string) (*robot.Account, error) {
+	stored, err := storage.GetRobotAccount(ctx, ra.driver, name)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	account := &robot.Account{
+		Name:      stored.Name,
+		TokenHash: stored.TokenHash,
+	}
+	for _, access := range stored.Access {
+		account.Access = append(account.Access, robot.Grant{
+			Repository: access.Repository,
+			Actions:    access.Actions,
+		})
+	}
+
+	return account, nil
+}
+
+// invalidate discards the cached named robot accounts, after they were
+// created or deleted.
+func (ra *robotAccounts) invalidate(names ...string) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	for _, name := range names {
+		delete(ra.entries, name)
+	}
+}
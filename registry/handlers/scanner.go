@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage"
+)
+
+// layerScanner submits uploaded layers to a content scanner, such as a
+// malware scanner, before they are committed.
+type layerScanner struct {
+	*validationHook
+}
+
+var _ storage.LayerValidator = layerScanner{}
+
+// ValidateLayer posts the layer content to the scanner. Rejections and scan
+// errors fail the upload if the scanner is enforced, and are only logged
+// otherwise.
+func (s layerScanner) ValidateLayer(ctx ctxu.Context, name string, dgst digest.Digest, content io.Reader) error {
+	err := s.post(content, http.Header{
+		"Content-Type":          []string{"application/octet-stream"},
+		"Docker-Content-Digest": []string{dgst.String()},
+		"Docker-Repository":     []string{name},
+	})
+	if err == nil {
+		return nil
+	}
+
+	if err := s.enforce(ctx, err, map[string]interface{}{"digest": dgst}); err != nil {
+		if rejection, ok := err.(hookRejection); ok {
+			return distribution.ErrLayerRejected{
+				Digest: dgst,
+				Reason: rejection.Error(),
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// layerScanners returns the configured layer scanners as layer validators.
+func layerScanners(config configuration.LayerValidation) []storage.LayerValidator {
+	var validators []storage.LayerValidator
+	for _, hookConfig := range config.Scanners {
+		validators = append(validators, layerScanner{newValidationHook(hookConfig)})
+	}
+
+	return validators
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+)
+
+// TestLayerScanner pushes clean and infected layers to registries scanning
+// them with blocking and logging enforcement.
+func TestLayerScanner(t *testing.T) {
+	var scanned []string
+	scanner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("unexpected error reading scanned content: %v", err)
+		}
+
+		dgst, err := digest.FromBytes(p)
+		if err != nil {
+			t.Fatalf("unexpected error digesting scanned content: %v", err)
+		}
+
+		if r.Header.Get("Docker-Content-Digest") != dgst.String() || r.Header.Get("Docker-Repository") != "foo/bar" {
+			t.Fatalf("unexpected scan request headers: %v", r.Header)
+		}
+		scanned = append(scanned, dgst.String())
+
+		if bytes.Contains(p, []byte("EICAR")) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Eicar-Test-Signature FOUND"))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer scanner.Close()
+
+	clean := []byte("clean content")
+	infected := []byte("infected EICAR content")
+
+	for _, testcase := range []struct {
+		enforce         string
+		infectedPushed  bool
+		expectedScanned int
+	}{
+		{enforce: "block", infectedPushed: false, expectedScanned: 2},
+		{enforce: "log", infectedPushed: true, expectedScanned: 2},
+	} {
+		scanned = nil
+		config := configuration.Configuration{
+			Storage: configuration.Storage{
+				"inmemory": configuration.Parameters{},
+			},
+			Validation: configuration.Validation{
+				Layers: configuration.LayerValidation{
+					Scanners: []configuration.ValidationHook{
+						{Name: "test", URL: scanner.URL, Enforce: testcase.enforce},
+					},
+				},
+			},
+		}
+		env := newTestEnvWithConfig(t, &config)
+
+		for _, content := range [][]byte{clean, infected} {
+			dgst, err := digest.FromBytes(content)
+			checkErr(t, err, "digesting layer")
+
+			uploadURLBase, _ := startPushLayer(t, env.builder, "foo/bar")
+			resp, err := doPushLayer(t, env.builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+			checkErr(t, err, "pushing layer")
+			defer resp.Body.Close()
+
+			if bytes.Equal(content, infected) && !testcase.infectedPushed {
+				checkResponse(t, "pushing infected layer", resp, http.StatusBadRequest)
+				checkBodyHasErrorCodes(t, "pushing infected layer", resp, v2.ErrorCodeBlobUploadInvalid)
+			} else {
+				checkResponse(t, "pushing layer", resp, http.StatusCreated)
+			}
+
+			layerURL, err := env.builder.BuildBlobURL("foo/bar", dgst)
+			checkErr(t, err, "building layer url")
+
+			resp, err = http.Head(layerURL)
+			checkErr(t, err, "checking head on layer")
+			defer resp.Body.Close()
+
+			if bytes.Equal(content, infected) && !testcase.infectedPushed {
+				checkResponse(t, "checking head on infected layer", resp, http.StatusNotFound)
+			} else {
+				checkResponse(t, "checking head on layer", resp, http.StatusOK)
+			}
+		}
+
+		if len(scanned) != testcase.expectedScanned {
+			t.Fatalf("%s: unexpected scanned layers: %v", testcase.enforce, scanned)
+		}
+	}
+}
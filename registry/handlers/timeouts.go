@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// routeTimeout returns the configured timeout of the route of the request,
+// or zero if it has none.
+func (app *App) routeTimeout(r *http.Request) time.Duration {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return 0
+	}
+
+	timeouts := app.Config.HTTP.Timeouts
+	switch route.GetName() {
+	case v2.RouteNameManifest:
+		return timeouts.Manifest
+	case v2.RouteNameBlob:
+		return timeouts.Blob
+	case v2.RouteNameBlobUpload, v2.RouteNameBlobUploadChunk:
+		return timeouts.Upload
+	}
+
+	return 0
+}
+
+// serveWithTimeout calls serve with a deadline on the start of its
+// response. If serve has not written the header of its response by the
+// deadline, the request context is cancelled and the response abandoned: 503
+// Service Unavailable is sent, and the writes and reads of the request body
+// of serve fail from then on. serve is still waited for, for as long again
+// as the timeout, since it may be writing to an upload which the client
+// would retry. It stops at its next read or write, or as soon as the storage
+// driver honors the cancellation of the request context; the S3, Azure and
+// Swift drivers do not, so a handler stuck in one of them is left to finish
+// in the background once the wait expires. Once the response has started,
+// the deadline no longer applies, so that large layers may be streamed to
+// slow clients.
+func serveWithTimeout(ctx *Context, w http.ResponseWriter, r *http.Request, timeout time.Duration, serve func(w http.ResponseWriter)) {
+	var cancel context.CancelFunc
+	ctx.Context, cancel = context.WithCancel(ctx.Context)
+	defer cancel()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	tw := &timeoutWriter{
+		w:         w,
+		h:         make(http.Header),
+		requestID: ctx.Errors.RequestID,
+		started:   make(chan struct{}),
+	}
+
+	if r.Body != nil {
+		r.Body = &timeoutBody{ReadCloser: r.Body, tw: tw}
+	}
+
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+
+		serve(tw)
+		close(done)
+	}()
+
+	// abandoned fires when a timed out handler has run for too long.
+	var abandoned <-chan time.Time
+
+	select {
+	case <-done:
+		return
+	case p := <-panicked:
+		// Panic in this goroutine, so that it is handled by the server.
+		panic(p)
+	case <-tw.started:
+	case <-timer.C:
+		if tw.timeout(timeout) {
+			ctxu.GetLogger(ctx).Errorf("request timed out after %v", timeout)
+			cancel()
+			timer.Reset(timeout)
+			abandoned = timer.C
+		}
+	}
+
+	select {
+	case <-done:
+	case p := <-panicked:
+		panic(p)
+	case <-abandoned:
+		ctxu.GetLogger(ctx).Errorf("request still running %v after timing out, abandoning it", timeout)
+	}
+}
+
+// timeoutBody fails the reads of the request body once the request has
+// timed out.
+type timeoutBody struct {
+	io.ReadCloser
+	tw *timeoutWriter
+}
+
+func (tb *timeoutBody) Read(p []byte) (int, error) {
+	if tb.tw.timedOutNow() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	return tb.ReadCloser.Read(p)
+}
+
+// timeoutWriter passes writes through to the response until the request
+// times out. The header is kept separately until it is written, so that it
+// can be replaced by the timeout response.
+type timeoutWriter struct {
+	w         http.ResponseWriter
+	h         http.Header
+	requestID string
+
+	// started is closed when the header of the response is written.
+	started chan struct{}
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+var (
+	_ http.Flusher  = &timeoutWriter{}
+	_ io.ReaderFrom = &timeoutWriter{}
+)
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+
+	tw.writeHeader(code)
+}
+
+// Flush flushes the response if the underlying writer supports it.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ReadFrom copies src to the response with the ReadFrom of the underlying
+// writer, if any, so that files may be sent without copying them through
+// user space.
+func (tw *timeoutWriter) ReadFrom(src io.Reader) (int64, error) {
+	tw.mu.Lock()
+	if tw.timedOut {
+		tw.mu.Unlock()
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	tw.mu.Unlock()
+
+	if rf, ok := tw.w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+
+	// Hide ReadFrom, so that io.Copy writes through Write.
+	return io.Copy(struct{ io.Writer }{tw}, src)
+}
+
+// timedOutNow returns true once the request has timed out.
+func (tw *timeoutWriter) timedOutNow() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.timedOut
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	for k, v := range tw.h {
+		tw.w.Header()[k] = v
+	}
+
+	tw.wroteHeader = true
+	close(tw.started)
+	tw.w.WriteHeader(code)
+}
+
+// timeout makes further writes fail and responds with an error, unless
+// the response has already started. It returns true if the request timed
+// out.
+func (tw *timeoutWriter) timeout(timeout time.Duration) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.wroteHeader {
+		return false
+	}
+	tw.timedOut = true
+
+	errs := v2.Errors{RequestID: tw.requestID}
+	errs.Push(v2.ErrorCodeUnknown, fmt.Sprintf("request timed out after %v", timeout))
+	tw.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	tw.w.WriteHeader(http.StatusServiceUnavailable)
+	serveJSON(tw.w, errs)
+	return true
+}
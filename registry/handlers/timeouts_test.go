@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+)
+
+func TestServeWithTimeout(t *testing.T) {
+	ctx := &Context{Context: ctxu.Background()}
+	r, err := http.NewRequest("PATCH", "http://example.com/", strings.NewReader("content"))
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	// A handler finishing in time responds as usual.
+	w := httptest.NewRecorder()
+	serveWithTimeout(ctx, w, r, time.Second, func(w http.ResponseWriter) {
+		w.Header().Set("X-Test", "ok")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d != %d", w.Code, http.StatusAccepted)
+	}
+	if w.Header().Get("X-Test") != "ok" {
+		t.Fatalf("missing header from handler: %v", w.Header())
+	}
+
+	// A stuck handler is cancelled and waited for, and its late reads and
+	// writes fail.
+	ctx = &Context{Context: ctxu.Background()}
+	r, err = http.NewRequest("PATCH", "http://example.com/", strings.NewReader("content"))
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	var lateRead, lateWrite error
+	w = httptest.NewRecorder()
+	serveWithTimeout(ctx, w, r, 10*time.Millisecond, func(w http.ResponseWriter) {
+		<-ctx.Done()
+		_, lateRead = r.Body.Read(make([]byte, 1))
+		_, lateWrite = w.Write([]byte("late"))
+	})
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d != %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected request context to be cancelled: %v", ctx.Err())
+	}
+	if lateRead != http.ErrHandlerTimeout {
+		t.Fatalf("unexpected error reading after timeout: %v", lateRead)
+	}
+	if lateWrite != http.ErrHandlerTimeout {
+		t.Fatalf("unexpected error writing after timeout: %v", lateWrite)
+	}
+
+	// A handler ignoring the cancellation is abandoned after waiting for it
+	// as long again as the timeout.
+	ctx = &Context{Context: ctxu.Background()}
+	r, err = http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	w = httptest.NewRecorder()
+	start := time.Now()
+	serveWithTimeout(ctx, w, r, 10*time.Millisecond, func(w http.ResponseWriter) {
+		<-release
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("stuck handler waited for %v", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d != %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// A response started in time is not cut short, however long it takes.
+	ctx = &Context{Context: ctxu.Background()}
+	r, err = http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	serveWithTimeout(ctx, w, r, 10*time.Millisecond, func(w http.ResponseWriter) {
+		w.Write([]byte("slow "))
+		time.Sleep(50 * time.Millisecond)
+		if ctx.Err() != nil {
+			t.Errorf("request context cancelled after the response started: %v", ctx.Err())
+		}
+		w.Write([]byte("content"))
+	})
+
+	if w.Code != http.StatusOK || w.Body.String() != "slow content" {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+}
+
+// TestTimeoutWriterInterfaces checks that the timeout writer passes flushes
+// and copies through to the response.
+func TestTimeoutWriterInterfaces(t *testing.T) {
+	ctx := &Context{Context: ctxu.Background()}
+	r, err := http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	serveWithTimeout(ctx, w, r, time.Second, func(w http.ResponseWriter) {
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("content")); err != nil {
+			t.Errorf("unexpected error copying to response: %v", err)
+		}
+		w.(http.Flusher).Flush()
+	})
+
+	if !w.Flushed {
+		t.Fatalf("response not flushed")
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "content" {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+}
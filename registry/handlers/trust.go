@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+)
+
+// configureTrust sets up the advertisement of the trust service holding the
+// signatures of the content of the registry. Clients discover it from the
+// Docker-Content-Trust-Server header of the responses and from the
+// information route.
+func (app *App) configureTrust(config configuration.Trust) {
+	if config.Server == "" {
+		return
+	}
+
+	u, err := url.Parse(config.Server)
+	if err != nil {
+		panic(fmt.Sprintf("invalid trust server url %q: %v", config.Server, err))
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		panic(fmt.Sprintf("invalid trust server url %q: an absolute http or https url is required", config.Server))
+	}
+
+	app.trustServer = strings.TrimSuffix(config.Server, "/")
+	ctxu.GetLogger(app).Infof("advertising trust server %s", app.trustServer)
+}
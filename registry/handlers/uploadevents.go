@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+)
+
+// defaultUploadEventsMinSize is the size from which uploads are reported,
+// unless configured otherwise.
+const defaultUploadEventsMinSize = 100 << 20
+
+// defaultUploadMilestones are the percentages of the expected size of an
+// upload at which its progress is reported, unless configured otherwise.
+var defaultUploadMilestones = []int{25, 50, 75}
+
+// uploadEvents configures the events reporting the progress of large
+// uploads.
+type uploadEvents struct {
+	// minSize is the size in bytes from which uploads are reported.
+	minSize int64
+
+	// milestones are the sorted percentages of the expected size at which
+	// the progress of uploads is reported.
+	milestones []int
+}
+
+// configureUploadEvents sets up the events reporting the progress of large
+// uploads.
+func (app *App) configureUploadEvents(config configuration.UploadEvents) {
+	if !config.Enabled {
+		return
+	}
+
+	events := &uploadEvents{
+		minSize:    config.MinSize,
+		milestones: append([]int(nil), config.Milestones...),
+	}
+
+	if events.minSize <= 0 {
+		events.minSize = defaultUploadEventsMinSize
+	}
+
+	if len(events.milestones) == 0 {
+		events.milestones = append(events.milestones, defaultUploadMilestones...)
+	}
+
+	for _, percent := range events.milestones {
+		if percent <= 0 || percent >= 100 {
+			panic(fmt.Sprintf("invalid upload milestone %d: milestones are percentages between 0 and 100", percent))
+		}
+	}
+	sort.Ints(events.milestones)
+
+	app.uploadEvents = events
+}
+
+// uploadProgress reports the progress of the upload of a request to its event
+// listener. An upload is reported once it is large, when its expected size
+// or the data received reach the minimum size. Its progress is reported at
+// the milestones of its expected size if the request announces it, or each
+// time the minimum size is received again otherwise.
+type uploadProgress struct {
+	*uploadEvents
+
+	ctx       *Context
+	listener  notifications.UploadListener
+	upload    distribution.LayerUpload
+	startedAt time.Time
+
+	// size is the expected size of the layer, or -1 if it is unknown.
+	size int64
+
+	// started is true once the start of the upload was reported by the
+	// request.
+	started bool
+}
+
+// trackUpload returns the progress of the upload of the request, whose
+// expected size is size, or -1 if unknown. It returns nil if upload events
+// are disabled.
+func (luh *layerUploadHandler) trackUpload(r *http.Request, size int64) *uploadProgress {
+	if luh.uploadEvents == nil {
+		return nil
+	}
+
+	listener, ok := luh.eventBridge(luh.Context, r).(notifications.UploadListener)
+	if !ok {
+		return nil
+	}
+
+	return &uploadProgress{
+		uploadEvents: luh.uploadEvents,
+		ctx:          luh.Context,
+		listener:     listener,
+		upload:       luh.Upload,
+		startedAt:    luh.Upload.StartedAt(),
+		size:         size,
+	}
+}
+
+// advance reports the events of the upload as the data received grows from
+// from to to bytes.
+func (up *uploadProgress) advance(from, to int64) {
+	known := up.size >= 0
+
+	if !up.started && ((known && up.size >= up.minSize && from == 0 && to > 0) || (from < up.minSize && to >= up.minSize)) {
+		up.started = true
+		up.report(up.listener.UploadStarted(up.ctx.Repository, up.record(to, 0)))
+	}
+
+	switch {
+	case known && up.size >= up.minSize:
+		for _, percent := range up.milestones {
+			milestone := up.size * int64(percent) / 100
+			if from < milestone && to >= milestone {
+				up.report(up.listener.UploadProgressed(up.ctx.Repository, up.record(to, percent)))
+			}
+		}
+	case !known:
+		// The first multiple of the minimum size starts the upload.
+		if to/up.minSize > from/up.minSize && to/up.minSize > 1 {
+			up.report(up.listener.UploadProgressed(up.ctx.Repository, up.record(to, 0)))
+		}
+	}
+}
+
+// complete reports the completion of the upload of layer, if it is large.
+func (up *uploadProgress) complete(layer distribution.Layer) {
+	if layer.Length() < up.minSize {
+		return
+	}
+
+	record := up.record(layer.Length(), 0)
+	record.Size = layer.Length()
+	up.report(up.listener.UploadCompleted(up.ctx.Repository, layer, record))
+}
+
+// record returns the upload record of an event sent once received bytes are
+// received.
+func (up *uploadProgress) record(received int64, percent int) notifications.UploadRecord {
+	record := notifications.UploadRecord{
+		UUID:     up.upload.UUID(),
+		Received: received,
+		Percent:  percent,
+		Duration: time.Since(up.startedAt),
+	}
+
+	if up.size >= 0 {
+		record.Size = up.size
+	}
+
+	return record
+}
+
+func (up *uploadProgress) report(err error) {
+	if err != nil {
+		ctxu.GetLogger(up.ctx).Errorf("error dispatching upload event to listener: %v", err)
+	}
+}
+
+// progressReader advances the progress of an upload as its data is read.
+type progressReader struct {
+	io.Reader
+	progress *uploadProgress
+
+	// received counts the data of the upload received so far, including
+	// the data received by the previous requests.
+	received int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.Reader.Read(p)
+	if n > 0 {
+		pr.progress.advance(pr.received, pr.received+int64(n))
+		pr.received += int64(n)
+	}
+
+	return n, err
+}
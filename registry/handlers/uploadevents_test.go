@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/notifications"
+)
+
+// uploadEventRecorder records the upload events of an upload progress.
+type uploadEventRecorder struct {
+	events []string
+}
+
+func (r *uploadEventRecorder) UploadStarted(repo distribution.Repository, upload notifications.UploadRecord) error {
+	r.events = append(r.events, "start")
+	return nil
+}
+
+func (r *uploadEventRecorder) UploadProgressed(repo distribution.Repository, upload notifications.UploadRecord) error {
+	if upload.Percent != 0 {
+		r.events = append(r.events, fmt.Sprintf("progress %d", upload.Percent))
+	} else {
+		r.events = append(r.events, "progress")
+	}
+	return nil
+}
+
+func (r *uploadEventRecorder) UploadCompleted(repo distribution.Repository, layer distribution.Layer, upload notifications.UploadRecord) error {
+	r.events = append(r.events, "complete")
+	return nil
+}
+
+func TestUploadProgress(t *testing.T) {
+	events := &uploadEvents{minSize: 100, milestones: []int{25, 50, 75}}
+
+	for _, testcase := range []struct {
+		description string
+		size        int64
+		reads       []int64
+		expected    []string
+	}{
+		{
+			description: "small upload of known size",
+			size:        99,
+			reads:       []int64{0, 50, 99},
+		},
+		{
+			description: "large upload of known size",
+			size:        200,
+			reads:       []int64{0, 10, 60, 200},
+			expected:    []string{"start", "progress 25", "progress 50", "progress 75"},
+		},
+		{
+			description: "large upload of unknown size",
+			size:        -1,
+			reads:       []int64{0, 50, 150, 250, 260, 400},
+			expected:    []string{"start", "progress", "progress"},
+		},
+		{
+			description: "last chunk of a large upload",
+			size:        200,
+			reads:       []int64{80, 120, 200},
+			expected:    []string{"start", "progress 50", "progress 75"},
+		},
+	} {
+		var recorder uploadEventRecorder
+		up := &uploadProgress{
+			uploadEvents: events,
+			ctx:          &Context{},
+			listener:     &recorder,
+			upload:       &testUpload{},
+			startedAt:    time.Now(),
+			size:         testcase.size,
+		}
+
+		for i := 1; i < len(testcase.reads); i++ {
+			up.advance(testcase.reads[i-1], testcase.reads[i])
+		}
+
+		if !reflect.DeepEqual(recorder.events, testcase.expected) {
+			t.Errorf("unexpected events for %s: %v != %v", testcase.description, recorder.events, testcase.expected)
+		}
+	}
+}
+
+// testUpload is an upload with only an identifier.
+type testUpload struct {
+	distribution.LayerUpload
+}
+
+func (u *testUpload) UUID() string {
+	return "test"
+}
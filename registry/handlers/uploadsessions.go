@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/garyburd/redigo/redis"
+)
+
+// uploadSessionExpiry is the time after which the session of an abandoned
+// upload is dropped from redis. It matches the default age of the uploads
+// purged from the storage backend.
+const uploadSessionExpiry = 168 * time.Hour
+
+// uploadSessionStore keeps the state of layer uploads in a store shared by
+// the registry instances, so that an upload started by one instance can be
+// continued by any other, even when the client does not send the state
+// token, or sends one signed with a secret unknown to this instance.
+type uploadSessionStore interface {
+	// resolve returns the state of the upload identified by uuid in the
+	// repository of the context, or distribution.ErrLayerUploadUnknown.
+	resolve(ctx *Context, uuid string) (layerUploadState, error)
+
+	// update records the state of an upload after a request.
+	update(ctx *Context, state layerUploadState) error
+
+	// remove drops the state of a completed or canceled upload.
+	remove(ctx *Context, uuid string) error
+}
+
+// storageUploadSessions rebuilds the state of uploads from the upload
+// directories of the storage backend. It needs no bookkeeping, at the cost
+// of resuming the upload to find its offset.
+type storageUploadSessions struct{}
+
+func (storageUploadSessions) resolve(ctx *Context, uuid string) (layerUploadState, error) {
+	upload, err := ctx.Repository.Layers().Resume(uuid)
+	if err != nil {
+		return layerUploadState{}, err
+	}
+	defer upload.Close()
+
+	offset, err := upload.Seek(0, os.SEEK_END)
+	if err != nil {
+		return layerUploadState{}, err
+	}
+
+	return layerUploadState{
+		Name:      ctx.Repository.Name(),
+		UUID:      uuid,
+		Offset:    offset,
+		StartedAt: upload.StartedAt(),
+	}, nil
+}
+
+func (storageUploadSessions) update(ctx *Context, state layerUploadState) error {
+	return nil
+}
+
+func (storageUploadSessions) remove(ctx *Context, uuid string) error {
+	return nil
+}
+
+// redisUploadSessions keeps the state of each upload in a redis hash, which
+// expires if the upload is abandoned.
+type redisUploadSessions struct {
+	pool *redis.Pool
+}
+
+func (rus *redisUploadSessions) resolve(ctx *Context, uuid string) (layerUploadState, error) {
+	conn := rus.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("HMGET", rus.uploadHashKey(ctx.Repository.Name(), uuid), "offset", "startedat"))
+	if err != nil {
+		return layerUploadState{}, err
+	}
+
+	if len(reply) < 2 || reply[0] == nil || reply[1] == nil {
+		return layerUploadState{}, distribution.ErrLayerUploadUnknown
+	}
+
+	state := layerUploadState{
+		Name: ctx.Repository.Name(),
+		UUID: uuid,
+	}
+
+	var startedAt string
+	if _, err := redis.Scan(reply, &state.Offset, &startedAt); err != nil {
+		return layerUploadState{}, err
+	}
+
+	state.StartedAt, err = time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil {
+		return layerUploadState{}, err
+	}
+
+	return state, nil
+}
+
+func (rus *redisUploadSessions) update(ctx *Context, state layerUploadState) error {
+	conn := rus.pool.Get()
+	defer conn.Close()
+
+	key := rus.uploadHashKey(state.Name, state.UUID)
+
+	conn.Send("MULTI")
+	conn.Send("HMSET", key, "offset", state.Offset, "startedat", state.StartedAt.Format(time.RFC3339Nano))
+	conn.Send("EXPIRE", key, int64(uploadSessionExpiry/time.Second))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+func (rus *redisUploadSessions) remove(ctx *Context, uuid string) error {
+	conn := rus.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", rus.uploadHashKey(ctx.Repository.Name(), uuid))
+	return err
+}
+
+func (rus *redisUploadSessions) uploadHashKey(name, uuid string) string {
+	return "upload::" + name + "::" + uuid
+}
+
+// configureUploadSessions selects the upload session store from the
+// configuration. It panics on an unknown store, like the other app
+// configuration steps.
+func (app *App) configureUploadSessions(sessions string) {
+	switch sessions {
+	case "":
+		return
+	case "storage":
+		app.uploadSessions = storageUploadSessions{}
+	case "redis":
+		if app.redis == nil {
+			panic("redis configuration required to use for upload sessions")
+		}
+		app.uploadSessions = &redisUploadSessions{pool: app.redis}
+	default:
+		panic(fmt.Sprintf("unsupported upload session store: %q", sessions))
+	}
+
+	ctxu.GetLogger(app).Infof("using %s upload sessions", sessions)
+}
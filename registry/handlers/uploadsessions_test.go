@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+)
+
+// TestStorageUploadSessions pushes a layer in chunks without passing the
+// upload state token back, which only works with upload sessions.
+func TestStorageUploadSessions(t *testing.T) {
+	imageName := "foo/sessions"
+	chunks := [][]byte{
+		bytes.Repeat([]byte("a"), 512),
+		bytes.Repeat([]byte("b"), 256),
+	}
+	dgst, err := digest.FromBytes(bytes.Join(chunks, nil))
+	if err != nil {
+		t.Fatalf("unexpected error digesting layer: %v", err)
+	}
+
+	stripState := func(location string) string {
+		u, err := url.Parse(location)
+		if err != nil {
+			t.Fatalf("error parsing location %q: %v", location, err)
+		}
+		u.RawQuery = ""
+		return u.String()
+	}
+
+	// Without sessions, the token is required.
+	env := newTestEnv(t)
+	uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+	resp, _, err := doPushChunk(t, stripState(uploadURLBase), bytes.NewReader(chunks[0]))
+	if err != nil {
+		t.Fatalf("unexpected error pushing chunk: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "pushing chunk without state", resp, http.StatusBadRequest)
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Uploads: configuration.Uploads{
+			Sessions: "storage",
+		},
+	}
+	env = newTestEnvWithConfig(t, &config)
+
+	uploadURLBase, _ = startPushLayer(t, env.builder, imageName)
+	var length int64
+	for _, chunk := range chunks {
+		length += int64(len(chunk))
+		uploadURLBase, _ = pushChunk(t, env.builder, imageName, stripState(uploadURLBase), bytes.NewReader(chunk), length)
+	}
+
+	finishUpload(t, env.builder, imageName, stripState(uploadURLBase), dgst)
+
+	// The session of a completed upload is gone.
+	resp, _, err = doPushChunk(t, stripState(uploadURLBase), bytes.NewReader(chunks[0]))
+	if err != nil {
+		t.Fatalf("unexpected error pushing chunk: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "pushing chunk to completed upload", resp, http.StatusNotFound)
+}
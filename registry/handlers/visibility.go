@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"golang.org/x/net/context"
+)
+
+const (
+	// visibilityCacheTTL is the time for which the visibility of a
+	// repository is cached, bounding how long changes made through the
+	// admin interface of another instance take to apply.
+	visibilityCacheTTL = 30 * time.Second
+
+	// maxVisibilityCacheEntries bounds the number of repositories whose
+	// visibility is cached, since clients may request any name.
+	maxVisibilityCacheEntries = 10000
+)
+
+// repositoryVisibility reports the visibility recorded in the metadata of
+// the repositories stored by driver, caching it for visibilityCacheTTL so
+// that authorizing requests does not read the metadata from the backend
+// each time.
+type repositoryVisibility struct {
+	driver storagedriver.StorageDriver
+	rules  *v2.NameRules
+
+	mu      sync.Mutex
+	entries map[string]visibilityEntry
+}
+
+// visibilityEntry is the cached visibility of a repository.
+type visibilityEntry struct {
+	public  bool
+	expires time.Time
+}
+
+var _ auth.RepositoryVisibility = &repositoryVisibility{}
+
+func newRepositoryVisibility(driver storagedriver.StorageDriver, rules *v2.NameRules) *repositoryVisibility {
+	return &repositoryVisibility{
+		driver:  driver,
+		rules:   rules,
+		entries: make(map[string]visibilityEntry),
+	}
+}
+
+// Public returns true if the metadata of the named repository marks it
+// public.
+func (rv *repositoryVisibility) Public(ctx context.Context, name string) (bool, error) {
+	now := time.Now()
+
+	rv.mu.Lock()
+	entry, ok := rv.entries[name]
+	rv.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.public, nil
+	}
+
+	metadata, err := storage.GetRepositoryMetadata(ctx, rv.driver, rv.rules, name)
+	if err != nil {
+		return false, err
+	}
+
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	if len(rv.entries) >= maxVisibilityCacheEntries {
+		for cached, entry := range rv.entries {
+			if !now.Before(entry.expires) {
+				delete(rv.entries, cached)
+			}
+		}
+
+		if len(rv.entries) >= maxVisibilityCacheEntries {
+			rv.entries = make(map[string]visibilityEntry)
+		}
+	}
+
+	rv.entries[name] = visibilityEntry{
+		public:  metadata.Public,
+		expires: now.Add(visibilityCacheTTL),
+	}
+
+	return metadata.Public, nil
+}
+
+// invalidate discards the cached visibility of the named repositories, after
+// their metadata has changed.
+func (rv *repositoryVisibility) invalidate(names ...string) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	for _, name := range names {
+		delete(rv.entries, name)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"golang.org/x/net/context"
+)
+
+// TestRepositoryVisibilityCache checks that the visibility of repositories
+// is cached until it is invalidated.
+func TestRepositoryVisibilityCache(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	rv := newRepositoryVisibility(driver, v2.DefaultNameRules)
+
+	public := func(name string, expected bool) {
+		p, err := rv.Public(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting visibility of %s: %v", name, err)
+		}
+		if p != expected {
+			t.Fatalf("unexpected visibility of %s: %t != %t", name, p, expected)
+		}
+	}
+
+	public("foo/bar", false)
+
+	if err := storage.PutRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/bar", storage.RepositoryMetadata{Public: true}); err != nil {
+		t.Fatalf("unexpected error putting metadata: %v", err)
+	}
+
+	// The cached visibility is used until it is invalidated.
+	public("foo/bar", false)
+	rv.invalidate("foo/bar")
+	public("foo/bar", true)
+}
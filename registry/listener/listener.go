@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/user"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,20 +29,41 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 	return tc, nil
 }
 
+// SocketOptions sets the permissions of unix sockets. Zero values leave the
+// defaults of the process.
+type SocketOptions struct {
+	// Mode is the file mode of the socket.
+	Mode os.FileMode
+
+	// Owner and Group are the names or numeric IDs of the user and group
+	// owning the socket.
+	Owner string
+	Group string
+}
+
 // NewListener announces on laddr and net. Accepted values of the net are
-// 'unix' and 'tcp'
+// 'unix', 'tcp' and 'systemd'. With 'systemd', laddr is the name of a socket
+// passed by systemd socket activation, or empty for the first one.
 func NewListener(net, laddr string) (net.Listener, error) {
+	return NewListenerWithOptions(net, laddr, SocketOptions{})
+}
+
+// NewListenerWithOptions is like NewListener, setting the permissions of
+// unix sockets from options.
+func NewListenerWithOptions(net, laddr string, options SocketOptions) (net.Listener, error) {
 	switch net {
 	case "unix":
-		return newUnixListener(laddr)
+		return newUnixListener(laddr, options)
 	case "tcp", "": // an empty net means tcp
 		return newTCPListener(laddr)
+	case "systemd":
+		return newSystemdListener(laddr)
 	default:
 		return nil, fmt.Errorf("unknown address type %s", net)
 	}
 }
 
-func newUnixListener(laddr string) (net.Listener, error) {
+func newUnixListener(laddr string, options SocketOptions) (net.Listener, error) {
 	fi, err := os.Stat(laddr)
 	if err == nil {
 		// the file exists.
@@ -57,7 +81,75 @@ func newUnixListener(laddr string) (net.Listener, error) {
 		return nil, err
 	}
 
-	return net.Listen("unix", laddr)
+	ln, err := net.Listen("unix", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setSocketPermissions(laddr, options); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// setSocketPermissions applies the options to the socket file at path.
+func setSocketPermissions(path string, options SocketOptions) error {
+	if options.Mode != 0 {
+		if err := os.Chmod(path, options.Mode); err != nil {
+			return err
+		}
+	}
+
+	if options.Owner == "" && options.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if options.Owner != "" {
+		id, err := lookupID(options.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown socket owner %s: %v", options.Owner, err)
+		}
+		uid = id
+	}
+
+	if options.Group != "" {
+		id, err := lookupID(options.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("unknown socket group %s: %v", options.Group, err)
+		}
+		gid = id
+	}
+
+	return os.Chown(path, uid, gid)
+}
+
+// lookupID returns the numeric ID of a user or group given by name or ID.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(id)
 }
 
 func isSocket(m os.FileMode) bool {
@@ -72,3 +164,118 @@ func newTCPListener(laddr string) (net.Listener, error) {
 
 	return tcpKeepAliveListener{ln.(*net.TCPListener)}, nil
 }
+
+// listenFDsStart is the first file descriptor passed by systemd.
+var listenFDsStart = 3
+
+// newSystemdListener returns the listener of a socket passed by systemd
+// socket activation, selected by its name in LISTEN_FDNAMES, or the first
+// one if name is empty. Since systemd keeps the socket open, connections
+// queue while the registry restarts instead of being refused.
+func newSystemdListener(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no sockets passed by systemd")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("no sockets passed by systemd")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := 0; i < count; i++ {
+		if name != "" && (i >= len(names) || names[i] != name) {
+			continue
+		}
+
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		defer f.Close()
+
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %d: %v", i, err)
+		}
+
+		if tcp, ok := ln.(*net.TCPListener); ok {
+			return tcpKeepAliveListener{tcp}, nil
+		}
+		return ln, nil
+	}
+
+	return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+}
+
+// networkListener accepts only the tcp connections from a set of networks.
+type networkListener struct {
+	net.Listener
+	networks []*net.IPNet
+}
+
+// RestrictNetworks returns a listener accepting the tcp connections of ln
+// only from the given networks, in CIDR notation, or addresses. Other
+// connections are closed as soon as they are accepted. Connections to unix
+// sockets are always accepted.
+func RestrictNetworks(ln net.Listener, networks []string) (net.Listener, error) {
+	nets, err := ParseNetworks(networks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &networkListener{Listener: ln, networks: nets}, nil
+}
+
+// ParseNetworks parses networks in CIDR notation or single addresses, which
+// are networks of a single address.
+func ParseNetworks(networks []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, network := range networks {
+		_, ipnet, err := net.ParseCIDR(network)
+		if err != nil {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network %q", network)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ipnet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+
+		nets = append(nets, ipnet)
+	}
+
+	return nets, nil
+}
+
+func (ln *networkListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if ln.allowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+// allowed returns true if connections from addr are accepted.
+func (ln *networkListener) allowed(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+
+	for _, network := range ln.networks {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package listener
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUnixListenerMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.sock")
+	ln, err := NewListenerWithOptions("unix", path, SocketOptions{
+		Mode:  0600,
+		Owner: strconv.Itoa(os.Getuid()),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error listening on %s: %v", path, err)
+	}
+	defer ln.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error getting socket info: %v", err)
+	}
+
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Fatalf("unexpected socket mode: %v != %v", mode, os.FileMode(0600))
+	}
+}
+
+func TestSystemdListener(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %v", err)
+	}
+	defer tcpListener.Close()
+
+	f, err := tcpListener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("unexpected error getting listener file: %v", err)
+	}
+	defer f.Close()
+
+	// Pretend that systemd passed the listener as the second socket.
+	defer func(start int) { listenFDsStart = start }(listenFDsStart)
+	listenFDsStart = int(f.Fd()) - 1
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "2")
+	os.Setenv("LISTEN_FDNAMES", "admin:registry")
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if _, err := NewListener("systemd", "missing"); err == nil {
+		t.Fatalf("expected an error for a socket not passed by systemd")
+	}
+
+	ln, err := NewListener("systemd", "registry")
+	if err != nil {
+		t.Fatalf("unexpected error getting systemd listener: %v", err)
+	}
+	defer ln.Close()
+
+	if ln.Addr().String() != tcpListener.Addr().String() {
+		t.Fatalf("unexpected listener address: %v != %v", ln.Addr(), tcpListener.Addr())
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error connecting: %v", err)
+	}
+	conn.Close()
+
+	if err := <-accepted; err != nil {
+		t.Fatalf("unexpected error accepting: %v", err)
+	}
+}
+
+func TestRestrictNetworks(t *testing.T) {
+	if _, err := RestrictNetworks(nil, []string{"not-a-network"}); err == nil {
+		t.Fatalf("expected error restricting to an invalid network")
+	}
+
+	for _, testcase := range []struct {
+		networks []string
+		allowed  bool
+	}{
+		{networks: []string{"127.0.0.0/8"}, allowed: true},
+		{networks: []string{"10.0.0.0/8", "127.0.0.1"}, allowed: true},
+		{networks: []string{"10.0.0.0/8"}, allowed: false},
+	} {
+		tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error listening: %v", err)
+		}
+
+		ln, err := RestrictNetworks(tcpListener, testcase.networks)
+		if err != nil {
+			t.Fatalf("unexpected error restricting networks: %v", err)
+		}
+
+		accepted := make(chan bool, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				conn.Close()
+			}
+			accepted <- err == nil
+		}()
+
+		conn, err := net.Dial("tcp", tcpListener.Addr().String())
+		if err != nil {
+			t.Fatalf("unexpected error dialing: %v", err)
+		}
+		conn.Close()
+
+		// A rejected connection leaves Accept waiting for the next one,
+		// until the listener is closed.
+		var allowed bool
+		select {
+		case allowed = <-accepted:
+		case <-time.After(100 * time.Millisecond):
+			ln.Close()
+			allowed = <-accepted
+		}
+		ln.Close()
+
+		if allowed != testcase.allowed {
+			t.Fatalf("unexpected acceptance of connection for %v: %v != %v", testcase.networks, allowed, testcase.allowed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package middleware - static response headers for the registry handler
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	httpmiddleware "github.com/docker/distribution/registry/middleware/http"
+)
+
+// headersMiddleware sets static headers on all the responses of the wrapped
+// handler, such as security headers required by a deployment.
+type headersMiddleware struct {
+	handler http.Handler
+	headers http.Header
+}
+
+// newHeadersMiddleware constructs a middleware setting the headers given by
+// the options, each mapping a header name to a value or a list of values.
+func newHeadersMiddleware(handler http.Handler, options map[string]interface{}) (http.Handler, error) {
+	headers := make(http.Header)
+	for name, value := range options {
+		switch value := value.(type) {
+		case string:
+			headers.Add(name, value)
+		case []interface{}:
+			for _, v := range value {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("values of header %s must be strings", name)
+				}
+				headers.Add(name, s)
+			}
+		default:
+			return nil, fmt.Errorf("header %s must be a string or a list of strings", name)
+		}
+	}
+
+	return &headersMiddleware{
+		handler: handler,
+		headers: headers,
+	}, nil
+}
+
+func (hm *headersMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for name, values := range hm.headers {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+
+	hm.handler.ServeHTTP(w, r)
+}
+
+// init registers the headers middleware.
+func init() {
+	httpmiddleware.Register("headers", httpmiddleware.InitFunc(newHeadersMiddleware))
+}
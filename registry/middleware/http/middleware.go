@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// InitFunc is the type of an HTTP middleware factory function and is used
+// to register the constructor for different HTTP middleware backends. The
+// middleware wraps the handler of the registry application.
+type InitFunc func(handler http.Handler, options map[string]interface{}) (http.Handler, error)
+
+var middlewares map[string]InitFunc
+
+// Register is used to register an InitFunc for
+// an HTTP middleware backend with the given name.
+func Register(name string, initFunc InitFunc) error {
+	if middlewares == nil {
+		middlewares = make(map[string]InitFunc)
+	}
+	if _, exists := middlewares[name]; exists {
+		return fmt.Errorf("name already registered: %s", name)
+	}
+
+	middlewares[name] = initFunc
+
+	return nil
+}
+
+// Get constructs an HTTP middleware with the given options using the named backend.
+func Get(name string, options map[string]interface{}, handler http.Handler) (http.Handler, error) {
+	if middlewares != nil {
+		if initFunc, exists := middlewares[name]; exists {
+			return initFunc(handler, options)
+		}
+	}
+
+	return nil, fmt.Errorf("no http middleware registered with name: %s", name)
+}
@@ -0,0 +1,122 @@
+// Package scheduler runs the maintenance tasks of the registry, such as
+// garbage collection and upload purging, on cron-style schedules inside the
+// registry process.
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"golang.org/x/net/context"
+)
+
+// Task is a scheduled task. Errors are logged, and do not prevent later runs.
+type Task func(ctx context.Context) error
+
+// Scheduler runs tasks on their schedules. Each task runs in its own
+// goroutine and never overlaps itself: a run lasting past the next scheduled
+// time delays the following run to the next scheduled time after it ends.
+type Scheduler struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	tasks   []*scheduledTask
+	stop    chan struct{}
+	started bool
+	wg      sync.WaitGroup
+}
+
+type scheduledTask struct {
+	name     string
+	schedule Schedule
+	task     Task
+}
+
+// New returns a scheduler running tasks with ctx.
+func New(ctx context.Context) *Scheduler {
+	return &Scheduler{
+		ctx:  ctx,
+		stop: make(chan struct{}),
+	}
+}
+
+// Add schedules a named task. Tasks added after Start are started
+// immediately.
+func (s *Scheduler) Add(name string, schedule Schedule, task Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &scheduledTask{
+		name:     name,
+		schedule: schedule,
+		task:     task,
+	}
+	s.tasks = append(s.tasks, t)
+
+	if s.started {
+		s.run(t)
+	}
+}
+
+// Start starts running the scheduled tasks.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, t := range s.tasks {
+		s.run(t)
+	}
+}
+
+// Stop stops scheduling tasks and waits for the running ones to end.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run starts the goroutine running t on its schedule.
+func (s *Scheduler) run(t *scheduledTask) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		logger := ctxu.GetLoggerWithField(s.ctx, "task", t.name)
+		for {
+			now := time.Now()
+			next := t.schedule.Next(now)
+			if next.IsZero() {
+				logger.Warnf("scheduled task never runs again")
+				return
+			}
+			logger.Debugf("next run of scheduled task at %s", next)
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			started := time.Now()
+			if err := t.task(s.ctx); err != nil {
+				logger.Errorf("scheduled task failed after %s: %v", time.Since(started), err)
+			} else {
+				logger.Infof("scheduled task completed in %s", time.Since(started))
+			}
+		}
+	}()
+}
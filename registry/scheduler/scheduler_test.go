@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2015, time.June, 1, 12, 30, 15, 0, time.UTC) // a Monday
+
+	for _, testcase := range []struct {
+		spec string
+		next time.Time
+	}{
+		{
+			spec: "* * * * *",
+			next: time.Date(2015, time.June, 1, 12, 31, 0, 0, time.UTC),
+		},
+		{
+			spec: "*/15 * * * *",
+			next: time.Date(2015, time.June, 1, 12, 45, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 3 * * *",
+			next: time.Date(2015, time.June, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "30 2,14 * * *",
+			next: time.Date(2015, time.June, 1, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 * * 7",
+			next: time.Date(2015, time.June, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 12 * * 1-5",
+			next: time.Date(2015, time.June, 2, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			// Either the day of month or the day of week matches.
+			spec: "0 0 15 * 3",
+			next: time.Date(2015, time.June, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 29 2 *",
+			next: time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "@hourly",
+			next: time.Date(2015, time.June, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "@monthly",
+			next: time.Date(2015, time.July, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "@every 90m",
+			next: from.Add(90 * time.Minute),
+		},
+		{
+			spec: "0 0 30 2 *",
+		},
+	} {
+		schedule, err := Parse(testcase.spec)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", testcase.spec, err)
+		}
+
+		if next := schedule.Next(from); !next.Equal(testcase.next) {
+			t.Fatalf("unexpected next time for %q: %s != %s", testcase.spec, next, testcase.next)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@fortnightly",
+		"@every",
+		"@every -1h",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	s := New(context.Background())
+
+	var runs, failures int32
+	s.Add("counter", Every(10*time.Millisecond), func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	s.Add("failing", Every(10*time.Millisecond), func(ctx context.Context) error {
+		atomic.AddInt32(&failures, 1)
+		return fmt.Errorf("failure")
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != 0 {
+		t.Fatalf("expected no task to run before the scheduler is started")
+	}
+
+	s.Start()
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	// Failed runs do not prevent later runs.
+	if atomic.LoadInt32(&runs) < 2 || atomic.LoadInt32(&failures) < 2 {
+		t.Fatalf("expected tasks to run repeatedly: %d runs, %d failures", runs, failures)
+	}
+
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != stopped {
+		t.Fatalf("expected no task to run after the scheduler is stopped")
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// BackupMetadata writes the metadata of the registry to w as a gzipped tar
+// archive, with paths relative to the storage root. The archive holds the
+// tags, manifest revisions, signatures and layer links of every repository,
+// the repository index, the robot accounts and the manifest and signature
+// blobs, but neither uploads in progress nor layer blobs, which
+// RestoreMetadata expects the backend to still hold. It returns the number of
+// files written.
+func BackupMetadata(ctx context.Context, driver storagedriver.StorageDriver, w io.Writer) (int, error) {
+	repositoriesRoot, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	indexRoot, err := defaultPathMapper.path(repositoryIndexPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	robotsRoot, err := defaultPathMapper.path(robotAccountsPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	storageRoot := path.Dir(repositoriesRoot)
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	written := 0
+
+	backup := func(fileInfo storagedriver.FileInfo) ([]byte, error) {
+		content, err := driver.GetContent(ctx, fileInfo.Path())
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    strings.TrimPrefix(fileInfo.Path(), storageRoot+"/"),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: fileInfo.ModTime(),
+		}); err != nil {
+			return nil, err
+		}
+
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+
+		written++
+		return content, nil
+	}
+
+	manifestBlobs := make(map[digest.Digest]struct{})
+	err = Walk(ctx, driver, repositoriesRoot, func(fileInfo storagedriver.FileInfo) error {
+		filePath := fileInfo.Path()
+		file := path.Base(filePath)
+		if fileInfo.IsDir() {
+			if file == "_uploads" {
+				return ErrSkipDir
+			}
+			return nil
+		}
+
+		content, err := backup(fileInfo)
+		if err != nil {
+			return err
+		}
+
+		// Revision and signature links reference the manifest blobs.
+		if file == "link" && strings.Contains(filePath, "/_manifests/revisions/") {
+			dgst, err := digest.ParseDigest(string(content))
+			if err != nil {
+				return fmt.Errorf("invalid link %s: %v", filePath, err)
+			}
+			manifestBlobs[dgst] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return written, err
+		}
+	}
+
+	for _, root := range []string{indexRoot, robotsRoot} {
+		err = Walk(ctx, driver, root, func(fileInfo storagedriver.FileInfo) error {
+			if fileInfo.IsDir() {
+				return nil
+			}
+
+			_, err := backup(fileInfo)
+			return err
+		})
+		if err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				return written, err
+			}
+		}
+	}
+
+	dgsts := make([]string, 0, len(manifestBlobs))
+	for dgst := range manifestBlobs {
+		dgsts = append(dgsts, string(dgst))
+	}
+	sort.Strings(dgsts)
+
+	for _, dgst := range dgsts {
+		blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: digest.Digest(dgst)})
+		if err != nil {
+			return written, err
+		}
+
+		fileInfo, err := driver.Stat(ctx, blobPath)
+		if err != nil {
+			return written, err
+		}
+
+		if _, err := backup(fileInfo); err != nil {
+			return written, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return written, err
+	}
+
+	return written, gw.Close()
+}
+
+// RestoreMetadata stores the metadata of an archive written by
+// BackupMetadata in the backend, replacing the existing metadata with the
+// same paths. Blobs already in the backend are kept. It returns the number
+// of files restored.
+func RestoreMetadata(ctx context.Context, driver storagedriver.StorageDriver, r io.Reader) (int, error) {
+	repositoriesRoot, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+	storageRoot := path.Dir(repositoriesRoot)
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gr.Close()
+
+	restored := 0
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return restored, err
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		switch {
+		case strings.HasPrefix(name, "repositories/"), strings.HasPrefix(name, "index/"), strings.HasPrefix(name, "robots/"):
+		case strings.HasPrefix(name, "blobs/"):
+			if exists, err := exists(ctx, driver, path.Join(storageRoot, name)); err != nil {
+				return restored, err
+			} else if exists {
+				continue
+			}
+		default:
+			return restored, fmt.Errorf("unexpected file %q in metadata archive", header.Name)
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return restored, err
+		}
+
+		if strings.HasPrefix(name, "blobs/") {
+			if err := verifyBlob(storageRoot, name, content); err != nil {
+				return restored, err
+			}
+		}
+
+		filePath := path.Join(storageRoot, name)
+		if err := driver.PutContent(ctx, filePath, content); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+
+	return restored, nil
+}
+
+// verifyBlob checks that the content of the blob at name, relative to
+// storageRoot, matches the digest in its path, so that a corrupt or edited
+// archive cannot store manifests under the digest of other content.
+func verifyBlob(storageRoot, name string, content []byte) error {
+	// Manifest blobs are stored at blobs/<algorithm>/<xx>/<hex>/data.
+	components := strings.Split(name, "/")
+	if len(components) != 5 || components[4] != "data" {
+		return fmt.Errorf("unexpected blob %q in metadata archive", name)
+	}
+
+	dgst := digest.NewDigestFromHex(components[1], components[3])
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return fmt.Errorf("unexpected blob %q in metadata archive: %v", name, err)
+	}
+
+	if blobPath != path.Join(storageRoot, name) {
+		return fmt.Errorf("unexpected blob %q in metadata archive", name)
+	}
+
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return fmt.Errorf("unexpected blob %q in metadata archive: %v", name, err)
+	}
+
+	verifier.Write(content)
+	if !verifier.Verified() {
+		return fmt.Errorf("content of blob %q in metadata archive does not match its digest", name)
+	}
+
+	return nil
+}
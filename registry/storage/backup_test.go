@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/testutil"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+)
+
+func TestBackupMetadata(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil, EnableRepositoryIndex())
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	rs, ds, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file: %v", err)
+	}
+	tarsum := digest.Digest(ds)
+
+	upload, err := repo.Layers().Upload()
+	if err != nil {
+		t.Fatalf("unexpected error creating test upload: %v", err)
+	}
+
+	if _, err := io.Copy(upload, rs); err != nil {
+		t.Fatalf("unexpected error copying to upload: %v", err)
+	}
+
+	layer, err := upload.Finish(tarsum)
+	if err != nil {
+		t.Fatalf("unexpected error finishing upload: %v", err)
+	}
+
+	// An upload in progress is not backed up.
+	if _, err := repo.Layers().Upload(); err != nil {
+		t.Fatalf("unexpected error creating test upload: %v", err)
+	}
+
+	sm, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name:     "foo/bar",
+		Tag:      "latest",
+		FSLayers: []manifest.FSLayer{{BlobSum: tarsum}},
+	}, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	account := RobotAccount{
+		Name:      "ci",
+		TokenHash: "hash",
+		Access:    []RobotAccess{{Repository: "foo/*", Actions: []string{"pull"}}},
+	}
+	if err := CreateRobotAccount(ctx, driver, account); err != nil {
+		t.Fatalf("unexpected error creating robot account: %v", err)
+	}
+
+	var archive bytes.Buffer
+	written, err := BackupMetadata(ctx, driver, &archive)
+	if err != nil {
+		t.Fatalf("unexpected error backing up metadata: %v", err)
+	}
+
+	// The restored backend only holds the layer blob.
+	restoredDriver := inmemory.New()
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: layer.Digest()})
+	if err != nil {
+		t.Fatalf("unexpected error getting blob path: %v", err)
+	}
+
+	content, err := driver.GetContent(ctx, blobPath)
+	if err != nil {
+		t.Fatalf("unexpected error reading layer blob: %v", err)
+	}
+
+	if err := restoredDriver.PutContent(ctx, blobPath, content); err != nil {
+		t.Fatalf("unexpected error copying layer blob: %v", err)
+	}
+
+	restored, err := RestoreMetadata(ctx, restoredDriver, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error restoring metadata: %v", err)
+	}
+
+	if restored != written {
+		t.Fatalf("unexpected number of files restored: %d != %d", restored, written)
+	}
+
+	restoredRepo, err := NewRegistryWithDriver(ctx, restoredDriver, nil).Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	fetched, err := restoredRepo.Manifests().GetByTag("latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting restored manifest: %v", err)
+	}
+
+	if !bytes.Equal(fetched.Raw, sm.Raw) {
+		t.Fatalf("restored manifest does not match")
+	}
+
+	if exists, err := restoredRepo.Layers().Exists(tarsum); err != nil || !exists {
+		t.Fatalf("expected restored layer to exist: %v, %v", exists, err)
+	}
+
+	repositories, err := ListRepositories(ctx, restoredDriver)
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+
+	if !reflect.DeepEqual(repositories, []string{"foo/bar"}) {
+		t.Fatalf("unexpected restored repository index: %v", repositories)
+	}
+
+	if restoredAccount, err := GetRobotAccount(ctx, restoredDriver, "ci"); err != nil || !reflect.DeepEqual(restoredAccount.Access, account.Access) {
+		t.Fatalf("unexpected restored robot account: %#v, %v", restoredAccount, err)
+	}
+
+	if uploads, errs := getOutstandingUploads(ctx, restoredDriver); len(uploads) != 0 || len(errs) != 0 {
+		t.Fatalf("unexpected restored uploads: %v, %v", uploads, errs)
+	}
+
+	// Restoring again keeps the blobs already in the backend.
+	restored, err = RestoreMetadata(ctx, restoredDriver, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error restoring metadata again: %v", err)
+	}
+
+	if restored >= written {
+		t.Fatalf("expected existing blobs to be skipped: %d files restored", restored)
+	}
+}
+
+// TestRestoreMetadataVerifiesBlobs checks that blobs in the archive whose
+// content does not match the digest in their path are not restored.
+func TestRestoreMetadataVerifiesBlobs(t *testing.T) {
+	ctx := context.Background()
+
+	content := []byte(`{"schemaVersion": 1}`)
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error getting blob path: %v", err)
+	}
+
+	repositoriesRoot, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error getting repositories root: %v", err)
+	}
+	name := strings.TrimPrefix(blobPath, path.Dir(repositoriesRoot)+"/")
+
+	archive := func(name string, content []byte) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, tc := range []struct {
+		name    string
+		content []byte
+	}{
+		{name: name, content: []byte(`{"schemaVersion": 2}`)},
+		{name: strings.Replace(name, "/data", "/other", 1), content: content},
+		{name: "blobs/sha256/" + dgst.Hex() + "/data", content: content},
+	} {
+		driver := inmemory.New()
+		if _, err := RestoreMetadata(ctx, driver, bytes.NewReader(archive(tc.name, tc.content))); err == nil {
+			t.Fatalf("expected error restoring blob %q", tc.name)
+		}
+
+		if exists, err := exists(ctx, driver, blobPath); err != nil || exists {
+			t.Fatalf("expected blob not to be restored: %v, %v", exists, err)
+		}
+	}
+
+	driver := inmemory.New()
+	restored, err := RestoreMetadata(ctx, driver, bytes.NewReader(archive(name, content)))
+	if err != nil {
+		t.Fatalf("unexpected error restoring blob: %v", err)
+	}
+
+	if restored != 1 {
+		t.Fatalf("unexpected number of files restored: %d", restored)
+	}
+
+	if stored, err := driver.GetContent(ctx, blobPath); err != nil || !bytes.Equal(stored, content) {
+		t.Fatalf("unexpected restored blob: %q, %v", stored, err)
+	}
+}
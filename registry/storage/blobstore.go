@@ -20,6 +20,9 @@ type blobStore struct {
 	driver storagedriver.StorageDriver
 	pm     *pathMapper
 	ctx    context.Context
+
+	// algorithm is the digest algorithm of content put in the store.
+	algorithm string
 }
 
 // exists reports whether or not the path exists. If the driver returns error
@@ -109,7 +112,7 @@ func (bs *blobStore) resolve(path string) (string, error) {
 // content is already present, only the digest will be returned. This should
 // only be used for small objects, such as manifests.
 func (bs *blobStore) put(p []byte) (digest.Digest, error) {
-	dgst, err := digest.FromBytes(p)
+	dgst, err := digest.FromBytesWithAlgorithm(bs.algorithm, p)
 	if err != nil {
 		context.GetLogger(bs.ctx).Errorf("error digesting content: %v, %s", err, string(p))
 		return "", err
@@ -157,3 +160,21 @@ func exists(ctx context.Context, driver storagedriver.StorageDriver, path string
 
 	return true, nil
 }
+
+// existsMany reports whether or not each of the paths exists, stating them
+// together with storagedriver.StatMany. The error of a path is set if the
+// driver returns an error other than storagedriver.PathNotFound.
+func existsMany(ctx context.Context, driver storagedriver.StorageDriver, paths []string) ([]bool, []error) {
+	oks := make([]bool, len(paths))
+	_, errs := storagedriver.StatMany(ctx, driver, paths)
+	for i, err := range errs {
+		switch err.(type) {
+		case nil:
+			oks[i] = true
+		case storagedriver.PathNotFoundError:
+			errs[i] = nil
+		}
+	}
+
+	return oks, errs
+}
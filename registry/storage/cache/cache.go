@@ -7,6 +7,7 @@ package cache
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/docker/distribution/digest"
 	"golang.org/x/net/context"
@@ -46,6 +47,89 @@ type LayerInfoCache interface {
 	SetMeta(ctx context.Context, dgst digest.Digest, meta LayerMeta) error
 }
 
+// Flusher is implemented by caches whose contents can be discarded, for
+// example after the backend has been modified out of band.
+type Flusher interface {
+	// Flush removes all entries from the cache.
+	Flush(ctx context.Context) error
+}
+
+// BatchLookup is an optional interface implemented by caches which can look
+// up many layers in a single round trip, such as with pipelined requests.
+// Callers should use ContainsMany and MetaMany, which fall back to a lookup
+// for each layer for other caches.
+type BatchLookup interface {
+	// ContainsMany reports whether the repository with name contains each
+	// of the layers. The returned slice has the length of dgsts.
+	ContainsMany(ctx context.Context, repo string, dgsts []digest.Digest) ([]bool, error)
+
+	// MetaMany provides the meta data of each of the layers. The returned
+	// slices have the length of dgsts, with either the meta data or the
+	// error of the layer at the same index, ErrNotFound if it is not cached.
+	MetaMany(ctx context.Context, dgsts []digest.Digest) ([]LayerMeta, []error)
+}
+
+// ContainsMany reports whether the repository with name contains each of the
+// layers, with the batched implementation of the cache if it is a
+// BatchLookup, or else with a Contains call for each layer.
+func ContainsMany(ctx context.Context, lic LayerInfoCache, repo string, dgsts []digest.Digest) ([]bool, error) {
+	if batchLookup, ok := lic.(BatchLookup); ok {
+		return batchLookup.ContainsMany(ctx, repo, dgsts)
+	}
+
+	contained := make([]bool, len(dgsts))
+	for i, dgst := range dgsts {
+		var err error
+		contained[i], err = lic.Contains(ctx, repo, dgst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contained, nil
+}
+
+// MetaMany provides the meta data of each of the layers, with the batched
+// implementation of the cache if it is a BatchLookup, or else with a Meta
+// call for each layer.
+func MetaMany(ctx context.Context, lic LayerInfoCache, dgsts []digest.Digest) ([]LayerMeta, []error) {
+	if batchLookup, ok := lic.(BatchLookup); ok {
+		return batchLookup.MetaMany(ctx, dgsts)
+	}
+
+	metas := make([]LayerMeta, len(dgsts))
+	errs := make([]error, len(dgsts))
+	for i, dgst := range dgsts {
+		metas[i], errs[i] = lic.Meta(ctx, dgst)
+	}
+
+	return metas, errs
+}
+
+// Invalidator is implemented by caches whose entries can be discarded
+// selectively, for example after a repository is renamed or deleted.
+type Invalidator interface {
+	// InvalidateRepository removes the layer memberships of the repository,
+	// and the layers recorded missing from it by a NegativeCache. The meta
+	// data of the layers is kept, since it does not depend on the
+	// repositories holding them.
+	InvalidateRepository(ctx context.Context, repo string) error
+}
+
+// NegativeCache is an optional interface implemented by caches which can
+// record that a layer is missing from a repository for a limited time, so
+// that repeated checks for a layer which does not exist do not all reach the
+// backend. Adding the layer to the repository discards the record.
+type NegativeCache interface {
+	// AddMissing records that the repository with name does not contain the
+	// layer, for the duration of ttl.
+	AddMissing(ctx context.Context, repo string, dgst digest.Digest, ttl time.Duration) error
+
+	// Missing returns true if the layer is recorded missing from the
+	// repository and the record has not expired.
+	Missing(ctx context.Context, repo string, dgst digest.Digest) (bool, error)
+}
+
 // base implements common checks between cache implementations. Note that
 // these are not full checks of input, since that should be done by the
 // caller.
@@ -96,3 +180,91 @@ func (b *base) SetMeta(ctx context.Context, dgst digest.Digest, meta LayerMeta)
 
 	return b.LayerInfoCache.SetMeta(ctx, dgst, meta)
 }
+
+func (b *base) ContainsMany(ctx context.Context, repo string, dgsts []digest.Digest) ([]bool, error) {
+	if repo == "" {
+		return nil, fmt.Errorf("cache: cannot check for empty repository name")
+	}
+
+	for _, dgst := range dgsts {
+		if dgst == "" {
+			return nil, fmt.Errorf("cache: cannot check for empty digests")
+		}
+	}
+
+	return ContainsMany(ctx, b.LayerInfoCache, repo, dgsts)
+}
+
+func (b *base) MetaMany(ctx context.Context, dgsts []digest.Digest) ([]LayerMeta, []error) {
+	for _, dgst := range dgsts {
+		if dgst == "" {
+			errs := make([]error, len(dgsts))
+			for i := range errs {
+				errs[i] = fmt.Errorf("cache: cannot get meta for empty digest")
+			}
+			return make([]LayerMeta, len(dgsts)), errs
+		}
+	}
+
+	return MetaMany(ctx, b.LayerInfoCache, dgsts)
+}
+
+func (b *base) Flush(ctx context.Context) error {
+	flusher, ok := b.LayerInfoCache.(Flusher)
+	if !ok {
+		return fmt.Errorf("cache: flush not supported")
+	}
+
+	return flusher.Flush(ctx)
+}
+
+func (b *base) InvalidateRepository(ctx context.Context, repo string) error {
+	if repo == "" {
+		return fmt.Errorf("cache: cannot invalidate empty repository name")
+	}
+
+	invalidator, ok := b.LayerInfoCache.(Invalidator)
+	if !ok {
+		return fmt.Errorf("cache: invalidation not supported")
+	}
+
+	return invalidator.InvalidateRepository(ctx, repo)
+}
+
+func (b *base) AddMissing(ctx context.Context, repo string, dgst digest.Digest, ttl time.Duration) error {
+	if repo == "" {
+		return fmt.Errorf("cache: cannot add empty repository name")
+	}
+
+	if dgst == "" {
+		return fmt.Errorf("cache: cannot add empty digest")
+	}
+
+	if ttl <= 0 {
+		return fmt.Errorf("cache: cannot add missing layer without ttl")
+	}
+
+	negativeCache, ok := b.LayerInfoCache.(NegativeCache)
+	if !ok {
+		return fmt.Errorf("cache: negative caching not supported")
+	}
+
+	return negativeCache.AddMissing(ctx, repo, dgst, ttl)
+}
+
+func (b *base) Missing(ctx context.Context, repo string, dgst digest.Digest) (bool, error) {
+	if repo == "" {
+		return false, fmt.Errorf("cache: cannot check for empty repository name")
+	}
+
+	if dgst == "" {
+		return false, fmt.Errorf("cache: cannot check for empty digests")
+	}
+
+	negativeCache, ok := b.LayerInfoCache.(NegativeCache)
+	if !ok {
+		return false, fmt.Errorf("cache: negative caching not supported")
+	}
+
+	return negativeCache.Missing(ctx, repo, dgst)
+}
@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/docker/distribution/digest"
 	"golang.org/x/net/context"
 )
 
@@ -83,4 +86,137 @@ func checkLayerInfoCache(t *testing.T, lic LayerInfoCache) {
 	if meta != expected {
 		t.Fatalf("retrieved meta data did not match: %v", err)
 	}
+
+	if err := lic.(Flusher).Flush(ctx); err != nil {
+		t.Fatalf("unexpected error flushing cache: %v", err)
+	}
+
+	exists, err = lic.Contains(ctx, "foo/bar", "fake:abc")
+	if err != nil {
+		t.Fatalf("unexpected error checking for cache item after flush: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("item should not exist after flush")
+	}
+
+	if _, err := lic.Meta(ctx, "foo/bar"); err != ErrNotFound {
+		t.Fatalf("expected unknown layer error getting meta after flush: %v", err)
+	}
+
+	// Invalidating a repository keeps the other repositories and meta data.
+	for _, repo := range []string{"foo/bar", "foo/baz"} {
+		if err := lic.Add(ctx, repo, "fake:abc"); err != nil {
+			t.Fatalf("unexpected error adding %s to cache: %v", repo, err)
+		}
+	}
+
+	if err := lic.SetMeta(ctx, "fake:abc", expected); err != nil {
+		t.Fatalf("unexpected error setting meta: %v", err)
+	}
+
+	if err := lic.(Invalidator).InvalidateRepository(ctx, "foo/bar"); err != nil {
+		t.Fatalf("unexpected error invalidating repository: %v", err)
+	}
+
+	for repo, expectedExists := range map[string]bool{"foo/bar": false, "foo/baz": true} {
+		exists, err := lic.Contains(ctx, repo, "fake:abc")
+		if err != nil {
+			t.Fatalf("unexpected error checking for %s after invalidation: %v", repo, err)
+		}
+
+		if exists != expectedExists {
+			t.Fatalf("unexpected membership of %s after invalidation: %v != %v", repo, exists, expectedExists)
+		}
+	}
+
+	if _, err := lic.Meta(ctx, "fake:abc"); err != nil {
+		t.Fatalf("unexpected error getting meta after invalidation: %v", err)
+	}
+
+	if err := lic.(Invalidator).InvalidateRepository(ctx, ""); err == nil {
+		t.Fatalf("expected error invalidating empty repository name")
+	}
+
+	// Batched lookups match the individual ones.
+	dgsts := []digest.Digest{"fake:abc", "fake:def", "fake:abc"}
+	contained, err := ContainsMany(ctx, lic, "foo/baz", dgsts)
+	if err != nil {
+		t.Fatalf("unexpected error checking for many cache items: %v", err)
+	}
+
+	if !reflect.DeepEqual(contained, []bool{true, false, true}) {
+		t.Fatalf("unexpected batched membership: %v", contained)
+	}
+
+	if _, err := ContainsMany(ctx, lic, "foo/baz", []digest.Digest{"fake:abc", ""}); err == nil {
+		t.Fatalf("expected error checking for many cache items with an empty digest")
+	}
+
+	metas, errs := MetaMany(ctx, lic, dgsts)
+	if metas[0] != expected || errs[0] != nil || metas[2] != expected || errs[2] != nil {
+		t.Fatalf("unexpected batched meta: %v, %v", metas, errs)
+	}
+
+	if errs[1] != ErrNotFound {
+		t.Fatalf("expected unknown layer error getting batched meta: %v", errs[1])
+	}
+
+	// Layers are recorded missing until they expire or are added.
+	negativeCache := lic.(NegativeCache)
+	if err := negativeCache.AddMissing(ctx, "foo/baz", "fake:def", 0); err == nil {
+		t.Fatalf("expected error recording missing layer without ttl")
+	}
+
+	if err := negativeCache.AddMissing(ctx, "", "fake:def", time.Minute); err == nil {
+		t.Fatalf("expected error recording missing layer with empty repo")
+	}
+
+	for _, dgst := range []digest.Digest{"fake:def", "fake:ghi"} {
+		if err := negativeCache.AddMissing(ctx, "foo/baz", dgst, time.Minute); err != nil {
+			t.Fatalf("unexpected error recording missing layer: %v", err)
+		}
+	}
+
+	if err := negativeCache.AddMissing(ctx, "foo/baz", "fake:jkl", 10*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error recording missing layer: %v", err)
+	}
+
+	checkMissing := func(repo string, dgst digest.Digest, expected bool) {
+		missing, err := negativeCache.Missing(ctx, repo, dgst)
+		if err != nil {
+			t.Fatalf("unexpected error checking for missing layer %s@%s: %v", repo, dgst, err)
+		}
+
+		if missing != expected {
+			t.Fatalf("unexpected missing record of %s@%s: %v != %v", repo, dgst, missing, expected)
+		}
+	}
+
+	checkMissing("foo/baz", "fake:def", true)
+	checkMissing("foo/bar", "fake:def", false)
+	checkMissing("foo/baz", "fake:abc", false)
+
+	time.Sleep(50 * time.Millisecond)
+	checkMissing("foo/baz", "fake:jkl", false)
+
+	if err := lic.Add(ctx, "foo/baz", "fake:def"); err != nil {
+		t.Fatalf("unexpected error adding missing layer: %v", err)
+	}
+	checkMissing("foo/baz", "fake:def", false)
+	checkMissing("foo/baz", "fake:ghi", true)
+
+	if err := lic.(Invalidator).InvalidateRepository(ctx, "foo/baz"); err != nil {
+		t.Fatalf("unexpected error invalidating repository: %v", err)
+	}
+	checkMissing("foo/baz", "fake:ghi", false)
+
+	if err := negativeCache.AddMissing(ctx, "foo/baz", "fake:ghi", time.Minute); err != nil {
+		t.Fatalf("unexpected error recording missing layer: %v", err)
+	}
+
+	if err := lic.(Flusher).Flush(ctx); err != nil {
+		t.Fatalf("unexpected error flushing cache: %v", err)
+	}
+	checkMissing("foo/baz", "fake:ghi", false)
 }
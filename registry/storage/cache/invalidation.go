@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+// Invalidation is an invalidation of the layer info caches of the registry
+// instances of a deployment.
+type Invalidation struct {
+	// Source identifies the instance which published the invalidation.
+	Source string `json:"source"`
+
+	// Repository is the repository whose layer memberships are invalidated,
+	// or empty if the caches are flushed.
+	Repository string `json:"repository,omitempty"`
+}
+
+// InvalidationChannel carries invalidations between the registry instances
+// of a deployment.
+type InvalidationChannel interface {
+	// Publish sends the invalidation to the subscribed instances.
+	Publish(ctx context.Context, invalidation Invalidation) error
+
+	// Subscribe calls handle with the invalidations published on the
+	// channel, including those of the instance, until ctx is done or the
+	// subscription fails.
+	Subscribe(ctx context.Context, handle func(Invalidation)) error
+}
+
+// invalidationRetryInterval is the delay before subscribing again to an
+// invalidation channel after the subscription fails.
+const invalidationRetryInterval = 5 * time.Second
+
+// broadcastingLayerInfoCache publishes the invalidations and flushes of a
+// cache local to an instance, and applies those published by the other
+// instances.
+type broadcastingLayerInfoCache struct {
+	LayerInfoCache
+	channel InvalidationChannel
+	source  string
+}
+
+// NewBroadcastingLayerInfoCache returns a cache using lic, which is local to
+// the instance identified by source, that publishes its flushes and
+// invalidations on the channel, so that the caches of the other instances
+// sharing the storage backend do not keep stale entries. The invalidations
+// published by the other instances are applied to lic until ctx is done. Since
+// invalidations may be missed while the channel is unreachable, lic is flushed
+// when the subscription is restored.
+func NewBroadcastingLayerInfoCache(ctx context.Context, lic LayerInfoCache, channel InvalidationChannel, source string) LayerInfoCache {
+	blic := &broadcastingLayerInfoCache{
+		LayerInfoCache: lic,
+		channel:        channel,
+		source:         source,
+	}
+
+	go blic.subscribe(ctx)
+	return blic
+}
+
+// Flush flushes the local cache and the caches of the other instances.
+func (blic *broadcastingLayerInfoCache) Flush(ctx context.Context) error {
+	if err := blic.apply(ctx, Invalidation{}); err != nil {
+		return err
+	}
+
+	return blic.channel.Publish(ctx, Invalidation{Source: blic.source})
+}
+
+// InvalidateRepository invalidates the repository in the local cache and
+// the caches of the other instances.
+func (blic *broadcastingLayerInfoCache) InvalidateRepository(ctx context.Context, repo string) error {
+	invalidation := Invalidation{
+		Source:     blic.source,
+		Repository: repo,
+	}
+
+	if err := blic.apply(ctx, invalidation); err != nil {
+		return err
+	}
+
+	return blic.channel.Publish(ctx, invalidation)
+}
+
+// AddMissing records the layer missing from the repository in the local
+// cache. Since layers are only recorded missing for a short time, the record
+// is not published.
+func (blic *broadcastingLayerInfoCache) AddMissing(ctx context.Context, repo string, dgst digest.Digest, ttl time.Duration) error {
+	negativeCache, ok := blic.LayerInfoCache.(NegativeCache)
+	if !ok {
+		return fmt.Errorf("cache: negative caching not supported")
+	}
+	return negativeCache.AddMissing(ctx, repo, dgst, ttl)
+}
+
+// Missing checks the local cache for the record of the layer missing from
+// the repository.
+func (blic *broadcastingLayerInfoCache) Missing(ctx context.Context, repo string, dgst digest.Digest) (bool, error) {
+	negativeCache, ok := blic.LayerInfoCache.(NegativeCache)
+	if !ok {
+		return false, fmt.Errorf("cache: negative caching not supported")
+	}
+	return negativeCache.Missing(ctx, repo, dgst)
+}
+
+// apply applies the invalidation to the local cache.
+func (blic *broadcastingLayerInfoCache) apply(ctx context.Context, invalidation Invalidation) error {
+	if invalidation.Repository == "" {
+		flusher, ok := blic.LayerInfoCache.(Flusher)
+		if !ok {
+			return fmt.Errorf("cache: flush not supported")
+		}
+		return flusher.Flush(ctx)
+	}
+
+	invalidator, ok := blic.LayerInfoCache.(Invalidator)
+	if !ok {
+		return fmt.Errorf("cache: invalidation not supported")
+	}
+	return invalidator.InvalidateRepository(ctx, invalidation.Repository)
+}
+
+// subscribe applies the invalidations of the other instances until ctx is
+// done, subscribing again after failures.
+func (blic *broadcastingLayerInfoCache) subscribe(ctx context.Context) {
+	handle := func(invalidation Invalidation) {
+		if invalidation.Source == blic.source {
+			return
+		}
+
+		if err := blic.apply(ctx, invalidation); err != nil {
+			ctxu.GetLogger(ctx).Errorf("cache: error applying invalidation from %s: %v", invalidation.Source, err)
+		}
+	}
+
+	for {
+		err := blic.channel.Subscribe(ctx, handle)
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		ctxu.GetLogger(ctx).Errorf("cache: invalidation subscription failed, flushing cache: %v", err)
+		if err := blic.apply(ctx, Invalidation{}); err != nil {
+			ctxu.GetLogger(ctx).Errorf("cache: error flushing cache: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(invalidationRetryInterval):
+		}
+	}
+}
+
+// redisInvalidationChannel publishes invalidations on a redis pub/sub
+// channel.
+type redisInvalidationChannel struct {
+	pool *redis.Pool
+	name string
+}
+
+// NewRedisInvalidationChannel returns an invalidation channel using the redis
+// pub/sub channel with the given name.
+func NewRedisInvalidationChannel(pool *redis.Pool, name string) InvalidationChannel {
+	return &redisInvalidationChannel{
+		pool: pool,
+		name: name,
+	}
+}
+
+func (ric *redisInvalidationChannel) Publish(ctx context.Context, invalidation Invalidation) error {
+	p, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+
+	conn := ric.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", ric.name, p)
+	return err
+}
+
+func (ric *redisInvalidationChannel) Subscribe(ctx context.Context, handle func(Invalidation)) error {
+	psc := redis.PubSubConn{Conn: ric.pool.Get()}
+	defer psc.Close()
+
+	if err := psc.Subscribe(ric.name); err != nil {
+		return err
+	}
+
+	// Closing the connection interrupts the pending receive.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			psc.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var invalidation Invalidation
+			if err := json.Unmarshal(v.Data, &invalidation); err != nil {
+				ctxu.GetLogger(ctx).Errorf("cache: invalid invalidation on %s: %v", ric.name, err)
+				continue
+			}
+			handle(invalidation)
+		case error:
+			return v
+		}
+	}
+}
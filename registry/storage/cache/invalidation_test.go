@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// testInvalidationChannel delivers invalidations to the subscribers in the
+// process, and fails their subscriptions once failed is closed.
+type testInvalidationChannel struct {
+	mu          sync.Mutex
+	subscribers map[chan Invalidation]struct{}
+	failed      chan struct{}
+}
+
+func newTestInvalidationChannel() *testInvalidationChannel {
+	return &testInvalidationChannel{
+		subscribers: make(map[chan Invalidation]struct{}),
+		failed:      make(chan struct{}),
+	}
+}
+
+func (tic *testInvalidationChannel) Publish(ctx context.Context, invalidation Invalidation) error {
+	tic.mu.Lock()
+	defer tic.mu.Unlock()
+
+	for subscriber := range tic.subscribers {
+		subscriber <- invalidation
+	}
+	return nil
+}
+
+func (tic *testInvalidationChannel) Subscribe(ctx context.Context, handle func(Invalidation)) error {
+	subscriber := make(chan Invalidation, 10)
+	tic.mu.Lock()
+	tic.subscribers[subscriber] = struct{}{}
+	tic.mu.Unlock()
+
+	defer func() {
+		tic.mu.Lock()
+		delete(tic.subscribers, subscriber)
+		tic.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case invalidation := <-subscriber:
+			handle(invalidation)
+		case <-tic.failed:
+			return fmt.Errorf("connection lost")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TestBroadcastingLayerInfoCache checks that invalidations and flushes of
+// one instance reach the cache of another.
+func TestBroadcastingLayerInfoCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	channel := newTestInvalidationChannel()
+	local := NewInMemoryLayerInfoCache()
+	a := NewBroadcastingLayerInfoCache(ctx, NewInMemoryLayerInfoCache(), channel, "a")
+	b := NewBroadcastingLayerInfoCache(ctx, local, channel, "b")
+
+	waitSubscribers := func(n int) {
+		for i := 0; i < 100; i++ {
+			channel.mu.Lock()
+			subscribed := len(channel.subscribers)
+			channel.mu.Unlock()
+			if subscribed == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d subscribers", n)
+	}
+	waitSubscribers(2)
+
+	contains := func(lic LayerInfoCache, repo string) bool {
+		// Invalidations are applied asynchronously.
+		time.Sleep(20 * time.Millisecond)
+
+		exists, err := lic.Contains(ctx, repo, "fake:abc")
+		if err != nil {
+			t.Fatalf("unexpected error checking %s: %v", repo, err)
+		}
+		return exists
+	}
+
+	for _, lic := range []LayerInfoCache{a, b} {
+		for _, repo := range []string{"foo/bar", "foo/baz"} {
+			if err := lic.Add(ctx, repo, "fake:abc"); err != nil {
+				t.Fatalf("unexpected error adding %s: %v", repo, err)
+			}
+		}
+	}
+
+	if err := a.(Invalidator).InvalidateRepository(ctx, "foo/bar"); err != nil {
+		t.Fatalf("unexpected error invalidating repository: %v", err)
+	}
+
+	if contains(a, "foo/bar") || contains(b, "foo/bar") {
+		t.Fatalf("expected foo/bar to be invalidated in both caches")
+	}
+
+	if !contains(a, "foo/baz") || !contains(b, "foo/baz") {
+		t.Fatalf("expected foo/baz to be kept in both caches")
+	}
+
+	// Missing layers are recorded locally, and discarded by invalidations.
+	if err := b.(NegativeCache).AddMissing(ctx, "foo/bar", "fake:def", time.Minute); err != nil {
+		t.Fatalf("unexpected error recording missing layer: %v", err)
+	}
+
+	if err := a.(Invalidator).InvalidateRepository(ctx, "foo/bar"); err != nil {
+		t.Fatalf("unexpected error invalidating repository: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if missing, err := b.(NegativeCache).Missing(ctx, "foo/bar", "fake:def"); err != nil || missing {
+		t.Fatalf("expected the missing layer to be invalidated: %v, %v", missing, err)
+	}
+
+	if err := a.(Flusher).Flush(ctx); err != nil {
+		t.Fatalf("unexpected error flushing cache: %v", err)
+	}
+
+	if contains(b, "foo/baz") {
+		t.Fatalf("expected the flush to reach the other cache")
+	}
+
+	// Invalidations may be missed while the subscription is down, so the
+	// cache is flushed when it fails.
+	if err := local.Add(ctx, "foo/bar", "fake:abc"); err != nil {
+		t.Fatalf("unexpected error adding foo/bar: %v", err)
+	}
+
+	close(channel.failed)
+	if contains(b, "foo/bar") {
+		t.Fatalf("expected the cache to be flushed after the subscription failed")
+	}
+}
@@ -1,14 +1,22 @@
 package cache
 
 import (
+	"sync"
+	"time"
+
 	"github.com/docker/distribution/digest"
 	"golang.org/x/net/context"
 )
 
 // inmemoryLayerInfoCache is a map-based implementation of LayerInfoCache.
 type inmemoryLayerInfoCache struct {
+	mu         sync.RWMutex
 	membership map[string]map[digest.Digest]struct{}
 	meta       map[digest.Digest]LayerMeta
+
+	// missing holds the expiry of the records of layers missing from each
+	// repository.
+	missing map[string]map[digest.Digest]time.Time
 }
 
 // NewInMemoryLayerInfoCache provides an implementation of LayerInfoCache that
@@ -17,10 +25,14 @@ func NewInMemoryLayerInfoCache() LayerInfoCache {
 	return &base{&inmemoryLayerInfoCache{
 		membership: make(map[string]map[digest.Digest]struct{}),
 		meta:       make(map[digest.Digest]LayerMeta),
+		missing:    make(map[string]map[digest.Digest]time.Time),
 	}}
 }
 
 func (ilic *inmemoryLayerInfoCache) Contains(ctx context.Context, repo string, dgst digest.Digest) (bool, error) {
+	ilic.mu.RLock()
+	defer ilic.mu.RUnlock()
+
 	members, ok := ilic.membership[repo]
 	if !ok {
 		return false, nil
@@ -32,6 +44,9 @@ func (ilic *inmemoryLayerInfoCache) Contains(ctx context.Context, repo string, d
 
 // Add adds the layer to the redis repository blob set.
 func (ilic *inmemoryLayerInfoCache) Add(ctx context.Context, repo string, dgst digest.Digest) error {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
 	members, ok := ilic.membership[repo]
 	if !ok {
 		members = make(map[digest.Digest]struct{})
@@ -39,6 +54,7 @@ func (ilic *inmemoryLayerInfoCache) Add(ctx context.Context, repo string, dgst d
 	}
 
 	members[dgst] = struct{}{}
+	delete(ilic.missing[repo], dgst)
 
 	return nil
 }
@@ -46,6 +62,9 @@ func (ilic *inmemoryLayerInfoCache) Add(ctx context.Context, repo string, dgst d
 // Meta retrieves the layer meta data from the redis hash, returning
 // ErrUnknownLayer if not found.
 func (ilic *inmemoryLayerInfoCache) Meta(ctx context.Context, dgst digest.Digest) (LayerMeta, error) {
+	ilic.mu.RLock()
+	defer ilic.mu.RUnlock()
+
 	meta, ok := ilic.meta[dgst]
 	if !ok {
 		return LayerMeta{}, ErrNotFound
@@ -58,6 +77,66 @@ func (ilic *inmemoryLayerInfoCache) Meta(ctx context.Context, dgst digest.Digest
 // is used here since we may store unrelated fields about a layer in the
 // future.
 func (ilic *inmemoryLayerInfoCache) SetMeta(ctx context.Context, dgst digest.Digest, meta LayerMeta) error {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
 	ilic.meta[dgst] = meta
 	return nil
 }
+
+// Flush discards all repository memberships and meta data.
+func (ilic *inmemoryLayerInfoCache) Flush(ctx context.Context) error {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
+	ilic.membership = make(map[string]map[digest.Digest]struct{})
+	ilic.meta = make(map[digest.Digest]LayerMeta)
+	ilic.missing = make(map[string]map[digest.Digest]time.Time)
+	return nil
+}
+
+// InvalidateRepository discards the layer memberships of the repository and
+// the layers recorded missing from it.
+func (ilic *inmemoryLayerInfoCache) InvalidateRepository(ctx context.Context, repo string) error {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
+	delete(ilic.membership, repo)
+	delete(ilic.missing, repo)
+	return nil
+}
+
+// AddMissing records the layer missing from the repository until ttl
+// elapses.
+func (ilic *inmemoryLayerInfoCache) AddMissing(ctx context.Context, repo string, dgst digest.Digest, ttl time.Duration) error {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
+	missing, ok := ilic.missing[repo]
+	if !ok {
+		missing = make(map[digest.Digest]time.Time)
+		ilic.missing[repo] = missing
+	}
+
+	missing[dgst] = time.Now().Add(ttl)
+	return nil
+}
+
+// Missing returns true if the layer is recorded missing from the repository,
+// discarding the record if it has expired.
+func (ilic *inmemoryLayerInfoCache) Missing(ctx context.Context, repo string, dgst digest.Digest) (bool, error) {
+	ilic.mu.Lock()
+	defer ilic.mu.Unlock()
+
+	expiry, ok := ilic.missing[repo][dgst]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Now().After(expiry) {
+		delete(ilic.missing[repo], dgst)
+		return false, nil
+	}
+
+	return true, nil
+}
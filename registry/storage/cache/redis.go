@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"time"
+
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/garyburd/redigo/redis"
@@ -49,7 +51,11 @@ func (rlic *redisLayerInfoCache) Add(ctx context.Context, repo string, dgst dige
 	defer conn.Close()
 
 	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).Add(%q, %q)", repo, dgst)
-	_, err := conn.Do("SADD", rlic.repositoryBlobSetKey(repo), dgst)
+	if err := conn.Send("SADD", rlic.repositoryBlobSetKey(repo), dgst); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("DEL", rlic.missingBlobKey(repo, dgst))
 	return err
 }
 
@@ -64,6 +70,12 @@ func (rlic *redisLayerInfoCache) Meta(ctx context.Context, dgst digest.Digest) (
 		return LayerMeta{}, err
 	}
 
+	return parseMeta(reply)
+}
+
+// parseMeta parses the reply to an HMGET of the path and length of a layer,
+// returning ErrNotFound if they are not set.
+func parseMeta(reply []interface{}) (LayerMeta, error) {
 	if len(reply) < 2 || reply[0] == nil || reply[1] == nil {
 		return LayerMeta{}, ErrNotFound
 	}
@@ -76,6 +88,78 @@ func (rlic *redisLayerInfoCache) Meta(ctx context.Context, dgst digest.Digest) (
 	return meta, nil
 }
 
+// ContainsMany pipelines the membership checks of the layers on a single
+// connection, making a single round trip to redis.
+func (rlic *redisLayerInfoCache) ContainsMany(ctx context.Context, repo string, dgsts []digest.Digest) ([]bool, error) {
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).ContainsMany(%q, %d)", repo, len(dgsts))
+	for _, dgst := range dgsts {
+		if err := conn.Send("SISMEMBER", rlic.repositoryBlobSetKey(repo), dgst); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	contained := make([]bool, len(dgsts))
+	for i := range dgsts {
+		var err error
+		contained[i], err = redis.Bool(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contained, nil
+}
+
+// MetaMany pipelines the meta data lookups of the layers on a single
+// connection, making a single round trip to redis.
+func (rlic *redisLayerInfoCache) MetaMany(ctx context.Context, dgsts []digest.Digest) ([]LayerMeta, []error) {
+	metas := make([]LayerMeta, len(dgsts))
+	errs := make([]error, len(dgsts))
+	fail := func(err error) ([]LayerMeta, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return metas, errs
+	}
+
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).MetaMany(%d)", len(dgsts))
+	for _, dgst := range dgsts {
+		if err := conn.Send("HMGET", rlic.blobMetaHashKey(dgst), "path", "length"); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return fail(err)
+	}
+
+	for i := range dgsts {
+		reply, err := redis.Values(conn.Receive())
+		if err != nil {
+			// The connection is unusable once a reply is lost.
+			if _, ok := err.(redis.Error); !ok {
+				return fail(err)
+			}
+			errs[i] = err
+			continue
+		}
+
+		metas[i], errs[i] = parseMeta(reply)
+	}
+
+	return metas, errs
+}
+
 // SetMeta sets the meta data for the given digest using a redis hash. A hash
 // is used here since we may store unrelated fields about a layer in the
 // future.
@@ -87,11 +171,101 @@ func (rlic *redisLayerInfoCache) SetMeta(ctx context.Context, dgst digest.Digest
 	return err
 }
 
+// Flush deletes all repository blob sets and blob meta data hashes from
+// redis.
+func (rlic *redisLayerInfoCache) Flush(ctx context.Context) error {
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).Flush()")
+	for _, pattern := range []string{rlic.repositoryBlobSetKey("*"), rlic.missingBlobKey("*", "*"), rlic.blobMetaHashKey("*")} {
+		if err := deleteMatching(conn, pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InvalidateRepository deletes the blob set of the repository and the keys
+// of the layers missing from it from redis.
+func (rlic *redisLayerInfoCache) InvalidateRepository(ctx context.Context, repo string) error {
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).InvalidateRepository(%q)", repo)
+	if _, err := conn.Do("DEL", rlic.repositoryBlobSetKey(repo)); err != nil {
+		return err
+	}
+
+	return deleteMatching(conn, rlic.missingBlobKey(repo, "*"))
+}
+
+// deleteMatching deletes the keys matching pattern. It iterates over them
+// with SCAN rather than KEYS, which would block redis while it walks the
+// whole keyspace.
+func deleteMatching(conn redis.Conn, pattern string) error {
+	cursor := "0"
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 1000))
+		if err != nil {
+			return err
+		}
+
+		var keys []interface{}
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if _, err := conn.Do("DEL", keys...); err != nil {
+				return err
+			}
+		}
+
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// AddMissing sets a key recording the layer missing from the repository,
+// which redis expires after ttl.
+func (rlic *redisLayerInfoCache) AddMissing(ctx context.Context, repo string, dgst digest.Digest, ttl time.Duration) error {
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).AddMissing(%q, %q, %s)", repo, dgst, ttl)
+	ms := int64(ttl / time.Millisecond)
+	if ms <= 0 {
+		ms = 1
+	}
+
+	_, err := conn.Do("SET", rlic.missingBlobKey(repo, dgst), 1, "PX", ms)
+	return err
+}
+
+// Missing checks for the key recording the layer missing from the
+// repository.
+func (rlic *redisLayerInfoCache) Missing(ctx context.Context, repo string, dgst digest.Digest) (bool, error) {
+	conn := rlic.pool.Get()
+	defer conn.Close()
+
+	ctxu.GetLogger(ctx).Debugf("(*redisLayerInfoCache).Missing(%q, %q)", repo, dgst)
+	return redis.Bool(conn.Do("EXISTS", rlic.missingBlobKey(repo, dgst)))
+}
+
 // repositoryBlobSetKey returns the key for the blob set in the cache.
 func (rlic *redisLayerInfoCache) repositoryBlobSetKey(repo string) string {
 	return "repository::" + repo + "::blobs"
 }
 
+// missingBlobKey returns the key recording the layer missing from the
+// repository in the cache.
+func (rlic *redisLayerInfoCache) missingBlobKey(repo string, dgst digest.Digest) string {
+	return "repository::" + repo + "::missing::" + dgst.String()
+}
+
 // blobMetaHashKey returns the cache key for immutable blob meta data.
 func (rlic *redisLayerInfoCache) blobMetaHashKey(dgst digest.Digest) string {
 	return "blobs::" + dgst.String()
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,15 +23,42 @@ import (
 const driverName = "azure"
 
 const (
-	paramAccountName = "accountname"
-	paramAccountKey  = "accountkey"
-	paramContainer   = "container"
-	paramRealm       = "realm"
+	paramAccountName      = "accountname"
+	paramAccountKey       = "accountkey"
+	paramContainer        = "container"
+	paramRealm            = "realm"
+	paramScheme           = "scheme"
+	paramBlockSize        = "blocksize"
+	paramBlockConcurrency = "blockconcurrency"
 )
 
+const (
+	// defaultBlockSize is the size of the blocks WriteStream uploads, which
+	// is also the largest block size the service accepts.
+	defaultBlockSize = azure.MaxBlobBlockSize
+
+	// defaultBlockConcurrency is the default number of blocks of a single
+	// WriteStream call which are uploaded concurrently.
+	defaultBlockConcurrency = 1
+)
+
+// DriverParameters encapsulates all of the driver parameters after all
+// values have been set.
+type DriverParameters struct {
+	AccountName      string
+	AccountKey       string
+	Container        string
+	Realm            string
+	Scheme           string
+	BlockSize        int
+	BlockConcurrency int
+}
+
 type driver struct {
-	client    azure.BlobStorageClient
-	container string
+	client           azure.BlobStorageClient
+	container        string
+	blockSize        int
+	blockConcurrency int
 }
 
 type baseEmbed struct{ base.Base }
@@ -51,19 +79,30 @@ func (factory *azureDriverFactory) Create(parameters map[string]interface{}) (st
 
 // FromParameters constructs a new Driver with a given parameters map.
 func FromParameters(parameters map[string]interface{}) (*Driver, error) {
+	params, err := driverParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(params)
+}
+
+// driverParameters validates the parameters map and sets the defaults of the
+// missing optional parameters.
+func driverParameters(parameters map[string]interface{}) (DriverParameters, error) {
 	accountName, ok := parameters[paramAccountName]
 	if !ok || fmt.Sprint(accountName) == "" {
-		return nil, fmt.Errorf("No %s parameter provided", paramAccountName)
+		return DriverParameters{}, fmt.Errorf("No %s parameter provided", paramAccountName)
 	}
 
 	accountKey, ok := parameters[paramAccountKey]
 	if !ok || fmt.Sprint(accountKey) == "" {
-		return nil, fmt.Errorf("No %s parameter provided", paramAccountKey)
+		return DriverParameters{}, fmt.Errorf("No %s parameter provided", paramAccountKey)
 	}
 
 	container, ok := parameters[paramContainer]
 	if !ok || fmt.Sprint(container) == "" {
-		return nil, fmt.Errorf("No %s parameter provided", paramContainer)
+		return DriverParameters{}, fmt.Errorf("No %s parameter provided", paramContainer)
 	}
 
 	realm, ok := parameters[paramRealm]
@@ -71,12 +110,76 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		realm = azure.DefaultBaseUrl
 	}
 
-	return New(fmt.Sprint(accountName), fmt.Sprint(accountKey), fmt.Sprint(container), fmt.Sprint(realm))
+	scheme, ok := parameters[paramScheme]
+	if !ok || fmt.Sprint(scheme) == "" {
+		scheme = "https"
+	}
+	if scheme != "http" && scheme != "https" {
+		return DriverParameters{}, fmt.Errorf("The %s parameter should be http or https, %v invalid", paramScheme, scheme)
+	}
+
+	blockSize := defaultBlockSize
+	if v, ok := parameters[paramBlockSize]; ok {
+		n, err := intParameter(paramBlockSize, v)
+		if err != nil {
+			return DriverParameters{}, err
+		}
+		if n < 1 || n > azure.MaxBlobBlockSize {
+			return DriverParameters{}, fmt.Errorf("The %s parameter should be a number between 1 and %d", paramBlockSize, azure.MaxBlobBlockSize)
+		}
+		blockSize = n
+	}
+
+	blockConcurrency := defaultBlockConcurrency
+	if v, ok := parameters[paramBlockConcurrency]; ok {
+		n, err := intParameter(paramBlockConcurrency, v)
+		if err != nil {
+			return DriverParameters{}, err
+		}
+		if n < 1 {
+			return DriverParameters{}, fmt.Errorf("The %s parameter should be a number larger than or equal to 1", paramBlockConcurrency)
+		}
+		blockConcurrency = n
+	}
+
+	return DriverParameters{
+		AccountName:      fmt.Sprint(accountName),
+		AccountKey:       fmt.Sprint(accountKey),
+		Container:        fmt.Sprint(container),
+		Realm:            fmt.Sprint(realm),
+		Scheme:           fmt.Sprint(scheme),
+		BlockSize:        blockSize,
+		BlockConcurrency: blockConcurrency,
+	}, nil
 }
 
-// New constructs a new Driver with the given Azure Storage Account credentials
-func New(accountName, accountKey, container, realm string) (*Driver, error) {
-	api, err := azure.NewClient(accountName, accountKey, realm, azure.DefaultApiVersion, true)
+// intParameter converts an integer parameter, which may have been provided
+// as a string or any integer type, to an int.
+func intParameter(name string, v interface{}) (int, error) {
+	switch v := v.(type) {
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("The %s parameter must be an integer, %v invalid", name, v)
+		}
+		return n, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("The %s parameter must be an integer, %#v invalid", name, v)
+	}
+}
+
+// New constructs a new Driver with the given Azure Storage Account
+// credentials. Requests are sent to the account's endpoint under Realm, over
+// https unless Scheme is http.
+func New(params DriverParameters) (*Driver, error) {
+	useHTTPS := params.Scheme != "http"
+	api, err := azure.NewClient(params.AccountName, params.AccountKey, params.Realm, azure.DefaultApiVersion, useHTTPS)
 	if err != nil {
 		return nil, err
 	}
@@ -84,13 +187,19 @@ func New(accountName, accountKey, container, realm string) (*Driver, error) {
 	blobClient := api.GetBlobService()
 
 	// Create registry container
-	if _, err = blobClient.CreateContainerIfNotExists(container, azure.ContainerAccessTypePrivate); err != nil {
+	if _, err = blobClient.CreateContainerIfNotExists(params.Container, azure.ContainerAccessTypePrivate); err != nil {
 		return nil, err
 	}
 
 	d := &driver{
-		client:    *blobClient,
-		container: container}
+		client:           *blobClient,
+		container:        params.Container,
+		blockSize:        params.BlockSize,
+		blockConcurrency: params.BlockConcurrency,
+	}
+	if d.blockSize <= 0 || d.blockSize > azure.MaxBlobBlockSize {
+		d.blockSize = defaultBlockSize
+	}
 	return &Driver{baseEmbed: baseEmbed{Base: base.Base{StorageDriver: d}}}, nil
 }
 
@@ -160,7 +269,7 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 	}
 
 	bs := newAzureBlockStorage(d.client)
-	bw := newRandomBlobWriter(&bs, azure.MaxBlobBlockSize)
+	bw := newRandomBlobWriter(&bs, d.blockSize, d.blockConcurrency)
 	zw := newZeroFillWriter(&bw)
 	return zw.Write(d.container, path, offset, reader)
 }
@@ -16,6 +16,7 @@ const (
 	envAccountKey  = "AZURE_STORAGE_ACCOUNT_KEY"
 	envContainer   = "AZURE_STORAGE_CONTAINER"
 	envRealm       = "AZURE_STORAGE_REALM"
+	envScheme      = "AZURE_STORAGE_SCHEME"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -27,6 +28,7 @@ func init() {
 		accountKey  string
 		container   string
 		realm       string
+		scheme      = os.Getenv(envScheme)
 	)
 
 	config := []struct {
@@ -48,7 +50,13 @@ func init() {
 	}
 
 	azureDriverConstructor := func() (storagedriver.StorageDriver, error) {
-		return New(accountName, accountKey, container, realm)
+		return New(DriverParameters{
+			AccountName: accountName,
+			AccountKey:  accountKey,
+			Container:   container,
+			Realm:       realm,
+			Scheme:      scheme,
+		})
 	}
 
 	// Skip Azure storage driver tests if environment variable parameters are not provided
@@ -67,3 +75,91 @@ func init() {
 	// 	paramRealm:       realm,
 	// }, skipCheck)
 }
+
+func TestDriverParametersScheme(t *testing.T) {
+	for _, testcase := range []struct {
+		scheme   interface{}
+		expected string
+		valid    bool
+	}{
+		{expected: "https", valid: true},
+		{scheme: "", expected: "https", valid: true},
+		{scheme: "http", expected: "http", valid: true},
+		{scheme: "https", expected: "https", valid: true},
+		{scheme: "ftp"},
+		{scheme: "HTTP"},
+	} {
+		parameters := map[string]interface{}{
+			paramAccountName: "account",
+			paramAccountKey:  "key",
+			paramContainer:   "container",
+		}
+		if testcase.scheme != nil {
+			parameters[paramScheme] = testcase.scheme
+		}
+
+		params, err := driverParameters(parameters)
+		if !testcase.valid {
+			if err == nil {
+				t.Fatalf("expected an error with scheme %v", testcase.scheme)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error with scheme %v: %v", testcase.scheme, err)
+		}
+		if params.Scheme != testcase.expected {
+			t.Fatalf("unexpected scheme with parameter %v: %s != %s", testcase.scheme, params.Scheme, testcase.expected)
+		}
+	}
+}
+
+func TestDriverParametersBlocks(t *testing.T) {
+	for _, testcase := range []struct {
+		blockSize        interface{}
+		blockConcurrency interface{}
+		expectedSize     int
+		expectedConc     int
+		valid            bool
+	}{
+		{expectedSize: defaultBlockSize, expectedConc: defaultBlockConcurrency, valid: true},
+		{blockSize: 1 << 20, blockConcurrency: 4, expectedSize: 1 << 20, expectedConc: 4, valid: true},
+		{blockSize: "1048576", blockConcurrency: "8", expectedSize: 1 << 20, expectedConc: 8, valid: true},
+		{blockSize: uint64(1), blockConcurrency: int64(1), expectedSize: 1, expectedConc: 1, valid: true},
+		{blockSize: 0},
+		{blockSize: defaultBlockSize + 1},
+		{blockSize: "large"},
+		{blockSize: 1.5},
+		{blockConcurrency: 0},
+		{blockConcurrency: "many"},
+	} {
+		parameters := map[string]interface{}{
+			paramAccountName: "account",
+			paramAccountKey:  "key",
+			paramContainer:   "container",
+		}
+		if testcase.blockSize != nil {
+			parameters[paramBlockSize] = testcase.blockSize
+		}
+		if testcase.blockConcurrency != nil {
+			parameters[paramBlockConcurrency] = testcase.blockConcurrency
+		}
+
+		params, err := driverParameters(parameters)
+		if !testcase.valid {
+			if err == nil {
+				t.Fatalf("expected an error with block size %v and concurrency %v", testcase.blockSize, testcase.blockConcurrency)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error with block size %v and concurrency %v: %v", testcase.blockSize, testcase.blockConcurrency, err)
+		}
+		if params.BlockSize != testcase.expectedSize || params.BlockConcurrency != testcase.expectedConc {
+			t.Fatalf("unexpected block size %d and concurrency %d with parameters %v and %v",
+				params.BlockSize, params.BlockConcurrency, testcase.blockSize, testcase.blockConcurrency)
+		}
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sync"
 
 	azure "github.com/MSOpenTech/azure-sdk-for-go/storage"
 )
 
 type StorageSimulator struct {
+	mu    *sync.Mutex
 	blobs map[string]*BlockBlob
 }
 
@@ -68,6 +70,9 @@ func (s *StorageSimulator) CreateBlockBlob(container, blob string) error {
 }
 
 func (s *StorageSimulator) PutBlock(container, blob, blockID string, chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	path := s.path(container, blob)
 	bb, ok := s.blobs[path]
 	if !ok {
@@ -150,6 +155,7 @@ func (s *StorageSimulator) PutBlockList(container, blob string, blocks []azure.B
 
 func NewStorageSimulator() StorageSimulator {
 	return StorageSimulator{
+		mu:    new(sync.Mutex),
 		blobs: make(map[string]*BlockBlob),
 	}
 }
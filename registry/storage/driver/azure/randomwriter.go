@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sync"
 
 	azure "github.com/MSOpenTech/azure-sdk-for-go/storage"
 )
@@ -24,13 +25,19 @@ type blockStorage interface {
 // within the blob. Normally, Azure Blob Storage does not support random
 // access semantics on block blobs; however, this writer can download, split and
 // reupload the overlapping blocks and discards those being overwritten entirely.
+//
+// Up to parallelism blocks of a single chunk are uploaded concurrently.
 type randomBlobWriter struct {
-	bs        blockStorage
-	blockSize int
+	bs          blockStorage
+	blockSize   int
+	parallelism int
 }
 
-func newRandomBlobWriter(bs blockStorage, blockSize int) randomBlobWriter {
-	return randomBlobWriter{bs: bs, blockSize: blockSize}
+func newRandomBlobWriter(bs blockStorage, blockSize, parallelism int) randomBlobWriter {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return randomBlobWriter{bs: bs, blockSize: blockSize, parallelism: parallelism}
 }
 
 // WriteBlobAt writes the given chunk to the specified position of an existing blob.
@@ -97,23 +104,60 @@ func (r *randomBlobWriter) writeChunkToBlocks(container, blob string, chunk io.R
 	var newBlocks []azure.Block
 	var nn int64
 
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex // protects putErr
+		putErr error
+		slots  = make(chan struct{}, r.parallelism)
+		free   = make(chan []byte, r.parallelism) // buffers of finished uploads
+	)
+
 	// Read chunks of at most size N except the last chunk to
 	// maximize block size and minimize block count.
-	buf := make([]byte, r.blockSize)
 	for {
+		slots <- struct{}{} // wait for an upload slot
+
+		mu.Lock()
+		err := putErr
+		mu.Unlock()
+		if err != nil {
+			<-slots
+			break
+		}
+
+		var buf []byte
+		select {
+		case buf = <-free:
+		default:
+			buf = make([]byte, r.blockSize)
+		}
+
 		n, err := io.ReadFull(chunk, buf)
 		if err == io.EOF {
+			<-slots
 			break
 		}
 		nn += int64(n)
-		data := buf[:n]
 		blockID := rand.Generate()
-		if err := r.bs.PutBlock(container, blob, blockID, data); err != nil {
-			return newBlocks, nn, err
-		}
 		newBlocks = append(newBlocks, azure.Block{Id: blockID, Status: azure.BlockStatusUncommitted})
+
+		wg.Add(1)
+		go func(blockID string, buf []byte, n int) {
+			defer wg.Done()
+			if err := r.bs.PutBlock(container, blob, blockID, buf[:n]); err != nil {
+				mu.Lock()
+				if putErr == nil {
+					putErr = err
+				}
+				mu.Unlock()
+			}
+			free <- buf
+			<-slots
+		}(blockID, buf, n)
 	}
-	return newBlocks, nn, nil
+
+	wg.Wait()
+	return newBlocks, nn, putErr
 }
 
 // blocksLeftSide returns the blocks that are going to be at the left side of
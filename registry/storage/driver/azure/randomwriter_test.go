@@ -14,7 +14,7 @@ import (
 
 func TestRandomWriter_writeChunkToBlocks(t *testing.T) {
 	s := NewStorageSimulator()
-	rw := newRandomBlobWriter(&s, 3)
+	rw := newRandomBlobWriter(&s, 3, 1)
 	rand := newBlockIDGenerator()
 	c := []byte("AAABBBCCCD")
 
@@ -54,6 +54,37 @@ func TestRandomWriter_writeChunkToBlocks(t *testing.T) {
 	assertBlobContents(t, r, c)
 }
 
+func TestRandomWriter_writeChunkToBlocksParallel(t *testing.T) {
+	s := NewStorageSimulator()
+	rw := newRandomBlobWriter(&s, 3, 4)
+	rand := newBlockIDGenerator()
+	c := []byte("AAABBBCCCDDDEEEFFFG")
+
+	if err := rw.bs.CreateBlockBlob("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	bw, nn, err := rw.writeChunkToBlocks("a", "b", bytes.NewReader(c), rand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := int64(len(c)); nn != expected {
+		t.Fatalf("wrong nn:%v, expected:%v", nn, expected)
+	}
+	if expected := 7; len(bw) != expected {
+		t.Fatalf("unexpected written block count: %d", len(bw))
+	}
+
+	if err := rw.bs.PutBlockList("a", "b", bw); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rw.bs.GetBlob("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBlobContents(t, r, c)
+}
+
 func TestRandomWriter_blocksLeftSide(t *testing.T) {
 	blob := "AAAAABBBBBCCC"
 	cases := []struct {
@@ -61,7 +92,7 @@ func TestRandomWriter_blocksLeftSide(t *testing.T) {
 		expectedBlob    string
 		expectedPattern []azure.BlockStatus
 	}{
-		{0, "", []azure.BlockStatus{}},                                                                                      // write to beginning, discard all
+		{0, "", []azure.BlockStatus{}}, // write to beginning, discard all
 		{13, blob, []azure.BlockStatus{azure.BlockStatusCommitted, azure.BlockStatusCommitted, azure.BlockStatusCommitted}}, // write to end, no change
 		{1, "A", []azure.BlockStatus{azure.BlockStatusUncommitted}},                                                         // write at 1
 		{5, "AAAAA", []azure.BlockStatus{azure.BlockStatusCommitted}},                                                       // write just after first block
@@ -71,7 +102,7 @@ func TestRandomWriter_blocksLeftSide(t *testing.T) {
 
 	for _, c := range cases {
 		s := NewStorageSimulator()
-		rw := newRandomBlobWriter(&s, 5)
+		rw := newRandomBlobWriter(&s, 5, 1)
 		rand := newBlockIDGenerator()
 
 		if err := rw.bs.CreateBlockBlob("a", "b"); err != nil {
@@ -124,19 +155,19 @@ func TestRandomWriter_blocksRightSide(t *testing.T) {
 		expectedBlob    string
 		expectedPattern []azure.BlockStatus
 	}{
-		{0, 100, "", []azure.BlockStatus{}},                                                                                             // overwrite the entire blob
+		{0, 100, "", []azure.BlockStatus{}}, // overwrite the entire blob
 		{0, 3, "AABBBBBCCC", []azure.BlockStatus{azure.BlockStatusUncommitted, azure.BlockStatusCommitted, azure.BlockStatusCommitted}}, // split first block
 		{4, 1, "BBBBBCCC", []azure.BlockStatus{azure.BlockStatusCommitted, azure.BlockStatusCommitted}},                                 // write to last char of first block
 		{1, 6, "BBBCCC", []azure.BlockStatus{azure.BlockStatusUncommitted, azure.BlockStatusCommitted}},                                 // overwrite splits first and second block, last block remains
 		{3, 8, "CC", []azure.BlockStatus{azure.BlockStatusUncommitted}},                                                                 // overwrite a block in middle block, split end block
 		{10, 1, "CC", []azure.BlockStatus{azure.BlockStatusUncommitted}},                                                                // overwrite first byte of rightmost block
-		{11, 2, "", []azure.BlockStatus{}},                                                                                              // overwrite the rightmost index
-		{13, 20, "", []azure.BlockStatus{}},                                                                                             // append to the end
+		{11, 2, "", []azure.BlockStatus{}},  // overwrite the rightmost index
+		{13, 20, "", []azure.BlockStatus{}}, // append to the end
 	}
 
 	for _, c := range cases {
 		s := NewStorageSimulator()
-		rw := newRandomBlobWriter(&s, 5)
+		rw := newRandomBlobWriter(&s, 5, 1)
 		rand := newBlockIDGenerator()
 
 		if err := rw.bs.CreateBlockBlob("a", "b"); err != nil {
@@ -184,8 +215,8 @@ func TestRandomWriter_blocksRightSide(t *testing.T) {
 func TestRandomWriter_Write_NewBlob(t *testing.T) {
 	var (
 		s    = NewStorageSimulator()
-		rw   = newRandomBlobWriter(&s, 1024*3) // 3 KB blocks
-		blob = randomContents(1024 * 7)        // 7 KB blob
+		rw   = newRandomBlobWriter(&s, 1024*3, 1) // 3 KB blocks
+		blob = randomContents(1024 * 7)           // 7 KB blob
 	)
 	if err := rw.bs.CreateBlockBlob("a", "b"); err != nil {
 		t.Fatal(err)
@@ -7,7 +7,7 @@ import (
 
 func Test_zeroFillWrite_AppendNoGap(t *testing.T) {
 	s := NewStorageSimulator()
-	bw := newRandomBlobWriter(&s, 1024*1)
+	bw := newRandomBlobWriter(&s, 1024*1, 1)
 	zw := newZeroFillWriter(&bw)
 	if err := s.CreateBlockBlob("a", "b"); err != nil {
 		t.Fatal(err)
@@ -41,7 +41,7 @@ func Test_zeroFillWrite_AppendNoGap(t *testing.T) {
 
 func Test_zeroFillWrite_StartWithGap(t *testing.T) {
 	s := NewStorageSimulator()
-	bw := newRandomBlobWriter(&s, 1024*2)
+	bw := newRandomBlobWriter(&s, 1024*2, 1)
 	zw := newZeroFillWriter(&bw)
 	if err := s.CreateBlockBlob("a", "b"); err != nil {
 		t.Fatal(err)
@@ -63,7 +63,7 @@ func Test_zeroFillWrite_StartWithGap(t *testing.T) {
 
 func Test_zeroFillWrite_AppendWithGap(t *testing.T) {
 	s := NewStorageSimulator()
-	bw := newRandomBlobWriter(&s, 1024*2)
+	bw := newRandomBlobWriter(&s, 1024*2, 1)
 	zw := newZeroFillWriter(&bw)
 	if err := s.CreateBlockBlob("a", "b"); err != nil {
 		t.Fatal(err)
@@ -95,7 +95,7 @@ func Test_zeroFillWrite_AppendWithGap(t *testing.T) {
 
 func Test_zeroFillWrite_LiesWithinSize(t *testing.T) {
 	s := NewStorageSimulator()
-	bw := newRandomBlobWriter(&s, 1024*2)
+	bw := newRandomBlobWriter(&s, 1024*2, 1)
 	zw := newZeroFillWriter(&bw)
 	if err := s.CreateBlockBlob("a", "b"); err != nil {
 		t.Fatal(err)
@@ -0,0 +1,262 @@
+package base
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// Limits bounds the number of concurrent calls to a storage driver by kind
+// of operation. A limit of zero leaves the operations of its kind unbounded.
+type Limits struct {
+	// Reads bounds the concurrent GetContent, ReadStream, Stat and List
+	// calls. ReadStream only holds its slot until the stream is opened.
+	Reads int
+
+	// Writes bounds the concurrent PutContent, WriteStream, Move and Delete
+	// calls.
+	Writes int
+}
+
+// LimitsFromParameters reads the limits from the maxreads and maxwrites
+// driver parameters, which are unbounded when absent.
+func LimitsFromParameters(parameters map[string]interface{}) (Limits, error) {
+	limit := func(name string) (int, error) {
+		param, ok := parameters[name]
+		if !ok {
+			return 0, nil
+		}
+
+		n, err := strconv.Atoi(fmt.Sprint(param))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("The %s parameter should be a non-negative integer, %v invalid", name, param)
+		}
+		return n, nil
+	}
+
+	var (
+		limits Limits
+		err    error
+	)
+	if limits.Reads, err = limit("maxreads"); err != nil {
+		return Limits{}, err
+	}
+	if limits.Writes, err = limit("maxwrites"); err != nil {
+		return Limits{}, err
+	}
+	return limits, nil
+}
+
+// regulator limits the concurrency of the calls to a storage driver, so that
+// bursts of requests queue in the registry instead of exhausting the
+// connections or threads of the backend.
+type regulator struct {
+	storagedriver.StorageDriver
+	reads  semaphore
+	writes semaphore
+}
+
+// NewRegulator returns a driver which calls driver with at most the limited
+// number of concurrent calls of each kind, making further calls wait. The
+// driver is returned as is if there are no limits. The regulator is a
+// storagedriver.FileOpener, which returns ErrUnsupportedMethod if driver is
+// not.
+func NewRegulator(driver storagedriver.StorageDriver, limits Limits) storagedriver.StorageDriver {
+	if limits.Reads <= 0 && limits.Writes <= 0 {
+		return driver
+	}
+
+	metrics := driverRegulatorMetrics(driver.Name())
+	r := &regulator{
+		StorageDriver: driver,
+		reads:         newSemaphore(limits.Reads, &metrics.Reads),
+		writes:        newSemaphore(limits.Writes, &metrics.Writes),
+	}
+
+	if _, ok := driver.(storagedriver.BatchStater); ok {
+		return &batchRegulator{r}
+	}
+	return r
+}
+
+func (r *regulator) GetContent(ctx context.Context, path string) ([]byte, error) {
+	defer r.reads.acquire()()
+	return r.StorageDriver.GetContent(ctx, path)
+}
+
+func (r *regulator) PutContent(ctx context.Context, path string, content []byte) error {
+	defer r.writes.acquire()()
+	return r.StorageDriver.PutContent(ctx, path, content)
+}
+
+func (r *regulator) ReadStream(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	defer r.reads.acquire()()
+	return r.StorageDriver.ReadStream(ctx, path, offset)
+}
+
+func (r *regulator) WriteStream(ctx context.Context, path string, offset int64, reader io.Reader) (int64, error) {
+	defer r.writes.acquire()()
+	return r.StorageDriver.WriteStream(ctx, path, offset, reader)
+}
+
+func (r *regulator) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	defer r.reads.acquire()()
+	return r.StorageDriver.Stat(ctx, path)
+}
+
+func (r *regulator) List(ctx context.Context, path string) ([]string, error) {
+	defer r.reads.acquire()()
+	return r.StorageDriver.List(ctx, path)
+}
+
+func (r *regulator) Move(ctx context.Context, sourcePath string, destPath string) error {
+	defer r.writes.acquire()()
+	return r.StorageDriver.Move(ctx, sourcePath, destPath)
+}
+
+func (r *regulator) Delete(ctx context.Context, path string) error {
+	defer r.writes.acquire()()
+	return r.StorageDriver.Delete(ctx, path)
+}
+
+// OpenFile holds a read slot until the file is open, like ReadStream.
+func (r *regulator) OpenFile(ctx context.Context, path string) (*os.File, error) {
+	opener, ok := r.StorageDriver.(storagedriver.FileOpener)
+	if !ok {
+		return nil, storagedriver.ErrUnsupportedMethod
+	}
+
+	defer r.reads.acquire()()
+	return opener.OpenFile(ctx, path)
+}
+
+// batchRegulator keeps the batched stats of a driver available, each batch
+// holding a single read slot. Other drivers fall back to parallel Stat calls
+// through the regulator.
+type batchRegulator struct {
+	*regulator
+}
+
+func (r *batchRegulator) StatMany(ctx context.Context, paths []string) ([]storagedriver.FileInfo, []error) {
+	defer r.reads.acquire()()
+	return r.StorageDriver.(storagedriver.BatchStater).StatMany(ctx, paths)
+}
+
+// semaphore bounds the concurrent calls of a kind, recording how long they
+// wait for a slot. A nil slots channel leaves the calls unbounded.
+type semaphore struct {
+	slots   chan struct{}
+	metrics *regulatorOperationMetrics
+}
+
+func newSemaphore(limit int, metrics *regulatorOperationMetrics) semaphore {
+	s := semaphore{metrics: metrics}
+	if limit > 0 {
+		s.slots = make(chan struct{}, limit)
+	}
+	return s
+}
+
+// acquire waits for a slot and returns the function releasing it.
+func (s semaphore) acquire() func() {
+	atomic.AddUint64(&s.metrics.Calls, 1)
+	if s.slots == nil {
+		return func() {}
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		atomic.AddUint64(&s.metrics.Waits, 1)
+		atomic.AddInt64(&s.metrics.Waiting, 1)
+		started := time.Now()
+
+		s.slots <- struct{}{}
+
+		atomic.AddInt64(&s.metrics.Waiting, -1)
+		atomic.AddInt64(&s.metrics.WaitTime, int64(time.Since(started)))
+	}
+
+	return func() { <-s.slots }
+}
+
+// regulatorOperationMetrics counts the calls of a kind of operation.
+type regulatorOperationMetrics struct {
+	Calls    uint64 // calls made
+	Waits    uint64 // calls which had to wait for a slot
+	Waiting  int64  // calls currently waiting for a slot
+	WaitTime int64  // total time spent waiting, in nanoseconds
+}
+
+// load returns the counters, each loaded atomically.
+func (m *regulatorOperationMetrics) load() regulatorOperationMetrics {
+	return regulatorOperationMetrics{
+		Calls:    atomic.LoadUint64(&m.Calls),
+		Waits:    atomic.LoadUint64(&m.Waits),
+		Waiting:  atomic.LoadInt64(&m.Waiting),
+		WaitTime: atomic.LoadInt64(&m.WaitTime),
+	}
+}
+
+// regulatorMetrics counts the calls of the regulated drivers of a name.
+type regulatorMetrics struct {
+	Reads  regulatorOperationMetrics
+	Writes regulatorOperationMetrics
+}
+
+var (
+	regulatorMetricsMu sync.Mutex
+
+	// regulatorMetricsByDriver keeps track of the calls of the regulated
+	// drivers by driver name. It is kept globally and made available via
+	// expvar.
+	regulatorMetricsByDriver = make(map[string]*regulatorMetrics)
+)
+
+// driverRegulatorMetrics returns the metrics of the drivers named name.
+func driverRegulatorMetrics(name string) *regulatorMetrics {
+	regulatorMetricsMu.Lock()
+	defer regulatorMetricsMu.Unlock()
+
+	metrics, ok := regulatorMetricsByDriver[name]
+	if !ok {
+		metrics = &regulatorMetrics{}
+		regulatorMetricsByDriver[name] = metrics
+	}
+	return metrics
+}
+
+func init() {
+	registry := expvar.Get("registry")
+	if registry == nil {
+		registry = expvar.NewMap("registry")
+	}
+
+	storage := registry.(*expvar.Map).Get("storage")
+	if storage == nil {
+		storage = &expvar.Map{}
+		storage.(*expvar.Map).Init()
+		registry.(*expvar.Map).Set("storage", storage)
+	}
+
+	storage.(*expvar.Map).Set("regulator", expvar.Func(func() interface{} {
+		regulatorMetricsMu.Lock()
+		defer regulatorMetricsMu.Unlock()
+
+		// The counters are updated atomically, and need not be consistent
+		// with each other when reported.
+		metrics := make(map[string]regulatorMetrics, len(regulatorMetricsByDriver))
+		for name, m := range regulatorMetricsByDriver {
+			metrics[name] = regulatorMetrics{Reads: m.Reads.load(), Writes: m.Writes.load()}
+		}
+		return metrics
+	}))
+}
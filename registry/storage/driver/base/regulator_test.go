@@ -0,0 +1,152 @@
+package base
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// slowDriver is a storage driver whose Stat calls block until released,
+// recording the maximum number of concurrent calls.
+type slowDriver struct {
+	storagedriver.StorageDriver
+	release chan struct{}
+
+	mu         sync.Mutex
+	concurrent int
+	max        int
+}
+
+func (d *slowDriver) Name() string {
+	return "slow"
+}
+
+func (d *slowDriver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	d.mu.Lock()
+	d.concurrent++
+	if d.concurrent > d.max {
+		d.max = d.concurrent
+	}
+	d.mu.Unlock()
+
+	<-d.release
+
+	d.mu.Lock()
+	d.concurrent--
+	d.mu.Unlock()
+	return nil, nil
+}
+
+func TestRegulator(t *testing.T) {
+	driver := &slowDriver{release: make(chan struct{})}
+	regulated := NewRegulator(driver, Limits{Reads: 2})
+	metrics := driverRegulatorMetrics("slow")
+	waits := atomic.LoadUint64(&metrics.Reads.Waits)
+
+	const calls = 5
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			regulated.Stat(context.Background(), "/a")
+		}()
+	}
+
+	// Wait for the calls beyond the limit to queue.
+	for atomic.LoadInt64(&metrics.Reads.Waiting) < calls-2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < calls; i++ {
+		driver.release <- struct{}{}
+	}
+	wg.Wait()
+
+	if driver.max != 2 {
+		t.Fatalf("unexpected maximum concurrent calls: %d != 2", driver.max)
+	}
+
+	if n := atomic.LoadUint64(&metrics.Reads.Waits) - waits; n != calls-2 {
+		t.Fatalf("unexpected waiting calls: %d != %d", n, calls-2)
+	}
+
+	storage := expvar.Get("registry").(*expvar.Map).Get("storage").(*expvar.Map)
+	var reported map[string]regulatorMetrics
+	if err := json.Unmarshal([]byte(storage.Get("regulator").String()), &reported); err != nil {
+		t.Fatalf("unexpected error decoding the metrics: %v", err)
+	}
+	if reported["slow"].Reads.Waits != atomic.LoadUint64(&metrics.Reads.Waits) {
+		t.Fatalf("unexpected metrics reported: %v", reported)
+	}
+
+	if NewRegulator(driver, Limits{}) != storagedriver.StorageDriver(driver) {
+		t.Fatalf("expected the driver to be returned as is without limits")
+	}
+}
+
+// TestRegulatorStatMany checks that the parallel Stat calls of StatMany are
+// regulated for drivers which do not batch them.
+func TestRegulatorStatMany(t *testing.T) {
+	driver := &slowDriver{release: make(chan struct{})}
+	regulated := NewRegulator(driver, Limits{Reads: 2})
+	if _, ok := regulated.(storagedriver.BatchStater); ok {
+		t.Fatalf("unexpected batched stats of a driver without them")
+	}
+
+	paths := []string{"/a", "/b", "/c", "/d", "/e"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, errs := storagedriver.StatMany(context.Background(), regulated, paths)
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("unexpected error stating %s: %v", paths[i], err)
+			}
+		}
+	}()
+
+	for range paths {
+		driver.release <- struct{}{}
+	}
+	<-done
+
+	if driver.max > 2 {
+		t.Fatalf("unexpected maximum concurrent calls: %d > 2", driver.max)
+	}
+}
+
+func TestLimitsFromParameters(t *testing.T) {
+	for _, testcase := range []struct {
+		parameters map[string]interface{}
+		limits     Limits
+		err        bool
+	}{
+		{parameters: nil},
+		{parameters: map[string]interface{}{"maxreads": 10}, limits: Limits{Reads: 10}},
+		{parameters: map[string]interface{}{"maxreads": "10", "maxwrites": 5}, limits: Limits{Reads: 10, Writes: 5}},
+		{parameters: map[string]interface{}{"maxwrites": "many"}, err: true},
+		{parameters: map[string]interface{}{"maxreads": -1}, err: true},
+	} {
+		limits, err := LimitsFromParameters(testcase.parameters)
+		if testcase.err {
+			if err == nil {
+				t.Fatalf("expected an error with parameters %v", testcase.parameters)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error with parameters %v: %v", testcase.parameters, err)
+		}
+		if limits != testcase.limits {
+			t.Fatalf("unexpected limits with parameters %v: %+v != %+v", testcase.parameters, limits, testcase.limits)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/docker/distribution/context"
@@ -26,11 +27,26 @@ func init() {
 type filesystemDriverFactory struct{}
 
 func (factory *filesystemDriverFactory) Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
-	return FromParameters(parameters), nil
+	return FromParameters(parameters)
+}
+
+// DriverParameters represents all configuration options available for the
+// filesystem driver
+type DriverParameters struct {
+	RootDirectory string
+
+	// DropBehindThreshold is the file size, in bytes, from which streamed
+	// reads and writes advise the kernel to drop the file from the page
+	// cache. Zero disables the advice.
+	DropBehindThreshold int64
+
+	// Limits bounds the concurrent calls to the filesystem.
+	Limits base.Limits
 }
 
 type driver struct {
-	rootDirectory string
+	rootDirectory       string
+	dropBehindThreshold int64
 }
 
 type baseEmbed struct {
@@ -46,30 +62,87 @@ type Driver struct {
 // FromParameters constructs a new Driver with a given parameters map
 // Optional Parameters:
 // - rootdirectory
-func FromParameters(parameters map[string]interface{}) *Driver {
-	var rootDirectory = defaultRootDirectory
+// - dropbehindthreshold
+// - maxreads
+// - maxwrites
+func FromParameters(parameters map[string]interface{}) (*Driver, error) {
+	params := DriverParameters{
+		RootDirectory: defaultRootDirectory,
+	}
 	if parameters != nil {
+		var err error
+		params.Limits, err = base.LimitsFromParameters(parameters)
+		if err != nil {
+			return nil, err
+		}
+
 		rootDir, ok := parameters["rootdirectory"]
 		if ok {
-			rootDirectory = fmt.Sprint(rootDir)
+			params.RootDirectory = fmt.Sprint(rootDir)
+		}
+
+		threshold, ok := parameters["dropbehindthreshold"]
+		if ok {
+			params.DropBehindThreshold, err = strconv.ParseInt(fmt.Sprint(threshold), 0, 64)
+			if err != nil || params.DropBehindThreshold < 0 {
+				return nil, fmt.Errorf("The dropbehindthreshold parameter should be a non-negative integer, %v invalid", threshold)
+			}
 		}
 	}
-	return New(rootDirectory)
+	return New(params), nil
 }
 
-// New constructs a new Driver with a given rootDirectory
-func New(rootDirectory string) *Driver {
+// New constructs a new Driver with the given parameters
+func New(params DriverParameters) *Driver {
 	return &Driver{
 		baseEmbed: baseEmbed{
 			Base: base.Base{
-				StorageDriver: &driver{
-					rootDirectory: rootDirectory,
-				},
+				StorageDriver: base.NewRegulator(&driver{
+					rootDirectory:       params.RootDirectory,
+					dropBehindThreshold: params.DropBehindThreshold,
+				}, params.Limits),
 			},
 		},
 	}
 }
 
+var _ storagedriver.FileOpener = &Driver{}
+
+// OpenFile opens the file storing the content at path, so that it can be
+// served with sendfile. ErrUnsupportedMethod is returned for the files which
+// are dropped from the page cache as they are read, as set by
+// DropBehindThreshold, which must be read with ReadStream.
+func (d *Driver) OpenFile(ctx context.Context, path string) (*os.File, error) {
+	if !storagedriver.PathRegexp.MatchString(path) {
+		return nil, storagedriver.InvalidPathError{Path: path}
+	}
+
+	return d.StorageDriver.(storagedriver.FileOpener).OpenFile(ctx, path)
+}
+
+// OpenFile is called through the regulator of the Driver, if any, once the
+// path has been checked.
+func (d *driver) OpenFile(ctx context.Context, path string) (*os.File, error) {
+	file, err := os.Open(d.fullPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storagedriver.PathNotFoundError{Path: path}
+		}
+
+		return nil, err
+	}
+
+	if d.dropBehindThreshold > 0 {
+		fi, err := file.Stat()
+		if err != nil || fi.Size() >= d.dropBehindThreshold {
+			file.Close()
+			return nil, storagedriver.ErrUnsupportedMethod
+		}
+	}
+
+	return file, nil
+}
+
 // Implement the storagedriver.StorageDriver interface
 
 func (d *driver) Name() string {
@@ -122,6 +195,12 @@ func (d *driver) ReadStream(ctx context.Context, path string, offset int64) (io.
 		return nil, storagedriver.InvalidOffsetError{Path: path, Offset: offset}
 	}
 
+	if d.dropBehindThreshold > 0 {
+		if fi, err := file.Stat(); err == nil && fi.Size() >= d.dropBehindThreshold {
+			return newDropBehindReader(file, offset), nil
+		}
+	}
+
 	return file, nil
 }
 
@@ -158,7 +237,20 @@ func (d *driver) WriteStream(ctx context.Context, subPath string, offset int64,
 		return 0, fmt.Errorf("bad seek to %v, expected %v in fp=%v", offset, nn, fp)
 	}
 
-	return io.Copy(fp, reader)
+	nn, err = io.Copy(fp, reader)
+	if err != nil {
+		return nn, err
+	}
+
+	if d.dropBehindThreshold > 0 && offset+nn >= d.dropBehindThreshold {
+		// Dirty pages can only be dropped once they have been written back.
+		if err := fp.Sync(); err != nil {
+			return nn, err
+		}
+		fadvise(fp, 0, 0, fadvDontNeed)
+	}
+
+	return nn, nil
 }
 
 // Stat retrieves the FileInfo for the given path, including the current size
@@ -1,10 +1,12 @@
 package filesystem
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"testing"
 
+	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
 	. "gopkg.in/check.v1"
@@ -21,9 +23,74 @@ func init() {
 	defer os.Remove(root)
 
 	testsuites.RegisterInProcessSuite(func() (storagedriver.StorageDriver, error) {
-		return New(root), nil
+		return New(DriverParameters{RootDirectory: root}), nil
 	}, testsuites.NeverSkip)
 
 	// BUG(stevvooe): IPC is broken so we're disabling for now. Will revisit later.
 	// testsuites.RegisterIPCSuite(driverName, map[string]string{"rootdirectory": root}, testsuites.NeverSkip)
 }
+
+func TestDropBehind(t *testing.T) {
+	root, err := ioutil.TempDir("", "driver-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789abcdef"), dropBehindInterval/8)
+
+	d := New(DriverParameters{RootDirectory: root, DropBehindThreshold: 1})
+	if nn, err := d.WriteStream(ctx, "/large", 0, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error writing stream: %v", err)
+	} else if nn != int64(len(content)) {
+		t.Fatalf("unexpected number of bytes written: %d != %d", nn, len(content))
+	}
+
+	rc, err := d.ReadStream(ctx, "/large", 5)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+
+	if _, ok := rc.(*dropBehindReader); !ok {
+		t.Fatalf("expected a dropBehindReader, got %T", rc)
+	}
+
+	p, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("unexpected error reading content: %v", err)
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatalf("unexpected error closing reader: %v", err)
+	}
+
+	if !bytes.Equal(p, content[5:]) {
+		t.Fatalf("unexpected content read back")
+	}
+}
+
+func TestFromParameters(t *testing.T) {
+	for _, testcase := range []struct {
+		parameters map[string]interface{}
+		valid      bool
+	}{
+		{parameters: nil, valid: true},
+		{parameters: map[string]interface{}{"dropbehindthreshold": 1048576}, valid: true},
+		{parameters: map[string]interface{}{"dropbehindthreshold": "1MB"}},
+		{parameters: map[string]interface{}{"dropbehindthreshold": -1}},
+		{parameters: map[string]interface{}{"maxwrites": "many"}},
+	} {
+		_, err := FromParameters(testcase.parameters)
+		if !testcase.valid {
+			if err == nil {
+				t.Fatalf("expected error with parameters %v", testcase.parameters)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error with parameters %v: %v", testcase.parameters, err)
+		}
+	}
+}
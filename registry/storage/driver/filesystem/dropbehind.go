@@ -0,0 +1,52 @@
+package filesystem
+
+import (
+	"io"
+	"os"
+)
+
+// Advice values for fadvise, matching POSIX_FADV_* on linux.
+const (
+	fadvSequential = 2
+	fadvDontNeed   = 4
+)
+
+// dropBehindInterval is the number of bytes read between requests to drop
+// already read pages from the page cache.
+const dropBehindInterval = 8 << 20
+
+// dropBehindReader advises the kernel to drop the pages of a large file from
+// the page cache as it is read, so that streaming it does not evict hot data.
+type dropBehindReader struct {
+	file    *os.File
+	offset  int64 // offset of the next read
+	dropped int64 // offset up to which pages have been dropped
+}
+
+var _ io.ReadCloser = &dropBehindReader{}
+
+func newDropBehindReader(file *os.File, offset int64) *dropBehindReader {
+	fadvise(file, offset, 0, fadvSequential)
+	return &dropBehindReader{
+		file:    file,
+		offset:  offset,
+		dropped: offset,
+	}
+}
+
+func (r *dropBehindReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	r.offset += int64(n)
+
+	if r.offset-r.dropped >= dropBehindInterval {
+		fadvise(r.file, r.dropped, r.offset-r.dropped, fadvDontNeed)
+		r.dropped = r.offset
+	}
+
+	return n, err
+}
+
+func (r *dropBehindReader) Close() error {
+	fadvise(r.file, 0, 0, fadvDontNeed)
+	return r.file.Close()
+}
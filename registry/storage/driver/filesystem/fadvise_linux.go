@@ -0,0 +1,14 @@
+// +build linux,amd64 linux,arm64
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+// fadvise declares the expected access pattern for the given region of f to
+// the kernel. Errors are ignored, since the advice is only a hint.
+func fadvise(f *os.File, offset, length int64, advice int) {
+	syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), uintptr(offset), uintptr(length), uintptr(advice), 0, 0)
+}
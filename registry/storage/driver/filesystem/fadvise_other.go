@@ -0,0 +1,8 @@
+// +build !linux !amd64,!arm64
+
+package filesystem
+
+import "os"
+
+// fadvise is a no-op on platforms without posix_fadvise support.
+func fadvise(f *os.File, offset, length int64, advice int) {}
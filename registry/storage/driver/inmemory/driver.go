@@ -24,7 +24,7 @@ func init() {
 type inMemoryDriverFactory struct{}
 
 func (factory *inMemoryDriverFactory) Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
-	return New(), nil
+	return FromParameters(parameters)
 }
 
 type driver struct {
@@ -44,6 +44,23 @@ type Driver struct {
 }
 
 var _ storagedriver.StorageDriver = &Driver{}
+var _ storagedriver.BatchStater = &Driver{}
+
+// FromParameters constructs a new Driver with a given parameters map
+// Optional Parameters:
+// - snapshot: a tar archive or directory to load the initial contents from
+func FromParameters(parameters map[string]interface{}) (*Driver, error) {
+	d := New()
+
+	snapshot, ok := parameters["snapshot"]
+	if ok && fmt.Sprint(snapshot) != "" {
+		if err := d.LoadSnapshot(fmt.Sprint(snapshot)); err != nil {
+			return nil, fmt.Errorf("unable to load snapshot %v: %v", snapshot, err)
+		}
+	}
+
+	return d, nil
+}
 
 // New constructs a new Driver.
 func New() *Driver {
@@ -63,6 +80,31 @@ func New() *Driver {
 	}
 }
 
+// StatMany returns info about each of the provided paths, holding the lock
+// once for all of them.
+func (d *Driver) StatMany(ctx context.Context, paths []string) ([]storagedriver.FileInfo, []error) {
+	return d.StorageDriver.(*driver).statMany(paths)
+}
+
+func (d *driver) statMany(paths []string) ([]storagedriver.FileInfo, []error) {
+	fis := make([]storagedriver.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for i, path := range paths {
+		if !storagedriver.PathRegexp.MatchString(path) {
+			errs[i] = storagedriver.InvalidPathError{Path: path}
+			continue
+		}
+
+		fis[i], errs[i] = d.stat(path)
+	}
+
+	return fis, errs
+}
+
 // Implement the storagedriver.StorageDriver interface.
 
 func (d *driver) Name() string {
@@ -172,6 +214,10 @@ func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo,
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
+	return d.stat(path)
+}
+
+func (d *driver) stat(path string) (storagedriver.FileInfo, error) {
 	normalized := normalize(path)
 	found := d.root.find(path)
 
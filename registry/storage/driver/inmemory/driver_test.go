@@ -1,8 +1,12 @@
 package inmemory
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
 
@@ -22,3 +26,69 @@ func init() {
 	// the problems with libchan.
 	// testsuites.RegisterIPCSuite(driverName, nil, testsuites.NeverSkip)
 }
+
+// TestConformanceWithFaults runs the stream tests of the storage driver test
+// suite with short reads injected in the streams of the driver. The other
+// tests do not read streams, and the large stream tests would hold several
+// copies of their content in memory.
+func TestConformanceWithFaults(t *testing.T) {
+	testsuites.RunFiltered(t, "TestWriteReadStreams|TestReadStreamWithOffset|TestContinueStreamAppendSmall|TestReadNonexistentStream", func() (storagedriver.StorageDriver, error) {
+		return New(), nil
+	}, testsuites.NeverSkip, testsuites.Faults{ShortReadRate: 0.5})
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	contents := map[string]string{
+		"/a":       "first",
+		"/b/c/d":   "second",
+		"/b/empty": "",
+	}
+
+	d := New()
+	for p, content := range contents {
+		if err := d.PutContent(ctx, p, []byte(content)); err != nil {
+			t.Fatalf("unexpected error putting content: %v", err)
+		}
+	}
+
+	checkContents := func(d *Driver) {
+		for p, content := range contents {
+			actual, err := d.GetContent(ctx, p)
+			if err != nil {
+				t.Fatalf("unexpected error getting %q: %v", p, err)
+			}
+
+			if string(actual) != content {
+				t.Fatalf("unexpected content at %q: %q != %q", p, actual, content)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := d.Save(&buf); err != nil {
+		t.Fatalf("unexpected error saving snapshot: %v", err)
+	}
+
+	fromTar := New()
+	if err := fromTar.Load(&buf); err != nil {
+		t.Fatalf("unexpected error loading snapshot: %v", err)
+	}
+	checkContents(fromTar)
+
+	root, err := ioutil.TempDir("", "inmemory-snapshot-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := d.SaveDirectory(root); err != nil {
+		t.Fatalf("unexpected error saving snapshot directory: %v", err)
+	}
+
+	fromDir, err := FromParameters(map[string]interface{}{"snapshot": root})
+	if err != nil {
+		t.Fatalf("unexpected error creating driver from snapshot directory: %v", err)
+	}
+	checkContents(fromDir)
+}
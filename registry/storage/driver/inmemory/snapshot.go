@@ -0,0 +1,181 @@
+package inmemory
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// Save writes the contents of the driver to w as a tar archive. Each file is
+// stored under its driver path, without the leading slash. The archive may
+// be restored into a driver with Load.
+func (d *Driver) Save(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	if err := d.StorageDriver.(*driver).walkFiles(func(f *file) error {
+		hdr := &tar.Header{
+			Name:     strings.TrimPrefix(f.path(), "/"),
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  f.modtime(),
+			Typeflag: tar.TypeReg,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		_, err := tw.Write(f.data)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Load adds the regular files of the tar archive read from r to the driver,
+// replacing any existing files at the same paths. Other entries, such as
+// directories, are ignored.
+func (d *Driver) Load(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		p, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if err := d.StorageDriver.(*driver).load(path.Join("/", hdr.Name), p); err != nil {
+			return err
+		}
+	}
+}
+
+// SaveDirectory writes the contents of the driver as files under the local
+// directory root, which is created if necessary.
+func (d *Driver) SaveDirectory(root string) error {
+	return d.StorageDriver.(*driver).walkFiles(func(f *file) error {
+		fullPath := filepath.Join(root, filepath.FromSlash(f.path()))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(fullPath, f.data, 0644)
+	})
+}
+
+// LoadDirectory adds the files under the local directory root to the driver,
+// replacing any existing files at the same paths.
+func (d *Driver) LoadDirectory(root string) error {
+	return filepath.Walk(root, func(fullPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, fullPath)
+		if err != nil {
+			return err
+		}
+
+		p, err := ioutil.ReadFile(fullPath)
+		if err != nil {
+			return err
+		}
+
+		return d.StorageDriver.(*driver).load("/"+filepath.ToSlash(rel), p)
+	})
+}
+
+// LoadSnapshot loads the tar archive or directory at the local path p into
+// the driver.
+func (d *Driver) LoadSnapshot(p string) error {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		return d.LoadDirectory(p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.Load(f)
+}
+
+// load stores the contents p at path, checking that the path is valid.
+func (d *driver) load(p string, contents []byte) error {
+	if !storagedriver.PathRegexp.MatchString(p) {
+		return storagedriver.InvalidPathError{Path: p}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, err := d.root.mkfile(p)
+	if err != nil {
+		return fmt.Errorf("unable to load %q: %v", p, err)
+	}
+
+	f.truncate()
+	f.WriteAt(contents, 0)
+	return nil
+}
+
+// walkFiles calls fn for every file in the driver, in path order, while
+// holding the read lock.
+func (d *driver) walkFiles(fn func(f *file) error) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return walkDir(d.root, fn)
+}
+
+func walkDir(dd *dir, fn func(f *file) error) error {
+	names := make([]string, 0, len(dd.children))
+	for name := range dd.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch n := dd.children[name].(type) {
+		case *dir:
+			if err := walkDir(n, fn); err != nil {
+				return err
+			}
+		case *file:
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
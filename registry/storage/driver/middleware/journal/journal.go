@@ -0,0 +1,282 @@
+// Package journal provides a storage middleware which records the mutations
+// of registry metadata, such as tags, manifest revisions, layer links, the
+// repository index and robot accounts, in an append-only journal file. Blobs
+// are content addressed and never rewritten, so replaying the journal over a
+// copy of the blob store reconstructs the metadata of the registry at any
+// point in time.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// The operations recorded in the journal.
+const (
+	// OpPut records the content written to a path.
+	OpPut = "put"
+
+	// OpDelete records the recursive deletion of a path.
+	OpDelete = "delete"
+
+	// OpMove records the move of a path to Dest.
+	OpMove = "move"
+
+	// OpAbort records that the mutation of the entry with the same ID
+	// failed, and must not be replayed.
+	OpAbort = "abort"
+)
+
+// Entry is a mutation recorded in the journal. Entries are written before
+// the mutation is applied, so that no mutation is missing from the journal,
+// and followed by an abort entry if it fails.
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Path    string    `json:"path,omitempty"`
+	Dest    string    `json:"dest,omitempty"`
+	Content []byte    `json:"content,omitempty"`
+}
+
+// journalMiddleware records the metadata mutations made through the wrapped
+// driver in the journal file.
+type journalMiddleware struct {
+	storagedriver.StorageDriver
+
+	mu   sync.Mutex
+	file *os.File
+	sync bool
+}
+
+var _ storagedriver.StorageDriver = &journalMiddleware{}
+
+// newJournalMiddleware constructs a journal middleware appending to the file
+// given by the "path" option. If the "sync" option is set, the file is synced
+// after each entry.
+func newJournalMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	path, ok := options["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("No path provided")
+	}
+
+	syncEntries := false
+	if s, ok := options["sync"]; ok {
+		syncEntries, ok = s.(bool)
+		if !ok {
+			return nil, fmt.Errorf("sync must be a boolean")
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journalMiddleware{
+		StorageDriver: storageDriver,
+		file:          file,
+		sync:          syncEntries,
+	}, nil
+}
+
+// metadataDirs are the directories of the storage layout holding registry
+// metadata: the repositories, the repository index and the robot accounts.
+var metadataDirs = []string{"/v2/repositories/", "/v2/index/", "/v2/robots/"}
+
+// journaled returns true if the path holds registry metadata. Uploads in
+// progress are not metadata, and the blobs they become are not rewritten.
+func journaled(path string) bool {
+	if strings.Contains(path, "/_uploads/") {
+		return false
+	}
+
+	for _, dir := range metadataDirs {
+		if strings.Contains(path+"/", dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// append writes the entry to the journal, with a new ID unless it has one.
+func (jm *journalMiddleware) append(entry Entry) (string, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.New()
+	}
+	entry.Time = time.Now().UTC()
+
+	p, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if _, err := jm.file.Write(append(p, '\n')); err != nil {
+		return "", err
+	}
+
+	if jm.sync {
+		if err := jm.file.Sync(); err != nil {
+			return "", err
+		}
+	}
+
+	return entry.ID, nil
+}
+
+// mutate records the entry and applies the mutation, which is not applied if
+// the entry cannot be recorded.
+func (jm *journalMiddleware) mutate(ctx context.Context, entry Entry, mutation func() error) error {
+	id, err := jm.append(entry)
+	if err != nil {
+		return fmt.Errorf("journal: error recording %s of %s: %v", entry.Op, entry.Path, err)
+	}
+
+	if err := mutation(); err != nil {
+		if _, abortErr := jm.append(Entry{ID: id, Op: OpAbort}); abortErr != nil {
+			context.GetLogger(ctx).Errorf("journal: error recording abort of %s of %s: %v", entry.Op, entry.Path, abortErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PutContent records content written to metadata paths.
+func (jm *journalMiddleware) PutContent(ctx context.Context, path string, content []byte) error {
+	if !journaled(path) {
+		return jm.StorageDriver.PutContent(ctx, path, content)
+	}
+
+	return jm.mutate(ctx, Entry{Op: OpPut, Path: path, Content: content}, func() error {
+		return jm.StorageDriver.PutContent(ctx, path, content)
+	})
+}
+
+// Move records moves from or to metadata paths.
+func (jm *journalMiddleware) Move(ctx context.Context, sourcePath string, destPath string) error {
+	if !journaled(sourcePath) && !journaled(destPath) {
+		return jm.StorageDriver.Move(ctx, sourcePath, destPath)
+	}
+
+	return jm.mutate(ctx, Entry{Op: OpMove, Path: sourcePath, Dest: destPath}, func() error {
+		return jm.StorageDriver.Move(ctx, sourcePath, destPath)
+	})
+}
+
+// Delete records deletions of metadata paths, including whole repositories.
+func (jm *journalMiddleware) Delete(ctx context.Context, path string) error {
+	if !journaled(path) {
+		return jm.StorageDriver.Delete(ctx, path)
+	}
+
+	return jm.mutate(ctx, Entry{Op: OpDelete, Path: path}, func() error {
+		return jm.StorageDriver.Delete(ctx, path)
+	})
+}
+
+// OpenFile keeps the optional file access of the wrapped driver available.
+func (jm *journalMiddleware) OpenFile(ctx context.Context, path string) (*os.File, error) {
+	opener, ok := jm.StorageDriver.(storagedriver.FileOpener)
+	if !ok {
+		return nil, storagedriver.ErrUnsupportedMethod
+	}
+	return opener.OpenFile(ctx, path)
+}
+
+// StatMany keeps the optional batched stats of the wrapped driver available.
+func (jm *journalMiddleware) StatMany(ctx context.Context, paths []string) ([]storagedriver.FileInfo, []error) {
+	return storagedriver.StatMany(ctx, jm.StorageDriver, paths)
+}
+
+// ReadJournal reads the entries of a journal, leaving out the aborted
+// mutations and the abort entries.
+func ReadJournal(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	aborted := make(map[string]struct{})
+
+	dec := json.NewDecoder(r)
+	for {
+		var entry Entry
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if entry.Op == OpAbort {
+			aborted[entry.ID] = struct{}{}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	applied := entries[:0]
+	for _, entry := range entries {
+		if _, ok := aborted[entry.ID]; !ok {
+			applied = append(applied, entry)
+		}
+	}
+
+	return applied, nil
+}
+
+// Replay applies the mutations recorded in the journal up to the given time,
+// or all of them if it is zero, to the driver, and returns the number of
+// mutations applied. The driver should hold the blobs referenced by the
+// metadata, and the metadata as it was when the journal was started, if any.
+// Deletions of missing paths are ignored.
+func Replay(ctx context.Context, driver storagedriver.StorageDriver, r io.Reader, until time.Time) (int, error) {
+	entries, err := ReadJournal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, entry := range entries {
+		if !until.IsZero() && entry.Time.After(until) {
+			continue
+		}
+
+		switch entry.Op {
+		case OpPut:
+			err = driver.PutContent(ctx, entry.Path, entry.Content)
+		case OpDelete:
+			err = driver.Delete(ctx, entry.Path)
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				err = nil
+			}
+		case OpMove:
+			err = driver.Move(ctx, entry.Path, entry.Dest)
+		default:
+			err = fmt.Errorf("unknown operation %q", entry.Op)
+		}
+		if err != nil {
+			return applied, fmt.Errorf("error replaying %s of %s at %s: %v", entry.Op, entry.Path, entry.Time, err)
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+// init registers the journal storage middleware.
+func init() {
+	storagemiddleware.Register("journal", storagemiddleware.InitFunc(newJournalMiddleware))
+}
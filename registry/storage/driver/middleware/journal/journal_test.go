@@ -0,0 +1,122 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestJournalReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	journalPath := filepath.Join(dir, "journal")
+	driver, err := newJournalMiddleware(inmemory.New(), map[string]interface{}{
+		"path": journalPath,
+		"sync": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating journal middleware: %v", err)
+	}
+
+	const (
+		currentPath = "/docker/registry/v2/repositories/foo/bar/_manifests/tags/latest/current/link"
+		stablePath  = "/docker/registry/v2/repositories/foo/bar/_manifests/tags/stable/current/link"
+		uploadPath  = "/docker/registry/v2/repositories/foo/bar/_uploads/uuid/startedat"
+		blobPath    = "/docker/registry/v2/blobs/sha256/ab/abcd/data"
+		indexPath   = "/docker/registry/v2/index/repositories/ab/foo__bar"
+		robotPath   = "/docker/registry/v2/robots/ci"
+	)
+
+	for _, p := range []string{currentPath, stablePath, uploadPath, blobPath, indexPath, robotPath} {
+		if err := driver.PutContent(ctx, p, []byte("sha256:abcd")); err != nil {
+			t.Fatalf("unexpected error putting %s: %v", p, err)
+		}
+	}
+
+	if err := driver.Delete(ctx, "/docker/registry/v2/repositories/foo/bar/_manifests/tags/missing"); err == nil {
+		t.Fatalf("expected an error deleting a missing path")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	checkpoint := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := driver.Delete(ctx, "/docker/registry/v2/repositories/foo/bar/_manifests/tags/stable"); err != nil {
+		t.Fatalf("unexpected error deleting tag: %v", err)
+	}
+
+	f, err := os.Open(journalPath)
+	if err != nil {
+		t.Fatalf("unexpected error opening journal: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := ReadJournal(f)
+	if err != nil {
+		t.Fatalf("unexpected error reading journal: %v", err)
+	}
+
+	// The upload and blob are not metadata, and the failed deletion is
+	// aborted.
+	if len(entries) != 5 || entries[0].Path != currentPath || entries[1].Path != stablePath ||
+		entries[2].Path != indexPath || entries[3].Path != robotPath || entries[4].Op != OpDelete {
+		t.Fatalf("unexpected journal entries: %#v", entries)
+	}
+
+	for _, testcase := range []struct {
+		until   time.Time
+		applied int
+		stable  bool
+	}{
+		{
+			until:   checkpoint,
+			applied: 4,
+			stable:  true,
+		},
+		{
+			applied: 5,
+		},
+	} {
+		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+			t.Fatalf("unexpected error seeking journal: %v", err)
+		}
+
+		restored := inmemory.New()
+		applied, err := Replay(ctx, restored, f, testcase.until)
+		if err != nil {
+			t.Fatalf("unexpected error replaying journal: %v", err)
+		}
+
+		if applied != testcase.applied {
+			t.Fatalf("unexpected number of mutations replayed until %s: %d != %d", testcase.until, applied, testcase.applied)
+		}
+
+		if content, err := restored.GetContent(ctx, currentPath); err != nil || string(content) != "sha256:abcd" {
+			t.Fatalf("unexpected replayed content: %q, %v", content, err)
+		}
+
+		_, err = restored.Stat(ctx, stablePath)
+		if _, ok := err.(storagedriver.PathNotFoundError); ok == testcase.stable {
+			t.Fatalf("unexpected replayed stable tag until %s: %v", testcase.until, err)
+		}
+
+		if _, err := restored.Stat(ctx, robotPath); err != nil {
+			t.Fatalf("unexpected error replaying robot account: %v", err)
+		}
+
+		if _, err := restored.Stat(ctx, uploadPath); err == nil {
+			t.Fatalf("expected upload not to be replayed")
+		}
+	}
+}
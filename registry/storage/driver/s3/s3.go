@@ -44,6 +44,21 @@ const minChunkSize = 5 << 20
 
 const defaultChunkSize = 2 * minChunkSize
 
+// defaultMultipartConcurrency is the default number of parts of a single
+// multipart upload which may be uploaded in parallel
+const defaultMultipartConcurrency = 1
+
+// validObjectACLs contains the canned ACLs which may be applied to objects
+// written by the driver
+var validObjectACLs = map[s3.ACL]struct{}{
+	s3.Private:           {},
+	s3.PublicRead:        {},
+	s3.PublicReadWrite:   {},
+	s3.AuthenticatedRead: {},
+	s3.BucketOwnerRead:   {},
+	s3.BucketOwnerFull:   {},
+}
+
 // listMax is the largest amount of objects you can request from S3 in a list call
 const listMax = 1000
 
@@ -56,8 +71,20 @@ type DriverParameters struct {
 	Encrypt       bool
 	Secure        bool
 	V4Auth        bool
+	Accelerate    bool
+	DualStack     bool
 	ChunkSize     int64
 	RootDirectory string
+	Tags          map[string]string
+	ObjectACL     s3.ACL
+
+	MultipartConcurrency int
+
+	ThrottleRetries         int
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
+	Limits base.Limits
 }
 
 func init() {
@@ -77,6 +104,15 @@ type driver struct {
 	ChunkSize     int64
 	Encrypt       bool
 	RootDirectory string
+	Tagging       []byte // PutObjectTagging body, nil without tags
+	ObjectACL     s3.ACL
+
+	// client sends the requests which the vendored client cannot send.
+	client *http.Client
+
+	MultipartConcurrency int
+
+	throttle *throttle
 
 	pool  sync.Pool // pool []byte buffers used for WriteStream
 	zeros []byte    // shared, zero-valued buffer used for WriteStream
@@ -90,6 +126,10 @@ type baseEmbed struct {
 // Objects are stored at absolute keys in the provided bucket.
 type Driver struct {
 	baseEmbed
+
+	// driver is the implementation, called through the regulator of the
+	// base, if any.
+	driver *driver
 }
 
 // FromParameters constructs a new Driver with a given parameters map
@@ -153,22 +193,31 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		}
 	}
 
+	accelerateBool := false
+	accelerate, ok := parameters["accelerate"]
+	if ok {
+		accelerateBool, ok = accelerate.(bool)
+		if !ok {
+			return nil, fmt.Errorf("The accelerate parameter should be a boolean")
+		}
+	}
+
+	dualStackBool := false
+	dualStack, ok := parameters["dualstack"]
+	if ok {
+		dualStackBool, ok = dualStack.(bool)
+		if !ok {
+			return nil, fmt.Errorf("The dualstack parameter should be a boolean")
+		}
+	}
+
 	chunkSize := int64(defaultChunkSize)
 	chunkSizeParam, ok := parameters["chunksize"]
 	if ok {
-		switch v := chunkSizeParam.(type) {
-		case string:
-			vv, err := strconv.ParseInt(v, 0, 64)
-			if err != nil {
-				return nil, fmt.Errorf("chunksize parameter must be an integer, %v invalid", chunkSizeParam)
-			}
-			chunkSize = vv
-		case int64:
-			chunkSize = v
-		case int, uint, int32, uint32, uint64:
-			chunkSize = reflect.ValueOf(v).Convert(reflect.TypeOf(chunkSize)).Int()
-		default:
-			return nil, fmt.Errorf("invalid valud for chunksize: %#v", chunkSizeParam)
+		var err error
+		chunkSize, err = parseIntParameter("chunksize", chunkSizeParam)
+		if err != nil {
+			return nil, err
 		}
 
 		if chunkSize < minChunkSize {
@@ -176,11 +225,109 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		}
 	}
 
+	objectACL := s3.Private
+	objectACLParam, ok := parameters["objectacl"]
+	if ok {
+		objectACLString, ok := objectACLParam.(string)
+		if !ok {
+			return nil, fmt.Errorf("The objectacl parameter should be a string")
+		}
+
+		if _, ok := validObjectACLs[s3.ACL(objectACLString)]; !ok {
+			return nil, fmt.Errorf("Invalid objectacl value: %v", objectACLString)
+		}
+		objectACL = s3.ACL(objectACLString)
+	}
+
+	// The vendored client cannot send the x-amz-request-payer header, which
+	// every request to a Requester Pays bucket needs.
+	if _, ok := parameters["requestpayer"]; ok {
+		return nil, fmt.Errorf("The requestpayer parameter is not supported: Requester Pays buckets cannot be accessed")
+	}
+
+	multipartConcurrency := int64(defaultMultipartConcurrency)
+	multipartConcurrencyParam, ok := parameters["multipartconcurrency"]
+	if ok {
+		var err error
+		multipartConcurrency, err = parseIntParameter("multipartconcurrency", multipartConcurrencyParam)
+		if err != nil {
+			return nil, err
+		}
+
+		if multipartConcurrency < 1 {
+			return nil, fmt.Errorf("The multipartconcurrency %#v parameter should be a number that is larger than or equal to 1", multipartConcurrency)
+		}
+	}
+
+	throttleRetries := int64(defaultThrottleRetries)
+	throttleRetriesParam, ok := parameters["throttleretries"]
+	if ok {
+		var err error
+		throttleRetries, err = parseIntParameter("throttleretries", throttleRetriesParam)
+		if err != nil {
+			return nil, err
+		}
+
+		if throttleRetries < 0 {
+			return nil, fmt.Errorf("The throttleretries %#v parameter should be a number that is larger than or equal to 0", throttleRetries)
+		}
+	}
+
+	circuitBreakerThreshold := int64(defaultCircuitBreakerThreshold)
+	circuitBreakerThresholdParam, ok := parameters["circuitbreakerthreshold"]
+	if ok {
+		var err error
+		circuitBreakerThreshold, err = parseIntParameter("circuitbreakerthreshold", circuitBreakerThresholdParam)
+		if err != nil {
+			return nil, err
+		}
+
+		if circuitBreakerThreshold < 0 {
+			return nil, fmt.Errorf("The circuitbreakerthreshold %#v parameter should be a number that is larger than or equal to 0", circuitBreakerThreshold)
+		}
+	}
+
+	circuitBreakerCooldown := defaultCircuitBreakerCooldown
+	circuitBreakerCooldownParam, ok := parameters["circuitbreakercooldown"]
+	if ok {
+		var err error
+		circuitBreakerCooldown, err = time.ParseDuration(fmt.Sprint(circuitBreakerCooldownParam))
+		if err != nil || circuitBreakerCooldown <= 0 {
+			return nil, fmt.Errorf("The circuitbreakercooldown parameter should be a positive duration")
+		}
+	}
+
 	rootDirectory, ok := parameters["rootdirectory"]
 	if !ok {
 		rootDirectory = ""
 	}
 
+	tags := map[string]string{}
+	tagsParam, ok := parameters["tags"]
+	if ok {
+		switch v := tagsParam.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				tags[key] = fmt.Sprint(value)
+			}
+		case map[interface{}]interface{}:
+			for key, value := range v {
+				tags[fmt.Sprint(key)] = fmt.Sprint(value)
+			}
+		case map[string]string:
+			for key, value := range v {
+				tags[key] = value
+			}
+		default:
+			return nil, fmt.Errorf("The tags parameter should be a map of tag keys to values")
+		}
+	}
+
+	limits, err := base.LimitsFromParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	params := DriverParameters{
 		fmt.Sprint(accessKey),
 		fmt.Sprint(secretKey),
@@ -189,13 +336,41 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		encryptBool,
 		secureBool,
 		v4AuthBool,
+		accelerateBool,
+		dualStackBool,
 		chunkSize,
 		fmt.Sprint(rootDirectory),
+		tags,
+		objectACL,
+		int(multipartConcurrency),
+		int(throttleRetries),
+		int(circuitBreakerThreshold),
+		circuitBreakerCooldown,
+		limits,
 	}
 
 	return New(params)
 }
 
+// parseIntParameter converts an integer driver parameter, which may have
+// been provided as a string or any integer type, to an int64.
+func parseIntParameter(name string, param interface{}) (int64, error) {
+	switch v := param.(type) {
+	case string:
+		vv, err := strconv.ParseInt(v, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s parameter must be an integer, %v invalid", name, param)
+		}
+		return vv, nil
+	case int64:
+		return v, nil
+	case int, uint, int32, uint32, uint64:
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(int64(0))).Int(), nil
+	default:
+		return 0, fmt.Errorf("invalid value for %s: %#v", name, param)
+	}
+}
+
 // New constructs a new Driver with the given AWS credentials, region, encryption flag, and
 // bucketName
 func New(params DriverParameters) (*Driver, error) {
@@ -204,8 +379,19 @@ func New(params DriverParameters) (*Driver, error) {
 		return nil, err
 	}
 
+	if params.DualStack {
+		params.Region.S3Endpoint = dualStackEndpoint(params.Region.Name)
+	}
+
+	if params.Accelerate {
+		// Transfer Acceleration is only available with virtual hosted-style
+		// addressing, so the bucket is placed in the hostname.
+		params.Region.S3BucketEndpoint = accelerateEndpoint(params.DualStack)
+	}
+
 	if !params.Secure {
 		params.Region.S3Endpoint = strings.Replace(params.Region.S3Endpoint, "https", "http", 1)
+		params.Region.S3BucketEndpoint = strings.Replace(params.Region.S3BucketEndpoint, "https", "http", 1)
 	}
 
 	s3obj := s3.New(auth, params.Region)
@@ -217,6 +403,14 @@ func New(params DriverParameters) (*Driver, error) {
 		if params.Region.Name == "eu-central-1" {
 			return nil, fmt.Errorf("The eu-central-1 region only works with v4 authentication")
 		}
+		if len(params.Tags) > 0 {
+			return nil, fmt.Errorf("Tagging objects only works with v4 authentication")
+		}
+	}
+
+	tagging, err := encodeTagging(params.Tags)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate that the given credentials have at least read permissions in the
@@ -246,8 +440,26 @@ func New(params DriverParameters) (*Driver, error) {
 		ChunkSize:     params.ChunkSize,
 		Encrypt:       params.Encrypt,
 		RootDirectory: params.RootDirectory,
+		Tagging:       tagging,
+		ObjectACL:     params.ObjectACL,
+		client:        newClient(s3obj),
 		zeros:         make([]byte, params.ChunkSize),
+
+		MultipartConcurrency: params.MultipartConcurrency,
+	}
+
+	if d.MultipartConcurrency < 1 {
+		d.MultipartConcurrency = defaultMultipartConcurrency
+	}
+
+	if d.ObjectACL == "" {
+		d.ObjectACL = s3.Private
+	}
+
+	if params.CircuitBreakerCooldown <= 0 {
+		params.CircuitBreakerCooldown = defaultCircuitBreakerCooldown
 	}
+	d.throttle = newThrottle(params.ThrottleRetries, params.CircuitBreakerThreshold, params.CircuitBreakerCooldown)
 
 	d.pool.New = func() interface{} {
 		return make([]byte, d.ChunkSize)
@@ -256,9 +468,10 @@ func New(params DriverParameters) (*Driver, error) {
 	return &Driver{
 		baseEmbed: baseEmbed{
 			Base: base.Base{
-				StorageDriver: d,
+				StorageDriver: base.NewRegulator(d, params.Limits),
 			},
 		},
+		driver: d,
 	}, nil
 }
 
@@ -270,7 +483,11 @@ func (d *driver) Name() string {
 
 // GetContent retrieves the content stored at "path" as a []byte.
 func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
-	content, err := d.Bucket.Get(d.s3Path(path))
+	var content []byte
+	err := d.throttle.do(ctx, func() (err error) {
+		content, err = d.Bucket.Get(d.s3Path(path))
+		return err
+	})
 	if err != nil {
 		return nil, parseError(path, err)
 	}
@@ -279,7 +496,14 @@ func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
 
 // PutContent stores the []byte content at a location designated by "path".
 func (d *driver) PutContent(ctx context.Context, path string, contents []byte) error {
-	return parseError(path, d.Bucket.Put(d.s3Path(path), contents, d.getContentType(), getPermissions(), d.getOptions()))
+	err := d.throttle.do(ctx, func() error {
+		return d.Bucket.Put(d.s3Path(path), contents, d.getContentType(), d.getPermissions(), d.getOptions())
+	})
+	if err != nil {
+		return parseError(path, err)
+	}
+	d.tagWritten(ctx, path)
+	return nil
 }
 
 // ReadStream retrieves an io.ReadCloser for the content stored at "path" with a
@@ -288,7 +512,11 @@ func (d *driver) ReadStream(ctx context.Context, path string, offset int64) (io.
 	headers := make(http.Header)
 	headers.Add("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
 
-	resp, err := d.Bucket.GetResponseWithHeaders(d.s3Path(path), headers)
+	var resp *http.Response
+	err := d.throttle.do(ctx, func() (err error) {
+		resp, err = d.Bucket.GetResponseWithHeaders(d.s3Path(path), headers)
+		return err
+	})
 	if err != nil {
 		if s3Err, ok := err.(*s3.Error); ok && s3Err.Code == "InvalidRange" {
 			return ioutil.NopCloser(bytes.NewReader(nil)), nil
@@ -309,14 +537,26 @@ func (d *driver) ReadStream(ctx context.Context, path string, offset int64) (io.
 func (d *driver) WriteStream(ctx context.Context, path string, offset int64, reader io.Reader) (totalRead int64, err error) {
 	partNumber := 1
 	bytesRead := 0
-	var putErrChan chan error
 	parts := []s3.Part{}
 	var part s3.Part
-	done := make(chan struct{}) // stopgap to free up waiting goroutines
 
-	multi, err := d.Bucket.InitMulti(d.s3Path(path), d.getContentType(), getPermissions(), d.getOptions())
+	// Parts read from the reader are uploaded in the background, with at most
+	// d.MultipartConcurrency uploads in flight. partsMu protects parts and
+	// putErr once any background upload has been started.
+	var (
+		partsMu sync.Mutex
+		putErr  error
+		putWG   sync.WaitGroup
+		putSem  = make(chan struct{}, d.MultipartConcurrency)
+	)
+
+	var multi *s3.Multi
+	err = d.throttle.do(ctx, func() (err error) {
+		multi, err = d.Bucket.InitMulti(d.s3Path(path), d.getContentType(), d.getPermissions(), d.getOptions())
+		return err
+	})
 	if err != nil {
-		return 0, err
+		return 0, parseError(path, err)
 	}
 
 	buf := d.getbuf()
@@ -329,25 +569,35 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 	// multipart upload, which will eventually be cleaned up, but we will lose all of the progress
 	// made prior to the machine crashing.
 	defer func() {
-		if putErrChan != nil {
-			if putErr := <-putErrChan; putErr != nil {
-				err = putErr
-			}
+		putWG.Wait()
+		if putErr != nil {
+			err = putErr
 		}
 
 		if len(parts) > 0 {
 			if multi == nil {
 				// Parts should be empty if the multi is not initialized
 				panic("Unreachable")
+			} else if putErr != nil {
+				// A failed background part leaves a gap in the upload, so
+				// completing it would produce a corrupt object.
+				multi.Abort()
 			} else {
-				if multi.Complete(parts) != nil {
+				completeErr := d.throttle.do(ctx, func() error {
+					return multi.Complete(parts)
+				})
+				if completeErr != nil {
 					multi.Abort()
+					if err == nil {
+						err = parseError(path, completeErr)
+					}
+				} else {
+					d.tagWritten(ctx, path)
 				}
 			}
 		}
 
 		d.putbuf(buf) // needs to be here to pick up new buf value
-		close(done)   // free up any waiting goroutines
 	}()
 
 	// Fills from 0 to total from current
@@ -391,43 +641,39 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 			}
 		}
 
-		if putErrChan == nil {
-			putErrChan = make(chan error)
-		} else {
-			if putErr := <-putErrChan; putErr != nil {
-				putErrChan = nil
-				return putErr
-			}
+		partsMu.Lock()
+		err := putErr
+		partsMu.Unlock()
+		if err != nil {
+			return err
 		}
 
-		go func(bytesRead int, from int64, buf []byte) {
-			defer d.putbuf(buf) // this buffer gets dropped after this call
+		if bytesRead <= 0 {
+			return nil
+		}
 
-			// DRAGONS(stevvooe): There are few things one might want to know
-			// about this section. First, the putErrChan is expecting an error
-			// and a nil or just a nil to come through the channel. This is
-			// covered by the silly defer below. The other aspect is the s3
-			// retry backoff to deal with RequestTimeout errors. Even though
-			// the underlying s3 library should handle it, it doesn't seem to
-			// be part of the shouldRetry function (see AdRoll/goamz/s3).
+		putSem <- struct{}{} // wait for a free upload slot
+		putWG.Add(1)
+		go func(partNumber int, bytesRead int, from int64, buf []byte) {
 			defer func() {
-				select {
-				case putErrChan <- nil: // for some reason, we do this no matter what.
-				case <-done:
-					return // ensure we don't leak the goroutine
-				}
+				<-putSem
+				putWG.Done()
 			}()
+			defer d.putbuf(buf) // this buffer gets dropped after this call
 
-			if bytesRead <= 0 {
-				return
-			}
-
+			// DRAGONS(stevvooe): The s3 retry backoff below deals with
+			// RequestTimeout errors. Even though the underlying s3 library
+			// should handle it, it doesn't seem to be part of the
+			// shouldRetry function (see AdRoll/goamz/s3).
 			var err error
 			var part s3.Part
 
 		loop:
 			for retries := 0; retries < 5; retries++ {
-				part, err = multi.PutPart(int(partNumber), bytes.NewReader(buf[0:int64(bytesRead)+from]))
+				err = d.throttle.do(ctx, func() (err error) {
+					part, err = multi.PutPart(partNumber, bytes.NewReader(buf[0:int64(bytesRead)+from]))
+					return err
+				})
 				if err == nil {
 					break // success!
 				}
@@ -448,35 +694,46 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 					default:
 						break loop
 					}
+				case storagedriver.ThrottledError:
+					// the circuit breaker is shedding calls.
+					break loop
 				}
 
 				backoff := 100 * time.Millisecond * time.Duration(retries+1)
 				logrus.Errorf("error putting part, retrying after %v: %v", err, backoff.String())
-				time.Sleep(backoff)
+				if waitErr := d.throttle.wait(ctx, backoff); waitErr != nil {
+					err = waitErr
+					break loop
+				}
 			}
 
+			partsMu.Lock()
+			defer partsMu.Unlock()
+
 			if err != nil {
 				logrus.Errorf("error putting part, aborting: %v", err)
-				select {
-				case putErrChan <- err:
-				case <-done:
-					return // don't leak the goroutine
+				if putErr == nil {
+					putErr = parseError(path, err)
 				}
+				return
 			}
 
-			// parts and partNumber are safe, because this function is the
-			// only one modifying them and we force it to be executed
-			// serially.
+			// Parts may complete out of order; they are sorted by part
+			// number when the upload is completed.
 			parts = append(parts, part)
-			partNumber++
-		}(bytesRead, from, buf)
+		}(partNumber, bytesRead, from, buf)
 
+		partNumber++
 		buf = d.getbuf() // use a new buffer for the next call
 		return nil
 	}
 
 	if offset > 0 {
-		resp, err := d.Bucket.Head(d.s3Path(path), nil)
+		var resp *http.Response
+		err := d.throttle.do(ctx, func() (err error) {
+			resp, err = d.Bucket.Head(d.s3Path(path), nil)
+			return err
+		})
 		if err != nil {
 			if s3Err, ok := err.(*s3.Error); !ok || s3Err.Code != "NoSuchKey" {
 				return 0, err
@@ -504,9 +761,12 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 				}
 			} else {
 				// currentLength >= offset >= chunkSize
-				_, part, err = multi.PutPartCopy(partNumber,
-					s3.CopyOptions{CopySourceOptions: "bytes=0-" + strconv.FormatInt(offset-1, 10)},
-					d.Bucket.Name+"/"+d.s3Path(path))
+				err = d.throttle.do(ctx, func() (err error) {
+					_, part, err = multi.PutPartCopy(partNumber,
+						s3.CopyOptions{CopySourceOptions: "bytes=0-" + strconv.FormatInt(offset-1, 10)},
+						d.Bucket.Name+"/"+d.s3Path(path))
+					return err
+				})
 				if err != nil {
 					return 0, err
 				}
@@ -533,7 +793,11 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 			fromZeroFillLarge := func(from, to int64) error {
 				bytesRead64 := int64(0)
 				for to-(from+bytesRead64) >= d.ChunkSize {
-					part, err := multi.PutPart(int(partNumber), bytes.NewReader(d.zeros))
+					var part s3.Part
+					err := d.throttle.do(ctx, func() (err error) {
+						part, err = multi.PutPart(int(partNumber), bytes.NewReader(d.zeros))
+						return err
+					})
 					if err != nil {
 						return err
 					}
@@ -575,7 +839,10 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 						return totalRead, err
 					}
 
-					part, err = multi.PutPart(int(partNumber), bytes.NewReader(buf))
+					err = d.throttle.do(ctx, func() (err error) {
+						part, err = multi.PutPart(int(partNumber), bytes.NewReader(buf))
+						return err
+					})
 					if err != nil {
 						return totalRead, err
 					}
@@ -598,9 +865,12 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 				}
 			} else {
 				// offset > currentLength >= chunkSize
-				_, part, err = multi.PutPartCopy(partNumber,
-					s3.CopyOptions{},
-					d.Bucket.Name+"/"+d.s3Path(path))
+				err = d.throttle.do(ctx, func() (err error) {
+					_, part, err = multi.PutPartCopy(partNumber,
+						s3.CopyOptions{},
+						d.Bucket.Name+"/"+d.s3Path(path))
+					return err
+				})
 				if err != nil {
 					return 0, err
 				}
@@ -641,9 +911,13 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 // Stat retrieves the FileInfo for the given path, including the current size
 // in bytes and the creation time.
 func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
-	listResponse, err := d.Bucket.List(d.s3Path(path), "", "", 1)
+	var listResponse *s3.ListResp
+	err := d.throttle.do(ctx, func() (err error) {
+		listResponse, err = d.Bucket.List(d.s3Path(path), "", "", 1)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, parseError(path, err)
 	}
 
 	fi := storagedriver.FileInfoFields{
@@ -686,9 +960,13 @@ func (d *driver) List(ctx context.Context, path string) ([]string, error) {
 		prefix = "/"
 	}
 
-	listResponse, err := d.Bucket.List(d.s3Path(path), "/", "", listMax)
+	var listResponse *s3.ListResp
+	err := d.throttle.do(ctx, func() (err error) {
+		listResponse, err = d.Bucket.List(d.s3Path(path), "/", "", listMax)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return nil, parseError(path, err)
 	}
 
 	files := []string{}
@@ -704,9 +982,13 @@ func (d *driver) List(ctx context.Context, path string) ([]string, error) {
 		}
 
 		if listResponse.IsTruncated {
-			listResponse, err = d.Bucket.List(d.s3Path(path), "/", listResponse.NextMarker, listMax)
+			marker := listResponse.NextMarker
+			err = d.throttle.do(ctx, func() (err error) {
+				listResponse, err = d.Bucket.List(d.s3Path(path), "/", marker, listMax)
+				return err
+			})
 			if err != nil {
-				return nil, err
+				return nil, parseError(path, err)
 			}
 		} else {
 			break
@@ -720,8 +1002,11 @@ func (d *driver) List(ctx context.Context, path string) ([]string, error) {
 // object.
 func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
 	/* This is terrible, but aws doesn't have an actual move. */
-	_, err := d.Bucket.PutCopy(d.s3Path(destPath), getPermissions(),
-		s3.CopyOptions{Options: d.getOptions(), ContentType: d.getContentType()}, d.Bucket.Name+"/"+d.s3Path(sourcePath))
+	err := d.throttle.do(ctx, func() error {
+		_, err := d.Bucket.PutCopy(d.s3Path(destPath), d.getPermissions(),
+			s3.CopyOptions{Options: d.getOptions(), ContentType: d.getContentType()}, d.Bucket.Name+"/"+d.s3Path(sourcePath))
+		return err
+	})
 	if err != nil {
 		return parseError(sourcePath, err)
 	}
@@ -781,18 +1066,38 @@ func (d *driver) URLFor(ctx context.Context, path string, options map[string]int
 	return d.Bucket.SignedURLWithMethod(methodString, d.s3Path(path), expiresTime, nil, nil), nil
 }
 
+// dualStackEndpoint returns the IPv4/IPv6 dual-stack endpoint for the given
+// region.
+func dualStackEndpoint(region string) string {
+	return "https://s3.dualstack." + region + ".amazonaws.com"
+}
+
+// accelerateEndpoint returns the Transfer Acceleration bucket endpoint,
+// optionally using the dual-stack variant.
+func accelerateEndpoint(dualStack bool) string {
+	if dualStack {
+		return "https://${bucket}.s3-accelerate.dualstack.amazonaws.com"
+	}
+	return "https://${bucket}.s3-accelerate.amazonaws.com"
+}
+
 func (d *driver) s3Path(path string) string {
 	return strings.TrimLeft(strings.TrimRight(d.RootDirectory, "/")+path, "/")
 }
 
 // S3BucketKey returns the s3 bucket key for the given storage driver path.
 func (d *Driver) S3BucketKey(path string) string {
-	return d.StorageDriver.(*driver).s3Path(path)
+	return d.driver.s3Path(path)
 }
 
 func parseError(path string, err error) error {
-	if s3Err, ok := err.(*s3.Error); ok && s3Err.Code == "NoSuchKey" {
-		return storagedriver.PathNotFoundError{Path: path}
+	if s3Err, ok := err.(*s3.Error); ok {
+		switch {
+		case s3Err.Code == "NoSuchKey":
+			return storagedriver.PathNotFoundError{Path: path}
+		case isThrottled(s3Err):
+			return storagedriver.ThrottledError{DriverName: driverName, Enclosed: err}
+		}
 	}
 
 	return err
@@ -807,8 +1112,8 @@ func (d *driver) getOptions() s3.Options {
 	return s3.Options{SSE: d.Encrypt}
 }
 
-func getPermissions() s3.ACL {
-	return s3.Private
+func (d *driver) getPermissions() s3.ACL {
+	return d.ObjectACL
 }
 
 func (d *driver) getContentType() string {
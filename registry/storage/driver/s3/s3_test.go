@@ -4,13 +4,18 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
 	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
 
+	xcontext "golang.org/x/net/context"
 	"gopkg.in/check.v1"
 )
 
@@ -66,8 +71,17 @@ func init() {
 			encryptBool,
 			secureBool,
 			v4AuthBool,
+			false,
+			false,
 			minChunkSize,
 			rootDirectory,
+			nil,
+			s3.Private,
+			defaultMultipartConcurrency,
+			defaultThrottleRetries,
+			defaultCircuitBreakerThreshold,
+			defaultCircuitBreakerCooldown,
+			base.Limits{},
 		}
 
 		return New(parameters)
@@ -150,3 +164,101 @@ func (suite *S3DriverSuite) TestEmptyRootList(c *check.C) {
 		c.Assert(storagedriver.PathRegexp.MatchString(path), check.Equals, true)
 	}
 }
+
+// TestRequestPayer checks that configuring Requester Pays is rejected
+// rather than ignored.
+func TestRequestPayer(t *testing.T) {
+	_, err := FromParameters(map[string]interface{}{
+		"region":       "us-east-1",
+		"bucket":       "bucket",
+		"requestpayer": "requester",
+	})
+	if err == nil || !strings.Contains(err.Error(), "requestpayer") {
+		t.Fatalf("unexpected error configuring requestpayer: %v", err)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	var slept []time.Duration
+	throttle := newThrottle(2, 2, time.Minute)
+	throttle.wait = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	slowDown := &s3.Error{StatusCode: 503, Code: "SlowDown"}
+	calls := 0
+	throttled := func() error {
+		calls++
+		return slowDown
+	}
+
+	// A call recovering from throttling succeeds after a backoff.
+	err := throttle.do(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return slowDown
+		}
+		return nil
+	})
+	if err != nil || calls != 2 || len(slept) != 1 {
+		t.Fatalf("unexpected retries of recovered call: err=%v calls=%d backoffs=%v", err, calls, slept)
+	}
+	if slept[0] < minThrottleBackoff/2 || slept[0] > minThrottleBackoff {
+		t.Fatalf("unexpected first backoff: %v", slept[0])
+	}
+
+	// Calls throttled after all retries open the circuit breaker.
+	for i := 0; i < 2; i++ {
+		calls = 0
+		if err := throttle.do(context.Background(), throttled); err != slowDown || calls != 3 {
+			t.Fatalf("unexpected result of throttled call: err=%v calls=%d", err, calls)
+		}
+	}
+
+	calls = 0
+	err = throttle.do(context.Background(), throttled)
+	throttledErr, ok := err.(storagedriver.ThrottledError)
+	if !ok || calls != 0 {
+		t.Fatalf("expected call to be shed by the open circuit breaker: err=%v calls=%d", err, calls)
+	}
+	if throttledErr.RetryAfter <= 0 || throttledErr.RetryAfter > time.Minute {
+		t.Fatalf("unexpected retry delay of shed call: %v", throttledErr.RetryAfter)
+	}
+}
+
+// TestThrottleContext checks that a throttled call stops waiting to retry
+// once its context is done.
+func TestThrottleContext(t *testing.T) {
+	throttle := newThrottle(defaultThrottleRetries, defaultCircuitBreakerThreshold, defaultCircuitBreakerCooldown)
+	ctx, cancel := xcontext.WithCancel(context.Background())
+
+	calls := 0
+	err := throttle.do(ctx, func() error {
+		calls++
+		cancel()
+		return &s3.Error{StatusCode: 503, Code: "SlowDown"}
+	})
+	if err != xcontext.Canceled || calls != 1 {
+		t.Fatalf("unexpected result of cancelled call: err=%v calls=%d", err, calls)
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	throttle := newThrottle(defaultThrottleRetries, defaultCircuitBreakerThreshold, defaultCircuitBreakerCooldown)
+	for _, testcase := range []struct {
+		retries  int
+		expected time.Duration
+	}{
+		{retries: 0, expected: minThrottleBackoff},
+		{retries: 1, expected: 2 * minThrottleBackoff},
+		{retries: 2, expected: 4 * minThrottleBackoff},
+		{retries: 100, expected: maxThrottleBackoff},
+	} {
+		retries, expected := testcase.retries, testcase.expected
+		backoff := throttle.backoff(retries)
+		if backoff < expected/2 || backoff > expected {
+			t.Errorf("unexpected backoff after %d retries: %v not in [%v, %v]", retries, backoff, expected/2, expected)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/context"
+)
+
+// tagging is the body of a PutObjectTagging request.
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+// encodeTagging returns the PutObjectTagging body setting tags, or nil if
+// there are no tags.
+func encodeTagging(tags map[string]string) ([]byte, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var body tagging
+	for _, key := range keys {
+		body.TagSet = append(body.TagSet, tag{Key: key, Value: tags[key]})
+	}
+	return xml.Marshal(body)
+}
+
+const (
+	// tagAttempts is the number of times a PutObjectTagging request failing
+	// with a network error or an internal error of s3 is attempted, as the
+	// vendored client attempts its own requests.
+	tagAttempts = 5
+
+	// tagRetryDelay is the delay between the attempts of a PutObjectTagging
+	// request.
+	tagRetryDelay = 200 * time.Millisecond
+
+	// tagTimeout bounds each PutObjectTagging request when the vendored
+	// client has no read timeout of its own.
+	tagTimeout = 30 * time.Second
+)
+
+// putTagging sets the configured tags on the object at path. The vendored
+// client can neither tag objects as they are written nor send the
+// PutObjectTagging request, so the request is made and signed here, which
+// requires v4 authentication. It is sent with a client configured like the
+// vendored one and retried like its requests, and throttling is handled
+// like for the other calls. Copies keep the tags of their source, so only
+// the objects written by PutContent and WriteStream need to be tagged.
+func (d *driver) putTagging(ctx context.Context, path string) error {
+	if d.Tagging == nil {
+		return nil
+	}
+
+	return parseError(path, d.throttle.do(ctx, func() error {
+		for attempt := 1; ; attempt++ {
+			err := d.sendTagging(path)
+			if err == nil || attempt >= tagAttempts || !retryTagging(err) {
+				return err
+			}
+
+			logrus.Warnf("error tagging %s, retrying after %v: %v", path, tagRetryDelay, err)
+			if err := d.throttle.wait(ctx, tagRetryDelay); err != nil {
+				return err
+			}
+		}
+	}))
+}
+
+// tagWritten tags the object written at path. The object is already
+// stored by then, so a write is not failed by its tagging: an object whose
+// tagging fails after the retries is logged and left untagged until it is
+// written again.
+func (d *driver) tagWritten(ctx context.Context, path string) {
+	if err := d.putTagging(ctx, path); err != nil {
+		logrus.Errorf("error tagging %s, leaving it untagged: %v", path, err)
+	}
+}
+
+// sendTagging sends a PutObjectTagging request for the object at path.
+func (d *driver) sendTagging(path string) error {
+	req, err := http.NewRequest("PUT", d.Bucket.URL(d.s3Path(path))+"?tagging", bytes.NewReader(d.Tagging))
+	if err != nil {
+		return err
+	}
+
+	sum := md5.Sum(d.Tagging)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	req.Header.Set("Content-Type", "application/xml")
+	if token := d.S3.Auth.Token(); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	signer := aws.NewV4Signer(d.S3.Auth, "s3", d.S3.Region)
+	signer.IncludeXAmzContentSha256 = true
+	signer.Sign(req)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// retryTagging returns whether a PutObjectTagging request failing with err
+// may succeed when attempted again. Throttling is retried by the throttle.
+func retryTagging(err error) bool {
+	switch err := err.(type) {
+	case *s3.Error:
+		return !isThrottled(err) && (err.Code == "InternalError" || err.StatusCode >= 500)
+	case net.Error, *url.Error:
+		return true
+	}
+	return false
+}
+
+// newClient returns an http client connecting to s3 with the timeouts of
+// s3obj. The vendored client dials a connection per request and applies
+// its read timeout from the dial, whereas this client keeps connections
+// alive, so the timeout bounds each request instead.
+func newClient(s3obj *s3.S3) *http.Client {
+	timeout := s3obj.ReadTimeout
+	if timeout <= 0 {
+		timeout = tagTimeout
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Dial:  (&net.Dialer{Timeout: s3obj.ConnectTimeout}).Dial,
+			Proxy: http.ProxyFromEnvironment,
+		},
+	}
+}
+
+// responseError decodes the error responded by s3 like the vendored client
+// does, so that it is handled like the errors of the other calls.
+func responseError(resp *http.Response) error {
+	err := &s3.Error{}
+	xml.NewDecoder(resp.Body).Decode(err)
+	err.StatusCode = resp.StatusCode
+	if err.Message == "" {
+		err.Message = resp.Status
+	}
+	return err
+}
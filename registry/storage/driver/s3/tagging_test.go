@@ -0,0 +1,141 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdRoll/goamz/aws"
+	"github.com/AdRoll/goamz/s3"
+	"github.com/docker/distribution/context"
+)
+
+func TestPutTagging(t *testing.T) {
+	var (
+		requests []*http.Request
+		bodies   []string
+		status   = http.StatusOK
+		failures int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>"))
+			return
+		}
+
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte("<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>"))
+		}
+	}))
+	defer server.Close()
+
+	tags, err := encodeTagging(map[string]string{"project": "registry", "env": "prod"})
+	if err != nil {
+		t.Fatalf("unexpected error encoding tags: %v", err)
+	}
+
+	s3obj := s3.New(aws.Auth{AccessKey: "access", SecretKey: "secret"}, aws.Region{Name: "us-east-1", S3Endpoint: server.URL})
+	s3obj.Signature = aws.V4Signature
+	d := &driver{
+		S3:            s3obj,
+		Bucket:        s3obj.Bucket("bucket"),
+		RootDirectory: "/root",
+		Tagging:       tags,
+		client:        newClient(s3obj),
+		throttle:      newThrottle(0, 0, time.Second),
+	}
+
+	if err := d.putTagging(context.Background(), "/a/b"); err != nil {
+		t.Fatalf("unexpected error tagging: %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("unexpected number of requests: %d", len(requests))
+	}
+	r := requests[0]
+	if r.Method != "PUT" || r.URL.Path != "/bucket/root/a/b" || r.URL.RawQuery != "tagging" {
+		t.Fatalf("unexpected request: %s %s", r.Method, r.URL)
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		t.Fatalf("unexpected authorization: %q", r.Header.Get("Authorization"))
+	}
+
+	expected := "<Tagging><TagSet><Tag><Key>env</Key><Value>prod</Value></Tag><Tag><Key>project</Key><Value>registry</Value></Tag></TagSet></Tagging>"
+	if bodies[0] != expected {
+		t.Fatalf("unexpected body: %s != %s", bodies[0], expected)
+	}
+
+	// Internal errors of s3 are retried.
+	failures = 2
+	if err := d.putTagging(context.Background(), "/a/b"); err != nil {
+		t.Fatalf("unexpected error tagging after internal errors: %v", err)
+	}
+	if len(requests) != 4 {
+		t.Fatalf("unexpected number of requests after internal errors: %d", len(requests))
+	}
+
+	status = http.StatusForbidden
+	err = d.putTagging(context.Background(), "/a/b")
+	if s3Err, ok := err.(*s3.Error); !ok || s3Err.Code != "AccessDenied" || s3Err.StatusCode != http.StatusForbidden {
+		t.Fatalf("unexpected error tagging without permission: %#v", err)
+	}
+
+	d.Tagging = nil
+	if err := d.putTagging(context.Background(), "/a/b"); err != nil || len(requests) != 5 {
+		t.Fatalf("unexpected tagging without tags: %v, %d requests", err, len(requests))
+	}
+}
+
+// TestTaggingFailure checks that a write succeeds even if its object cannot
+// be tagged, and that tagging requests time out.
+func TestTaggingFailure(t *testing.T) {
+	var (
+		puts    int
+		tagging int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if r.URL.RawQuery != "tagging" {
+			puts++
+			return
+		}
+
+		tagging++
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tags, err := encodeTagging(map[string]string{"project": "registry"})
+	if err != nil {
+		t.Fatalf("unexpected error encoding tags: %v", err)
+	}
+
+	s3obj := s3.New(aws.Auth{AccessKey: "access", SecretKey: "secret"}, aws.Region{Name: "us-east-1", S3Endpoint: server.URL})
+	s3obj.Signature = aws.V4Signature
+	s3obj.ReadTimeout = 10 * time.Millisecond
+	d := &driver{
+		S3:            s3obj,
+		Bucket:        s3obj.Bucket("bucket"),
+		RootDirectory: "/root",
+		Tagging:       tags,
+		client:        newClient(s3obj),
+		throttle:      newThrottle(0, 0, time.Second),
+	}
+
+	if err := d.PutContent(context.Background(), "/a/b", []byte("content")); err != nil {
+		t.Fatalf("unexpected error writing with tagging timing out: %v", err)
+	}
+	if puts != 1 || tagging != tagAttempts {
+		t.Fatalf("unexpected requests: %d puts, %d tagging", puts, tagging)
+	}
+}
@@ -0,0 +1,162 @@
+package s3
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/AdRoll/goamz/s3"
+	"github.com/Sirupsen/logrus"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// defaultThrottleRetries is the default number of times a call throttled by
+// s3 is retried before it fails.
+const defaultThrottleRetries = 4
+
+// defaultCircuitBreakerThreshold is the default number of consecutive calls
+// failing with throttling after which the circuit breaker opens.
+const defaultCircuitBreakerThreshold = 5
+
+// defaultCircuitBreakerCooldown is the default time during which calls are
+// not made to s3 once the circuit breaker opens.
+const defaultCircuitBreakerCooldown = 10 * time.Second
+
+const (
+	// minThrottleBackoff is the backoff before the first retry of a
+	// throttled call, doubled for every following retry.
+	minThrottleBackoff = 100 * time.Millisecond
+
+	// maxThrottleBackoff bounds the backoff between retries.
+	maxThrottleBackoff = 5 * time.Second
+)
+
+// errCircuitOpen is enclosed in the throttling errors of the calls shed while
+// the circuit breaker is open.
+var errCircuitOpen = errors.New("s3 circuit breaker is open")
+
+// throttle retries the calls throttled by s3 with an exponential backoff and
+// jitter. Once calls keep failing with throttling after all their retries,
+// its circuit breaker opens and sheds calls for a cooldown, failing them with
+// a throttling error that asks clients to retry after the cooldown.
+type throttle struct {
+	retries   int
+	threshold int
+	cooldown  time.Duration
+
+	// wait waits between retries, and may be replaced in tests.
+	wait func(ctx context.Context, d time.Duration) error
+
+	mu sync.Mutex
+
+	// failures counts the consecutive calls that failed with throttling.
+	failures int
+
+	// openUntil is the end of the cooldown of the open circuit breaker.
+	openUntil time.Time
+}
+
+func newThrottle(retries, threshold int, cooldown time.Duration) *throttle {
+	return &throttle{
+		retries:   retries,
+		threshold: threshold,
+		cooldown:  cooldown,
+		wait:      wait,
+	}
+}
+
+// do calls fn, retrying it while it is throttled. It returns the error of the
+// last call, or a throttling error without calling fn while the circuit
+// breaker is open. It stops waiting to retry, returning the error of ctx, once
+// ctx is done.
+func (t *throttle) do(ctx context.Context, fn func() error) error {
+	if wait := t.open(); wait > 0 {
+		return storagedriver.ThrottledError{DriverName: driverName, RetryAfter: wait, Enclosed: errCircuitOpen}
+	}
+
+	for retries := 0; ; retries++ {
+		err := fn()
+		if !isThrottled(err) {
+			t.succeeded()
+			return err
+		}
+
+		if retries >= t.retries {
+			t.failed()
+			return err
+		}
+
+		backoff := t.backoff(retries)
+		logrus.Warnf("s3 is throttling requests, retrying after %v: %v", backoff, err)
+		if err := t.wait(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for d, or returns the error of ctx once it is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the time to wait before retrying a call throttled after
+// retries retries. The exponential backoff is randomized between its half
+// and its whole, so that throttled calls do not retry in lockstep.
+func (t *throttle) backoff(retries int) time.Duration {
+	backoff := maxThrottleBackoff
+	if retries < 16 && minThrottleBackoff<<uint(retries) < maxThrottleBackoff {
+		backoff = minThrottleBackoff << uint(retries)
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// open returns the remaining cooldown of the circuit breaker, or zero if it
+// is closed.
+func (t *throttle) open() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wait := t.openUntil.Sub(time.Now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (t *throttle) succeeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures = 0
+}
+
+// failed records a call throttled after all its retries, and opens the
+// circuit breaker once the threshold is reached.
+func (t *throttle) failed() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures++
+	if t.threshold > 0 && t.failures >= t.threshold {
+		logrus.Errorf("s3 keeps throttling requests, shedding them for %v", t.cooldown)
+		t.openUntil = time.Now().Add(t.cooldown)
+		t.failures = 0
+	}
+}
+
+// isThrottled returns whether err is s3 asking to reduce the request rate.
+func isThrottled(err error) bool {
+	s3Err, ok := err.(*s3.Error)
+	return ok && (s3Err.Code == "SlowDown" || s3Err.StatusCode == 503 || s3Err.StatusCode == 429)
+}
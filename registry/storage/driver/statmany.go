@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"sync"
+
+	"github.com/docker/distribution/context"
+)
+
+// maxParallelStats bounds the number of concurrent Stat calls made by
+// StatMany for drivers which do not implement BatchStater.
+const maxParallelStats = 16
+
+// StatMany retrieves the FileInfo for each of the given paths, with the
+// batched implementation of the driver if it is a BatchStater, or else with
+// parallel Stat calls. The returned slices have the length of paths, with
+// either the FileInfo or the error of the path at the same index.
+func StatMany(ctx context.Context, driver StorageDriver, paths []string) ([]FileInfo, []error) {
+	if batchStater, ok := driver.(BatchStater); ok {
+		return batchStater.StatMany(ctx, paths)
+	}
+
+	fis := make([]FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	workers := maxParallelStats
+	if len(paths) < workers {
+		workers = len(paths)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fis[i], errs[i] = driver.Stat(ctx, paths[i])
+			}
+		}()
+	}
+
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return fis, errs
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/distribution/context"
 )
@@ -85,6 +87,26 @@ type StorageDriver interface {
 	URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error)
 }
 
+// BatchStater is an optional interface implemented by storage drivers which
+// can stat many paths more efficiently than with a Stat call for each, such
+// as with batched or parallel backend requests. Callers should use StatMany,
+// which falls back to parallel Stat calls for other drivers.
+type BatchStater interface {
+	// StatMany retrieves the FileInfo for each of the given paths. The
+	// returned slices have the length of paths, with either the FileInfo or
+	// the error of the path at the same index.
+	StatMany(ctx context.Context, paths []string) ([]FileInfo, []error)
+}
+
+// FileOpener is an optional interface implemented by storage drivers which
+// store content in local files, so that it can be served from the file, for
+// instance with sendfile. Implementations may return ErrUnsupportedMethod for
+// the files which should be read with ReadStream instead.
+type FileOpener interface {
+	// OpenFile opens the file storing the content at path for reading.
+	OpenFile(ctx context.Context, path string) (*os.File, error)
+}
+
 // PathRegexp is the regular expression which each file path must match. A
 // file path is absolute, beginning with a slash and containing a positive
 // number of path components separated by slashes, where each component is
@@ -113,6 +135,23 @@ func (err InvalidPathError) Error() string {
 	return fmt.Sprintf("Invalid path: %s", err.Path)
 }
 
+// ThrottledError is returned when the storage backend throttles requests,
+// such as by responding with 503 Slow Down or 429 Too Many Requests. Callers
+// should back off before retrying.
+type ThrottledError struct {
+	DriverName string
+
+	// RetryAfter is the delay requested by the backend before retrying, or
+	// zero if it requested none.
+	RetryAfter time.Duration
+
+	Enclosed error
+}
+
+func (err ThrottledError) Error() string {
+	return fmt.Sprintf("%s: storage backend is throttling requests: %v", err.DriverName, err.Enclosed)
+}
+
 // InvalidOffsetError is returned when attempting to read or write from an
 // invalid offset.
 type InvalidOffsetError struct {
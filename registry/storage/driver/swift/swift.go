@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lebauce/swift"
@@ -33,6 +34,19 @@ const driverName = "swift"
 
 const defaultChunkSize = 5 * 1024 * 1024
 
+// defaultSegmentExpiry is the time after which Swift deletes the segments
+// written by WriteStream, which matches the default age of purged uploads.
+const defaultSegmentExpiry = 168 * time.Hour
+
+// dirCacheTTL is the time for which the driver remembers that a directory
+// marker exists. Markers deleted by other registry instances are recreated
+// once it has passed.
+const dirCacheTTL = 5 * time.Minute
+
+// defaultUserAgent is the User-Agent of the requests to Swift, unless
+// configured otherwise.
+const defaultUserAgent = "distribution"
+
 //DriverParameters A struct that encapsulates all of the driver parameters after all values have been set
 type DriverParameters struct {
 	Username  string
@@ -43,10 +57,61 @@ type DriverParameters struct {
 	Container string
 	Prefix    string
 	ChunkSize int64
+	ReadACL   string
+	WriteACL  string
+
+	SegmentExpiry time.Duration
+
+	// UserAgent and Headers are sent with every request to Swift.
+	UserAgent string
+	Headers   map[string]string
+
+	Limits base.Limits
 }
 
 type swiftInfo map[string]interface{}
 
+// headerTransport sets the configured User-Agent and static headers on the
+// requests to Swift. The swift library always sends its own User-Agent with
+// object requests, so it is replaced here.
+type headerTransport struct {
+	http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+func newHeaderTransport(userAgent string, headers map[string]string) *headerTransport {
+	return &headerTransport{
+		// The settings of the default transport of the swift library.
+		RoundTripper: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: 2048,
+		},
+		userAgent: userAgent,
+		headers:   headers,
+	}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The swift library builds a new request for every call and cancels
+	// timed out requests by identity, so the headers are set in place.
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", t.userAgent)
+
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// CancelRequest cancels the requests timed out by the swift library.
+func (t *headerTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.RoundTripper.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
 func init() {
 	factory.Register(driverName, &swiftDriverFactory{})
 }
@@ -64,6 +129,8 @@ type driver struct {
 	Prefix            string
 	BulkDeleteSupport bool
 	ChunkSize         int64
+	SegmentExpiry     time.Duration
+	dirs              *dirCache
 }
 
 type baseEmbed struct {
@@ -120,6 +187,54 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		}
 	}
 
+	segmentExpiry := defaultSegmentExpiry
+	segmentExpiryParam, ok := parameters["segmentexpiry"]
+	if ok {
+		var err error
+		segmentExpiry, err = time.ParseDuration(fmt.Sprint(segmentExpiryParam))
+		if err != nil || segmentExpiry < 0 {
+			return nil, fmt.Errorf("The segmentexpiry parameter should be a positive duration or 0")
+		}
+	}
+	readACL, ok := parameters["readacl"]
+	if !ok {
+		readACL = ""
+	}
+	writeACL, ok := parameters["writeacl"]
+	if !ok {
+		writeACL = ""
+	}
+	userAgent, ok := parameters["useragent"]
+	if !ok {
+		userAgent = ""
+	}
+
+	headers := map[string]string{}
+	headersParam, ok := parameters["headers"]
+	if ok {
+		switch v := headersParam.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				headers[key] = fmt.Sprint(value)
+			}
+		case map[interface{}]interface{}:
+			for key, value := range v {
+				headers[fmt.Sprint(key)] = fmt.Sprint(value)
+			}
+		case map[string]string:
+			for key, value := range v {
+				headers[key] = value
+			}
+		default:
+			return nil, fmt.Errorf("The headers parameter should be a map of header names to values")
+		}
+	}
+
+	limits, err := base.LimitsFromParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	params := DriverParameters{
 		fmt.Sprint(username),
 		fmt.Sprint(password),
@@ -129,6 +244,12 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		fmt.Sprint(container),
 		fmt.Sprint(rootDirectory),
 		chunkSize,
+		fmt.Sprint(readACL),
+		fmt.Sprint(writeACL),
+		segmentExpiry,
+		fmt.Sprint(userAgent),
+		headers,
+		limits,
 	}
 
 	return New(params)
@@ -136,26 +257,42 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 
 // New constructs a new Driver with the given Openstack Swift credentials and container name
 func New(params DriverParameters) (*Driver, error) {
+	if params.UserAgent == "" {
+		params.UserAgent = defaultUserAgent
+	}
+
 	ct := swift.Connection{
 		UserName:       params.Username,
 		ApiKey:         params.Password,
 		AuthUrl:        params.AuthURL,
 		Region:         params.Region,
-		UserAgent:      "distribution",
+		UserAgent:      params.UserAgent,
 		Tenant:         params.Tenant,
 		ConnectTimeout: 60 * time.Second,
 		Timeout:        60 * time.Second,
+		Transport:      newHeaderTransport(params.UserAgent, params.Headers),
 	}
 	err := ct.Authenticate()
 	if err != nil {
 		return nil, fmt.Errorf("Swift authentication failed: %s", err)
 	}
 
-	if err := ct.ContainerCreate(params.Container, nil); err != nil {
+	// The ACLs are set on both containers, since reading a large object
+	// requires access to its segments. Swift updates them if the containers
+	// exist already.
+	headers := make(swift.Headers)
+	if params.ReadACL != "" {
+		headers["X-Container-Read"] = params.ReadACL
+	}
+	if params.WriteACL != "" {
+		headers["X-Container-Write"] = params.WriteACL
+	}
+
+	if err := ct.ContainerCreate(params.Container, headers); err != nil {
 		return nil, fmt.Errorf("Failed to create container %s (%s)", params.Container, err)
 	}
 
-	if err := ct.ContainerCreate(params.Container+"_segments", nil); err != nil {
+	if err := ct.ContainerCreate(params.Container+"_segments", headers); err != nil {
 		return nil, fmt.Errorf("Failed to create container %s (%s)", params.Container+"_segments", err)
 	}
 
@@ -165,12 +302,14 @@ func New(params DriverParameters) (*Driver, error) {
 		Prefix:            params.Prefix,
 		BulkDeleteSupport: detectBulkDelete(params.AuthURL),
 		ChunkSize:         params.ChunkSize,
+		SegmentExpiry:     params.SegmentExpiry,
+		dirs:              newDirCache(dirCacheTTL),
 	}
 
 	return &Driver{
 		baseEmbed: baseEmbed{
 			Base: base.Base{
-				StorageDriver: d,
+				StorageDriver: base.NewRegulator(d, params.Limits),
 			},
 		},
 	}, nil
@@ -301,7 +440,7 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 			// Insert a block a zero
 			d.Conn.ObjectPut(segmentsContainer, getSegment(),
 				bytes.NewReader(zeroBuf), false, "",
-				d.getContentType(), nil)
+				d.getContentType(), d.segmentHeaders())
 			currentLength += d.ChunkSize
 			partNumber++
 		}
@@ -323,7 +462,7 @@ func (d *driver) WriteStream(ctx context.Context, path string, offset int64, rea
 	)
 
 	for {
-		currentSegment, err := d.Conn.ObjectCreate(segmentsContainer, getSegment(), false, "", d.getContentType(), nil)
+		currentSegment, err := d.Conn.ObjectCreate(segmentsContainer, getSegment(), false, "", d.getContentType(), d.segmentHeaders())
 		if err != nil {
 			return bytesRead, parseError(path, err)
 		}
@@ -431,6 +570,10 @@ func (d *driver) Delete(ctx context.Context, path string) error {
 		objects[index] = name[len(d.Prefix):]
 	}
 
+	// Forget the deleted directory markers once they are gone, including
+	// when the deletion fails midway.
+	defer d.dirs.forget(path)
+
 	var multiDelete = true
 	if d.BulkDeleteSupport {
 		_, err := d.Conn.BulkDelete(d.Container, objects)
@@ -479,7 +622,7 @@ func (d *driver) swiftPath(path string) string {
 
 func (d *driver) createParentFolder(path string) (string, error) {
 	dir := gopath.Dir(path)
-	if dir != "/" {
+	if dir != "/" && !d.dirs.contains(dir) {
 		_, _, err := d.Conn.Object(d.Container, d.swiftPath(dir))
 		if swiftErr, ok := err.(*swift.Error); ok && swiftErr.StatusCode == 404 {
 			_, err := d.Conn.ObjectPut(d.Container, d.swiftPath(dir), bytes.NewReader(make([]byte, 0)),
@@ -488,11 +631,84 @@ func (d *driver) createParentFolder(path string) (string, error) {
 				return dir, err
 			}
 		}
+		d.dirs.add(dir)
 	}
 
 	return dir, nil
 }
 
+// dirCache remembers the directory markers known to exist for a while, so
+// that writes to a directory do not check for its marker every time.
+type dirCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	dirs  map[string]time.Time // dir -> time it was known to exist
+	swept time.Time
+}
+
+func newDirCache(ttl time.Duration) *dirCache {
+	return &dirCache{
+		ttl:   ttl,
+		dirs:  make(map[string]time.Time),
+		swept: time.Now(),
+	}
+}
+
+func (dc *dirCache) contains(dir string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	seen, ok := dc.dirs[dir]
+	return ok && time.Since(seen) < dc.ttl
+}
+
+func (dc *dirCache) add(dir string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	now := time.Now()
+	dc.dirs[dir] = now
+
+	// Drop expired directories once per ttl, so that the cache does not grow
+	// with every directory ever written.
+	if now.Sub(dc.swept) >= dc.ttl {
+		for d, seen := range dc.dirs {
+			if now.Sub(seen) >= dc.ttl {
+				delete(dc.dirs, d)
+			}
+		}
+		dc.swept = now
+	}
+}
+
+// forget drops the directory and its subdirectories, whose markers are
+// deleted with it.
+func (dc *dirCache) forget(dir string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	for d := range dc.dirs {
+		if d == dir || strings.HasPrefix(d, strings.TrimRight(dir, "/")+"/") {
+			delete(dc.dirs, d)
+		}
+	}
+}
+
+// segmentHeaders returns the headers of the segments written by WriteStream.
+// The segments expire, so that Swift purges the segments of abandoned
+// uploads, and those left behind when Move copies the content of an upload
+// into a single object.
+func (d *driver) segmentHeaders() swift.Headers {
+	if d.SegmentExpiry == 0 {
+		return nil
+	}
+
+	return swift.Headers{
+		"X-Delete-After": strconv.FormatInt(int64(d.SegmentExpiry/time.Second), 10),
+	}
+}
+
 func (d *driver) getContentType() string {
 	return "application/octet-stream"
 }
@@ -511,8 +727,14 @@ func detectBulkDelete(authURL string) (bulkDelete bool) {
 }
 
 func parseError(path string, err error) error {
-	if swiftErr, ok := err.(*swift.Error); ok && swiftErr.StatusCode == 404 {
-		return storagedriver.PathNotFoundError{Path: path}
+	if swiftErr, ok := err.(*swift.Error); ok {
+		switch swiftErr.StatusCode {
+		case 404:
+			return storagedriver.PathNotFoundError{Path: path}
+		case 429, 498, 503:
+			// 498 is the rate limiting status of some Swift deployments.
+			return storagedriver.ThrottledError{DriverName: driverName, Enclosed: err}
+		}
 	}
 
 	return err
@@ -9,6 +9,7 @@ import (
 
 	"github.com/docker/distribution/context"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/base"
 	"github.com/docker/distribution/registry/storage/driver/testsuites"
 
 	"gopkg.in/check.v1"
@@ -71,6 +72,12 @@ func init() {
 			container,
 			prefix,
 			defaultChunkSize,
+			"",
+			"",
+			defaultSegmentExpiry,
+			"",
+			nil,
+			base.Limits{},
 		}
 
 		return New(parameters)
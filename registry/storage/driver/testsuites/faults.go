@@ -0,0 +1,217 @@
+package testsuites
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// ErrInjectedFault is the error of the calls failed by a fault injecting
+// driver, unless its Faults specify another one.
+var ErrInjectedFault = errors.New("injected storage driver fault")
+
+// Faults configures the faults a fault injecting driver introduces in the
+// calls to the driver it wraps.
+type Faults struct {
+	// Latency delays every call.
+	Latency time.Duration
+
+	// ErrorRate is the fraction of calls, between 0 and 1, which fail with
+	// Err instead of reaching the driver.
+	ErrorRate float64
+
+	// Err is the error of the failed calls, ErrInjectedFault by default. A
+	// storagedriver.ThrottledError simulates a throttling backend.
+	Err error
+
+	// ShortReadRate is the fraction of the reads, between 0 and 1, which
+	// return fewer bytes than requested, both from the streams returned by
+	// ReadStream and from the readers given to WriteStream. Short reads are
+	// valid io.Reader behavior, which drivers and their callers must handle.
+	ShortReadRate float64
+
+	// Seed seeds the random choice of the faulty calls, so that failures
+	// can be reproduced.
+	Seed int64
+}
+
+// enabled returns true if any fault is configured.
+func (faults Faults) enabled() bool {
+	return faults.Latency > 0 || faults.ErrorRate > 0 || faults.ShortReadRate > 0
+}
+
+// faultInjector introduces faults in the calls to a storage driver.
+type faultInjector struct {
+	storagedriver.StorageDriver
+	faults Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFaultInjectingDriver returns a driver calling driver with the faults
+// injected, for testing the resilience of drivers and of their callers. The
+// conformance suite is expected to pass with latency and short reads, but not
+// with errors. StatMany is forwarded to drivers implementing
+// storagedriver.BatchStater, failing as a whole.
+func NewFaultInjectingDriver(driver storagedriver.StorageDriver, faults Faults) storagedriver.StorageDriver {
+	if faults.Err == nil {
+		faults.Err = ErrInjectedFault
+	}
+
+	fi := &faultInjector{
+		StorageDriver: driver,
+		faults:        faults,
+		rand:          rand.New(rand.NewSource(faults.Seed)),
+	}
+
+	if _, ok := driver.(storagedriver.BatchStater); ok {
+		return &batchFaultInjector{fi}
+	}
+	return fi
+}
+
+// chance returns true with the given probability.
+func (fi *faultInjector) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.rand.Float64() < probability
+}
+
+// intn returns a random integer in [0, n).
+func (fi *faultInjector) intn(n int) int {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.rand.Intn(n)
+}
+
+// inject delays the call and returns the error it fails with, if any.
+func (fi *faultInjector) inject() error {
+	if fi.faults.Latency > 0 {
+		time.Sleep(fi.faults.Latency)
+	}
+
+	if fi.chance(fi.faults.ErrorRate) {
+		return fi.faults.Err
+	}
+
+	return nil
+}
+
+func (fi *faultInjector) GetContent(ctx context.Context, path string) ([]byte, error) {
+	if err := fi.inject(); err != nil {
+		return nil, err
+	}
+	return fi.StorageDriver.GetContent(ctx, path)
+}
+
+func (fi *faultInjector) PutContent(ctx context.Context, path string, content []byte) error {
+	if err := fi.inject(); err != nil {
+		return err
+	}
+	return fi.StorageDriver.PutContent(ctx, path, content)
+}
+
+func (fi *faultInjector) ReadStream(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	if err := fi.inject(); err != nil {
+		return nil, err
+	}
+
+	rc, err := fi.StorageDriver.ReadStream(ctx, path, offset)
+	if err != nil || fi.faults.ShortReadRate <= 0 {
+		return rc, err
+	}
+
+	return &shortReadCloser{shortReader: shortReader{Reader: rc, fi: fi}, Closer: rc}, nil
+}
+
+func (fi *faultInjector) WriteStream(ctx context.Context, path string, offset int64, reader io.Reader) (int64, error) {
+	if err := fi.inject(); err != nil {
+		return 0, err
+	}
+
+	if fi.faults.ShortReadRate > 0 {
+		reader = &shortReader{Reader: reader, fi: fi}
+	}
+	return fi.StorageDriver.WriteStream(ctx, path, offset, reader)
+}
+
+func (fi *faultInjector) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	if err := fi.inject(); err != nil {
+		return nil, err
+	}
+	return fi.StorageDriver.Stat(ctx, path)
+}
+
+func (fi *faultInjector) List(ctx context.Context, path string) ([]string, error) {
+	if err := fi.inject(); err != nil {
+		return nil, err
+	}
+	return fi.StorageDriver.List(ctx, path)
+}
+
+func (fi *faultInjector) Move(ctx context.Context, sourcePath string, destPath string) error {
+	if err := fi.inject(); err != nil {
+		return err
+	}
+	return fi.StorageDriver.Move(ctx, sourcePath, destPath)
+}
+
+func (fi *faultInjector) Delete(ctx context.Context, path string) error {
+	if err := fi.inject(); err != nil {
+		return err
+	}
+	return fi.StorageDriver.Delete(ctx, path)
+}
+
+func (fi *faultInjector) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	if err := fi.inject(); err != nil {
+		return "", err
+	}
+	return fi.StorageDriver.URLFor(ctx, path, options)
+}
+
+// batchFaultInjector injects faults in the calls to a driver implementing
+// storagedriver.BatchStater.
+type batchFaultInjector struct {
+	*faultInjector
+}
+
+func (bfi *batchFaultInjector) StatMany(ctx context.Context, paths []string) ([]storagedriver.FileInfo, []error) {
+	if err := bfi.inject(); err != nil {
+		errs := make([]error, len(paths))
+		for i := range errs {
+			errs[i] = err
+		}
+		return make([]storagedriver.FileInfo, len(paths)), errs
+	}
+	return bfi.StorageDriver.(storagedriver.BatchStater).StatMany(ctx, paths)
+}
+
+// shortReader returns fewer bytes than requested from some of its reads.
+type shortReader struct {
+	io.Reader
+	fi *faultInjector
+}
+
+func (sr *shortReader) Read(p []byte) (int, error) {
+	if len(p) > 1 && sr.fi.chance(sr.fi.faults.ShortReadRate) {
+		p = p[:1+sr.fi.intn(len(p)-1)]
+	}
+	return sr.Reader.Read(p)
+}
+
+// shortReadCloser is a shortReader closing the stream it reads.
+type shortReadCloser struct {
+	shortReader
+	io.Closer
+}
@@ -0,0 +1,99 @@
+package testsuites
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestFaultInjectingDriverErrors(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	if err := driver.PutContent(ctx, "/a", []byte("content")); err != nil {
+		t.Fatalf("unexpected error putting content: %v", err)
+	}
+
+	failing := NewFaultInjectingDriver(driver, Faults{ErrorRate: 1})
+	if _, err := failing.GetContent(ctx, "/a"); err != ErrInjectedFault {
+		t.Fatalf("expected injected fault: %v", err)
+	}
+
+	if _, errs := storagedriver.StatMany(ctx, failing, []string{"/a", "/b"}); errs[0] != ErrInjectedFault || errs[1] != ErrInjectedFault {
+		t.Fatalf("expected injected faults from StatMany: %v", errs)
+	}
+
+	throttled := storagedriver.ThrottledError{DriverName: "inmemory", Enclosed: ErrInjectedFault}
+	throttling := NewFaultInjectingDriver(driver, Faults{ErrorRate: 1, Err: throttled})
+	if err := throttling.Delete(ctx, "/a"); err != throttled {
+		t.Fatalf("expected configured error: %v", err)
+	}
+
+	// About half of the calls fail with an error rate of one half, in the
+	// same way for the same seed.
+	failures := func(seed int64) []bool {
+		flaky := NewFaultInjectingDriver(driver, Faults{ErrorRate: 0.5, Seed: seed})
+
+		var failed []bool
+		for i := 0; i < 100; i++ {
+			_, err := flaky.Stat(ctx, "/a")
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first, second := failures(42), failures(42)
+	count := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same failures for the same seed")
+		}
+		if first[i] {
+			count++
+		}
+	}
+
+	if count < 25 || count > 75 {
+		t.Fatalf("unexpected number of failures with an error rate of one half: %d", count)
+	}
+}
+
+func TestFaultInjectingDriverShortReads(t *testing.T) {
+	ctx := context.Background()
+	driver := NewFaultInjectingDriver(inmemory.New(), Faults{ShortReadRate: 1})
+
+	contents := randomContents(4096)
+	if _, err := driver.WriteStream(ctx, "/a", 0, bytes.NewReader(contents)); err != nil {
+		t.Fatalf("unexpected error writing stream: %v", err)
+	}
+
+	rc, err := driver.ReadStream(ctx, "/a", 0)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+	defer rc.Close()
+
+	p := make([]byte, len(contents))
+	if n, err := rc.Read(p); err != nil || n >= len(p) {
+		t.Fatalf("expected a short read: %d, %v", n, err)
+	}
+
+	// Streams read in full are unaffected.
+	rc, err = driver.ReadStream(ctx, "/a", 0)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+	defer rc.Close()
+
+	read, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+
+	if !bytes.Equal(read, contents) {
+		t.Fatalf("unexpected contents read with short reads")
+	}
+}
@@ -3,6 +3,7 @@ package testsuites
 import (
 	"bytes"
 	"crypto/sha1"
+	"flag"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -32,6 +34,48 @@ func RegisterInProcessSuite(driverConstructor DriverConstructor, skipCheck SkipC
 	})
 }
 
+// Run runs the storage driver test suite against the driver returned by
+// driverConstructor, with the faults injected if any, and fails t if the
+// suite fails. It lets out-of-tree drivers check their conformance from a
+// plain go test function, without registering a suite, for instance:
+//
+//	func TestConformance(t *testing.T) {
+//		testsuites.Run(t, newDriver, testsuites.NeverSkip, testsuites.Faults{
+//			Latency:       time.Millisecond,
+//			ShortReadRate: 0.5,
+//		})
+//	}
+//
+// The check.f and check.v flags of gocheck select and report the tests.
+func Run(t *testing.T, driverConstructor DriverConstructor, skipCheck SkipCheck, faults Faults) {
+	RunFiltered(t, "", driverConstructor, skipCheck, faults)
+}
+
+// RunFiltered is like Run, but only runs the tests of the suite matching the
+// filter regular expression, unless the check.f flag selects other tests.
+// It lets drivers run the parts of the suite affected by the faults rather
+// than the whole suite once more.
+func RunFiltered(t *testing.T, filter string, driverConstructor DriverConstructor, skipCheck SkipCheck, faults Faults) {
+	conf := &check.RunConf{Filter: filter}
+	if f := flag.Lookup("check.f"); f != nil && f.Value.String() != "" {
+		conf.Filter = f.Value.String()
+	}
+	if f := flag.Lookup("check.v"); f != nil {
+		conf.Verbose = f.Value.String() == "true"
+	}
+
+	result := check.Run(&DriverSuite{
+		Constructor: driverConstructor,
+		SkipCheck:   skipCheck,
+		Faults:      faults,
+		ctx:         context.Background(),
+	}, conf)
+
+	if !result.Passed() {
+		t.Fatalf("storage driver test suite failed: %s", result)
+	}
+}
+
 // RegisterIPCSuite registers a storage driver test suite which runs the named
 // driver as a child process with the given parameters.
 func RegisterIPCSuite(driverName string, ipcParams map[string]string, skipCheck SkipCheck) {
@@ -89,6 +133,10 @@ type DriverSuite struct {
 	Constructor DriverConstructor
 	Teardown    DriverTeardown
 	SkipCheck
+
+	// Faults are injected in the calls to the driver, if any.
+	Faults Faults
+
 	storagedriver.StorageDriver
 	ctx context.Context
 }
@@ -100,6 +148,9 @@ func (suite *DriverSuite) SetUpSuite(c *check.C) {
 	}
 	d, err := suite.Constructor()
 	c.Assert(err, check.IsNil)
+	if suite.Faults.enabled() {
+		d = NewFaultInjectingDriver(d, suite.Faults)
+	}
 	suite.StorageDriver = d
 }
 
@@ -779,6 +830,49 @@ func (suite *DriverSuite) TestStatCall(c *check.C) {
 	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
+// TestStatMany checks that many paths can be stated together, with the
+// FileInfo or the error of each path at its index.
+func (suite *DriverSuite) TestStatMany(c *check.C) {
+	content := randomContents(4096)
+	dirPath := randomPath(32)
+	filePaths := make([]string, 20)
+	for i := range filePaths {
+		filePaths[i] = path.Join(dirPath, randomFilename(32))
+	}
+
+	defer suite.StorageDriver.Delete(suite.ctx, firstPart(dirPath))
+
+	// Every other file is written, the others are missing.
+	for i := 0; i < len(filePaths); i += 2 {
+		err := suite.StorageDriver.PutContent(suite.ctx, filePaths[i], content)
+		c.Assert(err, check.IsNil)
+	}
+
+	paths := append([]string{dirPath, "/invalid path"}, filePaths...)
+	fis, errs := storagedriver.StatMany(suite.ctx, suite.StorageDriver, paths)
+	c.Assert(fis, check.HasLen, len(paths))
+	c.Assert(errs, check.HasLen, len(paths))
+
+	c.Assert(errs[0], check.IsNil)
+	c.Assert(fis[0].Path(), check.Equals, dirPath)
+	c.Assert(fis[0].IsDir(), check.Equals, true)
+
+	c.Assert(errs[1], check.FitsTypeOf, storagedriver.InvalidPathError{})
+
+	for i, filePath := range filePaths {
+		fi, err := fis[i+2], errs[i+2]
+		if i%2 != 0 {
+			c.Assert(err, check.FitsTypeOf, storagedriver.PathNotFoundError{})
+			continue
+		}
+
+		c.Assert(err, check.IsNil)
+		c.Assert(fi.Path(), check.Equals, filePath)
+		c.Assert(fi.Size(), check.Equals, int64(len(content)))
+		c.Assert(fi.IsDir(), check.Equals, false)
+	}
+}
+
 // TestPutContentMultipleTimes checks that if storage driver can overwrite the content
 // in the subsequent puts. Validates that PutContent does not have to work
 // with an offset like WriteStream does and overwrites the file entirely
@@ -909,7 +1003,7 @@ func (suite *DriverSuite) TestEventualConsistency(c *check.C) {
 	}
 
 	if misswrites > 0 {
-		c.Log("There were " + string(misswrites) + " occurences of a write not being instantly available.")
+		c.Log("There were " + strconv.Itoa(misswrites) + " occurences of a write not being instantly available.")
 	}
 
 	c.Assert(misswrites, check.Not(check.Equals), 1024)
@@ -18,6 +18,9 @@ import (
 // set this correctly, so we may want to leave it to the driver. For
 // out of process drivers, we'll have to optimize this buffer size for
 // local communication.
+//
+// fileReaderBufferSize is the default size of the read buffer, see
+// ReadBufferSize to configure it.
 const fileReaderBufferSize = 4 << 20
 
 // remoteFileReader provides a read seeker interface to files stored in
@@ -33,6 +36,10 @@ type fileReader struct {
 	size    int64     // size is the total size, must be set.
 	modtime time.Time // TODO(stevvooe): This is not needed anymore.
 
+	// bufferSize is the size of the read buffer, fileReaderBufferSize if
+	// zero.
+	bufferSize int
+
 	// mutable fields
 	rc     io.ReadCloser // remote read closer
 	brd    *bufio.Reader // internal buffered io
@@ -167,7 +174,11 @@ func (fr *fileReader) reader() (io.Reader, error) {
 		// set this correctly, so we may want to leave it to the driver. For
 		// out of process drivers, we'll have to optimize this buffer size for
 		// local communication.
-		fr.brd = bufio.NewReaderSize(fr.rc, fileReaderBufferSize)
+		bufferSize := fr.bufferSize
+		if bufferSize <= 0 {
+			bufferSize = fileReaderBufferSize
+		}
+		fr.brd = bufio.NewReaderSize(fr.rc, bufferSize)
 	} else {
 		fr.brd.Reset(fr.rc)
 	}
@@ -6,14 +6,19 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/registry/storage/cache"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/base"
+	"github.com/docker/distribution/registry/storage/driver/filesystem"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
 )
@@ -248,6 +253,276 @@ func TestSimpleLayerRead(t *testing.T) {
 	}
 }
 
+// fileRecorder records whether responses are copied from a file with
+// ReadFrom, which lets net/http send them with sendfile.
+type fileRecorder struct {
+	*httptest.ResponseRecorder
+	fromFile bool
+}
+
+func (fr *fileRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r := src
+	if lr, ok := r.(*io.LimitedReader); ok {
+		r = lr.R
+	}
+	_, fr.fromFile = r.(*os.File)
+
+	return io.Copy(fr.ResponseRecorder, src)
+}
+
+// TestLayerServeContent serves layers of the filesystem driver, which are
+// sent from the layer file even through a regulator, and of the inmemory
+// driver, which are copied through the read buffer, checking that ranges are
+// honored by both.
+func TestLayerServeContent(t *testing.T) {
+	ctx := context.Background()
+	imageName := "foo/bar"
+
+	root, err := ioutil.TempDir("", "driver-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, testcase := range []struct {
+		driver   storagedriver.StorageDriver
+		fromFile bool
+	}{
+		{base.NewRegulator(filesystem.New(filesystem.DriverParameters{RootDirectory: root}), base.Limits{Reads: 1}), true},
+		{inmemory.New(), false},
+	} {
+		driver := testcase.driver
+		registry := NewRegistryWithDriver(ctx, driver, nil, ReadBufferSize(64<<10))
+		repository, err := registry.Repository(ctx, imageName)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		randomLayerReader, tarSumStr, err := testutil.CreateRandomTarFile()
+		if err != nil {
+			t.Fatalf("error creating random data: %v", err)
+		}
+		dgst := digest.Digest(tarSumStr)
+
+		if _, err := writeTestLayer(driver, defaultPathMapper, imageName, dgst, randomLayerReader); err != nil {
+			t.Fatalf("unexpected error writing test layer: %v", err)
+		}
+
+		if _, err := randomLayerReader.Seek(0, os.SEEK_SET); err != nil {
+			t.Fatalf("error resetting layer reader: %v", err)
+		}
+		randomLayerData, err := ioutil.ReadAll(randomLayerReader)
+		if err != nil {
+			t.Fatalf("random layer read failed: %v", err)
+		}
+
+		layer, err := repository.Layers().Fetch(dgst)
+		if err != nil {
+			t.Fatalf("unexpected error fetching layer: %v", err)
+		}
+		defer layer.Close()
+
+		handler, ok := layer.(http.Handler)
+		if !ok {
+			t.Fatalf("%s: layer does not serve its content", driver.Name())
+		}
+
+		for _, test := range []struct {
+			rangeHeader string
+			status      int
+			content     []byte
+		}{
+			{"", http.StatusOK, randomLayerData},
+			{"bytes=10-1033", http.StatusPartialContent, randomLayerData[10:1034]},
+		} {
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %v", err)
+			}
+			if test.rangeHeader != "" {
+				req.Header.Set("Range", test.rangeHeader)
+			}
+
+			w := &fileRecorder{ResponseRecorder: httptest.NewRecorder()}
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.status {
+				t.Fatalf("%s: unexpected status serving range %q: %d != %d", driver.Name(), test.rangeHeader, w.Code, test.status)
+			}
+
+			if w.fromFile != testcase.fromFile {
+				t.Fatalf("%s: unexpected sending from file serving range %q: %t != %t", driver.Name(), test.rangeHeader, w.fromFile, testcase.fromFile)
+			}
+
+			if !bytes.Equal(w.Body.Bytes(), test.content) {
+				t.Fatalf("%s: unexpected content serving range %q", driver.Name(), test.rangeHeader)
+			}
+
+			if w.Header().Get("Docker-Content-Digest") != dgst.String() {
+				t.Fatalf("%s: unexpected digest header: %q != %q", driver.Name(), w.Header().Get("Docker-Content-Digest"), dgst)
+			}
+		}
+	}
+}
+
+// TestLayerVerifyReads checks that layers are served completely when they
+// match their digest, and that the end of corrupt layers is withheld, when
+// layer reads are verified.
+func TestLayerVerifyReads(t *testing.T) {
+	ctx := context.Background()
+	imageName := "foo/bar"
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil, VerifyLayerReads())
+	repository, err := registry.Repository(ctx, imageName)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	content := make([]byte, 100<<10)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	if _, err := writeTestLayer(driver, defaultPathMapper, imageName, dgst, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error writing test layer: %v", err)
+	}
+
+	serve := func(rangeHeader string) *httptest.ResponseRecorder {
+		layer, err := repository.Layers().Fetch(dgst)
+		if err != nil {
+			t.Fatalf("unexpected error fetching layer: %v", err)
+		}
+		defer layer.Close()
+
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %v", err)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+
+		w := httptest.NewRecorder()
+		layer.(http.Handler).ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(""); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("unexpected response serving intact layer: %d, %d bytes", w.Code, w.Body.Len())
+	}
+
+	// Corrupt the layer in the backend, keeping its size.
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error getting blob path: %v", err)
+	}
+	corrupt := append([]byte(nil), content...)
+	corrupt[len(corrupt)/2]++
+	if err := driver.PutContent(ctx, blobPath, corrupt); err != nil {
+		t.Fatalf("unexpected error corrupting layer: %v", err)
+	}
+
+	w := serve("")
+	if w.Body.Len() >= len(content) {
+		t.Fatalf("expected corrupt layer to be truncated: %d bytes served", w.Body.Len())
+	}
+	if !bytes.Equal(w.Body.Bytes(), corrupt[:w.Body.Len()]) {
+		t.Fatalf("unexpected content served for corrupt layer")
+	}
+
+	// Ranges are not verified.
+	if w := serve("bytes=10-1033"); w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), corrupt[10:1034]) {
+		t.Fatalf("unexpected response serving range of corrupt layer: %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+// TestLayerContentEncoding checks that layers are served with the content
+// encoding they were pushed with, from the backend and from the cache.
+func TestLayerContentEncoding(t *testing.T) {
+	ctx := context.Background()
+	imageName := "foo/bar"
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryLayerInfoCache(), ContentEncodings())
+	repository, err := registry.Repository(ctx, imageName)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	content := []byte("pre-compressed content")
+	dgst, err := digest.FromBytesWithAlgorithm("sha256", content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	push := func(encoding string) {
+		upload, err := repository.Layers().Upload()
+		if err != nil {
+			t.Fatalf("unexpected error starting upload: %v", err)
+		}
+
+		if _, err := io.Copy(upload, bytes.NewReader(content)); err != nil {
+			t.Fatalf("unexpected error copying to upload: %v", err)
+		}
+		upload.Close()
+
+		// The encoding is set when the upload is completed.
+		upload, err = repository.Layers().Resume(upload.UUID())
+		if err != nil {
+			t.Fatalf("unexpected error resuming upload: %v", err)
+		}
+
+		upload.SetContentEncoding(encoding)
+		if _, err := upload.Finish(dgst); err != nil {
+			t.Fatalf("unexpected error finishing upload: %v", err)
+		}
+	}
+
+	checkEncoding := func(expected string) {
+		// The first fetch fills the cache and the second is served from it.
+		for i := 0; i < 2; i++ {
+			layer, err := repository.Layers().Fetch(dgst)
+			if err != nil {
+				t.Fatalf("unexpected error fetching layer: %v", err)
+			}
+			defer layer.Close()
+
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %v", err)
+			}
+
+			handler, err := layer.Handler(req)
+			if err != nil {
+				t.Fatalf("unexpected error getting layer handler: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+				t.Fatalf("unexpected response serving layer: %d, %q", w.Code, w.Body.String())
+			}
+
+			if encoding := w.Header().Get("Content-Encoding"); encoding != expected {
+				t.Fatalf("unexpected content encoding: %q != %q", encoding, expected)
+			}
+		}
+	}
+
+	push("gzip")
+	checkEncoding("gzip")
+
+	// Pushing without an encoding keeps the recorded one, and pushing with
+	// the identity encoding removes it.
+	push("")
+	checkEncoding("gzip")
+	push("identity")
+	checkEncoding("")
+}
+
 // TestLayerUploadZeroLength uploads zero-length
 func TestLayerUploadZeroLength(t *testing.T) {
 	ctx := context.Background()
@@ -287,6 +562,164 @@ func TestLayerUploadZeroLength(t *testing.T) {
 	}
 }
 
+// TestNegativeLayerCache checks that layers found missing are served from the
+// cache until they are pushed.
+func TestNegativeLayerCache(t *testing.T) {
+	ctx := context.Background()
+	imageName := "foo/bar"
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryLayerInfoCache(), NegativeLayerCacheTTL(time.Minute))
+	repository, err := registry.Repository(ctx, imageName)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	content := []byte("missing content")
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	checkMissing := func(expected bool) {
+		exists, err := repository.Layers().Exists(dgst)
+		if err != nil {
+			t.Fatalf("unexpected error checking layer: %v", err)
+		}
+
+		if exists == expected {
+			t.Fatalf("unexpected layer existence: %v", exists)
+		}
+
+		_, err = repository.Layers().Fetch(dgst)
+		switch err.(type) {
+		case nil:
+			if expected {
+				t.Fatalf("expected layer to be missing")
+			}
+		case distribution.ErrUnknownLayer:
+			if !expected {
+				t.Fatalf("expected layer to be found")
+			}
+		default:
+			t.Fatalf("unexpected error fetching layer: %v", err)
+		}
+	}
+
+	checkMissing(true)
+
+	// Layers written behind the back of the registry are not found while
+	// they are recorded missing.
+	if _, err := writeTestLayer(driver, defaultPathMapper, imageName, dgst, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error writing layer: %v", err)
+	}
+	checkMissing(true)
+
+	upload, err := repository.Layers().Upload()
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+
+	if _, err := io.Copy(upload, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error copying to upload: %v", err)
+	}
+	upload.Close()
+
+	upload, err = repository.Layers().Resume(upload.UUID())
+	if err != nil {
+		t.Fatalf("unexpected error resuming upload: %v", err)
+	}
+
+	if _, err := upload.Finish(dgst); err != nil {
+		t.Fatalf("unexpected error finishing upload: %v", err)
+	}
+	checkMissing(false)
+}
+
+// rejectingLayerValidator rejects layers whose content contains a marker.
+type rejectingLayerValidator struct {
+	marker    []byte
+	validated []digest.Digest
+}
+
+func (v *rejectingLayerValidator) ValidateLayer(ctx context.Context, name string, dgst digest.Digest, content io.Reader) error {
+	v.validated = append(v.validated, dgst)
+
+	p, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(p, v.marker) {
+		return distribution.ErrLayerRejected{Digest: dgst, Reason: "marker found"}
+	}
+
+	return nil
+}
+
+// TestLayerUploadValidators checks that layer validators see the uploaded
+// content and that rejected layers are not committed.
+func TestLayerUploadValidators(t *testing.T) {
+	ctx := context.Background()
+	validator := &rejectingLayerValidator{marker: []byte("EICAR")}
+	registry := NewRegistryWithDriver(ctx, inmemory.New(), nil, LayerValidators(validator))
+	repository, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	ls := repository.Layers()
+
+	for _, testcase := range []struct {
+		content  []byte
+		rejected bool
+	}{
+		{content: []byte("clean content")},
+		{content: []byte("infected EICAR content"), rejected: true},
+		{content: []byte{}},
+	} {
+		dgst, err := digest.FromBytes(testcase.content)
+		if err != nil {
+			t.Fatalf("error digesting content: %v", err)
+		}
+
+		upload, err := ls.Upload()
+		if err != nil {
+			t.Fatalf("unexpected error starting upload: %v", err)
+		}
+
+		if _, err := io.Copy(upload, bytes.NewReader(testcase.content)); err != nil {
+			t.Fatalf("unexpected error copying to upload: %v", err)
+		}
+		upload.Close()
+
+		upload, err = ls.Resume(upload.UUID())
+		if err != nil {
+			t.Fatalf("unexpected error resuming upload: %v", err)
+		}
+
+		_, err = upload.Finish(dgst)
+		if _, ok := err.(distribution.ErrLayerRejected); ok != testcase.rejected {
+			t.Fatalf("%q: unexpected error finishing upload: %v", testcase.content, err)
+		}
+
+		if testcase.rejected {
+			upload.Cancel()
+		}
+
+		exists, err := ls.Exists(dgst)
+		if err != nil {
+			t.Fatalf("unexpected error checking layer existence: %v", err)
+		}
+
+		if exists == testcase.rejected {
+			t.Fatalf("%q: unexpected layer existence: %t", testcase.content, exists)
+		}
+
+		if validated := validator.validated[len(validator.validated)-1]; validated != dgst {
+			t.Fatalf("%q: unexpected validated digest: %v != %v", testcase.content, validated, dgst)
+		}
+	}
+}
+
 // writeRandomLayer creates a random layer under name and tarSum using driver
 // and pathMapper. An io.ReadSeeker with the data is returned, along with the
 // sha256 hex digest.
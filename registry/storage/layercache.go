@@ -8,6 +8,7 @@ import (
 	"github.com/docker/distribution"
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/registry/storage/cache"
 	"github.com/docker/distribution/registry/storage/driver"
 	"golang.org/x/net/context"
@@ -22,6 +23,14 @@ type cachedLayerService struct {
 	driver                    driver.StorageDriver
 	*blobStore                // global blob store
 	cache                     cache.LayerInfoCache
+	readBufferSize            int
+	verifyReads               bool
+	encodings                 bool
+
+	// negativeCache records the layers found missing from the repository
+	// for negativeCacheTTL, if negative caching is enabled.
+	negativeCache    cache.NegativeCache
+	negativeCacheTTL time.Duration
 }
 
 // Exists checks for existence of the digest in the cache, immediately
@@ -48,6 +57,11 @@ func (lc *cachedLayerService) Exists(dgst digest.Digest) (bool, error) {
 		return true, nil
 	}
 
+	if lc.missing(dgst) {
+		atomic.AddUint64(&layerInfoCacheMetrics.Exists.Hits, 1)
+		return false, nil
+	}
+
 fallback:
 	atomic.AddUint64(&layerInfoCacheMetrics.Exists.Misses, 1)
 	exists, err := lc.LayerService.Exists(dgst)
@@ -56,15 +70,93 @@ fallback:
 	}
 
 	if exists {
-		// we can only cache this if the existence is positive.
 		if err := lc.cache.Add(lc.ctx, lc.repository.Name(), dgst); err != nil {
 			ctxu.GetLogger(lc.ctx).Errorf("error adding %v@%v to cache: %v", lc.repository.Name(), dgst, err)
 		}
+	} else {
+		lc.addMissing(dgst)
 	}
 
 	return exists, err
 }
 
+// missing returns true if the layer is recorded missing from the repository
+// by the negative cache.
+func (lc *cachedLayerService) missing(dgst digest.Digest) bool {
+	if lc.negativeCache == nil {
+		return false
+	}
+
+	missing, err := lc.negativeCache.Missing(lc.ctx, lc.repository.Name(), dgst)
+	if err != nil {
+		ctxu.GetLogger(lc.ctx).Errorf("error checking for missing %v@%v: %v", lc.repository.Name(), dgst, err)
+		return false
+	}
+
+	return missing
+}
+
+// addMissing records the layer missing from the repository in the negative
+// cache.
+func (lc *cachedLayerService) addMissing(dgst digest.Digest) {
+	if lc.negativeCache == nil {
+		return
+	}
+
+	if err := lc.negativeCache.AddMissing(lc.ctx, lc.repository.Name(), dgst, lc.negativeCacheTTL); err != nil {
+		ctxu.GetLogger(lc.ctx).Errorf("error recording missing %v@%v in cache: %v", lc.repository.Name(), dgst, err)
+	}
+}
+
+// existsMany checks for existence of the digests in the cache together,
+// checking the missing ones together upstream. Positive results are written into the
+// cache.
+func (lc *cachedLayerService) existsMany(dgsts []digest.Digest) ([]bool, []error) {
+	ctxu.GetLogger(lc.ctx).Debugf("(*cachedLayerService).existsMany(%d)", len(dgsts))
+
+	exists := make([]bool, len(dgsts))
+	errs := make([]error, len(dgsts))
+
+	// The layers are looked up together, in a single round trip with caches
+	// supporting batched lookups.
+	available, err := cache.ContainsMany(lc.ctx, lc.cache, lc.repository.Name(), dgsts)
+	if err != nil {
+		ctxu.GetLogger(lc.ctx).Errorf("error checking availability of %d layers of %v: %v", len(dgsts), lc.repository.Name(), err)
+		available = make([]bool, len(dgsts))
+	}
+
+	var misses []digest.Digest
+	var missed []int
+	for i, dgst := range dgsts {
+		atomic.AddUint64(&layerInfoCacheMetrics.Exists.Requests, 1)
+		if available[i] {
+			atomic.AddUint64(&layerInfoCacheMetrics.Exists.Hits, 1)
+			exists[i] = true
+			continue
+		}
+
+		atomic.AddUint64(&layerInfoCacheMetrics.Exists.Misses, 1)
+		misses = append(misses, dgst)
+		missed = append(missed, i)
+	}
+
+	if len(misses) == 0 {
+		return exists, errs
+	}
+
+	upstream, upstreamErrs := layersExist(lc.LayerService, misses)
+	for j, i := range missed {
+		exists[i], errs[i] = upstream[j], upstreamErrs[j]
+		if exists[i] && errs[i] == nil {
+			if err := lc.cache.Add(lc.ctx, lc.repository.Name(), dgsts[i]); err != nil {
+				ctxu.GetLogger(lc.ctx).Errorf("error adding %v@%v to cache: %v", lc.repository.Name(), dgsts[i], err)
+			}
+		}
+	}
+
+	return exists, errs
+}
+
 // Fetch checks for the availability of the layer in the repository via the
 // cache. If present, the metadata is resolved and the layer is returned. If
 // any operation fails, the layer is read directly from the upstream. The
@@ -93,17 +185,35 @@ func (lc *cachedLayerService) Fetch(dgst digest.Digest) (distribution.Layer, err
 		}
 
 		atomic.AddUint64(&layerInfoCacheMetrics.Fetch.Hits, 1)
-		return newLayerReader(lc.driver, dgst, meta.Path, meta.Length)
+		lr, err := newLayerReader(lc.driver, dgst, meta.Path, meta.Length, lc.readBufferSize)
+		if err != nil {
+			return nil, err
+		}
+		lr.ctx = lc.ctx
+		lr.verify = lc.verifyReads
+		if lc.encodings {
+			lr.encoding, err = readLayerEncoding(lc.ctx, lc.driver, lc.pm, lc.repository.Name(), dgst)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return lr, nil
 	}
 
-	// NOTE(stevvooe): Unfortunately, the cache here only makes checks for
-	// existing layers faster. We'd have to provide more careful
-	// synchronization with the backend to make the missing case as fast.
+	// Layers recorded missing are not looked up in the backend until the
+	// record expires or the layer is pushed.
+	if lc.missing(dgst) {
+		atomic.AddUint64(&layerInfoCacheMetrics.Fetch.Hits, 1)
+		return nil, distribution.ErrUnknownLayer{FSLayer: manifest.FSLayer{BlobSum: dgst}}
+	}
 
 fallback:
 	atomic.AddUint64(&layerInfoCacheMetrics.Fetch.Misses, 1)
 	layer, err := lc.LayerService.Fetch(dgst)
 	if err != nil {
+		if _, ok := err.(distribution.ErrUnknownLayer); ok {
+			lc.addMissing(dgst)
+		}
 		return nil, err
 	}
 
@@ -128,6 +238,53 @@ fallback:
 	return layer, err
 }
 
+// Upload begins a layer upload, whose layer is added to the cache once the
+// upload is finished.
+func (lc *cachedLayerService) Upload() (distribution.LayerUpload, error) {
+	upload, err := lc.LayerService.Upload()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedLayerUpload{LayerUpload: upload, lc: lc}, nil
+}
+
+// Resume continues a layer upload, whose layer is added to the cache once
+// the upload is finished.
+func (lc *cachedLayerService) Resume(uuid string) (distribution.LayerUpload, error) {
+	upload, err := lc.LayerService.Resume(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedLayerUpload{LayerUpload: upload, lc: lc}, nil
+}
+
+// cachedLayerUpload adds the layer of a finished upload to the cache, which
+// also discards any record of it missing from the repository.
+type cachedLayerUpload struct {
+	distribution.LayerUpload
+	lc *cachedLayerService
+}
+
+func (clu *cachedLayerUpload) Finish(dgst digest.Digest) (distribution.Layer, error) {
+	layer, err := clu.LayerUpload.Finish(dgst)
+	if err != nil {
+		return nil, err
+	}
+
+	// The layer is linked into the repository with both the provided and the
+	// canonical digests.
+	lc := clu.lc
+	for _, linked := range []digest.Digest{dgst, layer.Digest()} {
+		if err := lc.cache.Add(lc.ctx, lc.repository.Name(), linked); err != nil {
+			ctxu.GetLogger(lc.ctx).Errorf("error adding %v@%v to cache: %v", lc.repository.Name(), linked, err)
+		}
+	}
+
+	return layer, nil
+}
+
 // extractLayerInfo pulls the layerInfo from the layer, attempting to get the
 // path information from either the concrete object or by resolving the
 // primary blob store path.
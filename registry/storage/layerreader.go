@@ -1,10 +1,13 @@
 package storage
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/registry/storage/driver"
 )
@@ -15,15 +18,23 @@ type layerReader struct {
 	fileReader
 
 	digest digest.Digest
+
+	// verify enables the verification of the digest of the content served
+	// by the layer.
+	verify bool
+
+	// encoding is the content encoding the layer is served with, if any.
+	encoding string
 }
 
 // newLayerReader returns a new layerReader with the digest, path and length,
 // eliding round trips to the storage backend.
-func newLayerReader(driver driver.StorageDriver, dgst digest.Digest, path string, length int64) (*layerReader, error) {
+func newLayerReader(driver driver.StorageDriver, dgst digest.Digest, path string, length int64, bufferSize int) (*layerReader, error) {
 	fr := &fileReader{
-		driver: driver,
-		path:   path,
-		size:   length,
+		driver:     driver,
+		path:       path,
+		size:       length,
+		bufferSize: bufferSize,
 	}
 
 	return &layerReader{
@@ -54,6 +65,11 @@ func (lr *layerReader) Close() error {
 func (lr *layerReader) Handler(r *http.Request) (h http.Handler, err error) {
 	var handlerFunc http.HandlerFunc
 
+	// Storage urls would not serve the content encoding of the layer.
+	if lr.encoding != "" {
+		return lr, nil
+	}
+
 	redirectURL, err := lr.fileReader.driver.URLFor(lr.ctx, lr.path, map[string]interface{}{"method": r.Method})
 
 	switch err {
@@ -63,10 +79,8 @@ func (lr *layerReader) Handler(r *http.Request) (h http.Handler, err error) {
 			http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 		}
 	case driver.ErrUnsupportedMethod:
-		handlerFunc = func(w http.ResponseWriter, r *http.Request) {
-			// Fallback to serving the content directly.
-			http.ServeContent(w, r, lr.digest.String(), lr.CreatedAt(), lr)
-		}
+		// Fallback to serving the content directly.
+		handlerFunc = lr.serveContent
 	default:
 		// Some unexpected error.
 		return nil, err
@@ -77,3 +91,119 @@ func (lr *layerReader) Handler(r *http.Request) (h http.Handler, err error) {
 		handlerFunc.ServeHTTP(w, r)
 	}), nil
 }
+
+// ServeHTTP serves the layer content directly, without asking the driver
+// for a url to redirect to.
+func (lr *layerReader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Content-Digest", lr.digest.String())
+	lr.serveContent(w, r)
+}
+
+// serveContent serves the layer content, supporting range requests. Layers
+// of drivers storing them in local files are served from the layer file
+// itself, so that the content is sent with sendfile when possible, instead of
+// being copied through the read buffer.
+func (lr *layerReader) serveContent(w http.ResponseWriter, r *http.Request) {
+	if lr.encoding != "" {
+		w.Header().Set("Content-Encoding", lr.encoding)
+	}
+
+	if lr.verify && r.Method == "GET" {
+		if vr, err := newVerifyingReader(lr); err == nil {
+			http.ServeContent(w, r, lr.digest.String(), lr.CreatedAt(), vr)
+			return
+		}
+
+		// The app warns at startup that tarsum layers are not verified.
+		context.GetLogger(lr.ctx).Debugf("layer %s cannot be verified as it is read", lr.digest)
+	}
+
+	if opener, ok := lr.driver.(driver.FileOpener); ok {
+		file, err := opener.OpenFile(lr.ctx, lr.path)
+		if err == nil {
+			defer file.Close()
+			http.ServeContent(w, r, lr.digest.String(), lr.CreatedAt(), file)
+			return
+		}
+
+		if err != driver.ErrUnsupportedMethod {
+			context.GetLogger(lr.ctx).Debugf("layer %s cannot be served from its file: %v", lr.digest, err)
+		}
+	}
+
+	http.ServeContent(w, r, lr.digest.String(), lr.CreatedAt(), lr)
+}
+
+// ErrLayerCorrupt is returned when the content of a layer read from the
+// storage backend does not match its digest.
+type ErrLayerCorrupt struct {
+	Digest digest.Digest
+	Actual digest.Digest
+}
+
+func (err ErrLayerCorrupt) Error() string {
+	return fmt.Sprintf("layer %s is corrupt: content digest is %s", err.Digest, err.Actual)
+}
+
+// verifyingReader verifies the digest of a layer as it is read sequentially
+// from its start. The end of the content is withheld if it does not match the
+// digest, so that clients receive a truncated layer rather than a corrupt
+// one. Reads starting elsewhere, such as range requests, are not verified.
+type verifyingReader struct {
+	lr       *layerReader
+	digester digest.Digester
+
+	// offset is the current offset in the layer, and hashed the size of
+	// the content digested from the start so far.
+	offset int64
+	hashed int64
+}
+
+// newVerifyingReader returns a reader verifying lr. It fails if the digest
+// of lr cannot be verified as it is read, as tarsum digests.
+func newVerifyingReader(lr *layerReader) (*verifyingReader, error) {
+	digester, err := digest.NewDigesterForAlgorithm(lr.digest.Algorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifyingReader{
+		lr:       lr,
+		digester: digester,
+	}, nil
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.lr.Read(p)
+	if n > 0 && vr.offset == vr.hashed {
+		vr.digester.Write(p[:n])
+		vr.hashed += int64(n)
+
+		if vr.hashed == vr.lr.size {
+			if actual := vr.digester.Digest(); actual != vr.lr.digest {
+				err := ErrLayerCorrupt{Digest: vr.lr.digest, Actual: actual}
+				context.GetLogger(vr.lr.ctx).Errorf("error serving layer: %v", err)
+				return 0, err
+			}
+		}
+	}
+	vr.offset += int64(n)
+
+	return n, err
+}
+
+func (vr *verifyingReader) Seek(offset int64, whence int) (int64, error) {
+	newOffset, err := vr.lr.Seek(offset, whence)
+	if err == nil {
+		vr.offset = newOffset
+
+		// Content is read again from the start after its type is sniffed.
+		if newOffset == 0 {
+			vr.digester.Reset()
+			vr.hashed = 0
+		}
+	}
+	return newOffset, err
+}
+
+var _ io.ReadSeeker = &verifyingReader{}
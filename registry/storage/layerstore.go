@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sync"
 	"time"
 
 	"code.google.com/p/go-uuid/uuid"
@@ -34,6 +35,90 @@ func (ls *layerStore) Exists(digest digest.Digest) (bool, error) {
 	return true, nil
 }
 
+// maxParallelLinkReads bounds the number of layer links read concurrently by
+// existsMany.
+const maxParallelLinkReads = 16
+
+// existsMany reports whether or not each of the layers exists, reading the
+// links of the repository in parallel and stating the blobs they point to
+// together, rather than following each link in turn.
+func (ls *layerStore) existsMany(dgsts []digest.Digest) ([]bool, []error) {
+	ctx := ls.repository.ctx
+	context.GetLogger(ctx).Debug("(*layerStore).existsMany")
+
+	exists := make([]bool, len(dgsts))
+	errs := make([]error, len(dgsts))
+
+	linked := make([]digest.Digest, len(dgsts))
+	parallel(len(dgsts), maxParallelLinkReads, func(i int) {
+		layerLinkPath, err := ls.repository.pm.path(layerLinkPathSpec{name: ls.repository.Name(), digest: dgsts[i]})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+
+		content, err := ls.repository.driver.GetContent(ctx, layerLinkPath)
+		if err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				errs[i] = err
+			}
+			return
+		}
+
+		linked[i], errs[i] = digest.ParseDigest(string(content))
+	})
+
+	var paths []string
+	var layers []int
+	for i, dgst := range linked {
+		if dgst == "" {
+			continue
+		}
+
+		blobPath, err := ls.repository.blobStore.path(dgst)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		paths = append(paths, blobPath)
+		layers = append(layers, i)
+	}
+
+	oks, statErrs := existsMany(ctx, ls.repository.driver, paths)
+	for j, i := range layers {
+		exists[i], errs[i] = oks[j], statErrs[j]
+	}
+
+	return exists, errs
+}
+
+// parallel calls fn for each index below n, with at most limit calls running
+// concurrently, and returns once they all return.
+func parallel(n, limit int, fn func(i int)) {
+	if n < limit {
+		limit = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(limit)
+	for w := 0; w < limit; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
 func (ls *layerStore) Fetch(dgst digest.Digest) (distribution.Layer, error) {
 	ctx := ls.repository.ctx
 	context.GetLogger(ctx).Debug("(*layerStore).Fetch")
@@ -46,11 +131,47 @@ func (ls *layerStore) Fetch(dgst digest.Digest) (distribution.Layer, error) {
 	if err != nil {
 		return nil, err
 	}
+	fr.bufferSize = ls.repository.registry.readBufferSize
 
-	return &layerReader{
+	lr := &layerReader{
 		fileReader: *fr,
 		digest:     dgst,
-	}, nil
+		verify:     ls.repository.registry.verifyReads,
+	}
+
+	if ls.repository.registry.contentEncodings {
+		lr.encoding, err = readLayerEncoding(ctx, ls.repository.driver, ls.repository.pm, ls.repository.Name(), dgst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lr, nil
+}
+
+// readLayerEncoding returns the content encoding the layer was pushed with to
+// the repository, or an empty string if it was pushed without one or with the
+// identity encoding.
+func readLayerEncoding(ctx context.Context, driver storagedriver.StorageDriver, pm *pathMapper, name string, dgst digest.Digest) (string, error) {
+	encodingPath, err := pm.path(layerEncodingPathSpec{name: name, digest: dgst})
+	if err != nil {
+		return "", err
+	}
+
+	encoding, err := driver.GetContent(ctx, encodingPath)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+
+	// Layers pushed again as identity are no longer encoded.
+	if string(encoding) == "identity" {
+		return "", nil
+	}
+
+	return string(encoding), nil
 }
 
 // Upload begins a layer upload, returning a handle. If the layer upload
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+)
+
+// LayerValidator validates the content of uploaded layers before they are
+// committed to the blob store and linked into their repository. Validators
+// may, for instance, submit the content to a malware scanner.
+type LayerValidator interface {
+	// ValidateLayer reads the content of the layer with the canonical
+	// digest, uploaded to the named repository. It returns a
+	// distribution.ErrLayerRejected if the layer must not be committed, and
+	// other errors if it could not be validated, which also fail the upload.
+	ValidateLayer(ctx context.Context, name string, dgst digest.Digest, content io.Reader) error
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +27,10 @@ type layerWriter struct {
 	startedAt         time.Time
 	resumableDigester digest.ResumableDigester
 
+	// encoding is the content encoding of the layer, recorded alongside its
+	// links when content encodings are enabled.
+	encoding string
+
 	// implementes io.WriteSeeker, io.ReaderFrom and io.Closer to satisfy
 	// LayerUpload Interface
 	bufferedFileWriter
@@ -42,6 +47,12 @@ func (lw *layerWriter) StartedAt() time.Time {
 	return lw.startedAt
 }
 
+// SetContentEncoding records the content encoding of the uploaded layer. It
+// is ignored unless content encodings are enabled.
+func (lw *layerWriter) SetContentEncoding(encoding string) {
+	lw.encoding = encoding
+}
+
 // Finish marks the upload as completed, returning a valid handle to the
 // uploaded layer. The final size and checksum are validated against the
 // contents of the uploaded layer. The checksum should be provided in the
@@ -79,6 +90,10 @@ func (lw *layerWriter) Finish(dgst digest.Digest) (distribution.Layer, error) {
 
 	}
 
+	if err := lw.validateContent(canonical); err != nil {
+		return nil, err
+	}
+
 	if err := lw.moveLayer(canonical); err != nil {
 		// TODO(stevvooe): Cleanup?
 		return nil, err
@@ -307,7 +322,7 @@ func (lw *layerWriter) validateLayer(dgst digest.Digest) (digest.Digest, error)
 
 		if canonical.Algorithm() == dgst.Algorithm() {
 			// Common case: client and server prefer the same canonical digest
-			// algorithm - currently SHA256.
+			// algorithm - SHA256 by default.
 			verified = dgst == canonical
 		} else {
 			// The client wants to use a different digest algorithm. They'll just
@@ -321,7 +336,10 @@ func (lw *layerWriter) validateLayer(dgst digest.Digest) (digest.Digest, error)
 	}
 
 	if fullHash {
-		digester := digest.NewCanonicalDigester()
+		digester, err := digest.NewDigesterForAlgorithm(lw.layerStore.repository.registry.digestAlgorithm)
+		if err != nil {
+			return "", err
+		}
 
 		digestVerifier, err := digest.NewDigestVerifier(dgst)
 		if err != nil {
@@ -347,15 +365,53 @@ func (lw *layerWriter) validateLayer(dgst digest.Digest) (digest.Digest, error)
 	if !verified {
 		context.GetLoggerWithField(lw.layerStore.repository.ctx, "canonical", dgst).
 			Errorf("canonical digest does match provided digest")
+
+		var received digest.Digest
+		if canonical.Algorithm() == dgst.Algorithm() {
+			received = canonical
+		}
+
 		return "", distribution.ErrLayerInvalidDigest{
-			Digest: dgst,
-			Reason: fmt.Errorf("content does not match digest"),
+			Digest:   dgst,
+			Received: received,
+			Reason:   fmt.Errorf("content does not match digest"),
 		}
 	}
 
 	return canonical, nil
 }
 
+// validateContent runs the layer validators of the registry on the uploaded
+// content, before it is moved into the blob store.
+func (lw *layerWriter) validateContent(canonical digest.Digest) error {
+	for _, validator := range lw.layerStore.repository.registry.layerValidators {
+		if err := lw.validateContentWith(validator, canonical); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateContentWith runs a layer validator on the uploaded content.
+func (lw *layerWriter) validateContentWith(validator LayerValidator, canonical digest.Digest) error {
+	ctx := lw.layerStore.repository.ctx
+
+	// Empty uploads may have no data file.
+	var content io.Reader = bytes.NewReader(nil)
+	if lw.size > 0 {
+		fr, err := newFileReader(ctx, lw.bufferedFileWriter.driver, lw.path)
+		if err != nil {
+			return err
+		}
+		defer fr.Close()
+
+		content = fr
+	}
+
+	return validator.ValidateLayer(ctx, lw.layerStore.repository.Name(), canonical, content)
+}
+
 // moveLayer moves the data into its final, hash-qualified destination,
 // identified by dgst. The layer should be validated before commencing the
 // move.
@@ -440,11 +496,34 @@ func (lw *layerWriter) linkLayer(canonical digest.Digest, aliases ...digest.Dige
 		if err := lw.layerStore.repository.driver.PutContent(ctx, layerLinkPath, []byte(canonical)); err != nil {
 			return err
 		}
+
+		if err := lw.linkLayerEncoding(dgst); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// linkLayerEncoding records the content encoding of the layer alongside the
+// layer link for dgst. Layers pushed again with an encoding replace the
+// recorded one, and layers pushed without one keep it.
+func (lw *layerWriter) linkLayerEncoding(dgst digest.Digest) error {
+	if lw.encoding == "" || !lw.layerStore.repository.registry.contentEncodings {
+		return nil
+	}
+
+	encodingPath, err := lw.layerStore.repository.pm.path(layerEncodingPathSpec{
+		name:   lw.layerStore.repository.Name(),
+		digest: dgst,
+	})
+	if err != nil {
+		return err
+	}
+
+	return lw.layerStore.repository.driver.PutContent(lw.layerStore.repository.ctx, encodingPath, []byte(lw.encoding))
+}
+
 // removeResources should clean up all resources associated with the upload
 // instance. An error will be returned if the clean up cannot proceed. If the
 // resources are already not present, no error will be returned.
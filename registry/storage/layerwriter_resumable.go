@@ -5,5 +5,11 @@ package storage
 import "github.com/docker/distribution/digest"
 
 func (lw *layerWriter) setupResumableDigester() {
-	lw.resumableDigester = digest.NewCanonicalResumableDigester()
+	resumableDigester, err := digest.NewResumableDigester(lw.layerStore.repository.registry.digestAlgorithm)
+	if err != nil {
+		// Fall back to hashing the whole layer on completion.
+		return
+	}
+
+	lw.resumableDigester = resumableDigester
 }
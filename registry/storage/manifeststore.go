@@ -49,7 +49,15 @@ func (ms *manifestStore) Put(manifest *manifest.SignedManifest) error {
 	}
 
 	// Now, tag the manifest
-	return ms.tagStore.tag(manifest.Tag, revision)
+	if err := ms.tagStore.tag(manifest.Tag, revision); err != nil {
+		return err
+	}
+
+	if ms.repository.registry.repositoryIndex {
+		return indexRepository(ms.repository.ctx, ms.repository.driver, ms.repository.Name())
+	}
+
+	return nil
 }
 
 // Delete removes the revision of the specified manfiest.
@@ -102,14 +110,36 @@ func (ms *manifestStore) verifyManifest(mnfst *manifest.SignedManifest) error {
 		}
 	}
 
-	for _, fsLayer := range mnfst.FSLayers {
-		exists, err := ms.repository.Layers().Exists(fsLayer.BlobSum)
-		if err != nil {
-			errs = append(errs, err)
+	fsLayers := mnfst.FSLayers
+	switch ms.repository.registry.blobVerification {
+	case VerifyTopBlob:
+		if len(fsLayers) > 1 {
+			fsLayers = fsLayers[:1]
+		}
+	case VerifyNoBlobs:
+		fsLayers = nil
+	}
+
+	// Layers are often repeated, notably empty ones, and are only checked
+	// and reported once.
+	checked := make(map[digest.Digest]struct{}, len(fsLayers))
+	dgsts := make([]digest.Digest, 0, len(fsLayers))
+	for _, fsLayer := range fsLayers {
+		if _, ok := checked[fsLayer.BlobSum]; ok {
+			continue
+		}
+		checked[fsLayer.BlobSum] = struct{}{}
+		dgsts = append(dgsts, fsLayer.BlobSum)
+	}
+
+	exists, existsErrs := layersExist(ms.repository.Layers(), dgsts)
+	for i, dgst := range dgsts {
+		if existsErrs[i] != nil {
+			errs = append(errs, existsErrs[i])
 		}
 
-		if !exists {
-			errs = append(errs, distribution.ErrUnknownLayer{FSLayer: fsLayer})
+		if !exists[i] {
+			errs = append(errs, distribution.ErrUnknownLayer{FSLayer: manifest.FSLayer{BlobSum: dgst}})
 		}
 	}
 
@@ -120,3 +150,26 @@ func (ms *manifestStore) verifyManifest(mnfst *manifest.SignedManifest) error {
 
 	return nil
 }
+
+// multiExister is implemented by the layer services which can check the
+// existence of many layers together.
+type multiExister interface {
+	existsMany(dgsts []digest.Digest) ([]bool, []error)
+}
+
+// layersExist reports whether or not each of the layers exists in layers,
+// checking them together if the layer service supports it, so that manifests
+// with many layers do not wait for a sequence of storage calls.
+func layersExist(layers distribution.LayerService, dgsts []digest.Digest) ([]bool, []error) {
+	if me, ok := layers.(multiExister); ok {
+		return me.existsMany(dgsts)
+	}
+
+	exists := make([]bool, len(dgsts))
+	errs := make([]error, len(dgsts))
+	for i, dgst := range dgsts {
+		exists[i], errs[i] = layers.Exists(dgst)
+	}
+
+	return exists, errs
+}
@@ -288,3 +288,166 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected an error deleting manifest by digest: %v", err)
 	}
 }
+
+// TestManifestStorageDigestAlgorithm checks that layers and manifests pushed
+// to a registry with a non-default canonical digest algorithm are stored
+// and found under digests of that algorithm.
+func TestManifestStorageDigestAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistryWithDriver(ctx, inmemory.New(), nil, CanonicalDigestAlgorithm("sha512"))
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	rs, ds, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file")
+	}
+
+	upload, err := repo.Layers().Upload()
+	if err != nil {
+		t.Fatalf("unexpected error creating test upload: %v", err)
+	}
+
+	if _, err := io.Copy(upload, rs); err != nil {
+		t.Fatalf("unexpected error copying to upload: %v", err)
+	}
+
+	layer, err := upload.Finish(digest.Digest(ds))
+	if err != nil {
+		t.Fatalf("unexpected error finishing upload: %v", err)
+	}
+
+	if layer.Digest().Algorithm() != "sha512" {
+		t.Fatalf("unexpected canonical layer digest: %v", layer.Digest())
+	}
+
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: "foo/bar",
+		Tag:  "thetag",
+		FSLayers: []manifest.FSLayer{
+			{BlobSum: layer.Digest()},
+		},
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	ms := repo.Manifests()
+	if err := ms.Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytesWithAlgorithm("sha512", payload)
+	if err != nil {
+		t.Fatalf("error getting manifest digest: %v", err)
+	}
+
+	fetchedByDigest, err := ms.Get(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest by digest: %v", err)
+	}
+
+	fetchedByTag, err := ms.GetByTag("thetag")
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest by tag: %v", err)
+	}
+
+	if !reflect.DeepEqual(fetchedByDigest, fetchedByTag) {
+		t.Fatalf("fetched manifests not equal: %#v != %#v", fetchedByDigest, fetchedByTag)
+	}
+
+	if _, err := manifest.Verify(fetchedByTag); err != nil {
+		t.Fatalf("unexpected error verifying manifest: %v", err)
+	}
+}
+
+func TestManifestStorageBlobVerification(t *testing.T) {
+	var missing []digest.Digest
+	for i := 0; i < 2; i++ {
+		_, ds, err := testutil.CreateRandomTarFile()
+		if err != nil {
+			t.Fatalf("unexpected error generating test layer file")
+		}
+		missing = append(missing, digest.Digest(ds))
+	}
+
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: "foo/bar",
+		Tag:  "thetag",
+		FSLayers: []manifest.FSLayer{
+			{BlobSum: missing[0]},
+			{BlobSum: missing[0]},
+			{BlobSum: missing[1]},
+		},
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		verification BlobVerification
+		unknown      []digest.Digest
+	}{
+		{VerifyAllBlobs, missing},
+		{VerifyTopBlob, missing[:1]},
+		{VerifyNoBlobs, nil},
+	} {
+		ctx := context.Background()
+		registry := NewRegistryWithDriver(ctx, inmemory.New(), nil, ManifestBlobVerification(testcase.verification))
+		repo, err := registry.Repository(ctx, "foo/bar")
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		err = repo.Manifests().Put(sm)
+		if testcase.unknown == nil {
+			if err != nil {
+				t.Fatalf("verification %d: unexpected error putting manifest: %v", testcase.verification, err)
+			}
+			continue
+		}
+
+		errs, ok := err.(distribution.ErrManifestVerification)
+		if !ok {
+			t.Fatalf("verification %d: expected manifest verification error, got %v", testcase.verification, err)
+		}
+
+		var unknown []digest.Digest
+		for _, err := range errs {
+			if err, ok := err.(distribution.ErrUnknownLayer); ok {
+				unknown = append(unknown, err.FSLayer.BlobSum)
+			}
+		}
+
+		if !reflect.DeepEqual(unknown, testcase.unknown) {
+			t.Fatalf("verification %d: unexpected unknown layers: %v != %v", testcase.verification, unknown, testcase.unknown)
+		}
+	}
+}
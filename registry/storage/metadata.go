@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// RepositoryMetadata holds the attributes of a repository kept alongside its
+// content. Repositories without metadata are private and undescribed.
+type RepositoryMetadata struct {
+	// Public marks the repository as pullable by anonymous clients, for the
+	// access controllers consulting repository visibility.
+	Public bool `json:"public"`
+
+	// Description is a free form description of the repository.
+	Description string `json:"description,omitempty"`
+}
+
+// GetRepositoryMetadata returns the metadata of the named repository, or the
+// zero metadata if none has been set.
+func GetRepositoryMetadata(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name string) (RepositoryMetadata, error) {
+	var metadata RepositoryMetadata
+	if err := rules.Validate(name); err != nil {
+		return metadata, err
+	}
+
+	metadataPath, err := defaultPathMapper.path(repositoryMetadataPathSpec{name: name})
+	if err != nil {
+		return metadata, err
+	}
+
+	content, err := driver.GetContent(ctx, metadataPath)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return metadata, nil
+		}
+		return metadata, err
+	}
+
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return metadata, fmt.Errorf("invalid metadata of repository %s: %v", name, err)
+	}
+
+	return metadata, nil
+}
+
+// PutRepositoryMetadata replaces the metadata of the named repository. The
+// metadata may be set before the repository is pushed, and is moved and
+// removed along with the repository by RenameRepository and
+// DeleteRepository.
+func PutRepositoryMetadata(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name string, metadata RepositoryMetadata) error {
+	if err := rules.Validate(name); err != nil {
+		return err
+	}
+
+	metadataPath, err := defaultPathMapper.path(repositoryMetadataPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	return driver.PutContent(ctx, metadataPath, content)
+}
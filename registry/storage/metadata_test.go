@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"golang.org/x/net/context"
+)
+
+func TestRepositoryMetadata(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+
+	metadata, err := GetRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting metadata: %v", err)
+	}
+
+	if metadata != (RepositoryMetadata{}) {
+		t.Fatalf("expected repositories without metadata to be private: %#v", metadata)
+	}
+
+	expected := RepositoryMetadata{Public: true, Description: "the foo bar"}
+	if err := PutRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/bar", expected); err != nil {
+		t.Fatalf("unexpected error putting metadata: %v", err)
+	}
+
+	if metadata, err := GetRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/bar"); err != nil || metadata != expected {
+		t.Fatalf("unexpected metadata: %#v, %v", metadata, err)
+	}
+
+	// The metadata follows the repository when it is renamed, and is removed
+	// with it.
+	if err := RenameRepository(ctx, driver, v2.DefaultNameRules, "foo/bar", "foo/baz"); err != nil {
+		t.Fatalf("unexpected error renaming repository: %v", err)
+	}
+
+	if metadata, err := GetRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/baz"); err != nil || metadata != expected {
+		t.Fatalf("unexpected metadata of renamed repository: %#v, %v", metadata, err)
+	}
+
+	if metadata, err := GetRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/bar"); err != nil || metadata != (RepositoryMetadata{}) {
+		t.Fatalf("unexpected metadata left under the old name: %#v, %v", metadata, err)
+	}
+
+	if err := DeleteRepository(ctx, driver, v2.DefaultNameRules, "foo/baz"); err != nil {
+		t.Fatalf("unexpected error deleting repository: %v", err)
+	}
+
+	if metadata, err := GetRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "foo/baz"); err != nil || metadata != (RepositoryMetadata{}) {
+		t.Fatalf("unexpected metadata of deleted repository: %#v, %v", metadata, err)
+	}
+
+	if err := PutRepositoryMetadata(ctx, driver, v2.DefaultNameRules, "Foo", expected); err == nil {
+		t.Fatalf("expected error putting metadata of an invalid repository name")
+	}
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"path"
 	"strings"
@@ -34,8 +36,11 @@ const storagePathVersion = "v2"
 // 						data
 // 						startedat
 // 						hashstates/<algorithm>/<offset>
+// 					-> _metadata/attributes
 //			-> blob/<algorithm>
 //				<split directory content addressable storage>
+//			-> index/repositories/<shard>/<escaped name>
+//			-> robots/<robot name>
 //
 // The storage backend layout is broken up into a content- addressable blob
 // store and repositories. The content-addressable blob store holds most data
@@ -83,6 +88,7 @@ const storagePathVersion = "v2"
 // 	Layers:
 //
 // 	layerLinkPathSpec:             <root>/v2/repositories/<name>/_layers/tarsum/<tarsum version>/<tarsum hash alg>/<tarsum hash>/link
+// 	layerEncodingPathSpec:         <root>/v2/repositories/<name>/_layers/tarsum/<tarsum version>/<tarsum hash alg>/<tarsum hash>/encoding
 //
 //	Uploads:
 //
@@ -90,11 +96,40 @@ const storagePathVersion = "v2"
 // 	uploadStartedAtPathSpec:        <root>/v2/repositories/<name>/_uploads/<uuid>/startedat
 // 	uploadHashStatePathSpec:        <root>/v2/repositories/<name>/_uploads/<uuid>/hashstates/<algorithm>/<offset>
 //
+//	Trash:
+//
+// 	trashTagsPathSpec:              <root>/v2/repositories/<name>/_trash/tags/
+// 	trashTagPathSpec:               <root>/v2/repositories/<name>/_trash/tags/<tag>/tag/
+// 	trashTagDeletedAtPathSpec:      <root>/v2/repositories/<name>/_trash/tags/<tag>/deletedat
+//
+//	Metadata:
+//
+// 	repositoryMetadataPathSpec:     <root>/v2/repositories/<name>/_metadata/attributes
+//
 //	Blob Store:
 //
 // 	blobPathSpec:                   <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>
 // 	blobDataPathSpec:               <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
 //
+// The optional repository index holds an empty entry for every repository
+// with manifests. Entries are sharded by the first two hex characters of the
+// sha256 of the repository name and named after the repository, with slashes
+// escaped as double underscores, which cannot appear in a valid name. This
+// lets repositories be enumerated without walking the repositories tree.
+//
+//	Repository Index:
+//
+// 	repositoryIndexPathSpec:        <root>/v2/index/repositories/
+// 	repositoryIndexEntryPathSpec:   <root>/v2/index/repositories/<shard>/<escaped name>
+//
+// Robot accounts are kept outside of the repositories, each in a JSON file
+// named after the account, holding the hash of its token and its access.
+//
+//	Robot Accounts:
+//
+// 	robotAccountsPathSpec:          <root>/v2/robots/
+// 	robotAccountPathSpec:           <root>/v2/robots/<robot name>
+//
 // For more information on the semantic meaning of each path and their
 // contents, please see the path spec documentation.
 type pathMapper struct {
@@ -237,6 +272,15 @@ func (pm *pathMapper) path(spec pathSpec) (string, error) {
 		layerLinkPathComponents := append(repoPrefix, v.name, "_layers")
 
 		return path.Join(path.Join(append(layerLinkPathComponents, components...)...), "link"), nil
+	case layerEncodingPathSpec:
+		components, err := digestPathComponents(v.digest, false)
+		if err != nil {
+			return "", err
+		}
+
+		layerLinkPathComponents := append(repoPrefix, v.name, "_layers")
+
+		return path.Join(path.Join(append(layerLinkPathComponents, components...)...), "encoding"), nil
 	case blobDataPathSpec:
 		components, err := digestPathComponents(v.digest, true)
 		if err != nil {
@@ -257,8 +301,29 @@ func (pm *pathMapper) path(spec pathSpec) (string, error) {
 			offset = "" // Limit to the prefix for listing offsets.
 		}
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.uuid, "hashstates", v.alg, offset)...), nil
+	case trashTagsPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_trash", "tags")...), nil
+	case trashTagPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_trash", "tags", v.tag, "tag")...), nil
+	case trashTagDeletedAtPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_trash", "tags", v.tag, "deletedat")...), nil
+	case repositoryMetadataPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_metadata", "attributes")...), nil
 	case repositoriesRootPathSpec:
 		return path.Join(repoPrefix...), nil
+	case repositoryIndexPathSpec:
+		return path.Join(append(rootPrefix, "index", "repositories")...), nil
+	case repositoryIndexEntryPathSpec:
+		root, err := pm.path(repositoryIndexPathSpec{})
+		if err != nil {
+			return "", err
+		}
+
+		return path.Join(root, repositoryIndexShard(v.name), repositoryIndexEscaper.Replace(v.name)), nil
+	case robotAccountsPathSpec:
+		return path.Join(append(rootPrefix, "robots")...), nil
+	case robotAccountPathSpec:
+		return path.Join(append(rootPrefix, "robots", v.name)...), nil
 	default:
 		// TODO(sday): This is an internal error. Ensure it doesn't escape (panic?).
 		return "", fmt.Errorf("unknown path spec: %#v", v)
@@ -386,6 +451,17 @@ type layerLinkPathSpec struct {
 
 func (layerLinkPathSpec) pathSpec() {}
 
+// layerEncodingPathSpec specifies the path of the content encoding of a
+// layer, such as gzip, kept alongside the layer link when the layer was
+// pushed with one. The contents are the value of the Content-Encoding header
+// the layer is served with.
+type layerEncodingPathSpec struct {
+	name   string
+	digest digest.Digest
+}
+
+func (layerEncodingPathSpec) pathSpec() {}
+
 // blobAlgorithmReplacer does some very simple path sanitization for user
 // input. Mostly, this is to provide some hierarchy for tarsum digests. Paths
 // should be "safe" before getting this far due to strict digest requirements
@@ -448,12 +524,87 @@ type uploadHashStatePathSpec struct {
 
 func (uploadHashStatePathSpec) pathSpec() {}
 
+// trashTagsPathSpec describes the directory holding the deleted tags of a
+// repository until they are restored or purged.
+type trashTagsPathSpec struct {
+	name string
+}
+
+func (trashTagsPathSpec) pathSpec() {}
+
+// trashTagPathSpec describes the copy of the tag directory of a deleted tag,
+// with its current link and index, as it was under manifestTagPathSpec.
+type trashTagPathSpec struct {
+	name string
+	tag  string
+}
+
+func (trashTagPathSpec) pathSpec() {}
+
+// trashTagDeletedAtPathSpec describes the file holding the deletion time of
+// a deleted tag, in RFC3339 format, from which its retention is counted.
+type trashTagDeletedAtPathSpec struct {
+	name string
+	tag  string
+}
+
+func (trashTagDeletedAtPathSpec) pathSpec() {}
+
+// repositoryMetadataPathSpec describes the file holding the attributes of a
+// repository, such as its visibility, in JSON.
+type repositoryMetadataPathSpec struct {
+	name string
+}
+
+func (repositoryMetadataPathSpec) pathSpec() {}
+
 // repositoriesRootPathSpec returns the root of repositories
 type repositoriesRootPathSpec struct {
 }
 
 func (repositoriesRootPathSpec) pathSpec() {}
 
+// repositoryIndexPathSpec describes the root of the repository index.
+type repositoryIndexPathSpec struct {
+}
+
+func (repositoryIndexPathSpec) pathSpec() {}
+
+// repositoryIndexEntryPathSpec describes the index entry of a repository.
+type repositoryIndexEntryPathSpec struct {
+	name string
+}
+
+func (repositoryIndexEntryPathSpec) pathSpec() {}
+
+// robotAccountsPathSpec describes the directory holding the robot accounts.
+type robotAccountsPathSpec struct {
+}
+
+func (robotAccountsPathSpec) pathSpec() {}
+
+// robotAccountPathSpec describes the file holding a robot account.
+type robotAccountPathSpec struct {
+	name string
+}
+
+func (robotAccountPathSpec) pathSpec() {}
+
+// repositoryIndexEscaper escapes the slashes of repository names so that
+// each index entry is a single path component. Repository name components
+// never contain consecutive separators, so the escaping is reversible.
+var repositoryIndexEscaper = strings.NewReplacer("/", "__")
+
+// repositoryIndexUnescaper reverses repositoryIndexEscaper.
+var repositoryIndexUnescaper = strings.NewReplacer("__", "/")
+
+// repositoryIndexShard returns the shard of the repository index holding
+// the entry of the named repository.
+func repositoryIndexShard(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(h[:1])
+}
+
 // digestPathComponents provides a consistent path breakdown for a given
 // digest. For a generic digest, it will be as follows:
 //
@@ -95,6 +95,13 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/pathmapper-test/repositories/foo/bar/_layers/tarsum/v1/test/abcdef/link",
 		},
+		{
+			spec: layerEncodingPathSpec{
+				name:   "foo/bar",
+				digest: "tarsum.v1+test:abcdef",
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_layers/tarsum/v1/test/abcdef/encoding",
+		},
 		{
 			spec: blobDataPathSpec{
 				digest: digest.Digest("tarsum.dev+sha512:abcdefabcdefabcdef908909909"),
@@ -122,6 +129,42 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/pathmapper-test/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/startedat",
 		},
+		{
+			spec: trashTagPathSpec{
+				name: "foo/bar",
+				tag:  "thetag",
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_trash/tags/thetag/tag",
+		},
+		{
+			spec: trashTagDeletedAtPathSpec{
+				name: "foo/bar",
+				tag:  "thetag",
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_trash/tags/thetag/deletedat",
+		},
+		{
+			spec: repositoryMetadataPathSpec{
+				name: "foo/bar",
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_metadata/attributes",
+		},
+		{
+			spec:     repositoryIndexPathSpec{},
+			expected: "/pathmapper-test/index/repositories",
+		},
+		{
+			spec: repositoryIndexEntryPathSpec{
+				name: "foo/bar",
+			},
+			expected: "/pathmapper-test/index/repositories/cc/foo__bar",
+		},
+		{
+			spec: robotAccountPathSpec{
+				name: "ci",
+			},
+			expected: "/pathmapper-test/robots/ci",
+		},
 	} {
 		p, err := pm.path(testcase.spec)
 		if err != nil {
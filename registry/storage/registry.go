@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/storage/cache"
@@ -15,26 +17,176 @@ type registry struct {
 	pm             *pathMapper
 	blobStore      *blobStore
 	layerInfoCache cache.LayerInfoCache
+
+	// negativeCacheTTL is the time layers found missing from a repository
+	// are recorded missing in the layer info cache, if it is positive.
+	negativeCacheTTL time.Duration
+
+	// repositoryIndex enables the maintenance of the repository index.
+	repositoryIndex bool
+
+	// readBufferSize is the size of the buffer used to read layers.
+	readBufferSize int
+
+	// verifyReads enables the verification of the digest of layers as they
+	// are served.
+	verifyReads bool
+
+	// contentEncodings enables storing the content encodings of pushed
+	// layers and serving layers with them.
+	contentEncodings bool
+
+	// digestAlgorithm is the algorithm of the canonical digests of pushed
+	// layers and manifests.
+	digestAlgorithm string
+
+	// blobVerification selects the layers of pushed manifests which must
+	// exist in the repository.
+	blobVerification BlobVerification
+
+	// nameRules are the rules repository names follow.
+	nameRules *v2.NameRules
+
+	// layerValidators validate the content of uploaded layers before they
+	// are committed.
+	layerValidators []LayerValidator
+}
+
+// BlobVerification selects the layers referenced by a pushed manifest which
+// must exist in the repository for the manifest to be accepted.
+type BlobVerification int
+
+const (
+	// VerifyAllBlobs requires every layer referenced by the manifest to
+	// exist. It is the default.
+	VerifyAllBlobs BlobVerification = iota
+
+	// VerifyTopBlob only requires the top layer of the manifest to exist,
+	// which is the layer described by the image configuration of the
+	// manifest history. Pushes of large images take a single check, at the
+	// risk of accepting manifests referencing missing base layers.
+	VerifyTopBlob
+
+	// VerifyNoBlobs accepts manifests without checking their layers, leaving
+	// clients responsible for pushing them first.
+	VerifyNoBlobs
+)
+
+// RegistryOption configures optional behavior of a registry created by
+// NewRegistryWithDriver.
+type RegistryOption func(reg *registry)
+
+// EnableRepositoryIndex returns an option that makes the registry add
+// repositories to the repository index as manifests are pushed to them.
+func EnableRepositoryIndex() RegistryOption {
+	return func(reg *registry) {
+		reg.repositoryIndex = true
+	}
+}
+
+// ReadBufferSize returns an option that sets the size of the buffer used to
+// read layers from the driver. Larger buffers mean fewer, larger reads from
+// the backend, which helps throughput of high latency drivers.
+func ReadBufferSize(size int) RegistryOption {
+	return func(reg *registry) {
+		reg.readBufferSize = size
+	}
+}
+
+// VerifyLayerReads returns an option that verifies the digest of layers as
+// they are served, withholding the end of layers whose content does not match
+// their digest, to catch corruption in the storage backend.
+func VerifyLayerReads() RegistryOption {
+	return func(reg *registry) {
+		reg.verifyReads = true
+	}
+}
+
+// ContentEncodings returns an option that stores the content encoding layers
+// are pushed with, such as gzip for pre-compressed content, and serves the
+// layers with it. Fetching a layer then reads its encoding from the storage
+// backend.
+func ContentEncodings() RegistryOption {
+	return func(reg *registry) {
+		reg.contentEncodings = true
+	}
+}
+
+// CanonicalDigestAlgorithm returns an option that sets the algorithm of the
+// canonical digests of pushed layers and manifests, which identify them in
+// the blob store. It is one of sha256, the default, sha384 or sha512.
+// Content pushed before the algorithm is changed keeps its digests.
+func CanonicalDigestAlgorithm(alg string) RegistryOption {
+	return func(reg *registry) {
+		reg.digestAlgorithm = alg
+	}
+}
+
+// ManifestBlobVerification returns an option that selects the layers of
+// pushed manifests which must exist in the repository.
+func ManifestBlobVerification(verification BlobVerification) RegistryOption {
+	return func(reg *registry) {
+		reg.blobVerification = verification
+	}
+}
+
+// RepositoryNameRules returns an option that sets the rules the names of the
+// repositories of the registry follow, instead of those of the
+// specification.
+func RepositoryNameRules(rules *v2.NameRules) RegistryOption {
+	return func(reg *registry) {
+		reg.nameRules = rules
+	}
+}
+
+// LayerValidators returns an option that validates the content of uploaded
+// layers with each of the validators, in order, before committing them.
+func LayerValidators(validators ...LayerValidator) RegistryOption {
+	return func(reg *registry) {
+		reg.layerValidators = append(reg.layerValidators, validators...)
+	}
+}
+
+// NegativeLayerCacheTTL returns an option that records layers found missing
+// from a repository in the layer info cache for the duration of ttl, if the
+// cache is a cache.NegativeCache, so that repeated checks for layers which do
+// not exist do not reach the backend. Layers pushed to the repository are
+// found immediately, but layers added to it otherwise, such as by another
+// registry instance with a separate cache, may be reported missing until the
+// records expire.
+func NegativeLayerCacheTTL(ttl time.Duration) RegistryOption {
+	return func(reg *registry) {
+		reg.negativeCacheTTL = ttl
+	}
 }
 
 // NewRegistryWithDriver creates a new registry instance from the provided
 // driver. The resulting registry may be shared by multiple goroutines but is
 // cheap to allocate.
-func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriver, layerInfoCache cache.LayerInfoCache) distribution.Namespace {
+func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriver, layerInfoCache cache.LayerInfoCache, options ...RegistryOption) distribution.Namespace {
 	bs := &blobStore{
 		driver: driver,
 		pm:     defaultPathMapper,
 		ctx:    ctx,
 	}
 
-	return &registry{
+	reg := &registry{
 		driver:    driver,
 		blobStore: bs,
 
 		// TODO(sday): This should be configurable.
-		pm:             defaultPathMapper,
-		layerInfoCache: layerInfoCache,
+		pm:              defaultPathMapper,
+		layerInfoCache:  layerInfoCache,
+		digestAlgorithm: "sha256",
+		nameRules:       v2.DefaultNameRules,
 	}
+
+	for _, option := range options {
+		option(reg)
+	}
+	bs.algorithm = reg.digestAlgorithm
+
+	return reg
 }
 
 // Scope returns the namespace scope for a registry. The registry
@@ -47,7 +199,7 @@ func (reg *registry) Scope() distribution.Scope {
 // Instances should not be shared between goroutines but are cheap to
 // allocate. In general, they should be request scoped.
 func (reg *registry) Repository(ctx context.Context, name string) (distribution.Repository, error) {
-	if err := v2.ValidateRespositoryName(name); err != nil {
+	if err := reg.nameRules.Validate(name); err != nil {
 		return nil, distribution.ErrRepositoryNameInvalid{
 			Name:   name,
 			Reason: err,
@@ -104,14 +256,24 @@ func (repo *repository) Layers() distribution.LayerService {
 		// access and layer data coupled in a single object. Work is already under
 		// way to decouple this.
 
-		return &cachedLayerService{
-			LayerService: ls,
-			repository:   repo,
-			ctx:          repo.ctx,
-			driver:       repo.driver,
-			blobStore:    repo.blobStore,
-			cache:        repo.registry.layerInfoCache,
+		lc := &cachedLayerService{
+			LayerService:   ls,
+			repository:     repo,
+			ctx:            repo.ctx,
+			driver:         repo.driver,
+			blobStore:      repo.blobStore,
+			cache:          repo.registry.layerInfoCache,
+			readBufferSize: repo.registry.readBufferSize,
+			verifyReads:    repo.registry.verifyReads,
+			encodings:      repo.registry.contentEncodings,
 		}
+
+		if negativeCache, ok := lc.cache.(cache.NegativeCache); ok && repo.registry.negativeCacheTTL > 0 {
+			lc.negativeCache = negativeCache
+			lc.negativeCacheTTL = repo.registry.negativeCacheTTL
+		}
+
+		return lc
 	}
 
 	return ls
@@ -0,0 +1,551 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// indexRepository adds the named repository to the repository index. Adding
+// a repository that is already indexed has no effect.
+func indexRepository(ctx context.Context, driver storagedriver.StorageDriver, name string) error {
+	entryPath, err := defaultPathMapper.path(repositoryIndexEntryPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	return driver.PutContent(ctx, entryPath, []byte{})
+}
+
+// unindexRepository removes the named repository from the repository index.
+func unindexRepository(ctx context.Context, driver storagedriver.StorageDriver, name string) error {
+	entryPath, err := defaultPathMapper.path(repositoryIndexEntryPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	if err := driver.Delete(ctx, entryPath); err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListRepositories returns the sorted names of the repositories in the
+// repository index. It only lists one directory per shard, instead of walking
+// the repositories tree. The index is only maintained by registries with the
+// index enabled, see RebuildRepositoryIndex to populate it.
+func ListRepositories(ctx context.Context, driver storagedriver.StorageDriver) ([]string, error) {
+	root, err := defaultPathMapper.path(repositoryIndexPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	shards, err := driver.List(ctx, root)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	var repositories []string
+	for _, shard := range shards {
+		entries, err := driver.List(ctx, shard)
+		if err != nil {
+			switch err.(type) {
+			case storagedriver.PathNotFoundError:
+				// The shard was removed since listing the root.
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		for _, entry := range entries {
+			repositories = append(repositories, repositoryIndexUnescaper.Replace(path.Base(entry)))
+		}
+	}
+
+	sort.Strings(repositories)
+	return repositories, nil
+}
+
+// RebuildRepositoryIndex walks the repositories tree and updates the
+// repository index to match it, adding the repositories with manifests that
+// are missing and removing the entries of repositories that no longer exist.
+// It returns the sorted names of the indexed repositories.
+func RebuildRepositoryIndex(ctx context.Context, driver storagedriver.StorageDriver) ([]string, error) {
+	repositories, err := FindRepositories(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{}, len(repositories))
+	for _, name := range repositories {
+		found[name] = struct{}{}
+	}
+
+	indexed, err := ListRepositories(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range indexed {
+		if _, ok := found[name]; ok {
+			delete(found, name)
+			continue
+		}
+
+		context.GetLogger(ctx).Infof("removing %s from the repository index", name)
+		if err := unindexRepository(ctx, driver, name); err != nil {
+			return nil, err
+		}
+	}
+
+	for name := range found {
+		context.GetLogger(ctx).Infof("adding %s to the repository index", name)
+		if err := indexRepository(ctx, driver, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ListRepositories(ctx, driver)
+}
+
+// FindRepositories walks the repositories tree and returns the sorted names
+// of the repositories with manifests. Unlike ListRepositories, it does not
+// depend on the repository index, but it lists every directory of the tree.
+func FindRepositories(ctx context.Context, driver storagedriver.StorageDriver) ([]string, error) {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	var repositories []string
+	err = Walk(ctx, driver, root, func(fileInfo storagedriver.FileInfo) error {
+		filePath := fileInfo.Path()
+		dir, file := path.Split(filePath)
+		if !strings.HasPrefix(file, "_") {
+			return nil
+		}
+
+		if file == "_manifests" {
+			repositories = append(repositories, strings.TrimPrefix(path.Clean(dir), root+"/"))
+		}
+
+		// Reserved directories only hold repository content.
+		return ErrSkipDir
+	})
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return nil, err
+		}
+	}
+
+	sort.Strings(repositories)
+	return repositories, nil
+}
+
+// NamespaceCount is the number of indexed repositories beneath a namespace.
+type NamespaceCount struct {
+	Name         string `json:"name"`
+	Repositories int    `json:"repositories"`
+}
+
+// ListNamespaces returns the namespaces directly beneath the parent
+// namespace, or the top-level namespaces if parent is empty, with the number
+// of indexed repositories beneath each of them, sorted by name. A repository
+// named like a namespace is not counted in it. Like ListRepositories, it
+// only reads the repository index.
+func ListNamespaces(ctx context.Context, driver storagedriver.StorageDriver, parent string) ([]NamespaceCount, error) {
+	repositories, err := ListRepositories(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if parent != "" {
+		prefix = parent + "/"
+	}
+
+	var namespaces []NamespaceCount
+	for _, name := range repositories {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		i := strings.Index(name[len(prefix):], "/")
+		if i < 0 {
+			continue
+		}
+
+		namespace := name[:len(prefix)+i]
+		// Repositories are sorted, so those of a namespace are adjacent.
+		if n := len(namespaces); n > 0 && namespaces[n-1].Name == namespace {
+			namespaces[n-1].Repositories++
+			continue
+		}
+		namespaces = append(namespaces, NamespaceCount{Name: namespace, Repositories: 1})
+	}
+
+	// Namespaces that prefix one another, such as foo and foo-bar, are not
+	// in the order of their repositories, foo-bar/baz and foo/bar.
+	sort.Sort(namespaceCountsByName(namespaces))
+	return namespaces, nil
+}
+
+type namespaceCountsByName []NamespaceCount
+
+func (s namespaceCountsByName) Len() int           { return len(s) }
+func (s namespaceCountsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s namespaceCountsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// DeleteTag removes the tag from the named repository, with the history of
+// its revisions. The manifests and layers it references are left in place.
+// A PathNotFoundError is returned if the tag does not exist.
+func DeleteTag(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name, tag string) error {
+	if err := rules.Validate(name); err != nil {
+		return err
+	}
+
+	tagPath, err := defaultPathMapper.path(manifestTagPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	return driver.Delete(ctx, tagPath)
+}
+
+// TagRevision is a manifest revision a tag has referenced.
+type TagRevision struct {
+	Digest digest.Digest `json:"digest"`
+
+	// Tagged is the last time the tag was set to the revision, according to
+	// the modification time of its index entry, or the zero time if the
+	// driver does not report it.
+	Tagged time.Time `json:"tagged"`
+
+	// Current is true for the revision the tag references now.
+	Current bool `json:"current"`
+}
+
+// TagHistory returns the revisions the tag of the named repository has
+// referenced, from its tag index, in the order they were last tagged. A
+// PathNotFoundError is returned if the tag does not exist.
+func TagHistory(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name, tag string) ([]TagRevision, error) {
+	if err := rules.Validate(name); err != nil {
+		return nil, err
+	}
+
+	currentPath, err := defaultPathMapper.path(manifestTagCurrentPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := driver.GetContent(ctx, currentPath)
+	if err != nil {
+		return nil, err
+	}
+	current := digest.Digest(content)
+
+	indexPath, err := defaultPathMapper.path(manifestTagIndexPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The index has a directory per digest algorithm of the revisions.
+	algorithms, err := driver.List(ctx, indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []TagRevision
+	for _, algorithm := range algorithms {
+		entries, err := driver.List(ctx, algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			revision := TagRevision{
+				Digest: digest.NewDigestFromHex(path.Base(algorithm), path.Base(entry)),
+			}
+			revision.Current = revision.Digest == current
+
+			linkPath, err := defaultPathMapper.path(manifestTagIndexEntryLinkPathSpec{
+				name:     name,
+				tag:      tag,
+				revision: revision.Digest,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			fi, err := driver.Stat(ctx, linkPath)
+			if err != nil {
+				return nil, err
+			}
+			revision.Tagged = fi.ModTime()
+
+			revisions = append(revisions, revision)
+		}
+	}
+
+	sort.Sort(tagRevisionsByTime(revisions))
+	return revisions, nil
+}
+
+type tagRevisionsByTime []TagRevision
+
+func (s tagRevisionsByTime) Len() int      { return len(s) }
+func (s tagRevisionsByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s tagRevisionsByTime) Less(i, j int) bool {
+	if !s[i].Tagged.Equal(s[j].Tagged) {
+		return s[i].Tagged.Before(s[j].Tagged)
+	}
+	// The current revision was tagged last, even within the resolution of
+	// the modification times.
+	if s[i].Current != s[j].Current {
+		return s[j].Current
+	}
+	return s[i].Digest < s[j].Digest
+}
+
+// DeleteRepository removes the manifests, layer links and uploads of the
+// named repository, and its entry in the repository index. Repositories
+// nested under its name are left in place, and so are the blobs it links. A
+// PathNotFoundError is returned if the repository does not exist.
+func DeleteRepository(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name string) error {
+	if err := rules.Validate(name); err != nil {
+		return err
+	}
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	repositoryPath := path.Join(root, name)
+	children, err := driver.List(ctx, repositoryPath)
+	if err != nil {
+		return err
+	}
+
+	deleted := false
+	for _, child := range children {
+		// Only the reserved directories belong to this repository.
+		if !strings.HasPrefix(path.Base(child), "_") {
+			continue
+		}
+
+		if err := driver.Delete(ctx, child); err != nil {
+			return err
+		}
+		deleted = true
+	}
+
+	if !deleted {
+		return storagedriver.PathNotFoundError{Path: repositoryPath}
+	}
+
+	return unindexRepository(ctx, driver, name)
+}
+
+// ErrRepositoryExists is returned when renaming a repository to the name of
+// an existing repository.
+type ErrRepositoryExists struct {
+	Name string
+}
+
+func (err ErrRepositoryExists) Error() string {
+	return fmt.Sprintf("repository %s already exists", err.Name)
+}
+
+// RenameRepository moves the manifests, tags and layer links of the named
+// repository to a new name, and its entry in the repository index. Only the
+// small link files are rewritten: the blobs they link are left in place.
+// Uploads in progress are discarded. An ErrRepositoryExists is
+// returned if a repository exists under the new name, and a
+// PathNotFoundError if the repository does not exist.
+func RenameRepository(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, from, to string) error {
+	if err := rules.Validate(from); err != nil {
+		return err
+	}
+	if err := rules.Validate(to); err != nil {
+		return err
+	}
+	if from == to {
+		return ErrRepositoryExists{Name: to}
+	}
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	toPath := path.Join(root, to)
+	existing, err := repositoryDirs(ctx, driver, toPath)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return ErrRepositoryExists{Name: to}
+	}
+
+	fromPath := path.Join(root, from)
+	dirs, err := repositoryDirs(ctx, driver, fromPath)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		return storagedriver.PathNotFoundError{Path: fromPath}
+	}
+
+	for _, dir := range dirs {
+		if path.Base(dir) == "_uploads" {
+			continue
+		}
+
+		if err := copyLinks(ctx, driver, dir, path.Join(toPath, path.Base(dir))); err != nil {
+			return err
+		}
+	}
+
+	entryPath, err := defaultPathMapper.path(repositoryIndexEntryPathSpec{name: from})
+	if err != nil {
+		return err
+	}
+
+	indexed, err := exists(ctx, driver, entryPath)
+	if err != nil {
+		return err
+	}
+	if indexed {
+		if err := indexRepository(ctx, driver, to); err != nil {
+			return err
+		}
+	}
+
+	return DeleteRepository(ctx, driver, rules, from)
+}
+
+// repositoryDirs returns the reserved directories of the repository at
+// repositoryPath, which is empty if the repository does not exist.
+func repositoryDirs(ctx context.Context, driver storagedriver.StorageDriver, repositoryPath string) ([]string, error) {
+	children, err := driver.List(ctx, repositoryPath)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dirs []string
+	for _, child := range children {
+		// Only the reserved directories belong to this repository.
+		if strings.HasPrefix(path.Base(child), "_") {
+			dirs = append(dirs, child)
+		}
+	}
+
+	return dirs, nil
+}
+
+// copyLinks copies the link files under src to dst.
+func copyLinks(ctx context.Context, driver storagedriver.StorageDriver, src, dst string) error {
+	children, err := driver.List(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		target := path.Join(dst, path.Base(child))
+
+		fi, err := driver.Stat(ctx, child)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			if err := copyLinks(ctx, driver, child, target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := driver.GetContent(ctx, child)
+		if err != nil {
+			return err
+		}
+
+		if err := driver.PutContent(ctx, target, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BlobRepositories reports whether the blob with the given digest is in the
+// global blob store, and returns the sorted names of the indexed repositories
+// linking it. The blob store only holds blobs by canonical digest, while
+// repositories also link layers by the digest they were pushed with, such as
+// a tarsum. Only the link of each indexed repository is checked, instead of
+// walking the repositories tree, so repositories missing from the index are
+// not reported.
+func BlobRepositories(ctx context.Context, driver storagedriver.StorageDriver, dgst digest.Digest) (bool, []string, error) {
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return false, nil, err
+	}
+
+	stored, err := exists(ctx, driver, blobPath)
+	if err != nil {
+		return false, nil, err
+	}
+
+	indexed, err := ListRepositories(ctx, driver)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var repositories []string
+	for _, name := range indexed {
+		linkPath, err := defaultPathMapper.path(layerLinkPathSpec{name: name, digest: dgst})
+		if err != nil {
+			return false, nil, err
+		}
+
+		linked, err := exists(ctx, driver, linkPath)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if linked {
+			repositories = append(repositories, name)
+		}
+	}
+
+	return stored, repositories, nil
+}
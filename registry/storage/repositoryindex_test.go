@@ -0,0 +1,478 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/testutil"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+)
+
+func TestRepositoryIndex(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	indexed := NewRegistryWithDriver(ctx, driver, nil, EnableRepositoryIndex())
+	unindexed := NewRegistryWithDriver(ctx, driver, nil)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	putManifest := func(registry distribution.Namespace, name string) {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		sm, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "latest",
+		}, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+	}
+
+	checkRepositories := func(repositories []string, expected ...string) {
+		if !reflect.DeepEqual(repositories, expected) {
+			t.Fatalf("unexpected repositories: %v != %v", repositories, expected)
+		}
+	}
+
+	repositories, err := ListRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing empty index: %v", err)
+	}
+	checkRepositories(repositories)
+
+	putManifest(indexed, "foo/bar")
+	putManifest(indexed, "foo/bar")
+	putManifest(indexed, "baz")
+	putManifest(unindexed, "foo/bar/qux")
+
+	repositories, err = ListRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+	checkRepositories(repositories, "baz", "foo/bar")
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error getting repositories root: %v", err)
+	}
+
+	if err := driver.Delete(ctx, root+"/baz"); err != nil {
+		t.Fatalf("unexpected error deleting repository: %v", err)
+	}
+
+	repositories, err = RebuildRepositoryIndex(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error rebuilding index: %v", err)
+	}
+	checkRepositories(repositories, "foo/bar", "foo/bar/qux")
+
+	repositories, err = ListRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+	checkRepositories(repositories, "foo/bar", "foo/bar/qux")
+}
+
+// TestDeleteRepository removes a tag and then a repository nesting another
+// one.
+func TestDeleteRepository(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil, EnableRepositoryIndex())
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	for _, name := range []string{"foo/bar", "foo/bar/qux"} {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		sm, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "latest",
+		}, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+	}
+
+	if err := DeleteTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != nil {
+		t.Fatalf("unexpected error deleting tag: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	if exists, err := repo.Manifests().ExistsByTag("latest"); err != nil || exists {
+		t.Fatalf("expected deleted tag to be gone: %v, %v", exists, err)
+	}
+
+	if err := DeleteTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err == nil {
+		t.Fatalf("expected an error deleting a missing tag")
+	}
+
+	if err := DeleteRepository(ctx, driver, v2.DefaultNameRules, "foo/bar"); err != nil {
+		t.Fatalf("unexpected error deleting repository: %v", err)
+	}
+
+	found, err := FindRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error finding repositories: %v", err)
+	}
+
+	indexed, err := ListRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+
+	expected := []string{"foo/bar/qux"}
+	if !reflect.DeepEqual(found, expected) || !reflect.DeepEqual(indexed, expected) {
+		t.Fatalf("unexpected repositories after deletion: %v, %v", found, indexed)
+	}
+
+	if err := DeleteRepository(ctx, driver, v2.DefaultNameRules, "foo/bar"); err == nil {
+		t.Fatalf("expected an error deleting a missing repository")
+	}
+
+	if err := DeleteRepository(ctx, driver, v2.DefaultNameRules, "../foo"); err == nil {
+		t.Fatalf("expected an error deleting an invalid repository")
+	}
+}
+
+func TestListNamespaces(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+
+	for _, name := range []string{"baz", "foo", "foo/bar", "foo/baz/qux", "foo-bar/baz", "library/ubuntu"} {
+		if err := indexRepository(ctx, driver, name); err != nil {
+			t.Fatalf("unexpected error indexing %s: %v", name, err)
+		}
+	}
+
+	for _, testcase := range []struct {
+		parent   string
+		expected []NamespaceCount
+	}{
+		{
+			expected: []NamespaceCount{
+				{Name: "foo", Repositories: 2},
+				{Name: "foo-bar", Repositories: 1},
+				{Name: "library", Repositories: 1},
+			},
+		},
+		{
+			parent:   "foo",
+			expected: []NamespaceCount{{Name: "foo/baz", Repositories: 1}},
+		},
+		{
+			parent: "library",
+		},
+	} {
+		namespaces, err := ListNamespaces(ctx, driver, testcase.parent)
+		if err != nil {
+			t.Fatalf("unexpected error listing namespaces of %q: %v", testcase.parent, err)
+		}
+
+		if !reflect.DeepEqual(namespaces, testcase.expected) {
+			t.Fatalf("unexpected namespaces of %q: %v != %v", testcase.parent, namespaces, testcase.expected)
+		}
+	}
+}
+
+func TestTagHistory(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	var revisions []digest.Digest
+	for _, architecture := range []string{"amd64", "arm", "amd64"} {
+		sm, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name:         "foo/bar",
+			Tag:          "latest",
+			Architecture: architecture,
+		}, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+
+		payload, err := sm.Payload()
+		if err != nil {
+			t.Fatalf("unexpected error getting manifest payload: %v", err)
+		}
+
+		dgst, err := digest.FromBytes(payload)
+		if err != nil {
+			t.Fatalf("unexpected error digesting manifest: %v", err)
+		}
+		revisions = append(revisions, dgst)
+	}
+
+	history, err := TagHistory(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting tag history: %v", err)
+	}
+
+	// Tagging the first revision again moves it to the end of the history.
+	if len(history) != 2 ||
+		history[0].Digest != revisions[1] || history[0].Current ||
+		history[1].Digest != revisions[2] || !history[1].Current {
+		t.Fatalf("unexpected tag history: %#v", history)
+	}
+
+	for _, revision := range history {
+		if revision.Tagged.IsZero() {
+			t.Fatalf("expected the time the revision was tagged: %#v", revision)
+		}
+	}
+
+	if _, err := TagHistory(ctx, driver, v2.DefaultNameRules, "foo/bar", "missing"); err == nil {
+		t.Fatalf("expected an error getting the history of a missing tag")
+	} else if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+		t.Fatalf("unexpected error getting the history of a missing tag: %v", err)
+	}
+}
+
+func TestRenameRepository(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil, EnableRepositoryIndex())
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	rs, ds, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file: %v", err)
+	}
+	tarsum := digest.Digest(ds)
+
+	for _, name := range []string{"foo/bar", "baz"} {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		m := manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "latest",
+		}
+
+		if name == "foo/bar" {
+			upload, err := repo.Layers().Upload()
+			if err != nil {
+				t.Fatalf("unexpected error creating test upload: %v", err)
+			}
+
+			if _, err := io.Copy(upload, rs); err != nil {
+				t.Fatalf("unexpected error copying to upload: %v", err)
+			}
+
+			if _, err := upload.Finish(tarsum); err != nil {
+				t.Fatalf("unexpected error finishing upload: %v", err)
+			}
+
+			m.FSLayers = []manifest.FSLayer{{BlobSum: tarsum}}
+		}
+
+		sm, err := manifest.Sign(&m, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+	}
+
+	if err := RenameRepository(ctx, driver, v2.DefaultNameRules, "foo/bar", "team/bar"); err != nil {
+		t.Fatalf("unexpected error renaming repository: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "team/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	sm, err := repo.Manifests().GetByTag("latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting renamed manifest: %v", err)
+	}
+
+	// Manifests keep the name they were signed with.
+	if sm.Name != "foo/bar" {
+		t.Fatalf("unexpected manifest name: %q", sm.Name)
+	}
+
+	if exists, err := repo.Layers().Exists(tarsum); err != nil || !exists {
+		t.Fatalf("expected renamed layer to exist: %v, %v", exists, err)
+	}
+
+	found, err := FindRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error finding repositories: %v", err)
+	}
+
+	indexed, err := ListRepositories(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+
+	expected := []string{"baz", "team/bar"}
+	if !reflect.DeepEqual(found, expected) || !reflect.DeepEqual(indexed, expected) {
+		t.Fatalf("unexpected repositories after rename: %v, %v", found, indexed)
+	}
+
+	if err := RenameRepository(ctx, driver, v2.DefaultNameRules, "team/bar", "baz"); err != (ErrRepositoryExists{Name: "baz"}) {
+		t.Fatalf("unexpected error renaming to an existing repository: %v", err)
+	}
+
+	if err := RenameRepository(ctx, driver, v2.DefaultNameRules, "foo/bar", "qux"); err == nil {
+		t.Fatalf("expected an error renaming a missing repository")
+	}
+
+	if err := RenameRepository(ctx, driver, v2.DefaultNameRules, "team/bar", "../qux"); err == nil {
+		t.Fatalf("expected an error renaming to an invalid name")
+	}
+}
+
+func TestBlobRepositories(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil, EnableRepositoryIndex())
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	rs, ds, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file: %v", err)
+	}
+	tarsum := digest.Digest(ds)
+
+	var canonical digest.Digest
+	for _, name := range []string{"foo/bar", "baz", "qux"} {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		m := manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "latest",
+		}
+
+		if name != "qux" {
+			if _, err := rs.Seek(0, os.SEEK_SET); err != nil {
+				t.Fatalf("unexpected error seeking layer: %v", err)
+			}
+
+			upload, err := repo.Layers().Upload()
+			if err != nil {
+				t.Fatalf("unexpected error creating test upload: %v", err)
+			}
+
+			if _, err := io.Copy(upload, rs); err != nil {
+				t.Fatalf("unexpected error copying to upload: %v", err)
+			}
+
+			layer, err := upload.Finish(tarsum)
+			if err != nil {
+				t.Fatalf("unexpected error finishing upload: %v", err)
+			}
+			canonical = layer.Digest()
+
+			m.FSLayers = []manifest.FSLayer{{BlobSum: tarsum}}
+		}
+
+		sm, err := manifest.Sign(&m, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+	}
+
+	for _, testcase := range []struct {
+		dgst         digest.Digest
+		stored       bool
+		repositories []string
+	}{
+		{canonical, true, []string{"baz", "foo/bar"}},
+		{tarsum, false, []string{"baz", "foo/bar"}},
+		{digest.Digest("sha256:" + strings.Repeat("0", 64)), false, nil},
+	} {
+		stored, repositories, err := BlobRepositories(ctx, driver, testcase.dgst)
+		if err != nil {
+			t.Fatalf("%s: unexpected error finding blob repositories: %v", testcase.dgst, err)
+		}
+
+		if stored != testcase.stored || !reflect.DeepEqual(repositories, testcase.repositories) {
+			t.Fatalf("%s: unexpected blob repositories: %t %v != %t %v", testcase.dgst, stored, repositories, testcase.stored, testcase.repositories)
+		}
+	}
+}
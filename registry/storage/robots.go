@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// robotNameRegexp matches the names of robot accounts, which are single
+// path components.
+var robotNameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+// ErrRobotAccountExists is returned when creating a robot account under the
+// name of an existing one.
+type ErrRobotAccountExists struct {
+	Name string
+}
+
+func (err ErrRobotAccountExists) Error() string {
+	return fmt.Sprintf("robot account %s already exists", err.Name)
+}
+
+// RobotAccess grants actions on the repositories whose names match the
+// repository pattern, in the syntax of path.Match. The "*" action grants all
+// actions.
+type RobotAccess struct {
+	Repository string   `json:"repository"`
+	Actions    []string `json:"actions"`
+}
+
+// RobotAccount is an account for automated clients, authenticating with a
+// static token whose hash only is stored.
+type RobotAccount struct {
+	Name      string        `json:"name"`
+	TokenHash string        `json:"tokenHash,omitempty"`
+	Access    []RobotAccess `json:"access"`
+	Created   time.Time     `json:"created"`
+}
+
+// ValidateRobotAccount returns an error if the robot account has an invalid
+// name or access.
+func ValidateRobotAccount(account RobotAccount) error {
+	if !robotNameRegexp.MatchString(account.Name) {
+		return fmt.Errorf("invalid robot account name: %q", account.Name)
+	}
+
+	for _, access := range account.Access {
+		if _, err := path.Match(access.Repository, ""); err != nil || access.Repository == "" {
+			return fmt.Errorf("invalid repository pattern for robot account %s: %q", account.Name, access.Repository)
+		}
+
+		if len(access.Actions) == 0 {
+			return fmt.Errorf("no actions granted on %s to robot account %s", access.Repository, account.Name)
+		}
+	}
+
+	return nil
+}
+
+// CreateRobotAccount stores a new robot account. An ErrRobotAccountExists is
+// returned if an account with the same name exists.
+func CreateRobotAccount(ctx context.Context, driver storagedriver.StorageDriver, account RobotAccount) error {
+	if err := ValidateRobotAccount(account); err != nil {
+		return err
+	}
+
+	accountPath, err := defaultPathMapper.path(robotAccountPathSpec{name: account.Name})
+	if err != nil {
+		return err
+	}
+
+	if exists, err := exists(ctx, driver, accountPath); err != nil {
+		return err
+	} else if exists {
+		return ErrRobotAccountExists{Name: account.Name}
+	}
+
+	content, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+
+	return driver.PutContent(ctx, accountPath, content)
+}
+
+// GetRobotAccount returns the named robot account. A PathNotFoundError is
+// returned if it does not exist.
+func GetRobotAccount(ctx context.Context, driver storagedriver.StorageDriver, name string) (RobotAccount, error) {
+	var account RobotAccount
+	if !robotNameRegexp.MatchString(name) {
+		return account, fmt.Errorf("invalid robot account name: %q", name)
+	}
+
+	accountPath, err := defaultPathMapper.path(robotAccountPathSpec{name: name})
+	if err != nil {
+		return account, err
+	}
+
+	content, err := driver.GetContent(ctx, accountPath)
+	if err != nil {
+		return account, err
+	}
+
+	if err := json.Unmarshal(content, &account); err != nil {
+		return account, fmt.Errorf("invalid robot account %s: %v", name, err)
+	}
+
+	return account, nil
+}
+
+// RobotAccounts returns the robot accounts, sorted by name.
+func RobotAccounts(ctx context.Context, driver storagedriver.StorageDriver) ([]RobotAccount, error) {
+	accountsPath, err := defaultPathMapper.path(robotAccountsPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := driver.List(ctx, accountsPath)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	var accounts []RobotAccount
+	for _, entry := range entries {
+		account, err := GetRobotAccount(ctx, driver, path.Base(entry))
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	sort.Sort(robotAccountsByName(accounts))
+	return accounts, nil
+}
+
+type robotAccountsByName []RobotAccount
+
+func (s robotAccountsByName) Len() int           { return len(s) }
+func (s robotAccountsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s robotAccountsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// DeleteRobotAccount removes the named robot account, revoking its token. A
+// PathNotFoundError is returned if it does not exist.
+func DeleteRobotAccount(ctx context.Context, driver storagedriver.StorageDriver, name string) error {
+	if !robotNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid robot account name: %q", name)
+	}
+
+	accountPath, err := defaultPathMapper.path(robotAccountPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	return driver.Delete(ctx, accountPath)
+}
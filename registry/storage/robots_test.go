@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"golang.org/x/net/context"
+)
+
+func TestRobotAccounts(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+
+	if accounts, err := RobotAccounts(ctx, driver); err != nil || len(accounts) != 0 {
+		t.Fatalf("unexpected robot accounts: %#v, %v", accounts, err)
+	}
+
+	for _, name := range []string{"deploy", "ci"} {
+		if err := CreateRobotAccount(ctx, driver, RobotAccount{
+			Name:      name,
+			TokenHash: "sha256:" + name,
+			Access: []RobotAccess{
+				{Repository: "team/*", Actions: []string{"pull", "push"}},
+			},
+			Created: time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("unexpected error creating robot account %s: %v", name, err)
+		}
+	}
+
+	if err := CreateRobotAccount(ctx, driver, RobotAccount{Name: "ci"}); err != (ErrRobotAccountExists{Name: "ci"}) {
+		t.Fatalf("unexpected error creating existing robot account: %v", err)
+	}
+
+	for _, account := range []RobotAccount{
+		{Name: "CI"},
+		{Name: "ci/jobs"},
+		{Name: "builder", Access: []RobotAccess{{Repository: "[", Actions: []string{"pull"}}}},
+		{Name: "builder", Access: []RobotAccess{{Repository: "team/*"}}},
+	} {
+		if err := CreateRobotAccount(ctx, driver, account); err == nil {
+			t.Fatalf("expected error creating invalid robot account %#v", account)
+		}
+	}
+
+	account, err := GetRobotAccount(ctx, driver, "ci")
+	if err != nil {
+		t.Fatalf("unexpected error getting robot account: %v", err)
+	}
+
+	if account.Name != "ci" || account.TokenHash != "sha256:ci" || len(account.Access) != 1 || account.Created.IsZero() {
+		t.Fatalf("unexpected robot account: %#v", account)
+	}
+
+	accounts, err := RobotAccounts(ctx, driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing robot accounts: %v", err)
+	}
+
+	if len(accounts) != 2 || accounts[0].Name != "ci" || accounts[1].Name != "deploy" {
+		t.Fatalf("unexpected robot accounts: %#v", accounts)
+	}
+
+	if err := DeleteRobotAccount(ctx, driver, "ci"); err != nil {
+		t.Fatalf("unexpected error deleting robot account: %v", err)
+	}
+
+	if _, err := GetRobotAccount(ctx, driver, "ci"); err == nil {
+		t.Fatalf("expected deleted robot account to be gone")
+	} else if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+		t.Fatalf("unexpected error getting deleted robot account: %v", err)
+	}
+
+	if err := DeleteRobotAccount(ctx, driver, "ci"); err == nil {
+		t.Fatalf("expected error deleting missing robot account")
+	}
+}
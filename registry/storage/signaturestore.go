@@ -25,16 +25,24 @@ func (s *signatureStore) Get(dgst digest.Digest) ([][]byte, error) {
 		return nil, err
 	}
 
-	// Need to append signature digest algorithm to path to get all items.
-	// Perhaps, this should be in the pathMapper but it feels awkward. This
-	// can be eliminated by implementing listAll on drivers.
-	signaturesPath = path.Join(signaturesPath, "sha256")
-
-	signaturePaths, err := s.driver.List(s.repository.ctx, signaturesPath)
+	// Need to list the signature digest algorithms under the path to get
+	// all items. Perhaps, this should be in the pathMapper but it feels
+	// awkward. This can be eliminated by implementing listAll on drivers.
+	algorithmPaths, err := s.driver.List(s.repository.ctx, signaturesPath)
 	if err != nil {
 		return nil, err
 	}
 
+	var signaturePaths []string
+	for _, algorithmPath := range algorithmPaths {
+		paths, err := s.driver.List(s.repository.ctx, algorithmPath)
+		if err != nil {
+			return nil, err
+		}
+
+		signaturePaths = append(signaturePaths, paths...)
+	}
+
 	var wg sync.WaitGroup
 	type result struct {
 		index     int
@@ -128,17 +128,22 @@ func (ts *tagStore) revisions(tag string) ([]digest.Digest, error) {
 		return nil, err
 	}
 
-	// TODO(stevvooe): Need to append digest alg to get listing of revisions.
-	manifestTagIndexPath = path.Join(manifestTagIndexPath, "sha256")
-
-	entries, err := ts.driver.List(ts.repository.ctx, manifestTagIndexPath)
+	// The index has a directory per digest algorithm of the revisions.
+	algorithms, err := ts.driver.List(ts.repository.ctx, manifestTagIndexPath)
 	if err != nil {
 		return nil, err
 	}
 
 	var revisions []digest.Digest
-	for _, entry := range entries {
-		revisions = append(revisions, digest.NewDigestFromHex("sha256", path.Base(entry)))
+	for _, algorithm := range algorithms {
+		entries, err := ts.driver.List(ts.repository.ctx, algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			revisions = append(revisions, digest.NewDigestFromHex(path.Base(algorithm), path.Base(entry)))
+		}
 	}
 
 	return revisions, nil
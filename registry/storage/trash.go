@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// ErrTagExists is returned when restoring a deleted tag which has been
+// pushed again since it was deleted.
+type ErrTagExists struct {
+	Name string
+	Tag  string
+}
+
+func (err ErrTagExists) Error() string {
+	return fmt.Sprintf("tag %s:%s already exists", err.Name, err.Tag)
+}
+
+// TrashedTag is a deleted tag kept in the trash of its repository.
+type TrashedTag struct {
+	Tag     string    `json:"tag"`
+	Deleted time.Time `json:"deleted"`
+}
+
+// TrashTag deletes the tag from the named repository like DeleteTag, but
+// keeps a copy of the tag, with the history of its revisions, in the trash
+// of the repository, from which RestoreTag can restore it until PurgeTrash
+// removes it. A tag deleted again replaces its previous copy in the trash. A
+// PathNotFoundError is returned if the tag does not exist.
+func TrashTag(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name, tag string) error {
+	if err := rules.Validate(name); err != nil {
+		return err
+	}
+
+	tagPath, err := defaultPathMapper.path(manifestTagPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := driver.Stat(ctx, tagPath); err != nil {
+		return err
+	}
+
+	trashPath, err := defaultPathMapper.path(trashTagPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := driver.Delete(ctx, path.Dir(trashPath)); err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+
+	// The deletion time is written first so that a partial copy is purged
+	// with the rest of the trash.
+	deletedAtPath, err := defaultPathMapper.path(trashTagDeletedAtPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := driver.PutContent(ctx, deletedAtPath, []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+
+	if err := copyLinks(ctx, driver, tagPath, trashPath); err != nil {
+		return err
+	}
+
+	return driver.Delete(ctx, tagPath)
+}
+
+// RestoreTag moves the deleted tag back from the trash of the named
+// repository. An ErrTagExists is returned if the tag has been pushed again
+// since it was deleted, and a PathNotFoundError if it is not in the trash.
+func RestoreTag(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name, tag string) error {
+	if err := rules.Validate(name); err != nil {
+		return err
+	}
+
+	trashPath, err := defaultPathMapper.path(trashTagPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := driver.Stat(ctx, trashPath); err != nil {
+		return err
+	}
+
+	tagPath, err := defaultPathMapper.path(manifestTagPathSpec{
+		name: name,
+		tag:  tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if exists, err := exists(ctx, driver, tagPath); err != nil {
+		return err
+	} else if exists {
+		return ErrTagExists{Name: name, Tag: tag}
+	}
+
+	if err := copyLinks(ctx, driver, trashPath, tagPath); err != nil {
+		return err
+	}
+
+	return driver.Delete(ctx, path.Dir(trashPath))
+}
+
+// TrashedTags returns the deleted tags in the trash of the named repository,
+// sorted by tag.
+func TrashedTags(ctx context.Context, driver storagedriver.StorageDriver, rules *v2.NameRules, name string) ([]TrashedTag, error) {
+	if err := rules.Validate(name); err != nil {
+		return nil, err
+	}
+
+	tagsPath, err := defaultPathMapper.path(trashTagsPathSpec{name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := driver.List(ctx, tagsPath)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	var tags []TrashedTag
+	for _, entry := range entries {
+		deleted, err := readDeletedAt(ctx, driver, path.Join(entry, "deletedat"))
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, TrashedTag{
+			Tag:     path.Base(entry),
+			Deleted: deleted,
+		})
+	}
+
+	sort.Sort(trashedTagsByTag(tags))
+	return tags, nil
+}
+
+type trashedTagsByTag []TrashedTag
+
+func (s trashedTagsByTag) Len() int           { return len(s) }
+func (s trashedTagsByTag) Less(i, j int) bool { return s[i].Tag < s[j].Tag }
+func (s trashedTagsByTag) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// PurgeTrash removes the tags deleted before olderThan from the trash of
+// every repository, walking the repositories tree. The trash directories of
+// the tags removed, or that would be removed unless actuallyDelete is set,
+// and the errors encountered are returned.
+func PurgeTrash(ctx context.Context, driver storagedriver.StorageDriver, olderThan time.Time, actuallyDelete bool) ([]string, []error) {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var deleted []string
+	var errs []error
+	err = Walk(ctx, driver, root, func(fileInfo storagedriver.FileInfo) error {
+		_, file := path.Split(fileInfo.Path())
+		if len(file) == 0 || file[0] != '_' {
+			return nil
+		}
+
+		// Reserved directories other than the trash hold repository content.
+		if file != "_trash" {
+			return ErrSkipDir
+		}
+
+		entries, err := driver.List(ctx, path.Join(fileInfo.Path(), "tags"))
+		if err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+				errs = append(errs, err)
+			}
+			return ErrSkipDir
+		}
+
+		for _, entry := range entries {
+			deletedAt, err := readDeletedAt(ctx, driver, path.Join(entry, "deletedat"))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			if !deletedAt.Before(olderThan) {
+				continue
+			}
+
+			context.GetLogger(ctx).Infof("removing %s from the trash, deleted at %s", entry, deletedAt)
+			if actuallyDelete {
+				if err := driver.Delete(ctx, entry); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			deleted = append(deleted, entry)
+		}
+
+		return ErrSkipDir
+	})
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			errs = append(errs, err)
+		}
+	}
+
+	return deleted, errs
+}
+
+// readDeletedAt reads the deletion time of a deleted tag.
+func readDeletedAt(ctx context.Context, driver storagedriver.StorageDriver, deletedAtPath string) (time.Time, error) {
+	content, err := driver.GetContent(ctx, deletedAtPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, string(content))
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/api/v2"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+)
+
+func TestTrashTag(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	sm, err := manifest.Sign(&manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: "foo/bar",
+		Tag:  "latest",
+	}, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if err := TrashTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != nil {
+		t.Fatalf("unexpected error trashing tag: %v", err)
+	}
+
+	if exists, err := repo.Manifests().ExistsByTag("latest"); err != nil || exists {
+		t.Fatalf("expected trashed tag to be gone: %v, %v", exists, err)
+	}
+
+	trashed, err := TrashedTags(ctx, driver, v2.DefaultNameRules, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error listing trash: %v", err)
+	}
+
+	if len(trashed) != 1 || trashed[0].Tag != "latest" || trashed[0].Deleted.IsZero() {
+		t.Fatalf("unexpected trash: %#v", trashed)
+	}
+
+	if err := RestoreTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != nil {
+		t.Fatalf("unexpected error restoring tag: %v", err)
+	}
+
+	if _, err := repo.Manifests().GetByTag("latest"); err != nil {
+		t.Fatalf("unexpected error getting restored tag: %v", err)
+	}
+
+	if history, err := TagHistory(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != nil || len(history) != 1 {
+		t.Fatalf("expected the history of the restored tag: %#v, %v", history, err)
+	}
+
+	if err := RestoreTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err == nil {
+		t.Fatalf("expected an error restoring a tag missing from the trash")
+	} else if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+		t.Fatalf("unexpected error restoring a tag missing from the trash: %v", err)
+	}
+
+	// A tag pushed again after its deletion is not overwritten.
+	if err := TrashTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != nil {
+		t.Fatalf("unexpected error trashing tag: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if err := RestoreTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "latest"); err != (ErrTagExists{Name: "foo/bar", Tag: "latest"}) {
+		t.Fatalf("unexpected error restoring a tag pushed again: %v", err)
+	}
+
+	if err := TrashTag(ctx, driver, v2.DefaultNameRules, "foo/bar", "missing"); err == nil {
+		t.Fatalf("expected an error trashing a missing tag")
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, nil)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	for _, name := range []string{"foo/bar", "baz"} {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		sm, err := manifest.Sign(&manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "latest",
+		}, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		if err := repo.Manifests().Put(sm); err != nil {
+			t.Fatalf("unexpected error putting manifest: %v", err)
+		}
+
+		if err := TrashTag(ctx, driver, v2.DefaultNameRules, name, "latest"); err != nil {
+			t.Fatalf("unexpected error trashing tag: %v", err)
+		}
+	}
+
+	deleted, errs := PurgeTrash(ctx, driver, time.Now().Add(-time.Hour), true)
+	if len(deleted) != 0 || len(errs) != 0 {
+		t.Fatalf("unexpected purge of recent trash: %v, %v", deleted, errs)
+	}
+
+	deleted, errs = PurgeTrash(ctx, driver, time.Now().Add(time.Hour), false)
+	if len(deleted) != 2 || len(errs) != 0 {
+		t.Fatalf("unexpected dry run purge: %v, %v", deleted, errs)
+	}
+
+	if trashed, err := TrashedTags(ctx, driver, v2.DefaultNameRules, "baz"); err != nil || len(trashed) != 1 {
+		t.Fatalf("expected trash to be kept by a dry run: %#v, %v", trashed, err)
+	}
+
+	deleted, errs = PurgeTrash(ctx, driver, time.Now().Add(time.Hour), true)
+	if len(deleted) != 2 || len(errs) != 0 {
+		t.Fatalf("unexpected purge: %v, %v", deleted, errs)
+	}
+
+	for _, name := range []string{"foo/bar", "baz"} {
+		if trashed, err := TrashedTags(ctx, driver, v2.DefaultNameRules, name); err != nil || len(trashed) != 0 {
+			t.Fatalf("expected trash of %s to be purged: %#v, %v", name, trashed, err)
+		}
+	}
+}